@@ -0,0 +1,82 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	DevicePluginConfigCRDName = "DevicePluginConfig"
+)
+
+// DevicePluginConfigSpec defines the desired state of DevicePluginConfig
+type DevicePluginConfigSpec struct {
+	// RDMA resource pools merged into the configuration of the RDMA shared device plugin deployed by NicClusterPolicy,
+	// the pool names must be unique across NicClusterPolicy and all DevicePluginConfigs
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MinItems=1
+	RdmaSharedResourcePools []RdmaSharedDevicePoolSpec `json:"rdmaSharedResourcePools,omitempty"`
+	// Device plugin configuration used by the device plugins of NicClusterPolicy which reference
+	// the DevicePluginConfig by name with configRef, e.g. the resourceList of the SR-IOV device plugin
+	// +optional
+	Config string `json:"config,omitempty"`
+}
+
+// DevicePluginConfigStatus defines the observed state of DevicePluginConfig
+type DevicePluginConfigStatus struct {
+	// Reflects whether the resource pools are merged into the device plugin configuration or the config is used
+	// by a device plugin which references the DevicePluginConfig, ignore means neither of them is used
+	// +kubebuilder:validation:Enum={"ready", "ignore", "error"}
+	State State `json:"state"`
+	// Informative string in case the resource pools or the config are not used
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:object:generate=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.state`,priority=0
+// +kubebuilder:printcolumn:name="Age",type=string,JSONPath=`.metadata.creationTimestamp`,priority=0
+
+// DevicePluginConfig is the Schema for the devicepluginconfigs API, it contributes device plugin configuration
+// which is merged into the device plugins deployed by NicClusterPolicy or referenced by them
+type DevicePluginConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DevicePluginConfigSpec   `json:"spec,omitempty"`
+	Status DevicePluginConfigStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:object:generate=true
+
+// DevicePluginConfigList contains a list of DevicePluginConfig
+type DevicePluginConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DevicePluginConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DevicePluginConfig{}, &DevicePluginConfigList{})
+}
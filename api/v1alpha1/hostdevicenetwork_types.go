@@ -24,6 +24,15 @@ const (
 	HostDeviceNetworkCRDName = "HostDeviceNetwork"
 )
 
+const (
+	// HostDeviceTypeNetDevice is the device type of kernel network devices moved to the network namespace of the pod
+	HostDeviceTypeNetDevice = "netdevice"
+	// HostDeviceTypeDPDK is the device type of devices bound to a userspace driver and used by DPDK workloads
+	HostDeviceTypeDPDK = "dpdk"
+	// DefaultDPDKDriver is the userspace driver of the DPDK devices if it is not set in the spec
+	DefaultDPDKDriver = "vfio-pci"
+)
+
 // HostDeviceNetworkSpec defines the desired state of HostDeviceNetwork
 type HostDeviceNetworkSpec struct {
 	// Namespace of the NetworkAttachmentDefinition custom resource
@@ -32,6 +41,33 @@ type HostDeviceNetworkSpec struct {
 	ResourceName string `json:"resourceName,omitempty"`
 	// IPAM configuration to be used for this network
 	IPAM string `json:"ipam,omitempty"`
+	// CNIVersion of the generated NetworkAttachmentDefinition config, defaults to 0.3.1
+	// +optional
+	// +kubebuilder:validation:Enum={"0.3.0", "0.3.1", "0.4.0", "1.0.0"}
+	CNIVersion string `json:"cniVersion,omitempty"`
+	// DefaultRoute controls whether the network becomes the default route of the pod: if true, default routes
+	// are added to the routes of the IPAM configuration, if false, the IPAM configuration must not set a default
+	// route. The IPAM configuration is used as is if not set
+	// +optional
+	DefaultRoute *bool `json:"defaultRoute,omitempty"`
+	// DeviceType of the host devices of the network, "netdevice" devices are moved to the network namespace
+	// of the pod and configured by the IPAM, "dpdk" devices are bound to a userspace driver and used by DPDK
+	// workloads, IPAM and DefaultRoute must not be set for them. Defaults to netdevice
+	// +optional
+	// +kubebuilder:validation:Enum={"netdevice", "dpdk"}
+	DeviceType string `json:"deviceType,omitempty"`
+	// DPDK configuration of the devices, can only be set if DeviceType is dpdk
+	// +optional
+	DPDK *HostDeviceDPDKSpec `json:"dpdk,omitempty"`
+}
+
+// HostDeviceDPDKSpec describes how the host devices of a network are used by DPDK workloads
+type HostDeviceDPDKSpec struct {
+	// Driver the devices are bound to on the nodes, set in the NetworkAttachmentDefinition config for the DPDK
+	// workloads, defaults to vfio-pci
+	// +optional
+	// +kubebuilder:validation:Enum={"vfio-pci", "uio_pci_generic", "igb_uio"}
+	Driver string `json:"driver,omitempty"`
 }
 
 // HostDeviceNetworkStatus defines the observed state of HostDeviceNetwork
@@ -41,10 +77,20 @@ type HostDeviceNetworkStatus struct {
 	State State `json:"state"`
 	// Network attachment definition generated from HostDeviceNetworkSpec
 	HostDeviceNetworkAttachmentDef string `json:"hostDeviceNetworkAttachmentDef,omitempty"`
+	// NetworkAttachmentDefinition generated from the network spec
+	// +optional
+	NetworkAttachmentDefinition *NetworkAttachmentDefinitionStatus `json:"networkAttachmentDefinition,omitempty"`
+	// NetworkAttachmentDefinitions generated for the network in all namespaces, as namespace/name, the operator
+	// deletes all of them when the network is removed
+	// +optional
+	GeneratedNetworkAttachmentDefinitions []string `json:"generatedNetworkAttachmentDefinitions,omitempty"`
 	// Informative string in case the observed state is error
 	Reason string `json:"reason,omitempty"`
 	// AppliedStates provide a finer view of the observed state
 	AppliedStates []AppliedState `json:"appliedStates,omitempty"`
+	// Conditions of the network, e.g. PermissionDenied if the operator lacks permissions to sync the network
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
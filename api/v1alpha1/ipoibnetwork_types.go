@@ -32,6 +32,10 @@ type IPoIBNetworkSpec struct {
 	Master string `json:"master,omitempty"`
 	// IPAM configuration to be used for this network.
 	IPAM string `json:"ipam,omitempty"`
+	// CNIVersion of the generated NetworkAttachmentDefinition config, defaults to 0.3.1
+	// +optional
+	// +kubebuilder:validation:Enum={"0.3.0", "0.3.1", "0.4.0", "1.0.0"}
+	CNIVersion string `json:"cniVersion,omitempty"`
 }
 
 // IPoIBNetworkStatus defines the observed state of IPoIBNetwork
@@ -41,8 +45,18 @@ type IPoIBNetworkStatus struct {
 	State State `json:"state"`
 	// Network attachment definition generated from IPoIBNetworkSpec
 	IPoIBNetworkAttachmentDef string `json:"ipoibNetworkAttachmentDef,omitempty"`
+	// NetworkAttachmentDefinition generated from the network spec
+	// +optional
+	NetworkAttachmentDefinition *NetworkAttachmentDefinitionStatus `json:"networkAttachmentDefinition,omitempty"`
+	// NetworkAttachmentDefinitions generated for the network in all namespaces, as namespace/name, the operator
+	// deletes all of them when the network is removed
+	// +optional
+	GeneratedNetworkAttachmentDefinitions []string `json:"generatedNetworkAttachmentDefinitions,omitempty"`
 	// Informative string in case the observed state is error
 	Reason string `json:"reason,omitempty"`
+	// Conditions represent the latest available observations of the IPoIBNetwork, e.g. missing network namespace
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -28,7 +28,7 @@ const (
 type MacvlanNetworkSpec struct {
 	// Namespace of the NetworkAttachmentDefinition custom resource
 	NetworkNamespace string `json:"networkNamespace,omitempty"`
-	// Name of the host interface to enslave. Defaults to default route interface
+	// Name of the host interface to enslave, e.g. a bond interface. Defaults to default route interface
 	Master string `json:"master,omitempty"`
 	// +kubebuilder:validation:Enum={"bridge", "private", "vepa", "passthru"}
 	// Mode of interface one of "bridge", "private", "vepa", "passthru"
@@ -38,6 +38,18 @@ type MacvlanNetworkSpec struct {
 	Mtu int `json:"mtu,omitempty"`
 	// IPAM configuration to be used for this network.
 	IPAM string `json:"ipam,omitempty"`
+	// CNIVersion of the generated NetworkAttachmentDefinition config, defaults to 0.3.1
+	// +optional
+	// +kubebuilder:validation:Enum={"0.3.0", "0.3.1", "0.4.0", "1.0.0"}
+	CNIVersion string `json:"cniVersion,omitempty"`
+	// Additional namespaces to create the NetworkAttachmentDefinition custom resource in
+	// +optional
+	TargetNamespaces []string `json:"targetNamespaces,omitempty"`
+	// DefaultRoute controls whether the network becomes the default route of the pod: if true, default routes
+	// are added to the routes of the IPAM configuration, if false, the IPAM configuration must not set a default
+	// route. The IPAM configuration is used as is if not set
+	// +optional
+	DefaultRoute *bool `json:"defaultRoute,omitempty"`
 }
 
 // MacvlanNetworkStatus defines the observed state of MacvlanNetwork
@@ -47,8 +59,23 @@ type MacvlanNetworkStatus struct {
 	State State `json:"state"`
 	// Network attachment definition generated from MacvlanNetworkSpec
 	MacvlanNetworkAttachmentDef string `json:"macvlanNetworkAttachmentDef,omitempty"`
+	// NetworkAttachmentDefinition generated from the network spec
+	// +optional
+	NetworkAttachmentDefinition *NetworkAttachmentDefinitionStatus `json:"networkAttachmentDefinition,omitempty"`
+	// NetworkAttachmentDefinitions generated for the network in all namespaces, as namespace/name, the operator
+	// deletes all of them when the network is removed
+	// +optional
+	GeneratedNetworkAttachmentDefinitions []string `json:"generatedNetworkAttachmentDefinitions,omitempty"`
 	// Informative string in case the observed state is error
 	Reason string `json:"reason,omitempty"`
+	// Informative string in case the network may not work as expected on some nodes,
+	// e.g. the master looks like a physical interface while the nodes use bonding or IPAM excludes are not within
+	// their range, several warnings are separated by semicolon
+	// +optional
+	Warning string `json:"warning,omitempty"`
+	// Conditions of the network, e.g. IPPoolReady if the network uses nv-ipam
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -0,0 +1,38 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// DefaultCNIVersion is the cniVersion of the generated NetworkAttachmentDefinition config
+// if it is not set in the network spec
+const DefaultCNIVersion = "0.3.1"
+
+// SupportedCNIVersions contains the cniVersion values which can be set in the network spec
+var SupportedCNIVersions = []string{"0.3.0", "0.3.1", "0.4.0", "1.0.0"}
+
+// NetworkAttachmentDefinitionStatus describes the NetworkAttachmentDefinition generated from a network CR,
+// the name and namespace can be used to reference the network in the pod annotations
+type NetworkAttachmentDefinitionStatus struct {
+	// Name of the NetworkAttachmentDefinition
+	Name string `json:"name"`
+	// Namespace of the NetworkAttachmentDefinition
+	Namespace string `json:"namespace"`
+	// Ready is true if the NetworkAttachmentDefinition is in sync with the network spec
+	Ready bool `json:"ready"`
+	// Error which prevents the NetworkAttachmentDefinition from being created or updated
+	// +optional
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,94 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	NetworkDiagnosticCRDName = "NetworkDiagnostic"
+)
+
+const (
+	DiagnosticStateRunning = "running"
+	DiagnosticStatePassed  = "passed"
+	DiagnosticStateFailed  = "failed"
+)
+
+// NetworkDiagnosticSpec defines the desired state of NetworkDiagnostic
+type NetworkDiagnosticSpec struct {
+	// Name of the NetworkAttachmentDefinition to test,
+	// e.g. the one generated from MacvlanNetwork, HostDeviceNetwork or IPoIBNetwork
+	NetworkName string `json:"networkName"`
+	// Namespace of the NetworkAttachmentDefinition, defaults to the NetworkDiagnostic namespace
+	// +optional
+	NetworkNamespace string `json:"networkNamespace,omitempty"`
+	// Optional: resource required to attach the pods to the network, e.g. rdma/rdma_shared_device_a
+	// +optional
+	ResourceName string `json:"resourceName,omitempty"`
+	// Image of the test pods, the image should provide ip and ping commands
+	// +optional
+	// +kubebuilder:default:="mellanox/rping-test"
+	Image string `json:"image,omitempty"`
+	// TimeoutSeconds specifies the time to wait for the check to complete before it is reported as failed
+	// +optional
+	// +kubebuilder:default:=300
+	// +kubebuilder:validation:Minimum=1
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+}
+
+// NetworkDiagnosticStatus defines the observed state of NetworkDiagnostic
+type NetworkDiagnosticStatus struct {
+	// Reflects the state of the check
+	// +kubebuilder:validation:Enum={"running", "passed", "failed"}
+	State State `json:"state,omitempty"`
+	// Informative string about the result of the check
+	Reason string `json:"reason,omitempty"`
+	// Time when the check was completed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:object:generate=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.state`,priority=0
+// +kubebuilder:printcolumn:name="Age",type=string,JSONPath=`.metadata.creationTimestamp`,priority=0
+
+// NetworkDiagnostic is the Schema for the networkdiagnostics API
+type NetworkDiagnostic struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NetworkDiagnosticSpec   `json:"spec,omitempty"`
+	Status NetworkDiagnosticStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:object:generate=true
+
+// NetworkDiagnosticList contains a list of NetworkDiagnostic
+type NetworkDiagnosticList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NetworkDiagnostic `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NetworkDiagnostic{}, &NetworkDiagnosticList{})
+}
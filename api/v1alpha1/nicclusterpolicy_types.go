@@ -17,8 +17,10 @@ limitations under the License.
 package v1alpha1
 
 import (
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 const (
@@ -34,6 +36,7 @@ const (
 	StateReady    = "ready"
 	StateNotReady = "notReady"
 	StateIgnore   = "ignore"
+	StateDisabled = "disabled"
 	StateError    = "error"
 )
 
@@ -48,6 +51,27 @@ type ImageSpec struct {
 	// +optional
 	// +kubebuilder:default:={}
 	ImagePullSecrets []string `json:"imagePullSecrets"`
+	// ImagePullPolicy of the component containers, the policy of the component manifests is used if not set
+	// +optional
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+	ImagePullPolicy v1.PullPolicy `json:"imagePullPolicy,omitempty"`
+	// PriorityClassName of the component pods, system-node-critical is used if not set
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// SecurityContext is merged into the security context of the component containers, the fields which are set
+	// replace the settings of the component manifests. The OFED and NV peer memory driver containers must stay
+	// privileged, see ValidateDriverSecurityContext
+	// +optional
+	SecurityContext *v1.SecurityContext `json:"securityContext,omitempty"`
+	// Enabled set to false removes the component from the cluster while its configuration is kept in the spec
+	// +optional
+	// +kubebuilder:default:=true
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// IsEnabled returns false if the component is disabled in the spec, components are enabled by default
+func (s *ImageSpec) IsEnabled() bool {
+	return s.Enabled == nil || *s.Enabled
 }
 
 type PodProbeSpec struct {
@@ -79,6 +103,119 @@ type OFEDDriverSpec struct {
 	CertConfig *ConfigMapNameReference `json:"certConfig,omitempty"`
 	// Optional: Custom package repository configuration for OFED container
 	RepoConfig *ConfigMapNameReference `json:"repoConfig,omitempty"`
+	// Optional: Use precompiled driver packages matching the node's kernel instead of compiling the driver on the node
+	UsePrecompiled bool `json:"usePrecompiled,omitempty"`
+	// Optional: URL of the repository with precompiled driver packages, required if UsePrecompiled is set.
+	// Packages are expected under <precompiledRepository>/<version>/<kernel version>/
+	PrecompiledRepository string `json:"precompiledRepository,omitempty"`
+	// Optional: Run the driver pod in the host network namespace, true if not set
+	// +optional
+	HostNetwork *bool `json:"hostNetwork,omitempty"`
+	// Optional: DNS policy of the driver pod, e.g. to reach internal package mirrors during the driver build.
+	// Kubernetes default is used if not set
+	// +optional
+	// +kubebuilder:validation:Enum={"ClusterFirstWithHostNet", "ClusterFirst", "Default", "None"}
+	DNSPolicy v1.DNSPolicy `json:"dnsPolicy,omitempty"`
+	// Optional: DNS parameters of the driver pod in addition to the ones generated from DNS policy
+	// +optional
+	DNSConfig *v1.PodDNSConfig `json:"dnsConfig,omitempty"`
+	// Optional: Init container which prepares the driver, e.g. builds the kernel modules, before the driver container
+	// starts. The driver container image is then used only to load the prepared modules
+	// +optional
+	InitContainer *OFEDInitContainerSpec `json:"initContainer,omitempty"`
+	// Optional: Minimum OFED driver version, e.g. 5.7-0.1.2.0. Nodes running an older driver are reported
+	// in the DriverVersionBelowMinimum condition of the NicClusterPolicy status
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[0-9]+\.[0-9]+(-[0-9]+(\.[0-9]+)*)?$`
+	MinDriverVersion string `json:"minDriverVersion,omitempty"`
+	// Optional: Upgrade the nodes with OFED driver pods older than MinDriverVersion through the upgrade flow while
+	// automatic upgrade is disabled, with the settings of OfedUpgradePolicy or one by one with drain if not set.
+	// Ignored if automatic upgrade is enabled, which upgrades such pods anyway, or if the configured version
+	// is older than MinDriverVersion
+	// +optional
+	ForceMinDriverVersion bool `json:"forceMinDriverVersion,omitempty"`
+	// Optional: Set the nvidia.com/driver-ready condition on the nodes with Mellanox NICs, the condition is True
+	// while the OFED driver pod on the node is Ready, e.g. for schedulers which wait for the driver of the node
+	// +optional
+	DriverReadyNodeCondition bool `json:"driverReadyNodeCondition,omitempty"`
+	// Optional: Override environment variables of the driver container on single nodes with the
+	// network.nvidia.com/ofed-driver-env node annotation. The overrides of all nodes are stored in a ConfigMap,
+	// the driver container loads the file of its node from it before it starts NodeEnvEntrypoint.
+	// They take precedence over Env on the annotated node
+	// +optional
+	NodeEnvOverrides bool `json:"nodeEnvOverrides,omitempty"`
+	// Optional: Entrypoint of the driver image, which the driver container starts with the node env overrides,
+	// /root/entrypoint.sh by default
+	// +optional
+	NodeEnvEntrypoint string `json:"nodeEnvEntrypoint,omitempty"`
+	// Optional: Namespace of the OFED driver objects, the namespace must exist.
+	// The objects are created in the namespace of the operator if not set
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=63
+	Namespace string `json:"namespace,omitempty"`
+	// Optional: Tolerations of the driver pod in addition to the tolerations of the control plane and GPU taints.
+	// The device plugins inherit them unless disabled in the device plugin spec
+	// +optional
+	Tolerations []v1.Toleration `json:"tolerations,omitempty"`
+	// Optional: NIC firmware images compared with the firmware of the NICs, and optionally flashed to them,
+	// by an init container of the driver pod before the driver is loaded
+	// +optional
+	Firmware *OFEDFirmwareSpec `json:"firmware,omitempty"`
+	// Optional: Max number of driver pods which build and load the driver at the same time, e.g. to avoid thrashing
+	// a shared build cache when many nodes boot at once. The driver pods wait in an init container until the operator
+	// grants them a build slot, the slot is released when the driver pod is Ready. 0 means no limit
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxConcurrentBuilds int `json:"maxConcurrentBuilds,omitempty"`
+}
+
+// OFEDFirmwareSpec describes the NIC firmware images, *.bin files, provided to the OFED driver pod. Exactly one of
+// ConfigMap, PersistentVolumeClaim and HostPath must be set as the firmware source
+type OFEDFirmwareSpec struct {
+	// ConfigMap with the firmware files in the namespace of the OFED driver
+	// +optional
+	ConfigMap *ConfigMapNameReference `json:"configMap,omitempty"`
+	// PersistentVolumeClaim with the firmware files in the namespace of the OFED driver. The claim is mounted by the
+	// driver pods of all nodes, its volume must support the ReadOnlyMany or ReadWriteMany access mode
+	// +optional
+	PersistentVolumeClaim *v1.PersistentVolumeClaimVolumeSource `json:"persistentVolumeClaim,omitempty"`
+	// Directory of the node with the firmware files
+	// +optional
+	HostPath *v1.HostPathVolumeSource `json:"hostPath,omitempty"`
+	// Path of the firmware directory in the firmware init container
+	// +optional
+	// +kubebuilder:default:=/run/mellanox/firmware
+	MountPath string `json:"mountPath,omitempty"`
+	// Flash the firmware to the NICs of the node if it differs from their firmware,
+	// the NICs which don't run the provided firmware are only reported otherwise
+	// +optional
+	Update bool `json:"update,omitempty"`
+}
+
+// OFEDInitContainerSpec describes the init container of the OFED driver pod. The init container shares a directory
+// with the driver container to pass the prepared driver to it
+type OFEDInitContainerSpec struct {
+	// Full image reference of the init container, e.g. nvcr.io/nvidia/mellanox/mofed-builder:5.7-ubuntu20.04-amd64
+	// +kubebuilder:validation:MinLength=1
+	Image string `json:"image"`
+	// Image pull policy of the init container, IfNotPresent if not set
+	// +optional
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+	ImagePullPolicy v1.PullPolicy `json:"imagePullPolicy,omitempty"`
+	// Entrypoint of the init container, the image entrypoint is used if not set
+	// +optional
+	Command []string `json:"command,omitempty"`
+	// Arguments of the init container entrypoint
+	// +optional
+	Args []string `json:"args,omitempty"`
+	// List of environment variables to set in the init container in addition to the OFED container ones
+	// +optional
+	Env []v1.EnvVar `json:"env,omitempty"`
+	// Path of the directory shared by the init container and the driver container
+	// +optional
+	// +kubebuilder:default:=/run/mellanox/ofed-init
+	SharedDir string `json:"sharedDir,omitempty"`
 }
 
 // NVPeerDriverSpec describes configuration options for NV Peer Memory driver
@@ -93,8 +230,144 @@ type NVPeerDriverSpec struct {
 type DevicePluginSpec struct {
 	// Image information for device plugin
 	ImageSpec `json:""`
-	// Device plugin configuration
-	Config string `json:"config"`
+	// Device plugin configuration, mutually exclusive with ConfigRef
+	// +optional
+	Config string `json:"config,omitempty"`
+	// Name of a DevicePluginConfig which holds the device plugin configuration in its config field,
+	// so that the configuration can be managed independently of NicClusterPolicy. Mutually exclusive with Config
+	// +optional
+	ConfigRef string `json:"configRef,omitempty"`
+	// Init containers added to the device plugin pod after the init containers of the operator,
+	// e.g. to wait for a device of the driver, the names must not collide with the containers of the operator
+	// +optional
+	AdditionalInitContainers []v1.Container `json:"additionalInitContainers,omitempty"`
+	// List of environment variables to set in the device plugin container, e.g. tuning options which are not part
+	// of the device plugin config. The names must not collide with the environment variables set by the operator
+	// +optional
+	Env []v1.EnvVar `json:"env,omitempty"`
+	// Restrict the device plugin to nodes where the OFED driver is ready, i.e. nodes labeled with
+	// network.nvidia.com/operator.mofed.wait=false, the requirement is added to the node affinity of the policy.
+	// Enabled by default, disable it only if the device plugin doesn't depend on the driver deployed by the operator
+	// +optional
+	// +kubebuilder:default:=true
+	DriverReadyAffinity *bool `json:"driverReadyAffinity,omitempty"`
+	// Tolerations of the device plugin pod in addition to the tolerations of the control plane and GPU taints
+	// +optional
+	Tolerations []v1.Toleration `json:"tolerations,omitempty"`
+	// Add the tolerations of the OFED driver which the device plugin doesn't tolerate, so that the device plugin
+	// runs on every node of the driver. If disabled, the diverging tolerations are only reported in the log.
+	// Enabled by default
+	// +optional
+	// +kubebuilder:default:=true
+	InheritDriverTolerations *bool `json:"inheritDriverTolerations,omitempty"`
+	// Hold the creation of the device plugin until the OFED driver is ready on at least this number or percentage,
+	// e.g. 80%, of the nodes with Mellanox NICs. Once created, the device plugin is rolled out to all nodes and
+	// is not held again. The device plugin is created right away if not set
+	// +optional
+	MinDriverReadyNodes *intstr.IntOrString `json:"minDriverReadyNodes,omitempty"`
+	// Update strategy of the device plugin DaemonSet, e.g. a rolling update with maxUnavailable so that
+	// configuration changes are rolled out gradually and the resources stay available on the other nodes.
+	// The update strategy of the component manifests is used if not set
+	// +optional
+	UpdateStrategy *appsv1.DaemonSetUpdateStrategy `json:"updateStrategy,omitempty"`
+	// Restart the device plugin pod on a node where the OFED driver is ready, which advertises zero of a resource
+	// of the device plugin for longer than this number of seconds, so that the device plugin registers its resources
+	// with the kubelet again, e.g. after a kubelet restart. Only the resources listed in the allocatable resources
+	// of the node are checked. The period is doubled after each restart, the restarts stop after 3 restarts.
+	// Disabled if not set or 0
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	ResourceDriftRestartSeconds int `json:"resourceDriftRestartSeconds,omitempty"`
+}
+
+// RdmaSharedDevicePluginSpec describes configuration options for RDMA shared device plugin
+type RdmaSharedDevicePluginSpec struct {
+	// Image information for device plugin
+	ImageSpec `json:""`
+	// Device plugin configuration, mutually exclusive with ResourcePools and ConfigRef
+	// +optional
+	Config string `json:"config,omitempty"`
+	// Name of a DevicePluginConfig which holds the device plugin configuration in its config field,
+	// so that the configuration can be managed independently of NicClusterPolicy.
+	// Mutually exclusive with Config and ResourcePools
+	// +optional
+	ConfigRef string `json:"configRef,omitempty"`
+	// Named RDMA resource pools advertised by the device plugin,
+	// the device plugin configuration is generated from the pools if set
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	ResourcePools []RdmaSharedDevicePoolSpec `json:"resourcePools,omitempty"`
+	// Init containers added to the device plugin pod after the init containers of the operator,
+	// e.g. to wait for a device of the driver, the names must not collide with the containers of the operator
+	// +optional
+	AdditionalInitContainers []v1.Container `json:"additionalInitContainers,omitempty"`
+	// List of environment variables to set in the device plugin container, e.g. tuning options which are not part
+	// of the device plugin config. The names must not collide with the environment variables set by the operator
+	// +optional
+	Env []v1.EnvVar `json:"env,omitempty"`
+	// Restrict the device plugin to nodes where the OFED driver is ready, i.e. nodes labeled with
+	// network.nvidia.com/operator.mofed.wait=false, the requirement is added to the node affinity of the policy.
+	// Enabled by default, disable it only if the device plugin doesn't depend on the driver deployed by the operator
+	// +optional
+	// +kubebuilder:default:=true
+	DriverReadyAffinity *bool `json:"driverReadyAffinity,omitempty"`
+	// Tolerations of the device plugin pod in addition to the tolerations of the control plane and GPU taints
+	// +optional
+	Tolerations []v1.Toleration `json:"tolerations,omitempty"`
+	// Add the tolerations of the OFED driver which the device plugin doesn't tolerate, so that the device plugin
+	// runs on every node of the driver. If disabled, the diverging tolerations are only reported in the log.
+	// Enabled by default
+	// +optional
+	// +kubebuilder:default:=true
+	InheritDriverTolerations *bool `json:"inheritDriverTolerations,omitempty"`
+	// Hold the creation of the device plugin until the OFED driver is ready on at least this number or percentage,
+	// e.g. 80%, of the nodes with Mellanox NICs. Once created, the device plugin is rolled out to all nodes and
+	// is not held again. The device plugin is created right away if not set
+	// +optional
+	MinDriverReadyNodes *intstr.IntOrString `json:"minDriverReadyNodes,omitempty"`
+	// Update strategy of the device plugin DaemonSet, e.g. a rolling update with maxUnavailable so that
+	// configuration changes are rolled out gradually and the resources stay available on the other nodes.
+	// The update strategy of the component manifests is used if not set
+	// +optional
+	UpdateStrategy *appsv1.DaemonSetUpdateStrategy `json:"updateStrategy,omitempty"`
+	// Restart the device plugin pod on a node where the OFED driver is ready, which advertises zero of a resource
+	// of the device plugin for longer than this number of seconds, so that the device plugin registers its resources
+	// with the kubelet again, e.g. after a kubelet restart. Only the resources listed in the allocatable resources
+	// of the node are checked. The period is doubled after each restart, the restarts stop after 3 restarts.
+	// Disabled if not set or 0
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	ResourceDriftRestartSeconds int `json:"resourceDriftRestartSeconds,omitempty"`
+}
+
+// RdmaSharedDevicePoolSpec describes a named RDMA resource pool of the RDMA shared device plugin
+type RdmaSharedDevicePoolSpec struct {
+	// Name of the pool, used as the name of the advertised resource, must be unique in the policy
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// Maximum number of pods which can share a device of the pool
+	// +optional
+	// +kubebuilder:default:=1000
+	// +kubebuilder:validation:Minimum=1
+	RdmaHcaMax int `json:"rdmaHcaMax,omitempty"`
+	// Selectors of the devices included in the pool
+	Selectors RdmaSharedDevicePoolSelectors `json:"selectors"`
+}
+
+// RdmaSharedDevicePoolSelectors describes which devices are included in an RDMA resource pool,
+// a device is included if it matches all of the specified selectors
+type RdmaSharedDevicePoolSelectors struct {
+	// +optional
+	Vendors []string `json:"vendors,omitempty"`
+	// +optional
+	DeviceIDs []string `json:"deviceIDs,omitempty"`
+	// +optional
+	Drivers []string `json:"drivers,omitempty"`
+	// +optional
+	IfNames []string `json:"ifNames,omitempty"`
+	// +optional
+	LinkTypes []string `json:"linkTypes,omitempty"`
 }
 
 // MultusSpec describes configuration options for Multus CNI
@@ -106,6 +379,22 @@ type MultusSpec struct {
 	Config string `json:"config,omitempty"`
 }
 
+// DOCATelemetrySpec describes configuration options for DOCA Telemetry Service
+type DOCATelemetrySpec struct {
+	// Image information for DOCA Telemetry Service
+	ImageSpec `json:""`
+	// Content of DOCA Telemetry Service configuration file (dts_config.ini),
+	// configuration from the host /opt/mellanox/doca/services/telemetry/config directory is used if not set
+	// +optional
+	Config string `json:"config,omitempty"`
+	// Optional: Namespace of the DOCA Telemetry Service objects, the namespace must exist.
+	// The objects are created in the namespace of the operator if not set
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=63
+	Namespace string `json:"namespace,omitempty"`
+}
+
 // SecondaryNetwork describes configuration options for secondary network
 type SecondaryNetworkSpec struct {
 	// Image and configuration information for multus
@@ -141,15 +430,131 @@ type DrainSpec struct {
 	// https://kubernetes.io/docs/concepts/overview/working-with-objects/labels/#label-selectors
 	// +optional
 	PodSelector string `json:"podSelector,omitempty"`
-	// TimeoutSecond specifies the length of time in seconds to wait before giving up drain, zero means infinite
+	// TimeoutSecond specifies the length of time in seconds to wait before giving up drain, zero means infinite.
+	// The timeout is extended by the longest termination grace period of the drained pods
 	// +optional
 	// +kubebuilder:default:=0
 	TimeoutSecond int `json:"timeoutSeconds,omitempty"`
+	// GracePeriodSeconds overrides the termination grace period of the drained pods,
+	// the terminationGracePeriodSeconds of each pod is honored if not set
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	GracePeriodSeconds *int `json:"gracePeriodSeconds,omitempty"`
+	// CordonSettleSeconds is the time to wait after the node is cordoned before its pods are evicted, so that pods
+	// which the scheduler placed on the node right before the cordon land on it and are drained as well.
+	// The wait is repeated while new pods keep landing on the node, up to 5 times. The drain starts right
+	// after the cordon if not set
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=60
+	CordonSettleSeconds int `json:"cordonSettleSeconds,omitempty"`
 	// DeleteEmptyDir indicates if should continue even if there are pods using emptyDir
 	// (local data that will be deleted when the node is drained)
 	// +optional
 	// +kubebuilder:default:=false
 	DeleteEmptyDir bool `json:"deleteEmptyDir,omitempty"`
+	// DeleteFinishedPods indicates if Succeeded and Failed pods are deleted immediately when the drain starts
+	// instead of being evicted gracefully, pods which would be recreated by their controller are not deleted
+	// +optional
+	// +kubebuilder:default:=false
+	DeleteFinishedPods bool `json:"deleteFinishedPods,omitempty"`
+	// BarePods specifies how pods without a controller are handled during the drain, evicted bare pods are lost.
+	// Block keeps the node cordoned in drain state until the bare pods are removed, the pods are listed in the
+	// nvidia.com/ofed-upgrade-bare-pods node annotation. Evict evicts the bare pods even if Force is not set.
+	// If not set, bare pods fail the drain unless Force is set
+	// +optional
+	// +kubebuilder:validation:Enum=Block;Evict
+	BarePods string `json:"barePods,omitempty"`
+	// AvailabilityChecks block the drain of a node while evicting its pods would leave a selected Deployment with
+	// less available replicas than required. The node stays cordoned in drain state, the reason is reported in the
+	// nvidia.com/ofed-upgrade-drain-blocked node annotation
+	// +optional
+	AvailabilityChecks []DrainAvailabilityCheckSpec `json:"availabilityChecks,omitempty"`
+}
+
+// DrainAvailabilityCheckSpec describes the minimum availability of Deployments which the drain must not violate
+type DrainAvailabilityCheckSpec struct {
+	// Selector of the Deployments to check, in all namespaces
+	Selector metav1.LabelSelector `json:"selector"`
+	// MinAvailablePercent is the percentage of the desired replicas of each selected Deployment which must stay
+	// available after its pods on the node are evicted, rounded up to whole replicas
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	MinAvailablePercent int `json:"minAvailablePercent"`
+}
+
+const (
+	// BarePodsBlock blocks the drain while pods without a controller run on the node
+	BarePodsBlock = "Block"
+	// BarePodsEvict evicts pods without a controller during the drain
+	BarePodsEvict = "Evict"
+)
+
+// UpgradeNodeMarksSpec describes labels and taints which are added to the node when its drain starts
+// and removed when the node is uncordoned, so that external systems can react to the upgrade
+type UpgradeNodeMarksSpec struct {
+	// Labels to add to the node
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// Taints to add to the node, e.g. to keep pods of schedulers which ignore cordon off the node
+	// +optional
+	Taints []v1.Taint `json:"taints,omitempty"`
+}
+
+// UpgradeEventSinkSpec describes the HTTP endpoint which receives a POST request with a JSON event
+// on each node upgrade state change
+type UpgradeEventSinkSpec struct {
+	// URL of the endpoint
+	// +kubebuilder:validation:Pattern=`^https?://`
+	URL string `json:"url"`
+	// AuthHeaderSecretRef selects the key of a Secret in the operator namespace which holds
+	// the value of the Authorization header of the requests
+	// +optional
+	AuthHeaderSecretRef *v1.SecretKeySelector `json:"authHeaderSecretRef,omitempty"`
+}
+
+// UncordonWaitForPodsSpec describes DaemonSet pods the upgrade flow waits for after the node is uncordoned
+type UncordonWaitForPodsSpec struct {
+	// PodSelector is a label selector of the pods, every DaemonSet with matching pods which should run on the node
+	// must have a Ready pod on it
+	// +kubebuilder:validation:MinLength=1
+	PodSelector string `json:"podSelector"`
+	// TimeoutSeconds specifies the time in seconds to wait for the pods before the node is moved
+	// to upgrade-failed state
+	// +optional
+	// +kubebuilder:default:=300
+	// +kubebuilder:validation:Minimum=1
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+}
+
+// DriverValidationSpec describes the command which validates the restarted OFED driver on the node
+type DriverValidationSpec struct {
+	// Command is run in the OFED driver container, e.g. ["sh", "-c", "ibstat | grep -q 'State: Active'"],
+	// or in the validation Job if Job is set, the driver is valid if the command exits with zero
+	// +kubebuilder:validation:MinItems=1
+	Command []string `json:"command"`
+	// TimeoutSeconds specifies the time in seconds the command may run before the validation fails,
+	// the command is run with the timeout utility of the container, which terminates it once the time expires
+	// +optional
+	// +kubebuilder:default:=30
+	// +kubebuilder:validation:Minimum=1
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+	// Job runs the command in a Job on the node instead of the OFED driver container
+	// +optional
+	Job *DriverValidationJobSpec `json:"job,omitempty"`
+}
+
+// DriverValidationJobSpec describes the Job which runs the driver validation command on the node
+type DriverValidationJobSpec struct {
+	// Image of the validation container, the container is privileged and uses the host network
+	// +kubebuilder:validation:MinLength=1
+	Image string `json:"image"`
+	// Labels are added to the validation Job and its pod, e.g. to discover them with monitoring tools
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations are added to the validation Job and its pod
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
 // OfedUpgradePolicySpec describes policy configuration for automatic upgrades
@@ -163,8 +568,105 @@ type OfedUpgradePolicySpec struct {
 	// 0 means no limit, all nodes will be upgraded in parallel
 	// +optional
 	// +kubebuilder:default:=1
-	MaxParallelUpgrades int        `json:"maxParallelUpgrades,omitempty"`
-	DrainSpec           *DrainSpec `json:"drain,omitempty"`
+	MaxParallelUpgrades int `json:"maxParallelUpgrades,omitempty"`
+	// MaxUnavailableNodes indicates how many nodes can be cordoned by the upgrade at the same time,
+	// nodes which require upgrade are queued until the number of cordoned nodes drops below this value
+	// 0 means no limit
+	// +optional
+	// +kubebuilder:default:=0
+	// +kubebuilder:validation:Minimum=0
+	MaxUnavailableNodes int `json:"maxUnavailableNodes,omitempty"`
+	// CooldownSeconds specifies the time in seconds to wait after a node finished the upgrade
+	// before the next node is scheduled for drain, zero means no cooldown
+	// +optional
+	// +kubebuilder:default:=0
+	// +kubebuilder:validation:Minimum=0
+	CooldownSeconds int `json:"cooldownSeconds,omitempty"`
+	// MaxFailures indicates how many nodes can fail the upgrade before the upgrade is aborted,
+	// no new upgrades are started while the number of failed nodes is equal or greater than this value
+	// 0 means no limit
+	// +optional
+	// +kubebuilder:default:=0
+	// +kubebuilder:validation:Minimum=0
+	MaxFailures int `json:"maxFailures,omitempty"`
+	// QuarantineAfterFailures indicates how many times a node can fail the upgrade since its last successful
+	// upgrade before the node is cordoned, labeled with nvidia.com/ofed-upgrade.quarantined=true and excluded
+	// from the upgrade flow until the label is removed, 0 means nodes are never quarantined
+	// +optional
+	// +kubebuilder:default:=0
+	// +kubebuilder:validation:Minimum=0
+	QuarantineAfterFailures int `json:"quarantineAfterFailures,omitempty"`
+	// RequireApproval indicates that nodes wait in pending-approval state until the target
+	// OFED driver image is approved through the nvidia.com/ofed-upgrade-approved annotation
+	// on the NicClusterPolicy
+	// +optional
+	// +kubebuilder:default:=false
+	RequireApproval bool `json:"requireApproval,omitempty"`
+	// SoakSeconds specifies the time in seconds the restarted driver must stay healthy before the node
+	// is uncordoned and the upgrade is finished, zero means no soak
+	// +optional
+	// +kubebuilder:default:=0
+	// +kubebuilder:validation:Minimum=0
+	SoakSeconds int `json:"soakSeconds,omitempty"`
+	// DriverValidation specifies the command which must succeed in the restarted driver pod before the node
+	// proceeds to post-upgrade-soak or uncordon-required state, e.g. to check that the RDMA link is active
+	// +optional
+	DriverValidation *DriverValidationSpec `json:"driverValidation,omitempty"`
+	// UncordonReadyRetries specifies how many times the Ready state of the node is checked again after uncordon
+	// before the node is moved to upgrade-failed state, zero means the node is not required to be Ready
+	// +optional
+	// +kubebuilder:default:=0
+	// +kubebuilder:validation:Minimum=0
+	UncordonReadyRetries int `json:"uncordonReadyRetries,omitempty"`
+	// UncordonReadyBackoffSeconds specifies the initial time in seconds to wait before the Ready state
+	// of the uncordoned node is checked again, the time is doubled after each retry
+	// +optional
+	// +kubebuilder:default:=10
+	// +kubebuilder:validation:Minimum=1
+	UncordonReadyBackoffSeconds int `json:"uncordonReadyBackoffSeconds,omitempty"`
+	// UncordonWaitForPods specifies DaemonSet pods which must be Ready on the uncordoned node
+	// before the upgrade of the node is done
+	// +optional
+	UncordonWaitForPods *UncordonWaitForPodsSpec `json:"uncordonWaitForPods,omitempty"`
+	// NodeMarks specifies labels and taints set on the node for the time of the upgrade
+	// +optional
+	NodeMarks *UpgradeNodeMarksSpec `json:"nodeMarks,omitempty"`
+	// DevicePluginGraceSeconds specifies the time in seconds to wait after the device plugins are removed
+	// from the cordoned node before the node is drained, so that the device plugin resources are no longer
+	// advertised during the drain, zero means the device plugins are not removed before the drain
+	// +optional
+	// +kubebuilder:default:=0
+	// +kubebuilder:validation:Minimum=0
+	DevicePluginGraceSeconds int `json:"devicePluginGraceSeconds,omitempty"`
+	// MaxPreUpgradeStateSeconds specifies the time in seconds a node may stay in upgrade-required,
+	// pending-approval or drain state before the UpgradeStalled condition is set on the NicClusterPolicy,
+	// zero means no limit
+	// +optional
+	// +kubebuilder:default:=0
+	// +kubebuilder:validation:Minimum=0
+	MaxPreUpgradeStateSeconds int `json:"maxPreUpgradeStateSeconds,omitempty"`
+	// EventSink specifies the HTTP endpoint which is notified about node upgrade state changes
+	// +optional
+	EventSink *UpgradeEventSinkSpec `json:"eventSink,omitempty"`
+	// NodeGroups configure the upgrade of groups of nodes, the first group which selects a node applies to it
+	// +optional
+	NodeGroups []UpgradeNodeGroupSpec `json:"nodeGroups,omitempty"`
+	DrainSpec  *DrainSpec             `json:"drain,omitempty"`
+}
+
+// UpgradeNodeGroupSpec describes the upgrade settings of the nodes selected by the node selector
+type UpgradeNodeGroupSpec struct {
+	// Name of the node group, reported in the nvidia.com/ofed-upgrade-manual-required node annotation
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// NodeSelector selects the nodes of the group by their labels
+	NodeSelector metav1.LabelSelector `json:"nodeSelector"`
+	// DisruptionAllowed indicates that the operator may drain the nodes of the group and restart the driver on them,
+	// otherwise the nodes are skipped by the upgrade flow and flagged with the
+	// nvidia.com/ofed-upgrade-manual-required annotation until the driver pod is restarted manually
+	// +optional
+	// +kubebuilder:default:=true
+	DisruptionAllowed *bool `json:"disruptionAllowed,omitempty"`
 }
 
 // NicClusterPolicySpec defines the desired state of NicClusterPolicy
@@ -172,20 +674,26 @@ type NicClusterPolicySpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	NodeAffinity           *v1.NodeAffinity      `json:"nodeAffinity,omitempty"`
-	OFEDDriver             *OFEDDriverSpec       `json:"ofedDriver,omitempty"`
-	NVPeerDriver           *NVPeerDriverSpec     `json:"nvPeerDriver,omitempty"`
-	RdmaSharedDevicePlugin *DevicePluginSpec     `json:"rdmaSharedDevicePlugin,omitempty"`
-	SriovDevicePlugin      *DevicePluginSpec     `json:"sriovDevicePlugin,omitempty"`
-	SecondaryNetwork       *SecondaryNetworkSpec `json:"secondaryNetwork,omitempty"`
-	PSP                    *PSPSpec              `json:"psp,omitempty"`
+	NodeAffinity           *v1.NodeAffinity            `json:"nodeAffinity,omitempty"`
+	OFEDDriver             *OFEDDriverSpec             `json:"ofedDriver,omitempty"`
+	NVPeerDriver           *NVPeerDriverSpec           `json:"nvPeerDriver,omitempty"`
+	RdmaSharedDevicePlugin *RdmaSharedDevicePluginSpec `json:"rdmaSharedDevicePlugin,omitempty"`
+	SriovDevicePlugin      *DevicePluginSpec           `json:"sriovDevicePlugin,omitempty"`
+	SecondaryNetwork       *SecondaryNetworkSpec       `json:"secondaryNetwork,omitempty"`
+	PSP                    *PSPSpec                    `json:"psp,omitempty"`
+	DOCATelemetry          *DOCATelemetrySpec          `json:"docaTelemetry,omitempty"`
+	// Optional: ConfigMap in the operator namespace which maps component names to image references,
+	// images from this ConfigMap override images specified for the components in the NicClusterPolicy
+	ImageBundle *ConfigMapNameReference `json:"imageBundle,omitempty"`
 }
 
 // AppliedState defines a finer-grained view of the observed state of NicClusterPolicy
 type AppliedState struct {
 	Name string `json:"name"`
-	// +kubebuilder:validation:Enum={"ready", "notReady", "ignore", "error"}
+	// +kubebuilder:validation:Enum={"ready", "notReady", "ignore", "disabled", "error"}
 	State State `json:"state"`
+	// Informative message about the state, e.g. dependencies the state is waiting for
+	Message string `json:"message,omitempty"`
 }
 
 // NicClusterPolicyStatus defines the observed state of NicClusterPolicy
@@ -200,14 +708,32 @@ type NicClusterPolicyStatus struct {
 	Reason string `json:"reason,omitempty"`
 	// AppliedStates provide a finer view of the observed state
 	AppliedStates []AppliedState `json:"appliedStates,omitempty"`
+	// Conditions represent the latest available observations of the NicClusterPolicy, e.g. aborted OFED upgrade
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Upgrade reflects the progress of the automatic OFED driver upgrade
+	// +optional
+	Upgrade *UpgradeStatus `json:"upgrade,omitempty"`
+}
+
+// UpgradeStatus describes the progress of the automatic OFED driver upgrade
+type UpgradeStatus struct {
+	// Sorted names of the nodes which are cordoned by the upgrade flow
+	// +optional
+	CordonedNodes []string `json:"cordonedNodes,omitempty"`
+	// Sorted names of the nodes which are excluded from the upgrade flow after too many failed upgrades
+	// +optional
+	QuarantinedNodes []string `json:"quarantinedNodes,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:object:generate=true
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:scope=Cluster
+// +kubebuilder:storageversion
 // +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.state`,priority=0
 // +kubebuilder:printcolumn:name="Age",type=string,JSONPath=`.metadata.creationTimestamp`,priority=0
+// +kubebuilder:printcolumn:name="Cordoned",type=string,JSONPath=`.status.upgrade.cordonedNodes`,priority=1
 
 // NicClusterPolicy is the Schema for the nicclusterpolicies API
 type NicClusterPolicy struct {
@@ -0,0 +1,470 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// SetupWebhookWithManager registers NicClusterPolicy webhooks, including the conversion webhook, in the manager
+func (r *NicClusterPolicy) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//nolint:lll
+// +kubebuilder:webhook:path=/validate-mellanox-com-v1alpha1-nicclusterpolicy,mutating=false,failurePolicy=fail,sideEffects=None,groups=mellanox.com,resources=nicclusterpolicies,verbs=create;update,versions=v1alpha1,name=vnicclusterpolicy.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &NicClusterPolicy{}
+
+// ValidateCreate implements webhook.Validator
+func (r *NicClusterPolicy) ValidateCreate() error {
+	return r.ValidateSpec()
+}
+
+// ValidateUpdate implements webhook.Validator
+func (r *NicClusterPolicy) ValidateUpdate(old runtime.Object) error {
+	return r.ValidateSpec()
+}
+
+// ValidateDelete implements webhook.Validator
+func (r *NicClusterPolicy) ValidateDelete() error {
+	return nil
+}
+
+// ValidateSpec returns an Invalid error listing the invalid fields of the spec, it is used by the webhook and
+// by the reconciler, which doesn't sync the components of an invalid policy, e.g. if the webhook is not deployed
+func (r *NicClusterPolicy) ValidateSpec() error {
+	allErrs := r.validateComponentImages()
+	allErrs = append(allErrs, r.validateDevicePluginConfigs()...)
+	allErrs = append(allErrs, r.validateDevicePluginSelectors()...)
+	allErrs = append(allErrs, r.validateDevicePluginConfigRefs()...)
+	allErrs = append(allErrs, r.validateDriverSecurityContexts()...)
+	if r.Spec.OFEDDriver != nil {
+		allErrs = append(allErrs, firmwareSourceErrors(r.Spec.OFEDDriver.Firmware,
+			field.NewPath("spec", "ofedDriver", "firmware"))...)
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: GroupVersion.Group, Kind: NicClusterPolicyCRDName}, r.Name, allErrs)
+}
+
+// validateComponentImages checks that the images of the enabled components can form an image reference,
+// the image is rendered as repository/image:version
+func (r *NicClusterPolicy) validateComponentImages() field.ErrorList {
+	images := map[string]*ImageSpec{}
+	if r.Spec.OFEDDriver != nil {
+		images["ofedDriver"] = &r.Spec.OFEDDriver.ImageSpec
+	}
+	if r.Spec.NVPeerDriver != nil {
+		images["nvPeerDriver"] = &r.Spec.NVPeerDriver.ImageSpec
+	}
+	if r.Spec.RdmaSharedDevicePlugin != nil {
+		images["rdmaSharedDevicePlugin"] = &r.Spec.RdmaSharedDevicePlugin.ImageSpec
+	}
+	if r.Spec.SriovDevicePlugin != nil {
+		images["sriovDevicePlugin"] = &r.Spec.SriovDevicePlugin.ImageSpec
+	}
+	if r.Spec.DOCATelemetry != nil {
+		images["docaTelemetry"] = &r.Spec.DOCATelemetry.ImageSpec
+	}
+	if network := r.Spec.SecondaryNetwork; network != nil {
+		if network.Multus != nil {
+			images["secondaryNetwork.multus"] = &network.Multus.ImageSpec
+		}
+		images["secondaryNetwork.cniPlugins"] = network.CniPlugins
+		images["secondaryNetwork.ipoib"] = network.IPoIB
+		images["secondaryNetwork.ipamPlugin"] = network.IpamPlugin
+	}
+	names := make([]string, 0, len(images))
+	for name := range images {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var allErrs field.ErrorList
+	for _, name := range names {
+		image := images[name]
+		if image == nil || !image.IsEnabled() {
+			continue
+		}
+		path := field.NewPath("spec")
+		for _, part := range strings.Split(name, ".") {
+			path = path.Child(part)
+		}
+		allErrs = append(allErrs, imageReferenceErrors("image", image.Image, "/:@", path.Child("image"))...)
+		allErrs = append(allErrs, imageReferenceErrors("repository", image.Repository, "@", path.Child("repository"))...)
+		allErrs = append(allErrs, imageReferenceErrors("version", image.Version, "/:@", path.Child("version"))...)
+	}
+	return allErrs
+}
+
+// imageReferenceErrors checks that the part of the image reference is set and contains neither whitespace
+// nor any of the forbidden characters, which separate the other parts of the reference
+func imageReferenceErrors(part, value, forbidden string, path *field.Path) field.ErrorList {
+	switch {
+	case strings.TrimSpace(value) == "":
+		return field.ErrorList{field.Required(path, fmt.Sprintf("%s must be set for an enabled component", part))}
+	case strings.ContainsAny(value, " \t\n"):
+		return field.ErrorList{field.Invalid(path, value, fmt.Sprintf("%s must not contain whitespace", part))}
+	case strings.ContainsAny(value, forbidden):
+		return field.ErrorList{field.Invalid(path, value,
+			fmt.Sprintf("%s must not contain any of %q, the image is rendered as repository/image:version",
+				part, forbidden))}
+	}
+	return nil
+}
+
+// validateDevicePluginConfigs checks that the configurations of the enabled device plugins are valid JSON,
+// otherwise the device plugins fail to start with the rendered configuration
+func (r *NicClusterPolicy) validateDevicePluginConfigs() field.ErrorList {
+	configs := map[string]string{}
+	if dp := r.Spec.RdmaSharedDevicePlugin; dp != nil && dp.IsEnabled() {
+		configs["rdmaSharedDevicePlugin"] = dp.Config
+	}
+	if dp := r.Spec.SriovDevicePlugin; dp != nil && dp.IsEnabled() {
+		configs["sriovDevicePlugin"] = dp.Config
+	}
+	var allErrs field.ErrorList
+	for _, name := range []string{"rdmaSharedDevicePlugin", "sriovDevicePlugin"} {
+		if config := configs[name]; config != "" && !json.Valid([]byte(config)) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", name, "config"), config,
+				"config must be valid JSON"))
+		}
+	}
+	return allErrs
+}
+
+// validateDevicePluginSelectors checks that RDMA shared device plugin and SR-IOV device plugin
+// resources don't select the same devices
+func (r *NicClusterPolicy) validateDevicePluginSelectors() field.ErrorList {
+	if r.Spec.RdmaSharedDevicePlugin == nil || r.Spec.SriovDevicePlugin == nil {
+		return nil
+	}
+	rdmaPath := field.NewPath("spec", "rdmaSharedDevicePlugin", "config")
+	sriovPath := field.NewPath("spec", "sriovDevicePlugin", "config")
+
+	rdmaResources, err := rdmaSharedDevicePluginSelectors(r.Spec.RdmaSharedDevicePlugin)
+	if err != nil {
+		return field.ErrorList{field.Invalid(rdmaPath, r.Spec.RdmaSharedDevicePlugin.Config, err.Error())}
+	}
+	sriovResources, err := sriovDevicePluginSelectors(r.Spec.SriovDevicePlugin)
+	if err != nil {
+		return field.ErrorList{field.Invalid(sriovPath, r.Spec.SriovDevicePlugin.Config, err.Error())}
+	}
+
+	var allErrs field.ErrorList
+	for _, rdma := range rdmaResources {
+		for _, sriov := range sriovResources {
+			if !rdma.overlaps(sriov) {
+				continue
+			}
+			allErrs = append(allErrs, field.Invalid(sriovPath, sriov.resource, fmt.Sprintf(
+				"selectors %s of SR-IOV device plugin resource %q overlap with selectors %s "+
+					"of RDMA shared device plugin resource %q", sriov, sriov.resource, rdma, rdma.resource)))
+		}
+	}
+	return allErrs
+}
+
+// validateDevicePluginConfigRefs checks that the device plugins which reference a DevicePluginConfig
+// don't define their configuration in NicClusterPolicy as well
+func (r *NicClusterPolicy) validateDevicePluginConfigRefs() field.ErrorList {
+	var allErrs field.ErrorList
+	if sriov := r.Spec.SriovDevicePlugin; sriov != nil && sriov.ConfigRef != "" && sriov.Config != "" {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "sriovDevicePlugin", "configRef"),
+			sriov.ConfigRef, "configRef and config are mutually exclusive"))
+	}
+	dp := r.Spec.RdmaSharedDevicePlugin
+	if dp != nil && dp.ConfigRef != "" && (dp.Config != "" || len(dp.ResourcePools) > 0) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "rdmaSharedDevicePlugin", "configRef"),
+			dp.ConfigRef, "configRef is mutually exclusive with config and resourcePools"))
+	}
+	return allErrs
+}
+
+// driverCapabilities are the capabilities which the driver containers use to load kernel modules,
+// mount host directories and configure the network devices
+var driverCapabilities = []v1.Capability{"SYS_MODULE", "SYS_ADMIN", "NET_ADMIN"}
+
+// validateDriverSecurityContexts checks that the security contexts of the OFED and NV peer memory drivers
+// keep the settings required by the driver containers
+func (r *NicClusterPolicy) validateDriverSecurityContexts() field.ErrorList {
+	var allErrs field.ErrorList
+	if r.Spec.OFEDDriver != nil {
+		allErrs = append(allErrs, driverSecurityContextErrors(r.Spec.OFEDDriver.SecurityContext,
+			field.NewPath("spec", "ofedDriver", "securityContext"))...)
+	}
+	if r.Spec.NVPeerDriver != nil {
+		allErrs = append(allErrs, driverSecurityContextErrors(r.Spec.NVPeerDriver.SecurityContext,
+			field.NewPath("spec", "nvPeerDriver", "securityContext"))...)
+	}
+	return allErrs
+}
+
+// ValidateDriverSecurityContext returns an error if the security context removes settings required by the driver
+// containers: they run privileged as root with privilege escalation allowed and a writable root filesystem,
+// and none of the driverCapabilities is dropped
+func ValidateDriverSecurityContext(sc *v1.SecurityContext) error {
+	return driverSecurityContextErrors(sc, field.NewPath("securityContext")).ToAggregate()
+}
+
+func driverSecurityContextErrors(sc *v1.SecurityContext, path *field.Path) field.ErrorList {
+	if sc == nil {
+		return nil
+	}
+	var allErrs field.ErrorList
+	if sc.Privileged != nil && !*sc.Privileged {
+		allErrs = append(allErrs, field.Invalid(path.Child("privileged"), *sc.Privileged,
+			"driver containers must be privileged"))
+	}
+	if sc.AllowPrivilegeEscalation != nil && !*sc.AllowPrivilegeEscalation {
+		allErrs = append(allErrs, field.Invalid(path.Child("allowPrivilegeEscalation"), *sc.AllowPrivilegeEscalation,
+			"privilege escalation can't be disabled for privileged driver containers"))
+	}
+	if sc.RunAsNonRoot != nil && *sc.RunAsNonRoot {
+		allErrs = append(allErrs, field.Invalid(path.Child("runAsNonRoot"), *sc.RunAsNonRoot,
+			"driver containers must run as root"))
+	}
+	if sc.RunAsUser != nil && *sc.RunAsUser != 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("runAsUser"), *sc.RunAsUser,
+			"driver containers must run as root"))
+	}
+	if sc.ReadOnlyRootFilesystem != nil && *sc.ReadOnlyRootFilesystem {
+		allErrs = append(allErrs, field.Invalid(path.Child("readOnlyRootFilesystem"), *sc.ReadOnlyRootFilesystem,
+			"driver containers install the driver into their root filesystem"))
+	}
+	if sc.Capabilities != nil {
+		for _, dropped := range sc.Capabilities.Drop {
+			for _, required := range append([]v1.Capability{"ALL"}, driverCapabilities...) {
+				if strings.EqualFold(strings.TrimPrefix(string(dropped), "CAP_"), string(required)) {
+					allErrs = append(allErrs, field.Invalid(path.Child("capabilities", "drop"), dropped,
+						fmt.Sprintf("driver containers require capabilities %v", driverCapabilities)))
+				}
+			}
+		}
+	}
+	return allErrs
+}
+
+// ValidateFirmwareSource returns an error if not exactly one firmware source of the OFED driver is set
+func ValidateFirmwareSource(fw *OFEDFirmwareSpec) error {
+	return firmwareSourceErrors(fw, field.NewPath("firmware")).ToAggregate()
+}
+
+func firmwareSourceErrors(fw *OFEDFirmwareSpec, path *field.Path) field.ErrorList {
+	if fw == nil {
+		return nil
+	}
+	var sources []string
+	if fw.ConfigMap != nil {
+		if fw.ConfigMap.Name == "" {
+			return field.ErrorList{field.Required(path.Child("configMap", "name"), "ConfigMap name must be set")}
+		}
+		sources = append(sources, "configMap")
+	}
+	if fw.PersistentVolumeClaim != nil {
+		if fw.PersistentVolumeClaim.ClaimName == "" {
+			return field.ErrorList{field.Required(path.Child("persistentVolumeClaim", "claimName"),
+				"claim name must be set")}
+		}
+		sources = append(sources, "persistentVolumeClaim")
+	}
+	if fw.HostPath != nil {
+		if !strings.HasPrefix(fw.HostPath.Path, "/") {
+			return field.ErrorList{field.Invalid(path.Child("hostPath", "path"), fw.HostPath.Path,
+				"host path must be absolute")}
+		}
+		sources = append(sources, "hostPath")
+	}
+	switch len(sources) {
+	case 0:
+		return field.ErrorList{field.Required(path,
+			"one of configMap, persistentVolumeClaim and hostPath must be set as firmware source")}
+	case 1:
+		return nil
+	default:
+		return field.ErrorList{field.Invalid(path, strings.Join(sources, ","),
+			"only one of configMap, persistentVolumeClaim and hostPath can be set as firmware source")}
+	}
+}
+
+// deviceSelectors contains the device selectors of a device plugin resource
+// which are supported by both RDMA shared and SR-IOV device plugins
+type deviceSelectors struct {
+	resource  string
+	vendors   []string
+	deviceIDs []string
+	drivers   []string
+	ifNames   []string
+	linkTypes []string
+}
+
+// overlaps returns true if both resources may select the same device,
+// i.e. the values intersect for every selector which is set for both resources
+func (s *deviceSelectors) overlaps(other *deviceSelectors) bool {
+	return intersects(s.vendors, other.vendors) && intersects(s.deviceIDs, other.deviceIDs) &&
+		intersects(s.drivers, other.drivers) && intersects(s.ifNames, other.ifNames) &&
+		intersects(s.linkTypes, other.linkTypes)
+}
+
+func (s *deviceSelectors) String() string {
+	var parts []string
+	for _, selector := range []struct {
+		name   string
+		values []string
+	}{
+		{"vendors", s.vendors},
+		{"deviceIDs", s.deviceIDs},
+		{"drivers", s.drivers},
+		{"ifNames", s.ifNames},
+		{"linkTypes", s.linkTypes},
+	} {
+		if len(selector.values) != 0 {
+			parts = append(parts, fmt.Sprintf("%s=%v", selector.name, selector.values))
+		}
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// intersects returns true if any of the value lists is empty (matches all devices) or the lists have a common value
+func intersects(a, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return true
+	}
+	for _, x := range a {
+		for _, y := range b {
+			if strings.EqualFold(x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rdmaSharedDevicePluginSelectors returns device selectors of the RDMA shared device plugin resources
+func rdmaSharedDevicePluginSelectors(spec *RdmaSharedDevicePluginSpec) ([]*deviceSelectors, error) {
+	pools := spec.ResourcePools
+	if len(pools) == 0 && spec.Config != "" {
+		config := struct {
+			ConfigList []struct {
+				ResourceName string                        `json:"resourceName"`
+				Selectors    RdmaSharedDevicePoolSelectors `json:"selectors"`
+			} `json:"configList"`
+		}{}
+		if err := json.Unmarshal([]byte(spec.Config), &config); err != nil {
+			return nil, fmt.Errorf("failed to parse RDMA shared device plugin config: %v", err)
+		}
+		for _, resource := range config.ConfigList {
+			pools = append(pools, RdmaSharedDevicePoolSpec{Name: resource.ResourceName, Selectors: resource.Selectors})
+		}
+	}
+	result := make([]*deviceSelectors, 0, len(pools))
+	for i := range pools {
+		result = append(result, &deviceSelectors{
+			resource:  pools[i].Name,
+			vendors:   pools[i].Selectors.Vendors,
+			deviceIDs: pools[i].Selectors.DeviceIDs,
+			drivers:   pools[i].Selectors.Drivers,
+			ifNames:   pools[i].Selectors.IfNames,
+			linkTypes: pools[i].Selectors.LinkTypes,
+		})
+	}
+	return result, nil
+}
+
+// sriovSelectors is the subset of SR-IOV device plugin selectors used to detect overlaps
+type sriovSelectors struct {
+	Vendors      []string `json:"vendors"`
+	Devices      []string `json:"devices"`
+	Drivers      []string `json:"drivers"`
+	PfNames      []string `json:"pfNames"`
+	LinkTypes    []string `json:"linkTypes"`
+	RootDevices  []string `json:"rootDevices"`
+	PciAddresses []string `json:"pciAddresses"`
+}
+
+// sriovDevicePluginSelectors returns device selectors of the SR-IOV device plugin resources.
+// Resources selected by PCI addresses are skipped as the addresses can't be compared with other selectors
+func sriovDevicePluginSelectors(spec *DevicePluginSpec) ([]*deviceSelectors, error) {
+	if spec.Config == "" {
+		return nil, nil
+	}
+	config := struct {
+		ResourceList []struct {
+			ResourceName string          `json:"resourceName"`
+			Selectors    json.RawMessage `json:"selectors"`
+		} `json:"resourceList"`
+	}{}
+	if err := json.Unmarshal([]byte(spec.Config), &config); err != nil {
+		return nil, fmt.Errorf("failed to parse SR-IOV device plugin config: %v", err)
+	}
+	var result []*deviceSelectors
+	for _, resource := range config.ResourceList {
+		var selectorsList []sriovSelectors
+		selectors := bytes.TrimSpace(resource.Selectors)
+		switch {
+		case len(selectors) == 0:
+			selectorsList = []sriovSelectors{{}}
+		case selectors[0] == '[':
+			// newer device plugin versions accept a list of selectors
+			if err := json.Unmarshal(selectors, &selectorsList); err != nil {
+				return nil, fmt.Errorf("failed to parse SR-IOV device plugin config: %v", err)
+			}
+		default:
+			selectorsList = make([]sriovSelectors, 1)
+			if err := json.Unmarshal(selectors, &selectorsList[0]); err != nil {
+				return nil, fmt.Errorf("failed to parse SR-IOV device plugin config: %v", err)
+			}
+		}
+		for i := range selectorsList {
+			s := &selectorsList[i]
+			if len(s.RootDevices) != 0 || len(s.PciAddresses) != 0 {
+				continue
+			}
+			ifNames := make([]string, 0, len(s.PfNames))
+			for _, pfName := range s.PfNames {
+				// strip VF range, e.g. ens1f0#0-3
+				ifNames = append(ifNames, strings.SplitN(pfName, "#", 2)[0])
+			}
+			result = append(result, &deviceSelectors{
+				resource:  resource.ResourceName,
+				vendors:   s.Vendors,
+				deviceIDs: s.Devices,
+				drivers:   s.Drivers,
+				ifNames:   ifNames,
+				linkTypes: s.LinkTypes,
+			})
+		}
+	}
+	return result, nil
+}
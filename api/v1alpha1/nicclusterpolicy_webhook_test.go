@@ -0,0 +1,191 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/Mellanox/network-operator/api/v1alpha1"
+)
+
+var _ = Describe("NicClusterPolicy validating webhook", func() {
+	var cr *v1alpha1.NicClusterPolicy
+	image := v1alpha1.ImageSpec{Image: "component", Repository: "nvcr.io/nvidia/mellanox", Version: "v1.0.0"}
+
+	BeforeEach(func() {
+		cr = &v1alpha1.NicClusterPolicy{}
+		cr.Name = "nic-cluster-policy"
+		cr.Spec.RdmaSharedDevicePlugin = &v1alpha1.RdmaSharedDevicePluginSpec{
+			ImageSpec: image,
+			Config: `{"configList": [{"resourceName": "rdma_shared_device_a",
+				"selectors": {"vendors": ["15b3"], "ifNames": ["ens1f0"]}}]}`,
+		}
+		cr.Spec.SriovDevicePlugin = &v1alpha1.DevicePluginSpec{
+			ImageSpec: image,
+			Config: `{"resourceList": [{"resourceName": "hostdev",
+				"selectors": {"vendors": ["15b3"], "pfNames": ["ens2f0#0-7"]}}]}`,
+		}
+	})
+
+	It("Should accept device plugins selecting different devices", func() {
+		Expect(cr.ValidateCreate()).To(Succeed())
+		Expect(cr.ValidateUpdate(cr.DeepCopy())).To(Succeed())
+	})
+
+	It("Should accept NicClusterPolicy with a single device plugin", func() {
+		cr.Spec.SriovDevicePlugin.Config = `{"resourceList": [{"resourceName": "hostdev"}]}`
+		cr.Spec.RdmaSharedDevicePlugin = nil
+		Expect(cr.ValidateCreate()).To(Succeed())
+	})
+
+	It("Should reject device plugins with overlapping selectors", func() {
+		cr.Spec.SriovDevicePlugin.Config = `{"resourceList": [{"resourceName": "hostdev",
+			"selectors": {"vendors": ["15B3"], "pfNames": ["ens1f0#0-7"]}}]}`
+		err := cr.ValidateCreate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(`resource "hostdev"`))
+		Expect(err.Error()).To(ContainSubstring(`resource "rdma_shared_device_a"`))
+		Expect(err.Error()).To(ContainSubstring("ifNames=[ens1f0]"))
+	})
+
+	It("Should reject SR-IOV resource without selectors overlapping RDMA resource pools", func() {
+		cr.Spec.RdmaSharedDevicePlugin = &v1alpha1.RdmaSharedDevicePluginSpec{
+			ImageSpec: image,
+			ResourcePools: []v1alpha1.RdmaSharedDevicePoolSpec{{
+				Name:      "rdma_shared_device_b",
+				Selectors: v1alpha1.RdmaSharedDevicePoolSelectors{DeviceIDs: []string{"101b"}},
+			}},
+		}
+		cr.Spec.SriovDevicePlugin.Config = `{"resourceList": [{"resourceName": "hostdev",
+			"selectors": [{"devices": ["101e"]}, {"drivers": ["mlx5_core"]}]}]}`
+		err := cr.ValidateUpdate(cr.DeepCopy())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(`resource "rdma_shared_device_b"`))
+	})
+
+	It("Should skip SR-IOV resources selected by PCI address", func() {
+		cr.Spec.SriovDevicePlugin.Config = `{"resourceList": [{"resourceName": "hostdev",
+			"selectors": {"vendors": ["15b3"], "rootDevices": ["0000:03:00.0"]}}]}`
+		Expect(cr.ValidateCreate()).To(Succeed())
+	})
+
+	It("Should reject invalid device plugin config", func() {
+		cr.Spec.SriovDevicePlugin.Config = "invalid"
+		Expect(cr.ValidateCreate()).NotTo(Succeed())
+		Expect(cr.ValidateDelete()).To(Succeed())
+	})
+
+	It("Should reject device plugins with configRef and inline configuration", func() {
+		cr.Spec.SriovDevicePlugin.ConfigRef = "sriov-config"
+		cr.Spec.RdmaSharedDevicePlugin.ConfigRef = "rdma-config"
+		err := cr.ValidateCreate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("spec.sriovDevicePlugin.configRef"))
+		Expect(err.Error()).To(ContainSubstring("spec.rdmaSharedDevicePlugin.configRef"))
+
+		cr.Spec.SriovDevicePlugin.Config = ""
+		cr.Spec.RdmaSharedDevicePlugin.Config = ""
+		Expect(cr.ValidateCreate()).To(Succeed())
+	})
+
+	It("Should reject enabled components with invalid images", func() {
+		disabled := false
+		cr.Spec.OFEDDriver = &v1alpha1.OFEDDriverSpec{ImageSpec: image}
+		cr.Spec.OFEDDriver.Image = "mofed:5.7-0.1.2.0"
+		cr.Spec.OFEDDriver.Version = " "
+		cr.Spec.SecondaryNetwork = &v1alpha1.SecondaryNetworkSpec{
+			Multus:     &v1alpha1.MultusSpec{ImageSpec: image},
+			CniPlugins: &v1alpha1.ImageSpec{Image: "plugins", Repository: "ghcr.io/k8snetworkplumbingwg", Version: "v1 0"},
+		}
+		cr.Spec.NVPeerDriver = &v1alpha1.NVPeerDriverSpec{ImageSpec: v1alpha1.ImageSpec{Enabled: &disabled}}
+		err := cr.ValidateCreate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("spec.ofedDriver.image"))
+		Expect(err.Error()).To(ContainSubstring("spec.ofedDriver.version"))
+		Expect(err.Error()).To(ContainSubstring("spec.secondaryNetwork.cniPlugins.version"))
+		Expect(err.Error()).NotTo(ContainSubstring("spec.nvPeerDriver"))
+		Expect(err.Error()).NotTo(ContainSubstring("spec.secondaryNetwork.multus"))
+
+		cr.Spec.OFEDDriver.ImageSpec = image
+		cr.Spec.SecondaryNetwork.CniPlugins.Version = "v1.0"
+		Expect(cr.ValidateSpec()).To(Succeed())
+	})
+
+	It("Should reject device plugin config which is not JSON", func() {
+		cr.Spec.RdmaSharedDevicePlugin.Config = "configList: []"
+		err := cr.ValidateCreate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("spec.rdmaSharedDevicePlugin.config"))
+	})
+
+	It("Should accept driver security context which keeps the driver privileged", func() {
+		privileged := true
+		cr.Spec.OFEDDriver = &v1alpha1.OFEDDriverSpec{ImageSpec: image}
+		cr.Spec.OFEDDriver.SecurityContext = &corev1.SecurityContext{
+			Privileged:     &privileged,
+			SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+			Capabilities:   &corev1.Capabilities{Drop: []corev1.Capability{"NET_RAW"}},
+		}
+		Expect(cr.ValidateCreate()).To(Succeed())
+	})
+
+	It("Should reject driver security context which removes required settings", func() {
+		privileged := false
+		nonRoot := true
+		cr.Spec.NVPeerDriver = &v1alpha1.NVPeerDriverSpec{ImageSpec: image}
+		cr.Spec.NVPeerDriver.SecurityContext = &corev1.SecurityContext{
+			Privileged:   &privileged,
+			RunAsNonRoot: &nonRoot,
+			Capabilities: &corev1.Capabilities{Drop: []corev1.Capability{"CAP_SYS_MODULE"}},
+		}
+		err := cr.ValidateCreate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("spec.nvPeerDriver.securityContext.privileged"))
+		Expect(err.Error()).To(ContainSubstring("spec.nvPeerDriver.securityContext.runAsNonRoot"))
+		Expect(err.Error()).To(ContainSubstring("spec.nvPeerDriver.securityContext.capabilities.drop"))
+		Expect(v1alpha1.ValidateDriverSecurityContext(cr.Spec.NVPeerDriver.SecurityContext)).NotTo(Succeed())
+		Expect(v1alpha1.ValidateDriverSecurityContext(nil)).To(Succeed())
+	})
+
+	It("Should accept a single firmware source", func() {
+		cr.Spec.OFEDDriver = &v1alpha1.OFEDDriverSpec{ImageSpec: image, Firmware: &v1alpha1.OFEDFirmwareSpec{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "firmware"},
+			Update:                true,
+		}}
+		Expect(cr.ValidateCreate()).To(Succeed())
+		Expect(v1alpha1.ValidateFirmwareSource(nil)).To(Succeed())
+	})
+
+	It("Should reject missing or several firmware sources", func() {
+		cr.Spec.OFEDDriver = &v1alpha1.OFEDDriverSpec{ImageSpec: image, Firmware: &v1alpha1.OFEDFirmwareSpec{}}
+		err := cr.ValidateCreate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("spec.ofedDriver.firmware"))
+
+		cr.Spec.OFEDDriver.Firmware.ConfigMap = &v1alpha1.ConfigMapNameReference{Name: "firmware"}
+		cr.Spec.OFEDDriver.Firmware.HostPath = &corev1.HostPathVolumeSource{Path: "/opt/firmware"}
+		Expect(cr.ValidateCreate()).NotTo(Succeed())
+		Expect(v1alpha1.ValidateFirmwareSource(cr.Spec.OFEDDriver.Firmware)).NotTo(Succeed())
+
+		cr.Spec.OFEDDriver.Firmware.ConfigMap = nil
+		cr.Spec.OFEDDriver.Firmware.HostPath.Path = "opt/firmware"
+		Expect(cr.ValidateCreate()).NotTo(Succeed())
+	})
+})
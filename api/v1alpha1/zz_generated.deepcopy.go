@@ -22,8 +22,11 @@ limitations under the License.
 package v1alpha1
 
 import (
-	"k8s.io/api/core/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -56,10 +59,163 @@ func (in *ConfigMapNameReference) DeepCopy() *ConfigMapNameReference {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DOCATelemetrySpec) DeepCopyInto(out *DOCATelemetrySpec) {
+	*out = *in
+	in.ImageSpec.DeepCopyInto(&out.ImageSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DOCATelemetrySpec.
+func (in *DOCATelemetrySpec) DeepCopy() *DOCATelemetrySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DOCATelemetrySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DevicePluginConfig) DeepCopyInto(out *DevicePluginConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DevicePluginConfig.
+func (in *DevicePluginConfig) DeepCopy() *DevicePluginConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DevicePluginConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DevicePluginConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DevicePluginConfigList) DeepCopyInto(out *DevicePluginConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]DevicePluginConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DevicePluginConfigList.
+func (in *DevicePluginConfigList) DeepCopy() *DevicePluginConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(DevicePluginConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DevicePluginConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DevicePluginConfigSpec) DeepCopyInto(out *DevicePluginConfigSpec) {
+	*out = *in
+	if in.RdmaSharedResourcePools != nil {
+		in, out := &in.RdmaSharedResourcePools, &out.RdmaSharedResourcePools
+		*out = make([]RdmaSharedDevicePoolSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DevicePluginConfigSpec.
+func (in *DevicePluginConfigSpec) DeepCopy() *DevicePluginConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DevicePluginConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DevicePluginConfigStatus) DeepCopyInto(out *DevicePluginConfigStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DevicePluginConfigStatus.
+func (in *DevicePluginConfigStatus) DeepCopy() *DevicePluginConfigStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DevicePluginConfigStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DevicePluginSpec) DeepCopyInto(out *DevicePluginSpec) {
 	*out = *in
 	in.ImageSpec.DeepCopyInto(&out.ImageSpec)
+	if in.AdditionalInitContainers != nil {
+		in, out := &in.AdditionalInitContainers, &out.AdditionalInitContainers
+		*out = make([]corev1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]corev1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DriverReadyAffinity != nil {
+		in, out := &in.DriverReadyAffinity, &out.DriverReadyAffinity
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.InheritDriverTolerations != nil {
+		in, out := &in.InheritDriverTolerations, &out.InheritDriverTolerations
+		*out = new(bool)
+		**out = **in
+	}
+	if in.MinDriverReadyNodes != nil {
+		in, out := &in.MinDriverReadyNodes, &out.MinDriverReadyNodes
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.UpdateStrategy != nil {
+		in, out := &in.UpdateStrategy, &out.UpdateStrategy
+		*out = new(appsv1.DaemonSetUpdateStrategy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DevicePluginSpec.
@@ -72,9 +228,37 @@ func (in *DevicePluginSpec) DeepCopy() *DevicePluginSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DrainAvailabilityCheckSpec) DeepCopyInto(out *DrainAvailabilityCheckSpec) {
+	*out = *in
+	in.Selector.DeepCopyInto(&out.Selector)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DrainAvailabilityCheckSpec.
+func (in *DrainAvailabilityCheckSpec) DeepCopy() *DrainAvailabilityCheckSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DrainAvailabilityCheckSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DrainSpec) DeepCopyInto(out *DrainSpec) {
 	*out = *in
+	if in.GracePeriodSeconds != nil {
+		in, out := &in.GracePeriodSeconds, &out.GracePeriodSeconds
+		*out = new(int)
+		**out = **in
+	}
+	if in.AvailabilityChecks != nil {
+		in, out := &in.AvailabilityChecks, &out.AvailabilityChecks
+		*out = make([]DrainAvailabilityCheckSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DrainSpec.
@@ -87,12 +271,81 @@ func (in *DrainSpec) DeepCopy() *DrainSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriverValidationJobSpec) DeepCopyInto(out *DriverValidationJobSpec) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriverValidationJobSpec.
+func (in *DriverValidationJobSpec) DeepCopy() *DriverValidationJobSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DriverValidationJobSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriverValidationSpec) DeepCopyInto(out *DriverValidationSpec) {
+	*out = *in
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Job != nil {
+		in, out := &in.Job, &out.Job
+		*out = new(DriverValidationJobSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriverValidationSpec.
+func (in *DriverValidationSpec) DeepCopy() *DriverValidationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DriverValidationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostDeviceDPDKSpec) DeepCopyInto(out *HostDeviceDPDKSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostDeviceDPDKSpec.
+func (in *HostDeviceDPDKSpec) DeepCopy() *HostDeviceDPDKSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HostDeviceDPDKSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HostDeviceNetwork) DeepCopyInto(out *HostDeviceNetwork) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -149,6 +402,16 @@ func (in *HostDeviceNetworkList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HostDeviceNetworkSpec) DeepCopyInto(out *HostDeviceNetworkSpec) {
 	*out = *in
+	if in.DefaultRoute != nil {
+		in, out := &in.DefaultRoute, &out.DefaultRoute
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DPDK != nil {
+		in, out := &in.DPDK, &out.DPDK
+		*out = new(HostDeviceDPDKSpec)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostDeviceNetworkSpec.
@@ -164,11 +427,28 @@ func (in *HostDeviceNetworkSpec) DeepCopy() *HostDeviceNetworkSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HostDeviceNetworkStatus) DeepCopyInto(out *HostDeviceNetworkStatus) {
 	*out = *in
+	if in.NetworkAttachmentDefinition != nil {
+		in, out := &in.NetworkAttachmentDefinition, &out.NetworkAttachmentDefinition
+		*out = new(NetworkAttachmentDefinitionStatus)
+		**out = **in
+	}
+	if in.GeneratedNetworkAttachmentDefinitions != nil {
+		in, out := &in.GeneratedNetworkAttachmentDefinitions, &out.GeneratedNetworkAttachmentDefinitions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.AppliedStates != nil {
 		in, out := &in.AppliedStates, &out.AppliedStates
 		*out = make([]AppliedState, len(*in))
 		copy(*out, *in)
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostDeviceNetworkStatus.
@@ -187,7 +467,7 @@ func (in *IPoIBNetwork) DeepCopyInto(out *IPoIBNetwork) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPoIBNetwork.
@@ -258,6 +538,23 @@ func (in *IPoIBNetworkSpec) DeepCopy() *IPoIBNetworkSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IPoIBNetworkStatus) DeepCopyInto(out *IPoIBNetworkStatus) {
 	*out = *in
+	if in.NetworkAttachmentDefinition != nil {
+		in, out := &in.NetworkAttachmentDefinition, &out.NetworkAttachmentDefinition
+		*out = new(NetworkAttachmentDefinitionStatus)
+		**out = **in
+	}
+	if in.GeneratedNetworkAttachmentDefinitions != nil {
+		in, out := &in.GeneratedNetworkAttachmentDefinitions, &out.GeneratedNetworkAttachmentDefinitions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPoIBNetworkStatus.
@@ -278,6 +575,16 @@ func (in *ImageSpec) DeepCopyInto(out *ImageSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(corev1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Enabled != nil {
+		in, out := &in.Enabled, &out.Enabled
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageSpec.
@@ -295,8 +602,8 @@ func (in *MacvlanNetwork) DeepCopyInto(out *MacvlanNetwork) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MacvlanNetwork.
@@ -352,6 +659,16 @@ func (in *MacvlanNetworkList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MacvlanNetworkSpec) DeepCopyInto(out *MacvlanNetworkSpec) {
 	*out = *in
+	if in.TargetNamespaces != nil {
+		in, out := &in.TargetNamespaces, &out.TargetNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DefaultRoute != nil {
+		in, out := &in.DefaultRoute, &out.DefaultRoute
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MacvlanNetworkSpec.
@@ -367,6 +684,23 @@ func (in *MacvlanNetworkSpec) DeepCopy() *MacvlanNetworkSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MacvlanNetworkStatus) DeepCopyInto(out *MacvlanNetworkStatus) {
 	*out = *in
+	if in.NetworkAttachmentDefinition != nil {
+		in, out := &in.NetworkAttachmentDefinition, &out.NetworkAttachmentDefinition
+		*out = new(NetworkAttachmentDefinitionStatus)
+		**out = **in
+	}
+	if in.GeneratedNetworkAttachmentDefinitions != nil {
+		in, out := &in.GeneratedNetworkAttachmentDefinitions, &out.GeneratedNetworkAttachmentDefinitions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MacvlanNetworkStatus.
@@ -412,26 +746,41 @@ func (in *NVPeerDriverSpec) DeepCopy() *NVPeerDriverSpec {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NicClusterPolicy) DeepCopyInto(out *NicClusterPolicy) {
+func (in *NetworkAttachmentDefinitionStatus) DeepCopyInto(out *NetworkAttachmentDefinitionStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkAttachmentDefinitionStatus.
+func (in *NetworkAttachmentDefinitionStatus) DeepCopy() *NetworkAttachmentDefinitionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkAttachmentDefinitionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkDiagnostic) DeepCopyInto(out *NetworkDiagnostic) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
+	out.Spec = in.Spec
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NicClusterPolicy.
-func (in *NicClusterPolicy) DeepCopy() *NicClusterPolicy {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkDiagnostic.
+func (in *NetworkDiagnostic) DeepCopy() *NetworkDiagnostic {
 	if in == nil {
 		return nil
 	}
-	out := new(NicClusterPolicy)
+	out := new(NetworkDiagnostic)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *NicClusterPolicy) DeepCopyObject() runtime.Object {
+func (in *NetworkDiagnostic) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -439,31 +788,31 @@ func (in *NicClusterPolicy) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NicClusterPolicyList) DeepCopyInto(out *NicClusterPolicyList) {
+func (in *NetworkDiagnosticList) DeepCopyInto(out *NetworkDiagnosticList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]NicClusterPolicy, len(*in))
+		*out = make([]NetworkDiagnostic, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NicClusterPolicyList.
-func (in *NicClusterPolicyList) DeepCopy() *NicClusterPolicyList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkDiagnosticList.
+func (in *NetworkDiagnosticList) DeepCopy() *NetworkDiagnosticList {
 	if in == nil {
 		return nil
 	}
-	out := new(NicClusterPolicyList)
+	out := new(NetworkDiagnosticList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *NicClusterPolicyList) DeepCopyObject() runtime.Object {
+func (in *NetworkDiagnosticList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -471,26 +820,119 @@ func (in *NicClusterPolicyList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NicClusterPolicySpec) DeepCopyInto(out *NicClusterPolicySpec) {
+func (in *NetworkDiagnosticSpec) DeepCopyInto(out *NetworkDiagnosticSpec) {
 	*out = *in
-	if in.NodeAffinity != nil {
-		in, out := &in.NodeAffinity, &out.NodeAffinity
-		*out = new(v1.NodeAffinity)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.OFEDDriver != nil {
-		in, out := &in.OFEDDriver, &out.OFEDDriver
-		*out = new(OFEDDriverSpec)
-		(*in).DeepCopyInto(*out)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkDiagnosticSpec.
+func (in *NetworkDiagnosticSpec) DeepCopy() *NetworkDiagnosticSpec {
+	if in == nil {
+		return nil
 	}
-	if in.NVPeerDriver != nil {
-		in, out := &in.NVPeerDriver, &out.NVPeerDriver
-		*out = new(NVPeerDriverSpec)
-		(*in).DeepCopyInto(*out)
+	out := new(NetworkDiagnosticSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkDiagnosticStatus) DeepCopyInto(out *NetworkDiagnosticStatus) {
+	*out = *in
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkDiagnosticStatus.
+func (in *NetworkDiagnosticStatus) DeepCopy() *NetworkDiagnosticStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkDiagnosticStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NicClusterPolicy) DeepCopyInto(out *NicClusterPolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NicClusterPolicy.
+func (in *NicClusterPolicy) DeepCopy() *NicClusterPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(NicClusterPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NicClusterPolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NicClusterPolicyList) DeepCopyInto(out *NicClusterPolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NicClusterPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NicClusterPolicyList.
+func (in *NicClusterPolicyList) DeepCopy() *NicClusterPolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(NicClusterPolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NicClusterPolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NicClusterPolicySpec) DeepCopyInto(out *NicClusterPolicySpec) {
+	*out = *in
+	if in.NodeAffinity != nil {
+		in, out := &in.NodeAffinity, &out.NodeAffinity
+		*out = new(corev1.NodeAffinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OFEDDriver != nil {
+		in, out := &in.OFEDDriver, &out.OFEDDriver
+		*out = new(OFEDDriverSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NVPeerDriver != nil {
+		in, out := &in.NVPeerDriver, &out.NVPeerDriver
+		*out = new(NVPeerDriverSpec)
+		(*in).DeepCopyInto(*out)
 	}
 	if in.RdmaSharedDevicePlugin != nil {
 		in, out := &in.RdmaSharedDevicePlugin, &out.RdmaSharedDevicePlugin
-		*out = new(DevicePluginSpec)
+		*out = new(RdmaSharedDevicePluginSpec)
 		(*in).DeepCopyInto(*out)
 	}
 	if in.SriovDevicePlugin != nil {
@@ -508,6 +950,16 @@ func (in *NicClusterPolicySpec) DeepCopyInto(out *NicClusterPolicySpec) {
 		*out = new(PSPSpec)
 		**out = **in
 	}
+	if in.DOCATelemetry != nil {
+		in, out := &in.DOCATelemetry, &out.DOCATelemetry
+		*out = new(DOCATelemetrySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ImageBundle != nil {
+		in, out := &in.ImageBundle, &out.ImageBundle
+		*out = new(ConfigMapNameReference)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NicClusterPolicySpec.
@@ -528,6 +980,18 @@ func (in *NicClusterPolicyStatus) DeepCopyInto(out *NicClusterPolicyStatus) {
 		*out = make([]AppliedState, len(*in))
 		copy(*out, *in)
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Upgrade != nil {
+		in, out := &in.Upgrade, &out.Upgrade
+		*out = new(UpgradeStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NicClusterPolicyStatus.
@@ -561,7 +1025,7 @@ func (in *OFEDDriverSpec) DeepCopyInto(out *OFEDDriverSpec) {
 	}
 	if in.Env != nil {
 		in, out := &in.Env, &out.Env
-		*out = make([]v1.EnvVar, len(*in))
+		*out = make([]corev1.EnvVar, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
@@ -581,6 +1045,33 @@ func (in *OFEDDriverSpec) DeepCopyInto(out *OFEDDriverSpec) {
 		*out = new(ConfigMapNameReference)
 		**out = **in
 	}
+	if in.HostNetwork != nil {
+		in, out := &in.HostNetwork, &out.HostNetwork
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DNSConfig != nil {
+		in, out := &in.DNSConfig, &out.DNSConfig
+		*out = new(corev1.PodDNSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.InitContainer != nil {
+		in, out := &in.InitContainer, &out.InitContainer
+		*out = new(OFEDInitContainerSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Firmware != nil {
+		in, out := &in.Firmware, &out.Firmware
+		*out = new(OFEDFirmwareSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OFEDDriverSpec.
@@ -593,13 +1084,102 @@ func (in *OFEDDriverSpec) DeepCopy() *OFEDDriverSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OFEDFirmwareSpec) DeepCopyInto(out *OFEDFirmwareSpec) {
+	*out = *in
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(ConfigMapNameReference)
+		**out = **in
+	}
+	if in.PersistentVolumeClaim != nil {
+		in, out := &in.PersistentVolumeClaim, &out.PersistentVolumeClaim
+		*out = new(corev1.PersistentVolumeClaimVolumeSource)
+		**out = **in
+	}
+	if in.HostPath != nil {
+		in, out := &in.HostPath, &out.HostPath
+		*out = new(corev1.HostPathVolumeSource)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OFEDFirmwareSpec.
+func (in *OFEDFirmwareSpec) DeepCopy() *OFEDFirmwareSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OFEDFirmwareSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OFEDInitContainerSpec) DeepCopyInto(out *OFEDInitContainerSpec) {
+	*out = *in
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Args != nil {
+		in, out := &in.Args, &out.Args
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]corev1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OFEDInitContainerSpec.
+func (in *OFEDInitContainerSpec) DeepCopy() *OFEDInitContainerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OFEDInitContainerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OfedUpgradePolicySpec) DeepCopyInto(out *OfedUpgradePolicySpec) {
 	*out = *in
+	if in.DriverValidation != nil {
+		in, out := &in.DriverValidation, &out.DriverValidation
+		*out = new(DriverValidationSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UncordonWaitForPods != nil {
+		in, out := &in.UncordonWaitForPods, &out.UncordonWaitForPods
+		*out = new(UncordonWaitForPodsSpec)
+		**out = **in
+	}
+	if in.NodeMarks != nil {
+		in, out := &in.NodeMarks, &out.NodeMarks
+		*out = new(UpgradeNodeMarksSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EventSink != nil {
+		in, out := &in.EventSink, &out.EventSink
+		*out = new(UpgradeEventSinkSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodeGroups != nil {
+		in, out := &in.NodeGroups, &out.NodeGroups
+		*out = make([]UpgradeNodeGroupSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.DrainSpec != nil {
 		in, out := &in.DrainSpec, &out.DrainSpec
 		*out = new(DrainSpec)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 }
 
@@ -643,6 +1223,126 @@ func (in *PodProbeSpec) DeepCopy() *PodProbeSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RdmaSharedDevicePluginSpec) DeepCopyInto(out *RdmaSharedDevicePluginSpec) {
+	*out = *in
+	in.ImageSpec.DeepCopyInto(&out.ImageSpec)
+	if in.ResourcePools != nil {
+		in, out := &in.ResourcePools, &out.ResourcePools
+		*out = make([]RdmaSharedDevicePoolSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.AdditionalInitContainers != nil {
+		in, out := &in.AdditionalInitContainers, &out.AdditionalInitContainers
+		*out = make([]corev1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]corev1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DriverReadyAffinity != nil {
+		in, out := &in.DriverReadyAffinity, &out.DriverReadyAffinity
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.InheritDriverTolerations != nil {
+		in, out := &in.InheritDriverTolerations, &out.InheritDriverTolerations
+		*out = new(bool)
+		**out = **in
+	}
+	if in.MinDriverReadyNodes != nil {
+		in, out := &in.MinDriverReadyNodes, &out.MinDriverReadyNodes
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.UpdateStrategy != nil {
+		in, out := &in.UpdateStrategy, &out.UpdateStrategy
+		*out = new(appsv1.DaemonSetUpdateStrategy)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RdmaSharedDevicePluginSpec.
+func (in *RdmaSharedDevicePluginSpec) DeepCopy() *RdmaSharedDevicePluginSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RdmaSharedDevicePluginSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RdmaSharedDevicePoolSelectors) DeepCopyInto(out *RdmaSharedDevicePoolSelectors) {
+	*out = *in
+	if in.Vendors != nil {
+		in, out := &in.Vendors, &out.Vendors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DeviceIDs != nil {
+		in, out := &in.DeviceIDs, &out.DeviceIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Drivers != nil {
+		in, out := &in.Drivers, &out.Drivers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IfNames != nil {
+		in, out := &in.IfNames, &out.IfNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LinkTypes != nil {
+		in, out := &in.LinkTypes, &out.LinkTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RdmaSharedDevicePoolSelectors.
+func (in *RdmaSharedDevicePoolSelectors) DeepCopy() *RdmaSharedDevicePoolSelectors {
+	if in == nil {
+		return nil
+	}
+	out := new(RdmaSharedDevicePoolSelectors)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RdmaSharedDevicePoolSpec) DeepCopyInto(out *RdmaSharedDevicePoolSpec) {
+	*out = *in
+	in.Selectors.DeepCopyInto(&out.Selectors)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RdmaSharedDevicePoolSpec.
+func (in *RdmaSharedDevicePoolSpec) DeepCopy() *RdmaSharedDevicePoolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RdmaSharedDevicePoolSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SecondaryNetworkSpec) DeepCopyInto(out *SecondaryNetworkSpec) {
 	*out = *in
@@ -677,3 +1377,113 @@ func (in *SecondaryNetworkSpec) DeepCopy() *SecondaryNetworkSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UncordonWaitForPodsSpec) DeepCopyInto(out *UncordonWaitForPodsSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UncordonWaitForPodsSpec.
+func (in *UncordonWaitForPodsSpec) DeepCopy() *UncordonWaitForPodsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(UncordonWaitForPodsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradeEventSinkSpec) DeepCopyInto(out *UpgradeEventSinkSpec) {
+	*out = *in
+	if in.AuthHeaderSecretRef != nil {
+		in, out := &in.AuthHeaderSecretRef, &out.AuthHeaderSecretRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpgradeEventSinkSpec.
+func (in *UpgradeEventSinkSpec) DeepCopy() *UpgradeEventSinkSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(UpgradeEventSinkSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradeNodeGroupSpec) DeepCopyInto(out *UpgradeNodeGroupSpec) {
+	*out = *in
+	in.NodeSelector.DeepCopyInto(&out.NodeSelector)
+	if in.DisruptionAllowed != nil {
+		in, out := &in.DisruptionAllowed, &out.DisruptionAllowed
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpgradeNodeGroupSpec.
+func (in *UpgradeNodeGroupSpec) DeepCopy() *UpgradeNodeGroupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(UpgradeNodeGroupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradeNodeMarksSpec) DeepCopyInto(out *UpgradeNodeMarksSpec) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Taints != nil {
+		in, out := &in.Taints, &out.Taints
+		*out = make([]corev1.Taint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpgradeNodeMarksSpec.
+func (in *UpgradeNodeMarksSpec) DeepCopy() *UpgradeNodeMarksSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(UpgradeNodeMarksSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradeStatus) DeepCopyInto(out *UpgradeStatus) {
+	*out = *in
+	if in.CordonedNodes != nil {
+		in, out := &in.CordonedNodes, &out.CordonedNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.QuarantinedNodes != nil {
+		in, out := &in.QuarantinedNodes, &out.QuarantinedNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpgradeStatus.
+func (in *UpgradeStatus) DeepCopy() *UpgradeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(UpgradeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,41 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/Mellanox/network-operator/api/v1alpha1"
+)
+
+// ConvertTo converts this NicClusterPolicy to the Hub version (v1alpha1)
+func (src *NicClusterPolicy) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha1.NicClusterPolicy)
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.Spec = *src.Spec.NicClusterPolicySpec.DeepCopy()
+	dst.Status = *src.Status.NicClusterPolicyStatus.DeepCopy()
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1alpha1) to this version
+func (dst *NicClusterPolicy) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1alpha1.NicClusterPolicy)
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.Spec.NicClusterPolicySpec = *src.Spec.DeepCopy()
+	dst.Status.NicClusterPolicyStatus = *src.Status.DeepCopy()
+	return nil
+}
@@ -0,0 +1,94 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+
+	"github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/api/v1beta1"
+)
+
+func newTestNicClusterPolicy() *v1alpha1.NicClusterPolicy {
+	return &v1alpha1.NicClusterPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "nic-cluster-policy",
+			ResourceVersion: "42",
+			Labels:          map[string]string{"foo": "bar"},
+		},
+		Spec: v1alpha1.NicClusterPolicySpec{
+			OFEDDriver: &v1alpha1.OFEDDriverSpec{
+				ImageSpec: v1alpha1.ImageSpec{
+					Image:            "mofed",
+					Repository:       "nvcr.io/nvidia/mellanox",
+					Version:          "5.6-1.0.3.3",
+					ImagePullSecrets: []string{"secret"},
+				},
+				OfedUpgradePolicy: &v1alpha1.OfedUpgradePolicySpec{AutoUpgrade: true, MaxParallelUpgrades: 1},
+			},
+			SecondaryNetwork: &v1alpha1.SecondaryNetworkSpec{
+				Multus: &v1alpha1.MultusSpec{
+					ImageSpec: v1alpha1.ImageSpec{Image: "multus-cni", Repository: "ghcr.io", Version: "v3.8"},
+				},
+			},
+		},
+		Status: v1alpha1.NicClusterPolicyStatus{
+			State:         v1alpha1.StateReady,
+			AppliedStates: []v1alpha1.AppliedState{{Name: "state-OFED", State: v1alpha1.StateReady}},
+		},
+	}
+}
+
+var _ = Describe("NicClusterPolicy conversion", func() {
+	It("Should be convertible between v1alpha1 and v1beta1", func() {
+		scheme := runtime.NewScheme()
+		Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
+		Expect(v1beta1.AddToScheme(scheme)).To(Succeed())
+
+		ok, err := conversion.IsConvertible(scheme, &v1alpha1.NicClusterPolicy{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+	})
+	It("Should round-trip v1alpha1 through v1beta1", func() {
+		original := newTestNicClusterPolicy()
+
+		spoke := &v1beta1.NicClusterPolicy{}
+		Expect(spoke.ConvertFrom(original.DeepCopy())).To(Succeed())
+		Expect(spoke.Name).To(Equal(original.Name))
+		Expect(spoke.Spec.OFEDDriver.Version).To(Equal(original.Spec.OFEDDriver.Version))
+
+		hub := &v1alpha1.NicClusterPolicy{}
+		Expect(spoke.ConvertTo(hub)).To(Succeed())
+		Expect(hub).To(Equal(original))
+	})
+	It("Should round-trip v1beta1 through v1alpha1", func() {
+		original := &v1beta1.NicClusterPolicy{}
+		Expect(original.ConvertFrom(newTestNicClusterPolicy())).To(Succeed())
+
+		hub := &v1alpha1.NicClusterPolicy{}
+		Expect(original.ConvertTo(hub)).To(Succeed())
+
+		spoke := &v1beta1.NicClusterPolicy{}
+		Expect(spoke.ConvertFrom(hub)).To(Succeed())
+		Expect(spoke).To(Equal(original))
+	})
+})
@@ -0,0 +1,63 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Mellanox/network-operator/api/v1alpha1"
+)
+
+// NicClusterPolicySpec defines the desired state of NicClusterPolicy
+// TODO: replace with v1beta1 specific fields, it is identical to v1alpha1 for now
+type NicClusterPolicySpec struct {
+	v1alpha1.NicClusterPolicySpec `json:",inline"`
+}
+
+// NicClusterPolicyStatus defines the observed state of NicClusterPolicy
+type NicClusterPolicyStatus struct {
+	v1alpha1.NicClusterPolicyStatus `json:",inline"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.state`,priority=0
+// +kubebuilder:printcolumn:name="Age",type=string,JSONPath=`.metadata.creationTimestamp`,priority=0
+// +kubebuilder:printcolumn:name="Cordoned",type=string,JSONPath=`.status.upgrade.cordonedNodes`,priority=1
+
+// NicClusterPolicy is the Schema for the nicclusterpolicies API
+type NicClusterPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NicClusterPolicySpec   `json:"spec,omitempty"`
+	Status NicClusterPolicyStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NicClusterPolicyList contains a list of NicClusterPolicy
+type NicClusterPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NicClusterPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NicClusterPolicy{}, &NicClusterPolicyList{})
+}
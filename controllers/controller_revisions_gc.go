@@ -0,0 +1,151 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	appsv1 "k8s.io/api/apps/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/upgrade"
+)
+
+// defaultRevisionHistoryLimit is the number of old revisions kept if the DaemonSet doesn't set the limit,
+// same as the default of the DaemonSet API
+const defaultRevisionHistoryLimit = 10
+
+// prunedControllerRevisionsCounter counts ControllerRevisions of the OFED driver DaemonSets deleted by the operator
+var prunedControllerRevisionsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "network_operator_pruned_controller_revisions_total",
+	Help: "Number of ControllerRevisions of the OFED driver DaemonSets deleted by the operator",
+}, []string{"reason"})
+
+func init() {
+	metrics.Registry.MustRegister(prunedControllerRevisionsCounter)
+}
+
+// +kubebuilder:rbac:groups=apps,resources=controllerrevisions,verbs=list;delete
+
+// ControllerRevisionsCollector periodically deletes ControllerRevisions of the OFED driver DaemonSets
+// which exceed the revisionHistoryLimit of their DaemonSet, as well as revisions of DaemonSets which no longer
+// exist, e.g. after the DaemonSet was renamed on an OS upgrade of the nodes
+type ControllerRevisionsCollector struct {
+	Client client.Client
+	// Namespace of the collected revisions, revisions in all namespaces are collected if not set,
+	// e.g. when the OFED driver is deployed in another namespace than the operator
+	Namespace string
+	Log       logr.Logger
+	Interval  time.Duration
+}
+
+// Start collects the revisions every Interval until the context is canceled, it implements manager.Runnable
+func (c *ControllerRevisionsCollector) Start(ctx context.Context) error {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		c.collect(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// collect deletes the revisions which are not needed anymore, the latest revision of each DaemonSet is always kept
+func (c *ControllerRevisionsCollector) collect(ctx context.Context) {
+	revisionList := &appsv1.ControllerRevisionList{}
+	err := c.Client.List(ctx, revisionList,
+		client.InNamespace(c.Namespace), client.MatchingLabels{upgrade.OfedDriverLabel: ""})
+	if err != nil {
+		c.Log.V(consts.LogLevelWarning).Info("Failed to list OFED driver ControllerRevisions", "error:", err)
+		return
+	}
+
+	// group revisions by the owner DaemonSet
+	revisions := make(map[types.NamespacedName][]*appsv1.ControllerRevision)
+	for i := range revisionList.Items {
+		revision := &revisionList.Items[i]
+		owner := metav1.GetControllerOf(revision)
+		if owner == nil || owner.Kind != "DaemonSet" {
+			continue
+		}
+		dsName := types.NamespacedName{Namespace: revision.Namespace, Name: owner.Name}
+		revisions[dsName] = append(revisions[dsName], revision)
+	}
+
+	for dsName, dsRevisions := range revisions {
+		ds := &appsv1.DaemonSet{}
+		err := c.Client.Get(ctx, dsName, ds)
+		if apiErrors.IsNotFound(err) {
+			c.prune(ctx, dsRevisions, "orphaned")
+			continue
+		}
+		if err != nil {
+			c.Log.V(consts.LogLevelWarning).Info("Failed to get OFED driver DaemonSet", "name", dsName.String(),
+				"error:", err)
+			continue
+		}
+		limit := defaultRevisionHistoryLimit
+		if ds.Spec.RevisionHistoryLimit != nil {
+			limit = int(*ds.Spec.RevisionHistoryLimit)
+		}
+		c.prune(ctx, revisionsOverLimit(dsRevisions, limit), "historyLimit")
+	}
+}
+
+// prune deletes the revisions and counts the deleted ones, revisions which are already deleted are not counted
+func (c *ControllerRevisionsCollector) prune(
+	ctx context.Context, revisions []*appsv1.ControllerRevision, reason string) {
+	for _, revision := range revisions {
+		err := c.Client.Delete(ctx, revision)
+		if apiErrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			c.Log.V(consts.LogLevelWarning).Info("Failed to delete ControllerRevision",
+				"name", revision.Name, "error:", err)
+			continue
+		}
+		c.Log.V(consts.LogLevelInfo).Info("Deleted ControllerRevision", "name", revision.Name, "reason", reason)
+		prunedControllerRevisionsCounter.WithLabelValues(reason).Inc()
+	}
+}
+
+// revisionsOverLimit returns the oldest revisions exceeding the limit of old revisions,
+// the latest revision is the current one and is not counted
+func revisionsOverLimit(revisions []*appsv1.ControllerRevision, limit int) []*appsv1.ControllerRevision {
+	if limit < 0 {
+		limit = 0
+	}
+	if len(revisions) <= limit+1 {
+		return nil
+	}
+	sorted := append([]*appsv1.ControllerRevision(nil), revisions...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Revision < sorted[j].Revision })
+	return sorted[:len(sorted)-limit-1]
+}
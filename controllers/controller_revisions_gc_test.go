@@ -0,0 +1,100 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/Mellanox/network-operator/pkg/upgrade"
+)
+
+var _ = Describe("ControllerRevisions collector", func() {
+	const namespace = "network-operator"
+
+	newRevision := func(dsName string, revision int64) *appsv1.ControllerRevision {
+		controller := true
+		return &appsv1.ControllerRevision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("%s-%d", dsName, revision),
+				Namespace: namespace,
+				Labels:    map[string]string{upgrade.OfedDriverLabel: ""},
+				OwnerReferences: []metav1.OwnerReference{
+					{APIVersion: "apps/v1", Kind: "DaemonSet", Name: dsName, UID: "uid", Controller: &controller}},
+			},
+			Revision: revision,
+		}
+	}
+
+	It("should keep the current revision and revisionHistoryLimit old revisions", func() {
+		Expect(revisionsOverLimit([]*appsv1.ControllerRevision{newRevision("ds", 1)}, 0)).To(BeEmpty())
+		pruned := revisionsOverLimit([]*appsv1.ControllerRevision{
+			newRevision("ds", 3), newRevision("ds", 1), newRevision("ds", 4), newRevision("ds", 2)}, 1)
+		Expect(pruned).To(HaveLen(2))
+		Expect(pruned[0].Revision).To(Equal(int64(1)))
+		Expect(pruned[1].Revision).To(Equal(int64(2)))
+	})
+
+	It("should delete revisions over the limit and revisions of removed DaemonSets", func() {
+		historyLimit := int32(1)
+		objects := []client.Object{
+			&appsv1.DaemonSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "mofed-ds", Namespace: namespace},
+				Spec:       appsv1.DaemonSetSpec{RevisionHistoryLimit: &historyLimit},
+			},
+			newRevision("mofed-ds", 1), newRevision("mofed-ds", 2), newRevision("mofed-ds", 3),
+			newRevision("old-mofed-ds", 1),
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).Build()
+		historyLimitPruned := testutil.ToFloat64(prunedControllerRevisionsCounter.WithLabelValues("historyLimit"))
+		orphanedPruned := testutil.ToFloat64(prunedControllerRevisionsCounter.WithLabelValues("orphaned"))
+
+		collector := &ControllerRevisionsCollector{Client: fakeClient, Namespace: namespace, Log: ctrl.Log}
+		collector.collect(context.TODO())
+
+		revisions := &appsv1.ControllerRevisionList{}
+		Expect(fakeClient.List(context.TODO(), revisions)).To(Succeed())
+		var names []string
+		for _, revision := range revisions.Items {
+			names = append(names, revision.Name)
+		}
+		Expect(names).To(ConsistOf("mofed-ds-2", "mofed-ds-3"))
+		Expect(testutil.ToFloat64(prunedControllerRevisionsCounter.WithLabelValues("historyLimit"))).
+			To(Equal(historyLimitPruned + 1))
+		Expect(testutil.ToFloat64(prunedControllerRevisionsCounter.WithLabelValues("orphaned"))).
+			To(Equal(orphanedPruned + 1))
+	})
+	It("should not count revisions which are already deleted", func() {
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+		orphanedPruned := testutil.ToFloat64(prunedControllerRevisionsCounter.WithLabelValues("orphaned"))
+
+		collector := &ControllerRevisionsCollector{Client: fakeClient, Namespace: namespace, Log: ctrl.Log}
+		collector.prune(context.TODO(), []*appsv1.ControllerRevision{newRevision("old-mofed-ds", 1)}, "orphaned")
+		Expect(testutil.ToFloat64(prunedControllerRevisionsCounter.WithLabelValues("orphaned"))).
+			To(Equal(orphanedPruned))
+	})
+})
@@ -0,0 +1,80 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	appsv1 "k8s.io/api/apps/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/state"
+)
+
+// daemonSetComponentLabel is the label of the DaemonSets created by the operator which names the component
+const daemonSetComponentLabel = "app"
+
+var (
+	daemonSetDesiredGauge = newDaemonSetGauge("network_operator_daemonset_desired_number_scheduled",
+		"Number of nodes which should run the pod of the DaemonSet deployed by the operator")
+	daemonSetReadyGauge = newDaemonSetGauge("network_operator_daemonset_number_ready",
+		"Number of nodes which run a ready pod of the DaemonSet deployed by the operator")
+	daemonSetUpdatedGauge = newDaemonSetGauge("network_operator_daemonset_updated_number_scheduled",
+		"Number of nodes which run the updated pod of the DaemonSet deployed by the operator")
+	daemonSetUnavailableGauge = newDaemonSetGauge("network_operator_daemonset_number_unavailable",
+		"Number of nodes which should run the pod of the DaemonSet deployed by the operator "+
+			"but have no available pod")
+)
+
+func newDaemonSetGauge(name, help string) *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, []string{"component", "namespace"})
+}
+
+func init() {
+	metrics.Registry.MustRegister(daemonSetDesiredGauge, daemonSetReadyGauge, daemonSetUpdatedGauge,
+		daemonSetUnavailableGauge)
+}
+
+// updateDaemonSetMetrics reports the rollout status of the DaemonSets deployed by the operator, labeled by the
+// component of the DaemonSet, metrics of the DaemonSets which no longer exist are removed
+func (r *NicClusterPolicyReconciler) updateDaemonSetMetrics(ctx context.Context) {
+	dsList := &appsv1.DaemonSetList{}
+	err := r.List(ctx, dsList, client.MatchingLabels(state.ManagedLabels()))
+	if err != nil {
+		r.Log.V(consts.LogLevelWarning).Info("Failed to list DaemonSets for metrics", "error:", err)
+		return
+	}
+
+	daemonSetDesiredGauge.Reset()
+	daemonSetReadyGauge.Reset()
+	daemonSetUpdatedGauge.Reset()
+	daemonSetUnavailableGauge.Reset()
+	for i := range dsList.Items {
+		ds := &dsList.Items[i]
+		component := ds.Labels[daemonSetComponentLabel]
+		if component == "" {
+			component = ds.Name
+		}
+		daemonSetDesiredGauge.WithLabelValues(component, ds.Namespace).Set(float64(ds.Status.DesiredNumberScheduled))
+		daemonSetReadyGauge.WithLabelValues(component, ds.Namespace).Set(float64(ds.Status.NumberReady))
+		daemonSetUpdatedGauge.WithLabelValues(component, ds.Namespace).Set(float64(ds.Status.UpdatedNumberScheduled))
+		daemonSetUnavailableGauge.WithLabelValues(component, ds.Namespace).Set(float64(ds.Status.NumberUnavailable))
+	}
+}
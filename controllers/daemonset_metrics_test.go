@@ -0,0 +1,64 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/Mellanox/network-operator/pkg/state"
+)
+
+var _ = Describe("DaemonSet metrics", func() {
+	It("should report the status of the DaemonSets deployed by the operator", func() {
+		newDaemonSet := func(namespace, name string, labels map[string]string,
+			status appsv1.DaemonSetStatus) *appsv1.DaemonSet {
+			return &appsv1.DaemonSet{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels},
+				Status:     status,
+			}
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+			newDaemonSet("driver", "mofed-ubuntu20.04-ds",
+				state.MergeManagedLabels(map[string]string{"app": "mofed-ubuntu20.04"}),
+				appsv1.DaemonSetStatus{DesiredNumberScheduled: 3, NumberReady: 2, UpdatedNumberScheduled: 1,
+					NumberUnavailable: 1}),
+			newDaemonSet("operator", "rdma-shared-dp-ds", state.ManagedLabels(),
+				appsv1.DaemonSetStatus{DesiredNumberScheduled: 2, NumberReady: 2, UpdatedNumberScheduled: 2}),
+			newDaemonSet("operator", "unmanaged-ds", map[string]string{"app": "unmanaged"},
+				appsv1.DaemonSetStatus{DesiredNumberScheduled: 1}),
+		).Build()
+		reconciler := &NicClusterPolicyReconciler{Client: fakeClient, Log: ctrl.Log}
+		reconciler.updateDaemonSetMetrics(context.TODO())
+
+		Expect(testutil.ToFloat64(daemonSetDesiredGauge.WithLabelValues("mofed-ubuntu20.04", "driver"))).To(Equal(3.0))
+		Expect(testutil.ToFloat64(daemonSetReadyGauge.WithLabelValues("mofed-ubuntu20.04", "driver"))).To(Equal(2.0))
+		Expect(testutil.ToFloat64(daemonSetUpdatedGauge.WithLabelValues("mofed-ubuntu20.04", "driver"))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(daemonSetUnavailableGauge.WithLabelValues("mofed-ubuntu20.04", "driver"))).
+			To(Equal(1.0))
+		Expect(testutil.ToFloat64(daemonSetReadyGauge.WithLabelValues("rdma-shared-dp-ds", "operator"))).To(Equal(2.0))
+		Expect(testutil.CollectAndCount(daemonSetDesiredGauge)).To(Equal(2))
+	})
+})
@@ -0,0 +1,220 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/config"
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+	"github.com/Mellanox/network-operator/pkg/state"
+)
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=list;delete
+
+// maxResourceDriftRestarts is the number of device plugin pod restarts after which the node is not repaired anymore,
+// e.g. the node legitimately advertises zero resources
+const maxResourceDriftRestarts = 3
+
+// repairDevicePluginResources restarts the device plugin pod on the Ready nodes with a ready OFED driver which
+// advertise zero of an allocatable resource of the device plugin for longer than ResourceDriftRestartSeconds,
+// e.g. after a kubelet restart the resources are not advertised until the device plugin registers again.
+// The time a node was first seen without the resources is kept in memory and is reset when the pod is restarted,
+// so the pod is restarted at most once per period. The period is doubled after each restart, the restarts are
+// counted in the ResourceDriftRestartsAnnotationPrefix annotation of the node and stop after
+// maxResourceDriftRestarts, the annotation is removed once the node advertises the resources.
+// The time until the next check is due is returned, zero if no node is waiting for a restart
+func (r *NicClusterPolicyReconciler) repairDevicePluginResources(
+	ctx context.Context, cr *mellanoxv1alpha1.NicClusterPolicy) time.Duration {
+	if r.zeroResourcesSince == nil {
+		r.zeroResourcesSince = make(map[string]time.Time)
+	}
+	plugins, err := state.DevicePlugins(cr)
+	if err != nil {
+		r.Log.V(consts.LogLevelWarning).Info("Failed to get device plugin resources", "error:", err)
+		return 0
+	}
+
+	var nodes *corev1.NodeList
+	seen := make(map[string]bool)
+	var requeue time.Duration
+	now := time.Now()
+	for _, plugin := range plugins {
+		if plugin.ResourceDriftRestartSeconds <= 0 || len(plugin.ResourceNames) == 0 {
+			continue
+		}
+		if nodes == nil {
+			nodes = &corev1.NodeList{}
+			if err := r.List(ctx, nodes, client.MatchingLabels{nodeinfo.NodeLabelMlnxNIC: "true"}); err != nil {
+				r.Log.V(consts.LogLevelWarning).Info("Failed to list nodes", "error:", err)
+				return 0
+			}
+		}
+		threshold := time.Duration(plugin.ResourceDriftRestartSeconds) * time.Second
+		for i := range nodes.Items {
+			node := &nodes.Items[i]
+			zero := zeroAllocatableResources(node, plugin.ResourceNames)
+			restarts := resourceDriftRestarts(node, plugin.DaemonSet)
+			if len(zero) == 0 {
+				if restarts > 0 {
+					r.setResourceDriftRestarts(ctx, node, plugin.DaemonSet, 0)
+				}
+				continue
+			}
+			if !isResourceDriftCheckedNode(node) || restarts >= maxResourceDriftRestarts {
+				continue
+			}
+			key := node.Name + "/" + plugin.DaemonSet
+			seen[key] = true
+			since, ok := r.zeroResourcesSince[key]
+			if !ok {
+				r.Log.V(consts.LogLevelInfo).Info("Node advertises zero device plugin resources",
+					"node", node.Name, "resources", zero)
+				since = now
+				r.zeroResourcesSince[key] = since
+			}
+			period := threshold << restarts
+			remaining := period - now.Sub(since)
+			if remaining <= 0 {
+				r.Log.V(consts.LogLevelWarning).Info(
+					"Node advertises zero device plugin resources for too long, restarting the device plugin pod",
+					"node", node.Name, "daemonSet", plugin.DaemonSet, "resources", zero, "restarts", restarts+1)
+				r.restartDevicePluginPod(ctx, plugin.DaemonSet, node.Name)
+				r.setResourceDriftRestarts(ctx, node, plugin.DaemonSet, restarts+1)
+				if restarts+1 >= maxResourceDriftRestarts {
+					r.Log.V(consts.LogLevelWarning).Info(
+						"Device plugin pod restarted too many times, the node is not repaired anymore",
+						"node", node.Name, "daemonSet", plugin.DaemonSet)
+					continue
+				}
+				r.zeroResourcesSince[key] = now
+				remaining = period * 2
+			}
+			if requeue == 0 || remaining < requeue {
+				requeue = remaining
+			}
+		}
+	}
+	for key := range r.zeroResourcesSince {
+		if !seen[key] {
+			delete(r.zeroResourcesSince, key)
+		}
+	}
+	return requeue
+}
+
+// resourceDriftRestarts returns the number of restarts of the device plugin pod on the node recorded in the
+// ResourceDriftRestartsAnnotationPrefix annotation, zero if the annotation is missing or invalid
+func resourceDriftRestarts(node *corev1.Node, daemonSet string) int {
+	restarts, err := strconv.Atoi(node.Annotations[consts.ResourceDriftRestartsAnnotationPrefix+daemonSet])
+	if err != nil || restarts < 0 {
+		return 0
+	}
+	return restarts
+}
+
+// setResourceDriftRestarts records the number of restarts of the device plugin pod in the annotation of the node,
+// the annotation is removed if the number is zero. Errors are logged, the restarts are recorded again on the next check
+func (r *NicClusterPolicyReconciler) setResourceDriftRestarts(
+	ctx context.Context, node *corev1.Node, daemonSet string, restarts int) {
+	patch := client.MergeFrom(node.DeepCopy())
+	key := consts.ResourceDriftRestartsAnnotationPrefix + daemonSet
+	if restarts == 0 {
+		delete(node.Annotations, key)
+	} else {
+		if node.Annotations == nil {
+			node.Annotations = make(map[string]string)
+		}
+		node.Annotations[key] = strconv.Itoa(restarts)
+	}
+	if err := r.Patch(ctx, node, patch); err != nil {
+		r.Log.V(consts.LogLevelWarning).Info("Failed to record device plugin restarts on the node",
+			"node", node.Name, "error:", err)
+	}
+}
+
+// nodeAllocatableChanged passes node update events which change the allocatable resources of the node,
+// e.g. a device plugin resource drops to zero after a kubelet restart
+var nodeAllocatableChanged = predicate.Funcs{
+	CreateFunc: func(e event.CreateEvent) bool { return false },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldNode, oldOk := e.ObjectOld.(*corev1.Node)
+		newNode, newOk := e.ObjectNew.(*corev1.Node)
+		return oldOk && newOk && !equality.Semantic.DeepEqual(oldNode.Status.Allocatable, newNode.Status.Allocatable)
+	},
+	DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+	GenericFunc: func(e event.GenericEvent) bool { return false },
+}
+
+// isResourceDriftCheckedNode returns true if the node is Ready, the OFED driver is ready on the node, and the node is
+// not excluded from the management of the operator
+func isResourceDriftCheckedNode(node *corev1.Node) bool {
+	ready := findNodeCondition(node, corev1.NodeReady)
+	return ready != nil && ready.Status == corev1.ConditionTrue &&
+		node.Labels[nodeinfo.NodeLabelWaitOFED] == "false" && !nodeinfo.IsNodeExcluded(node)
+}
+
+// zeroAllocatableResources returns the resources which are listed in the allocatable resources of the node
+// with zero quantity
+func zeroAllocatableResources(node *corev1.Node, resourceNames []string) []string {
+	var zero []string
+	for _, name := range resourceNames {
+		quantity, ok := node.Status.Allocatable[corev1.ResourceName(name)]
+		if ok && quantity.IsZero() {
+			zero = append(zero, name)
+		}
+	}
+	return zero
+}
+
+// restartDevicePluginPod deletes the pod of the device plugin DaemonSet on the node, the DaemonSet creates a new pod
+// which registers the resources with the kubelet. Errors are logged, the pod is restarted again after the next period
+func (r *NicClusterPolicyReconciler) restartDevicePluginPod(ctx context.Context, daemonSet, nodeName string) {
+	pods := &corev1.PodList{}
+	err := r.List(ctx, pods, client.InNamespace(config.FromEnv().State.NetworkOperatorResourceNamespace))
+	if err != nil {
+		r.Log.V(consts.LogLevelWarning).Info("Failed to list device plugin pods", "error:", err)
+		return
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Spec.NodeName != nodeName || pod.DeletionTimestamp != nil || !isOwnedByDaemonSet(pod, daemonSet) {
+			continue
+		}
+		if err := r.Delete(ctx, pod); client.IgnoreNotFound(err) != nil {
+			r.Log.V(consts.LogLevelWarning).Info("Failed to restart device plugin pod",
+				"pod", pod.Name, "error:", err)
+		}
+	}
+}
+
+// isOwnedByDaemonSet returns true if the pod is controlled by the DaemonSet with the name
+func isOwnedByDaemonSet(pod *corev1.Pod, daemonSet string) bool {
+	controller := metav1.GetControllerOf(pod)
+	return controller != nil && controller.Kind == "DaemonSet" && controller.Name == daemonSet
+}
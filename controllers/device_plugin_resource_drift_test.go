@@ -0,0 +1,151 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/config"
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+)
+
+var _ = Describe("Device plugin resource drift", func() {
+	const resourceName = "rdma/rdma_shared_device_a"
+	newNode := func(name, quantity, driverWait string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{
+				nodeinfo.NodeLabelMlnxNIC: "true", nodeinfo.NodeLabelWaitOFED: driverWait}},
+			Status: corev1.NodeStatus{
+				Allocatable: corev1.ResourceList{resourceName: resource.MustParse(quantity)},
+				Conditions:  []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+			},
+		}
+	}
+	newPluginPod := func(nodeName string) *corev1.Pod {
+		controller := true
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "rdma-shared-dp-" + nodeName,
+				Namespace: config.FromEnv().State.NetworkOperatorResourceNamespace,
+				OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "DaemonSet",
+					Name: "rdma-shared-dp-ds", UID: "ds-uid", Controller: &controller}},
+			},
+			Spec: corev1.PodSpec{NodeName: nodeName},
+		}
+	}
+	podExists := func(c client.Client, nodeName string) bool {
+		err := c.Get(context.TODO(), types.NamespacedName{
+			Namespace: config.FromEnv().State.NetworkOperatorResourceNamespace, Name: "rdma-shared-dp-" + nodeName},
+			&corev1.Pod{})
+		if apiErrors.IsNotFound(err) {
+			return false
+		}
+		Expect(err).NotTo(HaveOccurred())
+		return true
+	}
+	newPolicy := func(restartSeconds int) *mellanoxv1alpha1.NicClusterPolicy {
+		cr := &mellanoxv1alpha1.NicClusterPolicy{}
+		cr.Spec.RdmaSharedDevicePlugin = &mellanoxv1alpha1.RdmaSharedDevicePluginSpec{
+			ResourcePools:               []mellanoxv1alpha1.RdmaSharedDevicePoolSpec{{Name: "rdma_shared_device_a"}},
+			ResourceDriftRestartSeconds: restartSeconds,
+		}
+		return cr
+	}
+
+	It("should restart the device plugin pod once the node advertises zero resources for too long", func() {
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+			newNode("drifted", "0", "false"), newNode("healthy", "1k", "false"), newNode("driver-wait", "0", "true"),
+			newPluginPod("drifted"), newPluginPod("healthy"), newPluginPod("driver-wait")).Build()
+		reconciler := &NicClusterPolicyReconciler{Client: fakeClient, Log: ctrl.Log}
+		cr := newPolicy(60)
+
+		requeue := reconciler.repairDevicePluginResources(context.TODO(), cr)
+		Expect(requeue).To(BeNumerically("~", time.Minute, time.Second))
+		Expect(reconciler.zeroResourcesSince).To(HaveLen(1))
+		Expect(podExists(fakeClient, "drifted")).To(BeTrue())
+
+		reconciler.zeroResourcesSince["drifted/rdma-shared-dp-ds"] = time.Now().Add(-2 * time.Minute)
+		requeue = reconciler.repairDevicePluginResources(context.TODO(), cr)
+		// the restarted device plugin is given a doubled period to register the resources
+		Expect(requeue).To(Equal(2 * time.Minute))
+		Expect(podExists(fakeClient, "drifted")).To(BeFalse())
+		Expect(podExists(fakeClient, "healthy")).To(BeTrue())
+		Expect(podExists(fakeClient, "driver-wait")).To(BeTrue())
+		Expect(time.Since(reconciler.zeroResourcesSince["drifted/rdma-shared-dp-ds"])).To(BeNumerically("<", time.Second))
+		node := &corev1.Node{}
+		Expect(fakeClient.Get(context.TODO(), types.NamespacedName{Name: "drifted"}, node)).To(Succeed())
+		Expect(node.Annotations).To(HaveKeyWithValue(consts.ResourceDriftRestartsAnnotationPrefix+"rdma-shared-dp-ds", "1"))
+	})
+
+	It("should stop restarting the device plugin pod after the maximum number of restarts", func() {
+		drifted := newNode("drifted", "0", "false")
+		drifted.Annotations = map[string]string{
+			consts.ResourceDriftRestartsAnnotationPrefix + "rdma-shared-dp-ds": strconv.Itoa(maxResourceDriftRestarts - 1)}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+			drifted, newPluginPod("drifted")).Build()
+		reconciler := &NicClusterPolicyReconciler{Client: fakeClient, Log: ctrl.Log,
+			zeroResourcesSince: map[string]time.Time{"drifted/rdma-shared-dp-ds": time.Now().Add(-time.Hour)}}
+		cr := newPolicy(60)
+
+		Expect(reconciler.repairDevicePluginResources(context.TODO(), cr)).To(BeZero())
+		Expect(podExists(fakeClient, "drifted")).To(BeFalse())
+		node := &corev1.Node{}
+		Expect(fakeClient.Get(context.TODO(), types.NamespacedName{Name: "drifted"}, node)).To(Succeed())
+		Expect(node.Annotations).To(HaveKeyWithValue(consts.ResourceDriftRestartsAnnotationPrefix+"rdma-shared-dp-ds",
+			strconv.Itoa(maxResourceDriftRestarts)))
+
+		Expect(fakeClient.Create(context.TODO(), newPluginPod("drifted"))).To(Succeed())
+		Expect(reconciler.repairDevicePluginResources(context.TODO(), cr)).To(BeZero())
+		Expect(podExists(fakeClient, "drifted")).To(BeTrue())
+		Expect(reconciler.zeroResourcesSince).To(BeEmpty())
+
+		// the restarts are reset once the node advertises the resources
+		node.Status.Allocatable[resourceName] = resource.MustParse("1k")
+		Expect(fakeClient.Update(context.TODO(), node)).To(Succeed())
+		Expect(reconciler.repairDevicePluginResources(context.TODO(), cr)).To(BeZero())
+		node = &corev1.Node{}
+		Expect(fakeClient.Get(context.TODO(), types.NamespacedName{Name: "drifted"}, node)).To(Succeed())
+		Expect(node.Annotations).NotTo(HaveKey(consts.ResourceDriftRestartsAnnotationPrefix + "rdma-shared-dp-ds"))
+	})
+
+	It("should not check the nodes if disabled and forget the nodes which recovered", func() {
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+			newNode("drifted", "0", "false"), newPluginPod("drifted")).Build()
+		reconciler := &NicClusterPolicyReconciler{Client: fakeClient, Log: ctrl.Log,
+			zeroResourcesSince: map[string]time.Time{"drifted/rdma-shared-dp-ds": time.Now().Add(-time.Hour)}}
+
+		Expect(reconciler.repairDevicePluginResources(context.TODO(), newPolicy(0))).To(BeZero())
+		Expect(reconciler.zeroResourcesSince).To(BeEmpty())
+		Expect(podExists(fakeClient, "drifted")).To(BeTrue())
+	})
+})
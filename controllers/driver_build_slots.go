@@ -0,0 +1,159 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/state"
+	"github.com/Mellanox/network-operator/pkg/upgrade"
+)
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=list;patch
+
+// driverBuildSlotTimeout is the time after which the slot of a driver pod which is not Ready yet is reclaimed
+const driverBuildSlotTimeout = time.Hour
+
+// updateDriverBuildSlots grants consts.OfedBuildSlotAnnotation to the OFED driver pods waiting in the build gate
+// init container, so that at most MaxConcurrentBuilds driver pods build the driver at the same time.
+// The slots of Ready pods are released, the oldest waiting pods are granted the free slots first.
+// The slots of pods which failed to build the driver are reclaimed, see buildSlotReclaimReason, the annotation
+// of such pods is set to consts.OfedBuildSlotReclaimed so that they don't wait for a slot again.
+// All waiting pods are granted a slot if the limit was removed, e.g. pods created before the DaemonSet was updated
+func (r *NicClusterPolicyReconciler) updateDriverBuildSlots(
+	ctx context.Context, cr *mellanoxv1alpha1.NicClusterPolicy) {
+	ofedSpec := cr.Spec.OFEDDriver
+	if ofedSpec == nil || !ofedSpec.IsEnabled() {
+		return
+	}
+	pods := &corev1.PodList{}
+	err := r.List(ctx, pods,
+		client.InNamespace(state.OfedDriverNamespace(cr)),
+		client.MatchingLabels{upgrade.OfedDriverLabel: ""})
+	if err != nil {
+		r.Log.V(consts.LogLevelWarning).Info("Failed to list OFED driver pods", "error:", err)
+		return
+	}
+
+	var waiting []*corev1.Pod
+	building := 0
+	now := time.Now()
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !hasBuildGate(pod) || pod.DeletionTimestamp != nil {
+			continue
+		}
+		slot, granted := pod.Annotations[consts.OfedBuildSlotAnnotation]
+		switch {
+		case granted && isDriverPodReady(pod):
+			r.patchBuildSlot(ctx, pod, nil)
+		case slot == consts.OfedBuildSlotReclaimed:
+		case granted:
+			if reason := buildSlotReclaimReason(pod, slot, now); reason != "" {
+				r.Log.V(consts.LogLevelWarning).Info("Reclaiming driver build slot", "pod", pod.Name,
+					"node", pod.Spec.NodeName, "reason", reason)
+				reclaimed := consts.OfedBuildSlotReclaimed
+				r.patchBuildSlot(ctx, pod, &reclaimed)
+				continue
+			}
+			building++
+		case !isDriverPodReady(pod):
+			waiting = append(waiting, pod)
+		}
+	}
+
+	sort.Slice(waiting, func(i, j int) bool {
+		if !waiting[i].CreationTimestamp.Equal(&waiting[j].CreationTimestamp) {
+			return waiting[i].CreationTimestamp.Before(&waiting[j].CreationTimestamp)
+		}
+		return waiting[i].Name < waiting[j].Name
+	})
+	free := len(waiting)
+	if ofedSpec.MaxConcurrentBuilds > 0 && ofedSpec.MaxConcurrentBuilds-building < free {
+		free = ofedSpec.MaxConcurrentBuilds - building
+	}
+	grantedAt := now.UTC().Format(time.RFC3339)
+	for i := 0; i < free; i++ {
+		r.Log.V(consts.LogLevelInfo).Info("Granting driver build slot", "pod", waiting[i].Name,
+			"node", waiting[i].Spec.NodeName, "building", building+i, "limit", ofedSpec.MaxConcurrentBuilds)
+		r.patchBuildSlot(ctx, waiting[i], &grantedAt)
+	}
+	if len(waiting) > free {
+		r.Log.V(consts.LogLevelDebug).Info("Driver pods wait for a build slot", "waiting", len(waiting)-free)
+	}
+}
+
+// buildSlotReclaimReason returns why the build slot granted to the pod at the given time is reclaimed, empty if
+// the pod keeps the slot. The slot is reclaimed if the pod has failed, if its driver container has terminated since
+// the grant, e.g. a failed build in CrashLoopBackOff, or if the pod is not Ready within driverBuildSlotTimeout
+func buildSlotReclaimReason(pod *corev1.Pod, slot string, now time.Time) string {
+	if pod.Status.Phase == corev1.PodFailed {
+		return "driver pod has failed"
+	}
+	grantedAt, err := time.Parse(time.RFC3339, slot)
+	if err != nil {
+		// the slot was granted before the grant time was recorded, it is kept until the pod is Ready
+		return ""
+	}
+	for i := range pod.Status.ContainerStatuses {
+		status := &pod.Status.ContainerStatuses[i]
+		terminated := status.LastTerminationState.Terminated
+		if status.Name == upgrade.OfedDriverContainerName && terminated != nil &&
+			!terminated.FinishedAt.Time.Before(grantedAt) {
+			return "driver container has terminated since the build slot was granted"
+		}
+	}
+	if now.Sub(grantedAt) > driverBuildSlotTimeout {
+		return "driver pod is not Ready within " + driverBuildSlotTimeout.String()
+	}
+	return ""
+}
+
+// hasBuildGate returns true if the pod waits for consts.OfedBuildSlotAnnotation in the build gate init container
+func hasBuildGate(pod *corev1.Pod) bool {
+	for i := range pod.Spec.InitContainers {
+		if pod.Spec.InitContainers[i].Name == consts.OfedBuildGateContainerName {
+			return true
+		}
+	}
+	return false
+}
+
+// patchBuildSlot sets consts.OfedBuildSlotAnnotation of the pod to the value, the annotation is removed if the value
+// is nil. Errors are logged, the slots are updated again in the next reconcile
+func (r *NicClusterPolicyReconciler) patchBuildSlot(ctx context.Context, pod *corev1.Pod, value *string) {
+	patch := client.MergeFrom(pod.DeepCopy())
+	if value == nil {
+		delete(pod.Annotations, consts.OfedBuildSlotAnnotation)
+	} else {
+		if pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		pod.Annotations[consts.OfedBuildSlotAnnotation] = *value
+	}
+	if err := r.Patch(ctx, pod, patch); err != nil {
+		r.Log.V(consts.LogLevelWarning).Info("Failed to update driver build slot of the pod",
+			"pod", pod.Name, "error:", err)
+	}
+}
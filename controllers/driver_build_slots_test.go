@@ -0,0 +1,153 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/config"
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/upgrade"
+)
+
+var _ = Describe("Driver build slots", func() {
+	created := time.Now()
+	newDriverPod := func(name string, age time.Duration, gate, granted bool, ready corev1.ConditionStatus) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         config.FromEnv().State.NetworkOperatorResourceNamespace,
+				Labels:            map[string]string{upgrade.OfedDriverLabel: ""},
+				CreationTimestamp: metav1.NewTime(created.Add(-age)),
+			},
+			Status: corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: ready}}},
+		}
+		if gate {
+			pod.Spec.InitContainers = []corev1.Container{{Name: consts.OfedBuildGateContainerName}}
+		}
+		if granted {
+			pod.Annotations = map[string]string{consts.OfedBuildSlotAnnotation: created.UTC().Format(time.RFC3339)}
+		}
+		return pod
+	}
+	hasSlot := func(c client.Client, name string) bool {
+		pod := &corev1.Pod{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{
+			Namespace: config.FromEnv().State.NetworkOperatorResourceNamespace, Name: name}, pod)).To(Succeed())
+		_, ok := pod.Annotations[consts.OfedBuildSlotAnnotation]
+		return ok
+	}
+	buildSlot := func(c client.Client, name string) string {
+		pod := &corev1.Pod{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{
+			Namespace: config.FromEnv().State.NetworkOperatorResourceNamespace, Name: name}, pod)).To(Succeed())
+		return pod.Annotations[consts.OfedBuildSlotAnnotation]
+	}
+
+	It("should limit the driver pods which build at the same time", func() {
+		objects := []client.Object{
+			newDriverPod("done", 5*time.Hour, true, true, corev1.ConditionTrue),
+			newDriverPod("building", 4*time.Hour, true, true, corev1.ConditionFalse),
+			newDriverPod("waiting-oldest", 3*time.Hour, true, false, corev1.ConditionFalse),
+			newDriverPod("waiting", 2*time.Hour, true, false, corev1.ConditionFalse),
+			newDriverPod("waiting-newest", time.Hour, true, false, corev1.ConditionFalse),
+			newDriverPod("no-gate", 6*time.Hour, false, false, corev1.ConditionFalse),
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).Build()
+		reconciler := &NicClusterPolicyReconciler{Client: fakeClient, Log: ctrl.Log}
+		cr := &mellanoxv1alpha1.NicClusterPolicy{}
+		cr.Spec.OFEDDriver = &mellanoxv1alpha1.OFEDDriverSpec{
+			ImageSpec:           mellanoxv1alpha1.ImageSpec{Image: "mofed", Repository: "nvcr.io", Version: "5.7"},
+			MaxConcurrentBuilds: 2,
+		}
+
+		reconciler.updateDriverBuildSlots(context.TODO(), cr)
+		Expect(hasSlot(fakeClient, "done")).To(BeFalse())
+		Expect(hasSlot(fakeClient, "building")).To(BeTrue())
+		Expect(hasSlot(fakeClient, "waiting-oldest")).To(BeTrue())
+		Expect(hasSlot(fakeClient, "waiting")).To(BeFalse())
+		Expect(hasSlot(fakeClient, "waiting-newest")).To(BeFalse())
+		Expect(hasSlot(fakeClient, "no-gate")).To(BeFalse())
+
+		// the limit is reached until a building pod is Ready
+		reconciler.updateDriverBuildSlots(context.TODO(), cr)
+		Expect(hasSlot(fakeClient, "waiting")).To(BeFalse())
+
+		cr.Spec.OFEDDriver.MaxConcurrentBuilds = 0
+		reconciler.updateDriverBuildSlots(context.TODO(), cr)
+		Expect(hasSlot(fakeClient, "waiting")).To(BeTrue())
+		Expect(hasSlot(fakeClient, "waiting-newest")).To(BeTrue())
+	})
+
+	It("should reclaim the slots of the driver pods which fail to build", func() {
+		terminated := func(pod *corev1.Pod, at time.Time) *corev1.Pod {
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+				Name:         upgrade.OfedDriverContainerName,
+				RestartCount: 1,
+				LastTerminationState: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, FinishedAt: metav1.NewTime(at)}},
+			}}
+			return pod
+		}
+		failed := newDriverPod("failed", 5*time.Hour, true, true, corev1.ConditionFalse)
+		failed.Status.Phase = corev1.PodFailed
+		timedOut := newDriverPod("timed-out", 5*time.Hour, true, true, corev1.ConditionFalse)
+		timedOut.Annotations[consts.OfedBuildSlotAnnotation] =
+			created.Add(-2 * driverBuildSlotTimeout).UTC().Format(time.RFC3339)
+		objects := []client.Object{
+			failed,
+			timedOut,
+			terminated(newDriverPod("crashing", 5*time.Hour, true, true, corev1.ConditionFalse), created.Add(time.Minute)),
+			// the driver container of the pod terminated before the pod restarted with its node
+			terminated(newDriverPod("building", 4*time.Hour, true, true, corev1.ConditionFalse), created.Add(-time.Hour)),
+			newDriverPod("waiting", 3*time.Hour, true, false, corev1.ConditionFalse),
+			newDriverPod("waiting-newest", 2*time.Hour, true, false, corev1.ConditionFalse),
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).Build()
+		reconciler := &NicClusterPolicyReconciler{Client: fakeClient, Log: ctrl.Log}
+		cr := &mellanoxv1alpha1.NicClusterPolicy{}
+		cr.Spec.OFEDDriver = &mellanoxv1alpha1.OFEDDriverSpec{
+			ImageSpec:           mellanoxv1alpha1.ImageSpec{Image: "mofed", Repository: "nvcr.io", Version: "5.7"},
+			MaxConcurrentBuilds: 2,
+		}
+
+		reconciler.updateDriverBuildSlots(context.TODO(), cr)
+		Expect(buildSlot(fakeClient, "failed")).To(Equal(consts.OfedBuildSlotReclaimed))
+		Expect(buildSlot(fakeClient, "timed-out")).To(Equal(consts.OfedBuildSlotReclaimed))
+		Expect(buildSlot(fakeClient, "crashing")).To(Equal(consts.OfedBuildSlotReclaimed))
+		Expect(buildSlot(fakeClient, "building")).NotTo(Equal(consts.OfedBuildSlotReclaimed))
+		Expect(hasSlot(fakeClient, "waiting")).To(BeTrue())
+		Expect(hasSlot(fakeClient, "waiting-newest")).To(BeFalse())
+
+		// the reclaimed pods don't take the slots again
+		reconciler.updateDriverBuildSlots(context.TODO(), cr)
+		Expect(buildSlot(fakeClient, "crashing")).To(Equal(consts.OfedBuildSlotReclaimed))
+		Expect(hasSlot(fakeClient, "waiting-newest")).To(BeFalse())
+	})
+})
@@ -0,0 +1,178 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+	"github.com/Mellanox/network-operator/pkg/state"
+	"github.com/Mellanox/network-operator/pkg/upgrade"
+)
+
+// +kubebuilder:rbac:groups="",resources=nodes/status,verbs=patch
+
+// updateDriverReadyNodeConditions sets consts.DriverReadyNodeCondition on the nodes with Mellanox NICs
+// according to the readiness of the OFED driver pod on the node, the condition is removed from the nodes
+// if it is disabled in the OFED driver spec. The nodes are patched only when the condition changes
+func (r *NicClusterPolicyReconciler) updateDriverReadyNodeConditions(
+	ctx context.Context, cr *mellanoxv1alpha1.NicClusterPolicy) {
+	ofedSpec := cr.Spec.OFEDDriver
+	enabled := ofedSpec != nil && ofedSpec.IsEnabled() && ofedSpec.DriverReadyNodeCondition
+
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes, client.MatchingLabels{nodeinfo.NodeLabelMlnxNIC: "true"}); err != nil {
+		r.Log.V(consts.LogLevelWarning).Info("Failed to list nodes", "error:", err)
+		return
+	}
+
+	driverPods := map[string]*corev1.Pod{}
+	if enabled {
+		pods := &corev1.PodList{}
+		err := r.List(ctx, pods,
+			client.InNamespace(state.OfedDriverNamespace(cr)),
+			client.MatchingLabels{upgrade.OfedDriverLabel: ""})
+		if err != nil {
+			// keep the current conditions if the driver pods can't be listed
+			r.Log.V(consts.LogLevelWarning).Info("Failed to list OFED driver pods", "error:", err)
+			return
+		}
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			// a Ready pod wins over a terminating or starting pod on the same node, e.g. during a restart
+			if current, ok := driverPods[pod.Spec.NodeName]; !ok || !isDriverPodReady(current) {
+				driverPods[pod.Spec.NodeName] = pod
+			}
+		}
+	}
+
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if nodeinfo.IsNodeExcluded(node) {
+			continue
+		}
+		var patch map[string]interface{}
+		if enabled {
+			patch = driverReadyConditionPatch(node, driverPods[node.Name])
+		} else {
+			patch = removeNodeConditionPatch(node, consts.DriverReadyNodeCondition)
+		}
+		r.patchNodeStatus(ctx, node, patch)
+	}
+}
+
+// patchNodeStatus applies the strategic merge patch to the node status, nothing is done if the patch is nil.
+// Errors are logged, the conditions are updated again in the next reconcile
+func (r *NicClusterPolicyReconciler) patchNodeStatus(
+	ctx context.Context, node *corev1.Node, patch map[string]interface{}) {
+	if patch == nil {
+		return
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		r.Log.V(consts.LogLevelWarning).Info("Failed to build node condition patch", "error:", err)
+		return
+	}
+	err = r.Status().Patch(ctx, node, client.RawPatch(types.StrategicMergePatchType, data))
+	if err != nil {
+		r.Log.V(consts.LogLevelWarning).Info("Failed to update condition of the node",
+			"node", node.Name, "error:", err)
+	}
+}
+
+// driverReadyConditionPatch returns the status patch which sets the driver ready condition of the node
+// for the given OFED driver pod, nil is returned if the condition is up to date
+func driverReadyConditionPatch(node *corev1.Node, pod *corev1.Pod) map[string]interface{} {
+	condition := corev1.NodeCondition{
+		Type:    consts.DriverReadyNodeCondition,
+		Status:  corev1.ConditionFalse,
+		Reason:  "DriverPodMissing",
+		Message: "OFED driver pod is not running on the node",
+	}
+	switch {
+	case pod != nil && isDriverPodReady(pod):
+		condition.Status = corev1.ConditionTrue
+		condition.Reason = "DriverReady"
+		condition.Message = fmt.Sprintf("OFED driver pod %s is Ready", pod.Name)
+	case pod != nil:
+		condition.Reason = "DriverNotReady"
+		condition.Message = fmt.Sprintf("OFED driver pod %s is not Ready", pod.Name)
+	}
+
+	return nodeConditionPatch(node, condition)
+}
+
+// nodeConditionPatch returns the status patch which sets the condition on the node, nil is returned if the
+// condition is up to date. The transition time is kept while the status of the condition doesn't change
+func nodeConditionPatch(node *corev1.Node, condition corev1.NodeCondition) map[string]interface{} {
+	now := metav1.NewTime(time.Now())
+	condition.LastHeartbeatTime = now
+	condition.LastTransitionTime = now
+	if current := findNodeCondition(node, condition.Type); current != nil {
+		if current.Status == condition.Status && current.Reason == condition.Reason &&
+			current.Message == condition.Message {
+			return nil
+		}
+		if current.Status == condition.Status {
+			condition.LastTransitionTime = current.LastTransitionTime
+		}
+	}
+	return map[string]interface{}{"status": map[string]interface{}{"conditions": []interface{}{condition}}}
+}
+
+// removeNodeConditionPatch returns the status patch which removes the condition from the node,
+// nil is returned if the condition is not set
+func removeNodeConditionPatch(node *corev1.Node, conditionType corev1.NodeConditionType) map[string]interface{} {
+	if findNodeCondition(node, conditionType) == nil {
+		return nil
+	}
+	return map[string]interface{}{"status": map[string]interface{}{"conditions": []interface{}{
+		map[string]interface{}{"type": conditionType, "$patch": "delete"}}}}
+}
+
+// findNodeCondition returns the condition of the given type of the node, nil if it is not set
+func findNodeCondition(node *corev1.Node, conditionType corev1.NodeConditionType) *corev1.NodeCondition {
+	for i := range node.Status.Conditions {
+		if node.Status.Conditions[i].Type == conditionType {
+			return &node.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// isDriverPodReady returns true if the OFED driver pod is Ready and is not being deleted
+func isDriverPodReady(pod *corev1.Pod) bool {
+	if pod.DeletionTimestamp != nil {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
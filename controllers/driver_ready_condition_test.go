@@ -0,0 +1,107 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/config"
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+	"github.com/Mellanox/network-operator/pkg/upgrade"
+)
+
+var _ = Describe("Driver ready node condition", func() {
+	newNode := func(name string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name: name, Labels: map[string]string{nodeinfo.NodeLabelMlnxNIC: "true"}}}
+	}
+	newDriverPod := func(nodeName string, ready corev1.ConditionStatus) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "mofed-" + nodeName,
+				Namespace: config.FromEnv().State.NetworkOperatorResourceNamespace,
+				Labels:    map[string]string{upgrade.OfedDriverLabel: ""},
+			},
+			Spec:   corev1.PodSpec{NodeName: nodeName},
+			Status: corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: ready}}},
+		}
+	}
+	getCondition := func(c client.Client, nodeName string) *corev1.NodeCondition {
+		node := &corev1.Node{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: nodeName}, node)).To(Succeed())
+		return findNodeCondition(node, consts.DriverReadyNodeCondition)
+	}
+
+	It("should reflect the readiness of the OFED driver pods and be removed when disabled", func() {
+		objects := []client.Object{
+			newNode("ready"), newNode("not-ready"), newNode("no-driver"),
+			newDriverPod("ready", corev1.ConditionTrue), newDriverPod("not-ready", corev1.ConditionFalse),
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).Build()
+		reconciler := &NicClusterPolicyReconciler{Client: fakeClient, Log: ctrl.Log}
+		cr := &mellanoxv1alpha1.NicClusterPolicy{}
+		cr.Spec.OFEDDriver = &mellanoxv1alpha1.OFEDDriverSpec{
+			ImageSpec:                mellanoxv1alpha1.ImageSpec{Image: "mofed", Repository: "nvcr.io", Version: "5.7"},
+			DriverReadyNodeCondition: true,
+		}
+
+		reconciler.updateDriverReadyNodeConditions(context.TODO(), cr)
+		Expect(getCondition(fakeClient, "ready").Status).To(Equal(corev1.ConditionTrue))
+		Expect(getCondition(fakeClient, "not-ready").Reason).To(Equal("DriverNotReady"))
+		Expect(getCondition(fakeClient, "no-driver").Reason).To(Equal("DriverPodMissing"))
+		transitionTime := getCondition(fakeClient, "ready").LastTransitionTime
+
+		// unchanged conditions are not touched
+		reconciler.updateDriverReadyNodeConditions(context.TODO(), cr)
+		Expect(getCondition(fakeClient, "ready").LastTransitionTime).To(Equal(transitionTime))
+
+		cr.Spec.OFEDDriver.DriverReadyNodeCondition = false
+		reconciler.updateDriverReadyNodeConditions(context.TODO(), cr)
+		for _, name := range []string{"ready", "not-ready", "no-driver"} {
+			Expect(getCondition(fakeClient, name)).To(BeNil())
+		}
+	})
+	It("should not set the condition on the nodes excluded from the operator management", func() {
+		savedExcludedNodeLabel := config.FromEnv().State.ExcludedNodeLabel
+		defer func() { config.FromEnv().State.ExcludedNodeLabel = savedExcludedNodeLabel }()
+		config.FromEnv().State.ExcludedNodeLabel = "network.nvidia.com/operator.exclude"
+		excluded := newNode("excluded")
+		excluded.Labels[config.FromEnv().State.ExcludedNodeLabel] = "true"
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(excluded).Build()
+		reconciler := &NicClusterPolicyReconciler{Client: fakeClient, Log: ctrl.Log}
+		cr := &mellanoxv1alpha1.NicClusterPolicy{}
+		cr.Spec.OFEDDriver = &mellanoxv1alpha1.OFEDDriverSpec{
+			ImageSpec:                mellanoxv1alpha1.ImageSpec{Image: "mofed", Repository: "nvcr.io", Version: "5.7"},
+			DriverReadyNodeCondition: true,
+		}
+
+		reconciler.updateDriverReadyNodeConditions(context.TODO(), cr)
+		Expect(getCondition(fakeClient, "excluded")).To(BeNil())
+	})
+})
@@ -0,0 +1,79 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/consts"
+)
+
+// acceptDriverRestartRequest replaces the "true" value of RestartDriverAnnotation with the current time,
+// the OFED state sets this time on the pod template of the driver DaemonSet, so the DaemonSet is rolled out
+// and further requests are possible without removing the annotation.
+// The driver DaemonSet uses the OnDelete update strategy and its pods are only restarted by the upgrade flow,
+// so the request is not accepted while the automatic upgrade is disabled. consts.DriverRestartRejectedCondition
+// is set then and the request is accepted once the automatic upgrade is enabled
+func (r *NicClusterPolicyReconciler) acceptDriverRestartRequest(
+	ctx context.Context, cr *mellanoxv1alpha1.NicClusterPolicy) error {
+	if cr.GetAnnotations()[consts.RestartDriverAnnotation] != "true" {
+		removeDriverRestartRejectedCondition(cr)
+		return nil
+	}
+	if message := driverRestartRejection(cr.Spec.OFEDDriver); message != "" {
+		r.Log.V(consts.LogLevelWarning).Info("Rejected OFED driver restart request", "reason", message)
+		// the status is updated with CR status
+		meta.SetStatusCondition(&cr.Status.Conditions, metav1.Condition{
+			Type:    consts.DriverRestartRejectedCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  "AutoUpgradeDisabled",
+			Message: message,
+		})
+		return nil
+	}
+	acceptedAt := time.Now().UTC().Format(time.RFC3339)
+	cr.Annotations[consts.RestartDriverAnnotation] = acceptedAt
+	if err := r.Update(ctx, cr); err != nil {
+		return err
+	}
+	removeDriverRestartRejectedCondition(cr)
+	r.Log.V(consts.LogLevelInfo).Info("Accepted OFED driver restart request", "acceptedAt", acceptedAt)
+	return nil
+}
+
+// driverRestartRejection returns the reason why the driver restart can't be accepted, empty if it can
+func driverRestartRejection(ofedSpec *mellanoxv1alpha1.OFEDDriverSpec) string {
+	if ofedSpec == nil || !ofedSpec.IsEnabled() {
+		return "OFED driver is not deployed"
+	}
+	if ofedSpec.OfedUpgradePolicy == nil || !ofedSpec.OfedUpgradePolicy.AutoUpgrade {
+		return "OFED driver pods are only restarted by the upgrade flow, enable autoUpgrade in the upgrade policy"
+	}
+	return ""
+}
+
+func removeDriverRestartRejectedCondition(cr *mellanoxv1alpha1.NicClusterPolicy) {
+	if meta.FindStatusCondition(cr.Status.Conditions, consts.DriverRestartRejectedCondition) != nil {
+		// the status is updated with CR status
+		meta.RemoveStatusCondition(&cr.Status.Conditions, consts.DriverRestartRejectedCondition)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/consts"
+)
+
+var _ = Describe("OFED driver restart request", func() {
+	newReconciler := func(autoUpgrade bool) (*NicClusterPolicyReconciler, *mellanoxv1alpha1.NicClusterPolicy) {
+		cr := &mellanoxv1alpha1.NicClusterPolicy{ObjectMeta: metav1.ObjectMeta{
+			Name:        consts.NicClusterPolicyResourceName,
+			Annotations: map[string]string{consts.RestartDriverAnnotation: "true"},
+		}}
+		cr.Spec.OFEDDriver = &mellanoxv1alpha1.OFEDDriverSpec{
+			ImageSpec: mellanoxv1alpha1.ImageSpec{
+				Image: "mofed", Repository: "nvcr.io/mellanox", Version: "5.7-0.1.2.0"},
+			OfedUpgradePolicy: &mellanoxv1alpha1.OfedUpgradePolicySpec{AutoUpgrade: autoUpgrade},
+		}
+		s := scheme.Scheme
+		Expect(mellanoxv1alpha1.AddToScheme(s)).To(Succeed())
+		fakeClient := fake.NewClientBuilder().WithScheme(s).WithObjects(cr).Build()
+		return &NicClusterPolicyReconciler{Client: fakeClient, Log: ctrl.Log}, cr
+	}
+	restartAnnotation := func(reconciler *NicClusterPolicyReconciler) string {
+		updated := &mellanoxv1alpha1.NicClusterPolicy{}
+		Expect(reconciler.Get(context.TODO(), types.NamespacedName{Name: consts.NicClusterPolicyResourceName},
+			updated)).To(Succeed())
+		return updated.Annotations[consts.RestartDriverAnnotation]
+	}
+
+	It("should accept the request if the automatic upgrade is enabled", func() {
+		reconciler, cr := newReconciler(true)
+
+		Expect(reconciler.acceptDriverRestartRequest(context.TODO(), cr)).To(Succeed())
+		acceptedAt, err := time.Parse(time.RFC3339, restartAnnotation(reconciler))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(acceptedAt).To(BeTemporally("~", time.Now(), time.Minute))
+		Expect(meta.FindStatusCondition(cr.Status.Conditions, consts.DriverRestartRejectedCondition)).To(BeNil())
+	})
+
+	It("should reject the request until the automatic upgrade is enabled", func() {
+		reconciler, cr := newReconciler(false)
+
+		Expect(reconciler.acceptDriverRestartRequest(context.TODO(), cr)).To(Succeed())
+		Expect(restartAnnotation(reconciler)).To(Equal("true"))
+		condition := meta.FindStatusCondition(cr.Status.Conditions, consts.DriverRestartRejectedCondition)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Message).To(ContainSubstring("autoUpgrade"))
+
+		cr.Spec.OFEDDriver.OfedUpgradePolicy.AutoUpgrade = true
+		Expect(reconciler.acceptDriverRestartRequest(context.TODO(), cr)).To(Succeed())
+		Expect(restartAnnotation(reconciler)).NotTo(Equal("true"))
+		Expect(meta.FindStatusCondition(cr.Status.Conditions, consts.DriverRestartRejectedCondition)).To(BeNil())
+	})
+
+	It("should remove the rejection once the request is withdrawn", func() {
+		reconciler, cr := newReconciler(false)
+
+		Expect(reconciler.acceptDriverRestartRequest(context.TODO(), cr)).To(Succeed())
+		Expect(meta.FindStatusCondition(cr.Status.Conditions, consts.DriverRestartRejectedCondition)).NotTo(BeNil())
+
+		delete(cr.Annotations, consts.RestartDriverAnnotation)
+		Expect(reconciler.acceptDriverRestartRequest(context.TODO(), cr)).To(Succeed())
+		Expect(meta.FindStatusCondition(cr.Status.Conditions, consts.DriverRestartRejectedCondition)).To(BeNil())
+	})
+})
@@ -0,0 +1,105 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+	"github.com/Mellanox/network-operator/pkg/state"
+	"github.com/Mellanox/network-operator/pkg/upgrade"
+)
+
+// updateDriverVersionNodeLabels sets consts.OfedVersionNodeLabel on the nodes with Mellanox NICs to the version of
+// the Ready OFED driver pod of the node, so that the version skew is visible with `kubectl get nodes -L`.
+// The label is removed from the nodes without a Ready driver pod, e.g. while the driver is restarted by an upgrade,
+// and from all nodes if the OFED driver is not deployed
+func (r *NicClusterPolicyReconciler) updateDriverVersionNodeLabels(
+	ctx context.Context, cr *mellanoxv1alpha1.NicClusterPolicy) {
+	versions := map[string]string{}
+	if cr.Spec.OFEDDriver != nil && cr.Spec.OFEDDriver.IsEnabled() {
+		pods := &corev1.PodList{}
+		err := r.List(ctx, pods,
+			client.InNamespace(state.OfedDriverNamespace(cr)),
+			client.MatchingLabels{upgrade.OfedDriverLabel: ""})
+		if err != nil {
+			// keep the current labels if the driver pods can't be listed
+			r.Log.V(consts.LogLevelWarning).Info("Failed to list OFED driver pods", "error:", err)
+			return
+		}
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if !isDriverPodReady(pod) {
+				continue
+			}
+			if version, ok := driverPodVersion(pod); ok {
+				versions[pod.Spec.NodeName] = version
+			}
+		}
+	}
+	r.setDriverVersionNodeLabels(ctx, versions)
+}
+
+// setDriverVersionNodeLabels sets consts.OfedVersionNodeLabel on the nodes with Mellanox NICs to the versions keyed
+// by node name, the label is removed from the nodes without a version. The nodes are patched only when the label
+// changes
+func (r *NicClusterPolicyReconciler) setDriverVersionNodeLabels(ctx context.Context, versions map[string]string) {
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes, client.MatchingLabels{nodeinfo.NodeLabelMlnxNIC: "true"}); err != nil {
+		r.Log.V(consts.LogLevelWarning).Info("Failed to list nodes", "error:", err)
+		return
+	}
+	for i := range nodes.Items {
+		if nodeinfo.IsNodeExcluded(&nodes.Items[i]) {
+			continue
+		}
+		r.patchDriverVersionLabel(ctx, &nodes.Items[i], versions[nodes.Items[i].Name])
+	}
+}
+
+// patchDriverVersionLabel sets consts.OfedVersionNodeLabel on the node to the version, the label is removed if the
+// version is empty. Errors are logged, the label is updated again in the next reconcile
+func (r *NicClusterPolicyReconciler) patchDriverVersionLabel(ctx context.Context, node *corev1.Node, version string) {
+	current, found := node.Labels[consts.OfedVersionNodeLabel]
+	if current == version && found == (version != "") {
+		return
+	}
+	var value interface{}
+	if version != "" {
+		value = version
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": map[string]interface{}{consts.OfedVersionNodeLabel: value}}})
+	if err != nil {
+		r.Log.V(consts.LogLevelWarning).Info("Failed to build node label patch", "error:", err)
+		return
+	}
+	if err := r.Patch(ctx, node, client.RawPatch(types.MergePatchType, data)); err != nil {
+		r.Log.V(consts.LogLevelWarning).Info("Failed to update OFED driver version label of the node",
+			"node", node.Name, "error:", err)
+		return
+	}
+	r.Log.V(consts.LogLevelDebug).Info("Updated OFED driver version label of the node",
+		"node", node.Name, "version", version)
+}
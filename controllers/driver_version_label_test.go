@@ -0,0 +1,99 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/config"
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+	"github.com/Mellanox/network-operator/pkg/upgrade"
+)
+
+var _ = Describe("Driver version node label", func() {
+	newNode := func(name string, labels map[string]string) *corev1.Node {
+		labels[nodeinfo.NodeLabelMlnxNIC] = "true"
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	newDriverPod := func(nodeName, image string, ready corev1.ConditionStatus) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "mofed-" + nodeName,
+				Namespace: config.FromEnv().State.NetworkOperatorResourceNamespace,
+				Labels:    map[string]string{upgrade.OfedDriverLabel: ""},
+			},
+			Spec: corev1.PodSpec{NodeName: nodeName, Containers: []corev1.Container{
+				{Name: upgrade.OfedDriverContainerName, Image: image}}},
+			Status: corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: ready}}},
+		}
+	}
+	getLabel := func(c client.Client, nodeName string) (string, bool) {
+		// Get into a new object, labels removed by the patch are kept when decoding into an existing one
+		node := &corev1.Node{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: nodeName}, node)).To(Succeed())
+		version, found := node.Labels[consts.OfedVersionNodeLabel]
+		return version, found
+	}
+
+	It("should label the nodes with the version of the Ready driver pods and remove it when disabled", func() {
+		objects := []client.Object{
+			newNode("ready", map[string]string{}),
+			newNode("upgraded", map[string]string{consts.OfedVersionNodeLabel: "5.6-1.0.3.3"}),
+			newNode("not-ready", map[string]string{consts.OfedVersionNodeLabel: "5.6-1.0.3.3"}),
+			newNode("no-driver", map[string]string{}),
+			newDriverPod("ready", "nvcr.io/mellanox/mofed:5.7-0.1.2.0-ubuntu20.04-amd64", corev1.ConditionTrue),
+			newDriverPod("upgraded", "nvcr.io/mellanox/mofed:5.7-0.1.2.0-ubuntu20.04-amd64", corev1.ConditionTrue),
+			newDriverPod("not-ready", "nvcr.io/mellanox/mofed:5.7-0.1.2.0-ubuntu20.04-amd64", corev1.ConditionFalse),
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).Build()
+		reconciler := &NicClusterPolicyReconciler{Client: fakeClient, Log: ctrl.Log}
+		cr := &mellanoxv1alpha1.NicClusterPolicy{}
+		cr.Spec.OFEDDriver = &mellanoxv1alpha1.OFEDDriverSpec{
+			ImageSpec: mellanoxv1alpha1.ImageSpec{Image: "mofed", Repository: "nvcr.io", Version: "5.7-0.1.2.0"},
+		}
+
+		reconciler.updateDriverVersionNodeLabels(context.TODO(), cr)
+		for _, name := range []string{"ready", "upgraded"} {
+			version, found := getLabel(fakeClient, name)
+			Expect(found).To(BeTrue())
+			Expect(version).To(Equal("5.7-0.1.2.0"))
+		}
+		for _, name := range []string{"not-ready", "no-driver"} {
+			_, found := getLabel(fakeClient, name)
+			Expect(found).To(BeFalse())
+		}
+
+		cr.Spec.OFEDDriver = nil
+		reconciler.updateDriverVersionNodeLabels(context.TODO(), cr)
+		for _, name := range []string{"ready", "upgraded", "not-ready", "no-driver"} {
+			_, found := getLabel(fakeClient, name)
+			Expect(found).To(BeFalse())
+		}
+	})
+})
@@ -0,0 +1,46 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+)
+
+// nodeToPolicy requeues NicClusterPolicy on the node events which affect it
+func (r *NicClusterPolicyReconciler) nodeToPolicy(_ client.Object) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: client.ObjectKey{Name: consts.NicClusterPolicyResourceName}}}
+}
+
+// excludedNodeLabelChanged passes node update events which exclude the node from the management of the operator
+// or include it back, see nodeinfo.IsNodeExcluded
+var excludedNodeLabelChanged = predicate.Funcs{
+	CreateFunc: func(e event.CreateEvent) bool { return false },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldNode, oldOk := e.ObjectOld.(*corev1.Node)
+		newNode, newOk := e.ObjectNew.(*corev1.Node)
+		return oldOk && newOk && nodeinfo.IsNodeExcluded(oldNode) != nodeinfo.IsNodeExcluded(newNode)
+	},
+	DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+	GenericFunc: func(e event.GenericEvent) bool { return false },
+}
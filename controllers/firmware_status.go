@@ -0,0 +1,89 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+)
+
+// updateFirmwareNodeConditions sets consts.FirmwareReadyNodeCondition on the nodes with Mellanox NICs according
+// to the firmware status label of the node, the condition is removed from the nodes if firmware is not set
+// in the OFED driver spec. The nodes are patched only when the condition changes
+func (r *NicClusterPolicyReconciler) updateFirmwareNodeConditions(
+	ctx context.Context, cr *mellanoxv1alpha1.NicClusterPolicy) {
+	ofedSpec := cr.Spec.OFEDDriver
+	enabled := ofedSpec != nil && ofedSpec.IsEnabled() && ofedSpec.Firmware != nil
+
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes, client.MatchingLabels{nodeinfo.NodeLabelMlnxNIC: "true"}); err != nil {
+		r.Log.V(consts.LogLevelWarning).Info("Failed to list nodes", "error:", err)
+		return
+	}
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if nodeinfo.IsNodeExcluded(node) {
+			continue
+		}
+		if enabled {
+			r.patchNodeStatus(ctx, node, nodeConditionPatch(node, firmwareReadyCondition(node)))
+		} else {
+			r.patchNodeStatus(ctx, node, removeNodeConditionPatch(node, consts.FirmwareReadyNodeCondition))
+		}
+	}
+}
+
+// firmwareReadyCondition returns the firmware ready condition for the firmware status label of the node,
+// the status is Unknown until the firmware init container of the OFED driver pod reports the firmware status
+func firmwareReadyCondition(node *corev1.Node) corev1.NodeCondition {
+	condition := corev1.NodeCondition{Type: consts.FirmwareReadyNodeCondition}
+	switch status := node.Labels[consts.FirmwareStatusNodeLabel]; status {
+	case consts.FirmwareStatusUpdated:
+		condition.Status = corev1.ConditionTrue
+		condition.Reason = "FirmwareUpdated"
+		condition.Message = "provided firmware was flashed to the NICs of the node"
+	case consts.FirmwareStatusCurrent:
+		condition.Status = corev1.ConditionTrue
+		condition.Reason = "FirmwareCurrent"
+		condition.Message = "NICs of the node run the provided firmware"
+	case consts.FirmwareStatusOutdated:
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = "FirmwareOutdated"
+		condition.Message = "NICs of the node don't run the provided firmware and firmware update is disabled"
+	case consts.FirmwareStatusFailed:
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = "FirmwareUpdateFailed"
+		condition.Message = "failed to flash the provided firmware, check the logs of the mofed-firmware container"
+	case "":
+		condition.Status = corev1.ConditionUnknown
+		condition.Reason = "FirmwareStatusUnknown"
+		condition.Message = fmt.Sprintf("firmware status is not reported yet in the %s node label",
+			consts.FirmwareStatusNodeLabel)
+	default:
+		condition.Status = corev1.ConditionUnknown
+		condition.Reason = "FirmwareStatusUnknown"
+		condition.Message = fmt.Sprintf("unknown firmware status %q", status)
+	}
+	return condition
+}
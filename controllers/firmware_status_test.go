@@ -0,0 +1,81 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+)
+
+var _ = Describe("Firmware ready node condition", func() {
+	newNode := func(name, status string) *corev1.Node {
+		labels := map[string]string{nodeinfo.NodeLabelMlnxNIC: "true"}
+		if status != "" {
+			labels[consts.FirmwareStatusNodeLabel] = status
+		}
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	getCondition := func(c client.Client, nodeName string) *corev1.NodeCondition {
+		node := &corev1.Node{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: nodeName}, node)).To(Succeed())
+		return findNodeCondition(node, consts.FirmwareReadyNodeCondition)
+	}
+
+	It("should reflect the firmware status of the nodes and be removed when disabled", func() {
+		objects := []client.Object{
+			newNode("updated", consts.FirmwareStatusUpdated), newNode("current", consts.FirmwareStatusCurrent),
+			newNode("outdated", consts.FirmwareStatusOutdated), newNode("failed", consts.FirmwareStatusFailed),
+			newNode("unknown", ""),
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).Build()
+		reconciler := &NicClusterPolicyReconciler{Client: fakeClient, Log: ctrl.Log}
+		cr := &mellanoxv1alpha1.NicClusterPolicy{}
+		cr.Spec.OFEDDriver = &mellanoxv1alpha1.OFEDDriverSpec{
+			ImageSpec: mellanoxv1alpha1.ImageSpec{Image: "mofed", Repository: "nvcr.io", Version: "5.7"},
+			Firmware: &mellanoxv1alpha1.OFEDFirmwareSpec{
+				HostPath: &corev1.HostPathVolumeSource{Path: "/opt/firmware"},
+				Update:   true,
+			},
+		}
+
+		reconciler.updateFirmwareNodeConditions(context.TODO(), cr)
+		Expect(getCondition(fakeClient, "updated").Reason).To(Equal("FirmwareUpdated"))
+		Expect(getCondition(fakeClient, "current").Status).To(Equal(corev1.ConditionTrue))
+		Expect(getCondition(fakeClient, "outdated").Reason).To(Equal("FirmwareOutdated"))
+		Expect(getCondition(fakeClient, "failed").Status).To(Equal(corev1.ConditionFalse))
+		Expect(getCondition(fakeClient, "unknown").Status).To(Equal(corev1.ConditionUnknown))
+
+		cr.Spec.OFEDDriver.Firmware = nil
+		reconciler.updateFirmwareNodeConditions(context.TODO(), cr)
+		for _, name := range []string{"updated", "current", "outdated", "failed", "unknown"} {
+			Expect(getCondition(fakeClient, name)).To(BeNil())
+		}
+	})
+})
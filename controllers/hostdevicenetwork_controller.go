@@ -17,9 +17,11 @@ package controllers //nolint:dupl
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	netattdefv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/go-logr/logr"
@@ -34,6 +36,7 @@ import (
 	mellanoxcomv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
 	"github.com/Mellanox/network-operator/pkg/config"
 	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
 	"github.com/Mellanox/network-operator/pkg/state"
 	"github.com/Mellanox/network-operator/pkg/utils"
 )
@@ -50,6 +53,7 @@ type HostDeviceNetworkReconciler struct {
 // +kubebuilder:rbac:groups=mellanox.com,resources=hostdevicenetworks,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=mellanox.com,resources=hostdevicenetworks/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=k8s.cni.cncf.io,resources=*,verbs=*
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
 
 //nolint:dupl
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
@@ -72,12 +76,45 @@ func (r *HostDeviceNetworkReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		return reconcile.Result{}, err
 	}
 
+	networkNamespaces := []string{networkNamespaceOrDefault(instance.Spec.NetworkNamespace)}
+	if !instance.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, handleNetworkTeardown(ctx, r.Client, instance,
+			instance.Status.GeneratedNetworkAttachmentDefinitions, networkNamespaces)
+	}
+	if err := addNetworkFinalizer(ctx, r.Client, instance); err != nil {
+		reqLogger.V(consts.LogLevelError).Info("Failed to add finalizer", "error:", err)
+		return reconcile.Result{}, err
+	}
+
 	managerStatus, err := r.stateManager.SyncState(instance, nil)
-	r.updateCrStatus(instance, managerStatus)
+	updateNetworkAttachmentDefinitionIndex(ctx, r.Client, r.Log,
+		&instance.Status.GeneratedNetworkAttachmentDefinitions, instance.Name, networkNamespaces)
+	updatePermissionDeniedCondition(r.Log, &instance.Status.Conditions, managerStatus, err)
 	if err != nil {
+		r.updateCrStatus(instance, managerStatus)
 		return reconcile.Result{}, err
 	}
 
+	resourceName := state.HostDeviceNetworkResourceName(instance)
+	advertised, err := r.isResourceAdvertised(ctx, resourceName)
+	if err != nil {
+		reqLogger.V(consts.LogLevelError).Info("Failed to check resource availability", "error:", err)
+		return reconcile.Result{}, err
+	}
+	if !advertised {
+		// NetworkAttachmentDefinition is created, but pods can't use it until the device plugin
+		// advertises the resource
+		reqLogger.V(consts.LogLevelInfo).Info("Resource is not advertised by any node", "resource", resourceName)
+		managerStatus.Status = state.SyncStateNotReady
+		instance.Status.Reason = fmt.Sprintf("waiting for resource %s to be advertised by device plugin", resourceName)
+		r.updateCrStatus(instance, managerStatus)
+		return reconcile.Result{
+			RequeueAfter: time.Duration(config.FromEnv().Controller.ResourceRequeueTimeSeconds) * time.Second,
+		}, nil
+	}
+	instance.Status.Reason = ""
+	r.updateCrStatus(instance, managerStatus)
+
 	if managerStatus.Status != state.SyncStateReady {
 		return reconcile.Result{
 			RequeueAfter: time.Duration(config.FromEnv().Controller.RequeueTimeSeconds) * time.Second,
@@ -87,6 +124,25 @@ func (r *HostDeviceNetworkReconciler) Reconcile(ctx context.Context, req ctrl.Re
 	return ctrl.Result{}, nil
 }
 
+// isResourceAdvertised returns true if at least one node has allocatable capacity of the resource,
+// the nodes excluded from the management of the operator are not considered
+func (r *HostDeviceNetworkReconciler) isResourceAdvertised(ctx context.Context, resourceName string) (bool, error) {
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes); err != nil {
+		return false, err
+	}
+	for i := range nodes.Items {
+		if nodeinfo.IsNodeExcluded(&nodes.Items[i]) {
+			continue
+		}
+		quantity, ok := nodes.Items[i].Status.Allocatable[corev1.ResourceName(resourceName)]
+		if ok && !quantity.IsZero() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 //nolint:dupl
 func (r *HostDeviceNetworkReconciler) updateCrStatus(cr *mellanoxcomv1alpha1.HostDeviceNetwork, status state.Results) {
 NextResult:
@@ -105,13 +161,20 @@ NextResult:
 	}
 	// Update global State
 	cr.Status.State = mellanoxcomv1alpha1.State(status.Status)
+	networkNamespace := networkNamespaceOrDefault(cr.Spec.NetworkNamespace)
+	if len(status.StatesStatus) > 0 {
+		// the NetworkAttachmentDefinition may be ready while the network waits for the resource
+		netAttachDefStatus := status.StatesStatus[0]
+		cr.Status.NetworkAttachmentDefinition = networkAttachmentDefinitionStatus(cr.Name, networkNamespace,
+			mellanoxcomv1alpha1.State(netAttachDefStatus.Status), netAttachDefStatus.ErrInfo)
+	}
 
 	if cr.Status.State == state.SyncStateReady {
 		netAttachDef := &netattdefv1.NetworkAttachmentDefinition{}
 		err := r.Get(context.TODO(),
 			types.NamespacedName{
 				Name:      cr.Name,
-				Namespace: cr.Spec.NetworkNamespace,
+				Namespace: networkNamespace,
 			}, netAttachDef)
 
 		if err != nil {
@@ -126,7 +189,7 @@ NextResult:
 		"Updating status", "Custom resource name", cr.Name, "namespace", cr.Namespace, "Result:", cr.Status)
 	err := r.Status().Update(context.TODO(), cr)
 	if err != nil {
-		r.Log.V(consts.LogLevelError).Info("Failed to update CR status", "error:", err)
+		logStatusUpdateError(r.Log, err)
 	}
 }
 
@@ -134,7 +197,7 @@ NextResult:
 // SetupWithManager sets up the controller with the Manager.
 func (r *HostDeviceNetworkReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	// Create state manager
-	stateManager, err := state.NewManager(mellanoxcomv1alpha1.HostDeviceNetworkCRDName, mgr.GetClient(), mgr.GetScheme())
+	stateManager, err := state.NewManager(mellanoxcomv1alpha1.HostDeviceNetworkCRDName, r.Client, mgr.GetScheme())
 	if err != nil {
 		// Error creating stateManager
 		r.Log.V(consts.LogLevelError).Info("Error creating state manager.", "error:", err)
@@ -147,7 +210,8 @@ func (r *HostDeviceNetworkReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		// Watch for changes to primary resource HostDeviceNetwork
 		Watches(&source.Kind{Type: &mellanoxcomv1alpha1.HostDeviceNetwork{}}, &handler.EnqueueRequestForObject{})
 
-	// Watch for changes to secondary resource DaemonSet and requeue the owner HostDeviceNetwork
+	// Watch for changes to the owned NetworkAttachmentDefinitions and requeue the owner HostDeviceNetwork,
+	// so that a deleted or modified NetworkAttachmentDefinition is restored right away
 	ws := stateManager.GetWatchSources()
 	r.Log.V(consts.LogLevelInfo).Info("Watch Sources", "Kind:", ws)
 	for i := range ws {
@@ -19,7 +19,12 @@ package controllers //nolint:dupl
 import (
 	goctx "context"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -27,6 +32,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/config"
 )
 
 //nolint:dupl
@@ -92,4 +98,36 @@ var _ = Describe("HostDeviceNetwork Controller", func() {
 
 		})
 	})
+
+	Context("When the resource of HostDeviceNetwork is checked", func() {
+		It("should report the resource as advertised only by nodes with allocatable capacity", func() {
+			savedExcludedNodeLabel := config.FromEnv().State.ExcludedNodeLabel
+			defer func() { config.FromEnv().State.ExcludedNodeLabel = savedExcludedNodeLabel }()
+			config.FromEnv().State.ExcludedNodeLabel = "network.nvidia.com/operator.exclude"
+			newNode := func(name string, hostdev string, labels map[string]string) *corev1.Node {
+				return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+					Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+						"nvidia.com/hostdev": resource.MustParse(hostdev)}}}
+			}
+			s := scheme.Scheme
+			Expect(mellanoxv1alpha1.AddToScheme(s)).To(Succeed())
+			fakeClient := fake.NewClientBuilder().WithScheme(s).WithObjects(
+				newNode("no-capacity", "0", nil),
+				newNode("excluded", "8", map[string]string{"network.nvidia.com/operator.exclude": "true"}),
+			).Build()
+			reconciler := &HostDeviceNetworkReconciler{Client: fakeClient, Log: ctrl.Log}
+
+			advertised, err := reconciler.isResourceAdvertised(goctx.TODO(), "nvidia.com/hostdev")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(advertised).To(BeFalse())
+			advertised, err = reconciler.isResourceAdvertised(goctx.TODO(), "nvidia.com/other")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(advertised).To(BeFalse())
+
+			Expect(fakeClient.Create(goctx.TODO(), newNode("worker", "8", nil))).To(Succeed())
+			advertised, err = reconciler.isResourceAdvertised(goctx.TODO(), "nvidia.com/hostdev")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(advertised).To(BeTrue())
+		})
+	})
 })
@@ -51,6 +51,7 @@ type IPoIBNetworkReconciler struct {
 // +kubebuilder:rbac:groups=mellanox.com,resources=ipoibnetworks/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=mellanox.com,resources=*,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=k8s.cni.cncf.io,resources=*,verbs=*
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch;create
 
 //nolint:dupl
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
@@ -73,7 +74,41 @@ func (r *IPoIBNetworkReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		return reconcile.Result{}, err
 	}
 
+	networkNamespace := networkNamespaceOrDefault(instance.Spec.NetworkNamespace)
+	if !instance.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, handleNetworkTeardown(ctx, r.Client, instance,
+			instance.Status.GeneratedNetworkAttachmentDefinitions, []string{networkNamespace})
+	}
+	if err := addNetworkFinalizer(ctx, r.Client, instance); err != nil {
+		reqLogger.V(consts.LogLevelError).Info("Failed to add finalizer", "error:", err)
+		return reconcile.Result{}, err
+	}
+
+	namespaceExists, err := ensureNetworkNamespace(ctx, r.Client, networkNamespace,
+		config.FromEnv().Controller.CreateNetworkNamespaces, &instance.Status.Conditions)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if !namespaceExists {
+		// the NetworkAttachmentDefinition can't be created until the namespace exists
+		reqLogger.V(consts.LogLevelWarning).Info("Network namespace doesn't exist", "namespace", networkNamespace)
+		notReady := state.Results{
+			Status:       state.SyncStateNotReady,
+			StatesStatus: []state.Result{{Status: state.SyncStateNotReady}},
+		}
+		err = r.updateCrStatus(instance, notReady, networkNamespaceMissingError(networkNamespace))
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{
+			RequeueAfter: time.Duration(config.FromEnv().Controller.RequeueTimeSeconds) * time.Second,
+		}, nil
+	}
+
 	managerStatus, managerErr := r.stateManager.SyncState(instance, nil)
+	updateNetworkAttachmentDefinitionIndex(ctx, r.Client, r.Log,
+		&instance.Status.GeneratedNetworkAttachmentDefinitions, instance.Name, []string{networkNamespace})
+	updatePermissionDeniedCondition(r.Log, &instance.Status.Conditions, managerStatus, managerErr)
 	err = r.updateCrStatus(instance, managerStatus, managerErr)
 	if err != nil {
 		return reconcile.Result{}, err
@@ -94,6 +129,9 @@ func (r *IPoIBNetworkReconciler) updateCrStatus(cr *mellanoxcomv1alpha1.IPoIBNet
 	if syncError != nil {
 		cr.Status.Reason = syncError.Error()
 	}
+	networkNamespace := networkNamespaceOrDefault(cr.Spec.NetworkNamespace)
+	cr.Status.NetworkAttachmentDefinition = networkAttachmentDefinitionStatus(
+		cr.Name, networkNamespace, cr.Status.State, syncError)
 
 	var err error
 
@@ -102,7 +140,7 @@ func (r *IPoIBNetworkReconciler) updateCrStatus(cr *mellanoxcomv1alpha1.IPoIBNet
 		getErr := r.Get(context.TODO(),
 			types.NamespacedName{
 				Name:      cr.Name,
-				Namespace: cr.Spec.NetworkNamespace,
+				Namespace: networkNamespace,
 			}, netAttachDef)
 
 		if getErr != nil {
@@ -118,7 +156,7 @@ func (r *IPoIBNetworkReconciler) updateCrStatus(cr *mellanoxcomv1alpha1.IPoIBNet
 		"Updating status", "Custom resource name", cr.Name, "namespace", cr.Namespace, "Result:", cr.Status)
 	updateErr := r.Status().Update(context.TODO(), cr)
 	if updateErr != nil {
-		r.Log.V(consts.LogLevelError).Info("Failed to update CR status", "error:", updateErr)
+		logStatusUpdateError(r.Log, updateErr)
 		err = updateErr
 	}
 
@@ -129,7 +167,7 @@ func (r *IPoIBNetworkReconciler) updateCrStatus(cr *mellanoxcomv1alpha1.IPoIBNet
 //nolint:dupl
 func (r *IPoIBNetworkReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	// Create state manager
-	stateManager, err := state.NewManager(mellanoxcomv1alpha1.IPoIBNetworkCRDName, mgr.GetClient(), mgr.GetScheme())
+	stateManager, err := state.NewManager(mellanoxcomv1alpha1.IPoIBNetworkCRDName, r.Client, mgr.GetScheme())
 	if err != nil {
 		// Error creating stateManager
 		r.Log.V(consts.LogLevelError).Info("Error creating state manager.", "error:", err)
@@ -142,7 +180,8 @@ func (r *IPoIBNetworkReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		// Watch for changes to primary resource IPoIBNetwork
 		Watches(&source.Kind{Type: &mellanoxcomv1alpha1.IPoIBNetwork{}}, &handler.EnqueueRequestForObject{})
 
-	// Watch for changes to secondary resource DaemonSet and requeue the owner IPoIBNetwork
+	// Watch for changes to the owned NetworkAttachmentDefinitions and requeue the owner IPoIBNetwork,
+	// so that a deleted or modified NetworkAttachmentDefinition is restored right away
 	ws := stateManager.GetWatchSources()
 	r.Log.V(consts.LogLevelInfo).Info("Watch Sources", "Kind:", ws)
 	for i := range ws {
@@ -70,6 +70,47 @@ var _ = Describe("IPoIBNetwork Controller", func() {
 			Expect(err).NotTo(HaveOccurred())
 		})
 
+		It("should recreate deleted NetworkAttachmentDefinition", func() {
+			cr := mellanoxv1alpha1.IPoIBNetwork{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "IPoIBNetwork",
+					APIVersion: "mellanox.com/v1alpha1",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-recreate",
+				},
+				Spec: mellanoxv1alpha1.IPoIBNetworkSpec{
+					NetworkNamespace: "default",
+					Master:           "ibs3",
+				},
+			}
+
+			err := k8sClient.Create(goctx.TODO(), &cr)
+			Expect(err).NotTo(HaveOccurred())
+
+			nadName := types.NamespacedName{Namespace: "default", Name: cr.GetName()}
+			netAttachDef := &netattdefv1.NetworkAttachmentDefinition{}
+			Eventually(func() error {
+				return k8sClient.Get(goctx.TODO(), nadName, netAttachDef)
+			}, timeout*3, interval).ShouldNot(HaveOccurred())
+			deletedUID := netAttachDef.UID
+
+			err = k8sClient.Delete(goctx.TODO(), netAttachDef)
+			Expect(err).NotTo(HaveOccurred())
+
+			// the deletion requeues the owner, the NetworkAttachmentDefinition is created again
+			Eventually(func() bool {
+				recreated := &netattdefv1.NetworkAttachmentDefinition{}
+				if err := k8sClient.Get(goctx.TODO(), nadName, recreated); err != nil {
+					return false
+				}
+				return recreated.UID != deletedUID
+			}, timeout*3, interval).Should(BeTrue())
+
+			err = k8sClient.Delete(goctx.TODO(), &cr)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
 		It("should create ipoib network empty state", func() {
 			cr := mellanoxv1alpha1.IPoIBNetwork{
 				TypeMeta: metav1.TypeMeta{
@@ -18,10 +18,16 @@ package controllers //nolint:dupl
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -35,6 +41,7 @@ import (
 	mellanoxcomv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
 	"github.com/Mellanox/network-operator/pkg/config"
 	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
 	"github.com/Mellanox/network-operator/pkg/state"
 	"github.com/Mellanox/network-operator/pkg/utils"
 )
@@ -52,6 +59,10 @@ type MacvlanNetworkReconciler struct {
 // +kubebuilder:rbac:groups=mellanox.com,resources=macvlannetworks/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=mellanox.com,resources=*,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=k8s.cni.cncf.io,resources=*,verbs=*
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+
+// physicalInterfacePrefixes match the names of physical network interfaces, e.g. eth0, ens1f0, enp59s0f1 or ib0
+var physicalInterfacePrefixes = []string{"eth", "en", "ib"}
 
 //nolint:dupl
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
@@ -74,34 +85,134 @@ func (r *MacvlanNetworkReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		return reconcile.Result{}, err
 	}
 
+	if !instance.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, handleNetworkTeardown(ctx, r.Client, instance,
+			instance.Status.GeneratedNetworkAttachmentDefinitions, state.MacvlanNetworkNamespaces(instance))
+	}
+	if err := addNetworkFinalizer(ctx, r.Client, instance); err != nil {
+		reqLogger.V(consts.LogLevelError).Info("Failed to add finalizer", "error:", err)
+		return reconcile.Result{}, err
+	}
+
 	managerStatus, err := r.stateManager.SyncState(instance, nil)
+	updateNetworkAttachmentDefinitionIndex(ctx, r.Client, r.Log,
+		&instance.Status.GeneratedNetworkAttachmentDefinitions, instance.Name, state.MacvlanNetworkNamespaces(instance))
+	instance.Status.Warning = strings.Join(
+		append(nonEmpty(r.masterWarning(ctx, instance)), r.ipamRangeWarnings(instance)...), "; ")
+	r.updateIPPoolCondition(ctx, instance)
+	updatePermissionDeniedCondition(r.Log, &instance.Status.Conditions, managerStatus, err)
 	r.updateCrStatus(instance, managerStatus, err)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
 
-	if managerStatus.Status != state.SyncStateReady {
+	if instance.Status.State != state.SyncStateReady {
 		return reconcile.Result{
 			RequeueAfter: time.Duration(config.FromEnv().Controller.RequeueTimeSeconds) * time.Second,
 		}, nil
 	}
+	// IPPools are not watched, as nv-ipam may not be deployed, ready networks check their pools periodically
+	if len(nvIpamPoolNames(instance.Spec.IPAM)) > 0 {
+		return reconcile.Result{
+			RequeueAfter: time.Duration(config.FromEnv().Controller.NvIpamPoolsCheckIntervalSeconds) * time.Second,
+		}, nil
+	}
 
 	return ctrl.Result{}, nil
 }
 
+// updateIPPoolCondition sets consts.IPPoolReadyCondition in the status of the network using nv-ipam,
+// the condition is removed if the network doesn't use nv-ipam
+func (r *MacvlanNetworkReconciler) updateIPPoolCondition(ctx context.Context, cr *mellanoxcomv1alpha1.MacvlanNetwork) {
+	condition := ipPoolReadyCondition(ctx, r.Client, cr.Spec.IPAM)
+	if condition == nil {
+		if meta.FindStatusCondition(cr.Status.Conditions, consts.IPPoolReadyCondition) != nil {
+			meta.RemoveStatusCondition(&cr.Status.Conditions, consts.IPPoolReadyCondition)
+		}
+		return
+	}
+	if condition.Status != metav1.ConditionTrue {
+		r.Log.V(consts.LogLevelWarning).Info("IPPool of MacvlanNetwork is not ready", "name", cr.Name,
+			"reason", condition.Reason, "message", condition.Message)
+	}
+	meta.SetStatusCondition(&cr.Status.Conditions, *condition)
+}
+
+// masterWarning returns a warning if the master of the network looks like a physical interface while some nodes
+// use bonding, the physical interface is usually enslaved to the bond there and the master should be the bond.
+// Nodes using bonding are recognized by nodeinfo.NodeLabelBondPresent label, no warning is returned without it
+func (r *MacvlanNetworkReconciler) masterWarning(ctx context.Context, cr *mellanoxcomv1alpha1.MacvlanNetwork) string {
+	if !isPhysicalInterfaceName(cr.Spec.Master) {
+		return ""
+	}
+	nodes := &corev1.NodeList{}
+	err := r.List(ctx, nodes, client.MatchingLabels{nodeinfo.NodeLabelBondPresent: "true"})
+	if err != nil {
+		r.Log.V(consts.LogLevelWarning).Info("Failed to list nodes using bonding", "error:", err)
+		return ""
+	}
+	if len(nodes.Items) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(nodes.Items))
+	for i := range nodes.Items {
+		names = append(names, nodes.Items[i].Name)
+	}
+	sort.Strings(names)
+	warning := fmt.Sprintf("master %q looks like a physical interface, but nodes %s use bonding, "+
+		"set master to the bond interface if the physical interface is enslaved to it",
+		cr.Spec.Master, strings.Join(names, ","))
+	r.Log.V(consts.LogLevelWarning).Info("MacvlanNetwork master may not work on nodes using bonding",
+		"name", cr.Name, "master", cr.Spec.Master, "nodes", names)
+	return warning
+}
+
+// ipamRangeWarnings returns the warnings about the whereabouts IPAM ranges of the network,
+// see whereaboutsRangeWarnings
+func (r *MacvlanNetworkReconciler) ipamRangeWarnings(cr *mellanoxcomv1alpha1.MacvlanNetwork) []string {
+	warnings := whereaboutsRangeWarnings(cr.Spec.IPAM)
+	if len(warnings) != 0 {
+		r.Log.V(consts.LogLevelWarning).Info("IPAM ranges of MacvlanNetwork may not work as expected",
+			"name", cr.Name, "warnings", warnings)
+	}
+	return warnings
+}
+
+// nonEmpty returns a list with the warning, an empty list if there is no warning
+func nonEmpty(warning string) []string {
+	if warning == "" {
+		return nil
+	}
+	return []string{warning}
+}
+
+// isPhysicalInterfaceName returns true if the interface name follows the naming of physical network interfaces,
+// VLAN interfaces of such interfaces are matched as well, bond, team and bridge interfaces are not
+func isPhysicalInterfaceName(name string) bool {
+	for _, prefix := range physicalInterfacePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *MacvlanNetworkReconciler) updateCrStatus(cr *mellanoxcomv1alpha1.MacvlanNetwork, status state.Results,
 	syncError error) {
 	cr.Status.State = mellanoxcomv1alpha1.State(status.StatesStatus[0].Status)
 	if syncError != nil {
 		cr.Status.Reason = syncError.Error()
 	}
+	networkNamespace := networkNamespaceOrDefault(cr.Spec.NetworkNamespace)
+	cr.Status.NetworkAttachmentDefinition = networkAttachmentDefinitionStatus(
+		cr.Name, networkNamespace, cr.Status.State, syncError)
 
 	if cr.Status.State == state.SyncStateReady {
 		netAttachDef := &netattdefv1.NetworkAttachmentDefinition{}
 		err := r.Get(context.TODO(),
 			types.NamespacedName{
 				Name:      cr.Name,
-				Namespace: cr.Spec.NetworkNamespace,
+				Namespace: networkNamespace,
 			}, netAttachDef)
 
 		if err != nil {
@@ -111,12 +222,18 @@ func (r *MacvlanNetworkReconciler) updateCrStatus(cr *mellanoxcomv1alpha1.Macvla
 		}
 	}
 
+	// the network can't be used while its IPPools don't allocate IPs on all nodes
+	if cr.Status.State == state.SyncStateReady &&
+		meta.IsStatusConditionFalse(cr.Status.Conditions, consts.IPPoolReadyCondition) {
+		cr.Status.State = state.SyncStateNotReady
+	}
+
 	// send status update request to k8s API
 	r.Log.V(consts.LogLevelInfo).Info(
 		"Updating status", "Custom resource name", cr.Name, "namespace", cr.Namespace, "Result:", cr.Status)
 	err := r.Status().Update(context.TODO(), cr)
 	if err != nil {
-		r.Log.V(consts.LogLevelError).Info("Failed to update CR status", "error:", err)
+		logStatusUpdateError(r.Log, err)
 	}
 }
 
@@ -124,7 +241,7 @@ func (r *MacvlanNetworkReconciler) updateCrStatus(cr *mellanoxcomv1alpha1.Macvla
 //nolint:dupl
 func (r *MacvlanNetworkReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	// Create state manager
-	stateManager, err := state.NewManager(mellanoxcomv1alpha1.MacvlanNetworkCRDName, mgr.GetClient(), mgr.GetScheme())
+	stateManager, err := state.NewManager(mellanoxcomv1alpha1.MacvlanNetworkCRDName, r.Client, mgr.GetScheme())
 	if err != nil {
 		// Error creating stateManager
 		r.Log.V(consts.LogLevelError).Info("Error creating state manager.", "error:", err)
@@ -137,7 +254,8 @@ func (r *MacvlanNetworkReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		// Watch for changes to primary resource MacvlanNetwork
 		Watches(&source.Kind{Type: &mellanoxcomv1alpha1.MacvlanNetwork{}}, &handler.EnqueueRequestForObject{})
 
-	// Watch for changes to secondary resource DaemonSet and requeue the owner MacvlanNetwork
+	// Watch for changes to the owned NetworkAttachmentDefinitions and requeue the owner MacvlanNetwork,
+	// so that a deleted or modified NetworkAttachmentDefinition is restored right away
 	ws := stateManager.GetWatchSources()
 	r.Log.V(consts.LogLevelInfo).Info("Watch Sources", "Kind:", ws)
 	for i := range ws {
@@ -0,0 +1,56 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+)
+
+var _ = Describe("MacvlanNetwork Controller", func() {
+	It("should warn if the master looks like a physical interface on nodes using bonding", func() {
+		bondNode := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name: "bond-node", Labels: map[string]string{nodeinfo.NodeLabelBondPresent: "true"}}}
+		otherNode := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "other-node"}}
+		reconciler := &MacvlanNetworkReconciler{
+			Client: fake.NewClientBuilder().WithObjects(bondNode, otherNode).Build(),
+			Log:    zap.New(zap.UseDevMode(true)),
+		}
+		cr := &mellanoxv1alpha1.MacvlanNetwork{ObjectMeta: metav1.ObjectMeta{Name: "macvlan"}}
+
+		for _, master := range []string{"", "bond0", "bond0.100", "team0", "br0"} {
+			cr.Spec.Master = master
+			Expect(reconciler.masterWarning(goctx.TODO(), cr)).To(BeEmpty())
+		}
+		for _, master := range []string{"eth0", "ens1f0", "enp59s0f1.100", "ib0"} {
+			cr.Spec.Master = master
+			Expect(reconciler.masterWarning(goctx.TODO(), cr)).To(ContainSubstring("bond-node"))
+		}
+
+		reconciler.Client = fake.NewClientBuilder().WithObjects(otherNode).Build()
+		Expect(reconciler.masterWarning(goctx.TODO(), cr)).To(BeEmpty())
+	})
+})
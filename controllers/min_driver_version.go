@@ -0,0 +1,145 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/state"
+	"github.com/Mellanox/network-operator/pkg/upgrade"
+	"github.com/Mellanox/network-operator/pkg/utils"
+)
+
+// updateMinDriverVersionCondition sets consts.DriverVersionBelowMinimumCondition in the NicClusterPolicy status
+// if the configured OFED driver version or driver pods on some nodes are older than MinDriverVersion,
+// the status is updated with CR status. Outdated driver pods are upgraded by the upgrade flow if
+// ForceMinDriverVersion is set, see forcedMinDriverVersionPolicy
+func (r *NicClusterPolicyReconciler) updateMinDriverVersionCondition(
+	ctx context.Context, cr *mellanoxv1alpha1.NicClusterPolicy) {
+	ofedSpec := cr.Spec.OFEDDriver
+	if ofedSpec == nil || !ofedSpec.IsEnabled() || ofedSpec.MinDriverVersion == "" {
+		meta.RemoveStatusCondition(&cr.Status.Conditions, consts.DriverVersionBelowMinimumCondition)
+		return
+	}
+	minVersion := ofedSpec.MinDriverVersion
+
+	var violations []string
+	specBelowMin, err := driverVersionBelow(ofedSpec.Version, minVersion)
+	if err != nil {
+		r.Log.V(consts.LogLevelWarning).Info("Failed to compare OFED driver version with the minimum version",
+			"version", ofedSpec.Version, "minVersion", minVersion, "error:", err)
+	}
+	if specBelowMin {
+		violations = append(violations, fmt.Sprintf("configured version %s", ofedSpec.Version))
+	}
+
+	podList := &corev1.PodList{}
+	err = r.List(ctx, podList,
+		client.InNamespace(state.OfedDriverNamespace(cr)),
+		client.MatchingLabels{upgrade.OfedDriverLabel: ""})
+	if err != nil {
+		// keep the current condition if the driver pods can't be listed
+		r.Log.V(consts.LogLevelWarning).Info("Failed to list OFED driver pods", "error:", err)
+		return
+	}
+	var nodes []string
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		version, ok := driverPodVersion(pod)
+		if !ok {
+			continue
+		}
+		below, err := driverVersionBelow(version, minVersion)
+		if err != nil || !below {
+			continue
+		}
+		nodes = append(nodes, fmt.Sprintf("%s (%s)", pod.Spec.NodeName, version))
+	}
+	if len(nodes) > 0 {
+		sort.Strings(nodes)
+		violations = append(violations, fmt.Sprintf("nodes: %s", strings.Join(nodes, ", ")))
+	}
+
+	if len(violations) == 0 {
+		meta.RemoveStatusCondition(&cr.Status.Conditions, consts.DriverVersionBelowMinimumCondition)
+		return
+	}
+	r.Log.V(consts.LogLevelWarning).Info("OFED driver version is below the minimum driver version",
+		"minVersion", minVersion, "violations", violations)
+	meta.SetStatusCondition(&cr.Status.Conditions, metav1.Condition{
+		Type:   consts.DriverVersionBelowMinimumCondition,
+		Status: metav1.ConditionTrue,
+		Reason: "MinDriverVersionNotMet",
+		Message: fmt.Sprintf("OFED driver version is below the minimum version %s: %s",
+			minVersion, strings.Join(violations, "; ")),
+	})
+}
+
+// forcedMinDriverVersionPolicy returns the upgrade policy used to upgrade the OFED driver pods older than
+// MinDriverVersion if ForceMinDriverVersion is set while automatic upgrade is disabled, nil otherwise.
+// The outdated nodes go through the upgrade flow with the settings of the upgrade policy, or one by one with drain
+// if the policy is not set. Nothing is forced if the configured version is older than MinDriverVersion
+func forcedMinDriverVersionPolicy(ofedSpec *mellanoxv1alpha1.OFEDDriverSpec) *mellanoxv1alpha1.OfedUpgradePolicySpec {
+	if ofedSpec == nil || !ofedSpec.IsEnabled() || !ofedSpec.ForceMinDriverVersion || ofedSpec.MinDriverVersion == "" {
+		return nil
+	}
+	if ofedSpec.OfedUpgradePolicy != nil && ofedSpec.OfedUpgradePolicy.AutoUpgrade {
+		// the outdated pods are upgraded by the automatic upgrade
+		return nil
+	}
+	if below, err := driverVersionBelow(ofedSpec.Version, ofedSpec.MinDriverVersion); err != nil || below {
+		return nil
+	}
+	policy := &mellanoxv1alpha1.OfedUpgradePolicySpec{
+		MaxParallelUpgrades: 1,
+		DrainSpec:           &mellanoxv1alpha1.DrainSpec{Enable: true},
+	}
+	if ofedSpec.OfedUpgradePolicy != nil {
+		policy = ofedSpec.OfedUpgradePolicy.DeepCopy()
+	}
+	policy.AutoUpgrade = true
+	return policy
+}
+
+// driverPodVersion returns the OFED driver version of the driver container image of the pod
+func driverPodVersion(pod *corev1.Pod) (string, bool) {
+	for _, container := range pod.Spec.Containers {
+		if container.Name == upgrade.OfedDriverContainerName {
+			return utils.ParseDriverVersion(container.Image)
+		}
+	}
+	return "", false
+}
+
+// driverVersionBelow returns true if the OFED driver version is older than the minimum version
+func driverVersionBelow(version, minVersion string) (bool, error) {
+	cmp, err := utils.CompareDriverVersions(version, minVersion)
+	if err != nil {
+		return false, err
+	}
+	return cmp < 0, nil
+}
@@ -0,0 +1,97 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/state"
+	"github.com/Mellanox/network-operator/pkg/upgrade"
+)
+
+var _ = Describe("Minimum driver version", func() {
+	var cr *mellanoxv1alpha1.NicClusterPolicy
+
+	BeforeEach(func() {
+		cr = &mellanoxv1alpha1.NicClusterPolicy{}
+		cr.Spec.OFEDDriver = &mellanoxv1alpha1.OFEDDriverSpec{
+			ImageSpec: mellanoxv1alpha1.ImageSpec{
+				Image: "mofed", Repository: "nvcr.io/mellanox", Version: "5.7-0.1.2.0"},
+			MinDriverVersion:      "5.6-1.0.3.3",
+			ForceMinDriverVersion: true,
+		}
+	})
+
+	It("should report outdated driver pods without deleting them", func() {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: state.OfedDriverNamespace(cr), Name: "mofed-1",
+				Labels: map[string]string{upgrade.OfedDriverLabel: ""}},
+			Spec: corev1.PodSpec{NodeName: "node-1", Containers: []corev1.Container{
+				{Name: upgrade.OfedDriverContainerName, Image: "nvcr.io/mellanox/mofed:5.5-1.0.3.2"}}},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(pod).Build()
+		reconciler := &NicClusterPolicyReconciler{Client: fakeClient, Log: ctrl.Log}
+		reconciler.updateMinDriverVersionCondition(context.TODO(), cr)
+
+		condition := meta.FindStatusCondition(cr.Status.Conditions, consts.DriverVersionBelowMinimumCondition)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Message).To(ContainSubstring("node-1 (5.5-1.0.3.2)"))
+		// the outdated pods are upgraded by the upgrade flow
+		Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(pod), &corev1.Pod{})).To(Succeed())
+	})
+
+	It("should upgrade outdated drivers through the upgrade flow if forced", func() {
+		policy := forcedMinDriverVersionPolicy(cr.Spec.OFEDDriver)
+		Expect(policy).NotTo(BeNil())
+		Expect(policy.AutoUpgrade).To(BeTrue())
+		Expect(policy.MaxParallelUpgrades).To(Equal(1))
+		Expect(policy.DrainSpec.Enable).To(BeTrue())
+
+		cr.Spec.OFEDDriver.OfedUpgradePolicy = &mellanoxv1alpha1.OfedUpgradePolicySpec{MaxParallelUpgrades: 3}
+		policy = forcedMinDriverVersionPolicy(cr.Spec.OFEDDriver)
+		Expect(policy.AutoUpgrade).To(BeTrue())
+		Expect(policy.MaxParallelUpgrades).To(Equal(3))
+		Expect(cr.Spec.OFEDDriver.OfedUpgradePolicy.AutoUpgrade).To(BeFalse())
+	})
+
+	It("should not force the upgrade if not required", func() {
+		cr.Spec.OFEDDriver.ForceMinDriverVersion = false
+		Expect(forcedMinDriverVersionPolicy(cr.Spec.OFEDDriver)).To(BeNil())
+
+		cr.Spec.OFEDDriver.ForceMinDriverVersion = true
+		cr.Spec.OFEDDriver.OfedUpgradePolicy = &mellanoxv1alpha1.OfedUpgradePolicySpec{AutoUpgrade: true}
+		Expect(forcedMinDriverVersionPolicy(cr.Spec.OFEDDriver)).To(BeNil())
+
+		// restarted pods would not satisfy the minimum version
+		cr.Spec.OFEDDriver.OfedUpgradePolicy = nil
+		cr.Spec.OFEDDriver.Version = "5.5-1.0.3.2"
+		Expect(forcedMinDriverVersionPolicy(cr.Spec.OFEDDriver)).To(BeNil())
+		Expect(forcedMinDriverVersionPolicy(nil)).To(BeNil())
+	})
+})
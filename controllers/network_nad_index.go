@@ -0,0 +1,111 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-logr/logr"
+	netattdefv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/Mellanox/network-operator/pkg/consts"
+)
+
+// networkAttachmentDefinitionKeys returns the namespace/name keys of the NetworkAttachmentDefinitions of the network
+// in the namespaces
+func networkAttachmentDefinitionKeys(name string, namespaces []string) []string {
+	keys := make([]string, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		keys = append(keys, namespace+"/"+name)
+	}
+	return keys
+}
+
+// deleteNetworkAttachmentDefinition deletes the NetworkAttachmentDefinition with the namespace/name key,
+// a missing NetworkAttachmentDefinition is not an error
+func deleteNetworkAttachmentDefinition(ctx context.Context, c client.Client, key string) error {
+	parts := strings.SplitN(key, "/", 2)
+	if len(parts) != 2 {
+		// not written by the operator, nothing to delete
+		return nil
+	}
+	err := c.Delete(ctx, &netattdefv1.NetworkAttachmentDefinition{
+		ObjectMeta: metav1.ObjectMeta{Namespace: parts[0], Name: parts[1]}})
+	return client.IgnoreNotFound(err)
+}
+
+// updateNetworkAttachmentDefinitionIndex sets the index of the NetworkAttachmentDefinitions generated for
+// the network to the NetworkAttachmentDefinitions in the desired namespaces. The NetworkAttachmentDefinitions of
+// the index which are no longer desired, e.g. after the namespace of the network was changed, are deleted.
+// The ones which fail to be deleted are kept in the index, so the deletion is retried in the next reconcile.
+// The index is stored with the status of the network
+func updateNetworkAttachmentDefinitionIndex(ctx context.Context, c client.Client, log logr.Logger,
+	index *[]string, name string, namespaces []string) {
+	desired := networkAttachmentDefinitionKeys(name, namespaces)
+	isDesired := make(map[string]bool, len(desired))
+	for _, key := range desired {
+		isDesired[key] = true
+	}
+	for _, key := range *index {
+		if isDesired[key] {
+			continue
+		}
+		if err := deleteNetworkAttachmentDefinition(ctx, c, key); err != nil {
+			log.V(consts.LogLevelWarning).Info("Failed to delete NetworkAttachmentDefinition of the network",
+				"networkAttachmentDefinition", key, "error:", err)
+			desired = append(desired, key)
+		}
+	}
+	*index = desired
+}
+
+// addNetworkFinalizer adds consts.NetworkFinalizer to the network, so that its NetworkAttachmentDefinitions in all
+// namespaces are deleted before the network is removed
+func addNetworkFinalizer(ctx context.Context, c client.Client, network client.Object) error {
+	if controllerutil.ContainsFinalizer(network, consts.NetworkFinalizer) {
+		return nil
+	}
+	controllerutil.AddFinalizer(network, consts.NetworkFinalizer)
+	return c.Update(ctx, network)
+}
+
+// handleNetworkTeardown deletes the NetworkAttachmentDefinitions of the removed network which are recorded in
+// the index or are in the desired namespaces, in case the index wasn't stored yet, and removes
+// consts.NetworkFinalizer afterwards. Owner references are not relied upon, as the NetworkAttachmentDefinitions are in
+// other namespaces
+func handleNetworkTeardown(ctx context.Context, c client.Client, network client.Object, index []string,
+	namespaces []string) error {
+	if !controllerutil.ContainsFinalizer(network, consts.NetworkFinalizer) {
+		return nil
+	}
+	keys := append(networkAttachmentDefinitionKeys(network.GetName(), namespaces), index...)
+	for _, key := range keys {
+		if err := deleteNetworkAttachmentDefinition(ctx, c, key); err != nil {
+			return errors.Wrapf(err, "failed to delete NetworkAttachmentDefinition %s", key)
+		}
+	}
+	controllerutil.RemoveFinalizer(network, consts.NetworkFinalizer)
+	if err := c.Update(ctx, network); err != nil {
+		return errors.Wrap(err, "failed to remove finalizer")
+	}
+	return nil
+}
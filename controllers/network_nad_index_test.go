@@ -0,0 +1,89 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	netattdefv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/consts"
+)
+
+var _ = Describe("NetworkAttachmentDefinition index", func() {
+	var fakeClient client.Client
+
+	netAttachDef := func(namespace string) *netattdefv1.NetworkAttachmentDefinition {
+		return &netattdefv1.NetworkAttachmentDefinition{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "macvlan"}}
+	}
+	exists := func(namespace string) bool {
+		err := fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: "macvlan"},
+			&netattdefv1.NetworkAttachmentDefinition{})
+		if apierrors.IsNotFound(err) {
+			return false
+		}
+		Expect(err).NotTo(HaveOccurred())
+		return true
+	}
+
+	BeforeEach(func() {
+		s := runtime.NewScheme()
+		Expect(mellanoxv1alpha1.AddToScheme(s)).To(Succeed())
+		Expect(netattdefv1.AddToScheme(s)).To(Succeed())
+		fakeClient = fake.NewClientBuilder().WithScheme(s).
+			WithObjects(netAttachDef("ns1"), netAttachDef("ns2"), netAttachDef("ns3")).Build()
+	})
+
+	It("should delete the NetworkAttachmentDefinitions which are no longer desired", func() {
+		index := []string{"ns1/macvlan", "ns2/macvlan"}
+		updateNetworkAttachmentDefinitionIndex(context.TODO(), fakeClient, zap.New(), &index, "macvlan",
+			[]string{"ns1", "ns3"})
+
+		Expect(index).To(Equal([]string{"ns1/macvlan", "ns3/macvlan"}))
+		Expect(exists("ns1")).To(BeTrue())
+		Expect(exists("ns2")).To(BeFalse())
+		Expect(exists("ns3")).To(BeTrue())
+	})
+
+	It("should delete all NetworkAttachmentDefinitions of the removed network and remove the finalizer", func() {
+		network := &mellanoxv1alpha1.MacvlanNetwork{ObjectMeta: metav1.ObjectMeta{Name: "macvlan"}}
+		Expect(fakeClient.Create(context.TODO(), network)).To(Succeed())
+		Expect(addNetworkFinalizer(context.TODO(), fakeClient, network)).To(Succeed())
+		Expect(network.Finalizers).To(ConsistOf(consts.NetworkFinalizer))
+
+		Expect(handleNetworkTeardown(context.TODO(), fakeClient, network,
+			[]string{"ns1/macvlan", "ns2/macvlan"}, []string{"ns3"})).To(Succeed())
+		Expect(exists("ns1")).To(BeFalse())
+		Expect(exists("ns2")).To(BeFalse())
+		Expect(exists("ns3")).To(BeFalse())
+
+		updated := &mellanoxv1alpha1.MacvlanNetwork{}
+		Expect(fakeClient.Get(context.TODO(), types.NamespacedName{Name: "macvlan"}, updated)).To(Succeed())
+		Expect(updated.Finalizers).To(BeEmpty())
+	})
+})
@@ -0,0 +1,74 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/state"
+)
+
+// networkNamespaceMissingError describes the missing network namespace in the CR status
+func networkNamespaceMissingError(namespace string) error {
+	return fmt.Errorf("network namespace %s doesn't exist", namespace)
+}
+
+// ensureNetworkNamespace returns false and sets consts.NetworkNamespaceMissingCondition in the conditions
+// if the network namespace doesn't exist, the condition is removed once the namespace exists.
+// The missing namespace is created instead if create is set, it gets the managed labels and annotations.
+// The created namespace is deliberately kept when the network is deleted, as deleting it would delete the
+// workloads of the users in it, the managed labels identify it for a manual cleanup
+func ensureNetworkNamespace(ctx context.Context, c client.Client, namespace string, create bool,
+	conditions *[]metav1.Condition) (bool, error) {
+	err := c.Get(ctx, types.NamespacedName{Name: namespace}, &corev1.Namespace{})
+	if apiErrors.IsNotFound(err) && create {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		ns.Labels = state.MergeManagedLabels(nil)
+		if annotations := state.MergeManagedAnnotations(nil); len(annotations) != 0 {
+			ns.Annotations = annotations
+		}
+		err = c.Create(ctx, ns)
+		if apiErrors.IsAlreadyExists(err) {
+			err = nil
+		}
+	}
+	if err == nil {
+		if meta.FindStatusCondition(*conditions, consts.NetworkNamespaceMissingCondition) != nil {
+			meta.RemoveStatusCondition(conditions, consts.NetworkNamespaceMissingCondition)
+		}
+		return true, nil
+	}
+	if !apiErrors.IsNotFound(err) {
+		return false, err
+	}
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:    consts.NetworkNamespaceMissingCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "NamespaceNotFound",
+		Message: networkNamespaceMissingError(namespace).Error(),
+	})
+	return false, nil
+}
@@ -0,0 +1,83 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/Mellanox/network-operator/pkg/config"
+	"github.com/Mellanox/network-operator/pkg/consts"
+)
+
+var _ = Describe("Network namespace check", func() {
+	It("should report the missing namespace until it exists", func() {
+		fakeClient := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+		var conditions []metav1.Condition
+
+		exists, err := ensureNetworkNamespace(context.TODO(), fakeClient, "ipoib", false, &conditions)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exists).To(BeFalse())
+		condition := meta.FindStatusCondition(conditions, consts.NetworkNamespaceMissingCondition)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Message).To(Equal("network namespace ipoib doesn't exist"))
+
+		Expect(fakeClient.Create(context.TODO(),
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ipoib"}})).To(Succeed())
+		exists, err = ensureNetworkNamespace(context.TODO(), fakeClient, "ipoib", false, &conditions)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exists).To(BeTrue())
+		Expect(conditions).To(BeEmpty())
+	})
+
+	It("should create the missing namespace if requested", func() {
+		fakeClient := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+		var conditions []metav1.Condition
+
+		exists, err := ensureNetworkNamespace(context.TODO(), fakeClient, "ipoib", true, &conditions)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exists).To(BeTrue())
+		Expect(conditions).To(BeEmpty())
+		namespace := &corev1.Namespace{}
+		Expect(fakeClient.Get(context.TODO(), types.NamespacedName{Name: "ipoib"}, namespace)).To(Succeed())
+		Expect(namespace.Labels).To(HaveKeyWithValue(consts.ManagedByLabel, consts.ManagedByLabelValue))
+	})
+
+	It("should set the generated object annotations on the created namespace", func() {
+		savedAnnotations := config.FromEnv().State.GeneratedObjectAnnotations
+		defer func() { config.FromEnv().State.GeneratedObjectAnnotations = savedAnnotations }()
+		config.FromEnv().State.GeneratedObjectAnnotations = []string{"example.com/owner=network-team"}
+		fakeClient := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+		var conditions []metav1.Condition
+
+		exists, err := ensureNetworkNamespace(context.TODO(), fakeClient, "ipoib", true, &conditions)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exists).To(BeTrue())
+		namespace := &corev1.Namespace{}
+		Expect(fakeClient.Get(context.TODO(), types.NamespacedName{Name: "ipoib"}, namespace)).To(Succeed())
+		Expect(namespace.Annotations).To(HaveKeyWithValue("example.com/owner", "network-team"))
+	})
+})
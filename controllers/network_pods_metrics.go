@@ -0,0 +1,209 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	netattdefv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/state"
+)
+
+// networkPodsListLimit is the page size of the pod list requests
+const networkPodsListLimit = 500
+
+// networkAttachedPodsGauge is set to the number of running pods which request the network
+var networkAttachedPodsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "network_operator_network_attached_pods",
+	Help: "Number of running pods which request the network in the k8s.v1.cni.cncf.io/networks annotation",
+}, []string{"kind", "network"})
+
+func init() {
+	metrics.Registry.MustRegister(networkAttachedPodsGauge)
+}
+
+// networkCR identifies the network CR which generates a NetworkAttachmentDefinition
+type networkCR struct {
+	kind string
+	name string
+}
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=list
+
+// NetworkPodsCollector periodically counts the running pods attached to MacvlanNetworks and HostDeviceNetworks
+// through their NetworkAttachmentDefinitions. Pods are read directly from the API server page by page,
+// so that the operator doesn't cache all pods of the cluster
+type NetworkPodsCollector struct {
+	// Client reads the network CRs
+	Client client.Client
+	// PodReader lists the pods, e.g. the API reader of the manager
+	PodReader client.Reader
+	Log       logr.Logger
+	Interval  time.Duration
+}
+
+// Start updates the metrics every Interval until the context is canceled, it implements manager.Runnable
+func (c *NetworkPodsCollector) Start(ctx context.Context) error {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		c.update(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// update counts the pods attached to each network CR, metrics of removed network CRs are removed
+func (c *NetworkPodsCollector) update(ctx context.Context) {
+	// map NetworkAttachmentDefinition to the kind and the name of the network CR
+	networks := make(map[types.NamespacedName]networkCR)
+	macvlanNetworks := &mellanoxv1alpha1.MacvlanNetworkList{}
+	if err := c.Client.List(ctx, macvlanNetworks); err != nil {
+		c.Log.V(consts.LogLevelWarning).Info("Failed to list MacvlanNetworks for metrics", "error:", err)
+		return
+	}
+	for i := range macvlanNetworks.Items {
+		cr := &macvlanNetworks.Items[i]
+		// a MacvlanNetwork creates its NetworkAttachmentDefinition in the target namespaces as well
+		for _, namespace := range state.MacvlanNetworkNamespaces(cr) {
+			networks[types.NamespacedName{Namespace: namespace, Name: cr.Name}] =
+				networkCR{kind: "MacvlanNetwork", name: cr.Name}
+		}
+	}
+	hostDeviceNetworks := &mellanoxv1alpha1.HostDeviceNetworkList{}
+	if err := c.Client.List(ctx, hostDeviceNetworks); err != nil {
+		c.Log.V(consts.LogLevelWarning).Info("Failed to list HostDeviceNetworks for metrics", "error:", err)
+		return
+	}
+	for i := range hostDeviceNetworks.Items {
+		cr := &hostDeviceNetworks.Items[i]
+		networks[types.NamespacedName{Namespace: networkNamespaceOrDefault(cr.Spec.NetworkNamespace), Name: cr.Name}] =
+			networkCR{kind: "HostDeviceNetwork", name: cr.Name}
+	}
+
+	counts := make(map[networkCR]int, len(networks))
+	for _, network := range networks {
+		counts[network] = 0
+	}
+	if len(networks) > 0 {
+		err := c.countPods(ctx, func(pod *corev1.Pod) {
+			for _, attachment := range podNetworks(pod) {
+				if network, ok := networks[attachment]; ok {
+					counts[network]++
+				}
+			}
+		})
+		if err != nil {
+			c.Log.V(consts.LogLevelWarning).Info("Failed to list pods for network metrics", "error:", err)
+			return
+		}
+	}
+
+	networkAttachedPodsGauge.Reset()
+	for network, count := range counts {
+		networkAttachedPodsGauge.WithLabelValues(network.kind, network.name).Set(float64(count))
+	}
+}
+
+// countPods calls the function for each running pod in the cluster
+func (c *NetworkPodsCollector) countPods(ctx context.Context, count func(pod *corev1.Pod)) error {
+	opts := []client.ListOption{
+		client.MatchingFields{"status.phase": string(corev1.PodRunning)}, client.Limit(networkPodsListLimit)}
+	continueToken := ""
+	for {
+		podList := &corev1.PodList{}
+		if err := c.PodReader.List(ctx, podList, append(opts, client.Continue(continueToken))...); err != nil {
+			return err
+		}
+		for i := range podList.Items {
+			count(&podList.Items[i])
+		}
+		continueToken = podList.Continue
+		if continueToken == "" {
+			return nil
+		}
+	}
+}
+
+// podNetworks returns the NetworkAttachmentDefinitions requested in the k8s.v1.cni.cncf.io/networks annotation
+// of the pod, each network is returned once even if the pod requests several interfaces in it
+func podNetworks(pod *corev1.Pod) []types.NamespacedName {
+	annotation := pod.Annotations[netattdefv1.NetworkAttachmentAnnot]
+	if annotation == "" {
+		return nil
+	}
+	elements, err := parseNetworkAnnotation(annotation)
+	if err != nil {
+		return nil
+	}
+	seen := make(map[types.NamespacedName]bool, len(elements))
+	result := make([]types.NamespacedName, 0, len(elements))
+	for _, element := range elements {
+		name := types.NamespacedName{Namespace: element.Namespace, Name: element.Name}
+		if name.Namespace == "" {
+			name.Namespace = pod.Namespace
+		}
+		if !seen[name] {
+			seen[name] = true
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// parseNetworkAnnotation parses the k8s.v1.cni.cncf.io/networks annotation, either a JSON list of network selection
+// elements or a comma separated list of [<namespace>/]<network name>[@<interface name>]
+func parseNetworkAnnotation(annotation string) ([]netattdefv1.NetworkSelectionElement, error) {
+	var elements []netattdefv1.NetworkSelectionElement
+	if strings.ContainsAny(annotation, "[{\"") {
+		if err := json.Unmarshal([]byte(annotation), &elements); err != nil {
+			return nil, fmt.Errorf("failed to parse networks annotation: %v", err)
+		}
+		return elements, nil
+	}
+	for _, item := range strings.Split(annotation, ",") {
+		item = strings.TrimSpace(item)
+		if i := strings.Index(item, "@"); i >= 0 {
+			item = item[:i]
+		}
+		element := netattdefv1.NetworkSelectionElement{Name: item}
+		if i := strings.Index(item, "/"); i >= 0 {
+			element.Namespace, element.Name = item[:i], item[i+1:]
+		}
+		if element.Name == "" {
+			return nil, fmt.Errorf("invalid network %q in networks annotation", item)
+		}
+		elements = append(elements, element)
+	}
+	return elements, nil
+}
@@ -0,0 +1,97 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+)
+
+var _ = Describe("Network pods metrics", func() {
+	newPod := func(name, namespace, networks string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace,
+				Annotations: map[string]string{"k8s.v1.cni.cncf.io/networks": networks}},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+	}
+
+	It("should parse networks annotation", func() {
+		pod := newPod("pod", "ns", "macvlan@net1, other/hostdev, macvlan@net2")
+		Expect(podNetworks(pod)).To(Equal([]types.NamespacedName{
+			{Namespace: "ns", Name: "macvlan"}, {Namespace: "other", Name: "hostdev"}}))
+
+		pod = newPod("pod", "ns", `[{"name": "macvlan", "interface": "net1"}, {"name": "hostdev", "namespace": "other"}]`)
+		Expect(podNetworks(pod)).To(Equal([]types.NamespacedName{
+			{Namespace: "ns", Name: "macvlan"}, {Namespace: "other", Name: "hostdev"}}))
+
+		Expect(podNetworks(newPod("pod", "ns", ""))).To(BeEmpty())
+		Expect(podNetworks(newPod("pod", "ns", "[invalid"))).To(BeEmpty())
+	})
+
+	It("should count running pods attached to network CRs", func() {
+		s := scheme.Scheme
+		Expect(mellanoxv1alpha1.AddToScheme(s)).To(Succeed())
+		objects := []client.Object{
+			&mellanoxv1alpha1.MacvlanNetwork{ObjectMeta: metav1.ObjectMeta{Name: "macvlan"}},
+			&mellanoxv1alpha1.HostDeviceNetwork{ObjectMeta: metav1.ObjectMeta{Name: "hostdev"},
+				Spec: mellanoxv1alpha1.HostDeviceNetworkSpec{NetworkNamespace: "other"}},
+			newPod("a", "default", "macvlan"),
+			newPod("b", "default", "macvlan,other/hostdev"),
+			newPod("c", "default", "hostdev"),
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(s).WithObjects(objects...).Build()
+		collector := &NetworkPodsCollector{Client: fakeClient, PodReader: fakeClient, Log: ctrl.Log}
+		collector.update(context.TODO())
+
+		Expect(testutil.ToFloat64(networkAttachedPodsGauge.WithLabelValues("MacvlanNetwork", "macvlan"))).To(Equal(2.0))
+		Expect(testutil.ToFloat64(networkAttachedPodsGauge.WithLabelValues("HostDeviceNetwork", "hostdev"))).
+			To(Equal(1.0))
+	})
+
+	It("should count running pods attached to the target namespaces of MacvlanNetworks", func() {
+		s := scheme.Scheme
+		Expect(mellanoxv1alpha1.AddToScheme(s)).To(Succeed())
+		objects := []client.Object{
+			&mellanoxv1alpha1.MacvlanNetwork{ObjectMeta: metav1.ObjectMeta{Name: "macvlan-multi"},
+				Spec: mellanoxv1alpha1.MacvlanNetworkSpec{TargetNamespaces: []string{"team-a", "team-b"}}},
+			newPod("a", "default", "macvlan-multi"),
+			newPod("b", "team-a", "macvlan-multi"),
+			newPod("c", "team-b", "team-b/macvlan-multi"),
+			// the NetworkAttachmentDefinition isn't created in the namespace
+			newPod("d", "team-c", "macvlan-multi"),
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(s).WithObjects(objects...).Build()
+		collector := &NetworkPodsCollector{Client: fakeClient, PodReader: fakeClient, Log: ctrl.Log}
+		collector.update(context.TODO())
+
+		Expect(testutil.ToFloat64(networkAttachedPodsGauge.WithLabelValues("MacvlanNetwork", "macvlan-multi"))).
+			To(Equal(3.0))
+	})
+})
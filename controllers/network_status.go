@@ -0,0 +1,48 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	mellanoxcomv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/state"
+)
+
+// defaultNetworkNamespace is used for the NetworkAttachmentDefinition if the network namespace is not set
+const defaultNetworkNamespace = "default"
+
+// networkNamespaceOrDefault returns the namespace of the NetworkAttachmentDefinition generated from the network CR
+func networkNamespaceOrDefault(namespace string) string {
+	if namespace == "" {
+		return defaultNetworkNamespace
+	}
+	return namespace
+}
+
+// networkAttachmentDefinitionStatus returns the status of the NetworkAttachmentDefinition generated
+// from the network CR
+func networkAttachmentDefinitionStatus(name, namespace string, syncState mellanoxcomv1alpha1.State,
+	syncError error) *mellanoxcomv1alpha1.NetworkAttachmentDefinitionStatus {
+	status := &mellanoxcomv1alpha1.NetworkAttachmentDefinitionStatus{
+		Name:      name,
+		Namespace: namespace,
+		Ready:     syncState == state.SyncStateReady,
+	}
+	if syncError != nil {
+		status.Error = syncError.Error()
+	}
+	return status
+}
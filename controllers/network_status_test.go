@@ -0,0 +1,59 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+
+	netattdefv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/state"
+)
+
+var _ = Describe("Network status", func() {
+	It("should report the NetworkAttachmentDefinition status", func() {
+		Expect(networkAttachmentDefinitionStatus("net", "ns", mellanoxv1alpha1.StateReady, nil)).To(Equal(
+			&mellanoxv1alpha1.NetworkAttachmentDefinitionStatus{Name: "net", Namespace: "ns", Ready: true}))
+		Expect(networkAttachmentDefinitionStatus("net", "ns", mellanoxv1alpha1.StateError,
+			errors.New("invalid IPAM"))).To(Equal(&mellanoxv1alpha1.NetworkAttachmentDefinitionStatus{
+			Name: "net", Namespace: "ns", Ready: false, Error: "invalid IPAM"}))
+	})
+
+	It("should report the NetworkAttachmentDefinition of a HostDeviceNetwork in the default namespace", func() {
+		cr := &mellanoxv1alpha1.HostDeviceNetwork{ObjectMeta: metav1.ObjectMeta{Name: "hostdev"}}
+		netAttachDef := &netattdefv1.NetworkAttachmentDefinition{
+			ObjectMeta: metav1.ObjectMeta{Name: "hostdev", Namespace: "default"}}
+		s := scheme.Scheme
+		Expect(mellanoxv1alpha1.AddToScheme(s)).To(Succeed())
+		Expect(netattdefv1.AddToScheme(s)).To(Succeed())
+		fakeClient := fake.NewClientBuilder().WithScheme(s).WithObjects(cr, netAttachDef).Build()
+		reconciler := &HostDeviceNetworkReconciler{Client: fakeClient, Log: ctrl.Log}
+
+		reconciler.updateCrStatus(cr, state.Results{Status: state.SyncStateReady, StatesStatus: []state.Result{
+			{StateName: "state-host-device-network", Status: state.SyncStateReady}}})
+		Expect(cr.Status.NetworkAttachmentDefinition).To(Equal(&mellanoxv1alpha1.NetworkAttachmentDefinitionStatus{
+			Name: "hostdev", Namespace: "default", Ready: true}))
+		Expect(cr.Status.HostDeviceNetworkAttachmentDef).To(ContainSubstring("/namespaces/default/"))
+	})
+})
@@ -0,0 +1,294 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	netattdefv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/config"
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/state"
+)
+
+const (
+	diagnosticLabelKey      = "network-diagnostic.mellanox.com/name"
+	diagnosticPodRoleKey    = "network-diagnostic.mellanox.com/role"
+	diagnosticServerRole    = "server"
+	diagnosticClientRole    = "client"
+	diagnosticPingCount     = 3
+	diagnosticPingTimeout   = 5
+	diagnosticMessageMaxLen = 512
+)
+
+// NetworkDiagnosticReconciler reconciles a NetworkDiagnostic object
+type NetworkDiagnosticReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=mellanox.com,resources=networkdiagnostics,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=mellanox.com,resources=networkdiagnostics/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;delete
+
+// Reconcile runs the network check of the NetworkDiagnostic: the server pod is attached to the network,
+// then the client pod attached to the same network pings the server pod secondary network address.
+// The test pods are deleted once the check is completed.
+func (r *NetworkDiagnosticReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	reqLogger := r.Log.WithValues("networkdiagnostic", req.NamespacedName)
+	reqLogger.Info("Reconciling NetworkDiagnostic")
+
+	instance := &mellanoxv1alpha1.NetworkDiagnostic{}
+	err := r.Get(ctx, req.NamespacedName, instance)
+	if err != nil {
+		if apiErrors.IsNotFound(err) {
+			// Test pods are automatically garbage collected
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	if instance.Status.State == mellanoxv1alpha1.DiagnosticStatePassed ||
+		instance.Status.State == mellanoxv1alpha1.DiagnosticStateFailed {
+		// the check runs once, the NetworkDiagnostic should be recreated to run it again
+		return reconcile.Result{}, nil
+	}
+
+	deadline := instance.CreationTimestamp.Add(time.Duration(instance.Spec.TimeoutSeconds) * time.Second)
+	if instance.Spec.TimeoutSeconds > 0 && time.Now().After(deadline) {
+		return reconcile.Result{}, r.complete(ctx, instance, mellanoxv1alpha1.DiagnosticStateFailed,
+			fmt.Sprintf("check did not complete in %d seconds", instance.Spec.TimeoutSeconds))
+	}
+	requeue := reconcile.Result{
+		RequeueAfter: time.Duration(config.FromEnv().Controller.RequeueTimeSeconds) * time.Second,
+	}
+
+	server, err := r.getOrCreatePod(ctx, instance, r.serverPod(instance))
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if server.Status.Phase == corev1.PodFailed || server.Status.Phase == corev1.PodSucceeded {
+		return reconcile.Result{}, r.complete(ctx, instance, mellanoxv1alpha1.DiagnosticStateFailed,
+			fmt.Sprintf("server pod %s terminated: %s", server.Name, podTerminationMessage(server)))
+	}
+	serverIP := networkIP(server, networkReference(instance))
+	if server.Status.Phase != corev1.PodRunning || serverIP == "" {
+		return requeue, r.updateStatus(ctx, instance, mellanoxv1alpha1.DiagnosticStateRunning,
+			fmt.Sprintf("waiting for server pod %s to be attached to the network", server.Name))
+	}
+
+	clientPod, err := r.getOrCreatePod(ctx, instance, r.clientPod(instance, serverIP))
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	switch clientPod.Status.Phase {
+	case corev1.PodSucceeded:
+		return reconcile.Result{}, r.complete(ctx, instance, mellanoxv1alpha1.DiagnosticStatePassed,
+			fmt.Sprintf("client pod %s reached server pod %s at %s over network %s", clientPod.Name, server.Name,
+				serverIP, networkReference(instance)))
+	case corev1.PodFailed:
+		return reconcile.Result{}, r.complete(ctx, instance, mellanoxv1alpha1.DiagnosticStateFailed,
+			fmt.Sprintf("client pod %s failed to reach server pod %s at %s: %s", clientPod.Name, server.Name,
+				serverIP, podTerminationMessage(clientPod)))
+	}
+	return requeue, r.updateStatus(ctx, instance, mellanoxv1alpha1.DiagnosticStateRunning,
+		fmt.Sprintf("waiting for client pod %s to complete", clientPod.Name))
+}
+
+// getOrCreatePod returns the current state of the pod, the pod is created if it doesn't exist
+func (r *NetworkDiagnosticReconciler) getOrCreatePod(ctx context.Context, cr *mellanoxv1alpha1.NetworkDiagnostic,
+	pod *corev1.Pod) (*corev1.Pod, error) {
+	current := &corev1.Pod{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, current)
+	if err == nil {
+		return current, nil
+	}
+	if !apiErrors.IsNotFound(err) {
+		return nil, err
+	}
+	if err := controllerutil.SetControllerReference(cr, pod, r.Scheme); err != nil {
+		return nil, err
+	}
+	r.Log.V(consts.LogLevelInfo).Info("Creating test pod", "namespace", pod.Namespace, "name", pod.Name)
+	if err := r.Create(ctx, pod); err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+// complete deletes the test pods and reports the result of the check
+func (r *NetworkDiagnosticReconciler) complete(ctx context.Context, cr *mellanoxv1alpha1.NetworkDiagnostic,
+	state mellanoxv1alpha1.State, reason string) error {
+	for _, role := range []string{diagnosticServerRole, diagnosticClientRole} {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: diagnosticPodName(cr, role), Namespace: cr.Namespace}}
+		err := r.Delete(ctx, pod)
+		if err != nil && !apiErrors.IsNotFound(err) {
+			return err
+		}
+	}
+	now := metav1.Now()
+	cr.Status.CompletionTime = &now
+	return r.updateStatus(ctx, cr, state, reason)
+}
+
+func (r *NetworkDiagnosticReconciler) updateStatus(ctx context.Context, cr *mellanoxv1alpha1.NetworkDiagnostic,
+	state mellanoxv1alpha1.State, reason string) error {
+	if cr.Status.State == state && cr.Status.Reason == reason {
+		return nil
+	}
+	cr.Status.State = state
+	cr.Status.Reason = reason
+	r.Log.V(consts.LogLevelInfo).Info(
+		"Updating status", "Custom resource name", cr.Name, "namespace", cr.Namespace, "Result:", cr.Status)
+	err := r.Status().Update(ctx, cr)
+	if err != nil {
+		r.Log.V(consts.LogLevelError).Info("Failed to update CR status", "error:", err)
+	}
+	return err
+}
+
+func (r *NetworkDiagnosticReconciler) serverPod(cr *mellanoxv1alpha1.NetworkDiagnostic) *corev1.Pod {
+	return r.testPod(cr, diagnosticServerRole, []string{"sh", "-c", "trap 'exit 0' TERM INT; sleep infinity & wait"})
+}
+
+func (r *NetworkDiagnosticReconciler) clientPod(cr *mellanoxv1alpha1.NetworkDiagnostic, serverIP string) *corev1.Pod {
+	pod := r.testPod(cr, diagnosticClientRole, []string{"sh", "-c",
+		fmt.Sprintf("ip addr show && ping -c %d -W %d %s", diagnosticPingCount, diagnosticPingTimeout, serverIP)})
+	// prefer to run the client on another node, so the traffic goes through the network
+	pod.Spec.Affinity = &corev1.Affinity{
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+				Weight: 100,
+				PodAffinityTerm: corev1.PodAffinityTerm{
+					LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{
+						diagnosticLabelKey:   cr.Name,
+						diagnosticPodRoleKey: diagnosticServerRole,
+					}},
+					TopologyKey: corev1.LabelHostname,
+				},
+			}},
+		},
+	}
+	return pod
+}
+
+func (r *NetworkDiagnosticReconciler) testPod(cr *mellanoxv1alpha1.NetworkDiagnostic, role string,
+	command []string) *corev1.Pod {
+	container := corev1.Container{
+		Name:                     role,
+		Image:                    cr.Spec.Image,
+		Command:                  command,
+		TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+	}
+	if cr.Spec.ResourceName != "" {
+		resources := corev1.ResourceList{corev1.ResourceName(cr.Spec.ResourceName): resource.MustParse("1")}
+		container.Resources = corev1.ResourceRequirements{Requests: resources, Limits: resources}
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      diagnosticPodName(cr, role),
+			Namespace: cr.Namespace,
+			Labels: state.MergeManagedLabels(map[string]string{
+				diagnosticLabelKey:   cr.Name,
+				diagnosticPodRoleKey: role,
+			}),
+			Annotations: state.MergeManagedAnnotations(map[string]string{
+				netattdefv1.NetworkAttachmentAnnot: networkReference(cr),
+			}),
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers:    []corev1.Container{container},
+		},
+	}
+}
+
+func diagnosticPodName(cr *mellanoxv1alpha1.NetworkDiagnostic, role string) string {
+	return cr.Name + "-" + role
+}
+
+// networkReference returns the <namespace>/<name> reference of the tested NetworkAttachmentDefinition
+func networkReference(cr *mellanoxv1alpha1.NetworkDiagnostic) string {
+	namespace := cr.Spec.NetworkNamespace
+	if namespace == "" {
+		namespace = cr.Namespace
+	}
+	return namespace + "/" + cr.Spec.NetworkName
+}
+
+// networkIP returns the pod address on the network reported by multus in the network status annotation
+func networkIP(pod *corev1.Pod, network string) string {
+	annotation, ok := pod.Annotations[netattdefv1.NetworkStatusAnnot]
+	if !ok {
+		return ""
+	}
+	var statuses []netattdefv1.NetworkStatus
+	if err := json.Unmarshal([]byte(annotation), &statuses); err != nil {
+		return ""
+	}
+	for _, status := range statuses {
+		if status.Name == network && len(status.IPs) > 0 {
+			return status.IPs[0]
+		}
+	}
+	return ""
+}
+
+// podTerminationMessage returns the termination message of the pod container, it contains the end of
+// the container log if the container failed
+func podTerminationMessage(pod *corev1.Pod) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Terminated == nil {
+			continue
+		}
+		message := strings.TrimSpace(status.State.Terminated.Message)
+		if len(message) > diagnosticMessageMaxLen {
+			message = message[len(message)-diagnosticMessageMaxLen:]
+		}
+		if message == "" {
+			message = fmt.Sprintf("exit code %d", status.State.Terminated.ExitCode)
+		}
+		return message
+	}
+	return pod.Status.Reason
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NetworkDiagnosticReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&mellanoxv1alpha1.NetworkDiagnostic{}).
+		Owns(&corev1.Pod{}).
+		Complete(r)
+}
@@ -0,0 +1,57 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	netattdefv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+)
+
+var _ = Describe("NetworkDiagnostic Controller", func() {
+	cr := &mellanoxv1alpha1.NetworkDiagnostic{
+		ObjectMeta: metav1.ObjectMeta{Name: "diag", Namespace: "test"},
+		Spec: mellanoxv1alpha1.NetworkDiagnosticSpec{
+			NetworkName:  "macvlan",
+			ResourceName: "rdma/rdma_shared_device_a",
+			Image:        "mellanox/rping-test",
+		},
+	}
+
+	It("should attach test pods to the network", func() {
+		r := &NetworkDiagnosticReconciler{}
+		pod := r.clientPod(cr, "10.10.0.1")
+		Expect(pod.Name).To(Equal("diag-client"))
+		Expect(pod.Annotations[netattdefv1.NetworkAttachmentAnnot]).To(Equal("test/macvlan"))
+		Expect(pod.Spec.Containers[0].Command[2]).To(ContainSubstring("10.10.0.1"))
+		Expect(pod.Spec.Containers[0].Resources.Limits).To(HaveKey(corev1.ResourceName("rdma/rdma_shared_device_a")))
+		Expect(pod.Spec.Affinity.PodAntiAffinity).NotTo(BeNil())
+	})
+
+	It("should get pod address on the network", func() {
+		pod := &corev1.Pod{}
+		Expect(networkIP(pod, "test/macvlan")).To(BeEmpty())
+		pod.Annotations = map[string]string{netattdefv1.NetworkStatusAnnot: `[
+			{"name": "cbr0", "ips": ["10.244.1.5"], "default": true},
+			{"name": "test/macvlan", "interface": "net1", "ips": ["10.10.0.1"]}]`}
+		Expect(networkIP(pod, "test/macvlan")).To(Equal("10.10.0.1"))
+	})
+})
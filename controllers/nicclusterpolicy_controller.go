@@ -18,19 +18,27 @@ package controllers
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlbuilder "sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
@@ -39,6 +47,8 @@ import (
 	"github.com/Mellanox/network-operator/pkg/consts"
 	"github.com/Mellanox/network-operator/pkg/nodeinfo"
 	"github.com/Mellanox/network-operator/pkg/state"
+	"github.com/Mellanox/network-operator/pkg/upgrade"
+	"github.com/Mellanox/network-operator/pkg/utils"
 )
 
 // NicClusterPolicyReconciler reconciles a NicClusterPolicy object
@@ -48,8 +58,26 @@ type NicClusterPolicyReconciler struct {
 	Scheme *runtime.Scheme
 
 	stateManager state.Manager
+	// PrecompiledChecker looks up precompiled OFED driver packages, created in SetupWithManager if not set
+	PrecompiledChecker utils.PrecompiledPackageChecker
+	// RootCAs verify the HTTPS endpoints the operator connects to, e.g. a precompiled package repository
+	// with a private CA, the system CAs are used if not set
+	RootCAs *x509.CertPool
+	// PodReader lists the pods of the nodes while the policy is torn down, e.g. the API reader of the manager,
+	// so that the pods of the cluster are not cached. Client is used if not set
+	PodReader client.Reader
+	// Recorder records the events of the policy, created in SetupWithManager if not set
+	Recorder record.EventRecorder
+	// zeroResourcesSince is the time each node was first seen advertising zero resources of a device plugin,
+	// by node and device plugin DaemonSet name
+	zeroResourcesSince map[string]time.Time
 }
 
+const (
+	precompiledLookupTimeout  = 5 * time.Second
+	precompiledLookupCacheTTL = 10 * time.Minute
+)
+
 //nolint
 // +kubebuilder:rbac:groups=mellanox.com,resources=*,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=security.openshift.io,resources=securitycontextconstraints,verbs=get;list;watch;create;update;patch;delete
@@ -98,8 +126,47 @@ func (r *NicClusterPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		return reconcile.Result{}, err
 	}
 
+	if !instance.DeletionTimestamp.IsZero() {
+		return r.handleTeardown(ctx, instance, reqLogger)
+	}
+	if remaining := r.reconcilePause(ctx, instance); remaining > 0 {
+		// the policy is reconciled again when the pause ends or the annotation changes
+		return reconcile.Result{RequeueAfter: remaining}, nil
+	}
+	if !controllerutil.ContainsFinalizer(instance, consts.NicClusterPolicyFinalizer) {
+		controllerutil.AddFinalizer(instance, consts.NicClusterPolicyFinalizer)
+		if err := r.Update(ctx, instance); err != nil {
+			reqLogger.V(consts.LogLevelError).Info("Failed to add finalizer", "error:", err)
+			return reconcile.Result{}, err
+		}
+	}
+
+	if !r.validateSpec(ctx, instance) {
+		// the policy is reconciled again when the spec is fixed
+		return reconcile.Result{}, nil
+	}
+
+	err = r.acceptDriverRestartRequest(ctx, instance)
+	if err != nil {
+		reqLogger.V(consts.LogLevelError).Info("Failed to accept driver restart request", "error:", err)
+		return reconcile.Result{}, err
+	}
+
+	err = r.applyImageBundle(ctx, instance)
+	if err != nil {
+		reqLogger.V(consts.LogLevelError).Info("Failed to apply image bundle", "error:", err)
+		return reconcile.Result{}, err
+	}
+
+	err = r.applyDevicePluginConfigs(ctx, instance)
+	if err != nil {
+		reqLogger.V(consts.LogLevelError).Info("Failed to apply device plugin configs", "error:", err)
+		return reconcile.Result{}, err
+	}
+
 	// Create a new State service catalog
 	sc := state.NewInfoCatalog()
+	var nodePtrList []*corev1.Node
 	if instance.Spec.OFEDDriver != nil || instance.Spec.NVPeerDriver != nil ||
 		instance.Spec.RdmaSharedDevicePlugin != nil || instance.Spec.SriovDevicePlugin != nil {
 		// Create node infoProvider and add to the service catalog
@@ -111,16 +178,28 @@ func (r *NicClusterPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Req
 			reqLogger.V(consts.LogLevelError).Info("Error occurred on LIST nodes request from API server.", "error:", err)
 			return reconcile.Result{}, err
 		}
-		nodePtrList := make([]*corev1.Node, len(nodeList.Items))
-		nodeNames := make([]*string, len(nodeList.Items))
+		nodePtrList = make([]*corev1.Node, len(nodeList.Items))
 		for i := range nodePtrList {
 			nodePtrList[i] = &nodeList.Items[i]
-			nodeNames[i] = &nodeList.Items[i].Name
+		}
+		// the nodes excluded from the management of the operator are not considered by the states
+		nodePtrList = nodeinfo.NewNotExcludedNodeFilter().Apply(nodePtrList)
+		nodeNames := make([]*string, len(nodePtrList))
+		for i := range nodePtrList {
+			nodeNames[i] = &nodePtrList[i].Name
 		}
 		reqLogger.V(consts.LogLevelDebug).Info("Node info provider with", "Nodes:", nodeNames)
 		infoProvider := nodeinfo.NewProvider(nodePtrList)
 		sc.Add(state.InfoTypeNodeInfo, infoProvider)
 	}
+	r.updatePrecompiledCondition(instance, nodePtrList)
+
+	err = r.syncOfedNodeEnv(ctx, instance, nodePtrList)
+	if err != nil {
+		reqLogger.V(consts.LogLevelError).Info("Failed to sync OFED driver node env overrides", "error:", err)
+		return reconcile.Result{}, err
+	}
+
 	// Create manager
 	managerStatus, err := r.stateManager.SyncState(instance, sc)
 
@@ -128,12 +207,21 @@ func (r *NicClusterPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		reqLogger.V(consts.LogLevelWarning).Info("Error occurred while syncing states", "error:", err)
 	}
 
+	// the errors of the node labels are reported in the status together with the sync errors
+	labelsErr := r.updateNodeLabels(instance)
+	r.updateMinDriverVersionCondition(ctx, instance)
+	updatePermissionDeniedCondition(r.Log, &instance.Status.Conditions, managerStatus, err, labelsErr)
 	r.updateCrStatus(instance, managerStatus)
-
-	err = r.updateNodeLabels(instance)
-	if err != nil {
-		return reconcile.Result{}, err
+	r.updateOfedDriverMetrics(ctx, instance)
+	r.updateDaemonSetMetrics(ctx)
+	if labelsErr != nil {
+		return reconcile.Result{}, labelsErr
 	}
+	r.updateDriverReadyNodeConditions(ctx, instance)
+	r.updateDriverBuildSlots(ctx, instance)
+	r.updateDriverVersionNodeLabels(ctx, instance)
+	r.updateFirmwareNodeConditions(ctx, instance)
+	resourceDriftRequeue := r.repairDevicePluginResources(ctx, instance)
 
 	if managerStatus.Status != state.SyncStateReady {
 		return reconcile.Result{
@@ -141,13 +229,14 @@ func (r *NicClusterPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		}, nil
 	}
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: resourceDriftRequeue}, nil
 }
 
 // updateNodeLabels updates nodes labels to mark device plugins should wait for OFED pod
-// Set nvidia.com/ofed.wait=false if OFED is not deployed.
+// Set nvidia.com/ofed.wait=false if OFED is not deployed or disabled.
+// The label is kept set on nodes where the upgrade flow paused the device plugins before the drain.
 func (r *NicClusterPolicyReconciler) updateNodeLabels(cr *mellanoxv1alpha1.NicClusterPolicy) error {
-	if cr.Spec.OFEDDriver != nil {
+	if cr.Spec.OFEDDriver != nil && cr.Spec.OFEDDriver.IsEnabled() {
 		pods := &corev1.PodList{}
 		podLabel := "mofed-" + cr.Spec.OFEDDriver.Version
 		_ = r.Client.List(context.TODO(), pods, client.MatchingLabels{"driver-pod": podLabel})
@@ -156,7 +245,8 @@ func (r *NicClusterPolicyReconciler) updateNodeLabels(cr *mellanoxv1alpha1.NicCl
 			labelValue := "true"
 			// We assume that OFED pod contains only one container to simplify the logic.
 			// We can revisit this logic in the future if needed
-			if len(pod.Status.ContainerStatuses) != 0 && pod.Status.ContainerStatuses[0].Ready {
+			if len(pod.Status.ContainerStatuses) != 0 && pod.Status.ContainerStatuses[0].Ready &&
+				!r.devicePluginsPaused(pod.Spec.NodeName) {
 				labelValue = "false"
 			}
 			patch := []byte(fmt.Sprintf(`{"metadata":{"labels":{%q:%q}}}`, nodeinfo.NodeLabelWaitOFED, labelValue))
@@ -167,7 +257,7 @@ func (r *NicClusterPolicyReconciler) updateNodeLabels(cr *mellanoxv1alpha1.NicCl
 			}, client.RawPatch(types.StrategicMergePatchType, patch))
 
 			if err != nil {
-				return errors.New("unable to update node label")
+				return errors.Wrap(err, "unable to update node label")
 			}
 		}
 	} else {
@@ -175,14 +265,17 @@ func (r *NicClusterPolicyReconciler) updateNodeLabels(cr *mellanoxv1alpha1.NicCl
 		// We deploy OFED and Device plugins only on a nodes with Mellanox NICs
 		err := r.Client.List(context.TODO(), nodes, client.MatchingLabels{nodeinfo.NodeLabelMlnxNIC: "true"})
 		if err != nil {
-			return errors.New("unable to get nodes")
+			return errors.Wrap(err, "unable to get nodes")
 		}
 
 		for i := range nodes.Items {
+			if nodeinfo.IsNodeExcluded(&nodes.Items[i]) {
+				continue
+			}
 			nodes.Items[i].Labels[nodeinfo.NodeLabelWaitOFED] = "false"
 			err = r.Client.Update(context.TODO(), &nodes.Items[i])
 			if err != nil {
-				return errors.New("unable to update node label")
+				return errors.Wrap(err, "unable to update node label")
 			}
 		}
 	}
@@ -190,20 +283,169 @@ func (r *NicClusterPolicyReconciler) updateNodeLabels(cr *mellanoxv1alpha1.NicCl
 	return nil
 }
 
+// devicePluginsPaused returns true if the upgrade flow removed the device plugins from the node
+func (r *NicClusterPolicyReconciler) devicePluginsPaused(nodeName string) bool {
+	node := &corev1.Node{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: nodeName}, node); err != nil {
+		return false
+	}
+	_, paused := node.Annotations[upgrade.UpgradeDevicePluginsPausedAnnotation]
+	return paused
+}
+
+// applyImageBundle overrides component images in the NicClusterPolicy with images
+// from the referenced image bundle ConfigMap. The NicClusterPolicy is modified in memory only.
+func (r *NicClusterPolicyReconciler) applyImageBundle(
+	ctx context.Context, cr *mellanoxv1alpha1.NicClusterPolicy) error {
+	if cr.Spec.ImageBundle == nil || cr.Spec.ImageBundle.Name == "" {
+		return nil
+	}
+	configMap := &corev1.ConfigMap{}
+	key := types.NamespacedName{
+		Namespace: config.FromEnv().State.NetworkOperatorResourceNamespace,
+		Name:      cr.Spec.ImageBundle.Name,
+	}
+	err := r.Get(ctx, key, configMap)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get image bundle ConfigMap %s", key)
+	}
+	return state.ApplyImageBundle(cr, configMap.Data)
+}
+
+// applyDevicePluginConfigs resolves the DevicePluginConfig CRs referenced by the device plugins and merges
+// the resource pools contributed by DevicePluginConfig CRs into the NicClusterPolicy, the result is reported
+// in the status of each DevicePluginConfig. The NicClusterPolicy is modified in memory only.
+// A device plugin whose DevicePluginConfig can't be resolved is reported by its state, the other states are synced.
+func (r *NicClusterPolicyReconciler) applyDevicePluginConfigs(
+	ctx context.Context, cr *mellanoxv1alpha1.NicClusterPolicy) error {
+	configList := &mellanoxv1alpha1.DevicePluginConfigList{}
+	if err := r.List(ctx, configList); err != nil {
+		return errors.Wrap(err, "failed to list DevicePluginConfigs")
+	}
+	statuses, applyErr := state.ApplyDevicePluginConfigs(cr, configList.Items)
+	for i := range configList.Items {
+		dpConfig := &configList.Items[i]
+		status := statuses[dpConfig.Name]
+		if dpConfig.Status == status {
+			continue
+		}
+		if status.State == mellanoxv1alpha1.StateError {
+			r.Log.V(consts.LogLevelWarning).Info("DevicePluginConfig is not merged",
+				"name", dpConfig.Name, "reason", status.Reason)
+		}
+		dpConfig.Status = status
+		if err := r.Status().Update(ctx, dpConfig); err != nil {
+			// the pools are merged regardless of the status update
+			r.Log.V(consts.LogLevelError).Info("Failed to update DevicePluginConfig status",
+				"name", dpConfig.Name, "error:", err)
+		}
+	}
+	if applyErr != nil {
+		r.Log.V(consts.LogLevelWarning).Info("Device plugin configs are not applied", "error:", applyErr)
+	}
+	return nil
+}
+
+// devicePluginConfigToPolicy maps a DevicePluginConfig to the NicClusterPolicy which merges or references it
+func (r *NicClusterPolicyReconciler) devicePluginConfigToPolicy(_ client.Object) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: consts.NicClusterPolicyResourceName}}}
+}
+
+// updatePrecompiledCondition sets consts.PrecompiledPackageMissingCondition in the NicClusterPolicy status
+// if no precompiled OFED driver package matches the kernel of some nodes, the status is updated with CR status
+func (r *NicClusterPolicyReconciler) updatePrecompiledCondition(
+	cr *mellanoxv1alpha1.NicClusterPolicy, nodes []*corev1.Node) {
+	if cr.Spec.OFEDDriver == nil || !cr.Spec.OFEDDriver.UsePrecompiled ||
+		cr.Spec.OFEDDriver.PrecompiledRepository == "" {
+		meta.RemoveStatusCondition(&cr.Status.Conditions, consts.PrecompiledPackageMissingCondition)
+		return
+	}
+	// map kernel version to the names of the nodes running it
+	kernelNodes := make(map[string][]string)
+	for _, node := range nodes {
+		kernel, ok := node.Labels[nodeinfo.NodeLabelKernelVerFull]
+		if !ok {
+			continue
+		}
+		kernelNodes[kernel] = append(kernelNodes[kernel], node.Name)
+	}
+	kernels := make([]string, 0, len(kernelNodes))
+	for kernel := range kernelNodes {
+		kernels = append(kernels, kernel)
+	}
+	sort.Strings(kernels)
+
+	var missing []string
+	for _, kernel := range kernels {
+		available, err := r.PrecompiledChecker.IsAvailable(
+			cr.Spec.OFEDDriver.PrecompiledRepository, cr.Spec.OFEDDriver.Version, kernel)
+		if err != nil {
+			// keep the current condition if the repository can't be reached
+			r.Log.V(consts.LogLevelWarning).Info("Failed to look up precompiled OFED driver packages",
+				"kernel", kernel, "error:", err)
+			return
+		}
+		if !available {
+			missing = append(missing, fmt.Sprintf("%s (nodes: %s)", kernel, strings.Join(kernelNodes[kernel], ", ")))
+		}
+	}
+
+	if len(missing) == 0 {
+		meta.RemoveStatusCondition(&cr.Status.Conditions, consts.PrecompiledPackageMissingCondition)
+		return
+	}
+	r.Log.V(consts.LogLevelWarning).Info("No precompiled OFED driver package matches node kernels",
+		"kernels", missing)
+	meta.SetStatusCondition(&cr.Status.Conditions, metav1.Condition{
+		Type:    consts.PrecompiledPackageMissingCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "KernelNotSupported",
+		Message: fmt.Sprintf("no precompiled OFED driver package for kernels: %s", strings.Join(missing, "; ")),
+	})
+}
+
+// imageBundleToPolicy maps an image bundle ConfigMap to the NicClusterPolicy which references it
+func (r *NicClusterPolicyReconciler) imageBundleToPolicy(obj client.Object) []reconcile.Request {
+	if obj.GetNamespace() != config.FromEnv().State.NetworkOperatorResourceNamespace {
+		return nil
+	}
+	cr := &mellanoxv1alpha1.NicClusterPolicy{}
+	err := r.Get(context.TODO(), types.NamespacedName{Name: consts.NicClusterPolicyResourceName}, cr)
+	if err != nil {
+		return nil
+	}
+	if cr.Spec.ImageBundle == nil || cr.Spec.ImageBundle.Name != obj.GetName() {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: cr.Name}}}
+}
+
 //nolint:dupl
 func (r *NicClusterPolicyReconciler) updateCrStatus(cr *mellanoxv1alpha1.NicClusterPolicy, status state.Results) {
 NextResult:
 	for _, stateStatus := range status.StatesStatus {
+		message := ""
+		if len(stateStatus.BlockedBy) > 0 {
+			message = fmt.Sprintf("waiting for dependencies: %s", strings.Join(stateStatus.BlockedBy, ", "))
+		}
+		if stateStatus.Status == state.SyncStateDisabled {
+			message = "component is disabled in the spec"
+		}
+		if stateStatus.Status == state.SyncStateError && stateStatus.ErrInfo != nil {
+			message = stateStatus.ErrInfo.Error()
+		}
 		// basically iterate over results and add/update crStatus.AppliedStates
 		for i := range cr.Status.AppliedStates {
 			if cr.Status.AppliedStates[i].Name == stateStatus.StateName {
 				cr.Status.AppliedStates[i].State = mellanoxv1alpha1.State(stateStatus.Status)
+				cr.Status.AppliedStates[i].Message = message
 				continue NextResult
 			}
 		}
 		cr.Status.AppliedStates = append(cr.Status.AppliedStates, mellanoxv1alpha1.AppliedState{
-			Name:  stateStatus.StateName,
-			State: mellanoxv1alpha1.State(stateStatus.Status),
+			Name:    stateStatus.StateName,
+			State:   mellanoxv1alpha1.State(stateStatus.Status),
+			Message: message,
 		})
 	}
 	// Update global State
@@ -214,7 +456,7 @@ NextResult:
 		"Updating status", "Custom resource name", cr.Name, "namespace", cr.Namespace, "Result:", cr.Status)
 	err := r.Status().Update(context.TODO(), cr)
 	if err != nil {
-		r.Log.V(consts.LogLevelError).Info("Failed to update CR status", "error:", err)
+		logStatusUpdateError(r.Log, err)
 	}
 }
 
@@ -240,18 +482,37 @@ func (r *NicClusterPolicyReconciler) handleUnsupportedInstance(instance *mellano
 //nolint:dupl
 func (r *NicClusterPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	// Create state manager
-	stateManager, err := state.NewManager(mellanoxv1alpha1.NicClusterPolicyCRDName, mgr.GetClient(), mgr.GetScheme())
+	stateManager, err := state.NewManager(mellanoxv1alpha1.NicClusterPolicyCRDName, r.Client, mgr.GetScheme())
 	if err != nil {
 		// Error creating stateManager
 		r.Log.V(consts.LogLevelError).Info("Error creating state manager.", "error:", err)
 		panic("Failed to create State manager")
 	}
 	r.stateManager = stateManager
+	if r.PrecompiledChecker == nil {
+		r.PrecompiledChecker = utils.NewPrecompiledPackageChecker(
+			precompiledLookupTimeout, precompiledLookupCacheTTL, r.RootCAs)
+	}
+	if r.Recorder == nil {
+		r.Recorder = mgr.GetEventRecorderFor("nicclusterpolicy-controller")
+	}
 
 	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&mellanoxv1alpha1.NicClusterPolicy{}).
 		// Watch for changes to primary resource NicClusterPolicy
-		Watches(&source.Kind{Type: &mellanoxv1alpha1.NicClusterPolicy{}}, &handler.EnqueueRequestForObject{})
+		Watches(&source.Kind{Type: &mellanoxv1alpha1.NicClusterPolicy{}}, &handler.EnqueueRequestForObject{}).
+		// Watch for changes to the image bundle ConfigMap referenced by NicClusterPolicy
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.imageBundleToPolicy)).
+		// Watch for changes to the spec of DevicePluginConfigs merged into or referenced by NicClusterPolicy,
+		// status updates are done by this controller and are ignored
+		Watches(&source.Kind{Type: &mellanoxv1alpha1.DevicePluginConfig{}},
+			handler.EnqueueRequestsFromMapFunc(r.devicePluginConfigToPolicy),
+			ctrlbuilder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// Watch for changes to the OFED driver env overrides of the nodes, to their exclusion and to their
+		// allocatable resources, which may drift after a kubelet restart
+		Watches(&source.Kind{Type: &corev1.Node{}}, handler.EnqueueRequestsFromMapFunc(r.nodeToPolicy),
+			ctrlbuilder.WithPredicates(
+				predicate.Or(ofedDriverEnvAnnotationChanged, excludedNodeLabelChanged, nodeAllocatableChanged)))
 
 	// Watch for changes to secondary resource DaemonSet and requeue the owner NicClusterPolicy
 	ws := stateManager.GetWatchSources()
@@ -0,0 +1,75 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/state"
+)
+
+var _ = Describe("NicClusterPolicy device plugin configs", func() {
+	It("should keep reconciling the policy if a referenced DevicePluginConfig doesn't exist", func() {
+		cr := &mellanoxv1alpha1.NicClusterPolicy{ObjectMeta: metav1.ObjectMeta{
+			Name: consts.NicClusterPolicyResourceName}}
+		image := mellanoxv1alpha1.ImageSpec{Image: "dp", Repository: "nvcr.io/mellanox", Version: "v1"}
+		cr.Spec.SriovDevicePlugin = &mellanoxv1alpha1.DevicePluginSpec{ImageSpec: image, ConfigRef: "missing"}
+		cr.Spec.RdmaSharedDevicePlugin = &mellanoxv1alpha1.RdmaSharedDevicePluginSpec{ImageSpec: image,
+			ConfigRef: "rdma-dp-config"}
+		rdmaConfig := &mellanoxv1alpha1.DevicePluginConfig{ObjectMeta: metav1.ObjectMeta{Name: "rdma-dp-config"}}
+		rdmaConfig.Spec.Config = `{"configList": []}`
+
+		s := scheme.Scheme
+		Expect(mellanoxv1alpha1.AddToScheme(s)).To(Succeed())
+		fakeClient := fake.NewClientBuilder().WithScheme(s).WithObjects(cr, rdmaConfig).Build()
+		reconciler := &NicClusterPolicyReconciler{Client: fakeClient, Log: ctrl.Log}
+
+		Expect(reconciler.applyDevicePluginConfigs(context.TODO(), cr)).To(Succeed())
+		Expect(cr.Spec.RdmaSharedDevicePlugin.Config).To(Equal(`{"configList": []}`))
+		Expect(cr.Spec.SriovDevicePlugin.Config).To(BeEmpty())
+	})
+
+	It("should report the error of a state in its applied state", func() {
+		cr := &mellanoxv1alpha1.NicClusterPolicy{ObjectMeta: metav1.ObjectMeta{
+			Name: consts.NicClusterPolicyResourceName}}
+		s := scheme.Scheme
+		Expect(mellanoxv1alpha1.AddToScheme(s)).To(Succeed())
+		fakeClient := fake.NewClientBuilder().WithScheme(s).WithObjects(cr).Build()
+		reconciler := &NicClusterPolicyReconciler{Client: fakeClient, Log: ctrl.Log}
+
+		reconciler.updateCrStatus(cr, state.Results{Status: state.SyncStateError, StatesStatus: []state.Result{
+			{StateName: "state-SRIOV-device-plugin", Status: state.SyncStateError,
+				ErrInfo: errors.New("DevicePluginConfig missing referenced by sriovDevicePlugin doesn't exist")},
+			{StateName: "state-OFED", Status: state.SyncStateReady},
+		}})
+		Expect(cr.Status.AppliedStates).To(Equal([]mellanoxv1alpha1.AppliedState{
+			{Name: "state-SRIOV-device-plugin", State: mellanoxv1alpha1.StateError,
+				Message: "DevicePluginConfig missing referenced by sriovDevicePlugin doesn't exist"},
+			{Name: "state-OFED", State: mellanoxv1alpha1.StateReady},
+		}))
+	})
+})
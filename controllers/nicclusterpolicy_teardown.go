@@ -0,0 +1,144 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/config"
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+	"github.com/Mellanox/network-operator/pkg/state"
+)
+
+// teardownTimeoutEventReason is the reason of the warning event recorded on NicClusterPolicy when the teardown
+// stops waiting for the workloads which use the device plugin resources
+const teardownTimeoutEventReason = "TeardownTimeout"
+
+// handleTeardown removes the device plugins first so no new workloads are scheduled with device plugin resources,
+// then waits for the running workloads which use the resources to terminate, at most for the teardown timeout
+// since the deletion of the policy. The finalizer is removed afterwards and the rest of the components,
+// including OFED driver, are garbage collected.
+func (r *NicClusterPolicyReconciler) handleTeardown(ctx context.Context, cr *mellanoxv1alpha1.NicClusterPolicy,
+	reqLogger logr.Logger) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(cr, consts.NicClusterPolicyFinalizer) {
+		return reconcile.Result{}, nil
+	}
+	reqLogger.V(consts.LogLevelInfo).Info("Tearing down NicClusterPolicy")
+
+	// the resources of the pools and configs of DevicePluginConfigs are in use as well, they are resolved
+	// in a copy so that the finalizer update doesn't store them in the policy
+	resolved := cr.DeepCopy()
+	if err := r.applyDevicePluginConfigs(ctx, resolved); err != nil {
+		// the workloads of the resolved resources are still waited for
+		reqLogger.V(consts.LogLevelWarning).Info("Failed to apply device plugin configs", "error:", err)
+	}
+
+	namespace := config.FromEnv().State.NetworkOperatorResourceNamespace
+	for _, name := range state.DevicePluginDaemonSets(resolved) {
+		ds := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+		err := r.Delete(ctx, ds)
+		if err != nil && !apiErrors.IsNotFound(err) {
+			return reconcile.Result{}, errors.Wrapf(err, "failed to delete device plugin DaemonSet %s", name)
+		}
+	}
+
+	resourceNames, err := state.DevicePluginResourceNames(resolved)
+	if err != nil {
+		// workloads can't be detected, don't block the removal
+		reqLogger.V(consts.LogLevelWarning).Info("Failed to get device plugin resources", "error:", err)
+	}
+	if len(resourceNames) > 0 {
+		podsInUse, err := r.countPodsUsingResources(ctx, resourceNames)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		timeout := time.Duration(config.FromEnv().Controller.TeardownTimeoutSeconds) * time.Second
+		timedOut := timeout > 0 && cr.DeletionTimestamp != nil && time.Since(cr.DeletionTimestamp.Time) >= timeout
+		if podsInUse > 0 && timedOut {
+			reqLogger.V(consts.LogLevelWarning).Info(
+				"Teardown timed out, removing the components while pods still use device plugin resources",
+				"pods", podsInUse, "resources", resourceNames, "timeout", timeout)
+			if r.Recorder != nil {
+				r.Recorder.Eventf(cr, corev1.EventTypeWarning, teardownTimeoutEventReason,
+					"Removing the components after %s while %d pods still use device plugin resources",
+					timeout, podsInUse)
+			}
+		} else if podsInUse > 0 {
+			reqLogger.V(consts.LogLevelInfo).Info("Waiting for pods which use device plugin resources to terminate",
+				"pods", podsInUse, "resources", resourceNames)
+			cr.Status.State = mellanoxv1alpha1.StateNotReady
+			cr.Status.Reason = fmt.Sprintf("waiting for %d pods which use device plugin resources to terminate",
+				podsInUse)
+			if err := r.Status().Update(ctx, cr); err != nil {
+				r.Log.V(consts.LogLevelError).Info("Failed to update CR status", "error:", err)
+			}
+			return reconcile.Result{
+				RequeueAfter: time.Duration(config.FromEnv().Controller.RequeueTimeSeconds) * time.Second,
+			}, nil
+		}
+	}
+
+	// the OFED driver is garbage collected with the policy
+	r.setDriverVersionNodeLabels(ctx, nil)
+	controllerutil.RemoveFinalizer(cr, consts.NicClusterPolicyFinalizer)
+	if err := r.Update(ctx, cr); err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "failed to remove finalizer")
+	}
+	return reconcile.Result{}, nil
+}
+
+// countPodsUsingResources returns the number of pods which use the device plugin resources. Only the pods of
+// the nodes with Mellanox NICs, where the device plugins run, are listed, node by node with a field selector
+func (r *NicClusterPolicyReconciler) countPodsUsingResources(ctx context.Context, resourceNames []string) (int, error) {
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes, nodeinfo.MellanoxNICListOptions...); err != nil {
+		return 0, errors.Wrap(err, "failed to list nodes")
+	}
+	var podReader client.Reader = r.Client
+	if r.PodReader != nil {
+		podReader = r.PodReader
+	}
+	podsInUse := 0
+	for i := range nodes.Items {
+		pods := &corev1.PodList{}
+		err := podReader.List(ctx, pods, client.MatchingFields{"spec.nodeName": nodes.Items[i].Name})
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to list pods of node %s", nodes.Items[i].Name)
+		}
+		for j := range pods.Items {
+			if state.PodUsesResources(&pods.Items[j], resourceNames) {
+				podsInUse++
+			}
+		}
+	}
+	return podsInUse, nil
+}
@@ -0,0 +1,172 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/config"
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+)
+
+// nodePodReader lists the pods of a single node, the fake client ignores field selectors
+type nodePodReader struct {
+	client.Reader
+}
+
+func (r *nodePodReader) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	if listOpts.FieldSelector == nil {
+		return fmt.Errorf("pods must be listed by node")
+	}
+	nodeName, ok := listOpts.FieldSelector.RequiresExactMatch("spec.nodeName")
+	if !ok {
+		return fmt.Errorf("pods must be listed by node, got field selector %q", listOpts.FieldSelector)
+	}
+	if err := r.Reader.List(ctx, list); err != nil {
+		return err
+	}
+	pods := list.(*corev1.PodList)
+	items := pods.Items[:0]
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName == nodeName {
+			items = append(items, pod)
+		}
+	}
+	pods.Items = items
+	return nil
+}
+
+var _ = Describe("NicClusterPolicy teardown", func() {
+	newPod := func(name, resourceName string) *corev1.Pod {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+		pod.Spec.NodeName = "node1"
+		pod.Spec.Containers = []corev1.Container{{Name: "app", Resources: corev1.ResourceRequirements{
+			Limits: corev1.ResourceList{corev1.ResourceName(resourceName): resource.MustParse("1")}}}}
+		return pod
+	}
+	newNode := func(name string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name: name, Labels: map[string]string{nodeinfo.NodeLabelMlnxNIC: "true"}}}
+	}
+
+	It("should wait for the workloads of the resources defined in DevicePluginConfigs", func() {
+		now := metav1.NewTime(time.Now())
+		cr := &mellanoxv1alpha1.NicClusterPolicy{ObjectMeta: metav1.ObjectMeta{
+			Name:              consts.NicClusterPolicyResourceName,
+			Finalizers:        []string{consts.NicClusterPolicyFinalizer},
+			DeletionTimestamp: &now,
+		}}
+		image := mellanoxv1alpha1.ImageSpec{Image: "dp", Repository: "nvcr.io/mellanox", Version: "v1"}
+		cr.Spec.RdmaSharedDevicePlugin = &mellanoxv1alpha1.RdmaSharedDevicePluginSpec{ImageSpec: image,
+			ResourcePools: []mellanoxv1alpha1.RdmaSharedDevicePoolSpec{{Name: "pool_a", RdmaHcaMax: 63,
+				Selectors: mellanoxv1alpha1.RdmaSharedDevicePoolSelectors{IfNames: []string{"ens1f0"}}}}}
+		cr.Spec.SriovDevicePlugin = &mellanoxv1alpha1.DevicePluginSpec{ImageSpec: image, ConfigRef: "sriov-dp-config"}
+		sriovConfig := &mellanoxv1alpha1.DevicePluginConfig{ObjectMeta: metav1.ObjectMeta{Name: "sriov-dp-config"}}
+		sriovConfig.Spec.Config = `{"resourceList": [{"resourcePrefix": "nvidia.com", "resourceName": "hostdev"}]}`
+		teamConfig := &mellanoxv1alpha1.DevicePluginConfig{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}}
+		teamConfig.Spec.RdmaSharedResourcePools = []mellanoxv1alpha1.RdmaSharedDevicePoolSpec{{Name: "pool_b",
+			RdmaHcaMax: 63, Selectors: mellanoxv1alpha1.RdmaSharedDevicePoolSelectors{IfNames: []string{"ens1f1"}}}}
+		hostdevPod := newPod("hostdev-app", "nvidia.com/hostdev")
+		poolPod := newPod("pool-app", "rdma/pool_b")
+		// the pods of nodes without Mellanox NICs don't use the resources of the device plugins
+		otherPod := newPod("other-app", "rdma/pool_b")
+		otherPod.Spec.NodeName = "node2"
+
+		s := scheme.Scheme
+		Expect(mellanoxv1alpha1.AddToScheme(s)).To(Succeed())
+		fakeClient := fake.NewClientBuilder().WithScheme(s).
+			WithObjects(cr, sriovConfig, teamConfig, hostdevPod, poolPod, otherPod, newNode("node1"),
+				&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node2"}}).Build()
+		reconciler := &NicClusterPolicyReconciler{
+			Client: fakeClient, Log: ctrl.Log, PodReader: &nodePodReader{Reader: fakeClient}}
+		teardown := func() (ctrl.Result, *mellanoxv1alpha1.NicClusterPolicy) {
+			current := &mellanoxv1alpha1.NicClusterPolicy{}
+			key := types.NamespacedName{Name: consts.NicClusterPolicyResourceName}
+			Expect(fakeClient.Get(context.TODO(), key, current)).To(Succeed())
+			result, err := reconciler.handleTeardown(context.TODO(), current, ctrl.Log)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeClient.Get(context.TODO(), key, current)).To(Succeed())
+			return result, current
+		}
+
+		for i, pod := range []client.Object{hostdevPod, poolPod} {
+			result, current := teardown()
+			Expect(result.RequeueAfter).NotTo(BeZero())
+			Expect(current.Finalizers).To(ContainElement(consts.NicClusterPolicyFinalizer))
+			Expect(current.Status.Reason).To(ContainSubstring(fmt.Sprintf("waiting for %d pods", 2-i)))
+			Expect(fakeClient.Delete(context.TODO(), pod)).To(Succeed())
+		}
+
+		result, current := teardown()
+		Expect(result.RequeueAfter).To(BeZero())
+		Expect(current.Finalizers).NotTo(ContainElement(consts.NicClusterPolicyFinalizer))
+		// the resolved configs are not stored in the policy
+		Expect(current.Spec.SriovDevicePlugin.Config).To(BeEmpty())
+		Expect(current.Spec.RdmaSharedDevicePlugin.ResourcePools).To(HaveLen(1))
+	})
+
+	It("should remove the components after the teardown timeout with a warning event", func() {
+		deleted := metav1.NewTime(time.Now().Add(
+			-time.Duration(config.FromEnv().Controller.TeardownTimeoutSeconds+1) * time.Second))
+		cr := &mellanoxv1alpha1.NicClusterPolicy{ObjectMeta: metav1.ObjectMeta{
+			Name:              consts.NicClusterPolicyResourceName,
+			Finalizers:        []string{consts.NicClusterPolicyFinalizer},
+			DeletionTimestamp: &deleted,
+		}}
+		image := mellanoxv1alpha1.ImageSpec{Image: "dp", Repository: "nvcr.io/mellanox", Version: "v1"}
+		cr.Spec.RdmaSharedDevicePlugin = &mellanoxv1alpha1.RdmaSharedDevicePluginSpec{ImageSpec: image,
+			ResourcePools: []mellanoxv1alpha1.RdmaSharedDevicePoolSpec{{Name: "pool_a", RdmaHcaMax: 63,
+				Selectors: mellanoxv1alpha1.RdmaSharedDevicePoolSelectors{IfNames: []string{"ens1f0"}}}}}
+
+		s := scheme.Scheme
+		Expect(mellanoxv1alpha1.AddToScheme(s)).To(Succeed())
+		fakeClient := fake.NewClientBuilder().WithScheme(s).
+			WithObjects(cr, newPod("pool-app", "rdma/pool_a"), newNode("node1")).Build()
+		recorder := record.NewFakeRecorder(1)
+		reconciler := &NicClusterPolicyReconciler{
+			Client: fakeClient, Log: ctrl.Log, PodReader: &nodePodReader{Reader: fakeClient}, Recorder: recorder}
+
+		current := &mellanoxv1alpha1.NicClusterPolicy{}
+		key := types.NamespacedName{Name: consts.NicClusterPolicyResourceName}
+		Expect(fakeClient.Get(context.TODO(), key, current)).To(Succeed())
+		result, err := reconciler.handleTeardown(context.TODO(), current, ctrl.Log)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeZero())
+		Expect(fakeClient.Get(context.TODO(), key, current)).To(Succeed())
+		Expect(current.Finalizers).NotTo(ContainElement(consts.NicClusterPolicyFinalizer))
+		Expect(recorder.Events).To(Receive(And(
+			HavePrefix(corev1.EventTypeWarning+" "+teardownTimeoutEventReason), ContainSubstring("1 pods"))))
+	})
+})
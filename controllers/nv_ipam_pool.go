@@ -0,0 +1,212 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Mellanox/network-operator/pkg/config"
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+)
+
+// nvIpamType is the type of the nv-ipam IPAM plugin in the IPAM configuration of the network
+const nvIpamType = "nv-ipam"
+
+// nvIpamPoolGVK is the GroupVersionKind of the IP pools of nv-ipam, the IPPools are read as unstructured objects,
+// as nv-ipam is deployed independently of the operator
+var nvIpamPoolGVK = schema.GroupVersionKind{Group: "nv-ipam.nvidia.com", Version: "v1alpha1", Kind: "IPPool"}
+
+// +kubebuilder:rbac:groups=nv-ipam.nvidia.com,resources=ippools,verbs=get;list;watch
+
+// nvIpamConfig is the part of the nv-ipam IPAM configuration read by the operator
+type nvIpamConfig struct {
+	Type     string `json:"type"`
+	PoolName string `json:"poolName"`
+}
+
+// nvIpamPoolNames returns the names of the IPPools referenced by the IPAM configuration of the network,
+// nil if the network doesn't use nv-ipam. Several pools are separated by comma, e.g. for dual stack networks
+func nvIpamPoolNames(ipam string) []string {
+	ipamConfig := &nvIpamConfig{}
+	if err := json.Unmarshal([]byte(ipam), ipamConfig); err != nil || ipamConfig.Type != nvIpamType {
+		return nil
+	}
+	var names []string
+	for _, name := range strings.Split(ipamConfig.PoolName, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// nvIpamPoolsNamespace returns the namespace of the nv-ipam IPPools
+func nvIpamPoolsNamespace() string {
+	if namespace := config.FromEnv().Controller.NvIpamPoolsNamespace; namespace != "" {
+		return namespace
+	}
+	return config.FromEnv().State.NetworkOperatorResourceNamespace
+}
+
+// ipPoolReadyCondition returns consts.IPPoolReadyCondition for the IPPools referenced by the IPAM configuration,
+// nil if the network doesn't use nv-ipam. The condition is False if a pool doesn't exist or no IP block of a pool
+// is allocated to some of the nodes with Mellanox NICs selected by the pool, e.g. when the pool is exhausted
+func ipPoolReadyCondition(ctx context.Context, c client.Client, ipam string) *metav1.Condition {
+	poolNames := nvIpamPoolNames(ipam)
+	if len(poolNames) == 0 {
+		return nil
+	}
+	nodes := &corev1.NodeList{}
+	if err := c.List(ctx, nodes, nodeinfo.MellanoxNICListOptions...); err != nil {
+		return &metav1.Condition{Type: consts.IPPoolReadyCondition, Status: metav1.ConditionFalse,
+			Reason: "IPPoolUnavailable", Message: fmt.Sprintf("failed to list nodes: %v", err)}
+	}
+
+	namespace := nvIpamPoolsNamespace()
+	for _, name := range poolNames {
+		pool := &unstructured.Unstructured{}
+		pool.SetGroupVersionKind(nvIpamPoolGVK)
+		err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, pool)
+		if apiErrors.IsNotFound(err) {
+			return &metav1.Condition{Type: consts.IPPoolReadyCondition, Status: metav1.ConditionFalse,
+				Reason: "IPPoolNotFound", Message: fmt.Sprintf("IPPool %s/%s doesn't exist", namespace, name)}
+		}
+		if err != nil {
+			return &metav1.Condition{Type: consts.IPPoolReadyCondition, Status: metav1.ConditionFalse,
+				Reason: "IPPoolUnavailable", Message: fmt.Sprintf("failed to get IPPool %s/%s: %v", namespace, name, err)}
+		}
+		missing, err := nodesWithoutAllocation(pool, nodes.Items)
+		if err != nil {
+			return &metav1.Condition{Type: consts.IPPoolReadyCondition, Status: metav1.ConditionFalse,
+				Reason: "IPPoolUnavailable", Message: fmt.Sprintf("invalid IPPool %s/%s: %v", namespace, name, err)}
+		}
+		if len(missing) > 0 {
+			return &metav1.Condition{Type: consts.IPPoolReadyCondition, Status: metav1.ConditionFalse,
+				Reason: "IPPoolExhausted", Message: fmt.Sprintf("IPPool %s/%s has no IP block allocated for nodes %s",
+					namespace, name, strings.Join(missing, ","))}
+		}
+	}
+	return &metav1.Condition{Type: consts.IPPoolReadyCondition, Status: metav1.ConditionTrue,
+		Reason: "IPPoolReady", Message: fmt.Sprintf("IPPools %s allocate IPs on all nodes", strings.Join(poolNames, ","))}
+}
+
+// nodesWithoutAllocation returns the sorted names of the nodes selected by the IPPool node selector
+// which are not listed in the allocations of the IPPool status
+func nodesWithoutAllocation(pool *unstructured.Unstructured, nodes []corev1.Node) ([]string, error) {
+	nodeSelector := &corev1.NodeSelector{}
+	rawSelector, found, err := unstructured.NestedMap(pool.Object, "spec", "nodeSelector")
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(rawSelector, nodeSelector); err != nil {
+			return nil, err
+		}
+	}
+	allocations, _, err := unstructured.NestedSlice(pool.Object, "status", "allocations")
+	if err != nil {
+		return nil, err
+	}
+	allocated := make(map[string]bool, len(allocations))
+	for _, allocation := range allocations {
+		if allocationMap, ok := allocation.(map[string]interface{}); ok {
+			if nodeName, ok := allocationMap["nodeName"].(string); ok {
+				allocated[nodeName] = true
+			}
+		}
+	}
+
+	var missing []string
+	for i := range nodes {
+		if allocated[nodes[i].Name] {
+			continue
+		}
+		selected, err := nodeSelectorMatches(nodeSelector, &nodes[i])
+		if err != nil {
+			return nil, err
+		}
+		if selected {
+			missing = append(missing, nodes[i].Name)
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
+
+// nodeSelectorMatches returns true if the node matches any of the node selector terms,
+// every node matches an empty node selector. Only the metadata.name field can be matched with matchFields
+func nodeSelectorMatches(nodeSelector *corev1.NodeSelector, node *corev1.Node) (bool, error) {
+	if len(nodeSelector.NodeSelectorTerms) == 0 {
+		return true, nil
+	}
+	for _, term := range nodeSelector.NodeSelectorTerms {
+		labelsMatch, err := nodeSelectorRequirementsMatch(term.MatchExpressions, labels.Set(node.Labels))
+		if err != nil {
+			return false, err
+		}
+		fieldsMatch, err := nodeSelectorRequirementsMatch(term.MatchFields, labels.Set{"metadata.name": node.Name})
+		if err != nil {
+			return false, err
+		}
+		if labelsMatch && fieldsMatch && (len(term.MatchExpressions) > 0 || len(term.MatchFields) > 0) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// nodeSelectorRequirementsMatch returns true if the set matches all the node selector requirements
+func nodeSelectorRequirementsMatch(requirements []corev1.NodeSelectorRequirement, set labels.Set) (bool, error) {
+	operators := map[corev1.NodeSelectorOperator]selection.Operator{
+		corev1.NodeSelectorOpIn:           selection.In,
+		corev1.NodeSelectorOpNotIn:        selection.NotIn,
+		corev1.NodeSelectorOpExists:       selection.Exists,
+		corev1.NodeSelectorOpDoesNotExist: selection.DoesNotExist,
+		corev1.NodeSelectorOpGt:           selection.GreaterThan,
+		corev1.NodeSelectorOpLt:           selection.LessThan,
+	}
+	for _, requirement := range requirements {
+		operator, ok := operators[requirement.Operator]
+		if !ok {
+			return false, fmt.Errorf("unsupported node selector operator %q", requirement.Operator)
+		}
+		labelRequirement, err := labels.NewRequirement(requirement.Key, operator, requirement.Values)
+		if err != nil {
+			return false, err
+		}
+		if !labelRequirement.Matches(set) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
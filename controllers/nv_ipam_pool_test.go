@@ -0,0 +1,95 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+)
+
+var _ = Describe("nv-ipam IPPool readiness", func() {
+	newNode := func(name string, nodeLabels map[string]string) *corev1.Node {
+		nodeLabels[nodeinfo.NodeLabelMlnxNIC] = "true"
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: nodeLabels}}
+	}
+	newPool := func(name string, nodeSelector map[string]interface{},
+		allocatedNodes ...string) *unstructured.Unstructured {
+		pool := &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{}}}
+		pool.SetGroupVersionKind(nvIpamPoolGVK)
+		pool.SetNamespace(nvIpamPoolsNamespace())
+		pool.SetName(name)
+		if nodeSelector != nil {
+			Expect(unstructured.SetNestedMap(pool.Object, nodeSelector, "spec", "nodeSelector")).To(Succeed())
+		}
+		allocations := make([]interface{}, 0, len(allocatedNodes))
+		for _, node := range allocatedNodes {
+			allocations = append(allocations, map[string]interface{}{"nodeName": node, "startIP": "192.168.0.1"})
+		}
+		Expect(unstructured.SetNestedSlice(pool.Object, allocations, "status", "allocations")).To(Succeed())
+		return pool
+	}
+
+	It("should read the pool names of nv-ipam configuration", func() {
+		Expect(nvIpamPoolNames(`{"type": "nv-ipam", "poolName": "pool-a"}`)).To(Equal([]string{"pool-a"}))
+		Expect(nvIpamPoolNames(`{"type": "nv-ipam", "poolName": "pool-v4, pool-v6"}`)).
+			To(Equal([]string{"pool-v4", "pool-v6"}))
+		Expect(nvIpamPoolNames(`{"type": "whereabouts", "range": "192.168.2.225/28"}`)).To(BeEmpty())
+		Expect(nvIpamPoolNames("")).To(BeEmpty())
+	})
+
+	It("should report missing and exhausted pools", func() {
+		gpuSelector := map[string]interface{}{"nodeSelectorTerms": []interface{}{map[string]interface{}{
+			"matchExpressions": []interface{}{map[string]interface{}{
+				"key": "gpu", "operator": "In", "values": []interface{}{"true"}}}}}}
+		objects := []client.Object{
+			newNode("node-1", map[string]string{"gpu": "true"}),
+			newNode("node-2", map[string]string{}),
+			newPool("all-nodes", nil, "node-1"),
+			newPool("gpu-nodes", gpuSelector, "node-1"),
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).Build()
+		ipam := func(pool string) string {
+			return `{"type": "nv-ipam", "poolName": "` + pool + `"}`
+		}
+
+		Expect(ipPoolReadyCondition(context.TODO(), fakeClient, `{"type": "host-local"}`)).To(BeNil())
+
+		condition := ipPoolReadyCondition(context.TODO(), fakeClient, ipam("gpu-nodes"))
+		Expect(condition.Type).To(Equal(consts.IPPoolReadyCondition))
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+
+		condition = ipPoolReadyCondition(context.TODO(), fakeClient, ipam("gpu-nodes,all-nodes"))
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal("IPPoolExhausted"))
+		Expect(condition.Message).To(ContainSubstring("all-nodes has no IP block allocated for nodes node-2"))
+
+		condition = ipPoolReadyCondition(context.TODO(), fakeClient, ipam("missing"))
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal("IPPoolNotFound"))
+	})
+})
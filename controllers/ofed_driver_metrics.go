@@ -0,0 +1,82 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/state"
+	"github.com/Mellanox/network-operator/pkg/upgrade"
+	"github.com/Mellanox/network-operator/pkg/utils"
+)
+
+var (
+	// ofedDriverPodsGauge is set to the number of OFED driver pods running the image
+	ofedDriverPodsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "network_operator_ofed_driver_pods",
+		Help: "Number of OFED driver pods deployed with the image",
+	}, []string{"image", "tag"})
+	// ofedDriverBuildTimestampGauge is set to the build date of the deployed image if the image tag contains it
+	ofedDriverBuildTimestampGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "network_operator_ofed_driver_build_timestamp_seconds",
+		Help: "Build date of the deployed OFED driver image as Unix time, parsed from the image tag",
+	}, []string{"image", "tag"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(ofedDriverPodsGauge, ofedDriverBuildTimestampGauge)
+}
+
+// updateOfedDriverMetrics reports the images of the deployed OFED driver pods,
+// metrics of the images which are no longer deployed are removed
+func (r *NicClusterPolicyReconciler) updateOfedDriverMetrics(
+	ctx context.Context, cr *mellanoxv1alpha1.NicClusterPolicy) {
+	podList := &corev1.PodList{}
+	err := r.List(ctx, podList,
+		client.InNamespace(state.OfedDriverNamespace(cr)),
+		client.MatchingLabels{upgrade.OfedDriverLabel: ""})
+	if err != nil {
+		r.Log.V(consts.LogLevelWarning).Info("Failed to list OFED driver pods for metrics", "error:", err)
+		return
+	}
+
+	pods := make(map[string]int)
+	for i := range podList.Items {
+		for _, container := range podList.Items[i].Spec.Containers {
+			if container.Name == upgrade.OfedDriverContainerName {
+				pods[container.Image]++
+			}
+		}
+	}
+
+	ofedDriverPodsGauge.Reset()
+	ofedDriverBuildTimestampGauge.Reset()
+	for image, count := range pods {
+		name, tag := utils.SplitImageTag(image)
+		ofedDriverPodsGauge.WithLabelValues(name, tag).Set(float64(count))
+		if buildDate, ok := utils.ParseImageBuildDate(tag); ok {
+			ofedDriverBuildTimestampGauge.WithLabelValues(name, tag).Set(float64(buildDate.Unix()))
+		}
+	}
+}
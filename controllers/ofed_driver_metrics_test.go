@@ -0,0 +1,71 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/state"
+	"github.com/Mellanox/network-operator/pkg/upgrade"
+)
+
+var _ = Describe("OFED driver metrics", func() {
+	It("should report the images of the deployed OFED driver pods", func() {
+		cr := &mellanoxv1alpha1.NicClusterPolicy{}
+		namespace := state.OfedDriverNamespace(cr)
+		newPod := func(name, image string, labels map[string]string) *corev1.Pod {
+			return &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{
+					{Name: upgrade.OfedDriverContainerName, Image: image}}},
+			}
+		}
+		driverLabels := map[string]string{upgrade.OfedDriverLabel: ""}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+			newPod("mofed-1", "nvcr.io/mellanox/mofed:5.6-1.0.3.3-20220730", driverLabels),
+			newPod("mofed-2", "nvcr.io/mellanox/mofed:5.6-1.0.3.3-20220730", driverLabels),
+			newPod("mofed-3", "nvcr.io/mellanox/mofed:5.5-1.0.3.2", driverLabels),
+			newPod("other", "nvcr.io/mellanox/mofed:5.4-1.0.3.0", map[string]string{"app": "other"}),
+		).Build()
+		ofedDriverPodsGauge.WithLabelValues("nvcr.io/mellanox/mofed", "5.3-1.0.0.1").Set(1)
+		reconciler := &NicClusterPolicyReconciler{Client: fakeClient, Log: ctrl.Log}
+		reconciler.updateOfedDriverMetrics(context.TODO(), cr)
+
+		Expect(testutil.ToFloat64(ofedDriverPodsGauge.WithLabelValues(
+			"nvcr.io/mellanox/mofed", "5.6-1.0.3.3-20220730"))).To(Equal(2.0))
+		Expect(testutil.ToFloat64(ofedDriverPodsGauge.WithLabelValues(
+			"nvcr.io/mellanox/mofed", "5.5-1.0.3.2"))).To(Equal(1.0))
+		// the image which is no longer deployed and the pod without the driver label are not reported
+		Expect(testutil.CollectAndCount(ofedDriverPodsGauge)).To(Equal(2))
+
+		buildDate := time.Date(2022, 7, 30, 0, 0, 0, 0, time.UTC)
+		Expect(testutil.ToFloat64(ofedDriverBuildTimestampGauge.WithLabelValues(
+			"nvcr.io/mellanox/mofed", "5.6-1.0.3.3-20220730"))).To(Equal(float64(buildDate.Unix())))
+		Expect(testutil.CollectAndCount(ofedDriverBuildTimestampGauge)).To(Equal(1))
+	})
+})
@@ -0,0 +1,142 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/state"
+)
+
+// syncOfedNodeEnv stores the OFED driver environment overrides of the nodes, set with
+// consts.OfedDriverEnvAnnotation, in the consts.OfedNodeEnvConfigMapName ConfigMap. Each node gets a key with
+// the shell-quoted NAME='value' lines of its overrides. The ConfigMap is deleted if the overrides are disabled
+func (r *NicClusterPolicyReconciler) syncOfedNodeEnv(
+	ctx context.Context, cr *mellanoxv1alpha1.NicClusterPolicy, nodes []*corev1.Node) error {
+	namespace := state.OfedDriverNamespace(cr)
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:      consts.OfedNodeEnvConfigMapName,
+		Namespace: namespace,
+	}}
+	ofedSpec := cr.Spec.OFEDDriver
+	if ofedSpec == nil || !ofedSpec.IsEnabled() || !ofedSpec.NodeEnvOverrides {
+		return client.IgnoreNotFound(r.Delete(ctx, configMap))
+	}
+
+	data := make(map[string]string)
+	for _, node := range nodes {
+		value, ok := node.Annotations[consts.OfedDriverEnvAnnotation]
+		if !ok {
+			continue
+		}
+		envFile, err := ofedNodeEnvFile(value)
+		if err != nil {
+			// the other nodes are not blocked by an invalid annotation, the node keeps the policy env
+			r.Log.V(consts.LogLevelWarning).Info("Ignoring invalid OFED driver env annotation of the node",
+				"node", node.Name, "annotation", consts.OfedDriverEnvAnnotation, "error:", err)
+			continue
+		}
+		data[node.Name] = envFile
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, configMap, func() error {
+		configMap.Labels = state.MergeManagedLabels(configMap.Labels)
+		configMap.Annotations = state.MergeManagedAnnotations(configMap.Annotations)
+		configMap.Data = data
+		return controllerutil.SetControllerReference(cr, configMap, r.Scheme)
+	})
+	if err != nil {
+		return err
+	}
+	return r.deleteStaleOfedNodeEnv(ctx, namespace)
+}
+
+// deleteStaleOfedNodeEnv deletes the node env ConfigMaps created by the operator in other namespaces than
+// the namespace of the OFED driver, e.g. after the namespace of the driver was changed
+func (r *NicClusterPolicyReconciler) deleteStaleOfedNodeEnv(ctx context.Context, namespace string) error {
+	configMaps := &corev1.ConfigMapList{}
+	if err := r.List(ctx, configMaps, client.MatchingLabels(state.ManagedLabels())); err != nil {
+		return err
+	}
+	for i := range configMaps.Items {
+		configMap := &configMaps.Items[i]
+		if configMap.Name != consts.OfedNodeEnvConfigMapName || configMap.Namespace == namespace {
+			continue
+		}
+		if err := r.Delete(ctx, configMap); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ofedNodeEnvFile returns the env file content for the value of consts.OfedDriverEnvAnnotation,
+// a JSON object of environment variable names and values. The lines are sorted by name
+func ofedNodeEnvFile(annotation string) (string, error) {
+	env := map[string]string{}
+	if err := json.Unmarshal([]byte(annotation), &env); err != nil {
+		return "", fmt.Errorf("expected a JSON object of environment variable names and values: %v", err)
+	}
+	names := make([]string, 0, len(env))
+	for name, value := range env {
+		if errs := validation.IsEnvVarName(name); len(errs) > 0 {
+			return "", fmt.Errorf("invalid environment variable name %q: %s", name, strings.Join(errs, ", "))
+		}
+		if strings.ContainsAny(value, "\n\r") {
+			return "", fmt.Errorf("value of environment variable %q must not contain line breaks", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s='%s'\n", name, strings.ReplaceAll(env[name], "'", `'\''`))
+	}
+	return b.String(), nil
+}
+
+// ofedDriverEnvAnnotationChanged passes node events which add, change or remove consts.OfedDriverEnvAnnotation
+var ofedDriverEnvAnnotationChanged = predicate.Funcs{
+	CreateFunc: func(e event.CreateEvent) bool {
+		_, ok := e.Object.GetAnnotations()[consts.OfedDriverEnvAnnotation]
+		return ok
+	},
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldValue, oldOk := e.ObjectOld.GetAnnotations()[consts.OfedDriverEnvAnnotation]
+		newValue, newOk := e.ObjectNew.GetAnnotations()[consts.OfedDriverEnvAnnotation]
+		return oldOk != newOk || oldValue != newValue
+	},
+	DeleteFunc: func(e event.DeleteEvent) bool {
+		_, ok := e.Object.GetAnnotations()[consts.OfedDriverEnvAnnotation]
+		return ok
+	},
+	GenericFunc: func(e event.GenericEvent) bool { return false },
+}
@@ -0,0 +1,90 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/config"
+	"github.com/Mellanox/network-operator/pkg/consts"
+)
+
+var _ = Describe("OFED driver node env overrides", func() {
+	It("should shell-quote the overrides sorted by name", func() {
+		envFile, err := ofedNodeEnvFile(`{"UNLOAD_STORAGE_MODULES":"true","CREATE_IFNAMES_UDEV":"it's on"}`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(envFile).To(Equal("CREATE_IFNAMES_UDEV='it'\\''s on'\nUNLOAD_STORAGE_MODULES='true'\n"))
+
+		for _, invalid := range []string{`not json`, `{"1NAME":"value"}`, `{"NAME":"line\nbreak"}`} {
+			_, err := ofedNodeEnvFile(invalid)
+			Expect(err).To(HaveOccurred())
+		}
+	})
+
+	It("should store the overrides of the annotated nodes in a ConfigMap", func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(mellanoxv1alpha1.AddToScheme(scheme)).To(Succeed())
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+		reconciler := &NicClusterPolicyReconciler{Client: fakeClient, Log: ctrl.Log, Scheme: scheme}
+
+		cr := &mellanoxv1alpha1.NicClusterPolicy{ObjectMeta: metav1.ObjectMeta{Name: consts.NicClusterPolicyResourceName}}
+		cr.Spec.OFEDDriver = &mellanoxv1alpha1.OFEDDriverSpec{
+			ImageSpec:        mellanoxv1alpha1.ImageSpec{Image: "mofed", Repository: "nvcr.io", Version: "5.7"},
+			NodeEnvOverrides: true,
+		}
+		nodes := []*corev1.Node{
+			{ObjectMeta: metav1.ObjectMeta{Name: "quirky", Annotations: map[string]string{
+				consts.OfedDriverEnvAnnotation: `{"UNLOAD_STORAGE_MODULES":"true"}`}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "invalid", Annotations: map[string]string{
+				consts.OfedDriverEnvAnnotation: `UNLOAD_STORAGE_MODULES=true`}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "regular"}},
+		}
+		Expect(reconciler.syncOfedNodeEnv(context.TODO(), cr, nodes)).To(Succeed())
+
+		key := types.NamespacedName{
+			Namespace: config.FromEnv().State.NetworkOperatorResourceNamespace, Name: consts.OfedNodeEnvConfigMapName}
+		configMap := &corev1.ConfigMap{}
+		Expect(fakeClient.Get(context.TODO(), key, configMap)).To(Succeed())
+		Expect(configMap.Data).To(Equal(map[string]string{"quirky": "UNLOAD_STORAGE_MODULES='true'\n"}))
+		Expect(configMap.OwnerReferences).To(HaveLen(1))
+
+		// the ConfigMap follows the driver into its namespace
+		cr.Spec.OFEDDriver.Namespace = "ofed-driver"
+		Expect(reconciler.syncOfedNodeEnv(context.TODO(), cr, nodes)).To(Succeed())
+		Expect(apiErrors.IsNotFound(fakeClient.Get(context.TODO(), key, &corev1.ConfigMap{}))).To(BeTrue())
+		key.Namespace = "ofed-driver"
+		Expect(fakeClient.Get(context.TODO(), key, configMap)).To(Succeed())
+
+		cr.Spec.OFEDDriver.NodeEnvOverrides = false
+		Expect(reconciler.syncOfedNodeEnv(context.TODO(), cr, nodes)).To(Succeed())
+		err := fakeClient.Get(context.TODO(), key, configMap)
+		Expect(apiErrors.IsNotFound(err)).To(BeTrue())
+	})
+})
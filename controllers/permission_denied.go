@@ -0,0 +1,119 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/state"
+)
+
+// forbiddenMessageRegexp matches the verb, resource, API group and namespace in the message of a Forbidden error
+// returned by the API server authorizer
+var forbiddenMessageRegexp = regexp.MustCompile(
+	`cannot (\S+) resource "([^"]+)" in API group "([^"]*)"(?: in the namespace "([^"]+)")?`)
+
+// missingPermission returns the permission of the operator which is missing, according to the Forbidden error,
+// e.g. `create daemonsets.apps in namespace nvidia-network-operator`, false if the error is not Forbidden
+func missingPermission(err error) (string, bool) {
+	if !apiErrors.IsForbidden(err) {
+		return "", false
+	}
+	var status apiErrors.APIStatus
+	if !errors.As(err, &status) {
+		return "", false
+	}
+	match := forbiddenMessageRegexp.FindStringSubmatch(status.Status().Message)
+	if match == nil {
+		// e.g. forbidden by an admission plugin, not by RBAC
+		return status.Status().Message, true
+	}
+	permission := match[1] + " " + match[2]
+	if match[3] != "" {
+		permission += "." + match[3]
+	}
+	if match[4] != "" {
+		permission += " in namespace " + match[4]
+	}
+	return permission, true
+}
+
+// permissionDeniedCondition returns consts.PermissionDeniedCondition listing the permissions missing according to
+// the errors of the reconcile steps, e.g. the sync error, and the errors of the synced states, nil if none of the
+// errors is Forbidden
+func permissionDeniedCondition(results state.Results, stepErrors ...error) *metav1.Condition {
+	errs := append([]error{}, stepErrors...)
+	for _, result := range results.StatesStatus {
+		errs = append(errs, result.ErrInfo)
+	}
+	permissions := make(map[string]bool)
+	for _, err := range errs {
+		if permission, ok := missingPermission(err); ok {
+			permissions[permission] = true
+		}
+	}
+	if len(permissions) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(permissions))
+	for permission := range permissions {
+		names = append(names, permission)
+	}
+	sort.Strings(names)
+	return &metav1.Condition{
+		Type:   consts.PermissionDeniedCondition,
+		Status: metav1.ConditionTrue,
+		Reason: "Forbidden",
+		Message: fmt.Sprintf("the operator is not allowed to %s, grant the permissions to the operator "+
+			"service account", strings.Join(names, "; ")),
+	}
+}
+
+// updatePermissionDeniedCondition sets consts.PermissionDeniedCondition in the conditions if the sync or another
+// reconcile step failed because of missing permissions of the operator, the condition is removed otherwise
+func updatePermissionDeniedCondition(logger logr.Logger, conditions *[]metav1.Condition, results state.Results,
+	stepErrors ...error) {
+	condition := permissionDeniedCondition(results, stepErrors...)
+	if condition == nil {
+		if meta.FindStatusCondition(*conditions, consts.PermissionDeniedCondition) != nil {
+			meta.RemoveStatusCondition(conditions, consts.PermissionDeniedCondition)
+		}
+		return
+	}
+	logger.V(consts.LogLevelWarning).Info("Operator lacks permissions, check its RBAC", "message", condition.Message)
+	meta.SetStatusCondition(conditions, *condition)
+}
+
+// logStatusUpdateError logs the error of the status update, the missing permission is named if the update is
+// Forbidden, it can't be reported in the conditions of the status
+func logStatusUpdateError(logger logr.Logger, err error) {
+	if permission, ok := missingPermission(err); ok {
+		logger.V(consts.LogLevelWarning).Info("Operator lacks permissions, check its RBAC",
+			"message", fmt.Sprintf("the operator is not allowed to %s", permission))
+	}
+	logger.V(consts.LogLevelError).Info("Failed to update CR status", "error:", err)
+}
@@ -0,0 +1,87 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/state"
+)
+
+var _ = Describe("Permission denied condition", func() {
+	forbidden := func(verb, resource, group, namespace string) error {
+		message := fmt.Sprintf(`User "system:serviceaccount:nvidia-network-operator:network-operator" `+
+			`cannot %s resource %q in API group %q`, verb, resource, group)
+		if namespace != "" {
+			message += fmt.Sprintf(" in the namespace %q", namespace)
+		}
+		return apiErrors.NewForbidden(schema.GroupResource{Group: group, Resource: resource}, "name",
+			errors.New(message))
+	}
+
+	It("should name the missing permissions", func() {
+		dsErr := errors.Wrap(forbidden("create", "daemonsets", "apps", "nvidia-network-operator"), "failed to sync")
+		results := state.Results{StatesStatus: []state.Result{
+			{StateName: "state-a", Status: state.SyncStateError, ErrInfo: dsErr},
+			{StateName: "state-b", Status: state.SyncStateError, ErrInfo: forbidden("list", "nodes", "", "")},
+			{StateName: "state-c", Status: state.SyncStateReady},
+		}}
+		condition := permissionDeniedCondition(results, dsErr)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Message).To(Equal("the operator is not allowed to " +
+			"create daemonsets.apps in namespace nvidia-network-operator; list nodes, " +
+			"grant the permissions to the operator service account"))
+	})
+
+	It("should name the permissions missing in the other reconcile steps", func() {
+		labelsErr := errors.Wrap(forbidden("patch", "nodes", "", ""), "unable to update node label")
+		condition := permissionDeniedCondition(state.Results{}, nil, labelsErr)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Message).To(Equal("the operator is not allowed to patch nodes, " +
+			"grant the permissions to the operator service account"))
+	})
+
+	It("should not set the condition for other errors", func() {
+		results := state.Results{StatesStatus: []state.Result{
+			{StateName: "state-a", Status: state.SyncStateError, ErrInfo: errors.New("failed")},
+		}}
+		Expect(permissionDeniedCondition(results, apiErrors.NewBadRequest("bad request"))).To(BeNil())
+	})
+
+	It("should remove the condition once the sync is not forbidden anymore", func() {
+		var conditions []metav1.Condition
+		log := ctrl.Log.WithName("test")
+		updatePermissionDeniedCondition(log, &conditions, state.Results{}, forbidden("get", "ippools",
+			"nv-ipam.nvidia.com", "default"))
+		Expect(meta.IsStatusConditionTrue(conditions, consts.PermissionDeniedCondition)).To(BeTrue())
+		updatePermissionDeniedCondition(log, &conditions, state.Results{}, nil)
+		Expect(conditions).To(BeEmpty())
+		updatePermissionDeniedCondition(log, &conditions, state.Results{}, nil)
+		Expect(conditions).To(BeEmpty())
+	})
+})
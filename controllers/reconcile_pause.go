@@ -0,0 +1,87 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/consts"
+)
+
+// reconcilePausedUntil returns the resume time of the reconcile set with consts.PauseReconcileUntilAnnotation,
+// false if the annotation is not set. An error is returned if the value is not an RFC3339 time
+func reconcilePausedUntil(obj metav1.Object) (time.Time, bool, error) {
+	value, ok := obj.GetAnnotations()[consts.PauseReconcileUntilAnnotation]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	resume, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid %s annotation %q, expected an RFC3339 time",
+			consts.PauseReconcileUntilAnnotation, value)
+	}
+	return resume, true, nil
+}
+
+// reconcilePause returns the time until the paused reconcile of the NicClusterPolicy resumes, zero if it is not
+// paused. consts.ReconcilePausedCondition and the status reason show the scheduled resume while the reconcile is
+// paused, the status is updated then. An invalid resume time doesn't pause the reconcile, it is reported in
+// the condition. The condition is removed once the reconcile resumes
+func (r *NicClusterPolicyReconciler) reconcilePause(
+	ctx context.Context, cr *mellanoxv1alpha1.NicClusterPolicy) time.Duration {
+	resume, ok, err := reconcilePausedUntil(cr)
+	if err != nil {
+		r.Log.V(consts.LogLevelWarning).Info("Ignoring the reconcile pause", "error:", err)
+		// the status is updated with CR status
+		meta.SetStatusCondition(&cr.Status.Conditions, metav1.Condition{
+			Type:    consts.ReconcilePausedCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "InvalidResumeTime",
+			Message: err.Error(),
+		})
+		return 0
+	}
+	remaining := time.Until(resume)
+	if !ok || remaining <= 0 {
+		if meta.FindStatusCondition(cr.Status.Conditions, consts.ReconcilePausedCondition) != nil {
+			// the status is updated with CR status
+			meta.RemoveStatusCondition(&cr.Status.Conditions, consts.ReconcilePausedCondition)
+			cr.Status.Reason = ""
+		}
+		return 0
+	}
+
+	resumeTime := resume.UTC().Format(time.RFC3339)
+	r.Log.V(consts.LogLevelInfo).Info("NicClusterPolicy reconcile is paused", "resumeTime", resumeTime)
+	meta.SetStatusCondition(&cr.Status.Conditions, metav1.Condition{
+		Type:    consts.ReconcilePausedCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "ScheduledPause",
+		Message: fmt.Sprintf("reconcile is paused until %s", resumeTime),
+	})
+	cr.Status.Reason = fmt.Sprintf("reconcile is paused until %s", resumeTime)
+	if err := r.Status().Update(ctx, cr); err != nil {
+		r.Log.V(consts.LogLevelError).Info("Failed to update CR status", "error:", err)
+	}
+	return remaining
+}
@@ -0,0 +1,80 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/consts"
+)
+
+var _ = Describe("NicClusterPolicy reconcile pause", func() {
+	newReconciler := func(resumeTime string) (*NicClusterPolicyReconciler, *mellanoxv1alpha1.NicClusterPolicy) {
+		cr := &mellanoxv1alpha1.NicClusterPolicy{ObjectMeta: metav1.ObjectMeta{
+			Name:        consts.NicClusterPolicyResourceName,
+			Annotations: map[string]string{consts.PauseReconcileUntilAnnotation: resumeTime},
+		}}
+		s := scheme.Scheme
+		Expect(mellanoxv1alpha1.AddToScheme(s)).To(Succeed())
+		fakeClient := fake.NewClientBuilder().WithScheme(s).WithObjects(cr).Build()
+		return &NicClusterPolicyReconciler{Client: fakeClient, Log: ctrl.Log}, cr
+	}
+
+	It("should pause the reconcile until the resume time and show it in the status", func() {
+		resume := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		reconciler, cr := newReconciler(resume)
+
+		Expect(reconciler.reconcilePause(context.TODO(), cr)).To(BeNumerically("~", time.Hour, time.Minute))
+		updated := &mellanoxv1alpha1.NicClusterPolicy{}
+		Expect(reconciler.Get(context.TODO(), types.NamespacedName{Name: cr.Name}, updated)).To(Succeed())
+		condition := meta.FindStatusCondition(updated.Status.Conditions, consts.ReconcilePausedCondition)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Message).To(ContainSubstring(resume))
+		Expect(updated.Status.Reason).To(ContainSubstring(resume))
+	})
+
+	It("should resume the reconcile once the resume time has passed", func() {
+		reconciler, cr := newReconciler(time.Now().Add(-time.Minute).UTC().Format(time.RFC3339))
+		meta.SetStatusCondition(&cr.Status.Conditions, metav1.Condition{
+			Type: consts.ReconcilePausedCondition, Status: metav1.ConditionTrue, Reason: "ScheduledPause"})
+
+		Expect(reconciler.reconcilePause(context.TODO(), cr)).To(BeZero())
+		Expect(meta.FindStatusCondition(cr.Status.Conditions, consts.ReconcilePausedCondition)).To(BeNil())
+	})
+
+	It("should not pause the reconcile with an invalid resume time", func() {
+		reconciler, cr := newReconciler("tomorrow")
+
+		Expect(reconciler.reconcilePause(context.TODO(), cr)).To(BeZero())
+		condition := meta.FindStatusCondition(cr.Status.Conditions, consts.ReconcilePausedCondition)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal("InvalidResumeTime"))
+	})
+})
@@ -0,0 +1,57 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/consts"
+)
+
+// validateSpec checks the NicClusterPolicy spec before any component is synced, so that an invalid spec doesn't
+// produce broken objects, e.g. if the validating webhook is not deployed. consts.SpecInvalidCondition and the error
+// state are set in the status if the spec is invalid, false is returned then. The condition is removed otherwise
+func (r *NicClusterPolicyReconciler) validateSpec(ctx context.Context, cr *mellanoxv1alpha1.NicClusterPolicy) bool {
+	err := cr.ValidateSpec()
+	if err == nil {
+		if meta.FindStatusCondition(cr.Status.Conditions, consts.SpecInvalidCondition) != nil {
+			// the status is updated with CR status
+			meta.RemoveStatusCondition(&cr.Status.Conditions, consts.SpecInvalidCondition)
+			cr.Status.Reason = ""
+		}
+		return true
+	}
+
+	r.Log.V(consts.LogLevelWarning).Info("NicClusterPolicy spec is invalid, components are not synced", "error:", err)
+	meta.SetStatusCondition(&cr.Status.Conditions, metav1.Condition{
+		Type:    consts.SpecInvalidCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "InvalidSpec",
+		Message: err.Error(),
+	})
+	cr.Status.State = mellanoxv1alpha1.StateError
+	cr.Status.Reason = fmt.Sprintf("invalid spec, components are not synced: %v", err)
+	if err := r.Status().Update(ctx, cr); err != nil {
+		r.Log.V(consts.LogLevelError).Info("Failed to update CR status", "error:", err)
+	}
+	return false
+}
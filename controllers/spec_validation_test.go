@@ -0,0 +1,57 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/consts"
+)
+
+var _ = Describe("NicClusterPolicy spec validation", func() {
+	It("should flag an invalid spec in the status and clear the condition once fixed", func() {
+		cr := &mellanoxv1alpha1.NicClusterPolicy{}
+		cr.Name = consts.NicClusterPolicyResourceName
+		cr.Spec.OFEDDriver = &mellanoxv1alpha1.OFEDDriverSpec{
+			ImageSpec: mellanoxv1alpha1.ImageSpec{Image: "mofed", Repository: "nvcr.io/nvidia/mellanox"},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(cr).Build()
+		reconciler := &NicClusterPolicyReconciler{Client: fakeClient, Log: ctrl.Log}
+
+		Expect(reconciler.validateSpec(context.TODO(), cr)).To(BeFalse())
+		stored := &mellanoxv1alpha1.NicClusterPolicy{}
+		Expect(fakeClient.Get(context.TODO(), types.NamespacedName{Name: cr.Name}, stored)).To(Succeed())
+		Expect(stored.Status.State).To(BeEquivalentTo(mellanoxv1alpha1.StateError))
+		condition := meta.FindStatusCondition(stored.Status.Conditions, consts.SpecInvalidCondition)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Message).To(ContainSubstring("spec.ofedDriver.version"))
+
+		stored.Spec.OFEDDriver.Version = "5.7-0.1.2.0"
+		Expect(reconciler.validateSpec(context.TODO(), stored)).To(BeTrue())
+		Expect(meta.FindStatusCondition(stored.Status.Conditions, consts.SpecInvalidCondition)).To(BeNil())
+		Expect(stored.Status.Reason).To(BeEmpty())
+	})
+})
@@ -18,9 +18,15 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"reflect"
+	"strings"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
@@ -40,6 +46,8 @@ import (
 	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
 	"github.com/Mellanox/network-operator/pkg/config"
 	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+	"github.com/Mellanox/network-operator/pkg/state"
 	"github.com/Mellanox/network-operator/pkg/upgrade"
 )
 
@@ -50,6 +58,8 @@ type UpgradeReconciler struct {
 	Scheme                   *runtime.Scheme
 	StateManager             *upgrade.ClusterUpgradeStateManager
 	NodeUpgradeStateProvider upgrade.NodeUpgradeStateProvider
+	// EventSink is configured from the upgrade policy if set
+	EventSink *upgrade.HTTPEventSink
 }
 
 const plannedRequeueInterval = time.Minute * 2
@@ -57,9 +67,13 @@ const plannedRequeueInterval = time.Minute * 2
 //nolint
 // +kubebuilder:rbac:groups=mellanox.com,resources=*,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch;update;patch
-// +kubebuilder:rbac:groups="",resources=pods,verbs=list
+// +kubebuilder:rbac:groups="",resources=pods,verbs=list;watch
+// +kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
+// +kubebuilder:rbac:groups="",resources=pods/log,verbs=get
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
 // +kubebuilder:rbac:groups=apps,resources=deployments;daemonsets;replicasets;statefulsets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=apps,resources=deployments/finalizers,verbs=update
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;create;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -74,33 +88,111 @@ func (r *UpgradeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{}, err
 	}
 
-	if nicClusterPolicy.Spec.OFEDDriver == nil ||
+	// the upgrade flow doesn't change the nodes while the reconcile of the policy is paused,
+	// an invalid resume time is reported by the NicClusterPolicy reconciler
+	if resume, paused, _ := reconcilePausedUntil(nicClusterPolicy); paused && time.Until(resume) > 0 {
+		reqLogger.V(consts.LogLevelInfo).Info("NicClusterPolicy reconcile is paused, skipping driver upgrade",
+			"resumeTime", resume.UTC().Format(time.RFC3339))
+		return ctrl.Result{RequeueAfter: time.Until(resume)}, nil
+	}
+
+	forcedPolicy := forcedMinDriverVersionPolicy(nicClusterPolicy.Spec.OFEDDriver)
+	if forcedPolicy == nil && (nicClusterPolicy.Spec.OFEDDriver == nil ||
+		!nicClusterPolicy.Spec.OFEDDriver.IsEnabled() ||
 		nicClusterPolicy.Spec.OFEDDriver.OfedUpgradePolicy == nil ||
-		!nicClusterPolicy.Spec.OFEDDriver.OfedUpgradePolicy.AutoUpgrade {
+		!nicClusterPolicy.Spec.OFEDDriver.OfedUpgradePolicy.AutoUpgrade) {
 		reqLogger.V(consts.LogLevelInfo).Info("OFED Upgrade Policy is disabled, skipping driver upgrade")
+		r.configureEventSink(ctx, nil)
 		err = r.removeNodeUpgradeStateAnnotations(ctx)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
+		err = r.updateUpgradeAbortedCondition(ctx, nicClusterPolicy, false)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		err = r.updatePendingApprovalCondition(ctx, nicClusterPolicy, nil)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		err = r.updateManualUpgradeCondition(ctx, nicClusterPolicy, nil)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		emptyState := upgrade.NewClusterUpgradeState()
+		updateUpgradeStateMetrics(&emptyState, nil)
+		err = r.updateStalledCondition(ctx, nicClusterPolicy, nil, nil)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		err = r.updateUpgradeStatus(ctx, nicClusterPolicy, nil, nil)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
 		return ctrl.Result{}, nil
 	}
 
 	upgradePolicy := nicClusterPolicy.Spec.OFEDDriver.OfedUpgradePolicy
+	if forcedPolicy != nil {
+		reqLogger.V(consts.LogLevelInfo).Info("Upgrading OFED driver pods older than the minimum driver version",
+			"minVersion", nicClusterPolicy.Spec.OFEDDriver.MinDriverVersion)
+		upgradePolicy = forcedPolicy
+	}
+	r.configureEventSink(ctx, upgradePolicy.EventSink)
 
-	state, err := r.BuildState(ctx)
+	driverNamespace := state.OfedDriverNamespace(nicClusterPolicy)
+	state, err := r.BuildState(ctx, driverNamespace)
 	if err != nil {
 		r.Log.V(consts.LogLevelError).Error(err, "Failed to build cluster upgrade state")
 		return ctrl.Result{}, err
 	}
+	state.ApprovedImages = getApprovedImages(nicClusterPolicy)
+	if forcedPolicy != nil {
+		state.MinDriverVersion = nicClusterPolicy.Spec.OFEDDriver.MinDriverVersion
+	}
 
 	reqLogger.V(consts.LogLevelInfo).Info("Propagate state to state manager")
 	reqLogger.V(consts.LogLevelDebug).Info("Current cluster upgrade state", "state", state)
 	err = r.StateManager.ApplyState(ctx, state, upgradePolicy)
+	if apierrors.IsConflict(err) {
+		// the state was built from a stale node object, build it again from the latest objects
+		reqLogger.V(consts.LogLevelInfo).Info("Cluster upgrade state is stale, requeue", "reason", err.Error())
+		return ctrl.Result{Requeue: true}, nil
+	}
 	if err != nil {
 		r.Log.V(consts.LogLevelError).Error(err, "Failed to apply cluster upgrade state")
 		return ctrl.Result{}, err
 	}
 
+	err = r.updateUpgradeAbortedCondition(ctx, nicClusterPolicy, r.StateManager.IsUpgradeAborted(state, upgradePolicy))
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	err = r.updatePendingApprovalCondition(
+		ctx, nicClusterPolicy, r.StateManager.ImagesPendingApproval(state, upgradePolicy))
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	err = r.updateManualUpgradeCondition(ctx, nicClusterPolicy, r.StateManager.ManualUpgradeNodes(state))
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	stalledNodes := r.StateManager.StalledNodes(state, upgradePolicy)
+	updateUpgradeStateMetrics(state, stalledNodes)
+	err = r.updateStalledCondition(ctx, nicClusterPolicy, upgradePolicy, stalledNodes)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	err = r.updateUpgradeStatus(ctx, nicClusterPolicy,
+		r.StateManager.CordonedNodes(state), r.StateManager.QuarantinedNodes(state))
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
 	// In some cases if node state changes fail to apply, upgrade process
 	// might become stuck until the new reconcile loop is scheduled.
 	// Since node/ds/nicclusterpolicy updates from outside of the upgrade flow
@@ -108,7 +200,216 @@ func (r *UpgradeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	return ctrl.Result{Requeue: true, RequeueAfter: plannedRequeueInterval}, nil
 }
 
-// removeNodeUpgradeStateAnnotations loops over nodes in the cluster and removes upgrade.UpgradeStateAnnotation
+// updateUpgradeAbortedCondition sets or removes upgrade.UpgradeAbortedCondition on the NicClusterPolicy status
+func (r *UpgradeReconciler) updateUpgradeAbortedCondition(
+	ctx context.Context, nicClusterPolicy *mellanoxv1alpha1.NicClusterPolicy, aborted bool) error {
+	current := meta.FindStatusCondition(nicClusterPolicy.Status.Conditions, upgrade.UpgradeAbortedCondition)
+	if aborted {
+		if current != nil && current.Status == metav1.ConditionTrue {
+			return nil
+		}
+		meta.SetStatusCondition(&nicClusterPolicy.Status.Conditions, metav1.Condition{
+			Type:   upgrade.UpgradeAbortedCondition,
+			Status: metav1.ConditionTrue,
+			Reason: "MaxFailuresReached",
+			Message: "No new OFED upgrades are started because the number of failed nodes reached maxFailures, " +
+				"manual interaction is required",
+		})
+	} else {
+		if current == nil {
+			return nil
+		}
+		meta.RemoveStatusCondition(&nicClusterPolicy.Status.Conditions, upgrade.UpgradeAbortedCondition)
+	}
+	r.Log.V(consts.LogLevelInfo).Info("Updating upgrade aborted condition", "aborted", aborted)
+	err := r.Status().Update(ctx, nicClusterPolicy)
+	if err != nil {
+		r.Log.V(consts.LogLevelError).Error(err, "Failed to update NicClusterPolicy status")
+		return err
+	}
+	return nil
+}
+
+// updatePendingApprovalCondition sets upgrade.UpgradePendingApprovalCondition on the NicClusterPolicy status
+// listing the driver images waiting for approval, the condition is removed if no images wait for approval
+func (r *UpgradeReconciler) updatePendingApprovalCondition(
+	ctx context.Context, nicClusterPolicy *mellanoxv1alpha1.NicClusterPolicy, images []string) error {
+	current := meta.FindStatusCondition(nicClusterPolicy.Status.Conditions, upgrade.UpgradePendingApprovalCondition)
+	if len(images) != 0 {
+		message := fmt.Sprintf("OFED upgrade waits for approval, to approve it annotate the NicClusterPolicy "+
+			"with %s=%s", upgrade.UpgradeApprovedAnnotation, strings.Join(images, ","))
+		if current != nil && current.Status == metav1.ConditionTrue && current.Message == message {
+			return nil
+		}
+		meta.SetStatusCondition(&nicClusterPolicy.Status.Conditions, metav1.Condition{
+			Type:    upgrade.UpgradePendingApprovalCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  "ApprovalRequired",
+			Message: message,
+		})
+	} else {
+		if current == nil {
+			return nil
+		}
+		meta.RemoveStatusCondition(&nicClusterPolicy.Status.Conditions, upgrade.UpgradePendingApprovalCondition)
+	}
+	r.Log.V(consts.LogLevelInfo).Info("Updating upgrade pending approval condition", "images", images)
+	err := r.Status().Update(ctx, nicClusterPolicy)
+	if err != nil {
+		r.Log.V(consts.LogLevelError).Error(err, "Failed to update NicClusterPolicy status")
+		return err
+	}
+	return nil
+}
+
+// updateManualUpgradeCondition sets upgrade.UpgradeManualRequiredCondition on the NicClusterPolicy status
+// listing the nodes which require manual upgrade, the condition is removed if no nodes require it
+func (r *UpgradeReconciler) updateManualUpgradeCondition(
+	ctx context.Context, nicClusterPolicy *mellanoxv1alpha1.NicClusterPolicy, nodes []string) error {
+	current := meta.FindStatusCondition(nicClusterPolicy.Status.Conditions, upgrade.UpgradeManualRequiredCondition)
+	if len(nodes) != 0 {
+		message := fmt.Sprintf("Node groups of the nodes don't allow disruption, restart the OFED driver pods "+
+			"on them manually: %s", strings.Join(nodes, ","))
+		if current != nil && current.Status == metav1.ConditionTrue && current.Message == message {
+			return nil
+		}
+		meta.SetStatusCondition(&nicClusterPolicy.Status.Conditions, metav1.Condition{
+			Type:    upgrade.UpgradeManualRequiredCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  "DisruptionNotAllowed",
+			Message: message,
+		})
+	} else {
+		if current == nil {
+			return nil
+		}
+		meta.RemoveStatusCondition(&nicClusterPolicy.Status.Conditions, upgrade.UpgradeManualRequiredCondition)
+	}
+	r.Log.V(consts.LogLevelInfo).Info("Updating upgrade manual required condition", "nodes", nodes)
+	err := r.Status().Update(ctx, nicClusterPolicy)
+	if err != nil {
+		r.Log.V(consts.LogLevelError).Error(err, "Failed to update NicClusterPolicy status")
+		return err
+	}
+	return nil
+}
+
+// updateStalledCondition sets upgrade.UpgradeStalledCondition on the NicClusterPolicy if any node stays
+// in a pre-upgrade state longer than allowed by the upgrade policy and removes it otherwise
+func (r *UpgradeReconciler) updateStalledCondition(ctx context.Context,
+	nicClusterPolicy *mellanoxv1alpha1.NicClusterPolicy, upgradePolicy *mellanoxv1alpha1.OfedUpgradePolicySpec,
+	nodes []string) error {
+	current := meta.FindStatusCondition(nicClusterPolicy.Status.Conditions, upgrade.UpgradeStalledCondition)
+	if len(nodes) != 0 {
+		message := fmt.Sprintf("Nodes stay in %s state longer than %d seconds: %s",
+			strings.Join(upgrade.PreUpgradeStates(), ", "), upgradePolicy.MaxPreUpgradeStateSeconds,
+			strings.Join(nodes, ","))
+		if current != nil && current.Status == metav1.ConditionTrue && current.Message == message {
+			return nil
+		}
+		meta.SetStatusCondition(&nicClusterPolicy.Status.Conditions, metav1.Condition{
+			Type:    upgrade.UpgradeStalledCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  "MaxPreUpgradeStateTimeExceeded",
+			Message: message,
+		})
+	} else {
+		if current == nil {
+			return nil
+		}
+		meta.RemoveStatusCondition(&nicClusterPolicy.Status.Conditions, upgrade.UpgradeStalledCondition)
+	}
+	r.Log.V(consts.LogLevelInfo).Info("Updating upgrade stalled condition", "nodes", nodes)
+	err := r.Status().Update(ctx, nicClusterPolicy)
+	if err != nil {
+		r.Log.V(consts.LogLevelError).Error(err, "Failed to update NicClusterPolicy status")
+		return err
+	}
+	return nil
+}
+
+// updateUpgradeStatus sets the nodes cordoned by the upgrade flow and the quarantined nodes in the upgrade status
+// of the NicClusterPolicy, the upgrade status is removed if no nodes are cordoned or quarantined
+func (r *UpgradeReconciler) updateUpgradeStatus(ctx context.Context,
+	nicClusterPolicy *mellanoxv1alpha1.NicClusterPolicy, cordonedNodes, quarantinedNodes []string) error {
+	var upgradeStatus *mellanoxv1alpha1.UpgradeStatus
+	if len(cordonedNodes) != 0 || len(quarantinedNodes) != 0 {
+		upgradeStatus = &mellanoxv1alpha1.UpgradeStatus{
+			CordonedNodes:    cordonedNodes,
+			QuarantinedNodes: quarantinedNodes,
+		}
+	}
+	if reflect.DeepEqual(nicClusterPolicy.Status.Upgrade, upgradeStatus) {
+		return nil
+	}
+	nicClusterPolicy.Status.Upgrade = upgradeStatus
+	r.Log.V(consts.LogLevelInfo).Info("Updating upgrade status",
+		"cordoned nodes", cordonedNodes, "quarantined nodes", quarantinedNodes)
+	err := r.Status().Update(ctx, nicClusterPolicy)
+	if err != nil {
+		r.Log.V(consts.LogLevelError).Error(err, "Failed to update NicClusterPolicy status")
+		return err
+	}
+	return nil
+}
+
+// getApprovedImages returns the driver images listed in upgrade.UpgradeApprovedAnnotation of the NicClusterPolicy
+func getApprovedImages(nicClusterPolicy *mellanoxv1alpha1.NicClusterPolicy) []string {
+	value := nicClusterPolicy.Annotations[upgrade.UpgradeApprovedAnnotation]
+	images := make([]string, 0)
+	for _, image := range strings.Split(value, ",") {
+		image = strings.TrimSpace(image)
+		if image != "" {
+			images = append(images, image)
+		}
+	}
+	return images
+}
+
+// configureEventSink points the upgrade event sink to the endpoint from the upgrade policy,
+// the sink is disabled if the endpoint is not set or its Authorization header can't be read,
+// unless the Secret key is optional
+func (r *UpgradeReconciler) configureEventSink(ctx context.Context, spec *mellanoxv1alpha1.UpgradeEventSinkSpec) {
+	if r.EventSink == nil {
+		return
+	}
+	if spec == nil {
+		r.EventSink.Configure("", "")
+		return
+	}
+	authHeader := ""
+	if ref := spec.AuthHeaderSecretRef; ref != nil {
+		secret := &corev1.Secret{}
+		err := r.Get(ctx, types.NamespacedName{
+			Namespace: config.FromEnv().State.NetworkOperatorResourceNamespace, Name: ref.Name}, secret)
+		optional := ref.Optional != nil && *ref.Optional
+		if err != nil && !(optional && apierrors.IsNotFound(err)) {
+			r.Log.V(consts.LogLevelWarning).Info("Failed to read upgrade event sink Secret, the sink is disabled",
+				"secret", ref.Name, "error", err.Error())
+			r.EventSink.Configure("", "")
+			return
+		}
+		value, ok := secret.Data[ref.Key]
+		if !ok && !optional {
+			r.Log.V(consts.LogLevelWarning).Info("Upgrade event sink Secret doesn't have the key, the sink is disabled",
+				"secret", ref.Name, "key", ref.Key)
+			r.EventSink.Configure("", "")
+			return
+		}
+		authHeader = string(value)
+	}
+	r.EventSink.Configure(spec.URL, authHeader)
+}
+
+// removeNodeUpgradeStateAnnotations loops over nodes in the cluster and removes upgrade.UpgradeStateAnnotation,
+// upgrade.UpgradeStateTimestampAnnotation, upgrade.UpgradeDoneTimestampAnnotation,
+// upgrade.UpgradeSoakStartTimestampAnnotation, upgrade.UpgradeBarePodsAnnotation,
+// upgrade.UpgradeDrainBlockedAnnotation, upgrade.UpgradeManualRequiredAnnotation, uncordon retry and pods wait
+// annotations, upgrade failures and quarantine, labels and taints added to the nodes for the upgrade are removed
+// and paused device plugins are resumed as well. The upgrade history is kept, an upgrade in progress is recorded
+// as canceled. Quarantined nodes stay cordoned, upgrade.UpgradeQuarantineCordonAnnotation explains the cordon until
+// the node is uncordoned. The nodes excluded from the management of the operator are cleaned up once they are
+// included back
 // It is used for cleanup when autoUpgrade feature gets disabled
 func (r *UpgradeReconciler) removeNodeUpgradeStateAnnotations(ctx context.Context) error {
 	r.Log.V(consts.LogLevelInfo).Info("Resetting node upgrade annotations from all nodes")
@@ -121,9 +422,50 @@ func (r *UpgradeReconciler) removeNodeUpgradeStateAnnotations(ctx context.Contex
 	}
 	for i := range nodeList.Items {
 		node := &nodeList.Items[i]
-		_, present := node.Annotations[upgrade.UpgradeStateAnnotation]
-		if present {
+		if nodeinfo.IsNodeExcluded(node) {
+			continue
+		}
+		_, statePresent := node.Annotations[upgrade.UpgradeStateAnnotation]
+		_, timestampPresent := node.Annotations[upgrade.UpgradeDoneTimestampAnnotation]
+		_, soakPresent := node.Annotations[upgrade.UpgradeSoakStartTimestampAnnotation]
+		_, retriesPresent := node.Annotations[upgrade.UpgradeUncordonRetriesAnnotation]
+		_, barePodsPresent := node.Annotations[upgrade.UpgradeBarePodsAnnotation]
+		_, podsWaitPresent := node.Annotations[upgrade.UpgradeUncordonPodsWaitStartAnnotation]
+		_, drainBlockedPresent := node.Annotations[upgrade.UpgradeDrainBlockedAnnotation]
+		_, manualPresent := node.Annotations[upgrade.UpgradeManualRequiredAnnotation]
+		_, failuresPresent := node.Annotations[upgrade.UpgradeFailuresAnnotation]
+		_, quarantinedPresent := node.Annotations[upgrade.UpgradeQuarantinedAnnotation]
+		_, quarantineLabelPresent := node.Labels[upgrade.OfedUpgradeQuarantinedLabel]
+		marksPresent := upgrade.RemoveNodeUpgradeMarks(node)
+		pausePresent := upgrade.ResumeDevicePlugins(node)
+		historyChanged := upgrade.SetUpgradeHistoryOutcome(r.Log, node, upgrade.UpgradeOutcomeCanceled)
+		_, cordonNotePresent := node.Annotations[upgrade.UpgradeQuarantineCordonAnnotation]
+		cordonNoteRequired := node.Spec.Unschedulable &&
+			(quarantinedPresent || quarantineLabelPresent || cordonNotePresent)
+		if statePresent || timestampPresent || soakPresent || retriesPresent || barePodsPresent || podsWaitPresent ||
+			drainBlockedPresent || manualPresent || failuresPresent || quarantinedPresent || quarantineLabelPresent ||
+			marksPresent || pausePresent || historyChanged || cordonNotePresent != cordonNoteRequired {
+			if cordonNoteRequired {
+				if node.Annotations == nil {
+					node.Annotations = make(map[string]string)
+				}
+				node.Annotations[upgrade.UpgradeQuarantineCordonAnnotation] = upgrade.UpgradeQuarantineCordonMessage
+			} else {
+				delete(node.Annotations, upgrade.UpgradeQuarantineCordonAnnotation)
+			}
 			delete(node.Annotations, upgrade.UpgradeStateAnnotation)
+			delete(node.Annotations, upgrade.UpgradeStateTimestampAnnotation)
+			delete(node.Annotations, upgrade.UpgradeDoneTimestampAnnotation)
+			delete(node.Annotations, upgrade.UpgradeSoakStartTimestampAnnotation)
+			delete(node.Annotations, upgrade.UpgradeUncordonRetriesAnnotation)
+			delete(node.Annotations, upgrade.UpgradeUncordonCheckTimestampAnnotation)
+			delete(node.Annotations, upgrade.UpgradeBarePodsAnnotation)
+			delete(node.Annotations, upgrade.UpgradeUncordonPodsWaitStartAnnotation)
+			delete(node.Annotations, upgrade.UpgradeDrainBlockedAnnotation)
+			delete(node.Annotations, upgrade.UpgradeManualRequiredAnnotation)
+			delete(node.Annotations, upgrade.UpgradeFailuresAnnotation)
+			delete(node.Annotations, upgrade.UpgradeQuarantinedAnnotation)
+			delete(node.Labels, upgrade.OfedUpgradeQuarantinedLabel)
 			err = r.Update(ctx, node)
 			if err != nil {
 				r.Log.V(consts.LogLevelError).Error(
@@ -139,12 +481,15 @@ func (r *UpgradeReconciler) removeNodeUpgradeStateAnnotations(ctx context.Contex
 // It creates mappings between nodes and their upgrade state
 // Nodes are grouped together with the driver POD running on them and the daemon set, controlling this pod
 // This state is then used as an input for the upgrade.ClusterUpgradeStateManager
-func (r *UpgradeReconciler) BuildState(ctx context.Context) (*upgrade.ClusterUpgradeState, error) {
+// The driver pods and DaemonSets are listed in the namespace of the OFED driver. The nodes excluded from the
+// management of the operator are skipped, they keep their upgrade state until they are included back
+func (r *UpgradeReconciler) BuildState(
+	ctx context.Context, namespace string) (*upgrade.ClusterUpgradeState, error) {
 	r.Log.V(consts.LogLevelInfo).Info("Building state")
 
 	upgradeState := upgrade.NewClusterUpgradeState()
 
-	daemonSets, err := r.getDriverDaemonSets(ctx)
+	daemonSets, err := r.getDriverDaemonSets(ctx, namespace)
 	if err != nil {
 		r.Log.V(consts.LogLevelError).Error(err, "Failed to get driver daemon set list")
 		return nil, err
@@ -156,7 +501,7 @@ func (r *UpgradeReconciler) BuildState(ctx context.Context) (*upgrade.ClusterUpg
 	podList := &corev1.PodList{}
 
 	err = r.List(ctx, podList,
-		client.InNamespace(config.FromEnv().State.NetworkOperatorResourceNamespace),
+		client.InNamespace(namespace),
 		client.MatchingLabels{upgrade.OfedDriverLabel: ""})
 	if err != nil {
 		return nil, err
@@ -179,10 +524,21 @@ func (r *UpgradeReconciler) BuildState(ctx context.Context) (*upgrade.ClusterUpg
 		}
 
 		nodeState, err := r.buildNodeUpgradeState(ctx, pod, ownerDaemonSet)
+		if apierrors.IsNotFound(err) {
+			// the pod of a deleted node is left until it is garbage collected
+			r.Log.V(consts.LogLevelWarning).Info("Node of OFED Driver Pod doesn't exist, skipping the pod",
+				"pod", pod.Name, "node", pod.Spec.NodeName)
+			continue
+		}
 		if err != nil {
 			r.Log.V(consts.LogLevelError).Error(err, "Failed to build node upgrade state for pod", "pod", pod)
 			return nil, err
 		}
+		if nodeinfo.IsNodeExcluded(nodeState.Node) {
+			r.Log.V(consts.LogLevelInfo).Info("Node is excluded from the operator management, skipping the node",
+				"node", nodeState.Node.Name)
+			continue
+		}
 		nodeStateAnnotation := nodeState.Node.Annotations[upgrade.UpgradeStateAnnotation]
 		upgradeState.NodeStates[nodeStateAnnotation] = append(
 			upgradeState.NodeStates[nodeStateAnnotation], nodeState)
@@ -208,12 +564,13 @@ func (r *UpgradeReconciler) buildNodeUpgradeState(
 }
 
 // getDriverDaemonSets retrieves DaemonSets labeled with OfedDriverLabel and returns UID->DaemonSet map
-func (r *UpgradeReconciler) getDriverDaemonSets(ctx context.Context) (map[types.UID]*appsv1.DaemonSet, error) {
+func (r *UpgradeReconciler) getDriverDaemonSets(
+	ctx context.Context, namespace string) (map[types.UID]*appsv1.DaemonSet, error) {
 	// Get list of driver pods
 	daemonSetList := &appsv1.DaemonSetList{}
 
 	err := r.List(ctx, daemonSetList,
-		client.InNamespace(config.FromEnv().State.NetworkOperatorResourceNamespace),
+		client.InNamespace(namespace),
 		client.MatchingLabels{upgrade.OfedDriverLabel: ""})
 	if err != nil {
 		r.Log.V(consts.LogLevelError).Error(err, "Failed to get daemon set list")
@@ -229,6 +586,10 @@ func (r *UpgradeReconciler) getDriverDaemonSets(ctx context.Context) (map[types.
 	return daemonSetMap, nil
 }
 
+// upgradeControllerName is used as a "name" label of workqueue_* metrics and
+// as a "controller" label of controller_runtime_reconcile_* metrics for UpgradeReconciler
+const upgradeControllerName = "ofed-upgrade"
+
 // SetupWithManager sets up the controller with the Manager.
 //nolint:dupl
 func (r *UpgradeReconciler) SetupWithManager(mgr ctrl.Manager) error {
@@ -253,33 +614,102 @@ func (r *UpgradeReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		},
 	}
 
-	createUpdateEnqueue := handler.Funcs{
+	// react on events only for OFED daemon set
+	daemonSetPredicates := builder.WithPredicates(predicate.NewPredicateFuncs(hasOfedDriverLabel))
+
+	// release the data of deleted nodes, so that neither the state nor the metrics reference them
+	nodeEnqueue := handler.Funcs{
 		CreateFunc: func(e event.CreateEvent, q workqueue.RateLimitingInterface) {
 			qHandler(q)
 		},
 		UpdateFunc: func(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
 			qHandler(q)
 		},
+		DeleteFunc: func(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+			r.forgetNode(e.Object.GetName())
+			qHandler(q)
+		},
 	}
 
-	// react on events only for OFED daemon set
-	daemonSetPredicates := builder.WithPredicates(predicate.NewPredicateFuncs(func(object client.Object) bool {
-		labels := object.GetLabels()
-		_, ok := labels[upgrade.OfedDriverLabel]
-		return ok
-	}))
+	// ignore all update events for nodes except when annotations or labels where changed,
+	// e.g. the quarantine label was removed
+	nodePredicates := builder.WithPredicates(
+		predicate.Or(predicate.AnnotationChangedPredicate{}, predicate.LabelChangedPredicate{}))
 
-	// ignore all update events for nodes except when annotations where changed
-	nodePredicates := builder.WithPredicates(predicate.AnnotationChangedPredicate{})
+	// react on OFED driver pods only when they are created, deleted or their readiness changes,
+	// so that the node upgrade state follows the restarted driver pod without waiting for the planned requeue
+	driverPodPredicates := builder.WithPredicates(predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return hasOfedDriverLabel(e.Object)
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if !hasOfedDriverLabel(e.ObjectNew) {
+				return false
+			}
+			oldPod, oldOk := e.ObjectOld.(*corev1.Pod)
+			newPod, newOk := e.ObjectNew.(*corev1.Pod)
+			return !oldOk || !newOk || driverPodStatusChanged(oldPod, newPod)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return hasOfedDriverLabel(e.Object)
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return false
+		},
+	})
 
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&mellanoxv1alpha1.NicClusterPolicy{}).
+		// use a dedicated name, otherwise the controller gets the same name as NicClusterPolicyReconciler
+		// and the workqueue and reconcile metrics exported by controller-runtime for both controllers are mixed
+		Named(upgradeControllerName).
 		// set MaxConcurrentReconciles to 1, by default it is already 1, but
 		// we set it explicitly here to indicate that we rely on this default behavior
 		// UpgradeReconciler contains logic which is not concurrent friendly
 		WithOptions(controller.Options{MaxConcurrentReconciles: 1}).
 		Watches(&source.Kind{Type: &mellanoxv1alpha1.NicClusterPolicy{}}, createUpdateDeleteEnqueue).
-		Watches(&source.Kind{Type: &corev1.Node{}}, createUpdateEnqueue, nodePredicates).
+		Watches(&source.Kind{Type: &corev1.Node{}}, nodeEnqueue, nodePredicates).
 		Watches(&source.Kind{Type: &appsv1.DaemonSet{}}, createUpdateDeleteEnqueue, daemonSetPredicates).
+		Watches(&source.Kind{Type: &corev1.Pod{}}, createUpdateDeleteEnqueue, driverPodPredicates).
 		Complete(r)
 }
+
+// forgetNode removes the metrics of the deleted node, the node locks of the node upgrade state provider
+// are released once they are unlocked
+func (r *UpgradeReconciler) forgetNode(nodeName string) {
+	r.Log.V(consts.LogLevelInfo).Info("Node is deleted, releasing its upgrade data", "node", nodeName)
+	deleteNodeUpgradeStateMetrics(nodeName)
+}
+
+// hasOfedDriverLabel returns true if the object has the OFED driver label, e.g. an OFED driver pod
+func hasOfedDriverLabel(object client.Object) bool {
+	_, ok := object.GetLabels()[upgrade.OfedDriverLabel]
+	return ok
+}
+
+// driverPodStatusChanged returns true if the change of the driver pod is relevant for the upgrade flow:
+// the pod is scheduled, its phase changes or a container becomes ready, not ready, restarts or starts waiting
+func driverPodStatusChanged(oldPod, newPod *corev1.Pod) bool {
+	if oldPod.Spec.NodeName != newPod.Spec.NodeName || oldPod.Status.Phase != newPod.Status.Phase ||
+		len(oldPod.Status.ContainerStatuses) != len(newPod.Status.ContainerStatuses) {
+		return true
+	}
+	for i := range newPod.Status.ContainerStatuses {
+		oldStatus := &oldPod.Status.ContainerStatuses[i]
+		newStatus := &newPod.Status.ContainerStatuses[i]
+		if oldStatus.Ready != newStatus.Ready || oldStatus.RestartCount != newStatus.RestartCount ||
+			waitingReason(oldStatus) != waitingReason(newStatus) {
+			return true
+		}
+	}
+	return false
+}
+
+// waitingReason returns the reason the container is waiting for, e.g. CrashLoopBackOff,
+// empty string if the container is not waiting
+func waitingReason(status *corev1.ContainerStatus) string {
+	if status.State.Waiting == nil {
+		return ""
+	}
+	return status.State.Waiting.Reason
+}
@@ -0,0 +1,154 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/Mellanox/network-operator/pkg/config"
+	"github.com/Mellanox/network-operator/pkg/upgrade"
+)
+
+var _ = Describe("Upgrade Controller", func() {
+	It("should detect driver pod changes relevant for the upgrade flow", func() {
+		pod := &corev1.Pod{
+			Spec: corev1.PodSpec{NodeName: "node-1"},
+			Status: corev1.PodStatus{
+				Phase:             corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{{Name: "mofed-container", Ready: false}},
+			},
+		}
+		Expect(driverPodStatusChanged(pod, pod.DeepCopy())).To(BeFalse())
+
+		updated := pod.DeepCopy()
+		updated.Labels = map[string]string{"new": "label"}
+		Expect(driverPodStatusChanged(pod, updated)).To(BeFalse())
+
+		updated = pod.DeepCopy()
+		updated.Status.ContainerStatuses[0].Ready = true
+		Expect(driverPodStatusChanged(pod, updated)).To(BeTrue())
+
+		updated = pod.DeepCopy()
+		updated.Status.ContainerStatuses[0].RestartCount = 1
+		updated.Status.ContainerStatuses[0].State.Waiting = &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}
+		Expect(driverPodStatusChanged(pod, updated)).To(BeTrue())
+
+		updated = pod.DeepCopy()
+		updated.Status.Phase = corev1.PodFailed
+		Expect(driverPodStatusChanged(pod, updated)).To(BeTrue())
+	})
+
+	It("should skip driver pods of deleted and excluded nodes and forget deleted nodes", func() {
+		savedExcludedNodeLabel := config.FromEnv().State.ExcludedNodeLabel
+		defer func() { config.FromEnv().State.ExcludedNodeLabel = savedExcludedNodeLabel }()
+		config.FromEnv().State.ExcludedNodeLabel = "network.nvidia.com/operator.exclude"
+		ds := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Namespace: "driver", Name: "mofed-ds", UID: "ds-uid",
+			Labels: map[string]string{upgrade.OfedDriverLabel: ""}}}
+		newPod := func(name, nodeName string) *corev1.Pod {
+			return &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "driver", Name: name,
+					Labels:          map[string]string{upgrade.OfedDriverLabel: ""},
+					OwnerReferences: []metav1.OwnerReference{{Kind: "DaemonSet", Name: ds.Name, UID: ds.UID}}},
+				Spec: corev1.PodSpec{NodeName: nodeName},
+			}
+		}
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1",
+			Annotations: map[string]string{upgrade.UpgradeStateAnnotation: upgrade.UpgradeStateDrain}}}
+		excludedNode := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2",
+			Labels:      map[string]string{config.FromEnv().State.ExcludedNodeLabel: "true"},
+			Annotations: map[string]string{upgrade.UpgradeStateAnnotation: upgrade.UpgradeStateDrain}}}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+			ds, node, excludedNode, newPod("mofed-1", "node-1"), newPod("mofed-2", "deleted-node"),
+			newPod("mofed-3", "node-2")).Build()
+		reconciler := &UpgradeReconciler{Client: fakeClient, Log: ctrl.Log,
+			NodeUpgradeStateProvider: upgrade.NewNodeUpgradeStateProvider(fakeClient, ctrl.Log)}
+
+		state, err := reconciler.BuildState(context.TODO(), "driver")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state.NodeStates[upgrade.UpgradeStateDrain]).To(HaveLen(1))
+		Expect(state.NodeStates[upgrade.UpgradeStateDrain][0].Node.Name).To(Equal("node-1"))
+
+		nodeUpgradeStateSecondsGauge.Reset()
+		nodeUpgradeStateSecondsGauge.WithLabelValues("node-1", upgrade.UpgradeStateDrain).Set(10)
+		nodeUpgradeStateSecondsGauge.WithLabelValues("deleted-node", upgrade.UpgradeStateDrain).Set(10)
+		reconciler.forgetNode("deleted-node")
+		Expect(testutil.CollectAndCount(nodeUpgradeStateSecondsGauge)).To(Equal(1))
+	})
+	It("should keep the upgrade annotations of excluded nodes on cleanup", func() {
+		savedExcludedNodeLabel := config.FromEnv().State.ExcludedNodeLabel
+		defer func() { config.FromEnv().State.ExcludedNodeLabel = savedExcludedNodeLabel }()
+		config.FromEnv().State.ExcludedNodeLabel = "network.nvidia.com/operator.exclude"
+		newNode := func(name string, labels map[string]string) *corev1.Node {
+			return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels,
+				Annotations: map[string]string{upgrade.UpgradeStateAnnotation: upgrade.UpgradeStateDrain}}}
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+			newNode("node-1", nil),
+			newNode("node-2", map[string]string{config.FromEnv().State.ExcludedNodeLabel: "true"})).Build()
+		reconciler := &UpgradeReconciler{Client: fakeClient, Log: ctrl.Log}
+
+		Expect(reconciler.removeNodeUpgradeStateAnnotations(context.TODO())).To(Succeed())
+		node := &corev1.Node{}
+		Expect(fakeClient.Get(context.TODO(), client.ObjectKey{Name: "node-1"}, node)).To(Succeed())
+		Expect(node.Annotations).NotTo(HaveKey(upgrade.UpgradeStateAnnotation))
+		node = &corev1.Node{}
+		Expect(fakeClient.Get(context.TODO(), client.ObjectKey{Name: "node-2"}, node)).To(Succeed())
+		Expect(node.Annotations).To(HaveKeyWithValue(upgrade.UpgradeStateAnnotation, upgrade.UpgradeStateDrain))
+	})
+	It("should explain the cordon of quarantined nodes on cleanup until they are uncordoned", func() {
+		quarantined := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-1",
+				Labels:      map[string]string{upgrade.OfedUpgradeQuarantinedLabel: "true"},
+				Annotations: map[string]string{upgrade.UpgradeQuarantinedAnnotation: "2022-01-01T00:00:00Z"}},
+			Spec: corev1.NodeSpec{Unschedulable: true}}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(quarantined).Build()
+		reconciler := &UpgradeReconciler{Client: fakeClient, Log: ctrl.Log}
+
+		Expect(reconciler.removeNodeUpgradeStateAnnotations(context.TODO())).To(Succeed())
+		node := &corev1.Node{}
+		Expect(fakeClient.Get(context.TODO(), client.ObjectKey{Name: "node-1"}, node)).To(Succeed())
+		Expect(node.Labels).NotTo(HaveKey(upgrade.OfedUpgradeQuarantinedLabel))
+		Expect(node.Annotations).NotTo(HaveKey(upgrade.UpgradeQuarantinedAnnotation))
+		Expect(node.Annotations).To(HaveKeyWithValue(
+			upgrade.UpgradeQuarantineCordonAnnotation, upgrade.UpgradeQuarantineCordonMessage))
+		Expect(node.Spec.Unschedulable).To(BeTrue())
+
+		// the annotation is kept while the node is cordoned
+		Expect(reconciler.removeNodeUpgradeStateAnnotations(context.TODO())).To(Succeed())
+		node = &corev1.Node{}
+		Expect(fakeClient.Get(context.TODO(), client.ObjectKey{Name: "node-1"}, node)).To(Succeed())
+		Expect(node.Annotations).To(HaveKey(upgrade.UpgradeQuarantineCordonAnnotation))
+
+		node.Spec.Unschedulable = false
+		Expect(fakeClient.Update(context.TODO(), node)).To(Succeed())
+		Expect(reconciler.removeNodeUpgradeStateAnnotations(context.TODO())).To(Succeed())
+		node = &corev1.Node{}
+		Expect(fakeClient.Get(context.TODO(), client.ObjectKey{Name: "node-1"}, node)).To(Succeed())
+		Expect(node.Annotations).NotTo(HaveKey(upgrade.UpgradeQuarantineCordonAnnotation))
+	})
+})
@@ -0,0 +1,62 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/Mellanox/network-operator/pkg/upgrade"
+)
+
+var (
+	// nodeUpgradeStateSecondsGauge is set to the time the node has spent in its current pre-upgrade state
+	nodeUpgradeStateSecondsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "network_operator_ofed_upgrade_node_state_seconds",
+		Help: "Time in seconds the node has spent in its current upgrade-required, pending-approval or drain state",
+	}, []string{"node", "state"})
+	// stalledNodesGauge is set to the number of nodes exceeding the max pre-upgrade state time of the upgrade policy
+	stalledNodesGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "network_operator_ofed_upgrade_stalled_nodes",
+		Help: "Number of nodes which stay in a pre-upgrade state longer than allowed by the upgrade policy",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(nodeUpgradeStateSecondsGauge, stalledNodesGauge)
+}
+
+// updateUpgradeStateMetrics reports the time the nodes have spent in pre-upgrade states,
+// metrics of the nodes which left these states are removed
+func updateUpgradeStateMetrics(state *upgrade.ClusterUpgradeState, stalledNodes []string) {
+	nodeUpgradeStateSecondsGauge.Reset()
+	for _, stateName := range upgrade.PreUpgradeStates() {
+		for _, nodeState := range state.NodeStates[stateName] {
+			if duration, ok := upgrade.GetNodeUpgradeStateDuration(nodeState.Node); ok {
+				nodeUpgradeStateSecondsGauge.WithLabelValues(nodeState.Node.Name, stateName).Set(duration.Seconds())
+			}
+		}
+	}
+	stalledNodesGauge.Set(float64(len(stalledNodes)))
+}
+
+// deleteNodeUpgradeStateMetrics removes the metrics of the node, e.g. once the node is deleted
+func deleteNodeUpgradeStateMetrics(nodeName string) {
+	for _, stateName := range upgrade.PreUpgradeStates() {
+		nodeUpgradeStateSecondsGauge.DeleteLabelValues(nodeName, stateName)
+	}
+}
@@ -0,0 +1,159 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+)
+
+// whereaboutsType is the type of the whereabouts IPAM plugin in the IPAM configuration of the network
+const whereaboutsType = "whereabouts"
+
+// whereaboutsRange is a range of the whereabouts IPAM configuration
+type whereaboutsRange struct {
+	Range      string   `json:"range"`
+	RangeStart string   `json:"range_start"`
+	RangeEnd   string   `json:"range_end"`
+	Exclude    []string `json:"exclude"`
+}
+
+// whereaboutsConfig is the part of the whereabouts IPAM configuration read by the operator,
+// the ranges are set either at the top level or in ipRanges
+type whereaboutsConfig struct {
+	Type string `json:"type"`
+	whereaboutsRange
+	IPRanges []whereaboutsRange `json:"ipRanges"`
+}
+
+// ipInterval is an inclusive interval of IP addresses
+type ipInterval struct {
+	first, last *big.Int
+}
+
+// whereaboutsRangeWarnings returns warnings about the ranges of the whereabouts IPAM configuration of the network:
+// excludes which are not within their range have no effect, excludes which consume the entire range leave no IPs
+// to allocate. Nil is returned if the network doesn't use whereabouts, ranges which are not CIDRs are skipped
+func whereaboutsRangeWarnings(ipam string) []string {
+	ipamConfig := &whereaboutsConfig{}
+	if err := json.Unmarshal([]byte(ipam), ipamConfig); err != nil || ipamConfig.Type != whereaboutsType {
+		return nil
+	}
+	ranges := ipamConfig.IPRanges
+	if ipamConfig.Range != "" {
+		ranges = append([]whereaboutsRange{ipamConfig.whereaboutsRange}, ranges...)
+	}
+
+	var warnings []string
+	for i := range ranges {
+		warnings = append(warnings, rangeWarnings(&ranges[i])...)
+	}
+	return warnings
+}
+
+// rangeWarnings returns the warnings about the excludes of a single whereabouts range
+func rangeWarnings(r *whereaboutsRange) []string {
+	_, rangeNet, err := net.ParseCIDR(r.Range)
+	if err != nil {
+		return nil
+	}
+	pool := allocatableInterval(rangeNet, r.RangeStart, r.RangeEnd)
+
+	var warnings []string
+	var excluded []ipInterval
+	for _, exclude := range r.Exclude {
+		_, excludeNet, err := net.ParseCIDR(exclude)
+		if err != nil {
+			continue
+		}
+		excludeOnes, _ := excludeNet.Mask.Size()
+		rangeOnes, _ := rangeNet.Mask.Size()
+		if len(excludeNet.IP) != len(rangeNet.IP) || excludeOnes < rangeOnes || !rangeNet.Contains(excludeNet.IP) {
+			warnings = append(warnings, fmt.Sprintf("exclude %s is not within range %s and has no effect",
+				exclude, r.Range))
+			continue
+		}
+		excluded = append(excluded, cidrInterval(excludeNet))
+	}
+	if intervalsCover(excluded, pool) {
+		warnings = append(warnings, fmt.Sprintf("excludes %s consume the entire range %s, no IPs can be allocated",
+			strings.Join(r.Exclude, ","), r.Range))
+	}
+	return warnings
+}
+
+// allocatableInterval returns the addresses of the range which whereabouts may allocate, limited by the optional
+// range start and end. The network and broadcast addresses of IPv4 ranges are not allocated
+func allocatableInterval(rangeNet *net.IPNet, rangeStart, rangeEnd string) ipInterval {
+	pool := cidrInterval(rangeNet)
+	ones, bits := rangeNet.Mask.Size()
+	if bits == 8*net.IPv4len && bits-ones > 1 {
+		pool.first.Add(pool.first, big.NewInt(1))
+		pool.last.Sub(pool.last, big.NewInt(1))
+	}
+	if start := net.ParseIP(rangeStart); start != nil && rangeNet.Contains(start) {
+		if value := ipToInt(start, len(rangeNet.IP)); value.Cmp(pool.first) > 0 {
+			pool.first = value
+		}
+	}
+	if end := net.ParseIP(rangeEnd); end != nil && rangeNet.Contains(end) {
+		if value := ipToInt(end, len(rangeNet.IP)); value.Cmp(pool.last) < 0 {
+			pool.last = value
+		}
+	}
+	return pool
+}
+
+// cidrInterval returns the interval of all addresses of the network
+func cidrInterval(ipNet *net.IPNet) ipInterval {
+	first := ipToInt(ipNet.IP, len(ipNet.IP))
+	ones, bits := ipNet.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	return ipInterval{first: first, last: new(big.Int).Sub(new(big.Int).Add(first, size), big.NewInt(1))}
+}
+
+// ipToInt converts the IP address of the given length to an integer
+func ipToInt(ip net.IP, length int) *big.Int {
+	if length == net.IPv4len {
+		ip = ip.To4()
+	} else {
+		ip = ip.To16()
+	}
+	return new(big.Int).SetBytes(ip)
+}
+
+// intervalsCover returns true if the union of the intervals contains every address of the pool
+func intervalsCover(intervals []ipInterval, pool ipInterval) bool {
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].first.Cmp(intervals[j].first) < 0 })
+	next := new(big.Int).Set(pool.first)
+	for _, interval := range intervals {
+		if interval.first.Cmp(next) > 0 {
+			return false
+		}
+		if interval.last.Cmp(next) >= 0 {
+			next.Add(interval.last, big.NewInt(1))
+		}
+		if next.Cmp(pool.last) > 0 {
+			return true
+		}
+	}
+	return false
+}
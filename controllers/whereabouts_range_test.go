@@ -0,0 +1,64 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("whereabouts range validation", func() {
+	It("should not warn about excludes within the range", func() {
+		Expect(whereaboutsRangeWarnings(`{"type": "whereabouts", "range": "192.168.2.0/24",
+			"exclude": ["192.168.2.0/28", "192.168.2.128/25"]}`)).To(BeEmpty())
+		Expect(whereaboutsRangeWarnings(`{"type": "host-local", "range": "192.168.2.0/24",
+			"exclude": ["10.0.0.0/8"]}`)).To(BeEmpty())
+		Expect(whereaboutsRangeWarnings("invalid")).To(BeEmpty())
+	})
+
+	It("should warn about excludes outside of the range", func() {
+		Expect(whereaboutsRangeWarnings(`{"type": "whereabouts", "range": "192.168.2.0/24",
+			"exclude": ["192.168.3.0/28", "192.168.0.0/16", "fd00::/64", "192.168.2.8/29"]}`)).To(Equal([]string{
+			"exclude 192.168.3.0/28 is not within range 192.168.2.0/24 and has no effect",
+			"exclude 192.168.0.0/16 is not within range 192.168.2.0/24 and has no effect",
+			"exclude fd00::/64 is not within range 192.168.2.0/24 and has no effect",
+		}))
+	})
+
+	It("should warn if the excludes consume the entire range", func() {
+		Expect(whereaboutsRangeWarnings(`{"type": "whereabouts", "range": "192.168.2.0/24",
+			"exclude": ["192.168.2.128/25", "192.168.2.0/25"]}`)).To(Equal([]string{
+			"excludes 192.168.2.128/25,192.168.2.0/25 consume the entire range 192.168.2.0/24, no IPs can be allocated",
+		}))
+		// the network and broadcast addresses are not allocated
+		Expect(whereaboutsRangeWarnings(`{"type": "whereabouts", "range": "192.168.2.0/30",
+			"exclude": ["192.168.2.1/32", "192.168.2.2/32"]}`)).To(HaveLen(1))
+		Expect(whereaboutsRangeWarnings(`{"type": "whereabouts", "range": "192.168.2.0/24",
+			"range_start": "192.168.2.100", "range_end": "192.168.2.103",
+			"exclude": ["192.168.2.96/28"]}`)).To(HaveLen(1))
+		Expect(whereaboutsRangeWarnings(`{"type": "whereabouts", "range": "192.168.2.0/24",
+			"range_start": "192.168.2.100", "exclude": ["192.168.2.96/28"]}`)).To(BeEmpty())
+	})
+
+	It("should check every range of ipRanges", func() {
+		Expect(whereaboutsRangeWarnings(`{"type": "whereabouts", "ipRanges": [
+			{"range": "192.168.2.0/24", "exclude": ["192.168.2.0/28"]},
+			{"range": "fd00::/64", "exclude": ["fd01::/64"]}]}`)).To(Equal([]string{
+			"exclude fd01::/64 is not within range fd00::/64 and has no effect",
+		}))
+	})
+})
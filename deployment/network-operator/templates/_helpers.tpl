@@ -139,6 +139,20 @@ imagePullSecrets helpers
 {{- end }}
 {{- end }}
 
+{{- define "network-operator.docaTelemetry.imagePullSecrets" }}
+{{- if .Values.docaTelemetry.imagePullSecrets }}
+{{- range .Values.docaTelemetry.imagePullSecrets }}
+  - {{ . }}
+{{- end }}
+{{- else }}
+{{- if .Values.imagePullSecrets }}
+{{- range .Values.imagePullSecrets }}
+  - {{ . }}
+{{- end }}
+{{- end }}
+{{- end }}
+{{- end }}
+
 {{- define "network-operator.secondaryNetwork.cniPlugins.imagePullSecrets" }}
 {{- if .Values.secondaryNetwork.cniPlugins.imagePullSecrets }}
 {{- range .Values.secondaryNetwork.cniPlugins.imagePullSecrets }}
@@ -180,3 +194,18 @@ imagePullSecrets helpers
 {{- end }}
 {{- end }}
 {{- end }}
+
+{{/*
+Namespaces in which the operator manages objects: the release namespace and the namespaces of the components
+deployed in another namespace. The operator Role is created in each of them
+*/}}
+{{- define "network-operator.namespaces" -}}
+{{- $namespaces := list .Release.Namespace }}
+{{- if .Values.ofedDriver.namespace }}
+{{- $namespaces = append $namespaces .Values.ofedDriver.namespace }}
+{{- end }}
+{{- if .Values.docaTelemetry.namespace }}
+{{- $namespaces = append $namespaces .Values.docaTelemetry.namespace }}
+{{- end }}
+{{- $namespaces | uniq | join " " }}
+{{- end }}
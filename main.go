@@ -17,8 +17,14 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"crypto/x509"
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -28,15 +34,25 @@ import (
 	osconfigv1 "github.com/openshift/api/config/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	mellanoxcomv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	mellanoxcomv1beta1 "github.com/Mellanox/network-operator/api/v1beta1"
 	"github.com/Mellanox/network-operator/controllers"
+	"github.com/Mellanox/network-operator/pkg/config"
+	"github.com/Mellanox/network-operator/pkg/features"
+	"github.com/Mellanox/network-operator/pkg/inventory"
+	"github.com/Mellanox/network-operator/pkg/metrics"
+	"github.com/Mellanox/network-operator/pkg/nodelabeler"
+	"github.com/Mellanox/network-operator/pkg/readonly"
 	"github.com/Mellanox/network-operator/pkg/upgrade"
 	"github.com/Mellanox/network-operator/pkg/utils"
+	"github.com/Mellanox/network-operator/version"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -49,105 +65,373 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(mellanoxcomv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(mellanoxcomv1beta1.AddToScheme(scheme))
 	utilruntime.Must(netattdefv1.AddToScheme(scheme))
 	utilruntime.Must(osconfigv1.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
-func setupCRDControllers(mgr ctrl.Manager) error {
-	if err := (&controllers.NicClusterPolicyReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("NicClusterPolicy"),
-		Scheme: mgr.GetScheme(),
+// enabledControllers selects the CRDs reconciled by the operator, controllers which are disabled
+// don't watch their CRDs
+type enabledControllers struct {
+	// nicClusterPolicy enables the NicClusterPolicy controller together with its webhook, the upgrade controller
+	// and the ControllerRevisions cleanup of the OFED driver DaemonSets
+	nicClusterPolicy  bool
+	macvlanNetwork    bool
+	hostDeviceNetwork bool
+	ipoibNetwork      bool
+	networkDiagnostic bool
+}
+
+// addFlags adds --enable-<crd> flags of the controllers to the flag set, all controllers are enabled by default
+func (c *enabledControllers) addFlags(fs *flag.FlagSet) {
+	for kind, enabled := range map[string]*bool{
+		"NicClusterPolicy":  &c.nicClusterPolicy,
+		"MacvlanNetwork":    &c.macvlanNetwork,
+		"HostDeviceNetwork": &c.hostDeviceNetwork,
+		"IPoIBNetwork":      &c.ipoibNetwork,
+		"NetworkDiagnostic": &c.networkDiagnostic,
+	} {
+		fs.BoolVar(enabled, "enable-"+strings.ToLower(kind), true,
+			fmt.Sprintf("Run the %s controller, the operator doesn't watch %s CRs if disabled.", kind, kind))
+	}
+}
+
+func setupCRDControllers(mgr ctrl.Manager, k8sClient client.Client, rootCAs *x509.CertPool,
+	enabled *enabledControllers) error {
+	if !enabled.nicClusterPolicy {
+		setupLog.Info("controller is disabled", "controller", "NicClusterPolicy")
+	} else if err := (&controllers.NicClusterPolicyReconciler{
+		Client:    k8sClient,
+		Log:       ctrl.Log.WithName("controllers").WithName("NicClusterPolicy"),
+		Scheme:    mgr.GetScheme(),
+		RootCAs:   rootCAs,
+		PodReader: mgr.GetAPIReader(),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "NicClusterPolicy")
 		return err
 	}
-	if err := (&controllers.MacvlanNetworkReconciler{
-		Client: mgr.GetClient(),
+	if !enabled.macvlanNetwork {
+		setupLog.Info("controller is disabled", "controller", "MacvlanNetwork")
+	} else if err := (&controllers.MacvlanNetworkReconciler{
+		Client: k8sClient,
 		Log:    ctrl.Log.WithName("controllers").WithName("MacvlanNetwork"),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "MacvlanNetwork")
 		return err
 	}
-	if err := (&controllers.HostDeviceNetworkReconciler{
-		Client: mgr.GetClient(),
+	if !enabled.hostDeviceNetwork {
+		setupLog.Info("controller is disabled", "controller", "HostDeviceNetwork")
+	} else if err := (&controllers.HostDeviceNetworkReconciler{
+		Client: k8sClient,
 		Log:    ctrl.Log.WithName("controllers").WithName("HostDeviceNetwork"),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "HostDeviceNetwork")
 		return err
 	}
-	if err := (&controllers.IPoIBNetworkReconciler{
-		Client: mgr.GetClient(),
+	if !enabled.ipoibNetwork {
+		setupLog.Info("controller is disabled", "controller", "IPoIBNetwork")
+	} else if err := (&controllers.IPoIBNetworkReconciler{
+		Client: k8sClient,
 		Log:    ctrl.Log.WithName("controllers").WithName("IPoIBNetwork"),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "IPoIBNetwork")
 		return err
 	}
+	// network pods are counted for MacvlanNetworks and HostDeviceNetworks
+	interval := config.FromEnv().Controller.NetworkPodsMetricsIntervalSeconds
+	if interval > 0 && (enabled.macvlanNetwork || enabled.hostDeviceNetwork) {
+		if err := mgr.Add(&controllers.NetworkPodsCollector{
+			Client:    k8sClient,
+			PodReader: mgr.GetAPIReader(),
+			Log:       ctrl.Log.WithName("controllers").WithName("NetworkPodsCollector"),
+			Interval:  time.Duration(interval) * time.Second,
+		}); err != nil {
+			setupLog.Error(err, "unable to add network pods metrics collector")
+			return err
+		}
+	}
+	interval = config.FromEnv().Controller.ControllerRevisionsGCIntervalSeconds
+	if interval > 0 && enabled.nicClusterPolicy {
+		// the OFED driver may be deployed in another namespace than the operator
+		if err := mgr.Add(&controllers.ControllerRevisionsCollector{
+			Client:   k8sClient,
+			Log:      ctrl.Log.WithName("controllers").WithName("ControllerRevisionsCollector"),
+			Interval: time.Duration(interval) * time.Second,
+		}); err != nil {
+			setupLog.Error(err, "unable to add ControllerRevisions collector")
+			return err
+		}
+	}
+	if !enabled.networkDiagnostic {
+		setupLog.Info("controller is disabled", "controller", "NetworkDiagnostic")
+	} else if err := (&controllers.NetworkDiagnosticReconciler{
+		Client: k8sClient,
+		Log:    ctrl.Log.WithName("controllers").WithName("NetworkDiagnostic"),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "NetworkDiagnostic")
+		return err
+	}
+	return nil
+}
+
+// createK8sInterface creates the k8s interface, retrying with backoff while the API server is not reachable
+func createK8sInterface() (kubernetes.Interface, error) {
+	controllerConfig := config.FromEnv().Controller
+	return utils.CreateK8sInterfaceWithRetry(setupLog, controllerConfig.K8sInterfaceRetries,
+		time.Duration(controllerConfig.K8sInterfaceRetryBackoffSeconds)*time.Second,
+		time.Duration(controllerConfig.K8sInterfaceTimeoutSeconds)*time.Second)
+}
+
+func setupUpgradeController(mgr ctrl.Manager, rootCAs *x509.CertPool) error {
+	upgradeLogger := ctrl.Log.WithName("controllers").WithName("Upgrade")
+	k8sInterface, err := createK8sInterface()
+	if err != nil {
+		setupLog.Error(err, "unable to create k8s interface", "controller", "Upgrade")
+		return err
+	}
+	eventSink := upgrade.NewHTTPEventSink(upgradeLogger.WithName("eventSink"), rootCAs)
+	if err := mgr.Add(eventSink); err != nil {
+		setupLog.Error(err, "unable to add upgrade event sink", "controller", "Upgrade")
+		return err
+	}
+	nodeUpgradeStateProvider := &upgrade.NodeUpgradeStateProviderImpl{
+		K8sClient: mgr.GetClient(),
+		Log:       upgradeLogger.WithName("nodeUpgradeStateProvider"),
+		EventSink: eventSink,
+	}
+	drainManager := upgrade.NewDrainManager(
+		k8sInterface, nodeUpgradeStateProvider, upgradeLogger.WithName("drainManager"))
+	drainManager.EventRecorder = mgr.GetEventRecorderFor("network-operator-upgrade")
+	uncordonManager := upgrade.NewUncordonManager(k8sInterface, upgradeLogger.WithName("uncordonManager"))
+	podDeleteManager := upgrade.NewPodDeleteManager(mgr.GetClient(), upgradeLogger.WithName("podDeleteManager"))
+	clusterUpdateStateManager := upgrade.NewClusterUpdateStateManager(
+		drainManager, podDeleteManager, uncordonManager, nodeUpgradeStateProvider,
+		upgradeLogger.WithName("clusterUpgradeManager"), mgr.GetClient(), k8sInterface)
+	clusterUpdateStateManager.DriverValidator = upgrade.NewDriverValidator(
+		k8sInterface, mgr.GetConfig(), upgradeLogger.WithName("driverValidator"))
+	if err := (&controllers.UpgradeReconciler{
+		Client:                   mgr.GetClient(),
+		Log:                      upgradeLogger,
+		Scheme:                   mgr.GetScheme(),
+		StateManager:             clusterUpdateStateManager,
+		NodeUpgradeStateProvider: nodeUpgradeStateProvider,
+		EventSink:                eventSink,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Upgrade")
+		return err
+	}
 	return nil
 }
 
+// runNicLabeler runs the NIC labeler node agent instead of the operator until the process is terminated
+func runNicLabeler(interval time.Duration) {
+	nodeName := os.Getenv("NODE_NAME")
+	if nodeName == "" {
+		setupLog.Error(fmt.Errorf("NODE_NAME environment variable is not set"), "unable to start NIC labeler")
+		os.Exit(1)
+	}
+	k8sInterface, err := createK8sInterface()
+	if err != nil {
+		setupLog.Error(err, "unable to create k8s interface", "agent", "NicLabeler")
+		os.Exit(1)
+	}
+	labeler := &nodelabeler.Labeler{
+		K8sInterface:  k8sInterface,
+		Log:           ctrl.Log.WithName("nicLabeler"),
+		NodeName:      nodeName,
+		SysfsRoot:     "/sys",
+		OSReleasePath: "/host/etc/os-release",
+	}
+	setupLog.Info("starting NIC labeler", "node", nodeName, "interval", interval)
+	labeler.Run(ctrl.SetupSignalHandler(), interval)
+}
+
+// dumpInventory writes the snapshot of the objects managed by the operator to stdout and exits
+func dumpInventory(format string) {
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create client")
+		os.Exit(1)
+	}
+	if err := inventory.Write(context.Background(), c, os.Stdout, format); err != nil {
+		setupLog.Error(err, "unable to dump inventory")
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
 func main() {
 	var metricsAddr string
+	var metricsSecure bool
+	var metricsCertFile string
+	var metricsKeyFile string
+	var caBundleFile string
 	var enableLeaderElection bool
 	var probeAddr string
+	var readOnly bool
+	var describeStates bool
+	var describeStatesFormat string
+	var nicLabeler bool
+	var nicLabelerInterval time.Duration
+	var inventoryDump bool
+	var inventoryFormat string
+	var enabled enabledControllers
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
+	flag.BoolVar(&metricsSecure, "metrics-secure", false,
+		"Serve the metrics over HTTPS instead of plaintext HTTP. A self-signed certificate is used "+
+			"if --metrics-cert-file and --metrics-key-file are not set.")
+	flag.StringVar(&metricsCertFile, "metrics-cert-file", "",
+		"Path to the TLS certificate of the metrics endpoint, the file is reloaded when it changes.")
+	flag.StringVar(&metricsKeyFile, "metrics-key-file", "",
+		"Path to the TLS key of the metrics endpoint, the file is reloaded when it changes.")
+	flag.StringVar(&caBundleFile, "ca-bundle-file", "",
+		"Path to a PEM encoded CA bundle trusted in addition to the system CAs for the HTTPS requests of the "+
+			"operator, e.g. precompiled package lookups and upgrade events sent to an internal endpoint.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&readOnly, "read-only", false,
+		"Run the operator in report-only mode. The operator never mutates the cluster, "+
+			"differences from the desired state are logged and reported with network_operator_drift metric.")
+	flag.BoolVar(&describeStates, "describe-states", false,
+		"Print the node upgrade states and transitions implemented by the upgrade controller and exit.")
+	flag.StringVar(&describeStatesFormat, "describe-states-format", upgrade.DescribeFormatText,
+		"Format of the --describe-states output, \"text\" or \"dot\" (Graphviz).")
+	flag.BoolVar(&nicLabeler, "nic-labeler", false,
+		"Run as NIC labeler node agent which sets Mellanox NIC and OS labels of the node specified by "+
+			"NODE_NAME environment variable, to be used in clusters without Node Feature Discovery.")
+	flag.DurationVar(&nicLabelerInterval, "nic-labeler-interval", time.Minute,
+		"Interval between node label updates of the NIC labeler.")
+	flag.BoolVar(&inventoryDump, "dump-inventory", false,
+		"Print a snapshot of the DaemonSets, Deployments, ConfigMaps, Services and NetworkAttachmentDefinitions "+
+			"managed by the operator instance as a single List document and exit.")
+	flag.StringVar(&inventoryFormat, "dump-inventory-format", inventory.FormatYAML,
+		"Format of the --dump-inventory output, \"yaml\" or \"json\".")
+	stateConfig := &config.FromEnv().State
+	flag.StringVar(&stateConfig.CniBinDir, "cni-bin-dir", stateConfig.CniBinDir,
+		"Host directory of the CNI plugin binaries, overrides CNI_BIN_DIR environment variable.")
+	flag.StringVar(&stateConfig.CniConfDir, "cni-conf-dir", stateConfig.CniConfDir,
+		"Host directory of the CNI network configuration files, overrides CNI_CONF_DIR environment variable.")
+	enabled.addFlags(flag.CommandLine)
+	features.AddFlag(flag.CommandLine)
 	opts := zap.Options{
 		Development: true,
 	}
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
+	if describeStates {
+		if err := upgrade.DescribeStates(os.Stdout, describeStatesFormat); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if nicLabeler {
+		runNicLabeler(nicLabelerInterval)
+		return
+	}
+
+	if inventoryDump {
+		dumpInventory(inventoryFormat)
+	}
+
+	for name, dir := range map[string]string{"--cni-bin-dir": stateConfig.CniBinDir,
+		"--cni-conf-dir": stateConfig.CniConfDir} {
+		if err := config.ValidateHostDir(dir); err != nil {
+			setupLog.Error(err, "invalid CNI directory", "flag", name)
+			os.Exit(1)
+		}
+	}
+
+	if (metricsCertFile == "") != (metricsKeyFile == "") {
+		setupLog.Error(fmt.Errorf("--metrics-cert-file and --metrics-key-file must be set together"),
+			"invalid metrics configuration")
+		os.Exit(1)
+	}
+	metricsSecure = metricsSecure || metricsCertFile != ""
+	var rootCAs *x509.CertPool
+	if caBundleFile != "" {
+		var err error
+		if rootCAs, err = utils.LoadCABundle(caBundleFile); err != nil {
+			setupLog.Error(err, "invalid CA bundle", "flag", "--ca-bundle-file")
+			os.Exit(1)
+		}
+	}
+	managerMetricsAddr := metricsAddr
+	if metricsSecure {
+		// metrics are served by the secure metrics server instead of the manager
+		managerMetricsAddr = "0"
+	}
+
+	leaderElectionID := "12620820.mellanox.com"
+	if readOnly {
+		// read-only instance runs alongside the regular one and should not compete for leadership with it
+		leaderElectionID = "read-only." + leaderElectionID
+	}
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
-		MetricsBindAddress:     metricsAddr,
+		MetricsBindAddress:     managerMetricsAddr,
 		Port:                   9443,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "12620820.mellanox.com",
+		LeaderElectionID:       leaderElectionID,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	err = setupCRDControllers(mgr)
-	if err != nil {
+	if metricsSecure {
+		if err := mgr.Add(&metrics.SecureServer{
+			BindAddress:   metricsAddr,
+			CertFile:      metricsCertFile,
+			KeyFile:       metricsKeyFile,
+			Log:           ctrl.Log.WithName("metrics"),
+			ExtraHandlers: map[string]http.Handler{version.Path: version.Handler()},
+		}); err != nil {
+			setupLog.Error(err, "unable to add secure metrics server")
+			os.Exit(1)
+		}
+	} else if err := mgr.AddMetricsExtraHandler(version.Path, version.Handler()); err != nil {
+		setupLog.Error(err, "unable to add version endpoint")
 		os.Exit(1)
 	}
 
-	upgradeLogger := ctrl.Log.WithName("controllers").WithName("Upgrade")
-	k8sInterface, err := utils.CreateK8sInterface()
+	k8sClient := mgr.GetClient()
+	if readOnly {
+		setupLog.Info("running in read-only mode, changes to the cluster are reported only")
+		k8sClient = readonly.NewClient(k8sClient, ctrl.Log.WithName("readOnlyClient"))
+	}
+
+	err = setupCRDControllers(mgr, k8sClient, rootCAs, &enabled)
 	if err != nil {
-		setupLog.Error(err, "unable to create k8s interface", "controller", "Upgrade")
 		os.Exit(1)
 	}
-	nodeUpgradeStateProvider := upgrade.NewNodeUpgradeStateProvider(
-		mgr.GetClient(), upgradeLogger.WithName("nodeUpgradeStateProvider"))
-	drainManager := upgrade.NewDrainManager(
-		k8sInterface, nodeUpgradeStateProvider, upgradeLogger.WithName("drainManager"))
-	uncordonManager := upgrade.NewUncordonManager(k8sInterface, upgradeLogger.WithName("uncordonManager"))
-	podDeleteManager := upgrade.NewPodDeleteManager(mgr.GetClient(), upgradeLogger.WithName("podDeleteManager"))
-	clusterUpdateStateManager := upgrade.NewClusterUpdateStateManager(
-		drainManager, podDeleteManager, uncordonManager, nodeUpgradeStateProvider,
-		upgradeLogger.WithName("clusterUpgradeManager"), mgr.GetClient(), k8sInterface)
-	if err = (&controllers.UpgradeReconciler{
-		Client:                   mgr.GetClient(),
-		Log:                      upgradeLogger,
-		Scheme:                   mgr.GetScheme(),
-		StateManager:             clusterUpdateStateManager,
-		NodeUpgradeStateProvider: nodeUpgradeStateProvider,
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "Upgrade")
+
+	if config.FromEnv().Controller.EnableWebhooks && enabled.nicClusterPolicy {
+		if err = (&mellanoxcomv1alpha1.NicClusterPolicy{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "NicClusterPolicy")
+			os.Exit(1)
+		}
+	}
+
+	if readOnly {
+		// upgrade flow drains and restarts nodes, it has nothing to report
+		setupLog.Info("upgrade controller is disabled in read-only mode")
+	} else if !enabled.nicClusterPolicy {
+		// upgrade flow follows the upgrade policy of the NicClusterPolicy
+		setupLog.Info("controller is disabled", "controller", "Upgrade")
+	} else if err = setupUpgradeController(mgr, rootCAs); err != nil {
 		os.Exit(1)
 	}
 	// +kubebuilder:scaffold:builder
@@ -161,7 +445,8 @@ func main() {
 		os.Exit(1)
 	}
 
-	setupLog.Info("starting manager")
+	info := version.Get()
+	setupLog.Info("starting manager", "version", info.Version, "commit", info.Commit, "date", info.Date)
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
@@ -17,6 +17,8 @@ limitations under the License.
 package config
 
 import (
+	"fmt"
+	"path/filepath"
 	"sync"
 
 	"github.com/caarlos0/env/v6"
@@ -35,6 +37,26 @@ type OperatorConfig struct {
 type StateConfig struct {
 	NetworkOperatorResourceNamespace string `env:"POD_NAMESPACE" envDefault:"nvidia-network-operator"`
 	ManifestBaseDir                  string `env:"STATE_MANIFEST_BASE_DIR" envDefault:"./manifests"`
+	// Comma separated list of label and annotation keys of network CRs which are propagated to
+	// the generated NetworkAttachmentDefinition, an entry ending with "*" matches keys by prefix
+	NetworkMetadataAllowlist []string `env:"NETWORK_METADATA_ALLOWLIST" envSeparator:","`
+	// Value of the app.kubernetes.io/instance label set on all objects created by the operator,
+	// allows to tell apart objects of several operator installations
+	InstanceLabelValue string `env:"INSTANCE_LABEL_VALUE" envDefault:"nvidia-network-operator"`
+	// Comma separated list of key=value annotations set on all objects created by the operator,
+	// e.g. to make GitOps tools ignore the generated objects
+	GeneratedObjectAnnotations []string `env:"GENERATED_OBJECT_ANNOTATIONS" envSeparator:","`
+	// Host directory of the CNI plugin binaries, e.g. /var/lib/cni/bin on OpenShift
+	CniBinDir string `env:"CNI_BIN_DIR" envDefault:"/opt/cni/bin"`
+	// Host directory of the CNI network configuration files, e.g. /etc/kubernetes/cni/net.d on OpenShift
+	CniConfDir string `env:"CNI_CONF_DIR" envDefault:"/etc/cni/net.d"`
+	// Maximum number of independent states of a CR synced in parallel, e.g. the CNI plugins and the OFED driver,
+	// states which depend on other states are still synced after them
+	SyncConcurrency int `env:"STATE_SYNC_CONCURRENCY" envDefault:"1"`
+	// Label of the nodes excluded from the management of the operator when set to "true", e.g. during unrelated
+	// maintenance of the node. The exclusion is disabled if not set, as it changes the pod template of the driver
+	// and device plugin DaemonSets
+	ExcludedNodeLabel string `env:"EXCLUDED_NODE_LABEL"`
 }
 
 // Controller related configurations
@@ -42,6 +64,29 @@ type ControllerConfig struct {
 	//nolint:stylecheck
 	// Request requeue time(seconds) in case the system still needs to be reconciled
 	RequeueTimeSeconds uint `env:"CONTROLLER_REQUEST_REQUEUE_SECONDS" envDefault:"5"`
+	// Request requeue time(seconds) for networks waiting for the device plugin to advertise the resource
+	ResourceRequeueTimeSeconds uint `env:"CONTROLLER_RESOURCE_REQUEUE_SECONDS" envDefault:"30"`
+	// Interval(seconds) of counting pods attached to the network CRs for metrics, 0 disables the metrics
+	NetworkPodsMetricsIntervalSeconds uint `env:"NETWORK_PODS_METRICS_INTERVAL_SECONDS" envDefault:"60"`
+	// Max time(seconds) the NicClusterPolicy teardown waits for the pods which use the device plugin resources,
+	// 0 waits without limit
+	TeardownTimeoutSeconds uint `env:"CONTROLLER_TEARDOWN_TIMEOUT_SECONDS" envDefault:"3600"`
+	// Interval(seconds) of deleting old ControllerRevisions of the OFED driver DaemonSets, 0 disables the deletion
+	ControllerRevisionsGCIntervalSeconds uint `env:"CONTROLLER_REVISIONS_GC_INTERVAL_SECONDS" envDefault:"3600"`
+	// Number of retries to create the k8s interface at startup if the API server is not reachable
+	K8sInterfaceRetries int `env:"K8S_INTERFACE_RETRIES" envDefault:"5"`
+	// Initial time(seconds) between the retries to create the k8s interface, doubled after each retry
+	K8sInterfaceRetryBackoffSeconds uint `env:"K8S_INTERFACE_RETRY_BACKOFF_SECONDS" envDefault:"2"`
+	// Timeout(seconds) of the API server reachability check of each attempt to create the k8s interface
+	K8sInterfaceTimeoutSeconds uint `env:"K8S_INTERFACE_TIMEOUT_SECONDS" envDefault:"10"`
+	// Enable webhooks, e.g. CRD conversion webhook. Requires webhook server certificates to be provisioned
+	EnableWebhooks bool `env:"ENABLE_WEBHOOKS" envDefault:"false"`
+	// Create the missing network namespace of IPoIBNetworks instead of reporting it in the CR status
+	CreateNetworkNamespaces bool `env:"CREATE_NETWORK_NAMESPACES" envDefault:"false"`
+	// Namespace of the nv-ipam IPPools referenced by the networks, the operator resource namespace if not set
+	NvIpamPoolsNamespace string `env:"NV_IPAM_POOLS_NAMESPACE"`
+	// Interval(seconds) of checking the nv-ipam IPPools referenced by ready networks
+	NvIpamPoolsCheckIntervalSeconds uint `env:"NV_IPAM_POOLS_CHECK_INTERVAL_SECONDS" envDefault:"60"`
 }
 
 func FromEnv() *OperatorConfig {
@@ -51,3 +96,18 @@ func FromEnv() *OperatorConfig {
 	})
 	return operatorConfig
 }
+
+// ValidateHostDir returns an error if the path can't be used as a host directory mounted into the pods
+// of the deployed components, the path must be absolute and clean and must not be the root directory
+func ValidateHostDir(path string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("host directory %q is not an absolute path", path)
+	}
+	if filepath.Clean(path) != path {
+		return fmt.Errorf("host directory %q is not a clean path, expected %q", path, filepath.Clean(path))
+	}
+	if path == "/" {
+		return fmt.Errorf("host directory can't be the root directory")
+	}
+	return nil
+}
@@ -30,4 +30,88 @@ const (
 
 const (
 	NicClusterPolicyResourceName = "nic-cluster-policy"
+	// NicClusterPolicyFinalizer blocks NicClusterPolicy removal until workloads which use
+	// device plugin resources are gone, so OFED driver is not removed under them
+	NicClusterPolicyFinalizer = "mellanox.com/nic-cluster-policy-teardown"
+	// NetworkFinalizer blocks the removal of MacvlanNetwork, HostDeviceNetwork and IPoIBNetwork until
+	// the NetworkAttachmentDefinitions generated for the network in all namespaces are deleted
+	NetworkFinalizer = "mellanox.com/network-teardown"
+	// RestartDriverAnnotation requests a rollout of the OFED driver DaemonSet when set to "true" on the
+	// NicClusterPolicy. The operator replaces the request with its acceptance time (RFC3339) and sets this time
+	// on the pod template of the DaemonSet
+	RestartDriverAnnotation = "nvidia.com/restart-driver"
+	// OfedDriverEnvAnnotation overrides environment variables of the OFED driver container on the annotated node
+	// if NodeEnvOverrides is enabled in the OFED driver spec, the value is a JSON object of names and values
+	OfedDriverEnvAnnotation = "network.nvidia.com/ofed-driver-env"
+	// OfedNodeEnvConfigMapName is the ConfigMap with the OFED driver environment overrides keyed by node name
+	OfedNodeEnvConfigMapName = "ofed-node-env"
+	// FirmwareStatusNodeLabel is published by Node Feature Discovery from the local feature file written by the
+	// firmware init container of the OFED driver pod, the value is one of the FirmwareStatus values
+	FirmwareStatusNodeLabel = "feature.node.kubernetes.io/mellanox-firmware-status"
+	// OfedVersionNodeLabel is set on the nodes to the OFED driver version loaded by the Ready driver pod of the node
+	OfedVersionNodeLabel = "nvidia.com/ofed.version"
+	// OfedBuildSlotAnnotation is set on the OFED driver pod when the operator grants it a driver build slot, the value
+	// is the grant time (RFC3339), or OfedBuildSlotReclaimed once the slot of a failing pod is reclaimed.
+	// The build gate init container of the pod waits for it, the annotation is removed once the pod is Ready
+	OfedBuildSlotAnnotation = "nvidia.com/ofed-driver-build-slot"
+	// OfedBuildSlotReclaimed is the value of OfedBuildSlotAnnotation of a driver pod which failed to build the driver,
+	// the pod doesn't hold a build slot and doesn't wait for one
+	OfedBuildSlotReclaimed = "reclaimed"
+	// OfedBuildGateContainerName is the init container of the OFED driver pod which waits for OfedBuildSlotAnnotation
+	OfedBuildGateContainerName = "mofed-build-gate"
+	// PauseReconcileUntilAnnotation pauses the reconcile of the NicClusterPolicy until the RFC3339 time of the value,
+	// e.g. during a change freeze. The reconcile resumes automatically at this time
+	PauseReconcileUntilAnnotation = "nvidia.com/pause-reconcile-until"
+	// ResourceDriftRestartsAnnotationPrefix followed by the device plugin DaemonSet name is set on the node to the number
+	// of device plugin pod restarts since the node advertises zero resources of the device plugin
+	ResourceDriftRestartsAnnotationPrefix = "nvidia.com/resource-drift-restarts-"
+	// FirmwareStatusUpdated means the firmware was flashed to the NICs of the node
+	FirmwareStatusUpdated = "updated"
+	// FirmwareStatusCurrent means the NICs of the node already run the provided firmware
+	FirmwareStatusCurrent = "current"
+	// FirmwareStatusOutdated means some NICs of the node don't run the provided firmware and update is disabled
+	FirmwareStatusOutdated = "outdated"
+	// FirmwareStatusFailed means the provided firmware couldn't be flashed to some NICs of the node
+	FirmwareStatusFailed = "failed"
+)
+
+const (
+	// PrecompiledPackageMissingCondition is set on the NicClusterPolicy when no precompiled OFED driver package
+	// matches the kernel of some nodes
+	PrecompiledPackageMissingCondition = "PrecompiledPackageMissing"
+	// DriverVersionBelowMinimumCondition is set on the NicClusterPolicy when OFED driver pods on some nodes
+	// or the configured OFED driver version are older than the minimum driver version
+	DriverVersionBelowMinimumCondition = "DriverVersionBelowMinimum"
+	// DriverRestartRejectedCondition is set on the NicClusterPolicy while the driver restart requested with
+	// RestartDriverAnnotation can't be accepted, e.g. because the automatic upgrade of the OFED driver is disabled
+	DriverRestartRejectedCondition = "DriverRestartRejected"
+	// DriverReadyNodeCondition is set on the nodes with Mellanox NICs if enabled in the OFED driver spec,
+	// it is True while the OFED driver pod on the node is Ready
+	DriverReadyNodeCondition = "nvidia.com/driver-ready"
+	// FirmwareReadyNodeCondition is set on the nodes with Mellanox NICs if firmware is set in the OFED driver spec,
+	// it reflects the firmware status reported by the firmware init container of the OFED driver pod of the node
+	FirmwareReadyNodeCondition = "nvidia.com/firmware-ready"
+	// NetworkNamespaceMissingCondition is set on the network CR when the namespace of its
+	// NetworkAttachmentDefinition doesn't exist
+	NetworkNamespaceMissingCondition = "NetworkNamespaceMissing"
+	// IPPoolReadyCondition is set on the MacvlanNetwork using nv-ipam, it is False while an IPPool of the network
+	// doesn't exist or has no IP block for some nodes with Mellanox NICs
+	IPPoolReadyCondition = "IPPoolReady"
+	// PermissionDeniedCondition is set in the status of a custom resource if its sync fails because the RBAC
+	// of the operator doesn't allow an operation, the message names the missing permissions
+	PermissionDeniedCondition = "PermissionDenied"
+	// SpecInvalidCondition is set on the NicClusterPolicy when its spec is invalid, e.g. an enabled component without
+	// an image, the components are not synced until the spec is fixed
+	SpecInvalidCondition = "SpecInvalid"
+	// ReconcilePausedCondition is set on the NicClusterPolicy while its reconcile is paused with
+	// PauseReconcileUntilAnnotation, the message shows the scheduled resume time
+	ReconcilePausedCondition = "ReconcilePaused"
+)
+
+const (
+	// ManagedByLabel and InstanceLabel are set on all objects created by the operator,
+	// the value of InstanceLabel is configurable to tell apart objects of several operator installations
+	ManagedByLabel      = "app.kubernetes.io/managed-by"
+	ManagedByLabelValue = "network-operator"
+	InstanceLabel       = "app.kubernetes.io/instance"
 )
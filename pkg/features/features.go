@@ -0,0 +1,81 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package features contains the feature gates of the operator, experimental behaviors are shipped disabled
+// and are enabled per deployment with the --feature-gates flag
+package features
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"k8s.io/component-base/featuregate"
+)
+
+const (
+	// ServerSideApply makes the operator apply the objects of the deployed components with server-side apply
+	// instead of replacing them with an update, so that fields set by other controllers are preserved
+	ServerSideApply featuregate.Feature = "ServerSideApply"
+)
+
+// defaultFeatureGates contains all known feature gates of the operator with their defaults
+var defaultFeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{
+	ServerSideApply: {Default: false, PreRelease: featuregate.Alpha},
+}
+
+// Gate is the feature gate set of the operator, it is set by --feature-gates flag
+var Gate = NewFeatureGate()
+
+// NewFeatureGate returns a new feature gate set with all known feature gates of the operator
+func NewFeatureGate() featuregate.MutableFeatureGate {
+	gate := featuregate.NewFeatureGate()
+	if err := gate.Add(defaultFeatureGates); err != nil {
+		// known feature gates are static, the error means they are not defined properly
+		panic(err)
+	}
+	return gate
+}
+
+// Enabled returns true if the feature gate is enabled in the feature gate set of the operator
+func Enabled(feature featuregate.Feature) bool {
+	return Gate.Enabled(feature)
+}
+
+// AddFlag adds --feature-gates flag which sets the feature gates of Gate to the flag set
+func AddFlag(fs *flag.FlagSet) {
+	fs.Var(&gateFlag{gate: Gate}, "feature-gates",
+		"A set of key=value pairs that enable or disable experimental features. Options are:\n"+
+			strings.Join(Gate.KnownFeatures(), "\n"))
+}
+
+// gateFlag implements flag.Value for a feature gate set
+type gateFlag struct {
+	gate featuregate.MutableFeatureGate
+}
+
+// Set sets the feature gates from a comma separated list of key=value pairs
+func (f *gateFlag) Set(value string) error {
+	return f.gate.Set(value)
+}
+
+// String returns the feature gates which are set, the flag package calls it on zero value to print the default
+func (f *gateFlag) String() string {
+	if f.gate == nil {
+		return ""
+	}
+	return fmt.Sprint(f.gate)
+}
@@ -0,0 +1,51 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package features_test
+
+import (
+	"flag"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/Mellanox/network-operator/pkg/features"
+)
+
+var _ = Describe("Feature gates tests", func() {
+	It("Should disable experimental features by default", func() {
+		gate := features.NewFeatureGate()
+		Expect(gate.Enabled(features.ServerSideApply)).To(BeFalse())
+	})
+	It("Should enable features from the flag value", func() {
+		gate := features.NewFeatureGate()
+		Expect(gate.Set("ServerSideApply=true")).To(Succeed())
+		Expect(gate.Enabled(features.ServerSideApply)).To(BeTrue())
+	})
+	It("Should reject unknown features and invalid values", func() {
+		Expect(features.NewFeatureGate().Set("Unknown=true")).NotTo(Succeed())
+		Expect(features.NewFeatureGate().Set("ServerSideApply=yes")).NotTo(Succeed())
+	})
+	It("Should set the feature gates of the operator from the command line flag", func() {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		features.AddFlag(fs)
+		Expect(fs.Parse([]string{"--feature-gates=ServerSideApply=true"})).To(Succeed())
+		Expect(features.Enabled(features.ServerSideApply)).To(BeTrue())
+		Expect(fs.Lookup("feature-gates").Value.String()).To(Equal("ServerSideApply=true"))
+		Expect(fs.Parse([]string{"--feature-gates=ServerSideApply=false"})).To(Succeed())
+		Expect(features.Enabled(features.ServerSideApply)).To(BeFalse())
+	})
+})
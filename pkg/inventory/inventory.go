@@ -0,0 +1,107 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package inventory exports a point-in-time snapshot of the objects managed by the operator,
+// e.g. to archive the footprint of the operator or to diff it between clusters or over time
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/Mellanox/network-operator/pkg/state"
+)
+
+const (
+	// FormatYAML writes the snapshot as a YAML document
+	FormatYAML = "yaml"
+	// FormatJSON writes the snapshot as a JSON document
+	FormatJSON = "json"
+)
+
+// Kinds are the kinds of the objects included in the snapshot
+var Kinds = []schema.GroupVersionKind{
+	{Group: "apps", Version: "v1", Kind: "DaemonSet"},
+	{Group: "apps", Version: "v1", Kind: "Deployment"},
+	{Group: "", Version: "v1", Kind: "ConfigMap"},
+	{Group: "", Version: "v1", Kind: "Service"},
+	{Group: "k8s.cni.cncf.io", Version: "v1", Kind: "NetworkAttachmentDefinition"},
+}
+
+// Snapshot returns a v1 List of all objects of Kinds which carry the managed labels of the operator instance,
+// see state.ManagedLabels. The items are sorted by kind, namespace and name, and their managedFields are dropped,
+// so that snapshots can be diffed
+func Snapshot(ctx context.Context, c client.Reader) (*unstructured.UnstructuredList, error) {
+	snapshot := &unstructured.UnstructuredList{}
+	snapshot.SetAPIVersion("v1")
+	snapshot.SetKind("List")
+	for _, gvk := range Kinds {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := c.List(ctx, list, client.MatchingLabels(state.ManagedLabels())); err != nil {
+			return nil, fmt.Errorf("failed to list %s objects: %v", gvk.Kind, err)
+		}
+		for i := range list.Items {
+			item := list.Items[i]
+			item.SetGroupVersionKind(gvk)
+			item.SetManagedFields(nil)
+			snapshot.Items = append(snapshot.Items, item)
+		}
+	}
+	sort.SliceStable(snapshot.Items, func(i, j int) bool {
+		a, b := &snapshot.Items[i], &snapshot.Items[j]
+		if a.GetKind() != b.GetKind() {
+			return a.GetKind() < b.GetKind()
+		}
+		if a.GetNamespace() != b.GetNamespace() {
+			return a.GetNamespace() < b.GetNamespace()
+		}
+		return a.GetName() < b.GetName()
+	})
+	return snapshot, nil
+}
+
+// Write writes the snapshot of the managed objects in the given format, FormatYAML or FormatJSON
+func Write(ctx context.Context, c client.Reader, w io.Writer, format string) error {
+	if format != FormatYAML && format != FormatJSON {
+		return fmt.Errorf("unsupported inventory format %q, expected %q or %q", format, FormatYAML, FormatJSON)
+	}
+	snapshot, err := Snapshot(ctx, c)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(snapshot.UnstructuredContent(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize inventory: %v", err)
+	}
+	if format == FormatYAML {
+		if data, err = yaml.JSONToYAML(data); err != nil {
+			return fmt.Errorf("failed to serialize inventory: %v", err)
+		}
+	} else {
+		data = append(data, '\n')
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,89 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	netattdefv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+
+	"github.com/Mellanox/network-operator/pkg/state"
+)
+
+var _ = Describe("Inventory", func() {
+	var c client.Client
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(netattdefv1.AddToScheme(scheme)).To(Succeed())
+		managed := func(name, namespace string) metav1.ObjectMeta {
+			return metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: state.ManagedLabels(),
+				ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "network-operator"}}}
+		}
+		c = fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			&appsv1.DaemonSet{ObjectMeta: managed("mofed-ubuntu20.04-ds", "nvidia-network-operator")},
+			&corev1.ConfigMap{ObjectMeta: managed("rdma-devices", "nvidia-network-operator")},
+			&corev1.ConfigMap{ObjectMeta: managed("ofed-config", "a-namespace")},
+			&netattdefv1.NetworkAttachmentDefinition{ObjectMeta: managed("macvlan", "default")},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "not-managed", Namespace: "default"}},
+		).Build()
+	})
+
+	It("should list the managed objects sorted by kind, namespace and name", func() {
+		snapshot, err := Snapshot(context.TODO(), c)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapshot.GetKind()).To(Equal("List"))
+		var names []string
+		for _, item := range snapshot.Items {
+			Expect(item.GetManagedFields()).To(BeEmpty())
+			names = append(names, item.GetKind()+"/"+item.GetNamespace()+"/"+item.GetName())
+		}
+		Expect(names).To(Equal([]string{
+			"ConfigMap/a-namespace/ofed-config",
+			"ConfigMap/nvidia-network-operator/rdma-devices",
+			"DaemonSet/nvidia-network-operator/mofed-ubuntu20.04-ds",
+			"NetworkAttachmentDefinition/default/macvlan",
+		}))
+	})
+
+	It("should write the snapshot as YAML or JSON", func() {
+		var yamlOut, jsonOut bytes.Buffer
+		Expect(Write(context.TODO(), c, &yamlOut, FormatYAML)).To(Succeed())
+		Expect(Write(context.TODO(), c, &jsonOut, FormatJSON)).To(Succeed())
+
+		fromYAML, fromJSON := map[string]interface{}{}, map[string]interface{}{}
+		Expect(yaml.Unmarshal(yamlOut.Bytes(), &fromYAML)).To(Succeed())
+		Expect(json.Unmarshal(jsonOut.Bytes(), &fromJSON)).To(Succeed())
+		Expect(fromYAML).To(Equal(fromJSON))
+		Expect(fromJSON["items"]).To(HaveLen(4))
+
+		Expect(Write(context.TODO(), c, &jsonOut, "xml")).NotTo(Succeed())
+	})
+})
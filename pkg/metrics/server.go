@@ -0,0 +1,199 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics serves the controller metrics over HTTPS, it replaces the plaintext metrics endpoint
+// of the controller manager when the metrics have to be TLS-secured
+package metrics
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/Mellanox/network-operator/pkg/consts"
+)
+
+const (
+	// Path of the metrics endpoint, same as the one of the controller manager
+	Path = "/metrics"
+
+	selfSignedCertValidity = 365 * 24 * time.Hour
+	shutdownTimeout        = 10 * time.Second
+)
+
+// SecureServer serves the metrics registered in the controller-runtime metrics registry over HTTPS.
+// The certificate and key are read from CertFile and KeyFile and are reloaded when the files change,
+// e.g. when cert-manager renews the certificate. A self-signed certificate is generated if the files are not set
+type SecureServer struct {
+	BindAddress string
+	CertFile    string
+	KeyFile     string
+	Log         logr.Logger
+	// ExtraHandlers are served next to the metrics by path, as the extra handlers of the manager metrics server
+	ExtraHandlers map[string]http.Handler
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	certTime time.Time
+	keyTime  time.Time
+}
+
+// NeedLeaderElection implements LeaderElectionRunnable, metrics are served by all replicas
+func (s *SecureServer) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves the metrics until the context is done
+func (s *SecureServer) Start(ctx context.Context) error {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if s.CertFile == "" && s.KeyFile == "" {
+		cert, err := newSelfSignedCertificate()
+		if err != nil {
+			return err
+		}
+		s.Log.V(consts.LogLevelWarning).Info(
+			"Metrics certificate is not configured, serving metrics with a self-signed certificate")
+		tlsConfig.Certificates = []tls.Certificate{*cert}
+	} else {
+		if _, err := s.getCertificate(nil); err != nil {
+			return err
+		}
+		tlsConfig.GetCertificate = s.getCertificate
+	}
+
+	listener, err := net.Listen("tcp", s.BindAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen on metrics address %s: %v", s.BindAddress, err)
+	}
+	return s.serve(ctx, tls.NewListener(listener, tlsConfig))
+}
+
+func (s *SecureServer) serve(ctx context.Context, listener net.Listener) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, promhttp.HandlerFor(ctrlmetrics.Registry, promhttp.HandlerOpts{
+		ErrorHandling: promhttp.HTTPErrorOnError,
+	}))
+	for path, handler := range s.ExtraHandlers {
+		mux.Handle(path, handler)
+	}
+	server := &http.Server{Handler: mux}
+
+	errCh := make(chan error, 1)
+	go func() {
+		s.Log.V(consts.LogLevelInfo).Info("Serving metrics over HTTPS", "address", listener.Addr().String())
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	case err := <-errCh:
+		return err
+	}
+}
+
+// getCertificate returns the certificate from CertFile and KeyFile, the files are read again
+// if their modification time has changed since the last read
+func (s *SecureServer) getCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	certInfo, err := os.Stat(s.CertFile)
+	if err != nil {
+		return s.cachedCertificate(err)
+	}
+	keyInfo, err := os.Stat(s.KeyFile)
+	if err != nil {
+		return s.cachedCertificate(err)
+	}
+	if s.cert != nil && certInfo.ModTime().Equal(s.certTime) && keyInfo.ModTime().Equal(s.keyTime) {
+		return s.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+	if err != nil {
+		return s.cachedCertificate(err)
+	}
+	if s.cert != nil {
+		s.Log.V(consts.LogLevelInfo).Info("Reloaded metrics certificate", "certFile", s.CertFile)
+	}
+	s.cert = &cert
+	s.certTime = certInfo.ModTime()
+	s.keyTime = keyInfo.ModTime()
+	return s.cert, nil
+}
+
+// cachedCertificate returns the last loaded certificate if the files can't be read,
+// e.g. while they are being replaced, the error is returned if no certificate was loaded yet
+func (s *SecureServer) cachedCertificate(err error) (*tls.Certificate, error) {
+	if s.cert == nil {
+		return nil, fmt.Errorf("failed to load metrics certificate: %v", err)
+	}
+	s.Log.V(consts.LogLevelWarning).Info("Failed to reload metrics certificate, using the previous one",
+		"error", err.Error())
+	return s.cert, nil
+}
+
+// newSelfSignedCertificate generates a self-signed certificate for the metrics endpoint
+func newSelfSignedCertificate() (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate metrics certificate key: %v", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate metrics certificate serial number: %v", err)
+	}
+	dnsNames := []string{"localhost"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		dnsNames = append(dnsNames, hostname)
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "network-operator-metrics"},
+		DNSNames:              dnsNames,
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedCertValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metrics certificate: %v", err)
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
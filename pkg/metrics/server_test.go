@@ -0,0 +1,112 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// writeCertificate writes a new self-signed certificate and its key to the files with the given modification time
+func writeCertificate(certFile, keyFile string, modTime time.Time) *tls.Certificate {
+	cert, err := newSelfSignedCertificate()
+	Expect(err).NotTo(HaveOccurred())
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(os.WriteFile(certFile,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600)).To(Succeed())
+	Expect(os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0600)).To(Succeed())
+	Expect(os.Chtimes(certFile, modTime, modTime)).To(Succeed())
+	Expect(os.Chtimes(keyFile, modTime, modTime)).To(Succeed())
+	return cert
+}
+
+var _ = Describe("Secure metrics server", func() {
+	It("Should serve metrics over HTTPS", func() {
+		cert, err := newSelfSignedCertificate()
+		Expect(err).NotTo(HaveOccurred())
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		server := &SecureServer{Log: zap.New(), ExtraHandlers: map[string]http.Handler{
+			"/extra": http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusAccepted) }),
+		}}
+		go func() {
+			done <- server.serve(ctx, tls.NewListener(listener, &tls.Config{Certificates: []tls.Certificate{*cert}}))
+		}()
+
+		client := &http.Client{Transport: &http.Transport{
+			//nolint:gosec
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}}
+		resp, err := client.Get("https://" + listener.Addr().String() + Path)
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.TLS).NotTo(BeNil())
+
+		resp, err = client.Get("https://" + listener.Addr().String() + "/extra")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusAccepted))
+
+		cancel()
+		Eventually(done).Should(Receive(BeNil()))
+	})
+
+	It("Should reload the certificate when the files change", func() {
+		dir, err := os.MkdirTemp("", "metrics-certs")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		server := &SecureServer{
+			CertFile: filepath.Join(dir, "tls.crt"),
+			KeyFile:  filepath.Join(dir, "tls.key"),
+			Log:      zap.New(),
+		}
+		_, err = server.getCertificate(nil)
+		Expect(err).To(HaveOccurred())
+
+		first := writeCertificate(server.CertFile, server.KeyFile, time.Now().Add(-time.Hour))
+		loaded, err := server.getCertificate(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded.Certificate).To(Equal(first.Certificate))
+
+		second := writeCertificate(server.CertFile, server.KeyFile, time.Now())
+		loaded, err = server.getCertificate(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded.Certificate).To(Equal(second.Certificate))
+
+		Expect(os.Remove(server.KeyFile)).To(Succeed())
+		loaded, err = server.getCertificate(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded.Certificate).To(Equal(second.Certificate))
+	})
+})
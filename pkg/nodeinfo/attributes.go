@@ -34,6 +34,8 @@ const (
 	NodeLabelHostname         = "kubernetes.io/hostname"
 	NodeLabelCPUArch          = "kubernetes.io/arch"
 	NodeLabelMlnxNIC          = "feature.node.kubernetes.io/pci-15b3.present"
+	NodeLabelMlnxSriovCapable = "feature.node.kubernetes.io/pci-15b3.sriov.capable"
+	NodeLabelBondPresent      = "network.nvidia.com/operator.network-bond.present"
 	NodeLabelNvGPU            = "nvidia.com/gpu.present"
 	NodeLabelWaitOFED         = "network.nvidia.com/operator.mofed.wait"
 	NodeLabelCudaVersionMajor = "nvidia.com/cuda.driver.major"
@@ -16,7 +16,11 @@ limitations under the License.
 
 package nodeinfo
 
-import corev1 "k8s.io/api/core/v1"
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/Mellanox/network-operator/pkg/config"
+)
 
 // A Filter applies a filter on a list of Nodes
 type Filter interface {
@@ -141,3 +145,29 @@ func (b *NodeLabelNoValFilterBuilder) Reset() *NodeLabelNoValFilterBuilder {
 	b.filter = newNodeLabelNoValFilter()
 	return b
 }
+
+// IsNodeExcluded returns true if the node is excluded from the management of the operator, i.e. it has
+// the label configured with EXCLUDED_NODE_LABEL set to "true". An empty label disables the exclusion
+func IsNodeExcluded(node *corev1.Node) bool {
+	label := config.FromEnv().State.ExcludedNodeLabel
+	return label != "" && node.GetLabels()[label] == "true"
+}
+
+// A filter of the nodes which are excluded from the management of the operator. use NewNotExcludedNodeFilter
+// to create instances
+type notExcludedNodeFilter struct{}
+
+// Apply Filter on Nodes
+func (f notExcludedNodeFilter) Apply(nodes []*corev1.Node) (filtered []*corev1.Node) {
+	for _, node := range nodes {
+		if !IsNodeExcluded(node) {
+			filtered = append(filtered, node)
+		}
+	}
+	return filtered
+}
+
+// NewNotExcludedNodeFilter returns a Filter which drops the nodes excluded from the management of the operator
+func NewNotExcludedNodeFilter() Filter {
+	return notExcludedNodeFilter{}
+}
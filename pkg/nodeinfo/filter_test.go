@@ -21,6 +21,8 @@ import (
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Mellanox/network-operator/pkg/config"
 )
 
 var _ = Describe("NodeAttributes tests", func() {
@@ -134,4 +136,23 @@ var _ = Describe("NodeAttributes tests", func() {
 			Expect(filteredNodes).To(BeEmpty())
 		})
 	})
+
+	Context("Filter excluded nodes", func() {
+		It("Should drop the nodes with the excluded node label set to true", func() {
+			savedExcludedNodeLabel := config.FromEnv().State.ExcludedNodeLabel
+			defer func() { config.FromEnv().State.ExcludedNodeLabel = savedExcludedNodeLabel }()
+			config.FromEnv().State.ExcludedNodeLabel = "network.nvidia.com/operator.exclude"
+			excluded := nodes[1].DeepCopy()
+			excluded.Labels[config.FromEnv().State.ExcludedNodeLabel] = "true"
+			notExcluded := nodes[2].DeepCopy()
+			notExcluded.Labels[config.FromEnv().State.ExcludedNodeLabel] = "false"
+			Expect(IsNodeExcluded(excluded)).To(BeTrue())
+			Expect(IsNodeExcluded(notExcluded)).To(BeFalse())
+
+			filteredNodes := NewNotExcludedNodeFilter().Apply([]*corev1.Node{nodes[0], excluded, notExcluded})
+			Expect(len(filteredNodes)).To(Equal(2))
+			Expect(filteredNodes[0].Name).To(Equal("node-1"))
+			Expect(filteredNodes[1].Name).To(Equal("node-3"))
+		})
+	})
 })
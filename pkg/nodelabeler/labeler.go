@@ -0,0 +1,206 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package nodelabeler implements a lightweight node agent which sets the node labels required by the operator
+// in clusters without Node Feature Discovery
+package nodelabeler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+)
+
+const (
+	mellanoxVendorID = "0x15b3"
+	// networkClassPrefix matches PCI class codes of network controllers, e.g. Ethernet (0x0200) and InfiniBand (0x0207)
+	networkClassPrefix = "0x02"
+)
+
+// managedLabels are the labels set by the Labeler, labels of features which are not detected are removed
+var managedLabels = []string{
+	nodeinfo.NodeLabelMlnxNIC,
+	nodeinfo.NodeLabelMlnxSriovCapable,
+	nodeinfo.NodeLabelBondPresent,
+	nodeinfo.NodeLabelOSName,
+	nodeinfo.NodeLabelOSVer,
+	nodeinfo.NodeLabelKernelVerFull,
+}
+
+// Labeler detects Mellanox NICs, bond interfaces and OS attributes of the node it runs on and sets the node labels
+// which are otherwise set by Node Feature Discovery, and nodeinfo.NodeLabelBondPresent of the operator
+type Labeler struct {
+	K8sInterface kubernetes.Interface
+	Log          logr.Logger
+	// NodeName is the name of the node the labeler runs on
+	NodeName string
+	// SysfsRoot is the path where the host sysfs is mounted, e.g. /sys
+	SysfsRoot string
+	// OSReleasePath is the path of the host os-release file
+	OSReleasePath string
+}
+
+// Run labels the node every interval until the context is done
+func (l *Labeler) Run(ctx context.Context, interval time.Duration) {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := l.Sync(ctx); err != nil {
+			l.Log.V(consts.LogLevelError).Error(err, "Failed to label the node", "node", l.NodeName)
+		}
+	}, interval)
+}
+
+// Sync detects the node features and updates the node labels if they changed
+func (l *Labeler) Sync(ctx context.Context) error {
+	node, err := l.K8sInterface.CoreV1().Nodes().Get(ctx, l.NodeName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	labels, err := l.DetectLabels()
+	if err != nil {
+		return err
+	}
+	if labels[nodeinfo.NodeLabelKernelVerFull] == "" && node.Status.NodeInfo.KernelVersion != "" {
+		labels[nodeinfo.NodeLabelKernelVerFull] = node.Status.NodeInfo.KernelVersion
+	}
+
+	changes := make(map[string]interface{})
+	for _, key := range managedLabels {
+		value, detected := labels[key]
+		current, exists := node.Labels[key]
+		switch {
+		case detected && (!exists || current != value):
+			changes[key] = value
+		case !detected && exists:
+			// nil removes the label with the merge patch
+			changes[key] = nil
+		}
+	}
+	if len(changes) == 0 {
+		l.Log.V(consts.LogLevelDebug).Info("Node labels are up to date", "node", l.NodeName)
+		return nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{"labels": changes}})
+	if err != nil {
+		return err
+	}
+	l.Log.V(consts.LogLevelInfo).Info("Updating node labels", "node", l.NodeName, "labels", changes)
+	_, err = l.K8sInterface.CoreV1().Nodes().Patch(ctx, l.NodeName, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// DetectLabels returns the labels of the features detected on the node
+func (l *Labeler) DetectLabels() (map[string]string, error) {
+	labels := make(map[string]string)
+	present, sriovCapable, err := l.detectMellanoxNICs()
+	if err != nil {
+		return nil, err
+	}
+	if present {
+		labels[nodeinfo.NodeLabelMlnxNIC] = "true"
+	}
+	if sriovCapable {
+		labels[nodeinfo.NodeLabelMlnxSriovCapable] = "true"
+	}
+	if l.detectBonds() {
+		labels[nodeinfo.NodeLabelBondPresent] = "true"
+	}
+	osRelease, err := readOSRelease(l.OSReleasePath)
+	if err != nil {
+		return nil, err
+	}
+	if osRelease["ID"] != "" {
+		labels[nodeinfo.NodeLabelOSName] = osRelease["ID"]
+	}
+	if osRelease["VERSION_ID"] != "" {
+		labels[nodeinfo.NodeLabelOSVer] = osRelease["VERSION_ID"]
+	}
+	return labels, nil
+}
+
+// detectMellanoxNICs returns whether the node has Mellanox network controllers
+// and whether any of them supports SR-IOV
+func (l *Labeler) detectMellanoxNICs() (present, sriovCapable bool, err error) {
+	devicesDir := filepath.Join(l.SysfsRoot, "bus", "pci", "devices")
+	devices, err := os.ReadDir(devicesDir)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to list PCI devices: %v", err)
+	}
+	for _, device := range devices {
+		devicePath := filepath.Join(devicesDir, device.Name())
+		if readSysfsValue(devicePath, "vendor") != mellanoxVendorID ||
+			!strings.HasPrefix(readSysfsValue(devicePath, "class"), networkClassPrefix) {
+			continue
+		}
+		present = true
+		if totalVfs, err := strconv.Atoi(readSysfsValue(devicePath, "sriov_totalvfs")); err == nil && totalVfs > 0 {
+			sriovCapable = true
+		}
+	}
+	return present, sriovCapable, nil
+}
+
+// detectBonds returns whether bond interfaces are configured on the node
+func (l *Labeler) detectBonds() bool {
+	return readSysfsValue(filepath.Join(l.SysfsRoot, "class", "net"), "bonding_masters") != ""
+}
+
+// readSysfsValue returns the content of the sysfs attribute file, empty string if it can't be read
+func readSysfsValue(devicePath, attribute string) string {
+	data, err := os.ReadFile(filepath.Join(devicePath, attribute))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// readOSRelease parses the os-release file, see os-release(5)
+func readOSRelease(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read os-release: %v", err)
+	}
+	defer f.Close()
+
+	result := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		result[parts[0]] = strings.Trim(parts[1], `"'`)
+	}
+	return result, scanner.Err()
+}
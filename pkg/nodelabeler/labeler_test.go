@@ -0,0 +1,138 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodelabeler_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+	"github.com/Mellanox/network-operator/pkg/nodelabeler"
+)
+
+func addPciDevice(sysfsRoot, address string, attributes map[string]string) {
+	devicePath := filepath.Join(sysfsRoot, "bus", "pci", "devices", address)
+	Expect(os.MkdirAll(devicePath, 0755)).To(Succeed())
+	for name, value := range attributes {
+		Expect(os.WriteFile(filepath.Join(devicePath, name), []byte(value+"\n"), 0600)).To(Succeed())
+	}
+}
+
+var _ = Describe("NIC labeler tests", func() {
+	var (
+		tmpDir    string
+		labeler   *nodelabeler.Labeler
+		clientSet *fake.Clientset
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "nodelabeler")
+		Expect(err).NotTo(HaveOccurred())
+		sysfsRoot := filepath.Join(tmpDir, "sys")
+		osRelease := filepath.Join(tmpDir, "os-release")
+		Expect(os.MkdirAll(filepath.Join(sysfsRoot, "bus", "pci", "devices"), 0755)).To(Succeed())
+		Expect(os.WriteFile(osRelease, []byte("NAME=\"Ubuntu\"\n# comment\nID=ubuntu\nVERSION_ID=\"20.04\"\n"),
+			0600)).To(Succeed())
+
+		clientSet = fake.NewSimpleClientset(&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "node-1",
+				Labels: map[string]string{"app": "test", nodeinfo.NodeLabelMlnxSriovCapable: "true"},
+			},
+			Status: corev1.NodeStatus{NodeInfo: corev1.NodeSystemInfo{KernelVersion: "5.4.0-42-generic"}},
+		})
+		labeler = &nodelabeler.Labeler{
+			K8sInterface:  clientSet,
+			Log:           zap.New(zap.UseDevMode(true)),
+			NodeName:      "node-1",
+			SysfsRoot:     sysfsRoot,
+			OSReleasePath: osRelease,
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	It("Should detect Mellanox network controllers", func() {
+		addPciDevice(labeler.SysfsRoot, "0000:00:01.0", map[string]string{"vendor": "0x8086", "class": "0x020000"})
+		addPciDevice(labeler.SysfsRoot, "0000:00:02.0", map[string]string{"vendor": "0x15b3", "class": "0x010802"})
+		Expect(labeler.DetectLabels()).NotTo(HaveKey(nodeinfo.NodeLabelMlnxNIC))
+
+		addPciDevice(labeler.SysfsRoot, "0000:3b:00.0",
+			map[string]string{"vendor": "0x15b3", "class": "0x020700", "sriov_totalvfs": "0"})
+		labels, err := labeler.DetectLabels()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(labels).To(Equal(map[string]string{
+			nodeinfo.NodeLabelMlnxNIC: "true",
+			nodeinfo.NodeLabelOSName:  "ubuntu",
+			nodeinfo.NodeLabelOSVer:   "20.04",
+		}))
+
+		addPciDevice(labeler.SysfsRoot, "0000:3b:00.1",
+			map[string]string{"vendor": "0x15b3", "class": "0x020000", "sriov_totalvfs": "8"})
+		Expect(labeler.DetectLabels()).To(HaveKeyWithValue(nodeinfo.NodeLabelMlnxSriovCapable, "true"))
+	})
+
+	It("Should detect bond interfaces", func() {
+		Expect(labeler.DetectLabels()).NotTo(HaveKey(nodeinfo.NodeLabelBondPresent))
+
+		netDir := filepath.Join(labeler.SysfsRoot, "class", "net")
+		Expect(os.MkdirAll(netDir, 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(netDir, "bonding_masters"), []byte("\n"), 0600)).To(Succeed())
+		Expect(labeler.DetectLabels()).NotTo(HaveKey(nodeinfo.NodeLabelBondPresent))
+
+		Expect(os.WriteFile(filepath.Join(netDir, "bonding_masters"), []byte("bond0\n"), 0600)).To(Succeed())
+		Expect(labeler.DetectLabels()).To(HaveKeyWithValue(nodeinfo.NodeLabelBondPresent, "true"))
+	})
+
+	It("Should fail if os-release can't be read", func() {
+		labeler.OSReleasePath = filepath.Join(tmpDir, "missing")
+		_, err := labeler.DetectLabels()
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should update the node labels", func() {
+		addPciDevice(labeler.SysfsRoot, "0000:3b:00.0", map[string]string{"vendor": "0x15b3", "class": "0x020000"})
+		Expect(labeler.Sync(context.Background())).To(Succeed())
+
+		node, err := clientSet.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(node.Labels).To(Equal(map[string]string{
+			"app":                           "test",
+			nodeinfo.NodeLabelMlnxNIC:       "true",
+			nodeinfo.NodeLabelOSName:        "ubuntu",
+			nodeinfo.NodeLabelOSVer:         "20.04",
+			nodeinfo.NodeLabelKernelVerFull: "5.4.0-42-generic",
+		}))
+
+		actions := len(clientSet.Actions())
+		Expect(labeler.Sync(context.Background())).To(Succeed())
+		// only the node get is expected as the labels are up to date
+		Expect(clientSet.Actions()).To(HaveLen(actions + 1))
+	})
+})
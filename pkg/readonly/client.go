@@ -0,0 +1,317 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package readonly provides a client which never mutates the cluster. Instead of applying changes, the client
+compares them with the current state of the objects, logs the difference and reports it with a drift metric.
+*/
+package readonly
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/Mellanox/network-operator/pkg/consts"
+)
+
+const (
+	// OperationCreate is reported when the object doesn't exist and would be created
+	OperationCreate = "create"
+	// OperationUpdate is reported when the object exists and differs from the desired state
+	OperationUpdate = "update"
+	// OperationPatch is reported when the patch would change the object
+	OperationPatch = "patch"
+	// OperationDelete is reported when the object exists and would be deleted
+	OperationDelete = "delete"
+)
+
+// DriftGauge is set to 1 for objects which differ from the desired state and to 0 for objects in sync
+var DriftGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "network_operator_drift",
+	Help: "Whether the object differs from the state desired by the operator, reported in read-only mode",
+}, []string{"kind", "namespace", "name", "operation"})
+
+func init() {
+	metrics.Registry.MustRegister(DriftGauge)
+}
+
+// metadata fields managed by the API server which are ignored when the objects are compared
+var ignoredMetadataFields = []string{
+	"resourceVersion", "uid", "generation", "creationTimestamp", "managedFields", "selfLink",
+}
+
+type readOnlyClient struct {
+	client.Client
+	log logr.Logger
+}
+
+// NewClient returns a client which reads through the provided client and, instead of writing,
+// records the changes it would have applied
+func NewClient(c client.Client, log logr.Logger) client.Client {
+	return &readOnlyClient{Client: c, log: log}
+}
+
+// Create reports drift if the object does not exist. AlreadyExists error is returned for existing objects,
+// same as the real client does, so callers fall through to Update which compares the objects.
+func (c *readOnlyClient) Create(ctx context.Context, obj client.Object, _ ...client.CreateOption) error {
+	current, err := c.getCurrent(ctx, obj)
+	if apierrors.IsNotFound(err) {
+		c.report(obj, OperationCreate, []string{"object does not exist"})
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c.report(obj, OperationCreate, nil)
+	gvk, _ := apiutil.GVKForObject(current, c.Scheme())
+	return apierrors.NewAlreadyExists(schema.GroupResource{Group: gvk.Group, Resource: gvk.Kind}, obj.GetName())
+}
+
+// Update reports drift if the desired object differs from the current one
+func (c *readOnlyClient) Update(ctx context.Context, obj client.Object, _ ...client.UpdateOption) error {
+	current, err := c.getCurrent(ctx, obj)
+	if apierrors.IsNotFound(err) {
+		// the object is reported as missing by Create
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	desiredMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return errors.Wrap(err, "failed to convert desired object")
+	}
+	currentMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(current)
+	if err != nil {
+		return errors.Wrap(err, "failed to convert current object")
+	}
+	c.report(obj, OperationUpdate, Diff(stripObject(desiredMap), stripObject(currentMap)))
+	return nil
+}
+
+// Patch reports drift if applying the patch would change the current object
+func (c *readOnlyClient) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	_ ...client.PatchOption) error {
+	current, err := c.getCurrent(ctx, obj)
+	if err != nil {
+		return err
+	}
+	data, err := patch.Data(obj)
+	if err != nil {
+		return errors.Wrap(err, "failed to get patch data")
+	}
+	currentJSON, err := json.Marshal(current)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal current object")
+	}
+
+	var patchedJSON []byte
+	_, isUnstructured := current.(*unstructured.Unstructured)
+	switch {
+	case patch.Type() == types.StrategicMergePatchType && !isUnstructured:
+		patchedJSON, err = strategicpatch.StrategicMergePatch(currentJSON, data, current)
+	case patch.Type() == types.MergePatchType || patch.Type() == types.StrategicMergePatchType:
+		// strategic merge patch can't be applied without the type information, handle it as a merge patch
+		patchedJSON, err = mergePatch(currentJSON, data)
+	default:
+		c.report(obj, OperationPatch, []string{fmt.Sprintf("%s patch: %s", patch.Type(), string(data))})
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to apply patch")
+	}
+
+	patched := map[string]interface{}{}
+	if err := json.Unmarshal(patchedJSON, &patched); err != nil {
+		return errors.Wrap(err, "failed to unmarshal patched object")
+	}
+	currentMap := map[string]interface{}{}
+	if err := json.Unmarshal(currentJSON, &currentMap); err != nil {
+		return errors.Wrap(err, "failed to unmarshal current object")
+	}
+	c.report(obj, OperationPatch, Diff(stripObject(patched), stripObject(currentMap)))
+	return nil
+}
+
+// Delete reports drift if the object exists
+func (c *readOnlyClient) Delete(ctx context.Context, obj client.Object, _ ...client.DeleteOption) error {
+	_, err := c.getCurrent(ctx, obj)
+	if apierrors.IsNotFound(err) {
+		c.report(obj, OperationDelete, nil)
+		// callers expect NotFound error for objects which don't exist
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	c.report(obj, OperationDelete, []string{"object exists"})
+	return nil
+}
+
+// DeleteAllOf is not applied, the request is logged only
+func (c *readOnlyClient) DeleteAllOf(_ context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	c.log.V(consts.LogLevelInfo).Info("Read-only mode, skipping delete all of objects",
+		"kind", c.kind(obj), "options", opts)
+	return nil
+}
+
+// Status returns a status writer which doesn't update objects status
+func (c *readOnlyClient) Status() client.StatusWriter {
+	return &readOnlyStatusWriter{log: c.log}
+}
+
+func (c *readOnlyClient) getCurrent(ctx context.Context, obj client.Object) (client.Object, error) {
+	current := obj.DeepCopyObject().(client.Object)
+	err := c.Get(ctx, types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}, current)
+	return current, err
+}
+
+func (c *readOnlyClient) kind(obj client.Object) string {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return reflect.TypeOf(obj).String()
+	}
+	return gvk.Kind
+}
+
+func (c *readOnlyClient) report(obj client.Object, operation string, diff []string) {
+	kind := c.kind(obj)
+	if len(diff) == 0 {
+		DriftGauge.WithLabelValues(kind, obj.GetNamespace(), obj.GetName(), operation).Set(0)
+		return
+	}
+	DriftGauge.WithLabelValues(kind, obj.GetNamespace(), obj.GetName(), operation).Set(1)
+	c.log.V(consts.LogLevelInfo).Info("Read-only mode, object differs from the desired state",
+		"operation", operation, "kind", kind, "namespace", obj.GetNamespace(), "name", obj.GetName(),
+		"diff", diff)
+}
+
+type readOnlyStatusWriter struct {
+	log logr.Logger
+}
+
+func (w *readOnlyStatusWriter) Update(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+	w.log.V(consts.LogLevelDebug).Info("Read-only mode, skipping status update",
+		"namespace", obj.GetNamespace(), "name", obj.GetName())
+	return nil
+}
+
+func (w *readOnlyStatusWriter) Patch(_ context.Context, obj client.Object, _ client.Patch,
+	_ ...client.PatchOption) error {
+	w.log.V(consts.LogLevelDebug).Info("Read-only mode, skipping status patch",
+		"namespace", obj.GetNamespace(), "name", obj.GetName())
+	return nil
+}
+
+// stripObject removes fields which are not managed by the operator
+func stripObject(obj map[string]interface{}) map[string]interface{} {
+	delete(obj, "status")
+	if metadata, ok := obj["metadata"].(map[string]interface{}); ok {
+		for _, field := range ignoredMetadataFields {
+			delete(metadata, field)
+		}
+	}
+	return obj
+}
+
+// Diff returns the list of paths with values of the desired object which differ from the current object.
+// Fields which are set in the current object only are ignored, e.g. defaults set by the API server.
+func Diff(desired, current map[string]interface{}) []string {
+	var diff []string
+	diffValues("", desired, current, &diff)
+	sort.Strings(diff)
+	return diff
+}
+
+func diffValues(path string, desired, current interface{}, diff *[]string) {
+	switch desiredValue := desired.(type) {
+	case map[string]interface{}:
+		currentValue, ok := current.(map[string]interface{})
+		if !ok {
+			*diff = append(*diff, fmt.Sprintf("%s: %v -> %v", path, current, desired))
+			return
+		}
+		for k, v := range desiredValue {
+			diffValues(strings.TrimPrefix(path+"."+k, "."), v, currentValue[k], diff)
+		}
+	case []interface{}:
+		currentValue, ok := current.([]interface{})
+		if !ok || len(currentValue) != len(desiredValue) {
+			*diff = append(*diff, fmt.Sprintf("%s: %v -> %v", path, current, desired))
+			return
+		}
+		for i := range desiredValue {
+			diffValues(fmt.Sprintf("%s[%d]", path, i), desiredValue[i], currentValue[i], diff)
+		}
+	default:
+		if desired == nil {
+			// empty desired values are omitted on serialization
+			return
+		}
+		if fmt.Sprint(desired) != fmt.Sprint(current) {
+			*diff = append(*diff, fmt.Sprintf("%s: %v -> %v", path, current, desired))
+		}
+	}
+}
+
+// mergePatch applies JSON merge patch (RFC 7386) to the document
+func mergePatch(doc, patch []byte) ([]byte, error) {
+	docMap := map[string]interface{}{}
+	if err := json.Unmarshal(doc, &docMap); err != nil {
+		return nil, err
+	}
+	patchMap := map[string]interface{}{}
+	if err := json.Unmarshal(patch, &patchMap); err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergeMaps(docMap, patchMap))
+}
+
+func mergeMaps(doc, patch map[string]interface{}) map[string]interface{} {
+	for k, v := range patch {
+		if v == nil {
+			delete(doc, k)
+			continue
+		}
+		patchValue, isMap := v.(map[string]interface{})
+		docValue, docIsMap := doc[k].(map[string]interface{})
+		if isMap && docIsMap {
+			doc[k] = mergeMaps(docValue, patchValue)
+		} else if isMap {
+			doc[k] = mergeMaps(map[string]interface{}{}, patchValue)
+		} else {
+			doc[k] = v
+		}
+	}
+	return doc
+}
@@ -0,0 +1,126 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package readonly_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/Mellanox/network-operator/pkg/readonly"
+)
+
+var _ = Describe("Read-only client tests", func() {
+	var (
+		ctx        context.Context
+		fakeClient client.Client
+		roClient   client.Client
+	)
+
+	newConfigMap := func(name string, data map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Data:       data,
+		}
+	}
+	drift := func(name, operation string) float64 {
+		return testutil.ToFloat64(readonly.DriftGauge.WithLabelValues("ConfigMap", "default", name, operation))
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		readonly.DriftGauge.Reset()
+		fakeClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).
+			WithObjects(newConfigMap("existing", map[string]string{"key": "value"})).Build()
+		roClient = readonly.NewClient(fakeClient, ctrl.Log.WithName("test"))
+	})
+
+	It("Should report missing object on create without creating it", func() {
+		Expect(roClient.Create(ctx, newConfigMap("new", nil))).To(Succeed())
+		Expect(drift("new", readonly.OperationCreate)).To(Equal(1.0))
+
+		err := fakeClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: "new"}, &corev1.ConfigMap{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("Should return AlreadyExists on create of existing object", func() {
+		err := roClient.Create(ctx, newConfigMap("existing", nil))
+		Expect(apierrors.IsAlreadyExists(err)).To(BeTrue())
+		Expect(drift("existing", readonly.OperationCreate)).To(Equal(0.0))
+	})
+
+	It("Should report update which changes the object without updating it", func() {
+		Expect(roClient.Update(ctx, newConfigMap("existing", map[string]string{"key": "other"}))).To(Succeed())
+		Expect(drift("existing", readonly.OperationUpdate)).To(Equal(1.0))
+
+		current := &corev1.ConfigMap{}
+		Expect(fakeClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: "existing"}, current)).To(Succeed())
+		Expect(current.Data).To(Equal(map[string]string{"key": "value"}))
+
+		Expect(roClient.Update(ctx, newConfigMap("existing", map[string]string{"key": "value"}))).To(Succeed())
+		Expect(drift("existing", readonly.OperationUpdate)).To(Equal(0.0))
+	})
+
+	It("Should report only effective patches", func() {
+		cm := newConfigMap("existing", nil)
+		patch := client.RawPatch(types.MergePatchType, []byte(`{"data":{"key":"value"}}`))
+		Expect(roClient.Patch(ctx, cm, patch)).To(Succeed())
+		Expect(drift("existing", readonly.OperationPatch)).To(Equal(0.0))
+
+		patch = client.RawPatch(types.StrategicMergePatchType, []byte(`{"data":{"other":"value"}}`))
+		Expect(roClient.Patch(ctx, cm, patch)).To(Succeed())
+		Expect(drift("existing", readonly.OperationPatch)).To(Equal(1.0))
+	})
+
+	It("Should report delete without deleting the object", func() {
+		Expect(roClient.Delete(ctx, newConfigMap("existing", nil))).To(Succeed())
+		Expect(drift("existing", readonly.OperationDelete)).To(Equal(1.0))
+		Expect(fakeClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: "existing"},
+			&corev1.ConfigMap{})).To(Succeed())
+
+		err := roClient.Delete(ctx, newConfigMap("missing", nil))
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("Should skip status updates", func() {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}}
+		Expect(roClient.Status().Update(ctx, node)).To(Succeed())
+	})
+
+	It("Should diff only fields set in the desired object", func() {
+		desired := map[string]interface{}{
+			"spec": map[string]interface{}{"replicas": int64(1), "list": []interface{}{"a"}},
+		}
+		current := map[string]interface{}{
+			"spec": map[string]interface{}{"replicas": float64(1), "list": []interface{}{"a"}, "defaulted": true},
+		}
+		Expect(readonly.Diff(desired, current)).To(BeEmpty())
+
+		current["spec"].(map[string]interface{})["list"] = []interface{}{"b"}
+		Expect(readonly.Diff(desired, current)).To(Equal([]string{"spec.list[0]: b -> a"}))
+	})
+})
@@ -0,0 +1,39 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"fmt"
+	"strings"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+)
+
+// netAttDefCNIVersion returns the cniVersion of the NetworkAttachmentDefinition config generated for the network,
+// mellanoxv1alpha1.DefaultCNIVersion is used if the version is not set in the network spec
+func netAttDefCNIVersion(version string) (string, error) {
+	if version == "" {
+		return mellanoxv1alpha1.DefaultCNIVersion, nil
+	}
+	for _, supported := range mellanoxv1alpha1.SupportedCNIVersions {
+		if version == supported {
+			return version, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported cniVersion %q, expected one of %s",
+		version, strings.Join(mellanoxv1alpha1.SupportedCNIVersions, ", "))
+}
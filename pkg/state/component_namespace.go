@@ -0,0 +1,94 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/config"
+	"github.com/Mellanox/network-operator/pkg/consts"
+)
+
+// OfedDriverNamespace returns the namespace of the OFED driver objects
+func OfedDriverNamespace(cr *mellanoxv1alpha1.NicClusterPolicy) string {
+	if cr.Spec.OFEDDriver == nil {
+		return componentNamespace("")
+	}
+	return componentNamespace(cr.Spec.OFEDDriver.Namespace)
+}
+
+// docaTelemetryNamespace returns the namespace of the DOCA Telemetry Service objects
+func docaTelemetryNamespace(cr *mellanoxv1alpha1.NicClusterPolicy) string {
+	if cr.Spec.DOCATelemetry == nil {
+		return componentNamespace("")
+	}
+	return componentNamespace(cr.Spec.DOCATelemetry.Namespace)
+}
+
+// componentNamespace returns the namespace override of a component, or the namespace of the operator if not set
+func componentNamespace(namespace string) string {
+	if namespace == "" {
+		return config.FromEnv().State.NetworkOperatorResourceNamespace
+	}
+	return namespace
+}
+
+// deleteObjsInOtherNamespaces deletes the objects created by the operator with the same kind and name as the
+// rendered namespaced objects in other namespaces, e.g. after the namespace of the component was changed
+func (s *stateSkel) deleteObjsInOtherNamespaces(objs []*unstructured.Unstructured) error {
+	for _, obj := range objs {
+		if obj.GetNamespace() == "" {
+			continue
+		}
+		if err := s.deleteManagedObjs(obj.GroupVersionKind(), obj.GetName(), obj.GetNamespace()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteManagedObjs deletes the objects created by the operator with the given kind and name in all namespaces
+// except the excluded one, e.g. objects which are no longer rendered for the component
+func (s *stateSkel) deleteManagedObjs(gvk schema.GroupVersionKind, name, excludedNamespace string) error {
+	list := &unstructured.UnstructuredList{}
+	listGVK := gvk
+	listGVK.Kind += "List"
+	list.SetGroupVersionKind(listGVK)
+	if err := s.client.List(context.TODO(), list, client.MatchingLabels(ManagedLabels())); err != nil {
+		return errors.Wrapf(err, "failed to list %s objects", gvk.Kind)
+	}
+	for i := range list.Items {
+		stale := &list.Items[i]
+		if stale.GetName() != name || stale.GetNamespace() == excludedNamespace {
+			continue
+		}
+		log.V(consts.LogLevelInfo).Info("Deleting stale object", "Kind:", stale.GetKind(),
+			"Namespace:", stale.GetNamespace(), "Name:", stale.GetName())
+		if err := s.client.Delete(context.TODO(), stale); err != nil && !k8serrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete %s %s/%s", stale.GetKind(), stale.GetNamespace(),
+				stale.GetName())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/config"
+)
+
+var _ = Describe("Component namespace tests", func() {
+	It("Should use the operator namespace if the namespace is not set", func() {
+		operatorNamespace := config.FromEnv().State.NetworkOperatorResourceNamespace
+		cr := &mellanoxv1alpha1.NicClusterPolicy{}
+		Expect(OfedDriverNamespace(cr)).To(Equal(operatorNamespace))
+		Expect(docaTelemetryNamespace(cr)).To(Equal(operatorNamespace))
+
+		cr.Spec.OFEDDriver = &mellanoxv1alpha1.OFEDDriverSpec{}
+		cr.Spec.DOCATelemetry = &mellanoxv1alpha1.DOCATelemetrySpec{Namespace: "telemetry"}
+		Expect(OfedDriverNamespace(cr)).To(Equal(operatorNamespace))
+		Expect(docaTelemetryNamespace(cr)).To(Equal("telemetry"))
+
+		cr.Spec.OFEDDriver.Namespace = "driver"
+		Expect(OfedDriverNamespace(cr)).To(Equal("driver"))
+	})
+
+	It("Should delete objects left in other namespaces", func() {
+		newDaemonSet := func(namespace, name string, labels map[string]string) *appsv1.DaemonSet {
+			return &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels}}
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(
+			newDaemonSet("driver", "mofed-ds", ManagedLabels()),
+			newDaemonSet("previous", "mofed-ds", ManagedLabels()),
+			newDaemonSet("previous", "other-ds", ManagedLabels()),
+			newDaemonSet("unmanaged", "mofed-ds", nil),
+		).Build()
+		s := &stateSkel{client: fakeClient}
+
+		rendered := &unstructured.Unstructured{}
+		rendered.SetAPIVersion("apps/v1")
+		rendered.SetKind("DaemonSet")
+		rendered.SetNamespace("driver")
+		rendered.SetName("mofed-ds")
+		Expect(s.deleteObjsInOtherNamespaces([]*unstructured.Unstructured{rendered})).To(Succeed())
+
+		daemonSets := &appsv1.DaemonSetList{}
+		Expect(fakeClient.List(context.TODO(), daemonSets)).To(Succeed())
+		var remaining []string
+		for _, ds := range daemonSets.Items {
+			remaining = append(remaining, client.ObjectKeyFromObject(&ds).String())
+		}
+		Expect(remaining).To(ConsistOf("driver/mofed-ds", "previous/other-ds", "unmanaged/mofed-ds"))
+	})
+})
@@ -0,0 +1,70 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ConfigHashAnnotation is set on the pod template of the DaemonSets to the hash of the ConfigMaps rendered
+// in the same state, so that the pods are restarted when the configuration changes
+const ConfigHashAnnotation = "nvidia.com/config-hash"
+
+// setConfigHashAnnotation sets ConfigHashAnnotation on the pod templates of the rendered DaemonSets
+// to the hash of the rendered ConfigMaps data
+func setConfigHashAnnotation(objs []*unstructured.Unstructured) error {
+	var configs []interface{}
+	for _, obj := range objs {
+		if obj.GetKind() != "ConfigMap" {
+			continue
+		}
+		// map keys are sorted on marshalling, so the hash is stable
+		configs = append(configs, []interface{}{obj.GetName(), obj.Object["data"]})
+	}
+	if len(configs) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(configs)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal ConfigMaps data")
+	}
+	hash := fmt.Sprintf("%x", sha256.Sum256(data))
+
+	for _, obj := range objs {
+		if obj.GetKind() != "DaemonSet" {
+			continue
+		}
+		annotations, _, err := unstructured.NestedStringMap(obj.Object, "spec", "template", "metadata", "annotations")
+		if err != nil {
+			return errors.Wrapf(err, "failed to get pod template annotations of DaemonSet %s", obj.GetName())
+		}
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[ConfigHashAnnotation] = hash
+		err = unstructured.SetNestedStringMap(obj.Object, annotations, "spec", "template", "metadata", "annotations")
+		if err != nil {
+			return errors.Wrapf(err, "failed to set pod template annotations of DaemonSet %s", obj.GetName())
+		}
+	}
+	return nil
+}
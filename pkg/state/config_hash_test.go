@@ -0,0 +1,69 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var _ = Describe("Config hash annotation tests", func() {
+	newObjs := func(config string) []*unstructured.Unstructured {
+		return []*unstructured.Unstructured{
+			{Object: map[string]interface{}{
+				"kind":     "ConfigMap",
+				"metadata": map[string]interface{}{"name": "config"},
+				"data":     map[string]interface{}{"config.json": config},
+			}},
+			{Object: map[string]interface{}{
+				"kind":     "DaemonSet",
+				"metadata": map[string]interface{}{"name": "ds"},
+				"spec": map[string]interface{}{"template": map[string]interface{}{
+					"metadata": map[string]interface{}{"annotations": map[string]interface{}{"foo": "bar"}},
+				}},
+			}},
+		}
+	}
+	getHash := func(ds *unstructured.Unstructured) string {
+		annotations, _, _ := unstructured.NestedStringMap(ds.Object, "spec", "template", "metadata", "annotations")
+		Expect(annotations).To(HaveKeyWithValue("foo", "bar"))
+		return annotations[ConfigHashAnnotation]
+	}
+
+	It("Should set the hash of the ConfigMap on the DaemonSet pod template", func() {
+		objs := newObjs(`{"resourceList": []}`)
+		Expect(setConfigHashAnnotation(objs)).To(Succeed())
+		hash := getHash(objs[1])
+		Expect(hash).NotTo(BeEmpty())
+
+		sameObjs := newObjs(`{"resourceList": []}`)
+		Expect(setConfigHashAnnotation(sameObjs)).To(Succeed())
+		Expect(getHash(sameObjs[1])).To(Equal(hash))
+
+		changedObjs := newObjs(`{"resourceList": [{"resourceName": "hostdev"}]}`)
+		Expect(setConfigHashAnnotation(changedObjs)).To(Succeed())
+		Expect(getHash(changedObjs[1])).NotTo(Equal(hash))
+	})
+
+	It("Should not set the annotation without ConfigMaps", func() {
+		objs := newObjs("")[1:]
+		Expect(setConfigHashAnnotation(objs)).To(Succeed())
+		Expect(getHash(objs[0])).To(BeEmpty())
+	})
+})
@@ -0,0 +1,78 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// addContainerEnv appends the environment variables to the containers of the rendered DaemonSets, after the
+// environment variables rendered by the operator. A variable must not have the name of a rendered variable,
+// so that the settings managed by the operator can't be overridden by accident
+func addContainerEnv(objs []*unstructured.Unstructured, env []v1.EnvVar) error {
+	if len(env) == 0 {
+		return nil
+	}
+	additional := make([]interface{}, 0, len(env))
+	names := make(map[string]bool, len(env))
+	for i := range env {
+		if env[i].Name == "" {
+			return errors.New("name of environment variable must be set")
+		}
+		if names[env[i].Name] {
+			return errors.Errorf("environment variable %s is set more than once", env[i].Name)
+		}
+		names[env[i].Name] = true
+		envVar, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&env[i])
+		if err != nil {
+			return errors.Wrapf(err, "failed to convert environment variable %s", env[i].Name)
+		}
+		additional = append(additional, envVar)
+	}
+
+	for _, obj := range objs {
+		if obj.GetKind() != "DaemonSet" {
+			continue
+		}
+		containers, _, err := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", "containers")
+		if err != nil {
+			return errors.Wrapf(err, "failed to get containers of DaemonSet %s", obj.GetName())
+		}
+		for _, container := range containers {
+			containerMap, ok := container.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			rendered, _ := containerMap["env"].([]interface{})
+			for _, envVar := range rendered {
+				if name, ok := envVar.(map[string]interface{})["name"].(string); ok && names[name] {
+					return errors.Errorf("environment variable %s collides with an environment variable "+
+						"managed by the operator in DaemonSet %s", name, obj.GetName())
+				}
+			}
+			containerMap["env"] = append(rendered, runtime.DeepCopyJSONValue(additional).([]interface{})...)
+		}
+		err = unstructured.SetNestedSlice(obj.Object, containers, "spec", "template", "spec", "containers")
+		if err != nil {
+			return errors.Wrapf(err, "failed to set containers of DaemonSet %s", obj.GetName())
+		}
+	}
+	return nil
+}
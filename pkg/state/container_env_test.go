@@ -0,0 +1,69 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var _ = Describe("Container env tests", func() {
+	var ds *unstructured.Unstructured
+
+	BeforeEach(func() {
+		ds = &unstructured.Unstructured{Object: map[string]interface{}{
+			"kind": "DaemonSet",
+			"spec": map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{
+				"initContainers": []interface{}{map[string]interface{}{"name": "init"}},
+				"containers": []interface{}{map[string]interface{}{"name": "main", "env": []interface{}{
+					map[string]interface{}{"name": "NODE_NAME", "value": "node"},
+				}}},
+			}}},
+		}}
+	})
+
+	It("Should append the environment variables to the rendered containers", func() {
+		Expect(addContainerEnv([]*unstructured.Unstructured{ds}, []v1.EnvVar{
+			{Name: "LOG_LEVEL", Value: "debug"},
+			{Name: "RESOURCE_PREFIX", Value: "nvidia.com"},
+		})).To(Succeed())
+
+		containers, _, _ := unstructured.NestedSlice(ds.Object, "spec", "template", "spec", "containers")
+		Expect(containers[0].(map[string]interface{})["env"]).To(Equal([]interface{}{
+			map[string]interface{}{"name": "NODE_NAME", "value": "node"},
+			map[string]interface{}{"name": "LOG_LEVEL", "value": "debug"},
+			map[string]interface{}{"name": "RESOURCE_PREFIX", "value": "nvidia.com"},
+		}))
+		initContainers, _, _ := unstructured.NestedSlice(ds.Object, "spec", "template", "spec", "initContainers")
+		Expect(initContainers[0]).NotTo(HaveKey("env"))
+	})
+
+	It("Should reject environment variables managed by the operator", func() {
+		Expect(addContainerEnv([]*unstructured.Unstructured{ds}, []v1.EnvVar{{Name: "NODE_NAME", Value: "other"}})).
+			NotTo(Succeed())
+	})
+
+	It("Should reject duplicate environment variables", func() {
+		Expect(addContainerEnv([]*unstructured.Unstructured{ds}, []v1.EnvVar{
+			{Name: "LOG_LEVEL", Value: "debug"},
+			{Name: "LOG_LEVEL", Value: "info"},
+		})).NotTo(Succeed())
+	})
+})
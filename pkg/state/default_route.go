@@ -0,0 +1,132 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	ipv4DefaultRoute = "0.0.0.0/0"
+	ipv6DefaultRoute = "::/0"
+)
+
+// ipamWithDefaultRoute returns the IPAM configuration of the network with the default routes added to its routes
+// if defaultRoute is true, the IPAM configuration is rejected if it sets a default route while defaultRoute is false.
+// A default route is added for each IP family of the gateways, ranges and subnets of the IPAM configuration,
+// IPv4 if none is found. The IPAM configuration is returned unchanged if defaultRoute is not set
+func ipamWithDefaultRoute(ipam string, defaultRoute *bool) (string, error) {
+	if defaultRoute == nil {
+		return ipam, nil
+	}
+	if strings.TrimSpace(ipam) == "" {
+		if *defaultRoute {
+			return "", fmt.Errorf("defaultRoute requires IPAM configuration")
+		}
+		return ipam, nil
+	}
+	ipamConfig := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(ipam), &ipamConfig); err != nil {
+		return "", fmt.Errorf("invalid IPAM configuration: %v", err)
+	}
+	routes, ok := ipamConfig["routes"].([]interface{})
+	if _, set := ipamConfig["routes"]; set && !ok {
+		return "", fmt.Errorf("invalid IPAM configuration: routes must be a list")
+	}
+
+	present := map[string]bool{}
+	for _, route := range routes {
+		if routeMap, ok := route.(map[string]interface{}); ok {
+			if dst, ok := routeMap["dst"].(string); ok {
+				if family := defaultRouteFamily(dst); family != "" {
+					present[family] = true
+				}
+			}
+		}
+	}
+	if !*defaultRoute {
+		if len(present) > 0 {
+			return "", fmt.Errorf("defaultRoute is false, but IPAM configuration sets a default route")
+		}
+		return ipam, nil
+	}
+
+	v4, v6 := ipamIPFamilies(ipamConfig)
+	if v4 || !v6 {
+		if !present[ipv4DefaultRoute] {
+			routes = append(routes, map[string]interface{}{"dst": ipv4DefaultRoute})
+		}
+	}
+	if v6 && !present[ipv6DefaultRoute] {
+		routes = append(routes, map[string]interface{}{"dst": ipv6DefaultRoute})
+	}
+	ipamConfig["routes"] = routes
+	result, err := json.Marshal(ipamConfig)
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
+
+// defaultRouteFamily returns ipv4DefaultRoute or ipv6DefaultRoute if the route destination is a default route
+// of the IP family, an empty string otherwise
+func defaultRouteFamily(dst string) string {
+	_, ipNet, err := net.ParseCIDR(dst)
+	if err != nil {
+		return ""
+	}
+	if ones, _ := ipNet.Mask.Size(); ones != 0 {
+		return ""
+	}
+	if ipNet.IP.To4() != nil {
+		return ipv4DefaultRoute
+	}
+	return ipv6DefaultRoute
+}
+
+// ipamIPFamilies returns the IP families of the gateway, range and subnet values at any level
+// of the IPAM configuration, e.g. of host-local ranges or whereabouts ipRanges
+func ipamIPFamilies(value interface{}) (v4, v6 bool) {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, field := range typed {
+			if str, ok := field.(string); ok && (key == "gateway" || key == "range" || key == "subnet") {
+				ip := net.ParseIP(strings.SplitN(str, "/", 2)[0])
+				if ip == nil {
+					continue
+				}
+				if ip.To4() != nil {
+					v4 = true
+				} else {
+					v6 = true
+				}
+				continue
+			}
+			fieldV4, fieldV6 := ipamIPFamilies(field)
+			v4, v6 = v4 || fieldV4, v6 || fieldV6
+		}
+	case []interface{}:
+		for _, item := range typed {
+			itemV4, itemV6 := ipamIPFamilies(item)
+			v4, v6 = v4 || itemV4, v6 || itemV6
+		}
+	}
+	return v4, v6
+}
@@ -0,0 +1,196 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+)
+
+// ApplyDevicePluginConfigs sets the config of the device plugins of the NicClusterPolicy which reference
+// a DevicePluginConfig with configRef and merges the RDMA resource pools contributed by DevicePluginConfigs,
+// the NicClusterPolicy is modified in memory only. The returned statuses are keyed by the DevicePluginConfig name,
+// an error is returned if a referenced DevicePluginConfig doesn't exist or has no config, the config of the device
+// plugin is left empty then and its state fails to sync without affecting the other states
+func ApplyDevicePluginConfigs(cr *mellanoxv1alpha1.NicClusterPolicy,
+	configs []mellanoxv1alpha1.DevicePluginConfig) (map[string]mellanoxv1alpha1.DevicePluginConfigStatus, error) {
+	statuses, err := resolveDevicePluginConfigRefs(cr, configs)
+	for name, status := range MergeDevicePluginConfigs(cr, configs) {
+		// pools which are not merged are reported even if the config is used
+		if referenced, ok := statuses[name]; ok && status.State != mellanoxv1alpha1.StateError {
+			status = referenced
+		}
+		statuses[name] = status
+	}
+	for i := range configs {
+		if _, ok := statuses[configs[i].Name]; !ok {
+			statuses[configs[i].Name] = mellanoxv1alpha1.DevicePluginConfigStatus{
+				State:  mellanoxv1alpha1.StateIgnore,
+				Reason: "config is not referenced by NicClusterPolicy and no resource pools are defined",
+			}
+		}
+	}
+	return statuses, err
+}
+
+// resolveDevicePluginConfigRefs replaces the configRef of the device plugins with the config
+// of the referenced DevicePluginConfigs and returns the statuses of the referenced DevicePluginConfigs
+func resolveDevicePluginConfigRefs(cr *mellanoxv1alpha1.NicClusterPolicy,
+	configs []mellanoxv1alpha1.DevicePluginConfig) (map[string]mellanoxv1alpha1.DevicePluginConfigStatus, error) {
+	statuses := make(map[string]mellanoxv1alpha1.DevicePluginConfigStatus, len(configs))
+	byName := make(map[string]*mellanoxv1alpha1.DevicePluginConfig, len(configs))
+	for i := range configs {
+		byName[configs[i].Name] = &configs[i]
+	}
+	type configRef struct {
+		field  string
+		ref    string
+		config *string
+	}
+	var refs []configRef
+	if dp := cr.Spec.SriovDevicePlugin; dp != nil && dp.IsEnabled() && dp.ConfigRef != "" {
+		refs = append(refs, configRef{field: "sriovDevicePlugin", ref: dp.ConfigRef, config: &dp.Config})
+	}
+	if dp := cr.Spec.RdmaSharedDevicePlugin; dp != nil && dp.IsEnabled() && dp.ConfigRef != "" {
+		refs = append(refs, configRef{field: "rdmaSharedDevicePlugin", ref: dp.ConfigRef, config: &dp.Config})
+	}
+
+	var errs []string
+	for _, ref := range refs {
+		dpConfig, ok := byName[ref.ref]
+		if !ok || dpConfig.Spec.Config == "" {
+			*ref.config = ""
+		}
+		if !ok {
+			errs = append(errs, fmt.Sprintf("DevicePluginConfig %s referenced by %s doesn't exist", ref.ref, ref.field))
+			continue
+		}
+		if dpConfig.Spec.Config == "" {
+			errs = append(errs, fmt.Sprintf("DevicePluginConfig %s referenced by %s has no config", ref.ref, ref.field))
+			statuses[ref.ref] = mellanoxv1alpha1.DevicePluginConfigStatus{
+				State: mellanoxv1alpha1.StateError, Reason: fmt.Sprintf("config is required by %s", ref.field)}
+			continue
+		}
+		*ref.config = dpConfig.Spec.Config
+		if _, ok := statuses[ref.ref]; !ok {
+			statuses[ref.ref] = mellanoxv1alpha1.DevicePluginConfigStatus{State: mellanoxv1alpha1.StateReady}
+		}
+	}
+	if len(errs) > 0 {
+		return statuses, fmt.Errorf("failed to resolve device plugin config references: %s", strings.Join(errs, "; "))
+	}
+	return statuses, nil
+}
+
+// unresolvedConfigRefError returns an error if the configRef of a device plugin was not resolved to a config,
+// the device plugin is not synced then so that the running device plugin keeps its configuration
+func unresolvedConfigRefError(field, configRef, config string) error {
+	if configRef == "" || config != "" {
+		return nil
+	}
+	return fmt.Errorf("DevicePluginConfig %s referenced by %s doesn't exist or has no config", configRef, field)
+}
+
+// MergeDevicePluginConfigs appends the RDMA resource pools contributed by DevicePluginConfigs to the resource pools
+// of the RDMA shared device plugin in the NicClusterPolicy, the NicClusterPolicy is modified in memory only.
+// DevicePluginConfigs are merged in the order of their creation, a DevicePluginConfig is either merged completely
+// or not at all: a pool name which is already defined by NicClusterPolicy or by an earlier DevicePluginConfig
+// is a conflict, so that a new contributor can never take over the resource of an existing one.
+// The returned statuses are keyed by the DevicePluginConfig name, DevicePluginConfigs without pools are skipped.
+func MergeDevicePluginConfigs(cr *mellanoxv1alpha1.NicClusterPolicy,
+	configs []mellanoxv1alpha1.DevicePluginConfig) map[string]mellanoxv1alpha1.DevicePluginConfigStatus {
+	statuses := make(map[string]mellanoxv1alpha1.DevicePluginConfigStatus, len(configs))
+	var withPools []mellanoxv1alpha1.DevicePluginConfig
+	for i := range configs {
+		if len(configs[i].Spec.RdmaSharedResourcePools) > 0 {
+			withPools = append(withPools, configs[i])
+		}
+	}
+	configs = withPools
+	if len(configs) == 0 {
+		return statuses
+	}
+	dpSpec := cr.Spec.RdmaSharedDevicePlugin
+	if dpSpec == nil || !dpSpec.IsEnabled() {
+		for i := range configs {
+			statuses[configs[i].Name] = mellanoxv1alpha1.DevicePluginConfigStatus{
+				State:  mellanoxv1alpha1.StateIgnore,
+				Reason: "RDMA shared device plugin is not deployed by NicClusterPolicy",
+			}
+		}
+		return statuses
+	}
+	if dpSpec.Config != "" {
+		for i := range configs {
+			statuses[configs[i].Name] = mellanoxv1alpha1.DevicePluginConfigStatus{
+				State: mellanoxv1alpha1.StateError,
+				Reason: "RDMA shared device plugin of NicClusterPolicy is configured with config or configRef, " +
+					"resourcePools are required to merge the pools",
+			}
+		}
+		return statuses
+	}
+
+	sorted := make([]*mellanoxv1alpha1.DevicePluginConfig, len(configs))
+	for i := range configs {
+		sorted[i] = &configs[i]
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if !sorted[i].CreationTimestamp.Equal(&sorted[j].CreationTimestamp) {
+			return sorted[i].CreationTimestamp.Before(&sorted[j].CreationTimestamp)
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	// owner of each resource pool name
+	owners := make(map[string]string, len(dpSpec.ResourcePools))
+	for i := range dpSpec.ResourcePools {
+		owners[dpSpec.ResourcePools[i].Name] = mellanoxv1alpha1.NicClusterPolicyCRDName
+	}
+	pools := append([]mellanoxv1alpha1.RdmaSharedDevicePoolSpec(nil), dpSpec.ResourcePools...)
+	for _, dpConfig := range sorted {
+		conflict := ""
+		names := make(map[string]bool, len(dpConfig.Spec.RdmaSharedResourcePools))
+		for i := range dpConfig.Spec.RdmaSharedResourcePools {
+			name := dpConfig.Spec.RdmaSharedResourcePools[i].Name
+			if owner, ok := owners[name]; ok {
+				conflict = fmt.Sprintf("resource pool %q is already defined by %s", name, owner)
+				break
+			}
+			if name == "" || names[name] {
+				conflict = fmt.Sprintf("resource pool name %q is empty or not unique", name)
+				break
+			}
+			names[name] = true
+		}
+		if conflict != "" {
+			statuses[dpConfig.Name] = mellanoxv1alpha1.DevicePluginConfigStatus{
+				State: mellanoxv1alpha1.StateError, Reason: conflict}
+			continue
+		}
+		for name := range names {
+			owners[name] = mellanoxv1alpha1.DevicePluginConfigCRDName + " " + dpConfig.Name
+		}
+		pools = append(pools, dpConfig.Spec.RdmaSharedResourcePools...)
+		statuses[dpConfig.Name] = mellanoxv1alpha1.DevicePluginConfigStatus{State: mellanoxv1alpha1.StateReady}
+	}
+	dpSpec.ResourcePools = pools
+	return statuses
+}
@@ -0,0 +1,138 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/state"
+)
+
+var _ = Describe("Device plugin config tests", func() {
+	var cr *mellanoxv1alpha1.NicClusterPolicy
+	created := time.Now()
+
+	newPool := func(name string) mellanoxv1alpha1.RdmaSharedDevicePoolSpec {
+		return mellanoxv1alpha1.RdmaSharedDevicePoolSpec{
+			Name:      name,
+			Selectors: mellanoxv1alpha1.RdmaSharedDevicePoolSelectors{IfNames: []string{name}},
+		}
+	}
+	newConfig := func(name string, createdAfter time.Duration, pools ...string) mellanoxv1alpha1.DevicePluginConfig {
+		dpConfig := mellanoxv1alpha1.DevicePluginConfig{ObjectMeta: metav1.ObjectMeta{
+			Name: name, CreationTimestamp: metav1.NewTime(created.Add(createdAfter))}}
+		for _, pool := range pools {
+			dpConfig.Spec.RdmaSharedResourcePools = append(dpConfig.Spec.RdmaSharedResourcePools, newPool(pool))
+		}
+		return dpConfig
+	}
+	poolNames := func() []string {
+		var names []string
+		for _, pool := range cr.Spec.RdmaSharedDevicePlugin.ResourcePools {
+			names = append(names, pool.Name)
+		}
+		return names
+	}
+
+	BeforeEach(func() {
+		cr = &mellanoxv1alpha1.NicClusterPolicy{
+			Spec: mellanoxv1alpha1.NicClusterPolicySpec{
+				RdmaSharedDevicePlugin: &mellanoxv1alpha1.RdmaSharedDevicePluginSpec{
+					ImageSpec:     mellanoxv1alpha1.ImageSpec{Image: "k8s-rdma-shared-dev-plugin"},
+					ResourcePools: []mellanoxv1alpha1.RdmaSharedDevicePoolSpec{newPool("rdma_shared_device_a")},
+				},
+			},
+		}
+	})
+
+	It("Should merge resource pools of all contributors", func() {
+		statuses := state.MergeDevicePluginConfigs(cr, []mellanoxv1alpha1.DevicePluginConfig{
+			newConfig("team-b", time.Minute, "team_b"),
+			newConfig("team-a", 0, "team_a_1", "team_a_2"),
+		})
+		Expect(statuses).To(HaveLen(2))
+		Expect(statuses["team-a"].State).To(BeEquivalentTo(mellanoxv1alpha1.StateReady))
+		Expect(statuses["team-b"].State).To(BeEquivalentTo(mellanoxv1alpha1.StateReady))
+		Expect(poolNames()).To(Equal([]string{"rdma_shared_device_a", "team_a_1", "team_a_2", "team_b"}))
+	})
+	It("Should reject contributors with duplicate resource names", func() {
+		statuses := state.MergeDevicePluginConfigs(cr, []mellanoxv1alpha1.DevicePluginConfig{
+			newConfig("late", time.Minute, "late_pool", "shared"),
+			newConfig("early", 0, "shared"),
+			newConfig("policy-conflict", 0, "rdma_shared_device_a"),
+		})
+		Expect(statuses["early"].State).To(BeEquivalentTo(mellanoxv1alpha1.StateReady))
+		Expect(statuses["late"].State).To(BeEquivalentTo(mellanoxv1alpha1.StateError))
+		Expect(statuses["late"].Reason).To(ContainSubstring(`"shared" is already defined by DevicePluginConfig early`))
+		Expect(statuses["policy-conflict"].State).To(BeEquivalentTo(mellanoxv1alpha1.StateError))
+		Expect(statuses["policy-conflict"].Reason).To(ContainSubstring("NicClusterPolicy"))
+		Expect(poolNames()).To(Equal([]string{"rdma_shared_device_a", "shared"}))
+	})
+	It("Should not merge pools into a raw device plugin config", func() {
+		cr.Spec.RdmaSharedDevicePlugin.ResourcePools = nil
+		cr.Spec.RdmaSharedDevicePlugin.Config = `{"configList": []}`
+		statuses := state.MergeDevicePluginConfigs(cr, []mellanoxv1alpha1.DevicePluginConfig{
+			newConfig("team-a", 0, "team_a")})
+		Expect(statuses["team-a"].State).To(BeEquivalentTo(mellanoxv1alpha1.StateError))
+		Expect(cr.Spec.RdmaSharedDevicePlugin.ResourcePools).To(BeEmpty())
+	})
+	It("Should ignore contributors if the device plugin is not deployed", func() {
+		cr.Spec.RdmaSharedDevicePlugin = nil
+		statuses := state.MergeDevicePluginConfigs(cr, []mellanoxv1alpha1.DevicePluginConfig{
+			newConfig("team-a", 0, "team_a")})
+		Expect(statuses["team-a"].State).To(BeEquivalentTo(mellanoxv1alpha1.StateIgnore))
+	})
+
+	It("Should use the config of the referenced DevicePluginConfigs", func() {
+		cr.Spec.RdmaSharedDevicePlugin.ResourcePools = nil
+		cr.Spec.RdmaSharedDevicePlugin.ConfigRef = "rdma"
+		cr.Spec.SriovDevicePlugin = &mellanoxv1alpha1.DevicePluginSpec{
+			ImageSpec: mellanoxv1alpha1.ImageSpec{Image: "sriov-device-plugin"}, ConfigRef: "sriov"}
+		rdmaConfig := newConfig("rdma", 0)
+		rdmaConfig.Spec.Config = `{"configList": []}`
+		sriovConfig := newConfig("sriov", 0)
+		sriovConfig.Spec.Config = `{"resourceList": []}`
+		statuses, err := state.ApplyDevicePluginConfigs(cr, []mellanoxv1alpha1.DevicePluginConfig{
+			rdmaConfig, sriovConfig, newConfig("unused", 0), newConfig("team-a", 0, "team_a")})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cr.Spec.RdmaSharedDevicePlugin.Config).To(Equal(`{"configList": []}`))
+		Expect(cr.Spec.SriovDevicePlugin.Config).To(Equal(`{"resourceList": []}`))
+		Expect(statuses["rdma"].State).To(BeEquivalentTo(mellanoxv1alpha1.StateReady))
+		Expect(statuses["sriov"].State).To(BeEquivalentTo(mellanoxv1alpha1.StateReady))
+		Expect(statuses["unused"].State).To(BeEquivalentTo(mellanoxv1alpha1.StateIgnore))
+		// pools can't be merged into the referenced config
+		Expect(statuses["team-a"].State).To(BeEquivalentTo(mellanoxv1alpha1.StateError))
+	})
+	It("Should fail if the referenced DevicePluginConfig doesn't exist or has no config", func() {
+		cr.Spec.RdmaSharedDevicePlugin.ResourcePools = nil
+		cr.Spec.RdmaSharedDevicePlugin.ConfigRef = "rdma"
+		cr.Spec.SriovDevicePlugin = &mellanoxv1alpha1.DevicePluginSpec{ConfigRef: "missing", Config: "stale"}
+		statuses, err := state.ApplyDevicePluginConfigs(cr, []mellanoxv1alpha1.DevicePluginConfig{
+			newConfig("rdma", 0, "rdma_pool")})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("DevicePluginConfig missing referenced by sriovDevicePlugin doesn't exist"))
+		Expect(err.Error()).To(ContainSubstring("DevicePluginConfig rdma referenced by rdmaSharedDevicePlugin has no config"))
+		Expect(statuses["rdma"].State).To(BeEquivalentTo(mellanoxv1alpha1.StateError))
+		Expect(cr.Spec.RdmaSharedDevicePlugin.Config).To(BeEmpty())
+		Expect(cr.Spec.SriovDevicePlugin.Config).To(BeEmpty())
+	})
+})
@@ -0,0 +1,67 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"github.com/pkg/errors"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+)
+
+// devicePluginRolloutHeld returns true if the device plugin DaemonSet doesn't exist yet and the OFED driver is ready
+// on fewer nodes with Mellanox NICs than the minimum, a percentage minimum is rounded up.
+// The rollout is never held once the DaemonSet is created, e.g. while drivers are restarted by the upgrade
+func (s *stateSkel) devicePluginRolloutHeld(objs []*unstructured.Unstructured, minDriverReadyNodes *intstr.IntOrString,
+	nodeInfo nodeinfo.Provider) (bool, error) {
+	if minDriverReadyNodes == nil {
+		return false, nil
+	}
+	for _, obj := range objs {
+		if obj.GetKind() != "DaemonSet" {
+			continue
+		}
+		err := s.getObj(obj.DeepCopy())
+		if err == nil {
+			return false, nil
+		}
+		if !k8serrors.IsNotFound(err) {
+			return false, errors.Wrapf(err, "failed to get DaemonSet %s", obj.GetName())
+		}
+	}
+
+	total := len(nodeInfo.GetNodesAttributes(
+		nodeinfo.NewNodeLabelFilterBuilder().WithLabel(nodeinfo.NodeLabelMlnxNIC, "true").Build()))
+	ready := len(nodeInfo.GetNodesAttributes(
+		nodeinfo.NewNodeLabelFilterBuilder().
+			WithLabel(nodeinfo.NodeLabelMlnxNIC, "true").
+			WithLabel(nodeinfo.NodeLabelWaitOFED, "false").
+			Build()))
+	minimum, err := intstr.GetScaledValueFromIntOrPercent(minDriverReadyNodes, total, true)
+	if err != nil {
+		return false, errors.Wrap(err, "invalid minimum number of driver ready nodes")
+	}
+	if ready >= minimum {
+		return false, nil
+	}
+	log.V(consts.LogLevelInfo).Info("Device plugin rollout is held until more nodes have a ready OFED driver",
+		"State:", s.name, "readyNodes", ready, "minimum", minimum, "nodes", total)
+	return true, nil
+}
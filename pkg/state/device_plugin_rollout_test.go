@@ -0,0 +1,89 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+)
+
+var _ = Describe("Device plugin rollout tests", func() {
+	var (
+		s         *stateSkel
+		objs      []*unstructured.Unstructured
+		nodeInfo  nodeinfo.Provider
+		threshold intstr.IntOrString
+	)
+
+	BeforeEach(func() {
+		s = &stateSkel{name: "state-device-plugin",
+			client: fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()}
+		ds := &unstructured.Unstructured{}
+		ds.SetAPIVersion("apps/v1")
+		ds.SetKind("DaemonSet")
+		ds.SetNamespace("nvidia-network-operator")
+		ds.SetName("device-plugin")
+		objs = []*unstructured.Unstructured{ds}
+
+		var nodes []*corev1.Node
+		for i, wait := range []string{"false", "false", "false", "true", "true"} {
+			nodes = append(nodes, &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+				Name:   string(rune('a' + i)),
+				Labels: map[string]string{nodeinfo.NodeLabelMlnxNIC: "true", nodeinfo.NodeLabelWaitOFED: wait},
+			}})
+		}
+		nodeInfo = nodeinfo.NewProvider(nodes)
+	})
+
+	It("Should not hold the rollout without a minimum", func() {
+		Expect(s.devicePluginRolloutHeld(objs, nil, nodeInfo)).To(BeFalse())
+	})
+
+	It("Should hold the rollout until the minimum of driver ready nodes is reached", func() {
+		threshold = intstr.FromString("80%")
+		Expect(s.devicePluginRolloutHeld(objs, &threshold, nodeInfo)).To(BeTrue())
+		threshold = intstr.FromString("60%")
+		Expect(s.devicePluginRolloutHeld(objs, &threshold, nodeInfo)).To(BeFalse())
+		threshold = intstr.FromInt(4)
+		Expect(s.devicePluginRolloutHeld(objs, &threshold, nodeInfo)).To(BeTrue())
+		threshold = intstr.FromInt(3)
+		Expect(s.devicePluginRolloutHeld(objs, &threshold, nodeInfo)).To(BeFalse())
+	})
+
+	It("Should not hold the rollout once the DaemonSet exists", func() {
+		s.client = fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(&appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "nvidia-network-operator", Name: "device-plugin"}}).Build()
+		threshold = intstr.FromString("100%")
+		Expect(s.devicePluginRolloutHeld(objs, &threshold, nodeInfo)).To(BeFalse())
+	})
+
+	It("Should reject an invalid minimum", func() {
+		threshold = intstr.FromString("most")
+		_, err := s.devicePluginRolloutHeld(objs, &threshold, nodeInfo)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,74 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/Mellanox/network-operator/pkg/config"
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+)
+
+// driverReadyNodeAffinity returns the node affinity of a device plugin, the policy node affinity is extended with
+// the requirement of the ready OFED driver on the node unless it is disabled in the device plugin spec.
+// The requirement is added to every node selector term, as the terms are ORed
+func driverReadyNodeAffinity(affinity *v1.NodeAffinity, driverReadyAffinity *bool) *v1.NodeAffinity {
+	if driverReadyAffinity != nil && !*driverReadyAffinity {
+		return affinity
+	}
+	return withRequiredNodeSelectorRequirement(affinity, v1.NodeSelectorRequirement{
+		Key:      nodeinfo.NodeLabelWaitOFED,
+		Operator: v1.NodeSelectorOpIn,
+		Values:   []string{"false"},
+	})
+}
+
+// excludedNodeAffinity returns the node affinity of a driver or device plugin DaemonSet, the policy node affinity
+// is extended with the requirement that the node is not excluded from the management of the operator
+func excludedNodeAffinity(affinity *v1.NodeAffinity) *v1.NodeAffinity {
+	label := config.FromEnv().State.ExcludedNodeLabel
+	if label == "" {
+		return affinity
+	}
+	return withRequiredNodeSelectorRequirement(affinity, v1.NodeSelectorRequirement{
+		Key:      label,
+		Operator: v1.NodeSelectorOpNotIn,
+		Values:   []string{"true"},
+	})
+}
+
+// withRequiredNodeSelectorRequirement returns a copy of the node affinity with the requirement added to every
+// required node selector term, as the terms are ORed
+func withRequiredNodeSelectorRequirement(
+	affinity *v1.NodeAffinity, requirement v1.NodeSelectorRequirement) *v1.NodeAffinity {
+	result := &v1.NodeAffinity{}
+	if affinity != nil {
+		result = affinity.DeepCopy()
+	}
+	if result.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		result.RequiredDuringSchedulingIgnoredDuringExecution = &v1.NodeSelector{}
+	}
+	required := result.RequiredDuringSchedulingIgnoredDuringExecution
+	if len(required.NodeSelectorTerms) == 0 {
+		required.NodeSelectorTerms = []v1.NodeSelectorTerm{{}}
+	}
+	for i := range required.NodeSelectorTerms {
+		required.NodeSelectorTerms[i].MatchExpressions = append(required.NodeSelectorTerms[i].MatchExpressions,
+			requirement)
+	}
+	return result
+}
@@ -31,10 +31,13 @@ import (
 
 // NewStateManager creates a state.Manager for the given CRD Kind
 func NewManager(crdKind string, k8sAPIClient client.Client, scheme *runtime.Scheme) (Manager, error) {
-	stateGroups, err := newStates(crdKind, k8sAPIClient, scheme)
+	stateGroups, dependencies, activeSelectors, specSelectors, err := newStates(crdKind, k8sAPIClient, scheme)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to create state manager")
 	}
+	if err := validateDependencies(stateGroups, dependencies); err != nil {
+		return nil, errors.Wrapf(err, "failed to create state manager")
+	}
 
 	if log.V(consts.LogLevelDebug).Enabled() {
 		stateNames := make([][]string, len(stateGroups))
@@ -47,85 +50,229 @@ func NewManager(crdKind string, k8sAPIClient client.Client, scheme *runtime.Sche
 	}
 
 	return &stateManager{
-		stateGroups: stateGroups,
-		client:      k8sAPIClient,
+		stateGroups:     stateGroups,
+		dependencies:    dependencies,
+		activeSelectors: activeSelectors,
+		specSelectors:   specSelectors,
+		client:          k8sAPIClient,
+		concurrency:     config.FromEnv().State.SyncConcurrency,
 	}, nil
 }
 
-// newStates creates States that compose a State manager
-func newStates(crdKind string, k8sAPIClient client.Client, scheme *runtime.Scheme) ([]Group, error) {
+// validateDependencies checks that every dependency of a state refers to a state from an earlier state group,
+// this guarantees that dependencies are synced before their dependents
+func validateDependencies(stateGroups []Group, dependencies Dependencies) error {
+	groupIndex := make(map[string]int)
+	for i := range stateGroups {
+		for _, state := range stateGroups[i].States() {
+			groupIndex[state.Name()] = i
+		}
+	}
+	for stateName, deps := range dependencies {
+		stateIdx, ok := groupIndex[stateName]
+		if !ok {
+			return fmt.Errorf("dependencies defined for unknown state %s", stateName)
+		}
+		for _, dep := range deps {
+			depIdx, ok := groupIndex[dep]
+			if !ok {
+				return fmt.Errorf("state %s depends on unknown state %s", stateName, dep)
+			}
+			if depIdx >= stateIdx {
+				return fmt.Errorf("state %s depends on state %s which is not in an earlier state group", stateName, dep)
+			}
+		}
+	}
+	return nil
+}
+
+// newStates creates States that compose a State manager, dependencies between them, whether the states are active
+// and the parts of the custom resource each state is rendered from
+func newStates(crdKind string, k8sAPIClient client.Client, scheme *runtime.Scheme) (
+	[]Group, Dependencies, ActiveSelectors, SpecSelectors, error) {
 	switch crdKind {
 	case mellanoxv1alpha1.NicClusterPolicyCRDName:
 		return newNicClusterPolicyStates(k8sAPIClient, scheme)
 	case mellanoxv1alpha1.MacvlanNetworkCRDName:
-		return newMacvlanNetworkStates(k8sAPIClient, scheme)
+		groups, err := newMacvlanNetworkStates(k8sAPIClient, scheme)
+		return groups, nil, nil, nil, err
 	case mellanoxv1alpha1.HostDeviceNetworkCRDName:
-		return newHostDeviceNetworkStates(k8sAPIClient, scheme)
+		groups, err := newHostDeviceNetworkStates(k8sAPIClient, scheme)
+		return groups, nil, nil, nil, err
 	case mellanoxv1alpha1.IPoIBNetworkCRDName:
-		return newIPoIBNetworkStates(k8sAPIClient, scheme)
+		groups, err := newIPoIBNetworkStates(k8sAPIClient, scheme)
+		return groups, nil, nil, nil, err
 	default:
 		break
 	}
-	return nil, fmt.Errorf("unsupported CRD for states factory: %s", crdKind)
+	return nil, nil, nil, nil, fmt.Errorf("unsupported CRD for states factory: %s", crdKind)
+}
+
+// nicClusterPolicySpec returns a spec selector of the NicClusterPolicy fields a state is rendered from
+func nicClusterPolicySpec(
+	fields func(spec *mellanoxv1alpha1.NicClusterPolicySpec) []interface{}) func(customResource interface{}) interface{} {
+	return func(customResource interface{}) interface{} {
+		cr, ok := customResource.(*mellanoxv1alpha1.NicClusterPolicy)
+		if !ok {
+			return nil
+		}
+		return fields(&cr.Spec)
+	}
+}
+
+// nicClusterPolicyComponent returns an active selector of the NicClusterPolicy component a state deploys
+func nicClusterPolicyComponent(
+	active func(spec *mellanoxv1alpha1.NicClusterPolicySpec) bool) func(customResource interface{}) bool {
+	return func(customResource interface{}) bool {
+		cr, ok := customResource.(*mellanoxv1alpha1.NicClusterPolicy)
+		if !ok {
+			return true
+		}
+		return active(&cr.Spec)
+	}
 }
 
 // newNicClusterPolicyStates creates states that reconcile NicClusterPolicy CRD
-func newNicClusterPolicyStates(k8sAPIClient client.Client, scheme *runtime.Scheme) ([]Group, error) {
+func newNicClusterPolicyStates(k8sAPIClient client.Client, scheme *runtime.Scheme) (
+	[]Group, Dependencies, ActiveSelectors, SpecSelectors, error) {
 	manifestBaseDir := config.FromEnv().State.ManifestBaseDir
 	ofedState, err := NewStateOFED(
 		k8sAPIClient, scheme, filepath.Join(manifestBaseDir, "stage-ofed-driver"))
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to create OFED driver State")
+		return nil, nil, nil, nil, errors.Wrapf(err, "failed to create OFED driver State")
 	}
 
 	sharedDpState, err := NewStateSharedDp(
 		k8sAPIClient, scheme, filepath.Join(manifestBaseDir, "stage-rdma-device-plugin"))
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to create Shared Device plugin State")
+		return nil, nil, nil, nil, errors.Wrapf(err, "failed to create Shared Device plugin State")
 	}
 	sriovDpState, err := NewStateSriovDp(
 		k8sAPIClient, scheme, filepath.Join(manifestBaseDir, "stage-sriov-device-plugin"))
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to create SR-IOV Device plugin State")
+		return nil, nil, nil, nil, errors.Wrapf(err, "failed to create SR-IOV Device plugin State")
 	}
 	nvPeerMemState, err := NewStateNVPeer(
 		k8sAPIClient, scheme, filepath.Join(manifestBaseDir, "stage-nv-peer-mem-driver"))
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to create NV peer memory driver State")
+		return nil, nil, nil, nil, errors.Wrapf(err, "failed to create NV peer memory driver State")
 	}
 	multusState, err := NewStateMultusCNI(
 		k8sAPIClient, scheme, filepath.Join(manifestBaseDir, "stage-multus-cni"))
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to create Multus CNI State")
+		return nil, nil, nil, nil, errors.Wrapf(err, "failed to create Multus CNI State")
 	}
 	cniPluginsState, err := NewStateCNIPlugins(
 		k8sAPIClient, scheme, filepath.Join(manifestBaseDir, "stage-container-networking-plugins"))
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to create Container Networking CNI Plugins State")
+		return nil, nil, nil, nil, errors.Wrapf(err, "failed to create Container Networking CNI Plugins State")
 	}
 	ipoibState, err := NewStateIPoIBCNI(
 		k8sAPIClient, scheme, filepath.Join(manifestBaseDir, "stage-ipoib-cni"))
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to create Container Networking CNI Plugins State")
+		return nil, nil, nil, nil, errors.Wrapf(err, "failed to create Container Networking CNI Plugins State")
 	}
 	whereaboutState, err := NewStateWhereaboutsCNI(
 		k8sAPIClient, scheme, filepath.Join(manifestBaseDir, "stage-whereabouts-cni"))
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to create Whereabouts CNI State")
+		return nil, nil, nil, nil, errors.Wrapf(err, "failed to create Whereabouts CNI State")
+	}
+	docaTelemetryState, err := NewStateDOCATelemetry(
+		k8sAPIClient, scheme, filepath.Join(manifestBaseDir, "stage-doca-telemetry"))
+	if err != nil {
+		return nil, nil, nil, nil, errors.Wrapf(err, "failed to create DOCA Telemetry Service State")
 	}
 	podSecurityPolicyState, err := NewStatePodSecurityPolicy(
 		k8sAPIClient, scheme, filepath.Join(manifestBaseDir, "stage-pod-security-policy"))
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to create Pod Security Policy State")
+		return nil, nil, nil, nil, errors.Wrapf(err, "failed to create Pod Security Policy State")
 	}
 
+	// all pods require Pod Security Policy to be applied first,
+	// device plugins, NV peer memory driver and DOCA Telemetry Service require OFED driver to be ready
+	dependencies := Dependencies{
+		multusState.Name():        {podSecurityPolicyState.Name()},
+		cniPluginsState.Name():    {podSecurityPolicyState.Name()},
+		ipoibState.Name():         {podSecurityPolicyState.Name()},
+		whereaboutState.Name():    {podSecurityPolicyState.Name()},
+		ofedState.Name():          {podSecurityPolicyState.Name()},
+		sriovDpState.Name():       {podSecurityPolicyState.Name(), ofedState.Name()},
+		sharedDpState.Name():      {podSecurityPolicyState.Name(), ofedState.Name()},
+		nvPeerMemState.Name():     {podSecurityPolicyState.Name(), ofedState.Name()},
+		docaTelemetryState.Name(): {podSecurityPolicyState.Name(), ofedState.Name()},
+	}
+
+	// dependencies are waited for only by the states which deploy a configured and enabled component
+	activeSelectors := ActiveSelectors{
+		multusState.Name(): nicClusterPolicyComponent(func(spec *mellanoxv1alpha1.NicClusterPolicySpec) bool {
+			return spec.SecondaryNetwork != nil && spec.SecondaryNetwork.Multus != nil &&
+				spec.SecondaryNetwork.Multus.IsEnabled()
+		}),
+		cniPluginsState.Name(): nicClusterPolicyComponent(func(spec *mellanoxv1alpha1.NicClusterPolicySpec) bool {
+			return spec.SecondaryNetwork != nil && spec.SecondaryNetwork.CniPlugins != nil &&
+				spec.SecondaryNetwork.CniPlugins.IsEnabled()
+		}),
+		ipoibState.Name(): nicClusterPolicyComponent(func(spec *mellanoxv1alpha1.NicClusterPolicySpec) bool {
+			return spec.SecondaryNetwork != nil && spec.SecondaryNetwork.IPoIB != nil &&
+				spec.SecondaryNetwork.IPoIB.IsEnabled()
+		}),
+		whereaboutState.Name(): nicClusterPolicyComponent(func(spec *mellanoxv1alpha1.NicClusterPolicySpec) bool {
+			return spec.SecondaryNetwork != nil && spec.SecondaryNetwork.IpamPlugin != nil &&
+				spec.SecondaryNetwork.IpamPlugin.IsEnabled()
+		}),
+		ofedState.Name(): nicClusterPolicyComponent(func(spec *mellanoxv1alpha1.NicClusterPolicySpec) bool {
+			return spec.OFEDDriver != nil && spec.OFEDDriver.IsEnabled()
+		}),
+		sriovDpState.Name(): nicClusterPolicyComponent(func(spec *mellanoxv1alpha1.NicClusterPolicySpec) bool {
+			return spec.SriovDevicePlugin != nil && spec.SriovDevicePlugin.IsEnabled()
+		}),
+		sharedDpState.Name(): nicClusterPolicyComponent(func(spec *mellanoxv1alpha1.NicClusterPolicySpec) bool {
+			return spec.RdmaSharedDevicePlugin != nil && spec.RdmaSharedDevicePlugin.IsEnabled()
+		}),
+		nvPeerMemState.Name(): nicClusterPolicyComponent(func(spec *mellanoxv1alpha1.NicClusterPolicySpec) bool {
+			return spec.NVPeerDriver != nil && spec.NVPeerDriver.IsEnabled()
+		}),
+		docaTelemetryState.Name(): nicClusterPolicyComponent(func(spec *mellanoxv1alpha1.NicClusterPolicySpec) bool {
+			return spec.DOCATelemetry != nil && spec.DOCATelemetry.IsEnabled()
+		}),
+	}
+
+	// all pods are rendered with the node affinity, device plugins and NV peer memory driver
+	// also depend on the OFED driver spec
+	secondaryNetworkSpec := nicClusterPolicySpec(func(spec *mellanoxv1alpha1.NicClusterPolicySpec) []interface{} {
+		return []interface{}{spec.NodeAffinity, spec.SecondaryNetwork}
+	})
+	specSelectors := SpecSelectors{
+		podSecurityPolicyState.Name(): nicClusterPolicySpec(func(spec *mellanoxv1alpha1.NicClusterPolicySpec) []interface{} {
+			return []interface{}{spec.PSP}
+		}),
+		multusState.Name():     secondaryNetworkSpec,
+		cniPluginsState.Name(): secondaryNetworkSpec,
+		ipoibState.Name():      secondaryNetworkSpec,
+		whereaboutState.Name(): secondaryNetworkSpec,
+		ofedState.Name(): nicClusterPolicySpec(func(spec *mellanoxv1alpha1.NicClusterPolicySpec) []interface{} {
+			return []interface{}{spec.NodeAffinity, spec.OFEDDriver}
+		}),
+		sriovDpState.Name(): nicClusterPolicySpec(func(spec *mellanoxv1alpha1.NicClusterPolicySpec) []interface{} {
+			return []interface{}{spec.NodeAffinity, spec.OFEDDriver, spec.SriovDevicePlugin}
+		}),
+		sharedDpState.Name(): nicClusterPolicySpec(func(spec *mellanoxv1alpha1.NicClusterPolicySpec) []interface{} {
+			return []interface{}{spec.NodeAffinity, spec.OFEDDriver, spec.RdmaSharedDevicePlugin}
+		}),
+		nvPeerMemState.Name(): nicClusterPolicySpec(func(spec *mellanoxv1alpha1.NicClusterPolicySpec) []interface{} {
+			return []interface{}{spec.NodeAffinity, spec.OFEDDriver, spec.NVPeerDriver}
+		}),
+		docaTelemetryState.Name(): nicClusterPolicySpec(func(spec *mellanoxv1alpha1.NicClusterPolicySpec) []interface{} {
+			return []interface{}{spec.NodeAffinity, spec.DOCATelemetry}
+		}),
+	}
+
+	// states without dependencies between them share a group, so that they can be synced in parallel
 	return []Group{
 		NewStateGroup([]State{podSecurityPolicyState}),
-		NewStateGroup([]State{multusState, cniPluginsState, ipoibState, whereaboutState}),
-		NewStateGroup([]State{ofedState}),
-		NewStateGroup([]State{sriovDpState}),
-		NewStateGroup([]State{sharedDpState, nvPeerMemState}),
-	}, nil
+		NewStateGroup([]State{multusState, cniPluginsState, ipoibState, whereaboutState, ofedState}),
+		NewStateGroup([]State{sriovDpState, sharedDpState, nvPeerMemState, docaTelemetryState}),
+	}, dependencies, activeSelectors, specSelectors, nil
 }
 
 // newMacvlanNetworkStates creates states that reconcile MacvlanNetwork CRD
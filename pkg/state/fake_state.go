@@ -24,6 +24,7 @@ type fakeState struct {
 	name, description string
 	watchResources    map[string]*source.Kind
 	syncState         SyncState
+	syncCount         int
 }
 
 // Name provides the State name
@@ -39,6 +40,7 @@ func (s *fakeState) Description() string {
 // Sync attempt to get the system to match the desired state which State represent.
 // a sync operation must be relatively short and must not block the execution thread.
 func (s *fakeState) Sync(customResource interface{}, infoCatalog InfoCatalog) (SyncState, error) {
+	s.syncCount++
 	return s.syncState, nil
 }
 
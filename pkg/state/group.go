@@ -17,41 +17,91 @@ limitations under the License.
 package state
 
 import (
+	"sync"
+
 	"github.com/Mellanox/network-operator/pkg/consts"
 )
 
 // Group Represents a set of disjoint States that are Synced (Reconciled) together
 type Group struct {
 	states  []State
-	results map[*State]Result
+	results []Result
 }
 
 // NewStateGroup returns a new group of states
 func NewStateGroup(states []State) Group {
 	return Group{
-		states:  states,
-		results: make(map[*State]Result),
+		states: states,
 	}
 }
 
 // SyncGroup sync and update status for a list of states
-func (sg *Group) Sync(customResource interface{}, infoCatalog InfoCatalog) (results []Result) {
+// concurrency is the maximum number of states synced in parallel, the states are synced one by one if it is below 2
+// blockedBy returns names of the not ready dependencies of a state which creates or updates its objects,
+// states with not ready dependencies are not synced and reported as not ready
+// unchanged returns the last result of a state if its part of the custom resource didn't change,
+// such states are not synced and reported with the last result
+func (sg *Group) Sync(customResource interface{}, infoCatalog InfoCatalog, concurrency int,
+	blockedBy func(stateName string) []string, unchanged func(stateName string) (Result, bool)) (results []Result) {
 	// sync and update status for the list of states
+	sg.results = make([]Result, len(sg.states))
+	var toSync []int
 	for i := range sg.states {
-		log.V(consts.LogLevelInfo).Info(
-			"Sync State", "Name:", sg.states[i].Name(), "Description:", sg.states[i].Description())
-		status, err := sg.states[i].Sync(customResource, infoCatalog)
-		sg.results[&sg.states[i]] = Result{
-			StateName: sg.states[i].Name(),
-			Status:    status,
-			ErrInfo:   err,
+		if blocked := blockedBy(sg.states[i].Name()); len(blocked) > 0 {
+			log.V(consts.LogLevelInfo).Info(
+				"State is blocked waiting on dependencies", "Name:", sg.states[i].Name(), "Dependencies:", blocked)
+			sg.results[i] = Result{
+				StateName: sg.states[i].Name(),
+				Status:    SyncStateNotReady,
+				BlockedBy: blocked,
+			}
+			continue
+		}
+		if result, ok := unchanged(sg.states[i].Name()); ok {
+			log.V(consts.LogLevelInfo).Info(
+				"State spec didn't change, skipping sync", "Name:", sg.states[i].Name(), "Status:", result.Status)
+			sg.results[i] = result
+			continue
+		}
+		toSync = append(toSync, i)
+	}
+
+	if concurrency < 2 {
+		for _, i := range toSync {
+			sg.results[i] = sg.syncState(i, customResource, infoCatalog)
+		}
+	} else {
+		// each state writes only its own result, the states of a group are disjoint
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, concurrency)
+		for _, i := range toSync {
+			wg.Add(1)
+			slots <- struct{}{}
+			go func(i int) {
+				defer wg.Done()
+				sg.results[i] = sg.syncState(i, customResource, infoCatalog)
+				<-slots
+			}(i)
 		}
+		wg.Wait()
 	}
 	results = sg.Results()
 	log.V(consts.LogLevelDebug).Info("syncGroup", "results:", results)
 	return results
 }
 
+// syncState syncs the state with the given index and returns its result
+func (sg *Group) syncState(i int, customResource interface{}, infoCatalog InfoCatalog) Result {
+	log.V(consts.LogLevelInfo).Info(
+		"Sync State", "Name:", sg.states[i].Name(), "Description:", sg.states[i].Description())
+	status, err := sg.states[i].Sync(customResource, infoCatalog)
+	return Result{
+		StateName: sg.states[i].Name(),
+		Status:    status,
+		ErrInfo:   err,
+	}
+}
+
 // GroupDone returns whether or not all states in the group are ready, error in second arg in case
 // one of the states returned with error
 func (sg *Group) SyncDone() (done bool, err error) {
@@ -67,12 +117,10 @@ func (sg *Group) SyncDone() (done bool, err error) {
 	return done, err
 }
 
-// Results return []Result of the last SyncGroup() invocation
+// Results return []Result of the last SyncGroup() invocation, results are ordered as the states in the group
 func (sg *Group) Results() []Result {
-	results := make([]Result, 0, len(sg.results))
-	for _, result := range sg.results {
-		results = append(results, result)
-	}
+	results := make([]Result, len(sg.results))
+	copy(results, sg.results)
 	return results
 }
 
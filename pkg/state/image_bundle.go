@@ -0,0 +1,96 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+)
+
+// Keys in the image bundle ConfigMap, each key holds an image reference
+// in the <repository>/<image>:<version> format
+const (
+	ImageBundleKeyOFEDDriver             = "ofedDriver"
+	ImageBundleKeyNVPeerDriver           = "nvPeerDriver"
+	ImageBundleKeyRdmaSharedDevicePlugin = "rdmaSharedDevicePlugin"
+	ImageBundleKeySriovDevicePlugin      = "sriovDevicePlugin"
+	ImageBundleKeyMultus                 = "multus"
+	ImageBundleKeyCniPlugins             = "cniPlugins"
+	ImageBundleKeyIPoIB                  = "ipoib"
+	ImageBundleKeyIpamPlugin             = "ipamPlugin"
+	ImageBundleKeyDOCATelemetry          = "docaTelemetry"
+)
+
+// ApplyImageBundle overrides image specs of the components configured in the NicClusterPolicy
+// with image references from the image bundle. Components which are not configured in the
+// NicClusterPolicy are not enabled by the bundle.
+func ApplyImageBundle(cr *mellanoxv1alpha1.NicClusterPolicy, bundle map[string]string) error {
+	imageSpecs := map[string]*mellanoxv1alpha1.ImageSpec{}
+	if cr.Spec.OFEDDriver != nil {
+		imageSpecs[ImageBundleKeyOFEDDriver] = &cr.Spec.OFEDDriver.ImageSpec
+	}
+	if cr.Spec.NVPeerDriver != nil {
+		imageSpecs[ImageBundleKeyNVPeerDriver] = &cr.Spec.NVPeerDriver.ImageSpec
+	}
+	if cr.Spec.RdmaSharedDevicePlugin != nil {
+		imageSpecs[ImageBundleKeyRdmaSharedDevicePlugin] = &cr.Spec.RdmaSharedDevicePlugin.ImageSpec
+	}
+	if cr.Spec.SriovDevicePlugin != nil {
+		imageSpecs[ImageBundleKeySriovDevicePlugin] = &cr.Spec.SriovDevicePlugin.ImageSpec
+	}
+	if cr.Spec.DOCATelemetry != nil {
+		imageSpecs[ImageBundleKeyDOCATelemetry] = &cr.Spec.DOCATelemetry.ImageSpec
+	}
+	if cr.Spec.SecondaryNetwork != nil {
+		if cr.Spec.SecondaryNetwork.Multus != nil {
+			imageSpecs[ImageBundleKeyMultus] = &cr.Spec.SecondaryNetwork.Multus.ImageSpec
+		}
+		imageSpecs[ImageBundleKeyCniPlugins] = cr.Spec.SecondaryNetwork.CniPlugins
+		imageSpecs[ImageBundleKeyIPoIB] = cr.Spec.SecondaryNetwork.IPoIB
+		imageSpecs[ImageBundleKeyIpamPlugin] = cr.Spec.SecondaryNetwork.IpamPlugin
+	}
+
+	for key, spec := range imageSpecs {
+		ref, ok := bundle[key]
+		if !ok || spec == nil {
+			continue
+		}
+		repository, image, version, err := parseImageRef(ref)
+		if err != nil {
+			return errors.Wrapf(err, "invalid image reference for %s in image bundle", key)
+		}
+		spec.Repository = repository
+		spec.Image = image
+		spec.Version = version
+	}
+	return nil
+}
+
+// parseImageRef splits an image reference in the <repository>/<image>:<version> format
+func parseImageRef(ref string) (repository, image, version string, err error) {
+	ref = strings.TrimSpace(ref)
+	slashIdx := strings.LastIndex(ref, "/")
+	colonIdx := strings.LastIndex(ref, ":")
+	if slashIdx <= 0 || colonIdx < slashIdx+2 || colonIdx == len(ref)-1 {
+		return "", "", "", fmt.Errorf("%q is not in the <repository>/<image>:<version> format", ref)
+	}
+	return ref[:slashIdx], ref[slashIdx+1 : colonIdx], ref[colonIdx+1:], nil
+}
@@ -0,0 +1,72 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/state"
+)
+
+var _ = Describe("Image bundle tests", func() {
+	var cr *mellanoxv1alpha1.NicClusterPolicy
+
+	BeforeEach(func() {
+		cr = &mellanoxv1alpha1.NicClusterPolicy{
+			Spec: mellanoxv1alpha1.NicClusterPolicySpec{
+				OFEDDriver: &mellanoxv1alpha1.OFEDDriverSpec{
+					ImageSpec: mellanoxv1alpha1.ImageSpec{Image: "mofed", Repository: "mellanox", Version: "5.6-1.0.3.3"},
+				},
+				SecondaryNetwork: &mellanoxv1alpha1.SecondaryNetworkSpec{
+					Multus: &mellanoxv1alpha1.MultusSpec{
+						ImageSpec: mellanoxv1alpha1.ImageSpec{Image: "multus-cni", Repository: "ghcr.io", Version: "v3.8"},
+					},
+				},
+			},
+		}
+	})
+
+	It("Should override images of configured components", func() {
+		bundle := map[string]string{
+			state.ImageBundleKeyOFEDDriver: "mirror.local:5000/nvidia/mellanox/mofed:5.7-0.1.2.0",
+			state.ImageBundleKeyMultus:     "mirror.local:5000/multus-cni:v3.9",
+		}
+		Expect(state.ApplyImageBundle(cr, bundle)).To(Succeed())
+		Expect(cr.Spec.OFEDDriver.Repository).To(Equal("mirror.local:5000/nvidia/mellanox"))
+		Expect(cr.Spec.OFEDDriver.Image).To(Equal("mofed"))
+		Expect(cr.Spec.OFEDDriver.Version).To(Equal("5.7-0.1.2.0"))
+		Expect(cr.Spec.SecondaryNetwork.Multus.Repository).To(Equal("mirror.local:5000"))
+		Expect(cr.Spec.SecondaryNetwork.Multus.Version).To(Equal("v3.9"))
+	})
+	It("Should not enable components which are not configured", func() {
+		bundle := map[string]string{
+			state.ImageBundleKeyNVPeerDriver: "mirror.local/mellanox/nv-peer-mem-driver:1.1-0",
+			state.ImageBundleKeyIpamPlugin:   "mirror.local/whereabouts:v0.5.2-amd64",
+		}
+		Expect(state.ApplyImageBundle(cr, bundle)).To(Succeed())
+		Expect(cr.Spec.NVPeerDriver).To(BeNil())
+		Expect(cr.Spec.SecondaryNetwork.IpamPlugin).To(BeNil())
+	})
+	It("Should fail on malformed image reference", func() {
+		for _, ref := range []string{"mofed:5.6", "mirror.local/mofed", "mirror.local:5000/mofed", "mirror.local/mofed:"} {
+			bundle := map[string]string{state.ImageBundleKeyOFEDDriver: ref}
+			Expect(state.ApplyImageBundle(cr, bundle)).NotTo(Succeed(), ref)
+		}
+	})
+})
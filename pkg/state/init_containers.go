@@ -0,0 +1,78 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// addInitContainers appends the additional init containers to the pod templates of the rendered DaemonSets,
+// after the init containers rendered by the operator, so that they run right before the main containers.
+// An additional init container must not have the name of another container of the pod.
+func addInitContainers(objs []*unstructured.Unstructured, containers []v1.Container) error {
+	if len(containers) == 0 {
+		return nil
+	}
+	additional := make([]interface{}, 0, len(containers))
+	for i := range containers {
+		container, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&containers[i])
+		if err != nil {
+			return errors.Wrapf(err, "failed to convert additional init container %s", containers[i].Name)
+		}
+		additional = append(additional, container)
+	}
+
+	for _, obj := range objs {
+		if obj.GetKind() != "DaemonSet" {
+			continue
+		}
+		initContainers, _, err := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", "initContainers")
+		if err != nil {
+			return errors.Wrapf(err, "failed to get init containers of DaemonSet %s", obj.GetName())
+		}
+		mainContainers, _, err := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", "containers")
+		if err != nil {
+			return errors.Wrapf(err, "failed to get containers of DaemonSet %s", obj.GetName())
+		}
+		names := make(map[string]bool, len(initContainers)+len(mainContainers))
+		for _, container := range append(append([]interface{}(nil), initContainers...), mainContainers...) {
+			if name, ok := container.(map[string]interface{})["name"].(string); ok {
+				names[name] = true
+			}
+		}
+		for i := range containers {
+			name := containers[i].Name
+			if name == "" {
+				return errors.Errorf("name of additional init container of DaemonSet %s must be set", obj.GetName())
+			}
+			if names[name] {
+				return errors.Errorf("additional init container name %q collides with another container "+
+					"of DaemonSet %s", name, obj.GetName())
+			}
+			names[name] = true
+		}
+		initContainers = append(initContainers, runtime.DeepCopyJSONValue(additional).([]interface{})...)
+		err = unstructured.SetNestedSlice(obj.Object, initContainers, "spec", "template", "spec", "initContainers")
+		if err != nil {
+			return errors.Wrapf(err, "failed to set init containers of DaemonSet %s", obj.GetName())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/Mellanox/network-operator/pkg/config"
+	"github.com/Mellanox/network-operator/pkg/consts"
+)
+
+// ManagedLabels returns the labels which are set on all objects created by the operator
+func ManagedLabels() map[string]string {
+	return map[string]string{
+		consts.ManagedByLabel: consts.ManagedByLabelValue,
+		consts.InstanceLabel:  config.FromEnv().State.InstanceLabelValue,
+	}
+}
+
+// MergeManagedLabels adds the managed labels to the given labels, the labels map is allocated if nil
+func MergeManagedLabels(labels map[string]string) map[string]string {
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	for k, v := range ManagedLabels() {
+		labels[k] = v
+	}
+	return labels
+}
+
+// ManagedAnnotations returns the annotations which are set on all objects created by the operator,
+// configured with GENERATED_OBJECT_ANNOTATIONS environment variable
+func ManagedAnnotations() map[string]string {
+	annotations := make(map[string]string)
+	for _, entry := range config.FromEnv().State.GeneratedObjectAnnotations {
+		parts := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if parts[0] == "" {
+			continue
+		}
+		value := ""
+		if len(parts) == 2 {
+			value = parts[1]
+		}
+		annotations[parts[0]] = value
+	}
+	return annotations
+}
+
+// MergeManagedAnnotations adds the managed annotations to the given annotations,
+// the annotations map is allocated if nil
+func MergeManagedAnnotations(annotations map[string]string) map[string]string {
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	for k, v := range ManagedAnnotations() {
+		annotations[k] = v
+	}
+	return annotations
+}
+
+// setManagedLabels sets the managed labels and annotations on the object metadata,
+// labels and annotations of the pod templates are not changed to avoid restarting the pods
+func setManagedLabels(obj *unstructured.Unstructured) {
+	obj.SetLabels(MergeManagedLabels(obj.GetLabels()))
+	if annotations := MergeManagedAnnotations(obj.GetAnnotations()); len(annotations) != 0 {
+		obj.SetAnnotations(annotations)
+	}
+}
@@ -0,0 +1,82 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/Mellanox/network-operator/pkg/config"
+	"github.com/Mellanox/network-operator/pkg/consts"
+)
+
+var _ = Describe("Managed labels tests", func() {
+	It("Should set managed labels on the object and keep its own labels", func() {
+		ds := newTestDaemonSet("app", map[string]string{"app": "test"})
+		setManagedLabels(ds)
+
+		Expect(ds.GetLabels()).To(Equal(map[string]string{
+			"app":                 "test",
+			consts.ManagedByLabel: consts.ManagedByLabelValue,
+			consts.InstanceLabel:  config.FromEnv().State.InstanceLabelValue,
+		}))
+		templateLabels, _, _ := unstructured.NestedStringMap(ds.Object, "spec", "template", "metadata", "labels")
+		Expect(templateLabels).To(Equal(map[string]string{"app": "app"}))
+	})
+
+	It("Should allocate labels if not set", func() {
+		Expect(MergeManagedLabels(nil)).To(HaveKeyWithValue(consts.ManagedByLabel, consts.ManagedByLabelValue))
+	})
+
+	Context("Managed annotations", func() {
+		var savedAnnotations []string
+
+		BeforeEach(func() {
+			savedAnnotations = config.FromEnv().State.GeneratedObjectAnnotations
+		})
+
+		AfterEach(func() {
+			config.FromEnv().State.GeneratedObjectAnnotations = savedAnnotations
+		})
+
+		It("Should not set annotations if not configured", func() {
+			config.FromEnv().State.GeneratedObjectAnnotations = nil
+			ds := newTestDaemonSet("app", nil)
+			setManagedLabels(ds)
+			Expect(ds.GetAnnotations()).To(BeEmpty())
+		})
+
+		It("Should set configured annotations on the object and keep its own annotations", func() {
+			config.FromEnv().State.GeneratedObjectAnnotations = []string{
+				"argocd.argoproj.io/compare-options=IgnoreExtraneous", " example.com/generated", "=ignored"}
+			ds := newTestDaemonSet("app", nil)
+			ds.SetAnnotations(map[string]string{"own": "value"})
+			setManagedLabels(ds)
+
+			Expect(ds.GetAnnotations()).To(Equal(map[string]string{
+				"own":                                "value",
+				"argocd.argoproj.io/compare-options": "IgnoreExtraneous",
+				"example.com/generated":              "",
+			}))
+			templateAnnotations, _, _ := unstructured.NestedStringMap(
+				ds.Object, "spec", "template", "metadata", "annotations")
+			Expect(templateAnnotations).To(BeEmpty())
+		})
+	})
+})
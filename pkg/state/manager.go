@@ -17,6 +17,12 @@ limitations under the License.
 package state
 
 import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
@@ -40,8 +46,25 @@ type Result struct {
 	Status    SyncState
 	// if SyncStateError then ErrInfo will contain additional error information
 	ErrInfo error
+	// names of the not ready states this state depends on, the state is not synced until they are ready
+	BlockedBy []string
 }
 
+// Dependencies maps a state name to the names of the states it depends on.
+// A state is synced only after all of its dependencies are either ready, ignored or disabled.
+// Dependencies must belong to an earlier state group than the dependent state.
+// Dependencies gate only the creation and update of the state objects, see ActiveSelectors.
+type Dependencies map[string][]string
+
+// ActiveSelectors maps a state name to a function returning true if the component of the state is configured
+// and enabled in the custom resource. An inactive state is synced without waiting for its dependencies,
+// so that its component is ignored or its objects are removed. States without a selector are always active.
+type ActiveSelectors map[string]func(customResource interface{}) bool
+
+// SpecSelectors maps a state name to a function returning the parts of the custom resource the state is rendered from.
+// When the custom resource generation changes, a ready state is synced again only if its selected parts changed.
+type SpecSelectors map[string]func(customResource interface{}) interface{}
+
 // Represent the Results of a collection of State.Sync() invocations, Status reflects the global status of all states.
 // If all are SyncStateReady then Status is SyncStateReady, if one is SyncStateNotReady, Status is SyncStateNotReady
 type Results struct {
@@ -50,8 +73,24 @@ type Results struct {
 }
 
 type stateManager struct {
-	stateGroups []Group
-	client      client.Client
+	stateGroups     []Group
+	dependencies    Dependencies
+	activeSelectors ActiveSelectors
+	specSelectors   SpecSelectors
+	client          client.Client
+	// concurrency is the maximum number of states of a group synced in parallel
+	concurrency int
+	// lastSync holds the spec hashes and results of the last synced custom resource,
+	// it is only tracked if spec selectors are defined
+	lastSync *syncRecord
+}
+
+// syncRecord contains the spec hashes and results of the states synced for a custom resource generation
+type syncRecord struct {
+	uid        types.UID
+	generation int64
+	hashes     map[string]string
+	results    map[string]Result
 }
 
 func (smgr *stateManager) GetWatchSources() []*source.Kind {
@@ -86,15 +125,39 @@ func (smgr *stateManager) SyncState(customResource interface{}, infoCatalog Info
 		Status: SyncStateNotReady,
 	}
 	statesReady := true
+	// states which are either ready or ignored and don't block their dependents
+	satisfied := make(map[string]bool)
+	blockedBy := func(stateName string) []string {
+		if isActive, ok := smgr.activeSelectors[stateName]; ok && !isActive(customResource) {
+			return nil
+		}
+		var blocked []string
+		for _, dep := range smgr.dependencies[stateName] {
+			if !satisfied[dep] {
+				blocked = append(blocked, dep)
+			}
+		}
+		return blocked
+	}
 
-	for i, stateGroup := range smgr.stateGroups {
+	hashes := smgr.specHashes(customResource)
+	unchanged := smgr.unchangedStates(customResource, hashes)
+
+	for i := range smgr.stateGroups {
+		stateGroup := &smgr.stateGroups[i]
 		log.V(consts.LogLevelInfo).Info("Sync State group", "index", i)
-		results := stateGroup.Sync(customResource, infoCatalog)
+		results := stateGroup.Sync(customResource, infoCatalog, smgr.concurrency, blockedBy, unchanged)
 		managerResult.StatesStatus = append(managerResult.StatesStatus, results...)
+		for _, result := range results {
+			if result.Status == SyncStateReady || result.Status == SyncStateIgnore || result.Status == SyncStateDisabled {
+				satisfied[result.StateName] = true
+			}
+		}
 
 		done, err := stateGroup.SyncDone()
 		if err != nil {
 			log.V(consts.LogLevelError).Info("Error while syncing states", "Error:", err)
+			smgr.recordSync(customResource, hashes, managerResult.StatesStatus)
 			return managerResult, err
 		}
 
@@ -111,6 +174,65 @@ func (smgr *stateManager) SyncState(customResource interface{}, infoCatalog Info
 		managerResult.Status = SyncStateReady
 		log.V(consts.LogLevelInfo).Info("Sync Done for custom resource")
 	}
+	smgr.recordSync(customResource, hashes, managerResult.StatesStatus)
 
 	return managerResult, nil
 }
+
+// specHashes returns the hashes of the custom resource parts selected for the states by the spec selectors,
+// states with parts which can't be hashed are omitted
+func (smgr *stateManager) specHashes(customResource interface{}) map[string]string {
+	hashes := make(map[string]string, len(smgr.specSelectors))
+	for stateName, selector := range smgr.specSelectors {
+		data, err := json.Marshal(selector(customResource))
+		if err != nil {
+			log.V(consts.LogLevelWarning).Info("Failed to hash state spec", "Name:", stateName, "Error:", err)
+			continue
+		}
+		hashes[stateName] = fmt.Sprintf("%x", sha256.Sum256(data))
+	}
+	return hashes
+}
+
+// unchangedStates returns a function which reports the last result of the states which don't need to be synced.
+// States are skipped only if the custom resource generation changed since the last sync and the parts
+// of the custom resource selected for the state did not, all states are synced on other events
+// to reconcile changes of the deployed objects.
+func (smgr *stateManager) unchangedStates(
+	customResource interface{}, hashes map[string]string) func(stateName string) (Result, bool) {
+	obj, ok := customResource.(metav1.Object)
+	last := smgr.lastSync
+	if !ok || last == nil || last.uid != obj.GetUID() || last.generation == obj.GetGeneration() {
+		return func(string) (Result, bool) { return Result{}, false }
+	}
+	return func(stateName string) (Result, bool) {
+		hash, ok := hashes[stateName]
+		if !ok || last.hashes[stateName] != hash {
+			return Result{}, false
+		}
+		result, ok := last.results[stateName]
+		if !ok || (result.Status != SyncStateReady && result.Status != SyncStateIgnore &&
+			result.Status != SyncStateDisabled) {
+			return Result{}, false
+		}
+		return result, true
+	}
+}
+
+// recordSync stores the spec hashes and results of the synced states for the custom resource generation
+func (smgr *stateManager) recordSync(customResource interface{}, hashes map[string]string, results []Result) {
+	obj, ok := customResource.(metav1.Object)
+	if !ok || len(smgr.specSelectors) == 0 {
+		return
+	}
+	record := &syncRecord{
+		uid:        obj.GetUID(),
+		generation: obj.GetGeneration(),
+		hashes:     hashes,
+		results:    make(map[string]Result, len(results)),
+	}
+	for _, result := range results {
+		record.results[result.StateName] = result
+	}
+	smgr.lastSync = record
+}
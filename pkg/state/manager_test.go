@@ -17,12 +17,42 @@ limitations under the License.
 package state
 
 import (
+	"sync"
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
 	"github.com/Mellanox/network-operator/pkg/testing/mocks"
 )
 
+// concurrencyCounter tracks the maximum number of states synced at the same time
+type concurrencyCounter struct {
+	mutex        sync.Mutex
+	running, max int
+}
+
+// concurrentState is a fake state which takes some time to sync and records the concurrent syncs
+type concurrentState struct {
+	fakeState
+	counter *concurrencyCounter
+}
+
+func (s *concurrentState) Sync(customResource interface{}, infoCatalog InfoCatalog) (SyncState, error) {
+	s.counter.mutex.Lock()
+	s.counter.running++
+	if s.counter.running > s.counter.max {
+		s.counter.max = s.counter.running
+	}
+	s.counter.mutex.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	s.counter.mutex.Lock()
+	s.counter.running--
+	s.counter.mutex.Unlock()
+	return s.fakeState.Sync(customResource, infoCatalog)
+}
+
 var _ = Describe("Manager tests", func() {
 
 	Context("Sync states", func() {
@@ -73,5 +103,201 @@ var _ = Describe("Manager tests", func() {
 			Expect(results.StatesStatus[1].StateName).To(Equal("test ready"))
 			Expect(results.StatesStatus[1].Status).To(Equal(SyncState(SyncStateReady)))
 		})
+		It("Should block states with not ready dependencies", func() {
+			testStateNotReady := &fakeState{
+				name:        "driver",
+				description: "test description",
+				syncState:   SyncStateNotReady,
+			}
+			testStateIgnore := &fakeState{
+				name:        "psp",
+				description: "test description",
+				syncState:   SyncStateIgnore,
+			}
+			testStateDependent := &fakeState{
+				name:        "device plugin",
+				description: "test description",
+				syncState:   SyncStateReady,
+			}
+			testStateIndependent := &fakeState{
+				name:        "cni",
+				description: "test description",
+				syncState:   SyncStateReady,
+			}
+			stateGroups := []Group{
+				NewStateGroup([]State{testStateIgnore, testStateNotReady}),
+				NewStateGroup([]State{testStateDependent, testStateIndependent}),
+			}
+			dependencies := Dependencies{
+				"device plugin": {"psp", "driver"},
+				"cni":           {"psp"},
+			}
+			Expect(validateDependencies(stateGroups, dependencies)).To(Succeed())
+			client := mocks.ControllerRutimeClient{}
+			manager := &stateManager{
+				stateGroups:  stateGroups,
+				dependencies: dependencies,
+				client:       &client,
+			}
+			results, err := manager.SyncState(nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results.Status).To(Equal(SyncState(SyncStateNotReady)))
+			Expect(results.StatesStatus[2].StateName).To(Equal("device plugin"))
+			Expect(results.StatesStatus[2].Status).To(Equal(SyncState(SyncStateNotReady)))
+			Expect(results.StatesStatus[2].BlockedBy).To(Equal([]string{"driver"}))
+			Expect(results.StatesStatus[3].StateName).To(Equal("cni"))
+			Expect(results.StatesStatus[3].Status).To(Equal(SyncState(SyncStateReady)))
+			Expect(results.StatesStatus[3].BlockedBy).To(BeEmpty())
+		})
+		It("Should sync inactive states without waiting for dependencies", func() {
+			ofedState := &fakeState{name: "ofed", syncState: SyncStateNotReady}
+			sriovDpState := &fakeState{name: "sriov-dp", syncState: SyncStateReady}
+			sharedDpState := &fakeState{name: "shared-dp", syncState: SyncStateIgnore}
+			nvPeerState := &fakeState{name: "nv-peer", syncState: SyncStateDisabled}
+			stateGroups := []Group{
+				NewStateGroup([]State{ofedState}),
+				NewStateGroup([]State{sriovDpState, sharedDpState, nvPeerState}),
+			}
+			dependencies := Dependencies{
+				"sriov-dp":  {"ofed"},
+				"shared-dp": {"ofed"},
+				"nv-peer":   {"ofed"},
+			}
+			Expect(validateDependencies(stateGroups, dependencies)).To(Succeed())
+			cr := &mellanoxv1alpha1.NicClusterPolicy{}
+			cr.Spec.SriovDevicePlugin = &mellanoxv1alpha1.DevicePluginSpec{}
+			disabled := false
+			cr.Spec.NVPeerDriver = &mellanoxv1alpha1.NVPeerDriverSpec{}
+			cr.Spec.NVPeerDriver.Enabled = &disabled
+			client := mocks.ControllerRutimeClient{}
+			manager := &stateManager{
+				stateGroups:  stateGroups,
+				dependencies: dependencies,
+				activeSelectors: ActiveSelectors{
+					"sriov-dp": nicClusterPolicyComponent(func(spec *mellanoxv1alpha1.NicClusterPolicySpec) bool {
+						return spec.SriovDevicePlugin != nil && spec.SriovDevicePlugin.IsEnabled()
+					}),
+					"shared-dp": nicClusterPolicyComponent(func(spec *mellanoxv1alpha1.NicClusterPolicySpec) bool {
+						return spec.RdmaSharedDevicePlugin != nil && spec.RdmaSharedDevicePlugin.IsEnabled()
+					}),
+					"nv-peer": nicClusterPolicyComponent(func(spec *mellanoxv1alpha1.NicClusterPolicySpec) bool {
+						return spec.NVPeerDriver != nil && spec.NVPeerDriver.IsEnabled()
+					}),
+				},
+				client: &client,
+			}
+			results, err := manager.SyncState(cr, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results.Status).To(Equal(SyncState(SyncStateNotReady)))
+			// the configured device plugin waits for the driver
+			Expect(results.StatesStatus[1].StateName).To(Equal("sriov-dp"))
+			Expect(results.StatesStatus[1].Status).To(Equal(SyncState(SyncStateNotReady)))
+			Expect(results.StatesStatus[1].BlockedBy).To(Equal([]string{"ofed"}))
+			Expect(sriovDpState.syncCount).To(Equal(0))
+			// the removed and the disabled components are synced right away
+			Expect(results.StatesStatus[2].Status).To(Equal(SyncState(SyncStateIgnore)))
+			Expect(results.StatesStatus[2].BlockedBy).To(BeEmpty())
+			Expect(sharedDpState.syncCount).To(Equal(1))
+			Expect(results.StatesStatus[3].Status).To(Equal(SyncState(SyncStateDisabled)))
+			Expect(results.StatesStatus[3].BlockedBy).To(BeEmpty())
+			Expect(nvPeerState.syncCount).To(Equal(1))
+
+			// the device plugin is synced once the driver is ready
+			ofedState.syncState = SyncStateReady
+			results, err = manager.SyncState(cr, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results.Status).To(Equal(SyncState(SyncStateReady)))
+			Expect(sriovDpState.syncCount).To(Equal(1))
+		})
+		It("Should sync only states with changed spec when custom resource generation changes", func() {
+			ofedState := &fakeState{name: "ofed", syncState: SyncStateReady}
+			sriovDpState := &fakeState{name: "sriov-dp", syncState: SyncStateReady}
+			sharedDpState := &fakeState{name: "shared-dp", syncState: SyncStateNotReady}
+			client := mocks.ControllerRutimeClient{}
+			manager := &stateManager{
+				stateGroups: []Group{
+					NewStateGroup([]State{ofedState}),
+					NewStateGroup([]State{sriovDpState, sharedDpState}),
+				},
+				specSelectors: SpecSelectors{
+					"ofed": nicClusterPolicySpec(func(spec *mellanoxv1alpha1.NicClusterPolicySpec) []interface{} {
+						return []interface{}{spec.OFEDDriver}
+					}),
+					"sriov-dp": nicClusterPolicySpec(func(spec *mellanoxv1alpha1.NicClusterPolicySpec) []interface{} {
+						return []interface{}{spec.SriovDevicePlugin}
+					}),
+					"shared-dp": nicClusterPolicySpec(func(spec *mellanoxv1alpha1.NicClusterPolicySpec) []interface{} {
+						return []interface{}{spec.RdmaSharedDevicePlugin}
+					}),
+				},
+				client: &client,
+			}
+			cr := &mellanoxv1alpha1.NicClusterPolicy{}
+			cr.UID = "uid"
+			cr.Generation = 1
+			cr.Spec.OFEDDriver = &mellanoxv1alpha1.OFEDDriverSpec{}
+			cr.Spec.SriovDevicePlugin = &mellanoxv1alpha1.DevicePluginSpec{Config: "a"}
+
+			_, err := manager.SyncState(cr, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect([]int{ofedState.syncCount, sriovDpState.syncCount, sharedDpState.syncCount}).To(Equal([]int{1, 1, 1}))
+
+			// only the changed and not ready states are synced
+			cr.Generation = 2
+			cr.Spec.SriovDevicePlugin.Config = "b"
+			results, err := manager.SyncState(cr, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect([]int{ofedState.syncCount, sriovDpState.syncCount, sharedDpState.syncCount}).To(Equal([]int{1, 2, 2}))
+			Expect(results.StatesStatus[0].StateName).To(Equal("ofed"))
+			Expect(results.StatesStatus[0].Status).To(Equal(SyncState(SyncStateReady)))
+
+			// all states are synced if the generation didn't change, e.g. on changes of the deployed objects
+			results, err = manager.SyncState(cr, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect([]int{ofedState.syncCount, sriovDpState.syncCount, sharedDpState.syncCount}).To(Equal([]int{2, 3, 3}))
+			Expect(results.Status).To(Equal(SyncState(SyncStateNotReady)))
+
+			// all states are synced for a new custom resource
+			cr.UID = "new-uid"
+			cr.Generation = 1
+			_, err = manager.SyncState(cr, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect([]int{ofedState.syncCount, sriovDpState.syncCount, sharedDpState.syncCount}).To(Equal([]int{3, 4, 4}))
+		})
+		It("Should sync the states of a group in parallel up to the concurrency", func() {
+			counter := &concurrencyCounter{}
+			var states []State
+			for _, name := range []string{"multus", "cni-plugins", "ipoib", "ofed"} {
+				states = append(states, &concurrentState{
+					fakeState: fakeState{name: name, syncState: SyncStateReady}, counter: counter})
+			}
+			client := mocks.ControllerRutimeClient{}
+			manager := &stateManager{
+				stateGroups: []Group{NewStateGroup(states)},
+				client:      &client,
+				concurrency: 2,
+			}
+			results, err := manager.SyncState(nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results.Status).To(Equal(SyncState(SyncStateReady)))
+			Expect(counter.max).To(Equal(2))
+			// results are ordered as the states in the group
+			for i, name := range []string{"multus", "cni-plugins", "ipoib", "ofed"} {
+				Expect(results.StatesStatus[i].StateName).To(Equal(name))
+			}
+
+			counter.max = 0
+			manager.concurrency = 1
+			_, err = manager.SyncState(nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(counter.max).To(Equal(1))
+		})
+		It("Should reject dependencies on states from the same or later group", func() {
+			stateA := &fakeState{name: "a"}
+			stateB := &fakeState{name: "b"}
+			stateGroups := []Group{NewStateGroup([]State{stateA, stateB})}
+			Expect(validateDependencies(stateGroups, Dependencies{"a": {"b"}})).NotTo(Succeed())
+			Expect(validateDependencies(stateGroups, Dependencies{"a": {"unknown"}})).NotTo(Succeed())
+		})
 	})
 })
@@ -0,0 +1,67 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// propagateNetworkMetadata copies labels and annotations of the network CR which match the allowlist
+// to the rendered NetworkAttachmentDefinition. An allowlist entry ending with "*" matches keys by prefix,
+// otherwise the key should be equal to the entry.
+func propagateNetworkMetadata(cr metav1.Object, netAttDef *unstructured.Unstructured, allowlist []string) {
+	if len(allowlist) == 0 {
+		return
+	}
+	if labels := filterByAllowlist(cr.GetLabels(), netAttDef.GetLabels(), allowlist); labels != nil {
+		netAttDef.SetLabels(labels)
+	}
+	if annotations := filterByAllowlist(cr.GetAnnotations(), netAttDef.GetAnnotations(), allowlist); annotations != nil {
+		netAttDef.SetAnnotations(annotations)
+	}
+}
+
+// filterByAllowlist adds entries of src with keys matching the allowlist to dst,
+// keys which already exist in dst are not overridden
+func filterByAllowlist(src, dst map[string]string, allowlist []string) map[string]string {
+	for k, v := range src {
+		if _, exists := dst[k]; exists || !isKeyAllowed(k, allowlist) {
+			continue
+		}
+		if dst == nil {
+			dst = make(map[string]string)
+		}
+		dst[k] = v
+	}
+	return dst
+}
+
+func isKeyAllowed(key string, allowlist []string) bool {
+	for _, entry := range allowlist {
+		if strings.HasSuffix(entry, "*") {
+			if strings.HasPrefix(key, strings.TrimSuffix(entry, "*")) {
+				return true
+			}
+		} else if key == entry {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+)
+
+var _ = Describe("Network metadata propagation tests", func() {
+	var (
+		cr        *mellanoxv1alpha1.MacvlanNetwork
+		netAttDef *unstructured.Unstructured
+	)
+
+	BeforeEach(func() {
+		cr = &mellanoxv1alpha1.MacvlanNetwork{}
+		cr.SetLabels(map[string]string{
+			"policy.example.com/tier": "gold",
+			"team":                    "net",
+		})
+		cr.SetAnnotations(map[string]string{
+			"policy.example.com/owner":         "alice",
+			"k8s.v1.cni.cncf.io/resourceName":  "override",
+			"kubectl.kubernetes.io/last-state": "{}",
+		})
+		netAttDef = &unstructured.Unstructured{}
+		netAttDef.SetKind("NetworkAttachmentDefinition")
+		netAttDef.SetAnnotations(map[string]string{"k8s.v1.cni.cncf.io/resourceName": "nvidia.com/hostdev"})
+	})
+
+	It("Should not propagate metadata with empty allowlist", func() {
+		propagateNetworkMetadata(cr, netAttDef, nil)
+		Expect(netAttDef.GetLabels()).To(BeEmpty())
+		Expect(netAttDef.GetAnnotations()).To(Equal(map[string]string{
+			"k8s.v1.cni.cncf.io/resourceName": "nvidia.com/hostdev"}))
+	})
+
+	It("Should propagate metadata matching prefix and exact entries", func() {
+		propagateNetworkMetadata(cr, netAttDef, []string{"policy.example.com/*", "team", "k8s.v1.cni.cncf.io/resourceName"})
+		Expect(netAttDef.GetLabels()).To(Equal(map[string]string{
+			"policy.example.com/tier": "gold",
+			"team":                    "net",
+		}))
+		Expect(netAttDef.GetAnnotations()).To(Equal(map[string]string{
+			"policy.example.com/owner": "alice",
+			// rendered value is not overridden
+			"k8s.v1.cni.cncf.io/resourceName": "nvidia.com/hostdev",
+		}))
+	})
+
+	It("Should not propagate partially matching keys", func() {
+		propagateNetworkMetadata(cr, netAttDef, []string{"tea", "policy.example.com"})
+		Expect(netAttDef.GetLabels()).To(BeEmpty())
+		Expect(netAttDef.GetAnnotations()).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,71 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// mergeSecurityContext merges the security context into the containers and init containers rendered
+// for the DaemonSets, the fields set in the security context replace the rendered ones.
+// It must be called before additional init containers are added, which keep their own security context
+func mergeSecurityContext(objs []*unstructured.Unstructured, securityContext *v1.SecurityContext) error {
+	if securityContext == nil {
+		return nil
+	}
+	override, err := runtime.DefaultUnstructuredConverter.ToUnstructured(securityContext)
+	if err != nil {
+		return errors.Wrap(err, "failed to convert security context")
+	}
+
+	for _, obj := range objs {
+		if obj.GetKind() != "DaemonSet" {
+			continue
+		}
+		for _, field := range []string{"initContainers", "containers"} {
+			containers, _, err := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", field)
+			if err != nil {
+				return errors.Wrapf(err, "failed to get %s of DaemonSet %s", field, obj.GetName())
+			}
+			for _, container := range containers {
+				containerMap, ok := container.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				rendered, ok := containerMap["securityContext"].(map[string]interface{})
+				if !ok {
+					rendered = map[string]interface{}{}
+				}
+				for key, value := range override {
+					rendered[key] = runtime.DeepCopyJSONValue(value)
+				}
+				containerMap["securityContext"] = rendered
+			}
+			if len(containers) == 0 {
+				continue
+			}
+			err = unstructured.SetNestedSlice(obj.Object, containers, "spec", "template", "spec", field)
+			if err != nil {
+				return errors.Wrapf(err, "failed to set %s of DaemonSet %s", field, obj.GetName())
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var _ = Describe("Security context tests", func() {
+	It("Should merge the security context into the rendered containers", func() {
+		ds := &unstructured.Unstructured{Object: map[string]interface{}{
+			"kind": "DaemonSet",
+			"spec": map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{
+				"initContainers": []interface{}{map[string]interface{}{"name": "init"}},
+				"containers": []interface{}{map[string]interface{}{"name": "main", "securityContext": map[string]interface{}{
+					"privileged":     true,
+					"seLinuxOptions": map[string]interface{}{"level": "s0"},
+				}}},
+			}}},
+		}}
+		cm := &unstructured.Unstructured{Object: map[string]interface{}{"kind": "ConfigMap"}}
+		Expect(mergeSecurityContext([]*unstructured.Unstructured{ds, cm}, &v1.SecurityContext{
+			SeccompProfile: &v1.SeccompProfile{Type: v1.SeccompProfileTypeRuntimeDefault},
+			SELinuxOptions: &v1.SELinuxOptions{Type: "spc_t"},
+		})).To(Succeed())
+
+		containers, _, _ := unstructured.NestedSlice(ds.Object, "spec", "template", "spec", "containers")
+		Expect(containers[0].(map[string]interface{})["securityContext"]).To(Equal(map[string]interface{}{
+			"privileged":     true,
+			"seLinuxOptions": map[string]interface{}{"type": "spc_t"},
+			"seccompProfile": map[string]interface{}{"type": "RuntimeDefault"},
+		}))
+		initContainers, _, _ := unstructured.NestedSlice(ds.Object, "spec", "template", "spec", "initContainers")
+		Expect(initContainers[0].(map[string]interface{})["securityContext"]).To(Equal(map[string]interface{}{
+			"seLinuxOptions": map[string]interface{}{"type": "spc_t"},
+			"seccompProfile": map[string]interface{}{"type": "RuntimeDefault"},
+		}))
+		Expect(cm.Object).NotTo(HaveKey("spec"))
+	})
+
+	It("Should keep the rendered containers if no security context is set", func() {
+		ds := &unstructured.Unstructured{Object: map[string]interface{}{
+			"kind": "DaemonSet",
+			"spec": map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{
+				"containers": []interface{}{map[string]interface{}{"name": "main"}},
+			}}},
+		}}
+		expected := ds.DeepCopy()
+		Expect(mergeSecurityContext([]*unstructured.Unstructured{ds}, nil)).To(Succeed())
+		Expect(ds).To(Equal(expected))
+	})
+})
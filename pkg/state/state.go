@@ -28,6 +28,8 @@ const (
 	SyncStateReady    = "ready"
 	SyncStateNotReady = "notReady"
 	SyncStateIgnore   = "ignore"
+	// SyncStateDisabled is reported by states of the components disabled in the spec, their objects are deleted
+	SyncStateDisabled = "disabled"
 	SyncStateReset    = "reset"
 	SyncStateError    = "error"
 )
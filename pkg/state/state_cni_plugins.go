@@ -27,7 +27,6 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
-	"github.com/Mellanox/network-operator/pkg/config"
 	"github.com/Mellanox/network-operator/pkg/consts"
 	"github.com/Mellanox/network-operator/pkg/render"
 	"github.com/Mellanox/network-operator/pkg/utils"
@@ -61,7 +60,7 @@ type stateCNIPlugins struct {
 type CNIPluginsManifestRenderData struct {
 	CrSpec       *mellanoxv1alpha1.ImageSpec
 	NodeAffinity *v1.NodeAffinity
-	RuntimeSpec  *runtimeSpec
+	RuntimeSpec  *cniRuntimeSpec
 }
 
 // Sync attempt to get the system to match the desired state which State represent.
@@ -85,6 +84,9 @@ func (s *stateCNIPlugins) Sync(customResource interface{}, infoCatalog InfoCatal
 	if err != nil {
 		return SyncStateNotReady, errors.Wrap(err, "failed to create k8s objects from manifest")
 	}
+	if !cr.Spec.SecondaryNetwork.CniPlugins.IsEnabled() {
+		return s.deleteDisabledObjs(objs)
+	}
 	if len(objs) == 0 {
 		return SyncStateNotReady, nil
 	}
@@ -119,9 +121,7 @@ func (s *stateCNIPlugins) getManifestObjects(
 	renderData := &CNIPluginsManifestRenderData{
 		CrSpec:       cr.Spec.SecondaryNetwork.CniPlugins,
 		NodeAffinity: cr.Spec.NodeAffinity,
-		RuntimeSpec: &runtimeSpec{
-			Namespace: config.FromEnv().State.NetworkOperatorResourceNamespace,
-		},
+		RuntimeSpec:  newCNIRuntimeSpec(),
 	}
 	// render objects
 	log.V(consts.LogLevelDebug).Info("Rendering objects", "data:", renderData)
@@ -129,6 +129,9 @@ func (s *stateCNIPlugins) getManifestObjects(
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to render objects")
 	}
+	if err := mergeSecurityContext(objs, cr.Spec.SecondaryNetwork.CniPlugins.SecurityContext); err != nil {
+		return nil, err
+	}
 	log.V(consts.LogLevelDebug).Info("Rendered", "objects:", objs)
 	return objs, nil
 }
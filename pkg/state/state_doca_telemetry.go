@@ -0,0 +1,179 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state //nolint:dupl
+
+import (
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+	"github.com/Mellanox/network-operator/pkg/render"
+	"github.com/Mellanox/network-operator/pkg/utils"
+)
+
+// NewStateDOCATelemetry creates a new DOCA Telemetry Service state
+func NewStateDOCATelemetry(k8sAPIClient client.Client, scheme *runtime.Scheme, manifestDir string) (State, error) {
+	files, err := utils.GetFilesWithSuffix(manifestDir, render.ManifestFileSuffix...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get files from manifest dir")
+	}
+
+	renderer := render.NewRenderer(files)
+	return &stateDOCATelemetry{
+		stateSkel: stateSkel{
+			name:        "state-DOCA-telemetry-service",
+			description: "DOCA Telemetry Service deployed in the cluster",
+			client:      k8sAPIClient,
+			scheme:      scheme,
+			renderer:    renderer,
+		}}, nil
+}
+
+const (
+	// names of the DOCA Telemetry Service objects, see stage-doca-telemetry manifests
+	docaTelemetryConfigMap = "doca-telemetry-config"
+	docaTelemetryDaemonSet = "doca-telemetry-service"
+)
+
+var (
+	configMapGVK = schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	daemonSetGVK = schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "DaemonSet"}
+)
+
+type stateDOCATelemetry struct {
+	stateSkel
+}
+
+type docaTelemetryManifestRenderData struct {
+	CrSpec       *mellanoxv1alpha1.DOCATelemetrySpec
+	NodeAffinity *v1.NodeAffinity
+	RuntimeSpec  *runtimeSpec
+}
+
+// Sync attempt to get the system to match the desired state which State represent.
+// a sync operation must be relatively short and must not block the execution thread.
+//
+//nolint:dupl
+func (s *stateDOCATelemetry) Sync(customResource interface{}, infoCatalog InfoCatalog) (SyncState, error) {
+	cr := customResource.(*mellanoxv1alpha1.NicClusterPolicy)
+	log.V(consts.LogLevelInfo).Info(
+		"Sync Custom resource", "State:", s.name, "Name:", cr.Name, "Namespace:", cr.Namespace)
+
+	if cr.Spec.DOCATelemetry == nil {
+		// Either this state was not required to run or an update occurred and we need to remove
+		// the resources that where created.
+		log.V(consts.LogLevelInfo).Info("DOCA Telemetry Service spec in CR is nil, deleting its objects if exist")
+		if err := s.deleteManagedObjs(daemonSetGVK, docaTelemetryDaemonSet, ""); err != nil {
+			return SyncStateNotReady, errors.Wrap(err, "failed to delete DOCA Telemetry Service DaemonSet")
+		}
+		if err := s.deleteManagedObjs(configMapGVK, docaTelemetryConfigMap, ""); err != nil {
+			return SyncStateNotReady, errors.Wrap(err, "failed to delete DOCA Telemetry Service ConfigMap")
+		}
+		return SyncStateIgnore, nil
+	}
+	// the ConfigMap is rendered only if the configuration is provided
+	if cr.Spec.DOCATelemetry.Config == "" {
+		if err := s.deleteManagedObjs(configMapGVK, docaTelemetryConfigMap, ""); err != nil {
+			return SyncStateNotReady, errors.Wrap(err, "failed to delete DOCA Telemetry Service ConfigMap")
+		}
+	}
+	// Fill ManifestRenderData and render objects
+	nodeInfo := infoCatalog.GetNodeInfoProvider()
+	if nodeInfo == nil {
+		return SyncStateError, errors.New("unexpected state, catalog does not provide node information")
+	}
+
+	objs, err := s.getManifestObjects(cr, nodeInfo)
+	if err != nil {
+		return SyncStateNotReady, errors.Wrap(err, "failed to create k8s objects from manifest")
+	}
+	if !cr.Spec.DOCATelemetry.IsEnabled() {
+		return s.deleteDisabledObjs(objs)
+	}
+	if len(objs) == 0 {
+		return SyncStateNotReady, nil
+	}
+
+	// Create objects if they dont exist, Update objects if they do exist
+	err = s.createOrUpdateObjs(func(obj *unstructured.Unstructured) error {
+		if err := controllerutil.SetControllerReference(cr, obj, s.scheme); err != nil {
+			return errors.Wrap(err, "failed to set controller reference for object")
+		}
+		return nil
+	}, objs)
+	if err != nil {
+		return SyncStateNotReady, errors.Wrap(err, "failed to create/update objects")
+	}
+	// objects are not garbage collected when the namespace of the component changes
+	if err := s.deleteObjsInOtherNamespaces(objs); err != nil {
+		return SyncStateNotReady, errors.Wrap(err, "failed to delete objects from previous namespace")
+	}
+	// Check objects status
+	syncState, err := s.getSyncState(objs)
+	if err != nil {
+		return SyncStateNotReady, errors.Wrap(err, "failed to get sync state")
+	}
+	return syncState, nil
+}
+
+// Get a map of source kinds that should be watched for the state keyed by the source kind name
+func (s *stateDOCATelemetry) GetWatchSources() map[string]*source.Kind {
+	wr := make(map[string]*source.Kind)
+	wr["DaemonSet"] = &source.Kind{Type: &appsv1.DaemonSet{}}
+	return wr
+}
+
+func (s *stateDOCATelemetry) getManifestObjects(
+	cr *mellanoxv1alpha1.NicClusterPolicy,
+	nodeInfo nodeinfo.Provider) ([]*unstructured.Unstructured, error) {
+	attrs := nodeInfo.GetNodesAttributes(
+		nodeinfo.NewNodeLabelFilterBuilder().
+			WithLabel(nodeinfo.NodeLabelMlnxNIC, "true").
+			Build())
+	if len(attrs) == 0 {
+		log.V(consts.LogLevelInfo).Info("No nodes with Mellanox NICs where found in the cluster.")
+		return []*unstructured.Unstructured{}, nil
+	}
+
+	renderData := &docaTelemetryManifestRenderData{
+		CrSpec:       cr.Spec.DOCATelemetry,
+		NodeAffinity: cr.Spec.NodeAffinity,
+		RuntimeSpec: &runtimeSpec{
+			Namespace: docaTelemetryNamespace(cr),
+		},
+	}
+	// render objects
+	log.V(consts.LogLevelDebug).Info("Rendering objects", "data:", renderData)
+	objs, err := s.renderer.RenderObjects(&render.TemplatingData{Data: renderData})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to render objects")
+	}
+	if err := mergeSecurityContext(objs, cr.Spec.DOCATelemetry.SecurityContext); err != nil {
+		return nil, err
+	}
+	log.V(consts.LogLevelDebug).Info("Rendered", "objects:", objs)
+	return objs, nil
+}
@@ -0,0 +1,164 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+	"github.com/Mellanox/network-operator/pkg/render"
+	"github.com/Mellanox/network-operator/pkg/testing/mocks"
+	"github.com/Mellanox/network-operator/pkg/utils"
+)
+
+var _ = Describe("DOCA Telemetry Service State tests", func() {
+	var (
+		docaTelemetryState stateDOCATelemetry
+		cr                 *mellanoxv1alpha1.NicClusterPolicy
+	)
+
+	BeforeEach(func() {
+		client := mocks.ControllerRutimeClient{}
+		files, err := utils.GetFilesWithSuffix("../../manifests/stage-doca-telemetry", render.ManifestFileSuffix...)
+		Expect(err).NotTo(HaveOccurred())
+		docaTelemetryState = stateDOCATelemetry{
+			stateSkel: stateSkel{
+				name:        "state-DOCA-telemetry-service",
+				description: "DOCA Telemetry Service deployed in the cluster",
+				client:      &client,
+				scheme:      runtime.NewScheme(),
+				renderer:    render.NewRenderer(files),
+			},
+		}
+		cr = &mellanoxv1alpha1.NicClusterPolicy{}
+		cr.Spec.DOCATelemetry = &mellanoxv1alpha1.DOCATelemetrySpec{
+			ImageSpec: mellanoxv1alpha1.ImageSpec{
+				Image:      "doca_telemetry",
+				Repository: "nvcr.io/nvidia/doca",
+				Version:    "1.11.0-doca1.3.0-host",
+			},
+		}
+	})
+
+	getVolumeSource := func(ds *unstructured.Unstructured, name string) map[string]interface{} {
+		volumes, _, _ := unstructured.NestedSlice(ds.Object, "spec", "template", "spec", "volumes")
+		for _, v := range volumes {
+			volume := v.(map[string]interface{})
+			if volume["name"] == name {
+				return volume
+			}
+		}
+		return nil
+	}
+
+	It("Should render objects in the configured namespace", func() {
+		cr.Spec.DOCATelemetry.Config = "[telemetry]"
+		cr.Spec.DOCATelemetry.Namespace = "telemetry"
+		objs, err := docaTelemetryState.getManifestObjects(cr, &dummyProvider{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(objs).To(HaveLen(2))
+		for _, obj := range objs {
+			Expect(obj.GetNamespace()).To(Equal("telemetry"))
+		}
+	})
+
+	It("Should render DaemonSet with host configuration", func() {
+		objs, err := docaTelemetryState.getManifestObjects(cr, &dummyProvider{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(objs).To(HaveLen(1))
+		Expect(objs[0].GetKind()).To(Equal("DaemonSet"))
+
+		containers, _, _ := unstructured.NestedSlice(objs[0].Object, "spec", "template", "spec", "containers")
+		Expect(containers[0].(map[string]interface{})["image"]).To(
+			Equal("nvcr.io/nvidia/doca/doca_telemetry:1.11.0-doca1.3.0-host"))
+		Expect(getVolumeSource(objs[0], "config")).To(HaveKey("hostPath"))
+	})
+
+	It("Should render ConfigMap with provided configuration", func() {
+		cr.Spec.DOCATelemetry.Config = "[prometheus]\nport=9100\n"
+		objs, err := docaTelemetryState.getManifestObjects(cr, &dummyProvider{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(objs).To(HaveLen(2))
+		Expect(objs[0].GetKind()).To(Equal("ConfigMap"))
+		config, _, _ := unstructured.NestedString(objs[0].Object, "data", "dts_config.ini")
+		Expect(config).To(Equal("[prometheus]\nport=9100\n"))
+		Expect(getVolumeSource(objs[1], "config")).To(HaveKey("configMap"))
+	})
+
+	Context("Objects which are no longer rendered", func() {
+		var fakeClient client.Client
+
+		BeforeEach(func() {
+			scheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+			Expect(mellanoxv1alpha1.AddToScheme(scheme)).To(Succeed())
+			namespace := docaTelemetryNamespace(cr)
+			fakeClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+				&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+					Namespace: namespace, Name: docaTelemetryConfigMap, Labels: ManagedLabels()}},
+				&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{
+					Namespace: namespace, Name: docaTelemetryDaemonSet, Labels: ManagedLabels()}},
+			).Build()
+			docaTelemetryState.client = fakeClient
+			docaTelemetryState.scheme = scheme
+		})
+
+		exists := func(obj client.Object, name string) bool {
+			err := fakeClient.Get(context.TODO(), types.NamespacedName{
+				Namespace: docaTelemetryNamespace(cr), Name: name}, obj)
+			if k8serrors.IsNotFound(err) {
+				return false
+			}
+			Expect(err).NotTo(HaveOccurred())
+			return true
+		}
+
+		It("Should delete the ConfigMap once the configuration is unset", func() {
+			catalog := NewInfoCatalog()
+			catalog.Add(InfoTypeNodeInfo, nodeinfo.NewProvider(nil))
+			syncState, err := docaTelemetryState.Sync(cr, catalog)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(syncState).To(Equal(SyncState(SyncStateNotReady)))
+			Expect(exists(&v1.ConfigMap{}, docaTelemetryConfigMap)).To(BeFalse())
+			Expect(exists(&appsv1.DaemonSet{}, docaTelemetryDaemonSet)).To(BeTrue())
+		})
+
+		It("Should delete the objects once the spec is removed", func() {
+			cr.Spec.DOCATelemetry = nil
+			syncState, err := docaTelemetryState.Sync(cr, NewInfoCatalog())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(syncState).To(Equal(SyncState(SyncStateIgnore)))
+			Expect(exists(&v1.ConfigMap{}, docaTelemetryConfigMap)).To(BeFalse())
+			Expect(exists(&appsv1.DaemonSet{}, docaTelemetryDaemonSet)).To(BeFalse())
+		})
+	})
+})
@@ -17,6 +17,7 @@ limitations under the License.
 package state //nolint:dupl
 
 import (
+	"fmt"
 	"strings"
 
 	netattdefv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
@@ -67,6 +68,9 @@ type HostDeviceManifestRenderData struct {
 	CrSpec                mellanoxv1alpha1.HostDeviceNetworkSpec
 	RuntimeSpec           *runtimeSpec
 	ResourceName          string
+	CniVersion            string
+	DeviceType            string
+	DPDKDriver            string
 }
 
 // Sync attempt to get the system to match the desired state which State represent.
@@ -123,20 +127,77 @@ func (s *stateHostDeviceNetwork) GetWatchSources() map[string]*source.Kind {
 	return wr
 }
 
-func (s *stateHostDeviceNetwork) getManifestObjects(
-	cr *mellanoxv1alpha1.HostDeviceNetwork) ([]*unstructured.Unstructured, error) {
+// HostDeviceNetworkResourceName returns the full name of the resource referenced by the HostDeviceNetwork,
+// nvidia.com/ prefix is added if the resource name is not prefixed
+func HostDeviceNetworkResourceName(cr *mellanoxv1alpha1.HostDeviceNetwork) string {
 	resourceName := cr.Spec.ResourceName
 	if !strings.HasPrefix(resourceName, resourceNamePrefix) {
 		resourceName = resourceNamePrefix + resourceName
 	}
+	return resourceName
+}
+
+// hostDeviceType returns the device type of the HostDeviceNetwork and the userspace driver of DPDK devices,
+// an error is returned if the spec fields don't match the device type
+func hostDeviceType(spec *mellanoxv1alpha1.HostDeviceNetworkSpec) (deviceType, dpdkDriver string, err error) {
+	deviceType = spec.DeviceType
+	if deviceType == "" {
+		deviceType = mellanoxv1alpha1.HostDeviceTypeNetDevice
+	}
+	switch deviceType {
+	case mellanoxv1alpha1.HostDeviceTypeNetDevice:
+		if spec.DPDK != nil {
+			return "", "", fmt.Errorf("dpdk can only be set if deviceType is %s", mellanoxv1alpha1.HostDeviceTypeDPDK)
+		}
+		return deviceType, "", nil
+	case mellanoxv1alpha1.HostDeviceTypeDPDK:
+		if spec.ResourceName == "" {
+			return "", "", errors.New("resourceName is required for dpdk devices")
+		}
+		if strings.TrimSpace(spec.IPAM) != "" {
+			return "", "", errors.New("ipam must not be set for dpdk devices, they are not configured by the kernel")
+		}
+		if spec.DefaultRoute != nil {
+			return "", "", errors.New("defaultRoute must not be set for dpdk devices")
+		}
+		dpdkDriver = mellanoxv1alpha1.DefaultDPDKDriver
+		if spec.DPDK != nil && spec.DPDK.Driver != "" {
+			dpdkDriver = spec.DPDK.Driver
+		}
+		return deviceType, dpdkDriver, nil
+	default:
+		return "", "", fmt.Errorf("unsupported deviceType %q, expected %s or %s", deviceType,
+			mellanoxv1alpha1.HostDeviceTypeNetDevice, mellanoxv1alpha1.HostDeviceTypeDPDK)
+	}
+}
+
+func (s *stateHostDeviceNetwork) getManifestObjects(
+	cr *mellanoxv1alpha1.HostDeviceNetwork) ([]*unstructured.Unstructured, error) {
+	resourceName := HostDeviceNetworkResourceName(cr)
+	cniVersion, err := netAttDefCNIVersion(cr.Spec.CNIVersion)
+	if err != nil {
+		return nil, err
+	}
+	deviceType, dpdkDriver, err := hostDeviceType(&cr.Spec)
+	if err != nil {
+		return nil, err
+	}
+	crSpec := cr.Spec
+	crSpec.IPAM, err = ipamWithDefaultRoute(cr.Spec.IPAM, cr.Spec.DefaultRoute)
+	if err != nil {
+		return nil, err
+	}
 
 	renderData := &HostDeviceManifestRenderData{
 		HostDeviceNetworkName: cr.Name,
-		CrSpec:                cr.Spec,
+		CrSpec:                crSpec,
 		RuntimeSpec: &runtimeSpec{
 			Namespace: config.FromEnv().State.NetworkOperatorResourceNamespace,
 		},
 		ResourceName: resourceName,
+		CniVersion:   cniVersion,
+		DeviceType:   deviceType,
+		DPDKDriver:   dpdkDriver,
 	}
 
 	// render objects
@@ -146,5 +207,10 @@ func (s *stateHostDeviceNetwork) getManifestObjects(
 		return nil, errors.Wrap(err, "failed to render objects")
 	}
 	log.V(consts.LogLevelDebug).Info("Rendered", "objects:", objs)
+	for _, obj := range objs {
+		if obj.GetKind() == "NetworkAttachmentDefinition" {
+			propagateNetworkMetadata(cr, obj, config.FromEnv().State.NetworkMetadataAllowlist)
+		}
+	}
 	return objs, nil
 }
@@ -17,6 +17,7 @@ limitations under the License.
 package state
 
 import (
+	"encoding/json"
 	"strings"
 
 	. "github.com/onsi/ginkgo"
@@ -83,6 +84,25 @@ var _ = Describe("HostDevice Network Stage rendering tests", func() {
 
 			checkRenderedNetAttachDef(objs[0], namespace, name, ipam)
 			checkResourceNameAnnotation(objs[0])
+			Expect(objs[0].Object["spec"].(map[string]interface{})["config"].(string)).To(
+				ContainSubstring(`"cniVersion":"` + mellanoxv1alpha1.DefaultCNIVersion + `"`))
+
+			cr.Spec.CNIVersion = "0.4.0"
+			objs, err = sriovDpState.getManifestObjects(cr)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(objs[0].Object["spec"].(map[string]interface{})["config"].(string)).To(
+				ContainSubstring(`"cniVersion":"0.4.0"`))
+
+			defaultRoute := true
+			cr.Spec.DefaultRoute = &defaultRoute
+			cr.Spec.IPAM = `{"type":"whereabouts","range":"192.168.2.0/24"}`
+			objs, err = sriovDpState.getManifestObjects(cr)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(objs[0].Object["spec"].(map[string]interface{})["config"].(string)).To(
+				ContainSubstring(`"routes":[{"dst":"0.0.0.0/0"}]`))
+			Expect(cr.Spec.IPAM).To(Equal(`{"type":"whereabouts","range":"192.168.2.0/24"}`))
 
 			spec.ResourceName = resourceNamePrefix + "test_resource_with_prefix"
 			objs, err = sriovDpState.getManifestObjects(cr)
@@ -90,5 +110,75 @@ var _ = Describe("HostDevice Network Stage rendering tests", func() {
 			Expect(err).NotTo(HaveOccurred())
 			checkResourceNameAnnotation(objs[0])
 		})
+
+		It("Should Render NetworkAttachmentDefinition for DPDK devices", func() {
+			manifestBaseDir := "../../manifests/stage-hostdevice-network"
+			files, err := utils.GetFilesWithSuffix(manifestBaseDir, render.ManifestFileSuffix...)
+			Expect(err).NotTo(HaveOccurred())
+			hostDeviceState := stateHostDeviceNetwork{
+				stateSkel: stateSkel{
+					client:   &mocks.ControllerRutimeClient{},
+					scheme:   runtime.NewScheme(),
+					renderer: render.NewRenderer(files),
+				},
+			}
+
+			cr := &mellanoxv1alpha1.HostDeviceNetwork{}
+			cr.Name = "dpdk-network"
+			cr.Spec = mellanoxv1alpha1.HostDeviceNetworkSpec{
+				NetworkNamespace: "default",
+				ResourceName:     "hostdev",
+				DeviceType:       mellanoxv1alpha1.HostDeviceTypeDPDK,
+			}
+			netConf := func() map[string]interface{} {
+				objs, err := hostDeviceState.getManifestObjects(cr)
+				Expect(err).NotTo(HaveOccurred())
+				conf := map[string]interface{}{}
+				Expect(json.Unmarshal(
+					[]byte(objs[0].Object["spec"].(map[string]interface{})["config"].(string)), &conf)).To(Succeed())
+				return conf
+			}
+
+			conf := netConf()
+			Expect(conf["type"]).To(Equal("host-device"))
+			Expect(conf).NotTo(HaveKey("ipam"))
+			// Multus passes the PCI address of the allocated device to the CNI and reports its device-info
+			Expect(conf["capabilities"]).To(Equal(map[string]interface{}{"deviceID": true, "CNIDeviceInfoFile": true}))
+			Expect(conf["dpdk"]).To(Equal(map[string]interface{}{"driver": mellanoxv1alpha1.DefaultDPDKDriver}))
+
+			cr.Spec.DPDK = &mellanoxv1alpha1.HostDeviceDPDKSpec{Driver: "uio_pci_generic"}
+			Expect(netConf()["dpdk"]).To(Equal(map[string]interface{}{"driver": "uio_pci_generic"}))
+
+			cr.Spec.IPAM = `{"type":"whereabouts","range":"192.168.2.0/24"}`
+			_, err = hostDeviceState.getManifestObjects(cr)
+			Expect(err).To(HaveOccurred())
+
+			cr.Spec.IPAM = ""
+			defaultRoute := false
+			cr.Spec.DefaultRoute = &defaultRoute
+			_, err = hostDeviceState.getManifestObjects(cr)
+			Expect(err).To(HaveOccurred())
+
+			cr.Spec.DefaultRoute = nil
+			cr.Spec.ResourceName = ""
+			_, err = hostDeviceState.getManifestObjects(cr)
+			Expect(err).To(HaveOccurred())
+
+			cr.Spec.ResourceName = "hostdev"
+			cr.Spec.DeviceType = ""
+			_, err = hostDeviceState.getManifestObjects(cr)
+			Expect(err).To(HaveOccurred())
+
+			cr.Spec.DPDK = nil
+			cr.Spec.IPAM = `{"type":"whereabouts","range":"192.168.2.0/24"}`
+			conf = netConf()
+			Expect(conf).To(HaveKey("ipam"))
+			Expect(conf).NotTo(HaveKey("capabilities"))
+			Expect(conf).NotTo(HaveKey("dpdk"))
+
+			cr.Spec.DeviceType = "vdpa"
+			_, err = hostDeviceState.getManifestObjects(cr)
+			Expect(err).To(HaveOccurred())
+		})
 	})
 })
@@ -27,7 +27,6 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
-	"github.com/Mellanox/network-operator/pkg/config"
 	"github.com/Mellanox/network-operator/pkg/consts"
 	"github.com/Mellanox/network-operator/pkg/render"
 	"github.com/Mellanox/network-operator/pkg/utils"
@@ -58,7 +57,7 @@ type stateIPoIBCNI struct {
 type IPoIBManifestRenderData struct {
 	CrSpec       *mellanoxv1alpha1.ImageSpec
 	NodeAffinity *v1.NodeAffinity
-	RuntimeSpec  *runtimeSpec
+	RuntimeSpec  *cniRuntimeSpec
 }
 
 // Sync attempt to get the system to match the desired state which State represent.
@@ -81,6 +80,9 @@ func (s *stateIPoIBCNI) Sync(customResource interface{}, infoCatalog InfoCatalog
 	if err != nil {
 		return SyncStateNotReady, errors.Wrap(err, "failed to create k8s objects from manifest")
 	}
+	if !cr.Spec.SecondaryNetwork.IPoIB.IsEnabled() {
+		return s.deleteDisabledObjs(objs)
+	}
 	if len(objs) == 0 {
 		return SyncStateNotReady, nil
 	}
@@ -115,9 +117,7 @@ func (s *stateIPoIBCNI) getManifestObjects(
 	renderData := &IPoIBManifestRenderData{
 		CrSpec:       cr.Spec.SecondaryNetwork.IPoIB,
 		NodeAffinity: cr.Spec.NodeAffinity,
-		RuntimeSpec: &runtimeSpec{
-			Namespace: config.FromEnv().State.NetworkOperatorResourceNamespace,
-		},
+		RuntimeSpec:  newCNIRuntimeSpec(),
 	}
 
 	// render objects
@@ -127,6 +127,9 @@ func (s *stateIPoIBCNI) getManifestObjects(
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to render objects")
 	}
+	if err := mergeSecurityContext(objs, cr.Spec.SecondaryNetwork.IPoIB.SecurityContext); err != nil {
+		return nil, err
+	}
 
 	log.V(consts.LogLevelDebug).Info("Rendered", "objects:", objs)
 	return objs, nil
@@ -20,12 +20,14 @@ import (
 	"encoding/json"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/config"
 	"github.com/Mellanox/network-operator/pkg/render"
 	"github.com/Mellanox/network-operator/pkg/testing/mocks"
 	"github.com/Mellanox/network-operator/pkg/utils"
@@ -81,6 +83,32 @@ var _ = Describe("IPoIB CNI State tests", func() {
 
 			Expect(err).NotTo(HaveOccurred())
 			Expect(len(objs)).To(Equal(1))
+			volumes, _, _ := unstructured.NestedSlice(objs[0].Object, "spec", "template", "spec", "volumes")
+			Expect(volumes).To(ContainElement(map[string]interface{}{
+				"name": "cnibin", "hostPath": map[string]interface{}{"path": "/opt/cni/bin"}}))
+		})
+
+		It("Should mount the configured CNI bin directory", func() {
+			savedCniBinDir := config.FromEnv().State.CniBinDir
+			defer func() { config.FromEnv().State.CniBinDir = savedCniBinDir }()
+			config.FromEnv().State.CniBinDir = "/var/lib/cni/bin"
+
+			files, err := utils.GetFilesWithSuffix("../../manifests/stage-ipoib-cni", render.ManifestFileSuffix...)
+			Expect(err).NotTo(HaveOccurred())
+			ipoibState := stateIPoIBCNI{stateSkel: stateSkel{renderer: render.NewRenderer(files)}}
+			cr := &mellanoxv1alpha1.NicClusterPolicy{}
+			cr.Spec.SecondaryNetwork = &mellanoxv1alpha1.SecondaryNetworkSpec{IPoIB: &mellanoxv1alpha1.ImageSpec{
+				Image:      "image",
+				Repository: "Repository",
+				Version:    "v0.0",
+			}}
+
+			objs, err := ipoibState.getManifestObjects(cr)
+
+			Expect(err).NotTo(HaveOccurred())
+			volumes, _, _ := unstructured.NestedSlice(objs[0].Object, "spec", "template", "spec", "volumes")
+			Expect(volumes).To(ContainElement(map[string]interface{}{
+				"name": "cnibin", "hostPath": map[string]interface{}{"path": "/var/lib/cni/bin"}}))
 		})
 	})
 })
@@ -31,6 +31,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/config"
 	"github.com/Mellanox/network-operator/pkg/consts"
 	"github.com/Mellanox/network-operator/pkg/render"
 	"github.com/Mellanox/network-operator/pkg/utils"
@@ -138,6 +139,11 @@ func (s *stateIPoIBNetwork) getManifestObjects(
 	}
 
 	data["Master"] = cr.Spec.Master
+	cniVersion, err := netAttDefCNIVersion(cr.Spec.CNIVersion)
+	if err != nil {
+		return nil, err
+	}
+	data["CniVersion"] = cniVersion
 
 	if cr.Spec.IPAM != "" {
 		data["Ipam"] = "\"ipam\":" + strings.Join(strings.Fields(cr.Spec.IPAM), "")
@@ -152,6 +158,11 @@ func (s *stateIPoIBNetwork) getManifestObjects(
 		return nil, errors.Wrap(err, "failed to render objects")
 	}
 	log.V(consts.LogLevelDebug).Info("Rendered", "objects:", objs)
+	for _, obj := range objs {
+		if obj.GetKind() == "NetworkAttachmentDefinition" {
+			propagateNetworkMetadata(cr, obj, config.FromEnv().State.NetworkMetadataAllowlist)
+		}
+	}
 	return objs, nil
 }
 
@@ -180,7 +191,12 @@ func (s *stateIPoIBNetwork) updateNetAttDefNamespace(cr *mellanoxv1alpha1.IPoIBN
 	lnns, lnnsExists := cr.GetAnnotations()[lastIPoIBNetworkNamespaceAnnot]
 	netAttDefChangedNamespace := lnnsExists && netAttDef.GetNamespace() != lnns
 	if !lnnsExists || netAttDefChangedNamespace {
-		anno := map[string]string{lastIPoIBNetworkNamespaceAnnot: netAttDef.GetNamespace()}
+		// keep other annotations of the CR, they can be propagated to the NetworkAttachmentDefinition
+		anno := cr.GetAnnotations()
+		if anno == nil {
+			anno = make(map[string]string)
+		}
+		anno[lastIPoIBNetworkNamespaceAnnot] = netAttDef.GetNamespace()
 		cr.SetAnnotations(anno)
 		if err := s.client.Update(context.Background(), cr); err != nil {
 			return errors.Wrap(err, "failed to update IPoIBNetwork annotations")
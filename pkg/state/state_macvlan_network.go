@@ -31,6 +31,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/config"
 	"github.com/Mellanox/network-operator/pkg/consts"
 	"github.com/Mellanox/network-operator/pkg/render"
 	"github.com/Mellanox/network-operator/pkg/utils"
@@ -40,6 +41,7 @@ const (
 	stateMacvlanNetworkName        = "state-Macvlan-Network"
 	stateMacvlanNetworkDescription = "Macvlan net-attach-def CR deployed in cluster"
 	lastNetworkNamespaceAnnot      = "operator.macvlannetwork.mellanox.com/last-network-namespace"
+	lastTargetNamespacesAnnot      = "operator.macvlannetwork.mellanox.com/last-target-namespaces"
 )
 
 // NewStateMacvlanNetwork creates a new state for MacvlanNetwork CR
@@ -90,6 +92,9 @@ func (s *stateMacvlanNetwork) Sync(customResource interface{}, _ InfoCatalog) (S
 	if err = s.handleNamespaceChange(cr, netAttDef); err != nil {
 		return SyncStateError, errors.Wrap(err, "Couldn't delete NetworkAttachmentDefinition CR")
 	}
+	if err = s.handleTargetNamespacesChange(cr, objs); err != nil {
+		return SyncStateError, errors.Wrap(err, "Couldn't delete NetworkAttachmentDefinition CR")
+	}
 
 	err = s.createOrUpdateObjs(func(obj *unstructured.Unstructured) error {
 		if err := controllerutil.SetControllerReference(cr, obj, s.scheme); err != nil {
@@ -131,32 +136,93 @@ func (s *stateMacvlanNetwork) getManifestObjects(
 	cr *mellanoxv1alpha1.MacvlanNetwork) ([]*unstructured.Unstructured, error) {
 	data := map[string]interface{}{}
 	data["NetworkName"] = cr.Name
-	if cr.Spec.NetworkNamespace == "" {
-		data["NetworkNamespace"] = "default"
-	} else {
-		data["NetworkNamespace"] = cr.Spec.NetworkNamespace
-	}
-
 	data["Master"] = cr.Spec.Master
 	data["Mode"] = cr.Spec.Mode
 	data["Mtu"] = cr.Spec.Mtu
+	cniVersion, err := netAttDefCNIVersion(cr.Spec.CNIVersion)
+	if err != nil {
+		return nil, err
+	}
+	data["CniVersion"] = cniVersion
+	ipam, err := ipamWithDefaultRoute(cr.Spec.IPAM, cr.Spec.DefaultRoute)
+	if err != nil {
+		return nil, err
+	}
 
-	if cr.Spec.IPAM != "" {
-		data["Ipam"] = "\"ipam\":" + strings.Join(strings.Fields(cr.Spec.IPAM), "")
+	if ipam != "" {
+		data["Ipam"] = "\"ipam\":" + strings.Join(strings.Fields(ipam), "")
 	} else {
 		data["Ipam"] = "\"ipam\":{}"
 	}
 
-	// render objects
-	log.V(consts.LogLevelDebug).Info("Rendering objects", "data:", data)
-	objs, err := s.renderer.RenderObjects(&render.TemplatingData{Data: data})
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to render objects")
+	// render objects, NetworkAttachmentDefinition in the network namespace goes first
+	var objs []*unstructured.Unstructured
+	for _, namespace := range MacvlanNetworkNamespaces(cr) {
+		data["NetworkNamespace"] = namespace
+		log.V(consts.LogLevelDebug).Info("Rendering objects", "data:", data)
+		nsObjs, err := s.renderer.RenderObjects(&render.TemplatingData{Data: data})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to render objects")
+		}
+		objs = append(objs, nsObjs...)
 	}
 	log.V(consts.LogLevelDebug).Info("Rendered", "objects:", objs)
+	for _, obj := range objs {
+		if obj.GetKind() == "NetworkAttachmentDefinition" {
+			propagateNetworkMetadata(cr, obj, config.FromEnv().State.NetworkMetadataAllowlist)
+		}
+	}
 	return objs, nil
 }
 
+// MacvlanNetworkNamespaces returns the namespaces of the NetworkAttachmentDefinitions of the MacvlanNetwork,
+// the network namespace followed by the target namespaces without duplicates
+func MacvlanNetworkNamespaces(cr *mellanoxv1alpha1.MacvlanNetwork) []string {
+	networkNamespace := cr.Spec.NetworkNamespace
+	if networkNamespace == "" {
+		networkNamespace = "default"
+	}
+	namespaces := []string{networkNamespace}
+	seen := map[string]bool{networkNamespace: true}
+	for _, namespace := range cr.Spec.TargetNamespaces {
+		if namespace == "" || seen[namespace] {
+			continue
+		}
+		seen[namespace] = true
+		namespaces = append(namespaces, namespace)
+	}
+	return namespaces
+}
+
+// handleTargetNamespacesChange deletes NetworkAttachmentDefinitions from the namespaces
+// which were removed from the target namespaces
+func (s *stateMacvlanNetwork) handleTargetNamespacesChange(cr *mellanoxv1alpha1.MacvlanNetwork,
+	objs []*unstructured.Unstructured) error {
+	ltns, ltnsExists := cr.GetAnnotations()[lastTargetNamespacesAnnot]
+	if !ltnsExists || ltns == "" {
+		return nil
+	}
+	desired := make(map[string]bool, len(objs))
+	for _, obj := range objs {
+		desired[obj.GetNamespace()] = true
+	}
+	for _, namespace := range strings.Split(ltns, ",") {
+		if desired[namespace] {
+			continue
+		}
+		err := s.client.Delete(context.TODO(), &netattdefv1.NetworkAttachmentDefinition{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      cr.GetName(),
+				Namespace: namespace,
+			},
+		})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *stateMacvlanNetwork) handleNamespaceChange(cr *mellanoxv1alpha1.MacvlanNetwork,
 	netAttDef *unstructured.Unstructured) error {
 	// Delete NetworkAttachmentDefinition if not in desired namespace
@@ -181,8 +247,20 @@ func (s *stateMacvlanNetwork) updateNetAttDefNamespace(cr *mellanoxv1alpha1.Macv
 	netAttDef *unstructured.Unstructured) error {
 	lnns, lnnsExists := cr.GetAnnotations()[lastNetworkNamespaceAnnot]
 	netAttDefChangedNamespace := lnnsExists && netAttDef.GetNamespace() != lnns
-	if !lnnsExists || netAttDefChangedNamespace {
-		anno := map[string]string{lastNetworkNamespaceAnnot: netAttDef.GetNamespace()}
+	targetNamespaces := strings.Join(MacvlanNetworkNamespaces(cr)[1:], ",")
+	targetNamespacesChanged := cr.GetAnnotations()[lastTargetNamespacesAnnot] != targetNamespaces
+	if !lnnsExists || netAttDefChangedNamespace || targetNamespacesChanged {
+		// keep other annotations of the CR, they can be propagated to the NetworkAttachmentDefinition
+		anno := cr.GetAnnotations()
+		if anno == nil {
+			anno = make(map[string]string)
+		}
+		anno[lastNetworkNamespaceAnnot] = netAttDef.GetNamespace()
+		if targetNamespaces == "" {
+			delete(anno, lastTargetNamespacesAnnot)
+		} else {
+			anno[lastTargetNamespacesAnnot] = targetNamespaces
+		}
 		cr.SetAnnotations(anno)
 		if err := s.client.Update(context.Background(), cr); err != nil {
 			return errors.Wrap(err, "failed to update MacvlanNetwork annotations")
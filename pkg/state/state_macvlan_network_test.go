@@ -0,0 +1,186 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	netattdefv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	"github.com/stretchr/testify/mock"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/render"
+	"github.com/Mellanox/network-operator/pkg/testing/mocks"
+	"github.com/Mellanox/network-operator/pkg/utils"
+)
+
+var _ = Describe("MacvlanNetwork State tests", func() {
+	var (
+		macvlanState stateMacvlanNetwork
+		cr           *mellanoxv1alpha1.MacvlanNetwork
+	)
+
+	BeforeEach(func() {
+		client := mocks.ControllerRutimeClient{}
+		files, err := utils.GetFilesWithSuffix("../../manifests/stage-macvlan-network", render.ManifestFileSuffix...)
+		Expect(err).NotTo(HaveOccurred())
+		macvlanState = stateMacvlanNetwork{
+			stateSkel: stateSkel{
+				name:        stateMacvlanNetworkName,
+				description: stateMacvlanNetworkDescription,
+				client:      &client,
+				scheme:      runtime.NewScheme(),
+				renderer:    render.NewRenderer(files),
+			},
+		}
+		cr = &mellanoxv1alpha1.MacvlanNetwork{}
+		cr.Name = "macvlan"
+		cr.Spec.NetworkNamespace = "net"
+	})
+
+	It("Should render NetworkAttachmentDefinition in the network namespace", func() {
+		objs, err := macvlanState.getManifestObjects(cr)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(objs).To(HaveLen(1))
+		Expect(objs[0].GetNamespace()).To(Equal("net"))
+		Expect(objs[0].GetName()).To(Equal("macvlan"))
+	})
+
+	It("Should render NetworkAttachmentDefinition in the target namespaces", func() {
+		cr.Spec.TargetNamespaces = []string{"a", "net", "b", "a"}
+		objs, err := macvlanState.getManifestObjects(cr)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(objs).To(HaveLen(3))
+		Expect(objs[0].GetNamespace()).To(Equal("net"))
+		Expect(objs[1].GetNamespace()).To(Equal("a"))
+		Expect(objs[2].GetNamespace()).To(Equal("b"))
+	})
+
+	It("Should delete NetworkAttachmentDefinitions from the removed target namespaces", func() {
+		client := &mocks.ControllerRutimeClient{}
+		client.On("Delete", mock.Anything, mock.MatchedBy(func(obj *netattdefv1.NetworkAttachmentDefinition) bool {
+			return obj.Name == "macvlan" && obj.Namespace == "b"
+		})).Return(nil).Once()
+		client.On("Delete", mock.Anything, mock.MatchedBy(func(obj *netattdefv1.NetworkAttachmentDefinition) bool {
+			return obj.Name == "macvlan" && obj.Namespace == "c"
+		})).Return(k8serrors.NewNotFound(schema.GroupResource{Resource: "network-attachment-definitions"}, "macvlan"))
+		macvlanState.client = client
+
+		cr.SetAnnotations(map[string]string{lastTargetNamespacesAnnot: "a,b,c"})
+		cr.Spec.TargetNamespaces = []string{"a"}
+		objs, err := macvlanState.getManifestObjects(cr)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(macvlanState.handleTargetNamespacesChange(cr, objs)).To(Succeed())
+		client.AssertNumberOfCalls(GinkgoT(), "Delete", 2)
+	})
+
+	It("Should render bond interface as the master", func() {
+		cr.Spec.Master = "bond0.100"
+		cr.Spec.Mode = "bridge"
+		objs, err := macvlanState.getManifestObjects(cr)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(objs).To(HaveLen(1))
+		config, _, err := unstructured.NestedString(objs[0].Object, "spec", "config")
+		Expect(err).NotTo(HaveOccurred())
+		var cniConfig map[string]interface{}
+		Expect(json.Unmarshal([]byte(config), &cniConfig)).To(Succeed())
+		Expect(cniConfig).To(HaveKeyWithValue("master", "bond0.100"))
+		Expect(cniConfig).To(HaveKeyWithValue("mode", "bridge"))
+	})
+
+	It("Should render the default or configured CNI version", func() {
+		cniVersion := func() interface{} {
+			objs, err := macvlanState.getManifestObjects(cr)
+			Expect(err).NotTo(HaveOccurred())
+			config, _, err := unstructured.NestedString(objs[0].Object, "spec", "config")
+			Expect(err).NotTo(HaveOccurred())
+			var cniConfig map[string]interface{}
+			Expect(json.Unmarshal([]byte(config), &cniConfig)).To(Succeed())
+			return cniConfig["cniVersion"]
+		}
+		Expect(cniVersion()).To(Equal(mellanoxv1alpha1.DefaultCNIVersion))
+		cr.Spec.CNIVersion = "1.0.0"
+		Expect(cniVersion()).To(Equal("1.0.0"))
+	})
+
+	It("Should fail to render unsupported CNI version", func() {
+		cr.Spec.CNIVersion = "0.2.0"
+		_, err := macvlanState.getManifestObjects(cr)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should add the default routes to the IPAM configuration if the network is the default route", func() {
+		ipamRoutes := func() interface{} {
+			objs, err := macvlanState.getManifestObjects(cr)
+			Expect(err).NotTo(HaveOccurred())
+			config, _, err := unstructured.NestedString(objs[0].Object, "spec", "config")
+			Expect(err).NotTo(HaveOccurred())
+			var cniConfig map[string]interface{}
+			Expect(json.Unmarshal([]byte(config), &cniConfig)).To(Succeed())
+			return cniConfig["ipam"].(map[string]interface{})["routes"]
+		}
+		cr.Spec.IPAM = `{"type": "whereabouts", "range": "192.168.2.0/24", "gateway": "192.168.2.1"}`
+		Expect(ipamRoutes()).To(BeNil())
+
+		defaultRoute := true
+		cr.Spec.DefaultRoute = &defaultRoute
+		Expect(ipamRoutes()).To(Equal([]interface{}{map[string]interface{}{"dst": "0.0.0.0/0"}}))
+
+		cr.Spec.IPAM = `{"type": "host-local", "ranges": [[{"subnet": "fd00::/64"}], [{"subnet": "10.0.0.0/24"}]],
+			"routes": [{"dst": "10.1.0.0/16"}, {"dst": "0.0.0.0/0", "gw": "10.0.0.254"}]}`
+		Expect(ipamRoutes()).To(Equal([]interface{}{
+			map[string]interface{}{"dst": "10.1.0.0/16"},
+			map[string]interface{}{"dst": "0.0.0.0/0", "gw": "10.0.0.254"},
+			map[string]interface{}{"dst": "::/0"},
+		}))
+	})
+
+	It("Should reject the IPAM configuration with a default route if the network is not the default route", func() {
+		defaultRoute := false
+		cr.Spec.DefaultRoute = &defaultRoute
+		cr.Spec.IPAM = `{"type": "whereabouts", "range": "192.168.2.0/24", "routes": [{"dst": "10.1.0.0/16"}]}`
+		_, err := macvlanState.getManifestObjects(cr)
+		Expect(err).NotTo(HaveOccurred())
+
+		cr.Spec.IPAM = `{"type": "whereabouts", "range": "fd00::/64", "routes": [{"dst": "::/0"}]}`
+		_, err = macvlanState.getManifestObjects(cr)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should fail to add the default routes without IPAM configuration", func() {
+		defaultRoute := true
+		cr.Spec.DefaultRoute = &defaultRoute
+		_, err := macvlanState.getManifestObjects(cr)
+		Expect(err).To(HaveOccurred())
+		cr.Spec.IPAM = "not-json"
+		_, err = macvlanState.getManifestObjects(cr)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should use default namespace if network namespace is not set", func() {
+		cr.Spec.NetworkNamespace = ""
+		cr.Spec.TargetNamespaces = []string{"a"}
+		Expect(MacvlanNetworkNamespaces(cr)).To(Equal([]string{"default", "a"}))
+	})
+})
@@ -90,6 +90,9 @@ func (s *stateNVPeer) Sync(customResource interface{}, infoCatalog InfoCatalog)
 	if err != nil {
 		return SyncStateNotReady, errors.Wrap(err, "failed to create k8s objects from manifest")
 	}
+	if !cr.Spec.NVPeerDriver.IsEnabled() {
+		return s.deleteDisabledObjs(objs)
+	}
 	if len(objs) == 0 {
 		// getManifestObjects returned no objects, this means that no objects need to be applied to the cluster
 		// as (most likely) no Mellanox/Nvidia hardware is found (No Mellanox and Nvidia labels where found).
@@ -153,13 +156,13 @@ func (s *stateNVPeer) getManifestObjects(
 
 	renderData := &nvPeerManifestRenderData{
 		CrSpec:       cr.Spec.NVPeerDriver,
-		NodeAffinity: cr.Spec.NodeAffinity,
+		NodeAffinity: excludedNodeAffinity(cr.Spec.NodeAffinity),
 		RuntimeSpec: &nvPeerRuntimeSpec{
 			runtimeSpec:    runtimeSpec{config.FromEnv().State.NetworkOperatorResourceNamespace},
 			CPUArch:        attrs[0].Attributes[nodeinfo.AttrTypeCPUArch],
 			OSName:         attrs[0].Attributes[nodeinfo.AttrTypeOSName],
 			OSVer:          attrs[0].Attributes[nodeinfo.AttrTypeOSVer],
-			UseHostOFED:    cr.Spec.OFEDDriver == nil,
+			UseHostOFED:    !isOFEDDriverDeployed(cr),
 			MaxCudaVersion: maxCudaVersionMajor,
 		},
 	}
@@ -169,6 +172,13 @@ func (s *stateNVPeer) getManifestObjects(
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to render objects")
 	}
+	// the validating webhook is optional, don't deploy the driver with a security context it can't work with
+	if err := mellanoxv1alpha1.ValidateDriverSecurityContext(cr.Spec.NVPeerDriver.SecurityContext); err != nil {
+		return nil, errors.Wrap(err, "invalid NV peer memory driver security context")
+	}
+	if err := mergeSecurityContext(objs, cr.Spec.NVPeerDriver.SecurityContext); err != nil {
+		return nil, err
+	}
 	log.V(consts.LogLevelDebug).Info("Rendered", "objects:", objs)
 	return objs, nil
 }
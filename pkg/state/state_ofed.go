@@ -41,7 +41,6 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
-	"github.com/Mellanox/network-operator/pkg/config"
 	"github.com/Mellanox/network-operator/pkg/consts"
 	"github.com/Mellanox/network-operator/pkg/nodeinfo"
 	"github.com/Mellanox/network-operator/pkg/render"
@@ -91,6 +90,23 @@ const (
 	envVarNameNoProxy    = "NO_PROXY"
 )
 
+// defaultOFEDInitSharedDir is the directory shared by the OFED init container and the driver container
+// if it is not set in NicClusterPolicy
+const defaultOFEDInitSharedDir = "/run/mellanox/ofed-init"
+
+// defaultOFEDFirmwareDir is the directory of the firmware in the driver container if it is not set in NicClusterPolicy
+const defaultOFEDFirmwareDir = "/run/mellanox/firmware"
+
+// defaultOFEDEntrypoint is the entrypoint of the Mellanox OFED driver images, the driver container starts it
+// after it loaded the node env overrides if it is not set in NicClusterPolicy
+const defaultOFEDEntrypoint = "/root/entrypoint.sh"
+
+// names of environment variables which used for OFED precompiled packages configuration
+const (
+	envVarNameUsePrecompiled        = "USE_PRECOMPILED"
+	envVarNamePrecompiledRepository = "PRECOMPILED_REPOSITORY"
+)
+
 //nolint:lll
 // CertConfigPathMap indicates standard OS specific paths for ssl keys/certificates.
 // Where Go looks for certs: https://golang.org/src/crypto/x509/root_linux.go
@@ -148,6 +164,7 @@ type ofedRuntimeSpec struct {
 	OSName         string
 	OSVer          string
 	MOFEDImageName string
+	HostNetwork    bool
 }
 
 type ofedManifestRenderData struct {
@@ -155,6 +172,12 @@ type ofedManifestRenderData struct {
 	NodeAffinity           *v1.NodeAffinity
 	RuntimeSpec            *ofedRuntimeSpec
 	AdditionalVolumeMounts additionalVolumeMounts
+	// DriverRestartedAt is the acceptance time of the last driver restart request
+	DriverRestartedAt string
+	// NodeEnvConfigMap is the ConfigMap with the per-node env overrides, empty if the overrides are disabled
+	NodeEnvConfigMap string
+	// NodeEnvEntrypoint is started by the driver container after it loaded the env overrides of its node
+	NodeEnvEntrypoint string
 }
 
 // getCertConfigPath returns the standard OS specific path for ssl keys/certificates
@@ -233,6 +256,11 @@ func (a *additionalVolumeMounts) createConfigMapVolume(configMapName string, ite
 	return v1.Volume{Name: configMapName, VolumeSource: volumeSource}
 }
 
+// isOFEDDriverDeployed returns true if the OFED driver is configured in the CR and not disabled
+func isOFEDDriverDeployed(cr *mellanoxv1alpha1.NicClusterPolicy) bool {
+	return cr.Spec.OFEDDriver != nil && cr.Spec.OFEDDriver.IsEnabled()
+}
+
 // Sync attempt to get the system to match the desired state which State represent.
 // a sync operation must be relatively short and must not block the execution thread.
 //nolint:dupl
@@ -259,10 +287,20 @@ func (s *stateOFED) Sync(customResource interface{}, infoCatalog InfoCatalog) (S
 		return SyncStateNotReady, errors.Wrap(err, "failed to handle Openshift cluster-wide proxy settings")
 	}
 
+	if cr.Spec.OFEDDriver.UsePrecompiled {
+		if cr.Spec.OFEDDriver.PrecompiledRepository == "" {
+			return SyncStateError, errors.New("precompiledRepository must be set when usePrecompiled is enabled")
+		}
+		s.setEnvForPrecompiled(cr)
+	}
+
 	objs, err := s.getManifestObjects(cr, nodeInfo)
 	if err != nil {
 		return SyncStateNotReady, errors.Wrap(err, "failed to create k8s objects from manifest")
 	}
+	if !cr.Spec.OFEDDriver.IsEnabled() {
+		return s.deleteDisabledObjs(objs)
+	}
 	if len(objs) == 0 {
 		// getManifestObjects returned no objects, this means that no objects need to be applied to the cluster
 		// as (most likely) no Mellanox hardware is found (No mellanox labels where found).
@@ -280,6 +318,10 @@ func (s *stateOFED) Sync(customResource interface{}, infoCatalog InfoCatalog) (S
 	if err != nil {
 		return SyncStateNotReady, errors.Wrap(err, "failed to create/update objects")
 	}
+	// objects are not garbage collected when the namespace of the component changes
+	if err := s.deleteObjsInOtherNamespaces(objs); err != nil {
+		return SyncStateNotReady, errors.Wrap(err, "failed to delete objects from previous namespace")
+	}
 	// Check objects status
 	syncState, err := s.getSyncState(objs)
 	if err != nil {
@@ -334,12 +376,12 @@ func (s *stateOFED) handleOpenshiftClusterWideProxyConfig(cr *mellanoxv1alpha1.N
 	return nil
 }
 
-// handleAdditionalMounts generates AdditionalVolumeMounts information for the specified ConfigMap
+// handleAdditionalMounts generates AdditionalVolumeMounts information for the specified ConfigMap,
+// the ConfigMap is read from the namespace of the OFED driver
 func (s *stateOFED) handleAdditionalMounts(
-	volMounts *additionalVolumeMounts, configMapName, destDir string) error {
+	volMounts *additionalVolumeMounts, namespace, configMapName, destDir string) error {
 	configMap := &v1.ConfigMap{}
 
-	namespace := config.FromEnv().State.NetworkOperatorResourceNamespace
 	objKey := client.ObjectKey{Namespace: namespace, Name: configMapName}
 	err := s.client.Get(context.TODO(), objKey, configMap)
 	if err != nil {
@@ -393,6 +435,19 @@ func (s *stateOFED) getManifestObjects(
 		}
 	}
 
+	if cr.Spec.OFEDDriver.InitContainer != nil && cr.Spec.OFEDDriver.InitContainer.SharedDir == "" {
+		cr.Spec.OFEDDriver.InitContainer.SharedDir = defaultOFEDInitSharedDir
+	}
+	if cr.Spec.OFEDDriver.Firmware != nil {
+		// the validating webhook is optional, don't render a firmware volume without a single source
+		if err := mellanoxv1alpha1.ValidateFirmwareSource(cr.Spec.OFEDDriver.Firmware); err != nil {
+			return nil, errors.Wrap(err, "invalid OFED driver firmware")
+		}
+		if cr.Spec.OFEDDriver.Firmware.MountPath == "" {
+			cr.Spec.OFEDDriver.Firmware.MountPath = defaultOFEDFirmwareDir
+		}
+	}
+
 	additionalVolMounts := additionalVolumeMounts{}
 	osname := attrs[0].Attributes[nodeinfo.AttrTypeOSName]
 	// set any custom ssl key/certificate configuration provided
@@ -402,7 +457,8 @@ func (s *stateOFED) getManifestObjects(
 			return nil, fmt.Errorf("failed to get destination directory for custom TLS certificates config: %v", err)
 		}
 
-		err = s.handleAdditionalMounts(&additionalVolMounts, cr.Spec.OFEDDriver.CertConfig.Name, destinationDir)
+		err = s.handleAdditionalMounts(&additionalVolMounts, OfedDriverNamespace(cr),
+			cr.Spec.OFEDDriver.CertConfig.Name, destinationDir)
 		if err != nil {
 			return nil, fmt.Errorf("failed to mount volumes for custom TLS certificates: %v", err)
 		}
@@ -415,7 +471,8 @@ func (s *stateOFED) getManifestObjects(
 			return nil, fmt.Errorf("failed to get destination directory for custom repo config: %v", err)
 		}
 
-		err = s.handleAdditionalMounts(&additionalVolMounts, cr.Spec.OFEDDriver.RepoConfig.Name, destinationDir)
+		err = s.handleAdditionalMounts(&additionalVolMounts, OfedDriverNamespace(cr),
+			cr.Spec.OFEDDriver.RepoConfig.Name, destinationDir)
 		if err != nil {
 			return nil, fmt.Errorf("failed to mount volumes for custom repositories configuration: %v", err)
 		}
@@ -425,14 +482,23 @@ func (s *stateOFED) getManifestObjects(
 	renderData := &ofedManifestRenderData{
 		CrSpec: cr.Spec.OFEDDriver,
 		RuntimeSpec: &ofedRuntimeSpec{
-			runtimeSpec:    runtimeSpec{config.FromEnv().State.NetworkOperatorResourceNamespace},
+			runtimeSpec:    runtimeSpec{OfedDriverNamespace(cr)},
 			CPUArch:        nodeAttr[nodeinfo.AttrTypeCPUArch],
 			OSName:         nodeAttr[nodeinfo.AttrTypeOSName],
 			OSVer:          nodeAttr[nodeinfo.AttrTypeOSVer],
 			MOFEDImageName: s.getMofedDriverImageName(cr, nodeAttr),
+			HostNetwork:    cr.Spec.OFEDDriver.HostNetwork == nil || *cr.Spec.OFEDDriver.HostNetwork,
 		},
-		NodeAffinity:           cr.Spec.NodeAffinity,
+		NodeAffinity:           excludedNodeAffinity(cr.Spec.NodeAffinity),
 		AdditionalVolumeMounts: additionalVolMounts,
+		DriverRestartedAt:      driverRestartedAt(cr),
+	}
+	if cr.Spec.OFEDDriver.NodeEnvOverrides {
+		renderData.NodeEnvConfigMap = consts.OfedNodeEnvConfigMapName
+		renderData.NodeEnvEntrypoint = cr.Spec.OFEDDriver.NodeEnvEntrypoint
+		if renderData.NodeEnvEntrypoint == "" {
+			renderData.NodeEnvEntrypoint = defaultOFEDEntrypoint
+		}
 	}
 	// render objects
 	log.V(consts.LogLevelDebug).Info("Rendering objects", "data:", renderData)
@@ -440,10 +506,30 @@ func (s *stateOFED) getManifestObjects(
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to render objects")
 	}
+	// the validating webhook is optional, don't deploy the driver with a security context it can't work with
+	if err := mellanoxv1alpha1.ValidateDriverSecurityContext(cr.Spec.OFEDDriver.SecurityContext); err != nil {
+		return nil, errors.Wrap(err, "invalid OFED driver security context")
+	}
+	if err := mergeSecurityContext(objs, cr.Spec.OFEDDriver.SecurityContext); err != nil {
+		return nil, err
+	}
+	if err := addTolerations(objs, cr.Spec.OFEDDriver.Tolerations); err != nil {
+		return nil, err
+	}
 	log.V(consts.LogLevelDebug).Info("Rendered", "objects:", objs)
 	return objs, nil
 }
 
+// driverRestartedAt returns the acceptance time of the last driver restart request on the NicClusterPolicy,
+// empty if there was no request or it is not accepted yet
+func driverRestartedAt(cr *mellanoxv1alpha1.NicClusterPolicy) string {
+	value := cr.GetAnnotations()[consts.RestartDriverAnnotation]
+	if _, err := time.Parse(time.RFC3339, value); err != nil {
+		return ""
+	}
+	return value
+}
+
 // getMofedDriverImageName generates MOFED driver image name based on the driver version specified in CR
 // TODO(adrianc): in Network-Operator v1.5.0, we should just use the new naming scheme
 func (s *stateOFED) getMofedDriverImageName(cr *mellanoxv1alpha1.NicClusterPolicy,
@@ -493,7 +579,7 @@ func (s *stateOFED) readOpenshiftProxyConfig() (*osconfigv1.Proxy, error) {
 func (s *stateOFED) getOrCreateTrustedCAConfigMap(cr *mellanoxv1alpha1.NicClusterPolicy) (*v1.ConfigMap, error) {
 	var (
 		cmName      = ocpTrustedCAConfigMapName
-		cmNamespace = config.FromEnv().State.NetworkOperatorResourceNamespace
+		cmNamespace = OfedDriverNamespace(cr)
 	)
 
 	configMap := &v1.ConfigMap{}
@@ -519,7 +605,8 @@ func (s *stateOFED) getOrCreateTrustedCAConfigMap(cr *mellanoxv1alpha1.NicCluste
 			Namespace: cmNamespace,
 			// apply label "config.openshift.io/inject-trusted-cabundle: true",
 			// so that cert is automatically filled/updated by Openshift
-			Labels: map[string]string{"config.openshift.io/inject-trusted-cabundle": "true"},
+			Labels:      MergeManagedLabels(map[string]string{"config.openshift.io/inject-trusted-cabundle": "true"}),
+			Annotations: MergeManagedAnnotations(nil),
 		},
 		Data: map[string]string{
 			ocpTrustedCABundleFileName: "",
@@ -592,3 +679,23 @@ func (s *stateOFED) setEnvFromClusterWideProxy(cr *mellanoxv1alpha1.NicClusterPo
 		)
 	}
 }
+
+// setEnvForPrecompiled sets environment variables which instruct the driver container to use
+// precompiled driver packages, environment variables statically configured in NicClusterPolicy have precedence
+func (s *stateOFED) setEnvForPrecompiled(cr *mellanoxv1alpha1.NicClusterPolicy) {
+	precompiledParams := [][]string{
+		{envVarNameUsePrecompiled, "true"},
+		{envVarNamePrecompiledRepository, cr.Spec.OFEDDriver.PrecompiledRepository},
+	}
+	envsFromStaticCfg := map[string]v1.EnvVar{}
+	for _, e := range cr.Spec.OFEDDriver.Env {
+		envsFromStaticCfg[e.Name] = e
+	}
+	for _, param := range precompiledParams {
+		envKey, envValue := param[0], param[1]
+		if _, exist := envsFromStaticCfg[envKey]; exist {
+			continue
+		}
+		cr.Spec.OFEDDriver.Env = append(cr.Spec.OFEDDriver.Env, v1.EnvVar{Name: envKey, Value: envValue})
+	}
+}
@@ -24,9 +24,14 @@ import (
 
 	osconfigv1 "github.com/openshift/api/config/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	"github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/config"
+	"github.com/Mellanox/network-operator/pkg/consts"
 	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+	"github.com/Mellanox/network-operator/pkg/render"
+	"github.com/Mellanox/network-operator/pkg/utils"
 )
 
 const (
@@ -37,6 +42,20 @@ const (
 	testNicPolicyNoProxy      = "no-proxy-policy"
 )
 
+type ofedNodeProvider struct {
+}
+
+func (p *ofedNodeProvider) GetNodesAttributes(filters ...nodeinfo.Filter) []nodeinfo.NodeAttributes {
+	attr := nodeinfo.NodeAttributes{
+		Name:       "test",
+		Attributes: make(map[nodeinfo.AttributeType]string),
+	}
+	attr.Attributes[nodeinfo.AttrTypeCPUArch] = "amd64"
+	attr.Attributes[nodeinfo.AttrTypeOSName] = "ubuntu"
+	attr.Attributes[nodeinfo.AttrTypeOSVer] = "20.04"
+	return []nodeinfo.NodeAttributes{attr}
+}
+
 var _ = Describe("MOFED state test", func() {
 	var stateOfed stateOFED
 
@@ -83,6 +102,26 @@ var _ = Describe("MOFED state test", func() {
 		})
 	})
 
+	Context("Precompiled packages config", func() {
+		It("Set precompiled packages environment variables", func() {
+			cr := &v1alpha1.NicClusterPolicy{
+				Spec: v1alpha1.NicClusterPolicySpec{OFEDDriver: &v1alpha1.OFEDDriverSpec{
+					UsePrecompiled:        true,
+					PrecompiledRepository: "http://repo.local/precompiled",
+					Env: []v1.EnvVar{
+						{Name: envVarNamePrecompiledRepository, Value: "http://other.local"},
+					},
+				}}}
+			stateOfed.setEnvForPrecompiled(cr)
+			crEnv := cr.Spec.OFEDDriver.Env
+			Expect(crEnv).To(HaveLen(2))
+			Expect(crEnv).To(ContainElements(
+				v1.EnvVar{Name: envVarNameUsePrecompiled, Value: "true"},
+				v1.EnvVar{Name: envVarNamePrecompiledRepository, Value: "http://other.local"},
+			))
+		})
+	})
+
 	Context("Proxy config", func() {
 		It("Set Proxy from Cluster Wide Proxy", func() {
 			cr := &v1alpha1.NicClusterPolicy{
@@ -132,4 +171,232 @@ var _ = Describe("MOFED state test", func() {
 			))
 		})
 	})
+
+	Context("Pod network settings", func() {
+		var cr *v1alpha1.NicClusterPolicy
+
+		BeforeEach(func() {
+			files, err := utils.GetFilesWithSuffix("../../manifests/stage-ofed-driver", render.ManifestFileSuffix...)
+			Expect(err).NotTo(HaveOccurred())
+			stateOfed.renderer = render.NewRenderer(files)
+			cr = &v1alpha1.NicClusterPolicy{
+				Spec: v1alpha1.NicClusterPolicySpec{OFEDDriver: &v1alpha1.OFEDDriverSpec{
+					ImageSpec: v1alpha1.ImageSpec{
+						Image:      "mofed",
+						Repository: "nvcr.io/mellanox",
+						Version:    "5.7-1.0.0.0",
+					},
+				}}}
+		})
+
+		getPodSpec := func() map[string]interface{} {
+			objs, err := stateOfed.getManifestObjects(cr, &ofedNodeProvider{})
+			Expect(err).NotTo(HaveOccurred())
+			for _, obj := range objs {
+				if obj.GetKind() == "DaemonSet" {
+					spec, _, _ := unstructured.NestedMap(obj.Object, "spec", "template", "spec")
+					return spec
+				}
+			}
+			Fail("DaemonSet is not rendered")
+			return nil
+		}
+
+		It("Should run in host network by default", func() {
+			spec := getPodSpec()
+			Expect(spec["hostNetwork"]).To(BeTrue())
+			Expect(spec).NotTo(HaveKey("dnsPolicy"))
+			Expect(spec).NotTo(HaveKey("dnsConfig"))
+		})
+
+		It("Should apply host network and DNS settings", func() {
+			hostNetwork := false
+			cr.Spec.OFEDDriver.HostNetwork = &hostNetwork
+			cr.Spec.OFEDDriver.DNSPolicy = v1.DNSNone
+			cr.Spec.OFEDDriver.DNSConfig = &v1.PodDNSConfig{
+				Nameservers: []string{"10.0.0.10"},
+				Searches:    []string{"mirror.local"},
+			}
+			spec := getPodSpec()
+			Expect(spec["hostNetwork"]).To(BeFalse())
+			Expect(spec["dnsPolicy"]).To(Equal("None"))
+			Expect(spec["dnsConfig"]).To(Equal(map[string]interface{}{
+				"nameservers": []interface{}{"10.0.0.10"},
+				"searches":    []interface{}{"mirror.local"},
+			}))
+		})
+
+		It("Should require the node not to be excluded only if the exclusion is enabled", func() {
+			Expect(getPodSpec()).NotTo(HaveKey("affinity"))
+
+			savedExcludedNodeLabel := config.FromEnv().State.ExcludedNodeLabel
+			defer func() { config.FromEnv().State.ExcludedNodeLabel = savedExcludedNodeLabel }()
+			config.FromEnv().State.ExcludedNodeLabel = "network.nvidia.com/operator.exclude"
+			terms, _, _ := unstructured.NestedSlice(getPodSpec(),
+				"affinity", "nodeAffinity", "requiredDuringSchedulingIgnoredDuringExecution", "nodeSelectorTerms")
+			Expect(terms).To(Equal([]interface{}{map[string]interface{}{"matchExpressions": []interface{}{
+				map[string]interface{}{
+					"key": "network.nvidia.com/operator.exclude", "operator": "NotIn", "values": []interface{}{"true"}},
+			}}}))
+		})
+
+		It("Should not render init container by default", func() {
+			Expect(getPodSpec()).NotTo(HaveKey("initContainers"))
+		})
+
+		It("Should render init container sharing a directory with the driver container", func() {
+			cr.Spec.OFEDDriver.Env = []v1.EnvVar{{Name: "CREATE_IFNAMES_UDEV", Value: "true"}}
+			cr.Spec.OFEDDriver.InitContainer = &v1alpha1.OFEDInitContainerSpec{
+				Image:   "nvcr.io/mellanox/mofed-builder:5.7-ubuntu20.04-amd64",
+				Command: []string{"/build.sh"},
+				Env:     []v1.EnvVar{{Name: "BUILD_JOBS", Value: "4"}},
+			}
+			spec := getPodSpec()
+
+			initContainers, _, _ := unstructured.NestedSlice(spec, "initContainers")
+			Expect(initContainers).To(HaveLen(1))
+			initContainer := initContainers[0].(map[string]interface{})
+			Expect(initContainer["image"]).To(Equal("nvcr.io/mellanox/mofed-builder:5.7-ubuntu20.04-amd64"))
+			Expect(initContainer["imagePullPolicy"]).To(Equal("IfNotPresent"))
+			Expect(initContainer["command"]).To(Equal([]interface{}{"/build.sh"}))
+			Expect(initContainer["env"]).To(ConsistOf(
+				map[string]interface{}{"name": "OFED_INIT_SHARED_DIR", "value": defaultOFEDInitSharedDir},
+				map[string]interface{}{"name": "CREATE_IFNAMES_UDEV", "value": "true"},
+				map[string]interface{}{"name": "BUILD_JOBS", "value": "4"},
+			))
+			Expect(initContainer["volumeMounts"]).To(ContainElement(
+				map[string]interface{}{"name": "ofed-init-shared", "mountPath": defaultOFEDInitSharedDir}))
+
+			containers, _, _ := unstructured.NestedSlice(spec, "containers")
+			driverContainer := containers[0].(map[string]interface{})
+			Expect(driverContainer["env"]).To(ContainElement(
+				map[string]interface{}{"name": "OFED_INIT_SHARED_DIR", "value": defaultOFEDInitSharedDir}))
+			Expect(driverContainer["volumeMounts"]).To(ContainElement(
+				map[string]interface{}{"name": "ofed-init-shared", "mountPath": defaultOFEDInitSharedDir}))
+			Expect(spec["volumes"]).To(ContainElement(
+				map[string]interface{}{"name": "ofed-init-shared", "emptyDir": map[string]interface{}{}}))
+		})
+
+		It("Should render the build gate before the init container if builds are limited", func() {
+			cr.Spec.OFEDDriver.MaxConcurrentBuilds = 2
+			cr.Spec.OFEDDriver.InitContainer = &v1alpha1.OFEDInitContainerSpec{
+				Image: "nvcr.io/mellanox/mofed-builder:5.7-ubuntu20.04-amd64",
+			}
+			spec := getPodSpec()
+
+			initContainers, _, _ := unstructured.NestedSlice(spec, "initContainers")
+			Expect(initContainers).To(HaveLen(2))
+			gate := initContainers[0].(map[string]interface{})
+			Expect(gate["name"]).To(Equal(consts.OfedBuildGateContainerName))
+			Expect(gate["command"]).To(ContainElement(ContainSubstring(consts.OfedBuildSlotAnnotation)))
+			Expect(gate["volumeMounts"]).To(ContainElement(map[string]interface{}{
+				"name": "build-slot", "mountPath": "/run/mellanox/build-slot", "readOnly": true}))
+			Expect(initContainers[1].(map[string]interface{})["name"]).To(Equal("mofed-init-container"))
+			Expect(spec["volumes"]).To(ContainElement(HaveKeyWithValue("name", "build-slot")))
+
+			cr.Spec.OFEDDriver.InitContainer = nil
+			initContainers, _, _ = unstructured.NestedSlice(getPodSpec(), "initContainers")
+			Expect(initContainers).To(HaveLen(1))
+		})
+
+		It("Should set the accepted driver restart time on the pod template", func() {
+			getPodAnnotations := func() map[string]string {
+				objs, err := stateOfed.getManifestObjects(cr, &ofedNodeProvider{})
+				Expect(err).NotTo(HaveOccurred())
+				for _, obj := range objs {
+					if obj.GetKind() == "DaemonSet" {
+						annotations, _, _ := unstructured.NestedStringMap(
+							obj.Object, "spec", "template", "metadata", "annotations")
+						return annotations
+					}
+				}
+				Fail("DaemonSet is not rendered")
+				return nil
+			}
+			Expect(getPodAnnotations()).NotTo(HaveKey(consts.RestartDriverAnnotation))
+
+			cr.Annotations = map[string]string{consts.RestartDriverAnnotation: "true"}
+			Expect(getPodAnnotations()).NotTo(HaveKey(consts.RestartDriverAnnotation))
+
+			cr.Annotations[consts.RestartDriverAnnotation] = "2022-10-01T10:00:00Z"
+			Expect(getPodAnnotations()).To(HaveKeyWithValue(consts.RestartDriverAnnotation, "2022-10-01T10:00:00Z"))
+		})
+
+		It("Should load the node env overrides before the entrypoint if enabled", func() {
+			spec := getPodSpec()
+			Expect(spec["volumes"]).NotTo(ContainElement(HaveKeyWithValue("name", "ofed-node-env")))
+			containers, _, _ := unstructured.NestedSlice(spec, "containers")
+			Expect(containers[0]).NotTo(HaveKey("command"))
+
+			cr.Spec.OFEDDriver.NodeEnvOverrides = true
+			spec = getPodSpec()
+			containers, _, _ = unstructured.NestedSlice(spec, "containers")
+			driverContainer := containers[0].(map[string]interface{})
+			Expect(driverContainer["command"]).To(Equal([]interface{}{"sh", "-c"}))
+			args, _, _ := unstructured.NestedStringSlice(driverContainer, "args")
+			Expect(args).To(HaveLen(1))
+			Expect(args[0]).To(ContainSubstring(`. "$OFED_NODE_ENV_FILE"`))
+			Expect(args[0]).To(HaveSuffix("exec " + defaultOFEDEntrypoint + "\n"))
+			Expect(driverContainer["env"]).To(ContainElement(map[string]interface{}{
+				"name": "OFED_NODE_ENV_FILE", "value": "/run/mellanox/ofed-node-env/$(NODE_NAME)"}))
+			Expect(driverContainer["volumeMounts"]).To(ContainElement(map[string]interface{}{
+				"name": "ofed-node-env", "mountPath": "/run/mellanox/ofed-node-env", "readOnly": true}))
+			Expect(spec["volumes"]).To(ContainElement(map[string]interface{}{
+				"name":      "ofed-node-env",
+				"configMap": map[string]interface{}{"name": consts.OfedNodeEnvConfigMapName, "optional": true}}))
+
+			cr.Spec.OFEDDriver.NodeEnvEntrypoint = "/usr/local/bin/driver-entrypoint"
+			containers, _, _ = unstructured.NestedSlice(getPodSpec(), "containers")
+			args, _, _ = unstructured.NestedStringSlice(containers[0].(map[string]interface{}), "args")
+			Expect(args[0]).To(HaveSuffix("exec /usr/local/bin/driver-entrypoint\n"))
+		})
+
+		It("Should mount the firmware source into the firmware init container", func() {
+			spec := getPodSpec()
+			Expect(spec["initContainers"]).To(BeNil())
+
+			cr.Spec.OFEDDriver.Firmware = &v1alpha1.OFEDFirmwareSpec{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "firmware", ReadOnly: true},
+				Update:                true,
+			}
+			spec = getPodSpec()
+			initContainers, _, _ := unstructured.NestedSlice(spec, "initContainers")
+			Expect(initContainers).To(HaveLen(1))
+			firmwareContainer := initContainers[0].(map[string]interface{})
+			Expect(firmwareContainer["name"]).To(Equal("mofed-firmware"))
+			Expect(firmwareContainer["env"]).To(ContainElements(
+				map[string]interface{}{"name": "FW_DIR", "value": defaultOFEDFirmwareDir},
+				map[string]interface{}{"name": "FW_UPDATE", "value": "true"},
+			))
+			Expect(firmwareContainer["volumeMounts"]).To(ContainElement(map[string]interface{}{
+				"name": "firmware", "mountPath": defaultOFEDFirmwareDir, "readOnly": true}))
+			containers, _, _ := unstructured.NestedSlice(spec, "containers")
+			driverContainer := containers[0].(map[string]interface{})
+			Expect(driverContainer["volumeMounts"]).NotTo(ContainElement(HaveKeyWithValue("name", "firmware")))
+			Expect(spec["volumes"]).To(ContainElement(map[string]interface{}{
+				"name":                  "firmware",
+				"persistentVolumeClaim": map[string]interface{}{"claimName": "firmware", "readOnly": true}}))
+
+			cr.Spec.OFEDDriver.Firmware.HostPath = &v1.HostPathVolumeSource{Path: "/opt/firmware"}
+			_, err := stateOfed.getManifestObjects(cr, &ofedNodeProvider{})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Should render the objects and RBAC subjects in the configured namespace", func() {
+			cr.Spec.OFEDDriver.Namespace = "ofed-driver"
+			objs, err := stateOfed.getManifestObjects(cr, &ofedNodeProvider{})
+			Expect(err).NotTo(HaveOccurred())
+			for _, obj := range objs {
+				if obj.GetKind() == "SecurityContextConstraints" {
+					Expect(obj.Object["users"]).To(ContainElement("system:serviceaccount:ofed-driver:ofed-driver"))
+					continue
+				}
+				Expect(obj.GetNamespace()).To(Equal("ofed-driver"), obj.GetKind())
+				if obj.GetKind() == "RoleBinding" {
+					subjects, _, _ := unstructured.NestedSlice(obj.Object, "subjects")
+					Expect(subjects[0]).To(HaveKeyWithValue("namespace", "ofed-driver"))
+				}
+			}
+		})
+	})
 })
@@ -17,6 +17,8 @@ limitations under the License.
 package state //nolint:dupl
 
 import (
+	"encoding/json"
+
 	"github.com/pkg/errors"
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
@@ -61,7 +63,8 @@ type sharedDpRuntimeSpec struct {
 	OSName string
 }
 type sharedDpManifestRenderData struct {
-	CrSpec              *mellanoxv1alpha1.DevicePluginSpec
+	CrSpec              *mellanoxv1alpha1.RdmaSharedDevicePluginSpec
+	Config              string
 	NodeAffinity        *v1.NodeAffinity
 	DeployInitContainer bool
 	RuntimeSpec         *sharedDpRuntimeSpec
@@ -81,6 +84,14 @@ func (s *stateSharedDp) Sync(customResource interface{}, infoCatalog InfoCatalog
 		log.V(consts.LogLevelInfo).Info("Device plugin spec in CR is nil, no action required")
 		return SyncStateIgnore, nil
 	}
+	if err := validateRdmaSharedDevicePools(cr.Spec.RdmaSharedDevicePlugin); err != nil {
+		return SyncStateError, err
+	}
+	if dp := cr.Spec.RdmaSharedDevicePlugin; dp.IsEnabled() {
+		if err := unresolvedConfigRefError("rdmaSharedDevicePlugin", dp.ConfigRef, dp.Config); err != nil {
+			return SyncStateError, err
+		}
+	}
 	// Fill ManifestRenderData and render objects
 	nodeInfo := infoCatalog.GetNodeInfoProvider()
 	if nodeInfo == nil {
@@ -91,9 +102,16 @@ func (s *stateSharedDp) Sync(customResource interface{}, infoCatalog InfoCatalog
 	if err != nil {
 		return SyncStateNotReady, errors.Wrap(err, "failed to create k8s objects from manifest")
 	}
+	if !cr.Spec.RdmaSharedDevicePlugin.IsEnabled() {
+		return s.deleteDisabledObjs(objs)
+	}
 	if len(objs) == 0 {
 		return SyncStateNotReady, nil
 	}
+	held, err := s.devicePluginRolloutHeld(objs, cr.Spec.RdmaSharedDevicePlugin.MinDriverReadyNodes, nodeInfo)
+	if err != nil || held {
+		return SyncStateNotReady, err
+	}
 
 	// Create objects if they dont exist, Update objects if they do exist
 	err = s.createOrUpdateObjs(func(obj *unstructured.Unstructured) error {
@@ -138,10 +156,18 @@ func (s *stateSharedDp) getManifestObjects(
 		return nil, err
 	}
 
+	dpConfig, err := rdmaSharedDpConfig(cr.Spec.RdmaSharedDevicePlugin)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeAffinity := excludedNodeAffinity(
+		driverReadyNodeAffinity(cr.Spec.NodeAffinity, cr.Spec.RdmaSharedDevicePlugin.DriverReadyAffinity))
 	renderData := &sharedDpManifestRenderData{
 		CrSpec:              cr.Spec.RdmaSharedDevicePlugin,
-		NodeAffinity:        cr.Spec.NodeAffinity,
-		DeployInitContainer: cr.Spec.OFEDDriver != nil,
+		Config:              dpConfig,
+		NodeAffinity:        nodeAffinity,
+		DeployInitContainer: isOFEDDriverDeployed(cr),
 		RuntimeSpec: &sharedDpRuntimeSpec{
 			runtimeSpec: runtimeSpec{config.FromEnv().State.NetworkOperatorResourceNamespace},
 			OSName:      attrs[0].Attributes[nodeinfo.AttrTypeOSName],
@@ -153,6 +179,93 @@ func (s *stateSharedDp) getManifestObjects(
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to render objects")
 	}
+	if err := mergeSecurityContext(objs, cr.Spec.RdmaSharedDevicePlugin.SecurityContext); err != nil {
+		return nil, err
+	}
+	if err := addContainerEnv(objs, cr.Spec.RdmaSharedDevicePlugin.Env); err != nil {
+		return nil, err
+	}
+	if err := addInitContainers(objs, cr.Spec.RdmaSharedDevicePlugin.AdditionalInitContainers); err != nil {
+		return nil, err
+	}
+	tolerations := devicePluginTolerations(cr, s.name, cr.Spec.RdmaSharedDevicePlugin.Tolerations,
+		cr.Spec.RdmaSharedDevicePlugin.InheritDriverTolerations)
+	if err := addTolerations(objs, tolerations); err != nil {
+		return nil, err
+	}
+	if err := setUpdateStrategy(objs, cr.Spec.RdmaSharedDevicePlugin.UpdateStrategy); err != nil {
+		return nil, err
+	}
+	// restart device plugin pods when the configuration changes
+	if err := setConfigHashAnnotation(objs); err != nil {
+		return nil, err
+	}
 	log.V(consts.LogLevelDebug).Info("Rendered", "objects:", objs)
 	return objs, nil
 }
+
+// rdmaSharedDpConfigList is the configuration file format of the RDMA shared device plugin
+type rdmaSharedDpConfigList struct {
+	ConfigList []rdmaSharedDpResourceConfig `json:"configList"`
+}
+
+type rdmaSharedDpResourceConfig struct {
+	ResourceName string                                         `json:"resourceName"`
+	RdmaHcaMax   int                                            `json:"rdmaHcaMax"`
+	Selectors    mellanoxv1alpha1.RdmaSharedDevicePoolSelectors `json:"selectors"`
+}
+
+// defaultRdmaHcaMax is used for resource pools which don't set rdmaHcaMax
+const defaultRdmaHcaMax = 1000
+
+// validateRdmaSharedDevicePools checks that resource pools are not combined with a raw configuration
+// and that the pool names are unique
+func validateRdmaSharedDevicePools(spec *mellanoxv1alpha1.RdmaSharedDevicePluginSpec) error {
+	if len(spec.ResourcePools) == 0 {
+		return nil
+	}
+	if spec.Config != "" {
+		return errors.New("config and resourcePools of rdmaSharedDevicePlugin are mutually exclusive")
+	}
+	names := make(map[string]bool, len(spec.ResourcePools))
+	for i := range spec.ResourcePools {
+		name := spec.ResourcePools[i].Name
+		if name == "" {
+			return errors.New("name of rdmaSharedDevicePlugin resource pool must be set")
+		}
+		if names[name] {
+			return errors.Errorf("duplicate rdmaSharedDevicePlugin resource pool name %q", name)
+		}
+		names[name] = true
+	}
+	return nil
+}
+
+// rdmaSharedDpConfig returns the RDMA shared device plugin configuration,
+// the configuration is generated from the resource pools if they are set
+func rdmaSharedDpConfig(spec *mellanoxv1alpha1.RdmaSharedDevicePluginSpec) (string, error) {
+	if len(spec.ResourcePools) == 0 {
+		return spec.Config, nil
+	}
+	if err := validateRdmaSharedDevicePools(spec); err != nil {
+		return "", err
+	}
+	config := rdmaSharedDpConfigList{ConfigList: make([]rdmaSharedDpResourceConfig, 0, len(spec.ResourcePools))}
+	for i := range spec.ResourcePools {
+		pool := &spec.ResourcePools[i]
+		rdmaHcaMax := pool.RdmaHcaMax
+		if rdmaHcaMax == 0 {
+			rdmaHcaMax = defaultRdmaHcaMax
+		}
+		config.ConfigList = append(config.ConfigList, rdmaSharedDpResourceConfig{
+			ResourceName: pool.Name,
+			RdmaHcaMax:   rdmaHcaMax,
+			Selectors:    pool.Selectors,
+		})
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal RDMA shared device plugin config")
+	}
+	return string(data), nil
+}
@@ -0,0 +1,265 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/stretchr/testify/mock"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/config"
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+	"github.com/Mellanox/network-operator/pkg/render"
+	"github.com/Mellanox/network-operator/pkg/testing/mocks"
+	"github.com/Mellanox/network-operator/pkg/utils"
+)
+
+var _ = Describe("RDMA Shared Device Plugin State tests", func() {
+	var (
+		sharedDpState stateSharedDp
+		cr            *mellanoxv1alpha1.NicClusterPolicy
+	)
+
+	BeforeEach(func() {
+		client := mocks.ControllerRutimeClient{}
+		files, err := utils.GetFilesWithSuffix("../../manifests/stage-rdma-device-plugin", render.ManifestFileSuffix...)
+		Expect(err).NotTo(HaveOccurred())
+		sharedDpState = stateSharedDp{
+			stateSkel: stateSkel{
+				name:        "state-RDMA-device-plugin",
+				description: "RDMA shared device plugin deployed in the cluster",
+				client:      &client,
+				scheme:      runtime.NewScheme(),
+				renderer:    render.NewRenderer(files),
+			},
+		}
+		cr = &mellanoxv1alpha1.NicClusterPolicy{}
+		cr.Spec.RdmaSharedDevicePlugin = &mellanoxv1alpha1.RdmaSharedDevicePluginSpec{
+			ImageSpec: mellanoxv1alpha1.ImageSpec{
+				Image:      "k8s-rdma-shared-dev-plugin",
+				Repository: "nvcr.io/nvidia/cloud-native",
+				Version:    "v1.3.2",
+			},
+		}
+	})
+
+	getConfig := func() string {
+		objs, err := sharedDpState.getManifestObjects(cr, &ofedNodeProvider{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(objs[0].GetKind()).To(Equal("ConfigMap"))
+		config, _, _ := unstructured.NestedString(objs[0].Object, "data", "config.json")
+		return config
+	}
+
+	It("Should render configuration from config", func() {
+		cr.Spec.RdmaSharedDevicePlugin.Config = `{"configList": [{"resourceName": "rdma_shared_device_a"}]}`
+		Expect(getConfig()).To(Equal(cr.Spec.RdmaSharedDevicePlugin.Config))
+	})
+
+	It("Should render configuration covering all resource pools", func() {
+		cr.Spec.RdmaSharedDevicePlugin.ResourcePools = []mellanoxv1alpha1.RdmaSharedDevicePoolSpec{
+			{
+				Name:      "rdma_shared_device_a",
+				Selectors: mellanoxv1alpha1.RdmaSharedDevicePoolSelectors{IfNames: []string{"ens1f0"}},
+			},
+			{
+				Name:       "rdma_shared_device_b",
+				RdmaHcaMax: 63,
+				Selectors:  mellanoxv1alpha1.RdmaSharedDevicePoolSelectors{Vendors: []string{"15b3"}},
+			},
+		}
+		Expect(getConfig()).To(MatchJSON(`{"configList": [
+			{"resourceName": "rdma_shared_device_a", "rdmaHcaMax": 1000, "selectors": {"ifNames": ["ens1f0"]}},
+			{"resourceName": "rdma_shared_device_b", "rdmaHcaMax": 63, "selectors": {"vendors": ["15b3"]}}]}`))
+	})
+
+	It("Should reject duplicate resource pool names", func() {
+		cr.Spec.RdmaSharedDevicePlugin.ResourcePools = []mellanoxv1alpha1.RdmaSharedDevicePoolSpec{
+			{Name: "rdma_shared_device_a"},
+			{Name: "rdma_shared_device_a"},
+		}
+		Expect(validateRdmaSharedDevicePools(cr.Spec.RdmaSharedDevicePlugin)).NotTo(Succeed())
+		_, err := sharedDpState.getManifestObjects(cr, &ofedNodeProvider{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should delete objects of the disabled device plugin", func() {
+		objs, err := sharedDpState.getManifestObjects(cr, &ofedNodeProvider{})
+		Expect(err).NotTo(HaveOccurred())
+		client := &mocks.ControllerRutimeClient{}
+		client.On("Delete", mock.Anything, mock.Anything).Return(
+			k8serrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, "rdma-devices")).Once()
+		client.On("Delete", mock.Anything, mock.Anything).Return(nil)
+		sharedDpState.client = client
+		catalog := NewInfoCatalog()
+		catalog.Add(InfoTypeNodeInfo, &ofedNodeProvider{})
+
+		enabled := false
+		cr.Spec.RdmaSharedDevicePlugin.Enabled = &enabled
+		syncState, err := sharedDpState.Sync(cr, catalog)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(syncState).To(Equal(SyncState(SyncStateDisabled)))
+		client.AssertNumberOfCalls(GinkgoT(), "Delete", len(objs))
+	})
+
+	It("Should fail without touching the objects if the configRef is not resolved", func() {
+		client := &mocks.ControllerRutimeClient{}
+		sharedDpState.client = client
+		catalog := NewInfoCatalog()
+		catalog.Add(InfoTypeNodeInfo, &ofedNodeProvider{})
+
+		cr.Spec.RdmaSharedDevicePlugin.ConfigRef = "missing"
+		syncState, err := sharedDpState.Sync(cr, catalog)
+		Expect(err).To(MatchError("DevicePluginConfig missing referenced by rdmaSharedDevicePlugin " +
+			"doesn't exist or has no config"))
+		Expect(syncState).To(Equal(SyncState(SyncStateError)))
+		// the running device plugin keeps its objects
+		Expect(client.Calls).To(BeEmpty())
+	})
+
+	It("Should reject resource pools combined with config", func() {
+		cr.Spec.RdmaSharedDevicePlugin.Config = `{"configList": []}`
+		cr.Spec.RdmaSharedDevicePlugin.ResourcePools = []mellanoxv1alpha1.RdmaSharedDevicePoolSpec{
+			{Name: "rdma_shared_device_a"},
+		}
+		Expect(validateRdmaSharedDevicePools(cr.Spec.RdmaSharedDevicePlugin)).NotTo(Succeed())
+	})
+
+	Context("Driver ready affinity", func() {
+		const excludedNodeLabel = "network.nvidia.com/operator.exclude"
+		driverReady := v1.NodeSelectorRequirement{
+			Key: nodeinfo.NodeLabelWaitOFED, Operator: v1.NodeSelectorOpIn, Values: []string{"false"}}
+		notExcluded := v1.NodeSelectorRequirement{
+			Key: excludedNodeLabel, Operator: v1.NodeSelectorOpNotIn, Values: []string{"true"}}
+		var savedExcludedNodeLabel string
+
+		BeforeEach(func() {
+			savedExcludedNodeLabel = config.FromEnv().State.ExcludedNodeLabel
+			config.FromEnv().State.ExcludedNodeLabel = excludedNodeLabel
+		})
+
+		AfterEach(func() {
+			config.FromEnv().State.ExcludedNodeLabel = savedExcludedNodeLabel
+		})
+
+		getNodeAffinity := func() *v1.NodeAffinity {
+			objs, err := sharedDpState.getManifestObjects(cr, &ofedNodeProvider{})
+			Expect(err).NotTo(HaveOccurred())
+			for _, obj := range objs {
+				if obj.GetKind() != "DaemonSet" {
+					continue
+				}
+				ds := &appsv1.DaemonSet{}
+				Expect(runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, ds)).To(Succeed())
+				if ds.Spec.Template.Spec.Affinity == nil {
+					return nil
+				}
+				return ds.Spec.Template.Spec.Affinity.NodeAffinity
+			}
+			Fail("device plugin DaemonSet is not rendered")
+			return nil
+		}
+
+		It("Should require the ready driver by default", func() {
+			affinity := getNodeAffinity()
+			Expect(affinity).NotTo(BeNil())
+			Expect(affinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms).To(Equal(
+				[]v1.NodeSelectorTerm{{MatchExpressions: []v1.NodeSelectorRequirement{driverReady, notExcluded}}}))
+		})
+
+		It("Should add the requirement to every term of the policy node affinity", func() {
+			zoneA := v1.NodeSelectorRequirement{Key: "zone", Operator: v1.NodeSelectorOpIn, Values: []string{"a"}}
+			zoneB := v1.NodeSelectorRequirement{Key: "zone", Operator: v1.NodeSelectorOpIn, Values: []string{"b"}}
+			cr.Spec.NodeAffinity = &v1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
+					NodeSelectorTerms: []v1.NodeSelectorTerm{
+						{MatchExpressions: []v1.NodeSelectorRequirement{zoneA}},
+						{MatchExpressions: []v1.NodeSelectorRequirement{zoneB}},
+					},
+				},
+			}
+			affinity := getNodeAffinity()
+			Expect(affinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms).To(Equal(
+				[]v1.NodeSelectorTerm{
+					{MatchExpressions: []v1.NodeSelectorRequirement{zoneA, driverReady, notExcluded}},
+					{MatchExpressions: []v1.NodeSelectorRequirement{zoneB, driverReady, notExcluded}},
+				}))
+			// the policy itself is not modified
+			Expect(cr.Spec.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].
+				MatchExpressions).To(HaveLen(1))
+		})
+
+		It("Should not require the ready driver if disabled", func() {
+			disabled := false
+			cr.Spec.RdmaSharedDevicePlugin.DriverReadyAffinity = &disabled
+			Expect(getNodeAffinity().RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms).To(Equal(
+				[]v1.NodeSelectorTerm{{MatchExpressions: []v1.NodeSelectorRequirement{notExcluded}}}))
+		})
+	})
+
+	Context("Additional init containers", func() {
+		BeforeEach(func() {
+			cr.Spec.OFEDDriver = &mellanoxv1alpha1.OFEDDriverSpec{
+				ImageSpec: mellanoxv1alpha1.ImageSpec{Image: "mofed", Repository: "nvcr.io/nvidia/mellanox", Version: "5.6"},
+			}
+		})
+
+		getInitContainerNames := func() []string {
+			objs, err := sharedDpState.getManifestObjects(cr, &ofedNodeProvider{})
+			Expect(err).NotTo(HaveOccurred())
+			var names []string
+			for _, obj := range objs {
+				if obj.GetKind() != "DaemonSet" {
+					continue
+				}
+				initContainers, _, _ := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", "initContainers")
+				for _, container := range initContainers {
+					names = append(names, container.(map[string]interface{})["name"].(string))
+				}
+			}
+			return names
+		}
+
+		It("Should add init containers after the init containers of the operator", func() {
+			cr.Spec.RdmaSharedDevicePlugin.AdditionalInitContainers = []v1.Container{
+				{Name: "wait-for-device", Image: "busybox",
+					Command: []string{"sh", "-c", "until [ -e /dev/infiniband ]; do sleep 5; done"}},
+			}
+			Expect(getInitContainerNames()).To(Equal([]string{"ofed-driver-validation", "wait-for-device"}))
+		})
+
+		It("Should reject init containers which collide with the containers of the operator", func() {
+			for _, name := range []string{"ofed-driver-validation", "rdma-shared-dp"} {
+				cr.Spec.RdmaSharedDevicePlugin.AdditionalInitContainers = []v1.Container{{Name: name, Image: "busybox"}}
+				_, err := sharedDpState.getManifestObjects(cr, &ofedNodeProvider{})
+				Expect(err).To(HaveOccurred())
+			}
+			cr.Spec.RdmaSharedDevicePlugin.AdditionalInitContainers = []v1.Container{
+				{Name: "wait", Image: "busybox"}, {Name: "wait", Image: "busybox"}}
+			_, err := sharedDpState.getManifestObjects(cr, &ofedNodeProvider{})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -24,20 +24,44 @@ import (
 	"github.com/pkg/errors"
 	appsv1 "k8s.io/api/apps/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/Mellanox/network-operator/pkg/config"
 	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/features"
 	"github.com/Mellanox/network-operator/pkg/nodeinfo"
 	"github.com/Mellanox/network-operator/pkg/render"
 )
 
+// AdoptObjectLabel marks a pre-existing object which is not managed by the operator (e.g. a DaemonSet left after
+// a manual install) as allowed to be taken over by the operator. The object is matched by the name of the rendered
+// object, and is reconciled in place instead of failing on conflicting immutable fields.
+const AdoptObjectLabel = "network.nvidia.com/operator.adopt"
+
 type runtimeSpec struct {
 	Namespace string
 }
 
+// cniRuntimeSpec is the runtime spec of the components which install CNI plugins or their configuration on the host
+type cniRuntimeSpec struct {
+	runtimeSpec
+	CniBinDir  string
+	CniConfDir string
+}
+
+func newCNIRuntimeSpec() *cniRuntimeSpec {
+	stateConfig := config.FromEnv().State
+	return &cniRuntimeSpec{
+		runtimeSpec: runtimeSpec{Namespace: stateConfig.NetworkOperatorResourceNamespace},
+		CniBinDir:   stateConfig.CniBinDir,
+		CniConfDir:  stateConfig.CniConfDir,
+	}
+}
+
 // a state skeleton intended to be embedded in structs implementing the State interface
 // it provides many of the common constructs and functionality needed to implement a state.
 type stateSkel struct {
@@ -88,6 +112,17 @@ func (s *stateSkel) updateObj(obj *unstructured.Unstructured) error {
 	// Note: Some objects may require update of the resource version
 	// TODO: using Patch preserves runtime attributes. In the future consider using patch if relevant
 	desired := obj.DeepCopy()
+	if features.Enabled(features.ServerSideApply) {
+		// fields of the object which are not rendered by the operator are kept,
+		// the fields owned by the operator are taken over from other field managers
+		desired.SetManagedFields(nil)
+		if err := s.client.Patch(context.TODO(), desired, client.Apply,
+			client.FieldOwner(consts.ManagedByLabelValue), client.ForceOwnership); err != nil {
+			return errors.Wrap(err, "failed to apply resource")
+		}
+		log.V(consts.LogLevelInfo).Info("Object applied successfully")
+		return nil
+	}
 	if err := s.client.Update(context.TODO(), desired); err != nil {
 		return errors.Wrap(err, "failed to update resource")
 	}
@@ -105,6 +140,7 @@ func (s *stateSkel) createOrUpdateObjs(
 		if err := setControllerReference(desiredObj); err != nil {
 			return errors.Wrap(err, "failed to set controller reference for object")
 		}
+		setManagedLabels(desiredObj)
 
 		err := s.createObj(desiredObj)
 		if err == nil {
@@ -134,6 +170,19 @@ func (s *stateSkel) createOrUpdateObjs(
 	return nil
 }
 
+// deleteDisabledObjs deletes the objects of a component disabled in the spec, objects which don't exist are skipped
+func (s *stateSkel) deleteDisabledObjs(objs []*unstructured.Unstructured) (SyncState, error) {
+	log.V(consts.LogLevelInfo).Info("Component is disabled in CR, deleting its objects", "State:", s.name)
+	for _, obj := range objs {
+		err := s.client.Delete(context.TODO(), obj)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return SyncStateNotReady, errors.Wrapf(err, "failed to delete %s %s of the disabled component",
+				obj.GetKind(), obj.GetName())
+		}
+	}
+	return SyncStateDisabled, nil
+}
+
 func (s *stateSkel) mergeObjects(updated, current *unstructured.Unstructured) error {
 	// Set resource version
 	// ResourceVersion must be passed unmodified back to the server.
@@ -141,6 +190,15 @@ func (s *stateSkel) mergeObjects(updated, current *unstructured.Unstructured) er
 	// when two PUT requests are specifying the resourceVersion, one of the PUTs will fail.
 	updated.SetResourceVersion(current.GetResourceVersion())
 
+	if s.isAdoptionRequired(updated, current) {
+		s.adoptObject(updated, current)
+	}
+	if isAdopted(current) {
+		if err := keepAdoptedObject(updated, current); err != nil {
+			return errors.Wrapf(err, "failed to merge adopted object %s/%s", current.GetNamespace(), current.GetName())
+		}
+	}
+
 	gvk := updated.GroupVersionKind()
 	if gvk.Group == "" && gvk.Kind == "ServiceAccount" {
 		return s.mergeServiceAccount(updated, current)
@@ -148,6 +206,83 @@ func (s *stateSkel) mergeObjects(updated, current *unstructured.Unstructured) er
 	return nil
 }
 
+// isAdoptionRequired returns true if the current object is not controlled by the owner of the updated object
+// and is explicitly marked to be adopted by the operator
+func (s *stateSkel) isAdoptionRequired(updated, current *unstructured.Unstructured) bool {
+	if !isAdopted(current) {
+		return false
+	}
+	desiredOwner := metav1.GetControllerOf(updated)
+	currentOwner := metav1.GetControllerOf(current)
+	if desiredOwner == nil || currentOwner == nil {
+		return currentOwner == nil
+	}
+	return desiredOwner.UID != currentOwner.UID
+}
+
+// isAdopted returns true if the object is marked to be adopted by the operator, the mark is kept on the object
+// after the adoption
+func isAdopted(obj *unstructured.Unstructured) bool {
+	return obj.GetLabels()[AdoptObjectLabel] == "true"
+}
+
+// adoptObject prepares the updated object to take over the current unmanaged object in place:
+// non-controller owner references of the current object are kept
+func (s *stateSkel) adoptObject(updated, current *unstructured.Unstructured) {
+	log.V(consts.LogLevelInfo).Info("Adopting existing object", "Kind:", current.GetKind(),
+		"Namespace:", current.GetNamespace(), "Name:", current.GetName())
+
+	ownerRefs := updated.GetOwnerReferences()
+	for _, ref := range current.GetOwnerReferences() {
+		if ref.Controller != nil && *ref.Controller {
+			// object can have only one controller
+			continue
+		}
+		ownerRefs = append(ownerRefs, ref)
+	}
+	updated.SetOwnerReferences(ownerRefs)
+}
+
+// keepAdoptedObject keeps the adoption mark and, for DaemonSets, the immutable selector of the current adopted
+// object on every update, the rendered selector differs from the one of the adopted object
+func keepAdoptedObject(updated, current *unstructured.Unstructured) error {
+	labels := updated.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels[AdoptObjectLabel] = "true"
+	updated.SetLabels(labels)
+
+	if updated.GetKind() != "DaemonSet" {
+		return nil
+	}
+
+	// DaemonSet selector is immutable, keep the one of the existing object and make sure
+	// pod template labels still match it
+	selector, found, err := unstructured.NestedMap(current.Object, "spec", "selector")
+	if err != nil || !found {
+		return err
+	}
+	if err := unstructured.SetNestedMap(updated.Object, selector, "spec", "selector"); err != nil {
+		return err
+	}
+	matchLabels, _, err := unstructured.NestedStringMap(selector, "matchLabels")
+	if err != nil {
+		return err
+	}
+	templateLabels, _, err := unstructured.NestedStringMap(updated.Object, "spec", "template", "metadata", "labels")
+	if err != nil {
+		return err
+	}
+	if templateLabels == nil {
+		templateLabels = make(map[string]string, len(matchLabels))
+	}
+	for k, v := range matchLabels {
+		templateLabels[k] = v
+	}
+	return unstructured.SetNestedStringMap(updated.Object, templateLabels, "spec", "template", "metadata", "labels")
+}
+
 // For Service Account, keep secrets if exists
 func (s *stateSkel) mergeServiceAccount(updated, current *unstructured.Unstructured) error {
 	curSecrets, ok, err := unstructured.NestedSlice(current.Object, "secrets")
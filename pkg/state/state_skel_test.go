@@ -0,0 +1,146 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/stretchr/testify/mock"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Mellanox/network-operator/pkg/testing/mocks"
+)
+
+func newTestDaemonSet(selectorLabel string, labels map[string]string) *unstructured.Unstructured {
+	ds := &unstructured.Unstructured{}
+	ds.SetAPIVersion("apps/v1")
+	ds.SetKind("DaemonSet")
+	ds.SetName("test-ds")
+	ds.SetNamespace("test-ns")
+	ds.SetLabels(labels)
+	_ = unstructured.SetNestedStringMap(ds.Object, map[string]string{"app": selectorLabel},
+		"spec", "selector", "matchLabels")
+	_ = unstructured.SetNestedStringMap(ds.Object, map[string]string{"app": selectorLabel},
+		"spec", "template", "metadata", "labels")
+	return ds
+}
+
+func controllerRef(uid string) metav1.OwnerReference {
+	isController := true
+	return metav1.OwnerReference{
+		APIVersion: "mellanox.com/v1alpha1",
+		Kind:       "NicClusterPolicy",
+		Name:       "nic-cluster-policy",
+		UID:        types.UID(uid),
+		Controller: &isController,
+	}
+}
+
+var _ = Describe("State skeleton tests", func() {
+	var skel stateSkel
+
+	BeforeEach(func() {
+		skel = stateSkel{}
+	})
+
+	Context("mergeObjects", func() {
+		It("Should adopt an unmanaged DaemonSet marked for adoption", func() {
+			desired := newTestDaemonSet("operator-managed", nil)
+			desired.SetOwnerReferences([]metav1.OwnerReference{controllerRef("policy-uid")})
+			current := newTestDaemonSet("manually-deployed", map[string]string{AdoptObjectLabel: "true"})
+			current.SetResourceVersion("42")
+			current.SetOwnerReferences([]metav1.OwnerReference{{Kind: "Other", Name: "other", UID: "other-uid"}})
+
+			Expect(skel.mergeObjects(desired, current)).To(Succeed())
+
+			Expect(desired.GetResourceVersion()).To(Equal("42"))
+			Expect(desired.GetOwnerReferences()).To(HaveLen(2))
+			Expect(metav1.GetControllerOf(desired).UID).To(Equal(types.UID("policy-uid")))
+			selector, _, _ := unstructured.NestedStringMap(desired.Object, "spec", "selector", "matchLabels")
+			Expect(selector).To(Equal(map[string]string{"app": "manually-deployed"}))
+			labels, _, _ := unstructured.NestedStringMap(desired.Object, "spec", "template", "metadata", "labels")
+			Expect(labels).To(HaveKeyWithValue("app", "manually-deployed"))
+		})
+		It("Should not adopt a DaemonSet which is not marked for adoption", func() {
+			desired := newTestDaemonSet("operator-managed", nil)
+			desired.SetOwnerReferences([]metav1.OwnerReference{controllerRef("policy-uid")})
+			current := newTestDaemonSet("manually-deployed", nil)
+
+			Expect(skel.mergeObjects(desired, current)).To(Succeed())
+
+			selector, _, _ := unstructured.NestedStringMap(desired.Object, "spec", "selector", "matchLabels")
+			Expect(selector).To(Equal(map[string]string{"app": "operator-managed"}))
+		})
+		It("Should not re-adopt a DaemonSet already controlled by the same owner", func() {
+			desired := newTestDaemonSet("operator-managed", nil)
+			desired.SetOwnerReferences([]metav1.OwnerReference{controllerRef("policy-uid")})
+			current := newTestDaemonSet("manually-deployed", map[string]string{AdoptObjectLabel: "true"})
+			current.SetOwnerReferences([]metav1.OwnerReference{controllerRef("policy-uid")})
+
+			Expect(skel.isAdoptionRequired(desired, current)).To(BeFalse())
+		})
+	})
+
+	Context("createOrUpdateObjs", func() {
+		It("Should keep the selector of the adopted DaemonSet on every update", func() {
+			live := newTestDaemonSet("manually-deployed", map[string]string{AdoptObjectLabel: "true"})
+			mockClient := &mocks.ControllerRutimeClient{}
+			mockClient.On("Create", mock.Anything, mock.Anything).Return(
+				k8serrors.NewAlreadyExists(schema.GroupResource{Resource: "daemonsets"}, live.GetName()))
+			mockClient.On("Get", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				live.DeepCopyInto(args.Get(2).(*unstructured.Unstructured))
+			}).Return(nil)
+			// the selector of a DaemonSet is immutable
+			mockClient.On("Update", mock.Anything, mock.Anything).Return(func(_ context.Context, obj client.Object,
+				_ ...client.UpdateOption) error {
+				updated := obj.(*unstructured.Unstructured)
+				liveSelector, _, _ := unstructured.NestedMap(live.Object, "spec", "selector")
+				selector, _, _ := unstructured.NestedMap(updated.Object, "spec", "selector")
+				if !reflect.DeepEqual(liveSelector, selector) {
+					return fmt.Errorf("field is immutable")
+				}
+				live = updated.DeepCopy()
+				return nil
+			})
+			skel.client = mockClient
+			setControllerReference := func(obj *unstructured.Unstructured) error {
+				obj.SetOwnerReferences([]metav1.OwnerReference{controllerRef("policy-uid")})
+				return nil
+			}
+
+			// adoption followed by a regular update of the adopted DaemonSet
+			for i := 0; i < 2; i++ {
+				desired := newTestDaemonSet("operator-managed", nil)
+				Expect(skel.createOrUpdateObjs(setControllerReference, []*unstructured.Unstructured{desired})).
+					To(Succeed())
+				Expect(metav1.GetControllerOf(live).UID).To(Equal(types.UID("policy-uid")))
+				Expect(live.GetLabels()).To(HaveKeyWithValue(AdoptObjectLabel, "true"))
+			}
+			mockClient.AssertNumberOfCalls(GinkgoT(), "Update", 2)
+		})
+	})
+})
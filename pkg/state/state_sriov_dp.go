@@ -83,6 +83,11 @@ func (s *stateSriovDp) Sync(customResource interface{}, infoCatalog InfoCatalog)
 		log.V(consts.LogLevelInfo).Info("Device plugin spec in CR is nil, no action required")
 		return SyncStateIgnore, nil
 	}
+	if dp := cr.Spec.SriovDevicePlugin; dp.IsEnabled() {
+		if err := unresolvedConfigRefError("sriovDevicePlugin", dp.ConfigRef, dp.Config); err != nil {
+			return SyncStateError, err
+		}
+	}
 	// Fill ManifestRenderData and render objects
 	nodeInfo := infoCatalog.GetNodeInfoProvider()
 	if nodeInfo == nil {
@@ -92,9 +97,16 @@ func (s *stateSriovDp) Sync(customResource interface{}, infoCatalog InfoCatalog)
 	if err != nil {
 		return SyncStateNotReady, errors.Wrap(err, "failed to create k8s objects from manifest")
 	}
+	if !cr.Spec.SriovDevicePlugin.IsEnabled() {
+		return s.deleteDisabledObjs(objs)
+	}
 	if len(objs) == 0 {
 		return SyncStateNotReady, nil
 	}
+	held, err := s.devicePluginRolloutHeld(objs, cr.Spec.SriovDevicePlugin.MinDriverReadyNodes, nodeInfo)
+	if err != nil || held {
+		return SyncStateNotReady, err
+	}
 
 	// Create objects if they dont exist, Update objects if they do exist
 	err = s.createOrUpdateObjs(func(obj *unstructured.Unstructured) error {
@@ -131,10 +143,12 @@ func (s *stateSriovDp) getManifestObjects(
 		return []*unstructured.Unstructured{}, nil
 	}
 
+	nodeAffinity := excludedNodeAffinity(
+		driverReadyNodeAffinity(cr.Spec.NodeAffinity, cr.Spec.SriovDevicePlugin.DriverReadyAffinity))
 	renderData := &sriovDpManifestRenderData{
 		CrSpec:              cr.Spec.SriovDevicePlugin,
-		NodeAffinity:        cr.Spec.NodeAffinity,
-		DeployInitContainer: cr.Spec.OFEDDriver != nil,
+		NodeAffinity:        nodeAffinity,
+		DeployInitContainer: isOFEDDriverDeployed(cr),
 		RuntimeSpec: &sriovDpRuntimeSpec{
 			runtimeSpec: runtimeSpec{config.FromEnv().State.NetworkOperatorResourceNamespace},
 			OSName:      attrs[0].Attributes[nodeinfo.AttrTypeOSName],
@@ -146,6 +160,27 @@ func (s *stateSriovDp) getManifestObjects(
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to render objects")
 	}
+	if err := mergeSecurityContext(objs, cr.Spec.SriovDevicePlugin.SecurityContext); err != nil {
+		return nil, err
+	}
+	if err := addContainerEnv(objs, cr.Spec.SriovDevicePlugin.Env); err != nil {
+		return nil, err
+	}
+	if err := addInitContainers(objs, cr.Spec.SriovDevicePlugin.AdditionalInitContainers); err != nil {
+		return nil, err
+	}
+	tolerations := devicePluginTolerations(cr, s.name, cr.Spec.SriovDevicePlugin.Tolerations,
+		cr.Spec.SriovDevicePlugin.InheritDriverTolerations)
+	if err := addTolerations(objs, tolerations); err != nil {
+		return nil, err
+	}
+	if err := setUpdateStrategy(objs, cr.Spec.SriovDevicePlugin.UpdateStrategy); err != nil {
+		return nil, err
+	}
+	// restart device plugin pods when the configuration changes
+	if err := setConfigHashAnnotation(objs); err != nil {
+		return nil, err
+	}
 	log.V(consts.LogLevelDebug).Info("Rendered", "objects:", objs)
 	return objs, nil
 }
@@ -19,6 +19,7 @@ package state
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -73,10 +74,26 @@ func checkRenderedDpDs(obj *unstructured.Unstructured, imageSpec *mellanoxv1alph
 	Expect(string(jsonSpec)).To(ContainSubstring(nodeAffinity))
 }
 
+// renderedPullPolicies returns image pull policies of the init containers and containers of the daemon set
+func renderedPullPolicies(obj *unstructured.Unstructured) []string {
+	var policies []string
+	for _, field := range []string{"initContainers", "containers"} {
+		containers, _, _ := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", field)
+		for _, container := range containers {
+			policy, _, _ := unstructured.NestedString(container.(map[string]interface{}), "imagePullPolicy")
+			policies = append(policies, policy)
+		}
+	}
+	return policies
+}
+
 var _ = Describe("SR-IOV Device Plugin State tests", func() {
 
 	Context("GetNodesAttributes with provide", func() {
 		It("Should Apply", func() {
+			savedExcludedNodeLabel := config.FromEnv().State.ExcludedNodeLabel
+			defer func() { config.FromEnv().State.ExcludedNodeLabel = savedExcludedNodeLabel }()
+			config.FromEnv().State.ExcludedNodeLabel = "network.nvidia.com/operator.exclude"
 			client := mocks.ControllerRutimeClient{}
 			manifestBaseDir := "../../manifests/stage-sriov-device-plugin"
 			scheme := runtime.NewScheme()
@@ -146,7 +163,39 @@ var _ = Describe("SR-IOV Device Plugin State tests", func() {
 
 			checkRenderedDpCm(objs[0], namespace, sriovConfig)
 			checkRenderedDpSA(objs[1], namespace)
-			checkRenderedDpDs(objs[2], imageSpec, nodeAffinitySpec)
+			// the device plugin additionally requires the ready OFED driver on the node, which is not excluded
+			excludedAffinitySpec := "{\"key\":\"network.nvidia.com/operator.exclude\"," +
+				"\"operator\":\"NotIn\",\"values\":[\"true\"]}"
+			driverReadyAffinitySpec := "{\"requiredDuringSchedulingIgnoredDuringExecution\":{\"nodeSelectorTerms\":" +
+				"[{\"matchExpressions\":[{\"key\":\"node-role.kubernetes.io/master\"," +
+				"\"operator\":\"DoesNotExist\"},{\"key\":\"network.nvidia.com/operator.mofed.wait\"," +
+				"\"operator\":\"In\",\"values\":[\"false\"]}," + excludedAffinitySpec + "]}]}}"
+			checkRenderedDpDs(objs[2], imageSpec, driverReadyAffinitySpec)
+
+			driverReadyAffinity := false
+			cr.Spec.SriovDevicePlugin.DriverReadyAffinity = &driverReadyAffinity
+			objs, err = sriovDpState.getManifestObjects(cr, nodeInfo)
+			Expect(err).NotTo(HaveOccurred())
+			checkRenderedDpDs(objs[2], imageSpec, strings.TrimSuffix(nodeAffinitySpec, "]}]}}")+
+				","+excludedAffinitySpec+"]}]}}")
+			cr.Spec.SriovDevicePlugin.DriverReadyAffinity = nil
+
+			priorityClassName, _, _ := unstructured.NestedString(objs[2].Object,
+				"spec", "template", "spec", "priorityClassName")
+			Expect(priorityClassName).To(Equal("system-node-critical"))
+
+			cr.Spec.SriovDevicePlugin.PriorityClassName = "custom-priority"
+			objs, err = sriovDpState.getManifestObjects(cr, nodeInfo)
+			Expect(err).NotTo(HaveOccurred())
+			priorityClassName, _, _ = unstructured.NestedString(objs[2].Object,
+				"spec", "template", "spec", "priorityClassName")
+			Expect(priorityClassName).To(Equal("custom-priority"))
+
+			Expect(renderedPullPolicies(objs[2])).To(Equal([]string{"IfNotPresent"}))
+			cr.Spec.SriovDevicePlugin.ImagePullPolicy = v1.PullAlways
+			objs, err = sriovDpState.getManifestObjects(cr, nodeInfo)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(renderedPullPolicies(objs[2])).To(Equal([]string{"Always"}))
 		})
 	})
 })
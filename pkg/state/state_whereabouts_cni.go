@@ -27,7 +27,6 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
-	"github.com/Mellanox/network-operator/pkg/config"
 	"github.com/Mellanox/network-operator/pkg/consts"
 	"github.com/Mellanox/network-operator/pkg/render"
 	"github.com/Mellanox/network-operator/pkg/utils"
@@ -58,7 +57,7 @@ type stateWhereaboutsCNI struct {
 type WhereaboutsManifestRenderData struct {
 	CrSpec       *mellanoxv1alpha1.ImageSpec
 	NodeAffinity *v1.NodeAffinity
-	RuntimeSpec  *runtimeSpec
+	RuntimeSpec  *cniRuntimeSpec
 }
 
 // Sync attempt to get the system to match the desired state which State represent.
@@ -81,6 +80,9 @@ func (s *stateWhereaboutsCNI) Sync(customResource interface{}, infoCatalog InfoC
 	if err != nil {
 		return SyncStateNotReady, errors.Wrap(err, "failed to create k8s objects from manifest")
 	}
+	if !cr.Spec.SecondaryNetwork.IpamPlugin.IsEnabled() {
+		return s.deleteDisabledObjs(objs)
+	}
 	if len(objs) == 0 {
 		return SyncStateNotReady, nil
 	}
@@ -115,9 +117,7 @@ func (s *stateWhereaboutsCNI) getManifestObjects(
 	renderData := &WhereaboutsManifestRenderData{
 		CrSpec:       cr.Spec.SecondaryNetwork.IpamPlugin,
 		NodeAffinity: cr.Spec.NodeAffinity,
-		RuntimeSpec: &runtimeSpec{
-			Namespace: config.FromEnv().State.NetworkOperatorResourceNamespace,
-		},
+		RuntimeSpec:  newCNIRuntimeSpec(),
 	}
 	// render objects
 	log.V(consts.LogLevelDebug).Info("Rendering objects", "data:", renderData)
@@ -125,6 +125,9 @@ func (s *stateWhereaboutsCNI) getManifestObjects(
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to render objects")
 	}
+	if err := mergeSecurityContext(objs, cr.Spec.SecondaryNetwork.IpamPlugin.SecurityContext); err != nil {
+		return nil, err
+	}
 	log.V(consts.LogLevelDebug).Info("Rendered", "objects:", objs)
 	return objs, nil
 }
@@ -0,0 +1,171 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+)
+
+const (
+	// names of the device plugin DaemonSets, see stage-rdma-device-plugin and stage-sriov-device-plugin manifests
+	rdmaSharedDevicePluginDaemonSet = "rdma-shared-dp-ds"
+	sriovDevicePluginDaemonSet      = "sriov-device-plugin"
+
+	// default resource prefixes of the device plugins
+	rdmaSharedDevicePluginResourcePrefix = "rdma"
+	sriovDevicePluginResourcePrefix      = "intel.com"
+)
+
+// DevicePluginDaemonSets returns names of the device plugin DaemonSets deployed for the NicClusterPolicy
+// in the operator namespace
+func DevicePluginDaemonSets(cr *mellanoxv1alpha1.NicClusterPolicy) []string {
+	var names []string
+	if cr.Spec.RdmaSharedDevicePlugin != nil {
+		names = append(names, rdmaSharedDevicePluginDaemonSet)
+	}
+	if cr.Spec.SriovDevicePlugin != nil {
+		names = append(names, sriovDevicePluginDaemonSet)
+	}
+	return names
+}
+
+// DevicePluginResources describes the extended resources advertised by a device plugin of the NicClusterPolicy
+type DevicePluginResources struct {
+	// DaemonSet is the name of the device plugin DaemonSet in the operator namespace
+	DaemonSet string
+	// ResourceNames are the names of the resources, e.g. rdma/rdma_shared_device_a
+	ResourceNames []string
+	// ResourceDriftRestartSeconds of the device plugin spec
+	ResourceDriftRestartSeconds int
+}
+
+// DevicePlugins returns the resources of each device plugin configured in the NicClusterPolicy
+func DevicePlugins(cr *mellanoxv1alpha1.NicClusterPolicy) ([]DevicePluginResources, error) {
+	var plugins []DevicePluginResources
+	if spec := cr.Spec.RdmaSharedDevicePlugin; spec != nil {
+		names, err := rdmaSharedDevicePluginResourceNames(spec)
+		if err != nil {
+			return nil, err
+		}
+		plugins = append(plugins, DevicePluginResources{DaemonSet: rdmaSharedDevicePluginDaemonSet,
+			ResourceNames: names, ResourceDriftRestartSeconds: spec.ResourceDriftRestartSeconds})
+	}
+	if spec := cr.Spec.SriovDevicePlugin; spec != nil {
+		names, err := sriovDevicePluginResourceNames(spec)
+		if err != nil {
+			return nil, err
+		}
+		plugins = append(plugins, DevicePluginResources{DaemonSet: sriovDevicePluginDaemonSet,
+			ResourceNames: names, ResourceDriftRestartSeconds: spec.ResourceDriftRestartSeconds})
+	}
+	return plugins, nil
+}
+
+// DevicePluginResourceNames returns names of the extended resources advertised by the device plugins
+// configured in the NicClusterPolicy, e.g. rdma/rdma_shared_device_a
+func DevicePluginResourceNames(cr *mellanoxv1alpha1.NicClusterPolicy) ([]string, error) {
+	plugins, err := DevicePlugins(cr)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, plugin := range plugins {
+		names = append(names, plugin.ResourceNames...)
+	}
+	return names, nil
+}
+
+// rdmaSharedDevicePluginResourceNames returns names of the resources of the RDMA shared device pools
+// and of the RDMA shared device plugin config
+func rdmaSharedDevicePluginResourceNames(spec *mellanoxv1alpha1.RdmaSharedDevicePluginSpec) ([]string, error) {
+	var names []string
+	for i := range spec.ResourcePools {
+		names = append(names, rdmaSharedDevicePluginResourcePrefix+"/"+spec.ResourcePools[i].Name)
+	}
+	if spec.Config == "" {
+		return names, nil
+	}
+	config := struct {
+		ResourcePrefix string `json:"resourcePrefix"`
+		ConfigList     []struct {
+			ResourceName string `json:"resourceName"`
+		} `json:"configList"`
+	}{}
+	if err := json.Unmarshal([]byte(spec.Config), &config); err != nil {
+		return nil, errors.Wrap(err, "failed to parse RDMA shared device plugin config")
+	}
+	prefix := config.ResourcePrefix
+	if prefix == "" {
+		prefix = rdmaSharedDevicePluginResourcePrefix
+	}
+	for _, resource := range config.ConfigList {
+		names = append(names, prefix+"/"+resource.ResourceName)
+	}
+	return names, nil
+}
+
+// sriovDevicePluginResourceNames returns names of the resources of the SR-IOV device plugin config
+func sriovDevicePluginResourceNames(spec *mellanoxv1alpha1.DevicePluginSpec) ([]string, error) {
+	if spec.Config == "" {
+		return nil, nil
+	}
+	config := struct {
+		ResourceList []struct {
+			ResourcePrefix string `json:"resourcePrefix"`
+			ResourceName   string `json:"resourceName"`
+		} `json:"resourceList"`
+	}{}
+	if err := json.Unmarshal([]byte(spec.Config), &config); err != nil {
+		return nil, errors.Wrap(err, "failed to parse SR-IOV device plugin config")
+	}
+	var names []string
+	for _, resource := range config.ResourceList {
+		prefix := resource.ResourcePrefix
+		if prefix == "" {
+			prefix = sriovDevicePluginResourcePrefix
+		}
+		names = append(names, prefix+"/"+resource.ResourceName)
+	}
+	return names, nil
+}
+
+// PodUsesResources returns true if a container of the not terminated pod requests one of the resources
+func PodUsesResources(pod *corev1.Pod, resourceNames []string) bool {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false
+	}
+	containers := make([]corev1.Container, 0, len(pod.Spec.InitContainers)+len(pod.Spec.Containers))
+	containers = append(containers, pod.Spec.InitContainers...)
+	containers = append(containers, pod.Spec.Containers...)
+	for i := range containers {
+		for _, name := range resourceNames {
+			resource := corev1.ResourceName(name)
+			if _, ok := containers[i].Resources.Limits[resource]; ok {
+				return true
+			}
+			if _, ok := containers[i].Resources.Requests[resource]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+)
+
+var _ = Describe("Teardown helpers tests", func() {
+	var cr *mellanoxv1alpha1.NicClusterPolicy
+
+	BeforeEach(func() {
+		cr = &mellanoxv1alpha1.NicClusterPolicy{}
+		cr.Spec.RdmaSharedDevicePlugin = &mellanoxv1alpha1.RdmaSharedDevicePluginSpec{
+			Config: `{"configList": [{"resourceName": "rdma_shared_device_a", "rdmaHcaMax": 1000}]}`,
+		}
+		cr.Spec.SriovDevicePlugin = &mellanoxv1alpha1.DevicePluginSpec{
+			Config: `{"resourceList": [{"resourcePrefix": "nvidia.com", "resourceName": "hostdev"},
+				{"resourceName": "sriov"}]}`,
+		}
+	})
+
+	It("Should return device plugin DaemonSets", func() {
+		Expect(DevicePluginDaemonSets(cr)).To(Equal([]string{"rdma-shared-dp-ds", "sriov-device-plugin"}))
+		Expect(DevicePluginDaemonSets(&mellanoxv1alpha1.NicClusterPolicy{})).To(BeEmpty())
+	})
+
+	It("Should return device plugin resource names", func() {
+		names, err := DevicePluginResourceNames(cr)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names).To(Equal([]string{"rdma/rdma_shared_device_a", "nvidia.com/hostdev", "intel.com/sriov"}))
+	})
+
+	It("Should return the resources of each device plugin", func() {
+		cr.Spec.SriovDevicePlugin.ResourceDriftRestartSeconds = 120
+		plugins, err := DevicePlugins(cr)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plugins).To(Equal([]DevicePluginResources{
+			{DaemonSet: "rdma-shared-dp-ds", ResourceNames: []string{"rdma/rdma_shared_device_a"}},
+			{DaemonSet: "sriov-device-plugin", ResourceNames: []string{"nvidia.com/hostdev", "intel.com/sriov"},
+				ResourceDriftRestartSeconds: 120},
+		}))
+	})
+
+	It("Should return resource names of RDMA shared device pools", func() {
+		cr.Spec.RdmaSharedDevicePlugin.Config = ""
+		cr.Spec.RdmaSharedDevicePlugin.ResourcePools = []mellanoxv1alpha1.RdmaSharedDevicePoolSpec{
+			{Name: "rdma_shared_device_a"}, {Name: "rdma_shared_device_b"},
+		}
+		cr.Spec.SriovDevicePlugin = nil
+		names, err := DevicePluginResourceNames(cr)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names).To(Equal([]string{"rdma/rdma_shared_device_a", "rdma/rdma_shared_device_b"}))
+	})
+
+	It("Should fail on invalid device plugin config", func() {
+		cr.Spec.SriovDevicePlugin.Config = "invalid"
+		_, err := DevicePluginResourceNames(cr)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should detect pods which use device plugin resources", func() {
+		pod := &corev1.Pod{}
+		pod.Spec.Containers = []corev1.Container{{
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{"nvidia.com/hostdev": resource.MustParse("1")},
+			},
+		}}
+		Expect(PodUsesResources(pod, []string{"nvidia.com/hostdev"})).To(BeTrue())
+		Expect(PodUsesResources(pod, []string{"rdma/rdma_shared_device_a"})).To(BeFalse())
+
+		pod.Status.Phase = corev1.PodSucceeded
+		Expect(PodUsesResources(pod, []string{"nvidia.com/hostdev"})).To(BeFalse())
+	})
+})
@@ -0,0 +1,110 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/consts"
+)
+
+// devicePluginTolerations returns the additional tolerations of a device plugin. The tolerations of the OFED driver
+// which the device plugin doesn't tolerate are added unless inheriting them is disabled, in which case they are
+// reported, as the device plugin doesn't advertise the resources of the nodes with such taints
+func devicePluginTolerations(cr *mellanoxv1alpha1.NicClusterPolicy, devicePlugin string,
+	tolerations []v1.Toleration, inherit *bool) []v1.Toleration {
+	if !isOFEDDriverDeployed(cr) {
+		return tolerations
+	}
+	missing := missingTolerations(cr.Spec.OFEDDriver.Tolerations, tolerations)
+	if len(missing) == 0 {
+		return tolerations
+	}
+	if inherit != nil && !*inherit {
+		log.V(consts.LogLevelWarning).Info("Device plugin doesn't tolerate all taints tolerated by the OFED driver, "+
+			"its resources are not advertised on nodes with these taints", "devicePlugin", devicePlugin,
+			"tolerations", missing)
+		return tolerations
+	}
+	return append(append([]v1.Toleration(nil), tolerations...), missing...)
+}
+
+// missingTolerations returns the required tolerations which are not covered by any of the tolerations
+func missingTolerations(required, tolerations []v1.Toleration) []v1.Toleration {
+	var missing []v1.Toleration
+	for i := range required {
+		covered := false
+		for j := range tolerations {
+			if toleratesAll(&tolerations[j], &required[i]) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			missing = append(missing, required[i])
+		}
+	}
+	return missing
+}
+
+// toleratesAll returns true if the toleration tolerates every taint tolerated by the other toleration,
+// the toleration seconds are ignored
+func toleratesAll(toleration, other *v1.Toleration) bool {
+	if toleration.Effect != "" && toleration.Effect != other.Effect {
+		return false
+	}
+	if toleration.Operator == v1.TolerationOpExists {
+		return toleration.Key == "" || toleration.Key == other.Key
+	}
+	return toleration.Key == other.Key && other.Operator != v1.TolerationOpExists && toleration.Value == other.Value
+}
+
+// addTolerations appends the tolerations to the pod templates of the rendered DaemonSets,
+// after the tolerations rendered by the operator
+func addTolerations(objs []*unstructured.Unstructured, tolerations []v1.Toleration) error {
+	if len(tolerations) == 0 {
+		return nil
+	}
+	additional := make([]interface{}, 0, len(tolerations))
+	for i := range tolerations {
+		toleration, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&tolerations[i])
+		if err != nil {
+			return errors.Wrapf(err, "failed to convert toleration %s", tolerations[i].Key)
+		}
+		additional = append(additional, toleration)
+	}
+
+	for _, obj := range objs {
+		if obj.GetKind() != "DaemonSet" {
+			continue
+		}
+		rendered, _, err := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", "tolerations")
+		if err != nil {
+			return errors.Wrapf(err, "failed to get tolerations of DaemonSet %s", obj.GetName())
+		}
+		rendered = append(rendered, runtime.DeepCopyJSONValue(additional).([]interface{})...)
+		err = unstructured.SetNestedSlice(obj.Object, rendered, "spec", "template", "spec", "tolerations")
+		if err != nil {
+			return errors.Wrapf(err, "failed to set tolerations of DaemonSet %s", obj.GetName())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	mellanoxv1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
+)
+
+var _ = Describe("Tolerations tests", func() {
+	dedicated := v1.Toleration{Key: "dedicated", Operator: v1.TolerationOpEqual, Value: "rdma",
+		Effect: v1.TaintEffectNoSchedule}
+	maintenance := v1.Toleration{Key: "maintenance", Operator: v1.TolerationOpExists}
+
+	It("Should find the tolerations which are not covered", func() {
+		required := []v1.Toleration{dedicated, maintenance}
+		Expect(missingTolerations(required, nil)).To(Equal(required))
+		Expect(missingTolerations(required, []v1.Toleration{dedicated})).To(Equal([]v1.Toleration{maintenance}))
+		Expect(missingTolerations(required, []v1.Toleration{{Operator: v1.TolerationOpExists}})).To(BeEmpty())
+		Expect(missingTolerations(required, []v1.Toleration{
+			{Key: "dedicated", Operator: v1.TolerationOpExists},
+			{Key: "maintenance", Operator: v1.TolerationOpExists, Effect: v1.TaintEffectNoSchedule},
+		})).To(Equal([]v1.Toleration{maintenance}))
+
+		otherValue := dedicated
+		otherValue.Value = "gpu"
+		Expect(missingTolerations(required, []v1.Toleration{otherValue, maintenance})).
+			To(Equal([]v1.Toleration{dedicated}))
+	})
+
+	It("Should inherit the driver tolerations unless disabled", func() {
+		cr := &mellanoxv1alpha1.NicClusterPolicy{}
+		own := []v1.Toleration{dedicated}
+		Expect(devicePluginTolerations(cr, "dp", own, nil)).To(Equal(own))
+
+		cr.Spec.OFEDDriver = &mellanoxv1alpha1.OFEDDriverSpec{Tolerations: []v1.Toleration{dedicated, maintenance}}
+		Expect(devicePluginTolerations(cr, "dp", own, nil)).To(Equal([]v1.Toleration{dedicated, maintenance}))
+		Expect(own).To(Equal([]v1.Toleration{dedicated}))
+
+		inherit := false
+		Expect(devicePluginTolerations(cr, "dp", own, &inherit)).To(Equal(own))
+	})
+
+	It("Should add the tolerations to DaemonSets", func() {
+		ds := &unstructured.Unstructured{Object: map[string]interface{}{
+			"kind": "DaemonSet",
+			"spec": map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{
+				"tolerations": []interface{}{map[string]interface{}{"key": "nvidia.com/gpu", "operator": "Exists"}},
+			}}},
+		}}
+		cm := &unstructured.Unstructured{Object: map[string]interface{}{"kind": "ConfigMap"}}
+		Expect(addTolerations([]*unstructured.Unstructured{ds, cm}, []v1.Toleration{dedicated})).To(Succeed())
+
+		tolerations, _, _ := unstructured.NestedSlice(ds.Object, "spec", "template", "spec", "tolerations")
+		Expect(tolerations).To(Equal([]interface{}{
+			map[string]interface{}{"key": "nvidia.com/gpu", "operator": "Exists"},
+			map[string]interface{}{"key": "dedicated", "operator": "Equal", "value": "rdma", "effect": "NoSchedule"},
+		}))
+		Expect(cm.Object).NotTo(HaveKey("spec"))
+	})
+})
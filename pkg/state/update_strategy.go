@@ -0,0 +1,47 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// setUpdateStrategy replaces the update strategy of the rendered DaemonSets, the rendered strategy is kept
+// if the update strategy is not set
+func setUpdateStrategy(objs []*unstructured.Unstructured, updateStrategy *appsv1.DaemonSetUpdateStrategy) error {
+	if updateStrategy == nil {
+		return nil
+	}
+	strategy, err := runtime.DefaultUnstructuredConverter.ToUnstructured(updateStrategy)
+	if err != nil {
+		return errors.Wrap(err, "failed to convert update strategy")
+	}
+
+	for _, obj := range objs {
+		if obj.GetKind() != "DaemonSet" {
+			continue
+		}
+		err := unstructured.SetNestedField(obj.Object, runtime.DeepCopyJSON(strategy), "spec", "updateStrategy")
+		if err != nil {
+			return errors.Wrapf(err, "failed to set update strategy of DaemonSet %s", obj.GetName())
+		}
+	}
+	return nil
+}
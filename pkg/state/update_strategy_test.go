@@ -0,0 +1,58 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+var _ = Describe("Update strategy tests", func() {
+	It("Should replace the update strategy of the rendered DaemonSets", func() {
+		ds := &unstructured.Unstructured{Object: map[string]interface{}{
+			"kind": "DaemonSet",
+			"spec": map[string]interface{}{"updateStrategy": map[string]interface{}{"type": "OnDelete"}},
+		}}
+		cm := &unstructured.Unstructured{Object: map[string]interface{}{"kind": "ConfigMap"}}
+		maxUnavailable := intstr.FromString("20%")
+		Expect(setUpdateStrategy([]*unstructured.Unstructured{ds, cm}, &appsv1.DaemonSetUpdateStrategy{
+			Type:          appsv1.RollingUpdateDaemonSetStrategyType,
+			RollingUpdate: &appsv1.RollingUpdateDaemonSet{MaxUnavailable: &maxUnavailable},
+		})).To(Succeed())
+
+		strategy, _, _ := unstructured.NestedMap(ds.Object, "spec", "updateStrategy")
+		Expect(strategy).To(Equal(map[string]interface{}{
+			"type":          "RollingUpdate",
+			"rollingUpdate": map[string]interface{}{"maxUnavailable": "20%"},
+		}))
+		Expect(cm.Object).NotTo(HaveKey("spec"))
+	})
+
+	It("Should keep the rendered update strategy if no update strategy is set", func() {
+		ds := &unstructured.Unstructured{Object: map[string]interface{}{
+			"kind": "DaemonSet",
+			"spec": map[string]interface{}{"updateStrategy": map[string]interface{}{"type": "OnDelete"}},
+		}}
+		expected := ds.DeepCopy()
+		Expect(setUpdateStrategy([]*unstructured.Unstructured{ds}, nil)).To(Succeed())
+		Expect(ds).To(Equal(expected))
+	})
+})
@@ -0,0 +1,180 @@
+/*
+Copyright 2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/consts"
+)
+
+// reserveAvailability checks the availability of the Deployments with pods on the node, see
+// findAvailabilityViolations. If there are no violations, the pods on the node are reserved as evicted until
+// releaseAvailability is called with the returned reservation, so the concurrent drains of other nodes
+// don't count them as available. The checks of concurrent drains are serialized
+func (m *DrainManagerImpl) reserveAvailability(ctx context.Context, nodeName string,
+	checks []v1alpha1.DrainAvailabilityCheckSpec) ([]string, map[string]int, error) {
+	m.availabilityMutex.Lock()
+	defer m.availabilityMutex.Unlock()
+	violations, evicting, err := m.findAvailabilityViolations(ctx, nodeName, checks)
+	if err != nil || len(violations) > 0 {
+		return violations, nil, err
+	}
+	for deployment, pods := range evicting {
+		m.evictingPods[deployment] += pods
+	}
+	return nil, evicting, nil
+}
+
+// releaseAvailability releases the pods reserved by reserveAvailability once the drain of their node is over
+func (m *DrainManagerImpl) releaseAvailability(evicting map[string]int) {
+	m.availabilityMutex.Lock()
+	defer m.availabilityMutex.Unlock()
+	for deployment, pods := range evicting {
+		m.evictingPods[deployment] -= pods
+		if m.evictingPods[deployment] <= 0 {
+			delete(m.evictingPods, deployment)
+		}
+	}
+}
+
+// findAvailabilityViolations returns a description of each Deployment selected by the availability checks which
+// would have less available replicas than required once its pods on the node are evicted, as well as the number of
+// available pods on the node of each affected Deployment by namespace/name. Deployments without available pods
+// on the node are not affected by the drain and are never reported.
+// The available replicas of a Deployment are limited to its Ready pods which are not terminating, so that the pods
+// evicted by the finished drains are not counted before the Deployment status is updated, and the pods reserved
+// by the drains in progress are not counted either
+func (m *DrainManagerImpl) findAvailabilityViolations(ctx context.Context, nodeName string,
+	checks []v1alpha1.DrainAvailabilityCheckSpec) ([]string, map[string]int, error) {
+	if len(checks) == 0 {
+		return nil, nil, nil
+	}
+	pods, err := m.k8sInterface.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.SelectorFromSet(fields.Set{"spec.nodeName": nodeName}).String(),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list pods on node %s: %v", nodeName, err)
+	}
+
+	var violations []string
+	evicting := make(map[string]int)
+	for i := range checks {
+		check := &checks[i]
+		selector, err := metav1.LabelSelectorAsSelector(&check.Selector)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid availability check selector: %v", err)
+		}
+		deployments, err := m.k8sInterface.AppsV1().Deployments(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+			LabelSelector: selector.String(),
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list deployments for availability check: %v", err)
+		}
+		for j := range deployments.Items {
+			deployment := &deployments.Items[j]
+			key := deployment.Namespace + "/" + deployment.Name
+			podSelector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+			if err != nil {
+				continue
+			}
+			onNode := 0
+			for k := range pods.Items {
+				pod := &pods.Items[k]
+				if pod.Spec.NodeName == nodeName && pod.Namespace == deployment.Namespace &&
+					podSelector.Matches(labels.Set(pod.Labels)) && isPodAvailable(pod) {
+					onNode++
+				}
+			}
+			if onNode == 0 {
+				continue
+			}
+			available, err := m.availableReplicas(ctx, deployment, podSelector)
+			if err != nil {
+				return nil, nil, err
+			}
+			desired := 1
+			if deployment.Spec.Replicas != nil {
+				desired = int(*deployment.Spec.Replicas)
+			}
+			// rounded up, so that the percentage is never violated
+			required := (desired*check.MinAvailablePercent + 99) / 100
+			remaining := available - m.evictingPods[key] - onNode
+			if remaining < required {
+				violations = append(violations, fmt.Sprintf(
+					"deployment %s/%s would have %d of %d replicas available, %d%% (%d) required",
+					deployment.Namespace, deployment.Name, remaining, desired, check.MinAvailablePercent, required))
+			}
+			// a Deployment selected by several checks is reserved once
+			evicting[key] = onNode
+		}
+	}
+	return violations, evicting, nil
+}
+
+// availableReplicas returns the available replicas of the Deployment, at most the number of its Ready pods
+// which are not terminating
+func (m *DrainManagerImpl) availableReplicas(
+	ctx context.Context, deployment *appsv1.Deployment, podSelector labels.Selector) (int, error) {
+	pods, err := m.k8sInterface.CoreV1().Pods(deployment.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: podSelector.String(),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list pods of deployment %s/%s: %v", deployment.Namespace, deployment.Name, err)
+	}
+	ready := 0
+	for i := range pods.Items {
+		if isPodAvailable(&pods.Items[i]) {
+			ready++
+		}
+	}
+	if available := int(deployment.Status.AvailableReplicas); available < ready {
+		return available, nil
+	}
+	return ready, nil
+}
+
+// isPodAvailable returns true if the pod is Ready and not terminating
+func isPodAvailable(pod *corev1.Pod) bool {
+	return pod.DeletionTimestamp == nil && isPodReady(pod)
+}
+
+// reportDrainBlocked sets UpgradeDrainBlockedAnnotation on the node to the availability check violations
+// which block its drain, the annotation is removed if there are no violations
+func (m *DrainManagerImpl) reportDrainBlocked(ctx context.Context, node *corev1.Node, violations []string) {
+	value := "null"
+	if len(violations) > 0 {
+		value = "drain would violate availability checks: " + strings.Join(violations, "; ")
+	}
+	if current, ok := node.Annotations[UpgradeDrainBlockedAnnotation]; current == value || (!ok && value == "null") {
+		return
+	}
+	err := m.nodeUpgradeStateProvider.ChangeNodeUpgradeAnnotation(ctx, node, UpgradeDrainBlockedAnnotation, value)
+	if err != nil {
+		m.log.V(consts.LogLevelWarning).Info("Failed to report blocked drain on the node", "node", node.Name,
+			"error", err.Error())
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2022 NVIDIA CORPORATION & AFFILIATES
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+
+	"github.com/Mellanox/network-operator/pkg/consts"
+)
+
+const (
+	// maxReportedBarePods limits the number of bare pods listed in UpgradeBarePodsAnnotation
+	maxReportedBarePods = 20
+	// mirrorPodAnnotation is set by kubelet on mirror pods of static pods, the drain skips them
+	mirrorPodAnnotation = "kubernetes.io/config.mirror"
+)
+
+// findBarePods returns namespace/name of the running pods on the node which have no controller,
+// such pods are not recreated on another node once they are evicted
+func (m *DrainManagerImpl) findBarePods(ctx context.Context, nodeName, podSelector string) ([]string, error) {
+	pods, err := m.k8sInterface.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		LabelSelector: podSelector,
+		FieldSelector: fields.SelectorFromSet(fields.Set{"spec.nodeName": nodeName}).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods on node %s: %v", nodeName, err)
+	}
+	var barePods []string
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if isBarePod(pod) {
+			barePods = append(barePods, pod.Namespace+"/"+pod.Name)
+		}
+	}
+	return barePods, nil
+}
+
+// isBarePod returns true if the pod has no controller and the drain would evict it:
+// finished, terminating and mirror pods are not bare pods
+func isBarePod(pod *corev1.Pod) bool {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed ||
+		pod.DeletionTimestamp != nil {
+		return false
+	}
+	if _, mirror := pod.Annotations[mirrorPodAnnotation]; mirror {
+		return false
+	}
+	return metav1.GetControllerOf(pod) == nil
+}
+
+// reportBarePods sets UpgradeBarePodsAnnotation on the node to the list of bare pods which block its drain,
+// the annotation is removed if the list is empty
+func (m *DrainManagerImpl) reportBarePods(ctx context.Context, node *corev1.Node, barePods []string) {
+	value := "null"
+	if len(barePods) > 0 {
+		value = strings.Join(barePods, ",")
+		if len(barePods) > maxReportedBarePods {
+			value = fmt.Sprintf("%s and %d more", strings.Join(barePods[:maxReportedBarePods], ","),
+				len(barePods)-maxReportedBarePods)
+		}
+	}
+	if current, ok := node.Annotations[UpgradeBarePodsAnnotation]; current == value || (!ok && value == "null") {
+		return
+	}
+	err := m.nodeUpgradeStateProvider.ChangeNodeUpgradeAnnotation(ctx, node, UpgradeBarePodsAnnotation, value)
+	if err != nil {
+		m.log.V(consts.LogLevelWarning).Info("Failed to report bare pods on the node", "node", node.Name,
+			"error", err.Error())
+	}
+}
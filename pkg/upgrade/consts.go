@@ -18,9 +18,69 @@ package upgrade
 
 const (
 	UpgradeStateAnnotation = "nvidia.com/ofed-upgrade-state"
+	// UpgradeStateTimestampAnnotation holds the time (RFC3339) when the node has entered its current upgrade state
+	UpgradeStateTimestampAnnotation = "nvidia.com/ofed-upgrade-state-timestamp"
+	// UpgradeDoneTimestampAnnotation holds the time (RFC3339) when the node has finished its last upgrade
+	UpgradeDoneTimestampAnnotation = "nvidia.com/ofed-upgrade-done-timestamp"
+	// UpgradeSoakStartTimestampAnnotation holds the time (RFC3339) when the node has entered the post upgrade soak
+	UpgradeSoakStartTimestampAnnotation = "nvidia.com/ofed-upgrade-soak-start-timestamp"
+	// UpgradeUncordonRetriesAnnotation holds the number of times the node was found not Ready after uncordon
+	UpgradeUncordonRetriesAnnotation = "nvidia.com/ofed-upgrade-uncordon-retries"
+	// UpgradeUncordonCheckTimestampAnnotation holds the time (RFC3339) of the last Ready state check
+	// of the node after uncordon
+	UpgradeUncordonCheckTimestampAnnotation = "nvidia.com/ofed-upgrade-uncordon-check-timestamp"
+	// UpgradeUncordonPodsWaitStartAnnotation holds the time (RFC3339) when the upgrade flow started to wait
+	// for the DaemonSet pods on the uncordoned node
+	UpgradeUncordonPodsWaitStartAnnotation = "nvidia.com/ofed-upgrade-uncordon-pods-wait-start"
+	// UpgradeNodeMarksAnnotation holds the labels and taints (JSON) which were added to the node
+	// from the nodeMarks of the upgrade policy, they are removed when the node is uncordoned
+	UpgradeNodeMarksAnnotation = "nvidia.com/ofed-upgrade-node-marks"
+	// UpgradeDevicePluginsPausedAnnotation holds the time (RFC3339) when the device plugins were removed
+	// from the node before the drain, the NicClusterPolicy controller keeps the OFED wait label set
+	// on the node while the annotation is present
+	UpgradeDevicePluginsPausedAnnotation = "nvidia.com/ofed-upgrade-device-plugins-paused"
+	// UpgradeBarePodsAnnotation holds a comma separated list of pods without a controller which block the drain
+	// of the node when the Block bare pods strategy is set in the drain spec
+	UpgradeBarePodsAnnotation = "nvidia.com/ofed-upgrade-bare-pods"
+	// UpgradeDrainBlockedAnnotation holds the reason why the drain of the node doesn't start,
+	// e.g. a Deployment which would violate an availability check of the drain spec
+	UpgradeDrainBlockedAnnotation = "nvidia.com/ofed-upgrade-drain-blocked"
+	// UpgradeManualRequiredAnnotation holds the name of the node group of the upgrade policy which doesn't allow
+	// disruption of the node, the upgrade flow skips the node until the driver pod is restarted manually
+	UpgradeManualRequiredAnnotation = "nvidia.com/ofed-upgrade-manual-required"
+	// UpgradeHistoryAnnotation holds the past upgrades of the node (JSON), the oldest entries are dropped
+	// once UpgradeHistoryLength is reached
+	UpgradeHistoryAnnotation = "nvidia.com/ofed-upgrade-history"
+	// UpgradeFailuresAnnotation holds the number of times the node has failed the upgrade since its last
+	// successful upgrade, i.e. entered UpgradeStateDrainFailed or UpgradeStateFailed state
+	UpgradeFailuresAnnotation = "nvidia.com/ofed-upgrade-failures"
+	// UpgradeQuarantinedAnnotation holds the time (RFC3339) when the node was quarantined by the upgrade flow,
+	// it is removed together with UpgradeFailuresAnnotation once OfedUpgradeQuarantinedLabel is removed
+	UpgradeQuarantinedAnnotation = "nvidia.com/ofed-upgrade-quarantined"
+	// UpgradeQuarantineCordonAnnotation is set on a cordoned quarantined node when automatic upgrade is disabled
+	// and the quarantine is removed, the value explains why the node stays cordoned. It is removed once the node
+	// is uncordoned
+	UpgradeQuarantineCordonAnnotation = "nvidia.com/ofed-upgrade-quarantine-cordon"
+	// UpgradeQuarantineCordonMessage is the value of UpgradeQuarantineCordonAnnotation
+	UpgradeQuarantineCordonMessage = "cordoned while quarantined by the OFED driver upgrade, " +
+		"uncordon the node once the driver is fixed"
+	// UpgradeDriverValidationFailedAnnotation holds the output of the driver validation command of the upgrade policy
+	// which has failed in the restarted driver pod, it is removed once the validation succeeds
+	UpgradeDriverValidationFailedAnnotation = "nvidia.com/ofed-upgrade-driver-validation-failed"
+	// ForceDriverReloadAnnotation requests driver pod reload on the node through the upgrade flow when set to "true".
+	// Once the request is accepted the value is replaced with the request time (RFC3339),
+	// the annotation is removed when the node is uncordoned
+	ForceDriverReloadAnnotation = "nvidia.com/force-driver-reload"
+	// UpgradeApprovedAnnotation is set on the NicClusterPolicy and holds a comma separated list of
+	// OFED driver images approved for the upgrade when the upgrade policy requires approval
+	UpgradeApprovedAnnotation = "nvidia.com/ofed-upgrade-approved"
 
 	OfedDriverLabel           = "nvidia.com/ofed-driver"
 	OfedUpgradeSkipDrainLabel = "nvidia.com/ofed-upgrade.skip-drain"
+	// OfedUpgradeQuarantinedLabel is set to "true" on the nodes excluded from the upgrade flow
+	// after too many failed upgrades, it is removed by the cluster administrator to re-enable the upgrade of the node
+	OfedUpgradeQuarantinedLabel = "nvidia.com/ofed-upgrade.quarantined"
+	OfedDriverContainerName     = "mofed-container"
 
 	// UpgradeStateUnknown Node has this state when the upgrade flow is disabled or the node hasn't been processed yet
 	UpgradeStateUnknown = ""
@@ -29,6 +89,10 @@ const (
 	// UpgradeStateUpgradeRequired is set when OFED POD on the node is not up-to-date and required upgrade
 	// No actions are performed at this stage
 	UpgradeStateUpgradeRequired = "upgrade-required"
+	// UpgradeStatePendingApproval is set when the node requires upgrade, but the upgrade policy requires approval
+	// and the target OFED driver image is not approved yet. After the approval the state is changed
+	// to UpgradeStateUpgradeRequired
+	UpgradeStatePendingApproval = "pending-approval"
 	// UpgradeStateDrain is set when the node is scheduled for drain. After the drain the state is changed
 	// either to UpgradeStatePodRestart or UpgradeStateDrainFailed
 	UpgradeStateDrain = "drain"
@@ -37,6 +101,29 @@ const (
 	UpgradeStatePodRestart = "pod-restart"
 	// UpgradeStateDrainFailed is set when drain on the node has failed. Manual interaction is required at this stage.
 	UpgradeStateDrainFailed = "drain-failed"
-	// UpgradeStateUncordonRequired is set when OFED POD on the node is up-to-date and has "Ready" status
+	// UpgradeStatePostUpgradeSoak is set when OFED POD on the node is up-to-date and has "Ready" status, but the
+	// upgrade policy requires the driver to stay healthy for the soak period. After the soak the state is changed
+	// to UpgradeStateUncordonRequired, if the driver fails during the soak the state is changed to UpgradeStateFailed
+	UpgradeStatePostUpgradeSoak = "post-upgrade-soak"
+	// UpgradeStateUncordonRequired is set when OFED POD on the node is up-to-date and has "Ready" status.
+	// If the upgrade policy requires the node to be Ready after uncordon, the node stays in this state until
+	// it is Ready or the retries are exhausted, then the state is changed to UpgradeStateFailed
 	UpgradeStateUncordonRequired = "uncordon-required"
+	// UpgradeStateFailed is set when the restarted OFED POD on the node failed to start
+	// or the node didn't become Ready after uncordon.
+	// Manual interaction might be required at this stage.
+	UpgradeStateFailed = "upgrade-failed"
+
+	// UpgradeAbortedCondition is set on the NicClusterPolicy when the number of failed nodes
+	// reaches the max failures limit of the upgrade policy
+	UpgradeAbortedCondition = "UpgradeAborted"
+	// UpgradePendingApprovalCondition is set on the NicClusterPolicy when nodes wait for the approval
+	// of the target OFED driver image
+	UpgradePendingApprovalCondition = "UpgradePendingApproval"
+	// UpgradeStalledCondition is set on the NicClusterPolicy when nodes stay in upgrade-required,
+	// pending-approval or drain state longer than the max pre-upgrade state time of the upgrade policy
+	UpgradeStalledCondition = "UpgradeStalled"
+	// UpgradeManualRequiredCondition is set on the NicClusterPolicy when nodes of node groups which don't allow
+	// disruption require the upgrade, the driver pods on these nodes have to be restarted manually
+	UpgradeManualRequiredCondition = "UpgradeManualRequired"
 )
@@ -0,0 +1,89 @@
+/*
+Copyright 2022 NVIDIA CORPORATION & AFFILIATES
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+
+	"github.com/Mellanox/network-operator/pkg/consts"
+)
+
+// maxCordonSettleRounds is the maximum number of settle intervals to wait for pods landing on the cordoned node,
+// so that a namespace which keeps creating pods on the node can't block the drain
+const maxCordonSettleRounds = 5
+
+// waitForCordonSettle waits for the settle interval after the node is cordoned and repeats the wait
+// while new pods land on the node, e.g. pods bound by the scheduler right before the cordon.
+// Pods of DaemonSets are not drained and don't extend the wait. Errors are logged and end the wait
+func (m *DrainManagerImpl) waitForCordonSettle(ctx context.Context, nodeName string, settle time.Duration) {
+	seen, err := m.nodePodNames(ctx, nodeName)
+	if err != nil {
+		m.log.V(consts.LogLevelWarning).Info("Failed to list pods on the cordoned node", "node", nodeName,
+			"error", err.Error())
+		return
+	}
+	for round := 1; round <= maxCordonSettleRounds; round++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(settle):
+		}
+		current, err := m.nodePodNames(ctx, nodeName)
+		if err != nil {
+			m.log.V(consts.LogLevelWarning).Info("Failed to list pods on the cordoned node", "node", nodeName,
+				"error", err.Error())
+			return
+		}
+		var landed []string
+		for name := range current {
+			if !seen[name] {
+				landed = append(landed, name)
+			}
+		}
+		if len(landed) == 0 {
+			m.log.V(consts.LogLevelDebug).Info("No new pods landed on the cordoned node", "node", nodeName)
+			return
+		}
+		sort.Strings(landed)
+		m.log.V(consts.LogLevelInfo).Info("New pods landed on the cordoned node, waiting before the drain",
+			"node", nodeName, "pods", landed, "round", round)
+		seen = current
+	}
+	m.log.V(consts.LogLevelWarning).Info("New pods keep landing on the cordoned node, starting the drain",
+		"node", nodeName)
+}
+
+// nodePodNames returns the namespaced names of the pods on the node which are not managed by a DaemonSet
+func (m *DrainManagerImpl) nodePodNames(ctx context.Context, nodeName string) (map[string]bool, error) {
+	pods, err := m.k8sInterface.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.SelectorFromSet(fields.Set{"spec.nodeName": nodeName}).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods on node %s: %v", nodeName, err)
+	}
+	names := make(map[string]bool, len(pods.Items))
+	for i := range pods.Items {
+		if controller := metav1.GetControllerOf(&pods.Items[i]); controller != nil && controller.Kind == "DaemonSet" {
+			continue
+		}
+		names[pods.Items[i].Namespace+"/"+pods.Items[i].Name] = true
+	}
+	return names, nil
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+// daemonSetDefaultTolerations are added by the DaemonSet controller to the pods of every DaemonSet,
+// so that the pods are scheduled to cordoned nodes and nodes under pressure
+var daemonSetDefaultTolerations = []corev1.Toleration{
+	{Key: corev1.TaintNodeNotReady, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+	{Key: corev1.TaintNodeUnreachable, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+	{Key: corev1.TaintNodeDiskPressure, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	{Key: corev1.TaintNodeMemoryPressure, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	{Key: corev1.TaintNodePIDPressure, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	{Key: corev1.TaintNodeUnschedulable, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+}
+
+// daemonSetSchedulesOnNode returns true if the DaemonSet runs a pod on the node: its node selector and required
+// node affinity match the node, and its pods tolerate the NoSchedule and NoExecute taints of the node
+func daemonSetSchedulesOnNode(ds *appsv1.DaemonSet, node *corev1.Node) bool {
+	podSpec := &ds.Spec.Template.Spec
+	if !labels.SelectorFromSet(podSpec.NodeSelector).Matches(labels.Set(node.Labels)) {
+		return false
+	}
+	if affinity := podSpec.Affinity; affinity != nil && affinity.NodeAffinity != nil &&
+		!nodeSelectorMatches(affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution, node) {
+		return false
+	}
+	tolerations := append(append([]corev1.Toleration{}, podSpec.Tolerations...), daemonSetDefaultTolerations...)
+	if podSpec.HostNetwork {
+		tolerations = append(tolerations, corev1.Toleration{Key: corev1.TaintNodeNetworkUnavailable,
+			Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule})
+	}
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		if !toleratesTaint(tolerations, taint) {
+			return false
+		}
+	}
+	return true
+}
+
+// nodeSelectorMatches returns true if the node matches any term of the node selector, a nil selector matches
+// all nodes. Terms without requirements and terms with invalid requirements match no nodes
+func nodeSelectorMatches(nodeSelector *corev1.NodeSelector, node *corev1.Node) bool {
+	if nodeSelector == nil {
+		return true
+	}
+	for i := range nodeSelector.NodeSelectorTerms {
+		if nodeSelectorTermMatches(&nodeSelector.NodeSelectorTerms[i], node) {
+			return true
+		}
+	}
+	return false
+}
+
+func nodeSelectorTermMatches(term *corev1.NodeSelectorTerm, node *corev1.Node) bool {
+	if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+		return false
+	}
+	if !requirementsMatch(term.MatchExpressions, labels.Set(node.Labels)) {
+		return false
+	}
+	// metadata.name is the only supported field
+	for i := range term.MatchFields {
+		if term.MatchFields[i].Key != "metadata.name" {
+			return false
+		}
+	}
+	return requirementsMatch(term.MatchFields, labels.Set{"metadata.name": node.Name})
+}
+
+func requirementsMatch(requirements []corev1.NodeSelectorRequirement, values labels.Set) bool {
+	operators := map[corev1.NodeSelectorOperator]selection.Operator{
+		corev1.NodeSelectorOpIn:           selection.In,
+		corev1.NodeSelectorOpNotIn:        selection.NotIn,
+		corev1.NodeSelectorOpExists:       selection.Exists,
+		corev1.NodeSelectorOpDoesNotExist: selection.DoesNotExist,
+		corev1.NodeSelectorOpGt:           selection.GreaterThan,
+		corev1.NodeSelectorOpLt:           selection.LessThan,
+	}
+	for i := range requirements {
+		operator, ok := operators[requirements[i].Operator]
+		if !ok {
+			return false
+		}
+		requirement, err := labels.NewRequirement(requirements[i].Key, operator, requirements[i].Values)
+		if err != nil || !requirement.Matches(values) {
+			return false
+		}
+	}
+	return true
+}
+
+func toleratesTaint(tolerations []corev1.Toleration, taint *corev1.Taint) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
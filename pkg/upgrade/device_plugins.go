@@ -0,0 +1,60 @@
+/*
+Copyright 2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+)
+
+// PauseDevicePlugins sets the OFED wait label on the node object, which removes the device plugin pods
+// from the node so that they deregister their resources, the pause time is recorded
+// in UpgradeDevicePluginsPausedAnnotation
+func PauseDevicePlugins(node *v1.Node, now time.Time) {
+	if node.Labels == nil {
+		node.Labels = make(map[string]string)
+	}
+	node.Labels[nodeinfo.NodeLabelWaitOFED] = "true"
+	if node.Annotations == nil {
+		node.Annotations = make(map[string]string)
+	}
+	node.Annotations[UpgradeDevicePluginsPausedAnnotation] = now.UTC().Format(time.RFC3339)
+}
+
+// ResumeDevicePlugins clears the OFED wait label set by PauseDevicePlugins and removes
+// UpgradeDevicePluginsPausedAnnotation from the node object, false is returned if the device plugins are not paused
+func ResumeDevicePlugins(node *v1.Node) bool {
+	if _, ok := node.Annotations[UpgradeDevicePluginsPausedAnnotation]; !ok {
+		return false
+	}
+	if node.Labels == nil {
+		node.Labels = make(map[string]string)
+	}
+	node.Labels[nodeinfo.NodeLabelWaitOFED] = "false"
+	delete(node.Annotations, UpgradeDevicePluginsPausedAnnotation)
+	return true
+}
+
+// devicePluginsPausedSince returns the time when the device plugins on the node were paused,
+// false is returned if they are not paused or the time is unknown
+func devicePluginsPausedSince(node *v1.Node) (time.Time, bool) {
+	pausedAt, err := time.Parse(time.RFC3339, node.Annotations[UpgradeDevicePluginsPausedAnnotation])
+	return pausedAt, err == nil
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
+	"github.com/Mellanox/network-operator/pkg/upgrade"
+)
+
+var _ = Describe("Device plugins pause tests", func() {
+	It("Should set OFED wait label while device plugins are paused", func() {
+		node := &corev1.Node{}
+		Expect(upgrade.ResumeDevicePlugins(node)).To(BeFalse())
+
+		now := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+		upgrade.PauseDevicePlugins(node, now)
+		Expect(node.Labels).To(HaveKeyWithValue(nodeinfo.NodeLabelWaitOFED, "true"))
+		Expect(node.Annotations).To(HaveKeyWithValue(upgrade.UpgradeDevicePluginsPausedAnnotation, "2022-06-01T10:00:00Z"))
+
+		Expect(upgrade.ResumeDevicePlugins(node)).To(BeTrue())
+		Expect(node.Labels).To(HaveKeyWithValue(nodeinfo.NodeLabelWaitOFED, "false"))
+		Expect(node.Annotations).NotTo(HaveKey(upgrade.UpgradeDevicePluginsPausedAnnotation))
+	})
+})
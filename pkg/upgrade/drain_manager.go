@@ -17,11 +17,14 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/kubectl/pkg/drain"
 
 	"github.com/Mellanox/network-operator/api/v1alpha1"
@@ -39,6 +42,16 @@ type DrainManagerImpl struct {
 	k8sInterface             kubernetes.Interface
 	drainingNodes            *StringSet
 	nodeUpgradeStateProvider NodeUpgradeStateProvider
+	// evictionGroupVersion is the group version of Eviction served by the cluster,
+	// empty if the eviction is not supported
+	evictionGroupVersion string
+	// EventRecorder records events on the pods removed by the drain and on their controllers,
+	// no events are recorded if it is not set
+	EventRecorder record.EventRecorder
+	// availabilityMutex serializes the availability checks of the drains and the reservation of the pods they evict
+	availabilityMutex sync.Mutex
+	// evictingPods counts the pods of each Deployment, by namespace/name, evicted by the drains in progress
+	evictingPods map[string]int
 
 	log logr.Logger
 }
@@ -52,6 +65,12 @@ type DrainManager interface {
 // When the node gets scheduled, it's marked as being drained and therefore will not be scheduled for drain twice
 // if the initial drain didn't complete yet.
 // During the drain the node is cordoned first, and then pods on the node are evicted.
+// If CordonSettleSeconds is set in the drain spec, the eviction starts once no new pods land on the cordoned node.
+// Finished pods are deleted right after the cordon if DeleteFinishedPods is set in the drain spec.
+// With the Block bare pods strategy the node stays in UpgradeStateDrain without eviction while pods without
+// a controller run on it, the pods are listed in UpgradeBarePodsAnnotation.
+// The node stays in UpgradeStateDrain as well while evicting its pods would violate the availability checks
+// of the drain spec, the violations are reported in UpgradeDrainBlockedAnnotation.
 // If the drain is successful, the node moves to UpgradeStatePodRestart state,
 // otherwise it moves to UpgradeStateDrainFailed state.
 func (m *DrainManagerImpl) ScheduleNodesDrain(ctx context.Context, drainConfig *DrainConfiguration) error {
@@ -72,22 +91,32 @@ func (m *DrainManagerImpl) ScheduleNodesDrain(ctx context.Context, drainConfig *
 		return nil
 	}
 
+	// -1 means the termination grace period of each pod is honored
+	gracePeriodSeconds := -1
+	if drainSpec.GracePeriodSeconds != nil {
+		gracePeriodSeconds = *drainSpec.GracePeriodSeconds
+	}
+
 	drainHelper := &drain.Helper{
 		Ctx:    ctx,
 		Client: m.k8sInterface,
-		Force:  drainSpec.Force,
+		// bare pods are lost once evicted, so they are only evicted if explicitly requested
+		Force: drainSpec.Force || drainSpec.BarePods == v1alpha1.BarePodsEvict,
 		// OFED Drivers Pods are part of a DaemonSet, so, this option needs to be set to true
 		IgnoreAllDaemonSets: true,
 		DeleteEmptyDirData:  drainSpec.DeleteEmptyDir,
-		GracePeriodSeconds:  -1,
-		Timeout:             time.Duration(drainSpec.TimeoutSecond),
-		PodSelector:         drainSpec.PodSelector,
+		// pods are deleted instead of eviction if the cluster doesn't support it
+		DisableEviction:    m.evictionGroupVersion == "",
+		GracePeriodSeconds: gracePeriodSeconds,
+		Timeout:            time.Duration(drainSpec.TimeoutSecond) * time.Second,
+		PodSelector:        drainSpec.PodSelector,
 		OnPodDeletedOrEvicted: func(pod *corev1.Pod, usingEviction bool) {
 			verbStr := "Deleted"
 			if usingEviction {
 				verbStr = "Evicted"
 			}
 			m.log.V(consts.LogLevelInfo).Info(fmt.Sprintf("%s pod from Node %s/%s", verbStr, pod.Namespace, pod.Name))
+			m.recordEvictionEvents(pod, pod.Spec.NodeName, usingEviction)
 		},
 		Out:    os.Stdout,
 		ErrOut: os.Stdout,
@@ -108,20 +137,67 @@ func (m *DrainManagerImpl) ScheduleNodesDrain(ctx context.Context, drainConfig *
 				err := drain.RunCordonOrUncordon(drainHelper, node, true)
 				if err != nil {
 					m.log.V(consts.LogLevelError).Error(err, "Failed to cordon node", "node", node.Name)
+					_ = recordUpgradeFailure(ctx, m.nodeUpgradeStateProvider, m.log, node)
 					_ = m.nodeUpgradeStateProvider.ChangeNodeUpgradeState(ctx, node, UpgradeStateDrainFailed)
 					return
 				}
 				m.log.V(consts.LogLevelInfo).Info("Cordoned the node", "node", node.Name)
 
-				err = drain.RunNodeDrain(drainHelper, node.Name)
+				if drainSpec.CordonSettleSeconds > 0 {
+					m.waitForCordonSettle(ctx, node.Name, time.Duration(drainSpec.CordonSettleSeconds)*time.Second)
+				}
+
+				if drainSpec.DeleteFinishedPods {
+					// pods which are not deleted here are evicted by the drain
+					if err := m.deleteFinishedPods(ctx, node.Name, drainSpec.PodSelector); err != nil {
+						m.log.V(consts.LogLevelWarning).Info("Failed to delete finished pods",
+							"node", node.Name, "error", err.Error())
+					}
+				}
+
+				var barePods []string
+				if drainSpec.BarePods == v1alpha1.BarePodsBlock {
+					barePods, err = m.findBarePods(ctx, node.Name, drainSpec.PodSelector)
+					if err != nil {
+						m.log.V(consts.LogLevelError).Error(err, "Failed to find bare pods", "node", node.Name)
+						return
+					}
+				}
+				// the list of blocking pods is cleared once they are gone or the strategy is changed
+				m.reportBarePods(ctx, node, barePods)
+				if len(barePods) > 0 {
+					m.log.V(consts.LogLevelWarning).Info(
+						"Drain is blocked by pods without a controller, delete or move them to continue the upgrade",
+						"node", node.Name, "pods", barePods)
+					return
+				}
+
+				violations, evicting, err := m.reserveAvailability(ctx, node.Name, drainSpec.AvailabilityChecks)
+				if err != nil {
+					m.log.V(consts.LogLevelError).Error(err, "Failed to check workload availability", "node", node.Name)
+					return
+				}
+				m.reportDrainBlocked(ctx, node, violations)
+				if len(violations) > 0 {
+					m.log.V(consts.LogLevelWarning).Info(
+						"Drain is blocked, it would drop workloads below their minimum availability",
+						"node", node.Name, "violations", violations)
+					return
+				}
+				defer m.releaseAvailability(evicting)
+
+				err = m.runNodeDrain(drainHelper, node.Name)
 				if err != nil {
 					m.log.V(consts.LogLevelError).Error(err, "Failed to drain node", "node", node.Name)
-					_ = m.nodeUpgradeStateProvider.ChangeNodeUpgradeState(ctx, node, UpgradeStateDrainFailed)
+					latest := m.latestNode(ctx, node)
+					_ = recordUpgradeFailure(ctx, m.nodeUpgradeStateProvider, m.log, latest)
+					_ = m.nodeUpgradeStateProvider.ChangeNodeUpgradeState(ctx, latest, UpgradeStateDrainFailed)
 					return
 				}
 				m.log.V(consts.LogLevelInfo).Info("Drained the node", "node", node.Name)
 
-				_ = m.nodeUpgradeStateProvider.ChangeNodeUpgradeState(ctx, node, UpgradeStatePodRestart)
+				_ = m.nodeUpgradeStateProvider.ChangeNodeUpgradeState(
+					ctx, m.latestNode(ctx, node), UpgradeStatePodRestart)
 			}()
 		} else {
 			m.log.V(consts.LogLevelInfo).Info("Node is already being drained, skipping", "node", node.Name)
@@ -130,6 +206,18 @@ func (m *DrainManagerImpl) ScheduleNodesDrain(ctx context.Context, drainConfig *
 	return nil
 }
 
+// latestNode returns the latest version of the node which was cordoned and drained, so that the upgrade state
+// of the node can be changed with the resourceVersion precondition. The given node is returned if it can't be read
+func (m *DrainManagerImpl) latestNode(ctx context.Context, node *corev1.Node) *corev1.Node {
+	latest, err := m.k8sInterface.CoreV1().Nodes().Get(ctx, node.Name, metav1.GetOptions{})
+	if err != nil {
+		m.log.V(consts.LogLevelWarning).Info("Failed to get the latest node object", "node", node.Name,
+			"error", err.Error())
+		return node
+	}
+	return latest
+}
+
 func NewDrainManager(
 	k8sInterface kubernetes.Interface,
 	nodeUpgradeStateProvider NodeUpgradeStateProvider,
@@ -139,7 +227,18 @@ func NewDrainManager(
 		log:                      log,
 		drainingNodes:            NewStringSet(),
 		nodeUpgradeStateProvider: nodeUpgradeStateProvider,
+		evictingPods:             make(map[string]int),
+	}
+
+	evictionGroupVersion, err := DetectEvictionGroupVersion(k8sInterface)
+	if err != nil {
+		// fall back to the version supported by the drain helper
+		log.V(consts.LogLevelWarning).Info("Failed to detect Eviction group version, falling back to default",
+			"error", err.Error(), "groupVersion", EvictionGroupVersionV1beta1)
+		evictionGroupVersion = EvictionGroupVersionV1beta1
 	}
+	log.V(consts.LogLevelInfo).Info("Detected Eviction group version", "groupVersion", evictionGroupVersion)
+	mgr.evictionGroupVersion = evictionGroupVersion
 
 	return mgr
 }
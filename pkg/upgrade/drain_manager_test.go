@@ -18,15 +18,28 @@ package upgrade_test
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/kubectl/pkg/drain"
 
 	. "github.com/Mellanox/network-operator/api/v1alpha1"
 	"github.com/Mellanox/network-operator/pkg/upgrade"
+	"github.com/Mellanox/network-operator/pkg/upgrade/mocks"
 )
 
 var _ = Describe("DrainManager tests", func() {
@@ -162,3 +175,416 @@ var _ = Describe("DrainManager tests", func() {
 		Expect(observedNode.Spec.Unschedulable).To(BeFalse())
 	})
 })
+
+var _ = Describe("Eviction group version detection tests", func() {
+	newClientset := func(apiResources ...metav1.APIResource) *k8sfake.Clientset {
+		clientset := k8sfake.NewSimpleClientset()
+		clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+			{GroupVersion: "v1", APIResources: apiResources},
+			{GroupVersion: "policy/v1beta1"},
+		}
+		return clientset
+	}
+	It("should detect policy/v1 Eviction", func() {
+		clientset := newClientset(metav1.APIResource{
+			Name: "pods/eviction", Kind: "Eviction", Group: "policy", Version: "v1"})
+		groupVersion, err := upgrade.DetectEvictionGroupVersion(clientset)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(groupVersion).To(Equal(upgrade.EvictionGroupVersionV1))
+	})
+	It("should detect policy/v1beta1 Eviction", func() {
+		clientset := newClientset(metav1.APIResource{
+			Name: "pods/eviction", Kind: "Eviction", Group: "policy", Version: "v1beta1"})
+		groupVersion, err := upgrade.DetectEvictionGroupVersion(clientset)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(groupVersion).To(Equal(upgrade.EvictionGroupVersionV1beta1))
+	})
+	It("should fall back to preferred policy group version", func() {
+		clientset := newClientset(metav1.APIResource{Name: "pods/eviction", Kind: "Eviction"})
+		groupVersion, err := upgrade.DetectEvictionGroupVersion(clientset)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(groupVersion).To(Equal(upgrade.EvictionGroupVersionV1beta1))
+	})
+	It("should return empty group version if eviction is not supported", func() {
+		clientset := newClientset(metav1.APIResource{Name: "pods", Kind: "Pod"})
+		groupVersion, err := upgrade.DetectEvictionGroupVersion(clientset)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(groupVersion).To(BeEmpty())
+	})
+})
+
+var _ = Describe("DrainManager finished pods tests", func() {
+	newPod := func(name string, phase corev1.PodPhase, controllerKind, controllerName string) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       corev1.PodSpec{NodeName: "node"},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+		if controllerKind != "" {
+			isController := true
+			pod.OwnerReferences = []metav1.OwnerReference{
+				{Kind: controllerKind, Name: controllerName, Controller: &isController}}
+		}
+		return pod
+	}
+	newJob := func(name string, complete bool) *batchv1.Job {
+		job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+		if complete {
+			job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+		}
+		return job
+	}
+
+	drainNode := func(clientset *k8sfake.Clientset, node *corev1.Node) {
+		stateProvider := &mocks.NodeUpgradeStateProvider{}
+		stateProvider.On("ChangeNodeUpgradeState", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		drainManager := upgrade.NewDrainManager(clientset, stateProvider, log)
+		drainSpec := &DrainSpec{Enable: true, Force: true, TimeoutSecond: 1, DeleteFinishedPods: true}
+		err := drainManager.ScheduleNodesDrain(context.TODO(),
+			&upgrade.DrainConfiguration{Nodes: []*corev1.Node{node}, Spec: drainSpec})
+		Expect(err).To(Succeed())
+	}
+	// finished pods are deleted before the drain lists the pods to evict
+	deletedPods := func(clientset *k8sfake.Clientset) func() []string {
+		return func() []string {
+			var deleted []string
+			podLists := 0
+			for _, action := range clientset.Actions() {
+				if action.GetResource().Resource != "pods" {
+					continue
+				}
+				if action.GetVerb() == "list" {
+					podLists++
+				}
+				if deleteAction, ok := action.(k8stesting.DeleteAction); ok && podLists == 1 {
+					deleted = append(deleted, deleteAction.GetName())
+				}
+			}
+			if podLists < 2 {
+				return nil
+			}
+			return deleted
+		}
+	}
+
+	It("DrainManager should delete finished pods which are not recreated by their controller", func() {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}}
+		clientset := k8sfake.NewSimpleClientset(node,
+			newPod("succeeded", corev1.PodSucceeded, "", ""),
+			newPod("failed", corev1.PodFailed, "ReplicaSet", "rs"),
+			newPod("complete-job", corev1.PodSucceeded, "Job", "complete"),
+			newPod("active-job", corev1.PodFailed, "Job", "active"),
+			newPod("statefulset", corev1.PodFailed, "StatefulSet", "sts"),
+			newJob("complete", true),
+			newJob("active", false))
+
+		drainNode(clientset, node)
+		Eventually(deletedPods(clientset), 5*time.Second).Should(ConsistOf("succeeded", "failed", "complete-job"))
+	})
+
+	It("DrainManager should delete finished pods of Jobs which can't be read", func() {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}}
+		clientset := k8sfake.NewSimpleClientset(node,
+			newPod("forbidden-job", corev1.PodSucceeded, "Job", "forbidden"),
+			newJob("forbidden", false))
+		clientset.PrependReactor("get", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewForbidden(batchv1.Resource("jobs"), "forbidden", fmt.Errorf("denied"))
+		})
+
+		drainNode(clientset, node)
+		Eventually(deletedPods(clientset), 5*time.Second).Should(ConsistOf("forbidden-job"))
+	})
+})
+
+var _ = Describe("DrainManager bare pods tests", func() {
+	newPod := func(name string, controlled bool, annotations map[string]string) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Annotations: annotations},
+			Spec:       corev1.PodSpec{NodeName: "node"},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+		if controlled {
+			isController := true
+			pod.OwnerReferences = []metav1.OwnerReference{
+				{Kind: "ReplicaSet", Name: "rs", Controller: &isController}}
+		}
+		return pod
+	}
+	var (
+		node          *corev1.Node
+		clientset     *k8sfake.Clientset
+		stateProvider *mocks.NodeUpgradeStateProvider
+	)
+
+	BeforeEach(func() {
+		node = &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}}
+		clientset = k8sfake.NewSimpleClientset(node,
+			newPod("bare", false, nil),
+			newPod("controlled", true, nil),
+			newPod("mirror", false, map[string]string{"kubernetes.io/config.mirror": "hash"}))
+		stateProvider = &mocks.NodeUpgradeStateProvider{}
+		stateProvider.On("ChangeNodeUpgradeState", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		stateProvider.On("ChangeNodeUpgradeAnnotation", mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything).Return(nil)
+	})
+
+	It("DrainManager should block the drain and report bare pods with Block strategy", func() {
+		drainManager := upgrade.NewDrainManager(clientset, stateProvider, log)
+		drainSpec := &DrainSpec{Enable: true, TimeoutSecond: 1, BarePods: BarePodsBlock}
+		err := drainManager.ScheduleNodesDrain(context.TODO(),
+			&upgrade.DrainConfiguration{Nodes: []*corev1.Node{node}, Spec: drainSpec})
+		Expect(err).To(Succeed())
+
+		Eventually(func() int { return len(stateProvider.Calls) }, 5*time.Second).Should(Equal(1))
+		stateProvider.AssertCalled(GinkgoT(), "ChangeNodeUpgradeAnnotation",
+			mock.Anything, mock.Anything, upgrade.UpgradeBarePodsAnnotation, "default/bare")
+		Consistently(func() []string {
+			var evicted []string
+			for _, action := range clientset.Actions() {
+				if action.GetSubresource() == "eviction" || action.GetVerb() == "delete" {
+					evicted = append(evicted, action.GetResource().Resource)
+				}
+			}
+			return evicted
+		}, time.Second).Should(BeEmpty())
+		stateProvider.AssertNotCalled(GinkgoT(), "ChangeNodeUpgradeState", mock.Anything, mock.Anything, mock.Anything)
+	})
+	It("DrainManager should evict bare pods with Evict strategy", func() {
+		drainManager := upgrade.NewDrainManager(clientset, stateProvider, log)
+		drainSpec := &DrainSpec{Enable: true, TimeoutSecond: 1, BarePods: BarePodsEvict}
+		err := drainManager.ScheduleNodesDrain(context.TODO(),
+			&upgrade.DrainConfiguration{Nodes: []*corev1.Node{node}, Spec: drainSpec})
+		Expect(err).To(Succeed())
+
+		Eventually(func() int { return len(stateProvider.Calls) }, 5*time.Second).Should(Equal(1))
+		stateProvider.AssertCalled(GinkgoT(), "ChangeNodeUpgradeState",
+			mock.Anything, mock.Anything, upgrade.UpgradeStatePodRestart)
+		stateProvider.AssertNotCalled(GinkgoT(), "ChangeNodeUpgradeAnnotation",
+			mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+})
+
+var _ = Describe("DrainManager availability checks tests", func() {
+	var (
+		node          *corev1.Node
+		otherNode     *corev1.Node
+		clientset     *k8sfake.Clientset
+		stateProvider *mocks.NodeUpgradeStateProvider
+	)
+
+	webPod := func(name, nodeName string) *corev1.Pod {
+		isController := true
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{"app": "web"},
+				OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web", Controller: &isController}}},
+			Spec: corev1.PodSpec{NodeName: nodeName},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning,
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}},
+		}
+	}
+
+	BeforeEach(func() {
+		replicas := int32(2)
+		node = &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}}
+		deployment := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Labels: map[string]string{"tier": "frontend"}},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			},
+			Status: appsv1.DeploymentStatus{AvailableReplicas: 2},
+		}
+		otherNode = &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "other-node"}}
+		clientset = k8sfake.NewSimpleClientset(node, otherNode, deployment, webPod("web-1", "node"),
+			webPod("web-2", "other-node"))
+		stateProvider = &mocks.NodeUpgradeStateProvider{}
+		stateProvider.On("ChangeNodeUpgradeState", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		stateProvider.On("ChangeNodeUpgradeAnnotation", mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything).Return(nil)
+	})
+
+	drainSpec := func(minAvailablePercent int) *DrainSpec {
+		return &DrainSpec{Enable: true, TimeoutSecond: 1, AvailabilityChecks: []DrainAvailabilityCheckSpec{{
+			Selector:            metav1.LabelSelector{MatchLabels: map[string]string{"tier": "frontend"}},
+			MinAvailablePercent: minAvailablePercent,
+		}}}
+	}
+
+	It("DrainManager should block the drain if it would violate the minimum availability", func() {
+		drainManager := upgrade.NewDrainManager(clientset, stateProvider, log)
+		err := drainManager.ScheduleNodesDrain(context.TODO(),
+			&upgrade.DrainConfiguration{Nodes: []*corev1.Node{node}, Spec: drainSpec(100)})
+		Expect(err).To(Succeed())
+
+		Eventually(func() int { return len(stateProvider.Calls) }, 5*time.Second).Should(Equal(1))
+		stateProvider.AssertCalled(GinkgoT(), "ChangeNodeUpgradeAnnotation",
+			mock.Anything, mock.Anything, upgrade.UpgradeDrainBlockedAnnotation,
+			"drain would violate availability checks: "+
+				"deployment default/web would have 1 of 2 replicas available, 100% (2) required")
+		Consistently(func() int { return len(stateProvider.Calls) }, time.Second).Should(Equal(1))
+		stateProvider.AssertNotCalled(GinkgoT(), "ChangeNodeUpgradeState", mock.Anything, mock.Anything, mock.Anything)
+	})
+	It("DrainManager should drain the node if the minimum availability is kept", func() {
+		drainManager := upgrade.NewDrainManager(clientset, stateProvider, log)
+		err := drainManager.ScheduleNodesDrain(context.TODO(),
+			&upgrade.DrainConfiguration{Nodes: []*corev1.Node{node}, Spec: drainSpec(50)})
+		Expect(err).To(Succeed())
+
+		Eventually(func() int { return len(stateProvider.Calls) }, 5*time.Second).Should(Equal(1))
+		stateProvider.AssertCalled(GinkgoT(), "ChangeNodeUpgradeState",
+			mock.Anything, mock.Anything, upgrade.UpgradeStatePodRestart)
+		stateProvider.AssertNotCalled(GinkgoT(), "ChangeNodeUpgradeAnnotation",
+			mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+	It("DrainManager should not let concurrent drains together violate the minimum availability", func() {
+		podsResource := corev1.SchemeGroupVersion.WithResource("pods")
+		// the fake clientset ignores field selectors, the drain of a node must only see the pods of the node
+		clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			fieldSelector := action.(k8stesting.ListAction).GetListRestrictions().Fields
+			nodeName, ok := fieldSelector.RequiresExactMatch("spec.nodeName")
+			if !ok {
+				return false, nil, nil
+			}
+			obj, err := clientset.Tracker().List(
+				podsResource, corev1.SchemeGroupVersion.WithKind("Pod"), action.GetNamespace())
+			if err != nil {
+				return true, nil, err
+			}
+			onNode := &corev1.PodList{}
+			for _, pod := range obj.(*corev1.PodList).Items {
+				if pod.Spec.NodeName == nodeName {
+					onNode.Items = append(onNode.Items, pod)
+				}
+			}
+			return true, onNode, nil
+		})
+		// the evicted pods are removed, so they are not available once the drain of their node is over
+		clientset.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if action.GetSubresource() != "eviction" {
+				return false, nil, nil
+			}
+			eviction := action.(k8stesting.CreateAction).GetObject().(metav1.Object)
+			return true, nil, clientset.Tracker().Delete(podsResource, eviction.GetNamespace(), eviction.GetName())
+		})
+		drainManager := upgrade.NewDrainManager(clientset, stateProvider, log)
+		err := drainManager.ScheduleNodesDrain(context.TODO(),
+			&upgrade.DrainConfiguration{Nodes: []*corev1.Node{node, otherNode}, Spec: drainSpec(50)})
+		Expect(err).To(Succeed())
+
+		// one of the nodes is drained, the drain of the other one would leave no web replica available
+		Eventually(func() int { return len(stateProvider.Calls) }, 5*time.Second).Should(Equal(2))
+		Consistently(func() int { return len(stateProvider.Calls) }, time.Second).Should(Equal(2))
+		stateProvider.AssertNumberOfCalls(GinkgoT(), "ChangeNodeUpgradeState", 1)
+		stateProvider.AssertCalled(GinkgoT(), "ChangeNodeUpgradeState",
+			mock.Anything, mock.Anything, upgrade.UpgradeStatePodRestart)
+		stateProvider.AssertCalled(GinkgoT(), "ChangeNodeUpgradeAnnotation",
+			mock.Anything, mock.Anything, upgrade.UpgradeDrainBlockedAnnotation,
+			"drain would violate availability checks: "+
+				"deployment default/web would have 0 of 2 replicas available, 50% (1) required")
+	})
+})
+
+var _ = Describe("DrainManager eviction events tests", func() {
+	It("DrainManager should record events on the evicted pods and their controllers", func() {
+		isController := true
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}}
+		clientset := k8sfake.NewSimpleClientset(node,
+			&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default", OwnerReferences: []metav1.OwnerReference{
+					{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web", Controller: &isController}}},
+				Spec:   corev1.PodSpec{NodeName: "node"},
+				Status: corev1.PodStatus{Phase: corev1.PodRunning},
+			},
+			&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "bare", Namespace: "default"},
+				Spec:       corev1.PodSpec{NodeName: "node"},
+				Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+			})
+		stateProvider := &mocks.NodeUpgradeStateProvider{}
+		stateProvider.On("ChangeNodeUpgradeState", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		stateProvider.On("ChangeNodeUpgradeAnnotation", mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything).Return(nil)
+		recorder := record.NewFakeRecorder(10)
+		drainManager := upgrade.NewDrainManager(clientset, stateProvider, log)
+		drainManager.EventRecorder = recorder
+		drainSpec := &DrainSpec{Enable: true, TimeoutSecond: 1, BarePods: BarePodsEvict}
+		err := drainManager.ScheduleNodesDrain(context.TODO(),
+			&upgrade.DrainConfiguration{Nodes: []*corev1.Node{node}, Spec: drainSpec})
+		Expect(err).To(Succeed())
+
+		Eventually(func() int { return len(stateProvider.Calls) }, 5*time.Second).Should(Equal(1))
+		var events []string
+		for len(recorder.Events) > 0 {
+			events = append(events, <-recorder.Events)
+		}
+		Expect(events).To(HaveLen(3))
+		Expect(events).To(ContainElement(MatchRegexp(
+			`^Normal OFEDUpgradeEviction (Evicted|Deleted) by the drain of node node for the OFED driver upgrade$`)))
+		Expect(events).To(ContainElement(MatchRegexp(
+			`^Normal OFEDUpgradeEviction (Evicted|Deleted) pod default/web-1 by the drain of node node`)))
+		Expect(events).NotTo(ContainElement(ContainSubstring("default/bare")))
+	})
+})
+
+var _ = Describe("DrainManager cordon settle tests", func() {
+	It("DrainManager should wait for pods landing on the cordoned node before the drain", func() {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}}
+		clientset := k8sfake.NewSimpleClientset(node)
+		landed := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "landed", Namespace: "default", OwnerReferences: []metav1.OwnerReference{
+				{Kind: "ReplicaSet", Name: "rs", Controller: func() *bool { b := true; return &b }()}}},
+			Spec:   corev1.PodSpec{NodeName: "node"},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+		podLists := 0
+		clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			podLists++
+			if podLists == 1 {
+				// the pod lands on the node right after the cordon
+				go func() {
+					time.Sleep(100 * time.Millisecond)
+					_ = clientset.Tracker().Add(landed)
+				}()
+			}
+			return false, nil, nil
+		})
+		stateProvider := &mocks.NodeUpgradeStateProvider{}
+		stateProvider.On("ChangeNodeUpgradeState", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		drainManager := upgrade.NewDrainManager(clientset, stateProvider, log)
+		drainSpec := &DrainSpec{Enable: true, TimeoutSecond: 1, CordonSettleSeconds: 1}
+		err := drainManager.ScheduleNodesDrain(context.TODO(),
+			&upgrade.DrainConfiguration{Nodes: []*corev1.Node{node}, Spec: drainSpec})
+		Expect(err).To(Succeed())
+
+		// the landed pod extends the wait to a second settle interval
+		Consistently(func() int { return len(stateProvider.Calls) }, 1500*time.Millisecond).Should(Equal(0))
+		Eventually(func() int { return len(stateProvider.Calls) }, 5*time.Second).Should(Equal(1))
+		stateProvider.AssertCalled(GinkgoT(), "ChangeNodeUpgradeState",
+			mock.Anything, mock.Anything, upgrade.UpgradeStatePodRestart)
+		// the landed pod is drained
+		_, err = clientset.Tracker().Get(corev1.SchemeGroupVersion.WithResource("pods"), "default", "landed")
+		Expect(err).To(HaveOccurred())
+	})
+	It("DrainTimeout should extend the drain timeout by the longest termination grace period", func() {
+		gracePeriod := int64(600)
+		pods := []corev1.Pod{
+			{ObjectMeta: metav1.ObjectMeta{Name: "default-grace-period"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "checkpointing"},
+				Spec: corev1.PodSpec{TerminationGracePeriodSeconds: &gracePeriod}},
+		}
+		helper := &drain.Helper{Timeout: 300 * time.Second, GracePeriodSeconds: -1}
+
+		// the termination grace period of the pods is honored by default
+		Expect(upgrade.DrainTimeout(helper, pods)).To(Equal(900 * time.Second))
+		// the default termination grace period of Kubernetes is used if the pod doesn't set it
+		Expect(upgrade.DrainTimeout(helper, pods[:1])).To(Equal(330 * time.Second))
+
+		// the grace period of the drain spec overrides the termination grace period of the pods
+		helper.GracePeriodSeconds = 120
+		Expect(upgrade.DrainTimeout(helper, pods)).To(Equal(420 * time.Second))
+
+		// infinite timeout stays infinite
+		helper.Timeout = 0
+		Expect(upgrade.DrainTimeout(helper, pods)).To(BeZero())
+	})
+})
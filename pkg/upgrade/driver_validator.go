@@ -0,0 +1,309 @@
+/*
+Copyright 2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/state"
+)
+
+const (
+	// maxDriverValidationOutputLength limits the output of the failed validation command kept
+	// in UpgradeDriverValidationFailedAnnotation, the end of the output is kept
+	maxDriverValidationOutputLength = 1024
+	// defaultDriverValidationTimeout is used if the validation doesn't specify the timeout
+	defaultDriverValidationTimeout = 30 * time.Second
+	// driverValidationStreamGracePeriod is the time the exec stream is waited for after the timeout of the command,
+	// the command is terminated in the container once its timeout expires, which ends the stream
+	driverValidationStreamGracePeriod = 10 * time.Second
+	// driverValidationJobStartTimeout is the time the validation Job may take to start its pod,
+	// e.g. to pull the image, in addition to the timeout of the command
+	driverValidationJobStartTimeout = 5 * time.Minute
+	// defaultDriverValidationJobPollInterval is the interval of the validation Job status checks
+	defaultDriverValidationJobPollInterval = 5 * time.Second
+	// driverValidationJobPrefix is the prefix of the validation Job name, followed by the node name
+	driverValidationJobPrefix = "ofed-driver-validation-"
+	// driverValidationContainerName is the name of the container of the validation Job
+	driverValidationContainerName = "driver-validation"
+)
+
+// DriverValidator is an interface that allows to validate the restarted driver by running a command in the driver pod
+// or in a Job on its node
+type DriverValidator interface {
+	// ValidateDriver runs the validation command in the driver container of the pod or in a Job on its node,
+	// the output of the command is returned, the error is not nil if the command has failed or could not be run
+	ValidateDriver(ctx context.Context, pod *corev1.Pod, validation *v1alpha1.DriverValidationSpec) (string, error)
+}
+
+// DriverValidatorImpl implements DriverValidator interface and runs the validation command through pod exec
+// or in a validation Job
+type DriverValidatorImpl struct {
+	k8sInterface kubernetes.Interface
+	restConfig   *rest.Config
+	log          logr.Logger
+	// NewExecutor creates the executor of the exec request, remotecommand.NewSPDYExecutor is used by default
+	NewExecutor func(config *rest.Config, method string, url *url.URL) (remotecommand.Executor, error)
+	// JobPollInterval is the interval of the validation Job status checks
+	JobPollInterval time.Duration
+}
+
+// ValidateDriver runs the validation command in the driver container through pod exec.
+// The command is wrapped with the timeout utility of the container, so it is terminated in the container
+// once the timeout of the validation expires, the exec stream is abandoned if it doesn't end shortly after
+func (v *DriverValidatorImpl) ValidateDriver(
+	ctx context.Context, pod *corev1.Pod, validation *v1alpha1.DriverValidationSpec) (string, error) {
+	v.log.V(consts.LogLevelInfo).Info("Running driver validation command", "pod", pod.Name)
+	timeout := time.Duration(validation.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultDriverValidationTimeout
+	}
+	command := append([]string{"timeout", strconv.Itoa(int(timeout.Seconds()))}, validation.Command...)
+	if validation.Job != nil {
+		return v.runValidationJob(ctx, pod, validation.Job, command, timeout)
+	}
+	req := v.k8sInterface.CoreV1().RESTClient().Post().
+		Resource("pods").Namespace(pod.Namespace).Name(pod.Name).SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: OfedDriverContainerName,
+			Command:   command,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+	executor, err := v.NewExecutor(v.restConfig, "POST", req.URL())
+	if err != nil {
+		return "", err
+	}
+
+	output := &syncBuffer{}
+	done := make(chan error, 1)
+	go func() {
+		done <- executor.Stream(remotecommand.StreamOptions{Stdout: output, Stderr: output})
+	}()
+	select {
+	case err = <-done:
+	case <-time.After(timeout + driverValidationStreamGracePeriod):
+		err = fmt.Errorf("validation command has not finished within %s", timeout)
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return output.String(), err
+}
+
+// runValidationJob runs the validation command in a Job on the node of the driver pod and waits for the Job
+// to finish, the output of the command is read from the log of the Job pod. The Job is owned by the driver pod,
+// so it is kept for inspection until the driver pod is deleted or the node is validated again
+func (v *DriverValidatorImpl) runValidationJob(ctx context.Context, pod *corev1.Pod,
+	jobSpec *v1alpha1.DriverValidationJobSpec, command []string, timeout time.Duration) (string, error) {
+	jobs := v.k8sInterface.BatchV1().Jobs(pod.Namespace)
+	job := driverValidationJob(pod, jobSpec, command, timeout+driverValidationJobStartTimeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout+driverValidationJobStartTimeout+driverValidationStreamGracePeriod)
+	defer cancel()
+
+	// the Job of the previous validation of the node is replaced
+	propagation := metav1.DeletePropagationBackground
+	err := jobs.Delete(ctx, job.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return "", fmt.Errorf("failed to delete previous validation Job %s: %v", job.Name, err)
+	}
+	err = wait.PollImmediateUntil(v.JobPollInterval, func() (bool, error) {
+		_, err := jobs.Create(ctx, job, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			// the previous Job is still being deleted
+			return false, nil
+		}
+		return err == nil, err
+	}, ctx.Done())
+	if err != nil {
+		return "", fmt.Errorf("failed to create validation Job %s: %v", job.Name, err)
+	}
+
+	var succeeded bool
+	err = wait.PollImmediateUntil(v.JobPollInterval, func() (bool, error) {
+		current, err := jobs.Get(ctx, job.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		succeeded = current.Status.Succeeded > 0
+		return succeeded || current.Status.Failed > 0 || isJobFailed(current), nil
+	}, ctx.Done())
+	if err == wait.ErrWaitTimeout {
+		return v.validationJobOutput(pod.Namespace, job.Name),
+			fmt.Errorf("validation Job %s has not finished within %s", job.Name, timeout)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get validation Job %s: %v", job.Name, err)
+	}
+	output := v.validationJobOutput(pod.Namespace, job.Name)
+	if !succeeded {
+		return output, fmt.Errorf("validation Job %s failed", job.Name)
+	}
+	return output, nil
+}
+
+// validationJobOutput returns the log of the validation Job pod, the output is empty if it can't be read
+func (v *DriverValidatorImpl) validationJobOutput(namespace, jobName string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), driverValidationStreamGracePeriod)
+	defer cancel()
+	pods, err := v.k8sInterface.CoreV1().Pods(namespace).List(
+		ctx, metav1.ListOptions{LabelSelector: "job-name=" + jobName})
+	if err != nil || len(pods.Items) == 0 {
+		return ""
+	}
+	logs, err := v.k8sInterface.CoreV1().Pods(namespace).GetLogs(pods.Items[0].Name,
+		&corev1.PodLogOptions{Container: driverValidationContainerName}).Do(ctx).Raw()
+	if err != nil {
+		v.log.V(consts.LogLevelWarning).Info("Failed to read the log of the validation Job",
+			"job", jobName, "error", err.Error())
+		return ""
+	}
+	return string(logs)
+}
+
+// driverValidationJob returns the Job which runs the validation command on the node of the driver pod,
+// the labels and annotations of the Job spec are set on the Job and its pod together with the managed ones
+func driverValidationJob(pod *corev1.Pod, jobSpec *v1alpha1.DriverValidationJobSpec, command []string,
+	deadline time.Duration) *batchv1.Job {
+	labels := make(map[string]string, len(jobSpec.Labels))
+	for key, value := range jobSpec.Labels {
+		labels[key] = value
+	}
+	labels = state.MergeManagedLabels(labels)
+	annotations := make(map[string]string, len(jobSpec.Annotations))
+	for key, value := range jobSpec.Annotations {
+		annotations[key] = value
+	}
+	annotations = state.MergeManagedAnnotations(annotations)
+	backoffLimit := int32(0)
+	deadlineSeconds := int64(deadline.Seconds())
+	privileged := true
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        driverValidationJobName(pod.Spec.NodeName),
+			Namespace:   pod.Namespace,
+			Labels:      labels,
+			Annotations: annotations,
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "v1", Kind: "Pod", Name: pod.Name, UID: pod.UID},
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          &backoffLimit,
+			ActiveDeadlineSeconds: &deadlineSeconds,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels, Annotations: annotations},
+				Spec: corev1.PodSpec{
+					NodeName:      pod.Spec.NodeName,
+					RestartPolicy: corev1.RestartPolicyNever,
+					HostNetwork:   true,
+					// the node is cordoned and may have the taints of the upgrade node marks
+					Tolerations: []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+					Containers: []corev1.Container{{
+						Name:            driverValidationContainerName,
+						Image:           jobSpec.Image,
+						Command:         command,
+						SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// driverValidationJobName returns the name of the validation Job of the node,
+// the node name is replaced with its hash if the name would be too long
+func driverValidationJobName(nodeName string) string {
+	name := driverValidationJobPrefix + nodeName
+	if len(name) <= validation.DNS1123LabelMaxLength {
+		return name
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(nodeName))
+	return fmt.Sprintf("%s%x", driverValidationJobPrefix, hash.Sum32())
+}
+
+// isJobFailed returns true if the Job has the Failed condition, e.g. once its deadline is exceeded
+func isJobFailed(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// syncBuffer is a buffer which can be written by the exec stream while the output is read after a timeout
+type syncBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
+
+// driverValidationFailure returns the value of UpgradeDriverValidationFailedAnnotation for the failed validation,
+// the output of the command or the error if there is no output
+func driverValidationFailure(output string, err error) string {
+	if output == "" {
+		output = err.Error()
+	}
+	if len(output) > maxDriverValidationOutputLength {
+		output = output[len(output)-maxDriverValidationOutputLength:]
+	}
+	return output
+}
+
+func NewDriverValidator(
+	k8sInterface kubernetes.Interface, restConfig *rest.Config, log logr.Logger) *DriverValidatorImpl {
+	return &DriverValidatorImpl{
+		k8sInterface:    k8sInterface,
+		restConfig:      restConfig,
+		log:             log,
+		NewExecutor:     remotecommand.NewSPDYExecutor,
+		JobPollInterval: defaultDriverValidationJobPollInterval,
+	}
+}
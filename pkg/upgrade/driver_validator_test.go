@@ -0,0 +1,257 @@
+/*
+Copyright 2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/upgrade"
+)
+
+// fakeExecutor writes the output to the stdout of the stream and returns the error,
+// the stream doesn't end until the block channel is closed if it is set
+type fakeExecutor struct {
+	output string
+	err    error
+	block  chan struct{}
+}
+
+func (e *fakeExecutor) Stream(options remotecommand.StreamOptions) error {
+	_, _ = options.Stdout.Write([]byte(e.output))
+	if e.block != nil {
+		<-e.block
+	}
+	return e.err
+}
+
+var _ = Describe("DriverValidator tests", func() {
+	var (
+		validator *upgrade.DriverValidatorImpl
+		executor  *fakeExecutor
+		execURL   *url.URL
+		pod       *corev1.Pod
+	)
+
+	BeforeEach(func() {
+		restConfig := &rest.Config{Host: "https://localhost:6443"}
+		clientset, err := kubernetes.NewForConfig(restConfig)
+		Expect(err).NotTo(HaveOccurred())
+		validator = upgrade.NewDriverValidator(clientset, restConfig, log)
+		executor = &fakeExecutor{}
+		execURL = nil
+		validator.NewExecutor = func(config *rest.Config, method string, url *url.URL) (remotecommand.Executor, error) {
+			Expect(method).To(Equal("POST"))
+			Expect(config).To(BeIdenticalTo(restConfig))
+			execURL = url
+			return executor, nil
+		}
+		pod = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "mofed-pod", Namespace: "network-operator"}}
+	})
+
+	It("DriverValidator should run the command with the timeout in the driver container", func() {
+		executor.output = "State: Active"
+		validation := &v1alpha1.DriverValidationSpec{Command: []string{"ibstat"}, TimeoutSeconds: 5}
+
+		output, err := validator.ValidateDriver(context.TODO(), pod, validation)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(output).To(Equal("State: Active"))
+		Expect(execURL.Path).To(Equal("/api/v1/namespaces/network-operator/pods/mofed-pod/exec"))
+		Expect(execURL.Query()["container"]).To(ConsistOf(upgrade.OfedDriverContainerName))
+		Expect(execURL.Query()["command"]).To(Equal([]string{"timeout", "5", "ibstat"}))
+		Expect(validation.Command).To(Equal([]string{"ibstat"}))
+	})
+	It("DriverValidator should use the default timeout", func() {
+		validation := &v1alpha1.DriverValidationSpec{Command: []string{"sh", "-c", "ibstat | grep -q Active"}}
+
+		_, err := validator.ValidateDriver(context.TODO(), pod, validation)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(execURL.Query()["command"]).To(Equal([]string{"timeout", "30", "sh", "-c", "ibstat | grep -q Active"}))
+	})
+	It("DriverValidator should return the output of the failed command", func() {
+		executor.output = "State: Down"
+		executor.err = errors.New("command terminated with exit code 1")
+
+		output, err := validator.ValidateDriver(
+			context.TODO(), pod, &v1alpha1.DriverValidationSpec{Command: []string{"ibstat"}})
+		Expect(err).To(MatchError("command terminated with exit code 1"))
+		Expect(output).To(Equal("State: Down"))
+	})
+	It("DriverValidator should return the error if the executor can't be created", func() {
+		validator.NewExecutor = func(*rest.Config, string, *url.URL) (remotecommand.Executor, error) {
+			return nil, errors.New("unsupported protocol")
+		}
+
+		_, err := validator.ValidateDriver(
+			context.TODO(), pod, &v1alpha1.DriverValidationSpec{Command: []string{"ibstat"}})
+		Expect(err).To(MatchError("unsupported protocol"))
+	})
+	It("DriverValidator should abandon the stream once the context is done", func() {
+		executor.output = "partial"
+		executor.block = make(chan struct{})
+		defer close(executor.block)
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+
+		output, err := validator.ValidateDriver(ctx, pod, &v1alpha1.DriverValidationSpec{Command: []string{"ibstat"}})
+		Expect(err).To(MatchError(context.Canceled))
+		Expect(output).To(Or(BeEmpty(), Equal("partial")))
+	})
+	Context("DriverValidator with validation Job", func() {
+		var (
+			clientset  *k8sfake.Clientset
+			validation *v1alpha1.DriverValidationSpec
+			result     chan error
+		)
+
+		BeforeEach(func() {
+			clientset = k8sfake.NewSimpleClientset()
+			validator = upgrade.NewDriverValidator(clientset, &rest.Config{}, log)
+			validator.JobPollInterval = 10 * time.Millisecond
+			pod.UID = "mofed-pod-uid"
+			pod.Spec.NodeName = "node1"
+			validation = &v1alpha1.DriverValidationSpec{
+				Command:        []string{"ibstat"},
+				TimeoutSeconds: 5,
+				Job: &v1alpha1.DriverValidationJobSpec{
+					Image:       "mellanox/validation:latest",
+					Labels:      map[string]string{"team": "network"},
+					Annotations: map[string]string{"monitoring/scrape": "false"},
+				},
+			}
+			result = make(chan error, 1)
+		})
+
+		getJob := func() (*batchv1.Job, error) {
+			return clientset.BatchV1().Jobs("network-operator").Get(
+				context.TODO(), "ofed-driver-validation-node1", metav1.GetOptions{})
+		}
+		validate := func() {
+			go func() {
+				defer GinkgoRecover()
+				_, err := validator.ValidateDriver(context.TODO(), pod, validation)
+				result <- err
+			}()
+		}
+		finishJob := func(status batchv1.JobStatus) {
+			var job *batchv1.Job
+			Eventually(func() error {
+				var err error
+				job, err = getJob()
+				return err
+			}).Should(Succeed())
+			job.Status = status
+			_, err := clientset.BatchV1().Jobs("network-operator").UpdateStatus(
+				context.TODO(), job, metav1.UpdateOptions{})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		It("DriverValidator should run the command in a Job on the node of the driver pod", func() {
+			validate()
+			finishJob(batchv1.JobStatus{Succeeded: 1})
+			Eventually(result).Should(Receive(BeNil()))
+
+			job, err := getJob()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(job.Labels).To(HaveKeyWithValue("team", "network"))
+			Expect(job.Labels).To(HaveKeyWithValue(consts.ManagedByLabel, consts.ManagedByLabelValue))
+			Expect(job.Annotations).To(HaveKeyWithValue("monitoring/scrape", "false"))
+			Expect(job.Spec.Template.Labels).To(Equal(job.Labels))
+			Expect(job.Spec.Template.Annotations).To(Equal(job.Annotations))
+			Expect(job.OwnerReferences).To(HaveLen(1))
+			Expect(job.OwnerReferences[0].UID).To(Equal(pod.UID))
+			Expect(job.Spec.Template.Spec.NodeName).To(Equal("node1"))
+			Expect(job.Spec.Template.Spec.Containers).To(HaveLen(1))
+			container := job.Spec.Template.Spec.Containers[0]
+			Expect(container.Image).To(Equal("mellanox/validation:latest"))
+			Expect(container.Command).To(Equal([]string{"timeout", "5", "ibstat"}))
+			Expect(validation.Job.Labels).To(Equal(map[string]string{"team": "network"}))
+		})
+		It("DriverValidator should return the output of the failed validation Job", func() {
+			_, err := clientset.CoreV1().Pods("network-operator").Create(context.TODO(), &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "ofed-driver-validation-node1-abcde",
+					Namespace: "network-operator",
+					Labels:    map[string]string{"job-name": "ofed-driver-validation-node1"},
+				},
+			}, metav1.CreateOptions{})
+			Expect(err).NotTo(HaveOccurred())
+
+			var output string
+			go func() {
+				defer GinkgoRecover()
+				var err error
+				output, err = validator.ValidateDriver(context.TODO(), pod, validation)
+				result <- err
+			}()
+			finishJob(batchv1.JobStatus{Failed: 1})
+			Eventually(result).Should(Receive(MatchError("validation Job ofed-driver-validation-node1 failed")))
+			Expect(output).To(Equal("fake logs"))
+		})
+		It("DriverValidator should replace the Job of the previous validation", func() {
+			_, err := clientset.BatchV1().Jobs("network-operator").Create(context.TODO(), &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "ofed-driver-validation-node1", Namespace: "network-operator"},
+				Status:     batchv1.JobStatus{Failed: 1},
+			}, metav1.CreateOptions{})
+			Expect(err).NotTo(HaveOccurred())
+
+			validate()
+			Eventually(func() string {
+				job, err := getJob()
+				if err != nil {
+					return ""
+				}
+				return job.Labels["team"]
+			}).Should(Equal("network"))
+			finishJob(batchv1.JobStatus{Succeeded: 1})
+			Eventually(result).Should(Receive(BeNil()))
+		})
+		It("DriverValidator should retry to create the Job while the previous Job is being deleted", func() {
+			var creates int32
+			clientset.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if atomic.AddInt32(&creates, 1) > 1 {
+					return false, nil, nil
+				}
+				return true, nil, apierrors.NewAlreadyExists(
+					batchv1.Resource("jobs"), "ofed-driver-validation-node1")
+			})
+
+			validate()
+			finishJob(batchv1.JobStatus{Succeeded: 1})
+			Eventually(result).Should(Receive(BeNil()))
+			Expect(atomic.LoadInt32(&creates)).To(Equal(int32(2)))
+		})
+	})
+})
@@ -0,0 +1,210 @@
+/*
+Copyright 2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/Mellanox/network-operator/pkg/consts"
+	"github.com/Mellanox/network-operator/pkg/utils"
+)
+
+const (
+	eventSinkQueueSize        = 100
+	eventSinkRequestTimeout   = 10 * time.Second
+	defaultEventSinkRetries   = 3
+	defaultEventSinkBackoff   = time.Second
+	defaultEventSinkThreshold = 5
+	defaultEventSinkOpenTime  = 5 * time.Minute
+)
+
+// UpgradeEvent describes a node upgrade state change
+type UpgradeEvent struct {
+	Node      string    `json:"node"`
+	FromState string    `json:"fromState"`
+	ToState   string    `json:"toState"`
+	Reason    string    `json:"reason,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// UpgradeEventSink receives node upgrade state changes, Send must not block the caller
+type UpgradeEventSink interface {
+	Send(event UpgradeEvent)
+}
+
+// HTTPEventSink posts UpgradeEvents as JSON to the configured URL from a background worker.
+// Failed requests are retried with exponential backoff, after FailureThreshold consecutive events fail to be
+// delivered, the circuit is opened and events are dropped for OpenDuration, then a single event is tried again.
+// Events are dropped as well if the sink is not configured or the queue is full, so that the sink never stalls
+// the upgrade flow
+type HTTPEventSink struct {
+	// Retries is the number of delivery attempts of an event
+	Retries int
+	// Backoff is the time to wait before the first retry, it is doubled after each retry
+	Backoff time.Duration
+	// FailureThreshold is the number of consecutive undelivered events which opens the circuit
+	FailureThreshold int
+	// OpenDuration is the time the events are dropped once the circuit is open
+	OpenDuration time.Duration
+
+	log    logr.Logger
+	client *http.Client
+	events chan UpgradeEvent
+
+	mutex      sync.Mutex
+	url        string
+	authHeader string
+	failures   int
+	openUntil  time.Time
+}
+
+// NewHTTPEventSink creates HTTPEventSink which doesn't send events until it is configured,
+// the sink must be started to deliver the events. HTTPS endpoints are verified with the root CAs,
+// the system CAs are used if they are nil
+func NewHTTPEventSink(log logr.Logger, rootCAs *x509.CertPool) *HTTPEventSink {
+	return &HTTPEventSink{
+		Retries:          defaultEventSinkRetries,
+		Backoff:          defaultEventSinkBackoff,
+		FailureThreshold: defaultEventSinkThreshold,
+		OpenDuration:     defaultEventSinkOpenTime,
+		log:              log,
+		client:           utils.NewHTTPClient(eventSinkRequestTimeout, rootCAs),
+		events:           make(chan UpgradeEvent, eventSinkQueueSize),
+	}
+}
+
+// Configure sets the URL and the Authorization header value of the requests, empty URL disables the sink.
+// The circuit is closed if the URL changes
+func (s *HTTPEventSink) Configure(url, authHeader string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.url != url {
+		s.failures = 0
+		s.openUntil = time.Time{}
+	}
+	s.url = url
+	s.authHeader = authHeader
+}
+
+// Send queues the event for delivery, the event is dropped if the sink is not configured,
+// the circuit is open or the queue is full
+func (s *HTTPEventSink) Send(event UpgradeEvent) {
+	s.mutex.Lock()
+	url, open := s.url, time.Now().Before(s.openUntil)
+	s.mutex.Unlock()
+	if url == "" {
+		return
+	}
+	if open {
+		s.log.V(consts.LogLevelWarning).Info("Upgrade event sink circuit is open, dropping event",
+			"node", event.Node, "state", event.ToState)
+		return
+	}
+	select {
+	case s.events <- event:
+	default:
+		s.log.V(consts.LogLevelWarning).Info("Upgrade event sink queue is full, dropping event",
+			"node", event.Node, "state", event.ToState)
+	}
+}
+
+// Start delivers the queued events until the context is canceled, it implements manager.Runnable
+func (s *HTTPEventSink) Start(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-s.events:
+			s.deliver(ctx, event)
+		}
+	}
+}
+
+// deliver posts the event, retrying failed requests, and updates the circuit state
+func (s *HTTPEventSink) deliver(ctx context.Context, event UpgradeEvent) {
+	s.mutex.Lock()
+	url, authHeader, open := s.url, s.authHeader, time.Now().Before(s.openUntil)
+	s.mutex.Unlock()
+	// the sink could be reconfigured or the circuit opened while the event was queued
+	if url == "" || open {
+		return
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		s.log.V(consts.LogLevelError).Error(err, "Failed to marshal upgrade event")
+		return
+	}
+	backoff := s.Backoff
+	for attempt := 1; ; attempt++ {
+		err = s.post(ctx, url, authHeader, body)
+		if err == nil || attempt >= s.Retries {
+			break
+		}
+		s.log.V(consts.LogLevelDebug).Info("Failed to post upgrade event, retrying",
+			"node", event.Node, "attempt", attempt, "error", err.Error())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err == nil {
+		s.failures = 0
+		return
+	}
+	s.failures++
+	s.log.V(consts.LogLevelWarning).Info("Failed to post upgrade event", "node", event.Node,
+		"state", event.ToState, "consecutiveFailures", s.failures, "error", err.Error())
+	if s.failures >= s.FailureThreshold {
+		s.openUntil = time.Now().Add(s.OpenDuration)
+		s.log.V(consts.LogLevelWarning).Info("Upgrade event sink circuit is open", "until", s.openUntil)
+	}
+}
+
+func (s *HTTPEventSink) post(ctx context.Context, url, authHeader string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if authHeader != "" {
+		req.Header.Set("Authorization", authHeader)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	return nil
+}
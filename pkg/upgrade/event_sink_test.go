@@ -0,0 +1,117 @@
+/*
+Copyright 2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/Mellanox/network-operator/pkg/upgrade"
+)
+
+var _ = Describe("HTTPEventSink tests", func() {
+	var (
+		mutex    sync.Mutex
+		received []upgrade.UpgradeEvent
+		headers  []string
+		status   int
+		server   *httptest.Server
+		sink     *upgrade.HTTPEventSink
+		cancel   context.CancelFunc
+	)
+	receivedEvents := func() []upgrade.UpgradeEvent {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]upgrade.UpgradeEvent(nil), received...)
+	}
+
+	BeforeEach(func() {
+		received, headers, status = nil, nil, http.StatusOK
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			headers = append(headers, r.Header.Get("Authorization"))
+			event := upgrade.UpgradeEvent{}
+			Expect(json.NewDecoder(r.Body).Decode(&event)).To(Succeed())
+			if status == http.StatusOK {
+				received = append(received, event)
+			}
+			w.WriteHeader(status)
+		}))
+		sink = upgrade.NewHTTPEventSink(log, nil)
+		sink.Backoff = time.Millisecond
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		go func() { _ = sink.Start(ctx) }()
+	})
+	AfterEach(func() {
+		cancel()
+		server.Close()
+	})
+
+	It("Should post events with the Authorization header", func() {
+		sink.Configure(server.URL, "Bearer token")
+		sink.Send(upgrade.UpgradeEvent{Node: "node", FromState: "upgrade-required", ToState: "drain"})
+
+		Eventually(receivedEvents).Should(HaveLen(1))
+		Expect(receivedEvents()[0].Node).To(Equal("node"))
+		Expect(receivedEvents()[0].ToState).To(Equal("drain"))
+		Expect(headers).To(Equal([]string{"Bearer token"}))
+	})
+	It("Should not post events if not configured", func() {
+		sink.Send(upgrade.UpgradeEvent{Node: "node", ToState: "drain"})
+		Consistently(func() int {
+			mutex.Lock()
+			defer mutex.Unlock()
+			return len(headers)
+		}, 200*time.Millisecond).Should(BeZero())
+	})
+	It("Should retry failed requests and open the circuit after consecutive failures", func() {
+		sink.Retries = 2
+		sink.FailureThreshold = 1
+		sink.OpenDuration = time.Hour
+		sink.Configure(server.URL, "")
+		mutex.Lock()
+		status = http.StatusServiceUnavailable
+		mutex.Unlock()
+		sink.Send(upgrade.UpgradeEvent{Node: "node", ToState: "drain"})
+		Eventually(func() int {
+			mutex.Lock()
+			defer mutex.Unlock()
+			return len(headers)
+		}).Should(Equal(2))
+
+		// events are dropped while the circuit is open
+		mutex.Lock()
+		status = http.StatusOK
+		mutex.Unlock()
+		sink.Send(upgrade.UpgradeEvent{Node: "node", ToState: "pod-restart"})
+		Consistently(receivedEvents, 200*time.Millisecond).Should(BeEmpty())
+
+		// reconfiguring the endpoint closes the circuit
+		sink.Configure(server.URL+"/new", "")
+		sink.Send(upgrade.UpgradeEvent{Node: "node", ToState: "pod-restart"})
+		Eventually(receivedEvents).Should(HaveLen(1))
+	})
+})
@@ -0,0 +1,206 @@
+/*
+Copyright 2022 NVIDIA CORPORATION & AFFILIATES
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/kubectl/pkg/drain"
+)
+
+const (
+	// EvictionGroupVersionV1 is the group version of Eviction served by Kubernetes 1.22 and newer
+	EvictionGroupVersionV1 = "policy/v1"
+	// EvictionGroupVersionV1beta1 is the group version of Eviction served by older Kubernetes versions
+	EvictionGroupVersionV1beta1 = "policy/v1beta1"
+
+	evictionRetryInterval = 5 * time.Second
+	deletionPollInterval  = time.Second
+	// defaultDrainTimeout is used when the drain timeout is not set, the same value is used by kubectl
+	defaultDrainTimeout = 365 * 24 * time.Hour
+	// defaultTerminationGracePeriod is used by Kubernetes when the pod doesn't set terminationGracePeriodSeconds
+	defaultTerminationGracePeriod = 30 * time.Second
+)
+
+// DetectEvictionGroupVersion uses Discovery API to find out the group version of Eviction objects
+// accepted by the pods/eviction subresource. Empty string is returned if the eviction is not supported.
+func DetectEvictionGroupVersion(k8sInterface kubernetes.Interface) (string, error) {
+	resourceList, err := k8sInterface.Discovery().ServerResourcesForGroupVersion("v1")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get core v1 resources")
+	}
+	for _, resource := range resourceList.APIResources {
+		if resource.Name != drain.EvictionSubresource || resource.Kind != drain.EvictionKind {
+			continue
+		}
+		if resource.Group != "" && resource.Version != "" {
+			return resource.Group + "/" + resource.Version, nil
+		}
+		// group version of the subresource is not reported, use the preferred version of the policy group
+		return drain.CheckEvictionSupport(k8sInterface)
+	}
+	return "", nil
+}
+
+// runNodeDrain evicts or deletes pods on the node using Eviction group version detected on startup
+func (m *DrainManagerImpl) runNodeDrain(helper *drain.Helper, nodeName string) error {
+	list, errs := helper.GetPodsForDeletion(nodeName)
+	if errs != nil {
+		return utilerrors.NewAggregate(errs)
+	}
+	// the helper is shared by the nodes drained in parallel, the timeout is set for this node only
+	nodeHelper := *helper
+	nodeHelper.Timeout = DrainTimeout(helper, list.Pods())
+
+	// drain helper supports only policy/v1beta1 Eviction objects
+	if m.evictionGroupVersion != EvictionGroupVersionV1 {
+		return drain.RunNodeDrain(&nodeHelper, nodeName)
+	}
+
+	if warnings := list.Warnings(); warnings != "" {
+		fmt.Fprintf(helper.ErrOut, "WARNING: %s\n", warnings)
+	}
+	return EvictPodsV1(&nodeHelper, list.Pods())
+}
+
+// DrainTimeout extends the drain timeout by the longest termination grace period of the pods,
+// so that the drain doesn't give up on the pods which are still terminating gracefully
+func DrainTimeout(helper *drain.Helper, pods []corev1.Pod) time.Duration {
+	if helper.Timeout == 0 {
+		// infinite timeout
+		return 0
+	}
+	var maxGracePeriod time.Duration
+	for i := range pods {
+		gracePeriod := defaultTerminationGracePeriod
+		switch {
+		case helper.GracePeriodSeconds >= 0:
+			gracePeriod = time.Duration(helper.GracePeriodSeconds) * time.Second
+		case pods[i].Spec.TerminationGracePeriodSeconds != nil:
+			gracePeriod = time.Duration(*pods[i].Spec.TerminationGracePeriodSeconds) * time.Second
+		}
+		if gracePeriod > maxGracePeriod {
+			maxGracePeriod = gracePeriod
+		}
+	}
+	return helper.Timeout + maxGracePeriod
+}
+
+// EvictPodsV1 evicts pods concurrently using policy/v1 Eviction and waits until the pods are deleted,
+// like the drain helper does for policy/v1beta1. The errors of all pods are returned
+func EvictPodsV1(helper *drain.Helper, pods []corev1.Pod) error {
+	timeout := helper.Timeout
+	if timeout == 0 {
+		timeout = defaultDrainTimeout
+	}
+	ctx, cancel := context.WithTimeout(helper.Ctx, timeout)
+	defer cancel()
+
+	results := make(chan error, len(pods))
+	for i := range pods {
+		pod := pods[i]
+		go func() {
+			results <- evictPodAndWaitV1(ctx, helper, pod)
+		}()
+	}
+	var errs []error
+	for range pods {
+		if err := <-results; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// evictPodAndWaitV1 evicts the pod using policy/v1 Eviction, the eviction is retried while it is blocked
+// by a PodDisruptionBudget, then waits until the pod is deleted
+func evictPodAndWaitV1(ctx context.Context, helper *drain.Helper, pod corev1.Pod) error {
+	err := wait.PollImmediateUntil(evictionRetryInterval, func() (bool, error) {
+		err := evictPodV1(ctx, helper, pod)
+		switch {
+		case err == nil || apierrors.IsNotFound(err):
+			return true, nil
+		case apierrors.IsTooManyRequests(err):
+			// eviction is blocked by a PodDisruptionBudget, retry later
+			fmt.Fprintf(helper.ErrOut, "error when evicting pod %q (will retry after %s): %v\n",
+				pod.Name, evictionRetryInterval, err)
+			return false, nil
+		default:
+			return false, err
+		}
+	}, ctx.Done())
+	if err != nil {
+		return errors.Wrapf(err, "failed to evict pod %s/%s", pod.Namespace, pod.Name)
+	}
+
+	err = wait.PollImmediateUntil(deletionPollInterval, func() (bool, error) {
+		current, err := helper.Client.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) || (err == nil && current.UID != pod.UID) {
+			return true, nil
+		}
+		return false, err
+	}, ctx.Done())
+	if err != nil {
+		return errors.Wrapf(err, "failed to wait for pod %s/%s deletion", pod.Namespace, pod.Name)
+	}
+	if helper.OnPodDeletedOrEvicted != nil {
+		helper.OnPodDeletedOrEvicted(&pod, true)
+	}
+	return nil
+}
+
+// evictPodV1 creates policy/v1 Eviction for the pod.
+// policy/v1 Eviction has the same schema as policy/v1beta1 one, so v1beta1 type is used with v1 group version
+func evictPodV1(ctx context.Context, helper *drain.Helper, pod corev1.Pod) error {
+	deleteOptions := metav1.DeleteOptions{}
+	if helper.GracePeriodSeconds >= 0 {
+		gracePeriodSeconds := int64(helper.GracePeriodSeconds)
+		deleteOptions.GracePeriodSeconds = &gracePeriodSeconds
+	}
+	eviction := &policyv1beta1.Eviction{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: EvictionGroupVersionV1,
+			Kind:       drain.EvictionKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+		},
+		DeleteOptions: &deleteOptions,
+	}
+	body, err := json.Marshal(eviction)
+	if err != nil {
+		return err
+	}
+	return helper.Client.CoreV1().RESTClient().Post().
+		Namespace(pod.Namespace).
+		Resource("pods").
+		Name(pod.Name).
+		SubResource("eviction").
+		SetHeader("Content-Type", "application/json").
+		Body(body).
+		Do(ctx).
+		Error()
+}
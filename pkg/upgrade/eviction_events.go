@@ -0,0 +1,54 @@
+/*
+Copyright 2022 NVIDIA CORPORATION & AFFILIATES
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// UpgradeEvictionEventReason is the reason of the events recorded on the pods evicted or deleted by the drain
+// and on their controllers, so that application teams can correlate disruptions to the OFED driver upgrade
+const UpgradeEvictionEventReason = "OFEDUpgradeEviction"
+
+// recordEvictionEvents records an event on the pod removed from the node by the drain and on the controller
+// of the pod, if any. Nothing is recorded if the drain manager has no event recorder
+func (m *DrainManagerImpl) recordEvictionEvents(pod *corev1.Pod, nodeName string, usingEviction bool) {
+	if m.EventRecorder == nil {
+		return
+	}
+	verb := "Deleted"
+	if usingEviction {
+		verb = "Evicted"
+	}
+	m.EventRecorder.Eventf(pod, corev1.EventTypeNormal, UpgradeEvictionEventReason,
+		"%s by the drain of node %s for the OFED driver upgrade", verb, nodeName)
+
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return
+	}
+	ownerRef := &corev1.ObjectReference{
+		APIVersion: owner.APIVersion,
+		Kind:       owner.Kind,
+		Namespace:  pod.Namespace,
+		Name:       owner.Name,
+		UID:        owner.UID,
+	}
+	m.EventRecorder.Event(ownerRef, corev1.EventTypeNormal, UpgradeEvictionEventReason,
+		fmt.Sprintf("%s pod %s/%s by the drain of node %s for the OFED driver upgrade",
+			verb, pod.Namespace, pod.Name, nodeName))
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/kubectl/pkg/drain"
+
+	"github.com/Mellanox/network-operator/pkg/upgrade"
+)
+
+var _ = Describe("Eviction tests", func() {
+	const podCount = 3
+	var (
+		server   *httptest.Server
+		mu       sync.Mutex
+		evicting int
+		allSent  chan struct{}
+		evicted  []string
+		helper   *drain.Helper
+	)
+
+	BeforeEach(func() {
+		evicting = 0
+		evicted = nil
+		allSent = make(chan struct{})
+		// the evictions are blocked until all of them are sent, so sequential evictions would time out
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/eviction") {
+				body, _ := ioutil.ReadAll(r.Body)
+				Expect(string(body)).To(ContainSubstring(`"apiVersion":"policy/v1"`))
+				mu.Lock()
+				evicting++
+				if evicting == podCount {
+					close(allSent)
+				}
+				mu.Unlock()
+				select {
+				case <-allSent:
+					w.WriteHeader(http.StatusCreated)
+				case <-time.After(2 * time.Second):
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+				return
+			}
+			// the evicted pods are deleted
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`))
+		}))
+		clientset, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+		Expect(err).NotTo(HaveOccurred())
+		helper = &drain.Helper{
+			Ctx:                context.TODO(),
+			Client:             clientset,
+			GracePeriodSeconds: -1,
+			Timeout:            10 * time.Second,
+			OnPodDeletedOrEvicted: func(pod *corev1.Pod, usingEviction bool) {
+				defer GinkgoRecover()
+				Expect(usingEviction).To(BeTrue())
+				mu.Lock()
+				evicted = append(evicted, pod.Name)
+				mu.Unlock()
+			},
+			ErrOut: GinkgoWriter,
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("EvictPodsV1 should evict the pods concurrently and wait for their deletion", func() {
+		var pods []corev1.Pod
+		for _, name := range []string{"pod-a", "pod-b", "pod-c"} {
+			pods = append(pods, corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}})
+		}
+
+		Expect(upgrade.EvictPodsV1(helper, pods)).To(Succeed())
+		Expect(evicted).To(ConsistOf("pod-a", "pod-b", "pod-c"))
+	})
+	It("EvictPodsV1 should return the errors of the pods which failed to be evicted", func() {
+		pods := []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pod-a"}}}
+
+		err := upgrade.EvictPodsV1(helper, pods)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("failed to evict pod default/pod-a"))
+		Expect(evicted).To(BeEmpty())
+	})
+})
@@ -0,0 +1,100 @@
+/*
+Copyright 2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade
+
+import (
+	"context"
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+
+	"github.com/Mellanox/network-operator/pkg/consts"
+)
+
+// deleteFinishedPods deletes Succeeded and Failed pods on the node without the termination grace period,
+// so that the drain doesn't wait for the graceful eviction of pods which are not running anymore.
+// Pods which would be recreated by their controller after the deletion are left to the drain
+func (m *DrainManagerImpl) deleteFinishedPods(ctx context.Context, nodeName, podSelector string) error {
+	pods, err := m.k8sInterface.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		LabelSelector: podSelector,
+		FieldSelector: fields.SelectorFromSet(fields.Set{"spec.nodeName": nodeName}).String(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list pods on node %s: %v", nodeName, err)
+	}
+	gracePeriodSeconds := int64(0)
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+			continue
+		}
+		if m.recreatedByController(ctx, pod) {
+			m.log.V(consts.LogLevelDebug).Info("Finished pod would be recreated by its controller, skipping",
+				"pod", pod.Namespace+"/"+pod.Name)
+			continue
+		}
+		err := m.k8sInterface.CoreV1().Pods(pod.Namespace).Delete(
+			ctx, pod.Name, metav1.DeleteOptions{GracePeriodSeconds: &gracePeriodSeconds})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete finished pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		}
+		m.log.V(consts.LogLevelInfo).Info("Deleted finished pod", "node", nodeName,
+			"pod", pod.Namespace+"/"+pod.Name, "phase", pod.Status.Phase)
+	}
+	return nil
+}
+
+// recreatedByController returns true if the controller of the finished pod may create the pod again
+// once it is deleted: StatefulSet pods and pods of Jobs which are not complete or failed yet.
+// Pods of Jobs which can't be read, e.g. because of missing permissions, are treated as not recreated
+func (m *DrainManagerImpl) recreatedByController(ctx context.Context, pod *corev1.Pod) bool {
+	controller := metav1.GetControllerOf(pod)
+	if controller == nil {
+		return false
+	}
+	switch controller.Kind {
+	case "StatefulSet":
+		return true
+	case "Job":
+		job, err := m.k8sInterface.BatchV1().Jobs(pod.Namespace).Get(ctx, controller.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return false
+		}
+		if err != nil {
+			m.log.V(consts.LogLevelWarning).Info("Failed to get Job of the finished pod, deleting the pod",
+				"pod", pod.Namespace+"/"+pod.Name, "error", err.Error())
+			return false
+		}
+		return !isJobFinished(job)
+	}
+	return false
+}
+
+// isJobFinished returns true if the Job is complete or failed, the Job controller doesn't create pods for it anymore
+func isJobFinished(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if (condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed) &&
+			condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
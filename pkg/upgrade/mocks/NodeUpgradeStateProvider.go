@@ -24,6 +24,20 @@ type NodeUpgradeStateProvider struct {
 	mock.Mock
 }
 
+// ChangeNodeUpgradeAnnotation provides a mock function with given fields: ctx, node, key, value
+func (_m *NodeUpgradeStateProvider) ChangeNodeUpgradeAnnotation(ctx context.Context, node *v1.Node, key string, value string) error {
+	ret := _m.Called(ctx, node, key, value)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *v1.Node, string, string) error); ok {
+		r0 = rf(ctx, node, key, value)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // ChangeNodeUpgradeState provides a mock function with given fields: ctx, node, newNodeState
 func (_m *NodeUpgradeStateProvider) ChangeNodeUpgradeState(ctx context.Context, node *v1.Node, newNodeState string) error {
 	ret := _m.Called(ctx, node, newNodeState)
@@ -38,6 +52,20 @@ func (_m *NodeUpgradeStateProvider) ChangeNodeUpgradeState(ctx context.Context,
 	return r0
 }
 
+// PruneNodeUpgradeAnnotations provides a mock function with given fields: ctx, node
+func (_m *NodeUpgradeStateProvider) PruneNodeUpgradeAnnotations(ctx context.Context, node *v1.Node) error {
+	ret := _m.Called(ctx, node)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *v1.Node) error); ok {
+		r0 = rf(ctx, node)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // GetNode provides a mock function with given fields: ctx, nodeName
 func (_m *NodeUpgradeStateProvider) GetNode(ctx context.Context, nodeName string) (*v1.Node, error) {
 	ret := _m.Called(ctx, nodeName)
@@ -18,12 +18,27 @@ import context "context"
 import mock "github.com/stretchr/testify/mock"
 
 import v1 "k8s.io/api/core/v1"
+import v1alpha1 "github.com/Mellanox/network-operator/api/v1alpha1"
 
 // UncordonManager is an autogenerated mock type for the UncordonManager type
 type UncordonManager struct {
 	mock.Mock
 }
 
+// AddNodeUpgradeMarks provides a mock function with given fields: ctx, node, marks
+func (_m *UncordonManager) AddNodeUpgradeMarks(ctx context.Context, node *v1.Node, marks *v1alpha1.UpgradeNodeMarksSpec) error {
+	ret := _m.Called(ctx, node, marks)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *v1.Node, *v1alpha1.UpgradeNodeMarksSpec) error); ok {
+		r0 = rf(ctx, node, marks)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // CordonOrUncordonNode provides a mock function with given fields: ctx, node, desired
 func (_m *UncordonManager) CordonOrUncordonNode(ctx context.Context, node *v1.Node, desired bool) error {
 	ret := _m.Called(ctx, node, desired)
@@ -37,3 +52,68 @@ func (_m *UncordonManager) CordonOrUncordonNode(ctx context.Context, node *v1.No
 
 	return r0
 }
+
+// NotReadyDaemonSetPods provides a mock function with given fields: ctx, node, podSelector
+func (_m *UncordonManager) NotReadyDaemonSetPods(ctx context.Context, node *v1.Node, podSelector string) ([]string, error) {
+	ret := _m.Called(ctx, node, podSelector)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, *v1.Node, string) []string); ok {
+		r0 = rf(ctx, node, podSelector)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *v1.Node, string) error); ok {
+		r1 = rf(ctx, node, podSelector)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PauseDevicePlugins provides a mock function with given fields: ctx, node
+func (_m *UncordonManager) PauseDevicePlugins(ctx context.Context, node *v1.Node) error {
+	ret := _m.Called(ctx, node)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *v1.Node) error); ok {
+		r0 = rf(ctx, node)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RemoveNodeUpgradeMarks provides a mock function with given fields: ctx, node
+func (_m *UncordonManager) RemoveNodeUpgradeMarks(ctx context.Context, node *v1.Node) error {
+	ret := _m.Called(ctx, node)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *v1.Node) error); ok {
+		r0 = rf(ctx, node)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ResumeDevicePlugins provides a mock function with given fields: ctx, node
+func (_m *UncordonManager) ResumeDevicePlugins(ctx context.Context, node *v1.Node) error {
+	ret := _m.Called(ctx, node)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *v1.Node) error); ok {
+		r0 = rf(ctx, node)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/Mellanox/network-operator/api/v1alpha1"
+)
+
+// appliedNodeMarks is stored in UpgradeNodeMarksAnnotation, only the marks that were applied are removed
+// after the upgrade even if the upgrade policy changes in the meantime
+type appliedNodeMarks struct {
+	Labels []string   `json:"labels,omitempty"`
+	Taints []v1.Taint `json:"taints,omitempty"`
+}
+
+// AddNodeUpgradeMarks adds labels and taints from the upgrade node marks to the node object
+// and records them in UpgradeNodeMarksAnnotation, labels and taints the node already has are not recorded
+func AddNodeUpgradeMarks(node *v1.Node, marks *v1alpha1.UpgradeNodeMarksSpec) error {
+	applied := appliedNodeMarks{}
+	if node.Labels == nil && len(marks.Labels) != 0 {
+		node.Labels = make(map[string]string)
+	}
+	for key, value := range marks.Labels {
+		if current, ok := node.Labels[key]; ok && current == value {
+			continue
+		}
+		node.Labels[key] = value
+		applied.Labels = append(applied.Labels, key)
+	}
+	sort.Strings(applied.Labels)
+	for i := range marks.Taints {
+		taint := marks.Taints[i]
+		if hasTaint(node, &taint) {
+			continue
+		}
+		node.Spec.Taints = append(node.Spec.Taints, taint)
+		applied.Taints = append(applied.Taints, v1.Taint{Key: taint.Key, Effect: taint.Effect})
+	}
+	data, err := json.Marshal(applied)
+	if err != nil {
+		return fmt.Errorf("failed to marshal upgrade node marks: %v", err)
+	}
+	if node.Annotations == nil {
+		node.Annotations = make(map[string]string)
+	}
+	node.Annotations[UpgradeNodeMarksAnnotation] = string(data)
+	return nil
+}
+
+// RemoveNodeUpgradeMarks removes the labels and taints recorded in UpgradeNodeMarksAnnotation from the node object,
+// false is returned if the node has no recorded marks
+func RemoveNodeUpgradeMarks(node *v1.Node) bool {
+	value, ok := node.Annotations[UpgradeNodeMarksAnnotation]
+	if !ok {
+		return false
+	}
+	applied := appliedNodeMarks{}
+	// the annotation is removed even if it is malformed
+	_ = json.Unmarshal([]byte(value), &applied)
+	for _, key := range applied.Labels {
+		delete(node.Labels, key)
+	}
+	var taints []v1.Taint
+	for i := range node.Spec.Taints {
+		if !isAppliedTaint(&applied, &node.Spec.Taints[i]) {
+			taints = append(taints, node.Spec.Taints[i])
+		}
+	}
+	node.Spec.Taints = taints
+	delete(node.Annotations, UpgradeNodeMarksAnnotation)
+	return true
+}
+
+// hasTaint returns true if the node has a taint with the same key and effect
+func hasTaint(node *v1.Node, taint *v1.Taint) bool {
+	for i := range node.Spec.Taints {
+		if node.Spec.Taints[i].MatchTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+func isAppliedTaint(applied *appliedNodeMarks, taint *v1.Taint) bool {
+	for i := range applied.Taints {
+		if applied.Taints[i].MatchTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
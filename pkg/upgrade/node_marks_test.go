@@ -0,0 +1,54 @@
+/*
+Copyright 2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/upgrade"
+)
+
+var _ = Describe("Node upgrade marks tests", func() {
+	It("Should remove only the labels and taints added for the upgrade", func() {
+		ownTaint := corev1.Taint{Key: "own", Effect: corev1.TaintEffectNoSchedule}
+		node := &corev1.Node{}
+		node.Labels = map[string]string{"own": "true", "example.com/upgrading": "true"}
+		node.Spec.Taints = []corev1.Taint{ownTaint}
+
+		marks := &v1alpha1.UpgradeNodeMarksSpec{
+			Labels: map[string]string{"example.com/upgrading": "true", "example.com/phase": "drain"},
+			Taints: []corev1.Taint{
+				{Key: "example.com/upgrading", Value: "true", Effect: corev1.TaintEffectNoSchedule},
+				ownTaint,
+			},
+		}
+		Expect(upgrade.AddNodeUpgradeMarks(node, marks)).To(Succeed())
+		Expect(node.Labels).To(HaveKeyWithValue("example.com/phase", "drain"))
+		Expect(node.Spec.Taints).To(HaveLen(2))
+		Expect(node.Annotations).To(HaveKey(upgrade.UpgradeNodeMarksAnnotation))
+
+		Expect(upgrade.RemoveNodeUpgradeMarks(node)).To(BeTrue())
+		Expect(node.Labels).To(Equal(map[string]string{"own": "true", "example.com/upgrading": "true"}))
+		Expect(node.Spec.Taints).To(Equal([]corev1.Taint{ownTaint}))
+		Expect(node.Annotations).NotTo(HaveKey(upgrade.UpgradeNodeMarksAnnotation))
+
+		Expect(upgrade.RemoveNodeUpgradeMarks(node)).To(BeFalse())
+	})
+})
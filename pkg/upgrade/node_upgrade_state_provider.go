@@ -15,11 +15,13 @@ package upgrade
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/go-logr/logr"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -32,11 +34,16 @@ import (
 type NodeUpgradeStateProvider interface {
 	GetNode(ctx context.Context, nodeName string) (*v1.Node, error)
 	ChangeNodeUpgradeState(ctx context.Context, node *v1.Node, newNodeState string) error
+	ChangeNodeUpgradeAnnotation(ctx context.Context, node *v1.Node, key string, value string) error
+	// PruneNodeUpgradeAnnotations removes the annotations of past upgrades from a node in UpgradeStateDone
+	PruneNodeUpgradeAnnotations(ctx context.Context, node *v1.Node) error
 }
 
 type NodeUpgradeStateProviderImpl struct {
 	K8sClient client.Client
 	Log       logr.Logger
+	// EventSink is notified about node upgrade state changes if set
+	EventSink UpgradeEventSink
 	nodeMutex KeyedMutex
 }
 
@@ -61,23 +68,59 @@ func (p *NodeUpgradeStateProviderImpl) GetNode(ctx context.Context, nodeName str
 
 // ChangeNodeUpgradeState patches a given v1.Node object and updates its UpgradeStateAnnotation with a given value
 // The function then waits for the operator cache to get updated
+// State changes which are not listed in StateTransitions are rejected
+// The patch is applied only if the node was not changed since the given object was read, i.e. the decision to change
+// the state was not made on a stale object, otherwise a Conflict error is returned and the change must be re-evaluated
+// EventSink is notified once the state is changed
 func (p *NodeUpgradeStateProviderImpl) ChangeNodeUpgradeState(
 	ctx context.Context, node *v1.Node, newNodeState string) error {
 	p.Log.V(consts.LogLevelInfo).Info("Updating node upgrade state",
 		"node", node.Name,
 		"new state", newNodeState)
 
+	currentState := node.Annotations[UpgradeStateAnnotation]
+	if !IsStateTransitionAllowed(currentState, newNodeState) {
+		return fmt.Errorf("node %s upgrade state transition from %q to %q is not allowed",
+			node.Name, currentState, newNodeState)
+	}
+
 	defer p.nodeMutex.Lock(node.Name)()
 
-	patchString := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q: %q}}}`, UpgradeStateAnnotation, newNodeState))
+	annotations := map[string]string{UpgradeStateAnnotation: newNodeState}
+	if currentState != newNodeState {
+		annotations[UpgradeStateTimestampAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	}
+	metadata := map[string]interface{}{"annotations": annotations}
+	if node.ResourceVersion != "" {
+		// resourceVersion in the patch is a precondition, the API server rejects the patch of a newer node object
+		metadata["resourceVersion"] = node.ResourceVersion
+	}
+	patchString, err := json.Marshal(map[string]interface{}{"metadata": metadata})
+	if err != nil {
+		return err
+	}
 	patch := client.RawPatch(types.StrategicMergePatchType, patchString)
-	err := p.K8sClient.Patch(ctx, node, patch)
+	err = p.K8sClient.Patch(ctx, node, patch)
+	if apierrors.IsConflict(err) {
+		p.Log.V(consts.LogLevelWarning).Info("Node object is stale, upgrade state is not changed",
+			"node", node.Name, "resourceVersion", node.ResourceVersion, "new state", newNodeState)
+		return err
+	}
 	if err != nil {
 		p.Log.V(consts.LogLevelError).Error(err, "Failed to patch node state annotation on a node object",
 			"node", node,
 			"state", newNodeState)
 		return err
 	}
+	if p.EventSink != nil && currentState != newNodeState {
+		p.EventSink.Send(UpgradeEvent{
+			Node:      node.Name,
+			FromState: stateDisplayName(currentState),
+			ToState:   stateDisplayName(newNodeState),
+			Reason:    transitionReason(currentState, newNodeState),
+			Timestamp: time.Now().UTC(),
+		})
+	}
 
 	// Upgrade controller is watching on a set of different resources (NicClusterPolicy, DaemonSet, Pods)
 	// Because of that, when a new Reconcile event is triggered, the operator cache might not have the latest changes
@@ -117,3 +160,154 @@ func (p *NodeUpgradeStateProviderImpl) ChangeNodeUpgradeState(
 
 	return err
 }
+
+// ChangeNodeUpgradeAnnotation patches a given v1.Node object and updates the annotation with a given value.
+// If the value is "null", the annotation is removed from the node.
+// The function then waits for the operator cache to get updated
+func (p *NodeUpgradeStateProviderImpl) ChangeNodeUpgradeAnnotation(
+	ctx context.Context, node *v1.Node, key string, value string) error {
+	p.Log.V(consts.LogLevelInfo).Info("Updating node upgrade annotation",
+		"node", node.Name,
+		"annotationKey", key,
+		"annotationValue", value)
+
+	defer p.nodeMutex.Lock(node.Name)()
+
+	var patchString []byte
+	if value == "null" {
+		patchString = []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q: null}}}`, key))
+	} else {
+		patchString = []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q: %q}}}`, key, value))
+	}
+	patch := client.RawPatch(types.StrategicMergePatchType, patchString)
+	err := p.K8sClient.Patch(ctx, node, patch)
+	if err != nil {
+		p.Log.V(consts.LogLevelError).Error(err, "Failed to patch node annotation",
+			"node", node,
+			"annotationKey", key,
+			"annotationValue", value)
+		return err
+	}
+
+	// Wait for the operator cache to get updated, see ChangeNodeUpgradeState for details
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Second*10)
+	defer cancel()
+	err = wait.PollImmediateUntil(time.Second, func() (bool, error) {
+		err := p.K8sClient.Get(timeoutCtx, types.NamespacedName{Name: node.Name}, node)
+		if err != nil {
+			return false, err
+		}
+		currentValue, exists := node.Annotations[key]
+		if value == "null" {
+			return !exists, nil
+		}
+		return currentValue == value, nil
+	}, timeoutCtx.Done())
+
+	if err != nil {
+		p.Log.V(consts.LogLevelError).Error(err, "Error while waiting on node annotation update",
+			"node", node,
+			"annotationKey", key,
+			"annotationValue", value)
+	} else {
+		p.Log.V(consts.LogLevelInfo).Info("Successfully changed node upgrade annotation",
+			"node", node.Name,
+			"annotationKey", key,
+			"annotationValue", value)
+	}
+
+	return err
+}
+
+// PruneNodeUpgradeAnnotations removes the ObsoleteUpgradeAnnotations from a node in UpgradeStateDone with a single
+// patch, nothing is done if the node has none of them. The function then waits for the operator cache to get updated
+func (p *NodeUpgradeStateProviderImpl) PruneNodeUpgradeAnnotations(ctx context.Context, node *v1.Node) error {
+	if state := node.Annotations[UpgradeStateAnnotation]; state != UpgradeStateDone {
+		return fmt.Errorf("upgrade annotations of node %s can't be pruned in upgrade state %q", node.Name, state)
+	}
+	obsolete := ObsoleteUpgradeAnnotations(node)
+	if len(obsolete) == 0 {
+		return nil
+	}
+	p.Log.V(consts.LogLevelInfo).Info("Pruning obsolete node upgrade annotations",
+		"node", node.Name, "annotations", obsolete)
+
+	defer p.nodeMutex.Lock(node.Name)()
+
+	annotations := make(map[string]interface{}, len(obsolete))
+	for _, key := range obsolete {
+		annotations[key] = nil
+	}
+	patchString, err := json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{
+		"annotations": annotations}})
+	if err != nil {
+		return err
+	}
+	err = p.K8sClient.Patch(ctx, node, client.RawPatch(types.StrategicMergePatchType, patchString))
+	if err != nil {
+		p.Log.V(consts.LogLevelError).Error(err, "Failed to prune node upgrade annotations", "node", node.Name)
+		return err
+	}
+
+	// Wait for the operator cache to get updated, see ChangeNodeUpgradeState for details
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Second*10)
+	defer cancel()
+	err = wait.PollImmediateUntil(time.Second, func() (bool, error) {
+		// read into a new object, decoding into the given node would keep the removed annotations in its map
+		current := &v1.Node{}
+		err := p.K8sClient.Get(timeoutCtx, types.NamespacedName{Name: node.Name}, current)
+		if err != nil {
+			return false, err
+		}
+		*node = *current
+		return len(ObsoleteUpgradeAnnotations(node)) == 0, nil
+	}, timeoutCtx.Done())
+	if err != nil {
+		p.Log.V(consts.LogLevelError).Error(err, "Error while waiting on node annotations update",
+			"node", node.Name)
+	}
+	return err
+}
+
+// ObsoleteUpgradeAnnotations returns the upgrade annotations of the node which have no meaning once the node
+// has finished its upgrade: the annotations of the drain, soak and uncordon steps and the accepted forced driver
+// reload request. UpgradeNodeMarksAnnotation is never obsolete, as it tracks the node marks still to be removed
+func ObsoleteUpgradeAnnotations(node *v1.Node) []string {
+	var obsolete []string
+	for _, key := range []string{
+		UpgradeSoakStartTimestampAnnotation,
+		UpgradeUncordonRetriesAnnotation,
+		UpgradeUncordonCheckTimestampAnnotation,
+		UpgradeUncordonPodsWaitStartAnnotation,
+		UpgradeDevicePluginsPausedAnnotation,
+		UpgradeBarePodsAnnotation,
+		UpgradeDrainBlockedAnnotation,
+		UpgradeManualRequiredAnnotation,
+	} {
+		if _, ok := node.Annotations[key]; ok {
+			obsolete = append(obsolete, key)
+		}
+	}
+	if _, ok := node.Annotations[UpgradeQuarantineCordonAnnotation]; ok && !node.Spec.Unschedulable {
+		obsolete = append(obsolete, UpgradeQuarantineCordonAnnotation)
+	}
+	// "true" is a new request which is not accepted yet
+	if value, ok := node.Annotations[ForceDriverReloadAnnotation]; ok && value != "true" {
+		obsolete = append(obsolete, ForceDriverReloadAnnotation)
+	}
+	return obsolete
+}
+
+// GetNodeUpgradeStateDuration returns the time the node has spent in its current upgrade state,
+// false is returned if the time when the node has entered the state is not known
+func GetNodeUpgradeStateDuration(node *v1.Node) (time.Duration, bool) {
+	value, ok := node.Annotations[UpgradeStateTimestampAnnotation]
+	if !ok {
+		return 0, false
+	}
+	since, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, false
+	}
+	return time.Since(since), true
+}
@@ -18,13 +18,28 @@ package upgrade_test
 
 import (
 	"context"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	"github.com/Mellanox/network-operator/pkg/upgrade"
 )
 
+// recordingEventSink records the sent upgrade events
+type recordingEventSink struct {
+	events []upgrade.UpgradeEvent
+}
+
+func (s *recordingEventSink) Send(event upgrade.UpgradeEvent) {
+	s.events = append(s.events, event)
+}
+
 var _ = Describe("NodeUpgradeStateProvider tests", func() {
 	It("NodeUpgradeStateProvider should change node upgrade state and retrieve the latest node object", func() {
 		ctx := context.TODO()
@@ -39,4 +54,119 @@ var _ = Describe("NodeUpgradeStateProvider tests", func() {
 		Expect(err).To(Succeed())
 		Expect(node.Annotations[upgrade.UpgradeStateAnnotation]).To(Equal(upgrade.UpgradeStateUpgradeRequired))
 	})
+	It("NodeUpgradeStateProvider should reject the state change of a stale node object", func() {
+		ctx := context.TODO()
+		fakeClient := fake.NewClientBuilder().WithObjects(&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "stale-node",
+				Annotations: map[string]string{upgrade.UpgradeStateAnnotation: upgrade.UpgradeStateUpgradeRequired},
+			},
+		}).Build()
+		provider := upgrade.NewNodeUpgradeStateProvider(fakeClient, log)
+
+		staleNode, err := provider.GetNode(ctx, "stale-node")
+		Expect(err).To(Succeed())
+		latestNode := staleNode.DeepCopy()
+		latestNode.Spec.Unschedulable = true
+		Expect(fakeClient.Update(ctx, latestNode)).To(Succeed())
+
+		err = provider.ChangeNodeUpgradeState(ctx, staleNode, upgrade.UpgradeStateDrain)
+		Expect(apierrors.IsConflict(err)).To(BeTrue())
+		node := &corev1.Node{}
+		Expect(fakeClient.Get(ctx, types.NamespacedName{Name: "stale-node"}, node)).To(Succeed())
+		Expect(node.Annotations[upgrade.UpgradeStateAnnotation]).To(Equal(upgrade.UpgradeStateUpgradeRequired))
+
+		Expect(provider.ChangeNodeUpgradeState(ctx, node, upgrade.UpgradeStateDrain)).To(Succeed())
+		Expect(node.Annotations[upgrade.UpgradeStateAnnotation]).To(Equal(upgrade.UpgradeStateDrain))
+	})
+	It("NodeUpgradeStateProvider should record the time the node has entered the upgrade state", func() {
+		ctx := context.TODO()
+		enteredAt := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+		fakeClient := fake.NewClientBuilder().WithObjects(&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "timestamp-node",
+				Annotations: map[string]string{
+					upgrade.UpgradeStateAnnotation:          upgrade.UpgradeStateDrain,
+					upgrade.UpgradeStateTimestampAnnotation: enteredAt,
+				},
+			},
+		}).Build()
+		provider := upgrade.NewNodeUpgradeStateProvider(fakeClient, log)
+
+		node, err := provider.GetNode(ctx, "timestamp-node")
+		Expect(err).To(Succeed())
+		Expect(provider.ChangeNodeUpgradeState(ctx, node, upgrade.UpgradeStateDrain)).To(Succeed())
+		Expect(node.Annotations[upgrade.UpgradeStateTimestampAnnotation]).To(Equal(enteredAt))
+		duration, ok := upgrade.GetNodeUpgradeStateDuration(node)
+		Expect(ok).To(BeTrue())
+		Expect(duration).To(BeNumerically(">=", time.Hour))
+
+		Expect(provider.ChangeNodeUpgradeState(ctx, node, upgrade.UpgradeStatePodRestart)).To(Succeed())
+		duration, ok = upgrade.GetNodeUpgradeStateDuration(node)
+		Expect(ok).To(BeTrue())
+		Expect(duration).To(BeNumerically("<", time.Minute))
+	})
+	It("NodeUpgradeStateProvider should prune obsolete annotations of a node in Done state", func() {
+		ctx := context.TODO()
+		acceptedAt := time.Now().UTC().Format(time.RFC3339)
+		fakeClient := fake.NewClientBuilder().WithObjects(&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "done-node",
+				Annotations: map[string]string{
+					upgrade.UpgradeStateAnnotation:               upgrade.UpgradeStateUpgradeRequired,
+					upgrade.UpgradeDoneTimestampAnnotation:       acceptedAt,
+					upgrade.UpgradeNodeMarksAnnotation:           `{"labels":{"upgrading":"true"}}`,
+					upgrade.UpgradeSoakStartTimestampAnnotation:  acceptedAt,
+					upgrade.UpgradeDevicePluginsPausedAnnotation: acceptedAt,
+					upgrade.ForceDriverReloadAnnotation:          acceptedAt,
+				},
+			},
+		}).Build()
+		provider := upgrade.NewNodeUpgradeStateProvider(fakeClient, log)
+
+		node, err := provider.GetNode(ctx, "done-node")
+		Expect(err).To(Succeed())
+		Expect(provider.PruneNodeUpgradeAnnotations(ctx, node)).NotTo(Succeed())
+
+		Expect(provider.ChangeNodeUpgradeState(ctx, node, upgrade.UpgradeStateDone)).To(Succeed())
+		Expect(provider.PruneNodeUpgradeAnnotations(ctx, node)).To(Succeed())
+		Expect(node.Annotations).To(HaveKey(upgrade.UpgradeStateAnnotation))
+		Expect(node.Annotations).To(HaveKey(upgrade.UpgradeDoneTimestampAnnotation))
+		Expect(node.Annotations).To(HaveKey(upgrade.UpgradeNodeMarksAnnotation))
+		Expect(node.Annotations).NotTo(HaveKey(upgrade.UpgradeSoakStartTimestampAnnotation))
+		Expect(node.Annotations).NotTo(HaveKey(upgrade.UpgradeDevicePluginsPausedAnnotation))
+		Expect(node.Annotations).NotTo(HaveKey(upgrade.ForceDriverReloadAnnotation))
+	})
+	It("NodeUpgradeStateProvider should keep a forced driver reload request which is not accepted yet", func() {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+			upgrade.UpgradeStateAnnotation:      upgrade.UpgradeStateDone,
+			upgrade.ForceDriverReloadAnnotation: "true",
+		}}}
+		Expect(upgrade.ObsoleteUpgradeAnnotations(node)).To(BeEmpty())
+	})
+	It("NodeUpgradeStateProvider should keep the quarantine cordon annotation while the node is cordoned", func() {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+			upgrade.UpgradeStateAnnotation:            upgrade.UpgradeStateDone,
+			upgrade.UpgradeQuarantineCordonAnnotation: upgrade.UpgradeQuarantineCordonMessage,
+		}}, Spec: corev1.NodeSpec{Unschedulable: true}}
+		Expect(upgrade.ObsoleteUpgradeAnnotations(node)).To(BeEmpty())
+
+		node.Spec.Unschedulable = false
+		Expect(upgrade.ObsoleteUpgradeAnnotations(node)).To(ConsistOf(upgrade.UpgradeQuarantineCordonAnnotation))
+	})
+	It("NodeUpgradeStateProvider should report both states of the event with their display names", func() {
+		ctx := context.TODO()
+		fakeClient := fake.NewClientBuilder().WithObjects(&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "new-node"}}).Build()
+		sink := &recordingEventSink{}
+		provider := upgrade.NewNodeUpgradeStateProvider(fakeClient, log)
+		provider.(*upgrade.NodeUpgradeStateProviderImpl).EventSink = sink
+
+		node, err := provider.GetNode(ctx, "new-node")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(provider.ChangeNodeUpgradeState(ctx, node, upgrade.UpgradeStateDone)).To(Succeed())
+		Expect(sink.events).To(HaveLen(1))
+		Expect(sink.events[0].FromState).To(Equal("unknown"))
+		Expect(sink.events[0].ToState).To(Equal(upgrade.UpgradeStateDone))
+	})
 })
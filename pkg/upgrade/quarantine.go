@@ -0,0 +1,148 @@
+/*
+Copyright 2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Mellanox/network-operator/pkg/consts"
+)
+
+// IsNodeQuarantined returns true if the node is excluded from the upgrade flow with OfedUpgradeQuarantinedLabel
+func IsNodeQuarantined(node *v1.Node) bool {
+	return node.Labels[OfedUpgradeQuarantinedLabel] == "true"
+}
+
+// getNodeUpgradeFailures returns the number of failed upgrades of the node in UpgradeFailuresAnnotation
+func getNodeUpgradeFailures(node *v1.Node) int {
+	failures, err := strconv.Atoi(node.Annotations[UpgradeFailuresAnnotation])
+	if err != nil || failures < 0 {
+		return 0
+	}
+	return failures
+}
+
+// recordUpgradeFailure sets the failed outcome of the last upgrade in the history of the node and increments
+// the number of failed upgrades of the node, it is called before the node is moved to a failed state
+func recordUpgradeFailure(ctx context.Context, provider NodeUpgradeStateProvider, log logr.Logger,
+	node *v1.Node) error {
+	if err := recordUpgradeOutcome(ctx, provider, log, node, UpgradeOutcomeFailed, ""); err != nil {
+		return err
+	}
+	failures := strconv.Itoa(getNodeUpgradeFailures(node) + 1)
+	err := provider.ChangeNodeUpgradeAnnotation(ctx, node, UpgradeFailuresAnnotation, failures)
+	if err != nil {
+		log.V(consts.LogLevelError).Error(err, "Failed to record upgrade failure", "node", node.Name)
+	}
+	return err
+}
+
+// ProcessQuarantinedNodes quarantines the nodes in UpgradeStateDrainFailed or UpgradeStateFailed state
+// which have failed the upgrade quarantineAfterFailures times: the node is cordoned and labeled with
+// OfedUpgradeQuarantinedLabel, the upgrade flow skips it until the label is removed.
+// Once the label is removed from a node quarantined by the upgrade flow, its failures are reset
+// and the node continues the upgrade from its current state. Zero quarantineAfterFailures disables the quarantine,
+// nodes which are quarantined already stay quarantined
+func (m *ClusterUpgradeStateManager) ProcessQuarantinedNodes(ctx context.Context,
+	currentClusterState *ClusterUpgradeState, quarantineAfterFailures int) error {
+	m.Log.V(consts.LogLevelInfo).Info("ProcessQuarantinedNodes")
+	for stateName, nodeStates := range currentClusterState.NodeStates {
+		for _, nodeState := range nodeStates {
+			node := nodeState.Node
+			_, quarantinedByUpgrade := node.Annotations[UpgradeQuarantinedAnnotation]
+			switch {
+			case IsNodeQuarantined(node):
+				continue
+			case quarantinedByUpgrade:
+				m.Log.V(consts.LogLevelInfo).Info("Quarantine label was removed, node upgrade is re-enabled",
+					"node", node.Name)
+				err := m.removeNodeUpgradeAnnotations(ctx, node, UpgradeQuarantinedAnnotation, UpgradeFailuresAnnotation)
+				if err != nil {
+					return err
+				}
+			case quarantineAfterFailures > 0 && getNodeUpgradeFailures(node) >= quarantineAfterFailures &&
+				(stateName == UpgradeStateDrainFailed || stateName == UpgradeStateFailed):
+				if err := m.quarantineNode(ctx, node); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// quarantineNode cordons the node and labels it with OfedUpgradeQuarantinedLabel
+func (m *ClusterUpgradeStateManager) quarantineNode(ctx context.Context, node *v1.Node) error {
+	m.Log.V(consts.LogLevelWarning).Info("Node failed the upgrade too many times, quarantining the node",
+		"node", node.Name, "failures", getNodeUpgradeFailures(node))
+	if err := m.UncordonManager.CordonOrUncordonNode(ctx, node, true); err != nil {
+		m.Log.V(consts.LogLevelError).Error(err, "Failed to cordon the quarantined node", "node", node.Name)
+		return err
+	}
+	err := m.NodeUpgradeStateProvider.ChangeNodeUpgradeAnnotation(
+		ctx, node, UpgradeQuarantinedAnnotation, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		m.Log.V(consts.LogLevelError).Error(err, "Failed to set quarantined annotation", "node", node.Name)
+		return err
+	}
+	patch := client.MergeFrom(node.DeepCopy())
+	if node.Labels == nil {
+		node.Labels = map[string]string{}
+	}
+	node.Labels[OfedUpgradeQuarantinedLabel] = "true"
+	if err := m.K8sClient.Patch(ctx, node, patch); err != nil {
+		m.Log.V(consts.LogLevelError).Error(err, "Failed to label the quarantined node", "node", node.Name)
+		return err
+	}
+	return nil
+}
+
+// withoutQuarantinedNodes returns the cluster upgrade state without the quarantined nodes
+func withoutQuarantinedNodes(currentClusterState *ClusterUpgradeState) *ClusterUpgradeState {
+	result := NewClusterUpgradeState()
+	result.ApprovedImages = currentClusterState.ApprovedImages
+	result.MinDriverVersion = currentClusterState.MinDriverVersion
+	for stateName, nodeStates := range currentClusterState.NodeStates {
+		for _, nodeState := range nodeStates {
+			if !IsNodeQuarantined(nodeState.Node) {
+				result.NodeStates[stateName] = append(result.NodeStates[stateName], nodeState)
+			}
+		}
+	}
+	return &result
+}
+
+// QuarantinedNodes returns the sorted list of nodes which are excluded from the upgrade flow
+func (m *ClusterUpgradeStateManager) QuarantinedNodes(currentClusterState *ClusterUpgradeState) []string {
+	var result []string
+	for _, nodeStates := range currentClusterState.NodeStates {
+		for _, nodeState := range nodeStates {
+			if IsNodeQuarantined(nodeState.Node) {
+				result = append(result, nodeState.Node.Name)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/upgrade"
+	"github.com/Mellanox/network-operator/pkg/utils"
+)
+
+var _ = Describe("Upgrade quarantine tests", func() {
+	var fakeClient client.Client
+
+	failedNode := func(state, failures string) *corev1.Node {
+		node := nodeWithUpgradeState(state)
+		node.Name = "node-" + state
+		node.Annotations[upgrade.UpgradeFailuresAnnotation] = failures
+		fakeClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(node.DeepCopy()).Build()
+		return node
+	}
+	stateManager := func() *upgrade.ClusterUpgradeStateManager {
+		return upgrade.NewClusterUpdateStateManager(
+			&drainManager, &podDeleteManager, &uncordonManager, &nodeUpgradeStateProvider, log, fakeClient, k8sInterface)
+	}
+	clusterStateWith := func(state string, node *corev1.Node) *upgrade.ClusterUpgradeState {
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[state] = []*upgrade.NodeUpgradeState{{Node: node}}
+		return &clusterState
+	}
+	labelOf := func(node *corev1.Node) (string, bool) {
+		stored := &corev1.Node{}
+		Expect(fakeClient.Get(context.TODO(), types.NamespacedName{Name: node.Name}, stored)).To(Succeed())
+		value, ok := stored.Labels[upgrade.OfedUpgradeQuarantinedLabel]
+		return value, ok
+	}
+
+	It("Should count the failed upgrades of the node", func() {
+		node := failedNode(upgrade.UpgradeStatePodRestart, "1")
+		pod := &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{Labels: map[string]string{utils.PodTemplateGenerationLabel: "2"}},
+			Status:     corev1.PodStatus{Phase: corev1.PodFailed},
+		}
+		daemonSet := &appsv1.DaemonSet{ObjectMeta: v1.ObjectMeta{Generation: 2}}
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStatePodRestart] = []*upgrade.NodeUpgradeState{{
+			Node: node, DriverPod: pod, DriverDaemonSet: daemonSet,
+		}}
+
+		Expect(stateManager().ApplyState(context.TODO(), &clusterState,
+			&v1alpha1.OfedUpgradePolicySpec{AutoUpgrade: true})).To(Succeed())
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateFailed))
+		Expect(node.Annotations[upgrade.UpgradeFailuresAnnotation]).To(Equal("2"))
+	})
+
+	It("Should quarantine the failed node once the failures reach the threshold", func() {
+		node := failedNode(upgrade.UpgradeStateDrainFailed, "3")
+		clusterState := clusterStateWith(upgrade.UpgradeStateDrainFailed, node)
+
+		Expect(stateManager().ProcessQuarantinedNodes(context.TODO(), clusterState, 3)).To(Succeed())
+		Expect(upgrade.IsNodeQuarantined(node)).To(BeTrue())
+		Expect(node.Annotations).To(HaveKey(upgrade.UpgradeQuarantinedAnnotation))
+		value, ok := labelOf(node)
+		Expect(ok).To(BeTrue())
+		Expect(value).To(Equal("true"))
+		Expect(stateManager().QuarantinedNodes(clusterState)).To(Equal([]string{node.Name}))
+	})
+
+	It("Should not quarantine the node below the threshold or if the quarantine is disabled", func() {
+		node := failedNode(upgrade.UpgradeStateFailed, "2")
+		clusterState := clusterStateWith(upgrade.UpgradeStateFailed, node)
+
+		Expect(stateManager().ProcessQuarantinedNodes(context.TODO(), clusterState, 3)).To(Succeed())
+		Expect(stateManager().ProcessQuarantinedNodes(context.TODO(), clusterState, 0)).To(Succeed())
+		Expect(upgrade.IsNodeQuarantined(node)).To(BeFalse())
+		_, ok := labelOf(node)
+		Expect(ok).To(BeFalse())
+		Expect(stateManager().QuarantinedNodes(clusterState)).To(BeEmpty())
+	})
+
+	It("Should not quarantine the node which is not in a failed state", func() {
+		node := failedNode(upgrade.UpgradeStateDone, "5")
+		clusterState := clusterStateWith(upgrade.UpgradeStateDone, node)
+
+		Expect(stateManager().ProcessQuarantinedNodes(context.TODO(), clusterState, 3)).To(Succeed())
+		Expect(upgrade.IsNodeQuarantined(node)).To(BeFalse())
+	})
+
+	It("Should reset the failures once the quarantine label is removed", func() {
+		node := failedNode(upgrade.UpgradeStateFailed, "3")
+		node.Annotations[upgrade.UpgradeQuarantinedAnnotation] = "2022-01-01T00:00:00Z"
+		clusterState := clusterStateWith(upgrade.UpgradeStateFailed, node)
+
+		Expect(stateManager().ProcessQuarantinedNodes(context.TODO(), clusterState, 3)).To(Succeed())
+		Expect(node.Annotations).NotTo(HaveKey(upgrade.UpgradeQuarantinedAnnotation))
+		Expect(node.Annotations).NotTo(HaveKey(upgrade.UpgradeFailuresAnnotation))
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateFailed))
+	})
+
+	It("Should not count the quarantined nodes as failures of the upgrade", func() {
+		node := failedNode(upgrade.UpgradeStateFailed, "3")
+		node.Labels = map[string]string{upgrade.OfedUpgradeQuarantinedLabel: "true"}
+		clusterState := clusterStateWith(upgrade.UpgradeStateFailed, node)
+
+		Expect(stateManager().IsUpgradeAborted(clusterState,
+			&v1alpha1.OfedUpgradePolicySpec{AutoUpgrade: true, MaxFailures: 1})).To(BeFalse())
+	})
+})
@@ -0,0 +1,180 @@
+/*
+Copyright 2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// AnyUpgradeState matches every node upgrade state in StateTransition.From
+	AnyUpgradeState = "*"
+
+	// DescribeFormatText prints the states and transitions as human readable text
+	DescribeFormatText = "text"
+	// DescribeFormatDot prints the states and transitions as a Graphviz DOT diagram
+	DescribeFormatDot = "dot"
+)
+
+// StateDescription describes a node upgrade state
+type StateDescription struct {
+	Name        string
+	Description string
+}
+
+// StateTransition describes a legal change of the node upgrade state and the condition which triggers it
+type StateTransition struct {
+	From   string
+	To     string
+	Reason string
+}
+
+// upgradeStates contains all node upgrade states in the order of the upgrade flow
+var upgradeStates = []StateDescription{
+	{UpgradeStateUnknown, "upgrade flow is disabled or the node hasn't been processed yet"},
+	{UpgradeStateDone, "OFED driver pod is up to date and running on the node, the node is schedulable"},
+	{UpgradeStateUpgradeRequired, "OFED driver pod on the node is not up to date, the node waits for an upgrade slot"},
+	{UpgradeStatePendingApproval, "target OFED driver image is not approved yet"},
+	{UpgradeStateDrain, "node is cordoned and scheduled for drain"},
+	{UpgradeStateDrainFailed, "drain of the node has failed, manual interaction is required"},
+	{UpgradeStatePodRestart, "OFED driver pod on the node is scheduled for restart"},
+	{UpgradeStatePostUpgradeSoak, "restarted OFED driver pod must stay healthy for the soak period"},
+	{UpgradeStateUncordonRequired, "OFED driver pod is up to date and Ready, the node is to be uncordoned"},
+	{UpgradeStateFailed, "restarted OFED driver pod failed or the node or its pods didn't become Ready after uncordon"},
+}
+
+// stateTransitions contains all node upgrade state changes performed by the upgrade flow,
+// NodeUpgradeStateProvider rejects any other state change
+var stateTransitions = []StateTransition{
+	{UpgradeStateUnknown, UpgradeStateDone, "OFED driver pod is up to date"},
+	{UpgradeStateUnknown, UpgradeStateUpgradeRequired,
+		"OFED driver pod is not up to date or forced driver reload is requested"},
+	{UpgradeStateDone, UpgradeStateUpgradeRequired,
+		"OFED driver pod is not up to date or forced driver reload is requested"},
+	{UpgradeStateUpgradeRequired, UpgradeStatePendingApproval,
+		"upgrade policy requires approval and the target image is not approved"},
+	{UpgradeStateUpgradeRequired, UpgradeStateDrain, "upgrade slot is available"},
+	{UpgradeStateUpgradeRequired, UpgradeStateDone,
+		"OFED driver pod was restarted manually on a node of a node group which doesn't allow disruption"},
+	{UpgradeStatePendingApproval, UpgradeStateUpgradeRequired,
+		"target image is approved or approval is no longer required"},
+	{UpgradeStatePendingApproval, UpgradeStateDone, "upgrade is no longer required"},
+	{UpgradeStateDrain, UpgradeStatePodRestart, "node is drained or drain is disabled by upgrade policy"},
+	{UpgradeStateDrain, UpgradeStateDrainFailed, "drain of the node failed or timed out"},
+	{UpgradeStatePodRestart, UpgradeStatePostUpgradeSoak, "restarted OFED driver pod is Ready and soak is enabled"},
+	{UpgradeStatePodRestart, UpgradeStateUncordonRequired,
+		"restarted OFED driver pod is Ready and soak is disabled"},
+	{UpgradeStatePodRestart, UpgradeStateFailed, "restarted OFED driver pod failed to start"},
+	{UpgradeStateDrainFailed, UpgradeStatePostUpgradeSoak, "OFED driver pod is up to date and Ready, soak is enabled"},
+	{UpgradeStateDrainFailed, UpgradeStateUncordonRequired,
+		"OFED driver pod is up to date and Ready, soak is disabled"},
+	{UpgradeStatePostUpgradeSoak, UpgradeStatePodRestart, "OFED driver was updated during the soak"},
+	{UpgradeStatePostUpgradeSoak, UpgradeStateFailed, "OFED driver pod failed or restarted during the soak"},
+	{UpgradeStatePostUpgradeSoak, UpgradeStateUncordonRequired, "soak period is over"},
+	{UpgradeStateFailed, UpgradeStatePostUpgradeSoak, "OFED driver pod recovered, soak is enabled"},
+	{UpgradeStateFailed, UpgradeStateUncordonRequired, "OFED driver pod recovered, soak is disabled"},
+	{UpgradeStateUncordonRequired, UpgradeStateDone,
+		"node is uncordoned, the node and DaemonSet pods on it are Ready if required by upgrade policy"},
+	{UpgradeStateUncordonRequired, UpgradeStateFailed,
+		"node didn't become Ready after uncordon retries or DaemonSet pods on it weren't Ready in time"},
+	{AnyUpgradeState, UpgradeStateUnknown, "automatic upgrade is disabled, the state annotation is removed"},
+}
+
+// PreUpgradeStates returns the node upgrade states in which the node waits before the driver pod is restarted
+func PreUpgradeStates() []string {
+	return []string{UpgradeStateUpgradeRequired, UpgradeStatePendingApproval, UpgradeStateDrain}
+}
+
+// UpgradeStates returns all node upgrade states in the order of the upgrade flow
+func UpgradeStates() []StateDescription {
+	return append([]StateDescription(nil), upgradeStates...)
+}
+
+// StateTransitions returns all legal node upgrade state changes
+func StateTransitions() []StateTransition {
+	return append([]StateTransition(nil), stateTransitions...)
+}
+
+// IsStateTransitionAllowed returns true if the upgrade flow may change the node upgrade state from one to another,
+// keeping the current state is always allowed
+func IsStateTransitionAllowed(from, to string) bool {
+	if from == to {
+		return true
+	}
+	for _, transition := range stateTransitions {
+		if (transition.From == from || transition.From == AnyUpgradeState) && transition.To == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transitionReason returns the reason of the state change listed in StateTransitions
+func transitionReason(from, to string) string {
+	for _, transition := range stateTransitions {
+		if (transition.From == from || transition.From == AnyUpgradeState) && transition.To == to {
+			return transition.Reason
+		}
+	}
+	return ""
+}
+
+// DescribeStates writes the node upgrade states and their transitions in the given format,
+// DescribeFormatText or DescribeFormatDot
+func DescribeStates(w io.Writer, format string) error {
+	var b strings.Builder
+	switch format {
+	case DescribeFormatText:
+		b.WriteString("Node upgrade states (" + UpgradeStateAnnotation + " node annotation):\n")
+		for _, state := range upgradeStates {
+			fmt.Fprintf(&b, "  %-20s %s\n", stateDisplayName(state.Name), state.Description)
+		}
+		b.WriteString("\nTransitions:\n")
+		for _, transition := range stateTransitions {
+			fmt.Fprintf(&b, "  %-20s -> %-20s %s\n",
+				stateDisplayName(transition.From), stateDisplayName(transition.To), transition.Reason)
+		}
+	case DescribeFormatDot:
+		b.WriteString("digraph ofed_upgrade {\n")
+		for _, state := range upgradeStates {
+			fmt.Fprintf(&b, "  %q [tooltip=%q];\n", stateDisplayName(state.Name), state.Description)
+		}
+		for _, transition := range stateTransitions {
+			fmt.Fprintf(&b, "  %q -> %q [label=%q];\n",
+				stateDisplayName(transition.From), stateDisplayName(transition.To), transition.Reason)
+		}
+		b.WriteString("}\n")
+	default:
+		return fmt.Errorf("unsupported states description format %q, expected %q or %q",
+			format, DescribeFormatText, DescribeFormatDot)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// stateDisplayName returns the name of the state as it is shown in descriptions
+func stateDisplayName(state string) string {
+	switch state {
+	case UpgradeStateUnknown:
+		return "unknown"
+	case AnyUpgradeState:
+		return "any"
+	}
+	return state
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade_test
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/Mellanox/network-operator/pkg/upgrade"
+)
+
+var _ = Describe("State transitions tests", func() {
+	It("Should only have transitions between known states", func() {
+		known := map[string]bool{upgrade.AnyUpgradeState: true}
+		for _, state := range upgrade.UpgradeStates() {
+			known[state.Name] = true
+		}
+		outgoing := map[string]bool{}
+		for _, transition := range upgrade.StateTransitions() {
+			Expect(known).To(HaveKey(transition.From))
+			Expect(known).To(HaveKey(transition.To))
+			Expect(transition.To).NotTo(Equal(upgrade.AnyUpgradeState))
+			outgoing[transition.From] = true
+		}
+		for _, state := range upgrade.UpgradeStates() {
+			if state.Name != upgrade.UpgradeStateDone {
+				Expect(outgoing).To(HaveKey(state.Name), "state %q has no transitions", state.Name)
+			}
+		}
+	})
+	It("Should allow only listed transitions", func() {
+		Expect(upgrade.IsStateTransitionAllowed(upgrade.UpgradeStateUpgradeRequired, upgrade.UpgradeStateDrain)).
+			To(BeTrue())
+		Expect(upgrade.IsStateTransitionAllowed(upgrade.UpgradeStateDrain, upgrade.UpgradeStateDrain)).To(BeTrue())
+		Expect(upgrade.IsStateTransitionAllowed(upgrade.UpgradeStateFailed, upgrade.UpgradeStateUnknown)).To(BeTrue())
+		Expect(upgrade.IsStateTransitionAllowed(upgrade.UpgradeStateDone, upgrade.UpgradeStateDrain)).To(BeFalse())
+		Expect(upgrade.IsStateTransitionAllowed(upgrade.UpgradeStateDrainFailed, upgrade.UpgradeStateDone)).
+			To(BeFalse())
+	})
+	It("Should describe states and transitions", func() {
+		text := &bytes.Buffer{}
+		Expect(upgrade.DescribeStates(text, upgrade.DescribeFormatText)).To(Succeed())
+		Expect(text.String()).To(ContainSubstring("unknown"))
+		Expect(text.String()).To(MatchRegexp(`upgrade-required\s+-> drain\s+upgrade slot is available`))
+
+		dot := &bytes.Buffer{}
+		Expect(upgrade.DescribeStates(dot, upgrade.DescribeFormatDot)).To(Succeed())
+		Expect(dot.String()).To(HavePrefix("digraph"))
+		Expect(dot.String()).To(ContainSubstring(`"upgrade-required" -> "drain" [label="upgrade slot is available"];`))
+
+		Expect(upgrade.DescribeStates(&bytes.Buffer{}, "yaml")).NotTo(Succeed())
+	})
+})
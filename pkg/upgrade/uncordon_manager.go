@@ -15,11 +15,21 @@ package upgrade
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/kubectl/pkg/drain"
+
+	"github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/consts"
 )
 
 type UncordonManagerImpl struct {
@@ -27,9 +37,15 @@ type UncordonManagerImpl struct {
 	log          logr.Logger
 }
 
-// UncordonManager is an interface that allows to uncordon nodes
+// UncordonManager is an interface that allows to uncordon nodes, to mark nodes with labels and taints
+// and to remove device plugins from nodes for the time of the upgrade
 type UncordonManager interface {
 	CordonOrUncordonNode(ctx context.Context, node *corev1.Node, desired bool) error
+	AddNodeUpgradeMarks(ctx context.Context, node *corev1.Node, marks *v1alpha1.UpgradeNodeMarksSpec) error
+	RemoveNodeUpgradeMarks(ctx context.Context, node *corev1.Node) error
+	PauseDevicePlugins(ctx context.Context, node *corev1.Node) error
+	ResumeDevicePlugins(ctx context.Context, node *corev1.Node) error
+	NotReadyDaemonSetPods(ctx context.Context, node *corev1.Node, podSelector string) ([]string, error)
 }
 
 func (m *UncordonManagerImpl) CordonOrUncordonNode(ctx context.Context, node *corev1.Node, desired bool) error {
@@ -37,6 +53,121 @@ func (m *UncordonManagerImpl) CordonOrUncordonNode(ctx context.Context, node *co
 	return drain.RunCordonOrUncordon(helper, node, desired)
 }
 
+// AddNodeUpgradeMarks adds labels and taints of the upgrade node marks to the node, see AddNodeUpgradeMarks
+func (m *UncordonManagerImpl) AddNodeUpgradeMarks(
+	ctx context.Context, node *corev1.Node, marks *v1alpha1.UpgradeNodeMarksSpec) error {
+	m.log.V(consts.LogLevelInfo).Info("Adding upgrade labels and taints to the node", "node", node.Name)
+	return m.updateNode(ctx, node, func(n *corev1.Node) (bool, error) {
+		return true, AddNodeUpgradeMarks(n, marks)
+	})
+}
+
+// RemoveNodeUpgradeMarks removes labels and taints added to the node for the upgrade, see RemoveNodeUpgradeMarks
+func (m *UncordonManagerImpl) RemoveNodeUpgradeMarks(ctx context.Context, node *corev1.Node) error {
+	m.log.V(consts.LogLevelInfo).Info("Removing upgrade labels and taints from the node", "node", node.Name)
+	return m.updateNode(ctx, node, func(n *corev1.Node) (bool, error) {
+		return RemoveNodeUpgradeMarks(n), nil
+	})
+}
+
+// PauseDevicePlugins removes the device plugins from the node, see PauseDevicePlugins
+func (m *UncordonManagerImpl) PauseDevicePlugins(ctx context.Context, node *corev1.Node) error {
+	m.log.V(consts.LogLevelInfo).Info("Pausing device plugins on the node", "node", node.Name)
+	now := time.Now()
+	return m.updateNode(ctx, node, func(n *corev1.Node) (bool, error) {
+		PauseDevicePlugins(n, now)
+		return true, nil
+	})
+}
+
+// ResumeDevicePlugins returns the device plugins to the node, see ResumeDevicePlugins
+func (m *UncordonManagerImpl) ResumeDevicePlugins(ctx context.Context, node *corev1.Node) error {
+	m.log.V(consts.LogLevelInfo).Info("Resuming device plugins on the node", "node", node.Name)
+	return m.updateNode(ctx, node, func(n *corev1.Node) (bool, error) {
+		return ResumeDevicePlugins(n), nil
+	})
+}
+
+// NotReadyDaemonSetPods returns namespace/name of the DaemonSets which should run a pod matching the pod selector
+// on the node, but their pod on the node doesn't exist or is not Ready. A DaemonSet should run a pod on the node
+// if its pod template matches the selector and it already has a pod on the node, or its pods can be scheduled
+// to the node, see daemonSetSchedulesOnNode
+func (m *UncordonManagerImpl) NotReadyDaemonSetPods(
+	ctx context.Context, node *corev1.Node, podSelector string) ([]string, error) {
+	selector, err := labels.Parse(podSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pod selector %q: %v", podSelector, err)
+	}
+	daemonSets, err := m.k8sInterface.AppsV1().DaemonSets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list DaemonSets: %v", err)
+	}
+	pods, err := m.k8sInterface.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		LabelSelector: podSelector,
+		FieldSelector: fields.SelectorFromSet(fields.Set{"spec.nodeName": node.Name}).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods on node %s: %v", node.Name, err)
+	}
+	// DaemonSets which have a pod on the node, true if the pod is Ready
+	ready := make(map[types.UID]bool)
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if owner := metav1.GetControllerOf(pod); owner != nil && owner.Kind == "DaemonSet" &&
+			pod.Spec.NodeName == node.Name {
+			ready[owner.UID] = ready[owner.UID] || isPodReady(pod)
+		}
+	}
+
+	var notReady []string
+	for i := range daemonSets.Items {
+		ds := &daemonSets.Items[i]
+		if !selector.Matches(labels.Set(ds.Spec.Template.Labels)) {
+			continue
+		}
+		podReady, hasPod := ready[ds.UID]
+		if !hasPod && !daemonSetSchedulesOnNode(ds, node) {
+			continue
+		}
+		if !podReady {
+			notReady = append(notReady, ds.Namespace+"/"+ds.Name)
+		}
+	}
+	return notReady, nil
+}
+
+// isPodReady returns true if the pod has Ready condition
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// updateNode applies the change to the latest version of the node, retrying on conflicts,
+// the node object is replaced with the updated one
+func (m *UncordonManagerImpl) updateNode(
+	ctx context.Context, node *corev1.Node, change func(*corev1.Node) (bool, error)) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := m.k8sInterface.CoreV1().Nodes().Get(ctx, node.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		changed, err := change(current)
+		if err != nil || !changed {
+			return err
+		}
+		updated, err := m.k8sInterface.CoreV1().Nodes().Update(ctx, current, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+		*node = *updated
+		return nil
+	})
+}
+
 func NewUncordonManager(k8sInterface kubernetes.Interface, log logr.Logger) *UncordonManagerImpl {
 	return &UncordonManagerImpl{
 		k8sInterface: k8sInterface,
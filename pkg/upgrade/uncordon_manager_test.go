@@ -21,7 +21,13 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
 
+	"github.com/Mellanox/network-operator/api/v1alpha1"
 	"github.com/Mellanox/network-operator/pkg/upgrade"
 )
 
@@ -39,4 +45,108 @@ var _ = Describe("UncordonManager tests", func() {
 		Expect(err).To(Succeed())
 		Expect(node.Spec.Unschedulable).To(BeFalse())
 	})
+	It("UncordonManager should add and remove upgrade labels and taints", func() {
+		ctx := context.TODO()
+		node := createNode("test-node-marks")
+
+		uncordonManager := upgrade.NewUncordonManager(k8sInterface, log)
+		marks := &v1alpha1.UpgradeNodeMarksSpec{
+			Labels: map[string]string{"example.com/upgrading": "true"},
+			Taints: []corev1.Taint{{Key: "example.com/upgrading", Effect: corev1.TaintEffectNoSchedule}},
+		}
+		Expect(uncordonManager.AddNodeUpgradeMarks(ctx, node, marks)).To(Succeed())
+		Expect(node.Labels).To(HaveKeyWithValue("example.com/upgrading", "true"))
+		Expect(node.Spec.Taints).To(HaveLen(1))
+
+		Expect(uncordonManager.RemoveNodeUpgradeMarks(ctx, node)).To(Succeed())
+		Expect(node.Labels).NotTo(HaveKey("example.com/upgrading"))
+		Expect(node.Spec.Taints).To(BeEmpty())
+		Expect(node.Annotations).NotTo(HaveKey(upgrade.UpgradeNodeMarksAnnotation))
+	})
+	It("UncordonManager should report DaemonSets without a Ready pod on the node", func() {
+		ctx := context.TODO()
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: map[string]string{"gpu": "true"}}}
+		newDaemonSet := func(name string, uid types.UID, nodeSelector map[string]string) *appsv1.DaemonSet {
+			ds := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: uid}}
+			ds.Spec.Template.Labels = map[string]string{"app": name, "role": "network"}
+			ds.Spec.Template.Spec.NodeSelector = nodeSelector
+			return ds
+		}
+		newPod := func(name string, ds *appsv1.DaemonSet, ready corev1.ConditionStatus) *corev1.Pod {
+			controller := true
+			return &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: name, Namespace: "default", Labels: ds.Spec.Template.Labels,
+					OwnerReferences: []metav1.OwnerReference{
+						{Kind: "DaemonSet", Name: ds.Name, UID: ds.UID, Controller: &controller}},
+				},
+				Spec:   corev1.PodSpec{NodeName: node.Name},
+				Status: corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: ready}}},
+			}
+		}
+		readyDs := newDaemonSet("ready", "uid-ready", nil)
+		notReadyDs := newDaemonSet("not-ready", "uid-not-ready", nil)
+		missingDs := newDaemonSet("missing", "uid-missing", nil)
+		otherNodesDs := newDaemonSet("other-nodes", "uid-other-nodes", map[string]string{"gpu": "false"})
+		clientset := k8sfake.NewSimpleClientset(node, readyDs, notReadyDs, missingDs, otherNodesDs,
+			newPod("ready-pod", readyDs, corev1.ConditionTrue),
+			newPod("not-ready-pod", notReadyDs, corev1.ConditionFalse))
+
+		uncordonManager := upgrade.NewUncordonManager(clientset, log)
+		notReady, err := uncordonManager.NotReadyDaemonSetPods(ctx, node, "role=network")
+		Expect(err).To(Succeed())
+		Expect(notReady).To(ConsistOf("default/not-ready", "default/missing"))
+
+		notReady, err = uncordonManager.NotReadyDaemonSetPods(ctx, node, "app=ready")
+		Expect(err).To(Succeed())
+		Expect(notReady).To(BeEmpty())
+
+		_, err = uncordonManager.NotReadyDaemonSetPods(ctx, node, "role in (")
+		Expect(err).NotTo(Succeed())
+	})
+	It("UncordonManager should only wait for DaemonSets which schedule pods on the node", func() {
+		ctx := context.TODO()
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: map[string]string{"zone": "a"}},
+			Spec: corev1.NodeSpec{Unschedulable: true, Taints: []corev1.Taint{
+				{Key: corev1.TaintNodeUnschedulable, Effect: corev1.TaintEffectNoSchedule},
+				{Key: "example.com/dedicated", Value: "storage", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "example.com/preferred", Effect: corev1.TaintEffectPreferNoSchedule},
+			}},
+		}
+		zoneRequirement := func(operator corev1.NodeSelectorOperator, zone string) *corev1.Affinity {
+			return &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: []corev1.NodeSelectorRequirement{
+						{Key: "zone", Operator: operator, Values: []string{zone}}}}}}}}
+		}
+		dedicated := []corev1.Toleration{{Key: "example.com/dedicated", Operator: corev1.TolerationOpEqual,
+			Value: "storage", Effect: corev1.TaintEffectNoSchedule}}
+		newDaemonSet := func(name string, affinity *corev1.Affinity, tolerations []corev1.Toleration) *appsv1.DaemonSet {
+			ds := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name)}}
+			ds.Spec.Template.Labels = map[string]string{"app": name, "role": "network"}
+			ds.Spec.Template.Spec.Affinity = affinity
+			ds.Spec.Template.Spec.Tolerations = tolerations
+			return ds
+		}
+		otherZone := newDaemonSet("other-zone", zoneRequirement(corev1.NodeSelectorOpIn, "b"), dedicated)
+		notOtherZone := newDaemonSet("not-other-zone", zoneRequirement(corev1.NodeSelectorOpNotIn, "b"), dedicated)
+		notTolerated := newDaemonSet("not-tolerated", nil, nil)
+		scheduledAnyway := newDaemonSet("scheduled-anyway", nil, nil)
+		controller := true
+		// the pod of the DaemonSet is on the node although the DaemonSet doesn't tolerate the taint of the node
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "scheduled-anyway-pod", Namespace: "default",
+				Labels: scheduledAnyway.Spec.Template.Labels,
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: "DaemonSet", Name: scheduledAnyway.Name, UID: scheduledAnyway.UID, Controller: &controller}}},
+			Spec: corev1.PodSpec{NodeName: node.Name},
+		}
+		clientset := k8sfake.NewSimpleClientset(node, otherZone, notOtherZone, notTolerated, scheduledAnyway, pod)
+
+		uncordonManager := upgrade.NewUncordonManager(clientset, log)
+		notReady, err := uncordonManager.NotReadyDaemonSetPods(ctx, node, "role=network")
+		Expect(err).To(Succeed())
+		Expect(notReady).To(ConsistOf("default/not-other-zone", "default/scheduled-anyway"))
+	})
 })
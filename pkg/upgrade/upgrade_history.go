@@ -0,0 +1,176 @@
+/*
+Copyright 2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/Mellanox/network-operator/pkg/consts"
+)
+
+// UpgradeHistoryLength is the max number of upgrades kept in UpgradeHistoryAnnotation of a node
+const UpgradeHistoryLength = 10
+
+const (
+	// UpgradeOutcomeInProgress is the outcome of the upgrade which is not finished yet
+	UpgradeOutcomeInProgress = "in-progress"
+	// UpgradeOutcomeSucceeded is the outcome of the upgrade which has brought the node to UpgradeStateDone
+	// with the up to date driver pod, including the manual driver pod restarts
+	UpgradeOutcomeSucceeded = "succeeded"
+	// UpgradeOutcomeFailed is the outcome of the upgrade which has moved the node to UpgradeStateDrainFailed
+	// or UpgradeStateFailed, it is changed to UpgradeOutcomeSucceeded if the node recovers
+	UpgradeOutcomeFailed = "failed"
+	// UpgradeOutcomeCanceled is the outcome of the upgrade which is no longer required before the driver pod
+	// was restarted or which was interrupted by disabling the automatic upgrade
+	UpgradeOutcomeCanceled = "canceled"
+)
+
+// UpgradeHistoryEntry is a single upgrade of the node in UpgradeHistoryAnnotation, times are RFC3339
+type UpgradeHistoryEntry struct {
+	StartTime string `json:"startTime"`
+	// EndTime is the time the outcome was set, empty while the upgrade is in progress
+	EndTime string `json:"endTime,omitempty"`
+	// FailureTime is the time the upgrade has failed first, it is kept if the node recovers
+	FailureTime string `json:"failureTime,omitempty"`
+	FromImage   string `json:"fromImage,omitempty"`
+	ToImage     string `json:"toImage,omitempty"`
+	Outcome     string `json:"outcome"`
+}
+
+// GetNodeUpgradeHistory returns the upgrades of the node recorded in UpgradeHistoryAnnotation, the oldest first
+func GetNodeUpgradeHistory(node *v1.Node) ([]UpgradeHistoryEntry, error) {
+	value, ok := node.Annotations[UpgradeHistoryAnnotation]
+	if !ok || value == "" {
+		return nil, nil
+	}
+	var history []UpgradeHistoryEntry
+	if err := json.Unmarshal([]byte(value), &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// SetUpgradeHistoryOutcome sets the outcome of the last upgrade in UpgradeHistoryAnnotation of the node object
+// if the upgrade is in progress or has failed, true is returned if the annotation was changed
+func SetUpgradeHistoryOutcome(log logr.Logger, node *v1.Node, outcome string) bool {
+	value, changed := upgradeHistoryWithOutcome(log, node, outcome, "")
+	if changed {
+		node.Annotations[UpgradeHistoryAnnotation] = value
+	}
+	return changed
+}
+
+// upgradeHistoryWithStart returns UpgradeHistoryAnnotation of the node with a new upgrade in progress appended,
+// the oldest upgrades are dropped to keep UpgradeHistoryLength entries. Nothing is changed if the last upgrade
+// is still in progress, e.g. when the state change which has started it is retried
+func upgradeHistoryWithStart(log logr.Logger, node *v1.Node, fromImage, toImage string) (string, bool) {
+	history := nodeUpgradeHistory(log, node)
+	if len(history) > 0 && history[len(history)-1].Outcome == UpgradeOutcomeInProgress {
+		return "", false
+	}
+	history = append(history, UpgradeHistoryEntry{
+		StartTime: time.Now().UTC().Format(time.RFC3339),
+		FromImage: fromImage,
+		ToImage:   toImage,
+		Outcome:   UpgradeOutcomeInProgress,
+	})
+	if len(history) > UpgradeHistoryLength {
+		history = history[len(history)-UpgradeHistoryLength:]
+	}
+	return marshalUpgradeHistory(log, history)
+}
+
+// upgradeHistoryWithOutcome returns UpgradeHistoryAnnotation of the node with the outcome set on the last upgrade,
+// toImage replaces the target image of the upgrade if set. The outcome of a failed upgrade can still be changed,
+// the outcome of a succeeded or canceled upgrade is final
+func upgradeHistoryWithOutcome(log logr.Logger, node *v1.Node, outcome, toImage string) (string, bool) {
+	history := nodeUpgradeHistory(log, node)
+	if len(history) == 0 {
+		return "", false
+	}
+	last := &history[len(history)-1]
+	final := last.Outcome != UpgradeOutcomeInProgress && last.Outcome != UpgradeOutcomeFailed
+	if final || last.Outcome == outcome {
+		return "", false
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	last.Outcome = outcome
+	last.EndTime = now
+	if outcome == UpgradeOutcomeFailed && last.FailureTime == "" {
+		last.FailureTime = now
+	}
+	if toImage != "" {
+		last.ToImage = toImage
+	}
+	return marshalUpgradeHistory(log, history)
+}
+
+// nodeUpgradeHistory returns the upgrade history of the node, an invalid annotation is replaced by a new history
+func nodeUpgradeHistory(log logr.Logger, node *v1.Node) []UpgradeHistoryEntry {
+	history, err := GetNodeUpgradeHistory(node)
+	if err != nil {
+		log.V(consts.LogLevelWarning).Info("Invalid upgrade history annotation of the node, starting a new history",
+			"node", node.Name, "error", err.Error())
+	}
+	return history
+}
+
+func marshalUpgradeHistory(log logr.Logger, history []UpgradeHistoryEntry) (string, bool) {
+	value, err := json.Marshal(history)
+	if err != nil {
+		log.V(consts.LogLevelError).Error(err, "Failed to marshal upgrade history")
+		return "", false
+	}
+	return string(value), true
+}
+
+// recordUpgradeStart appends the upgrade from the image of the running driver pod to the image of the driver
+// daemon set to the history of the node
+func recordUpgradeStart(ctx context.Context, provider NodeUpgradeStateProvider, log logr.Logger,
+	nodeState *NodeUpgradeState) error {
+	value, changed := upgradeHistoryWithStart(log, nodeState.Node,
+		getPodDriverImage(nodeState.DriverPod), getDriverImage(nodeState.DriverDaemonSet))
+	if !changed {
+		return nil
+	}
+	err := provider.ChangeNodeUpgradeAnnotation(ctx, nodeState.Node, UpgradeHistoryAnnotation, value)
+	if err != nil {
+		log.V(consts.LogLevelError).Error(err, "Failed to record upgrade start", "node", nodeState.Node.Name)
+	}
+	return err
+}
+
+// recordUpgradeOutcome sets the outcome of the last upgrade in the history of the node,
+// toImage is the image the node was upgraded to if known
+func recordUpgradeOutcome(ctx context.Context, provider NodeUpgradeStateProvider, log logr.Logger,
+	node *v1.Node, outcome, toImage string) error {
+	value, changed := upgradeHistoryWithOutcome(log, node, outcome, toImage)
+	if !changed {
+		return nil
+	}
+	err := provider.ChangeNodeUpgradeAnnotation(ctx, node, UpgradeHistoryAnnotation, value)
+	if err != nil {
+		log.V(consts.LogLevelError).Error(err, "Failed to record upgrade outcome",
+			"node", node.Name, "outcome", outcome)
+	}
+	return err
+}
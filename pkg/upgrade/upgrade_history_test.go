@@ -0,0 +1,171 @@
+/*
+Copyright 2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/upgrade"
+	"github.com/Mellanox/network-operator/pkg/utils"
+)
+
+var _ = Describe("Upgrade history tests", func() {
+	driverDaemonSet := func(image string) *appsv1.DaemonSet {
+		return &appsv1.DaemonSet{
+			ObjectMeta: v1.ObjectMeta{Generation: 2},
+			Spec: appsv1.DaemonSetSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: upgrade.OfedDriverContainerName, Image: image}}}}},
+		}
+	}
+	driverPod := func(generation, image string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{Labels: map[string]string{utils.PodTemplateGenerationLabel: generation}},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: upgrade.OfedDriverContainerName, Image: image}}},
+		}
+	}
+	history := func(node *corev1.Node) []upgrade.UpgradeHistoryEntry {
+		entries, err := upgrade.GetNodeUpgradeHistory(node)
+		Expect(err).NotTo(HaveOccurred())
+		return entries
+	}
+	setHistory := func(node *corev1.Node, entries []upgrade.UpgradeHistoryEntry) {
+		value, err := json.Marshal(entries)
+		Expect(err).NotTo(HaveOccurred())
+		node.Annotations[upgrade.UpgradeHistoryAnnotation] = string(value)
+	}
+	applyState := func(clusterState *upgrade.ClusterUpgradeState) {
+		stateManager := upgrade.NewClusterUpdateStateManager(
+			&drainManager, &podDeleteManager, &uncordonManager, &nodeUpgradeStateProvider, log, k8sClient, k8sInterface)
+		Expect(stateManager.ApplyState(context.TODO(), clusterState,
+			&v1alpha1.OfedUpgradePolicySpec{AutoUpgrade: true})).To(Succeed())
+	}
+
+	It("Should record the start of the upgrade with the current and the target driver images", func() {
+		node := nodeWithUpgradeState(upgrade.UpgradeStateDone)
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStateDone] = []*upgrade.NodeUpgradeState{{
+			Node:            node,
+			DriverPod:       driverPod("1", "mofed:5.5"),
+			DriverDaemonSet: driverDaemonSet("mofed:5.6"),
+		}}
+
+		applyState(&clusterState)
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateUpgradeRequired))
+		entries := history(node)
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].FromImage).To(Equal("mofed:5.5"))
+		Expect(entries[0].ToImage).To(Equal("mofed:5.6"))
+		Expect(entries[0].Outcome).To(Equal(upgrade.UpgradeOutcomeInProgress))
+		Expect(entries[0].StartTime).NotTo(BeEmpty())
+		Expect(entries[0].EndTime).To(BeEmpty())
+	})
+
+	It("Should drop the oldest upgrades once the history is full", func() {
+		node := nodeWithUpgradeState(upgrade.UpgradeStateDone)
+		var past []upgrade.UpgradeHistoryEntry
+		for i := 0; i < upgrade.UpgradeHistoryLength; i++ {
+			past = append(past, upgrade.UpgradeHistoryEntry{
+				StartTime: fmt.Sprintf("2022-01-%02dT00:00:00Z", i+1), Outcome: upgrade.UpgradeOutcomeSucceeded})
+		}
+		setHistory(node, past)
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStateDone] = []*upgrade.NodeUpgradeState{{
+			Node:            node,
+			DriverPod:       driverPod("1", "mofed:5.5"),
+			DriverDaemonSet: driverDaemonSet("mofed:5.6"),
+		}}
+
+		applyState(&clusterState)
+		entries := history(node)
+		Expect(entries).To(HaveLen(upgrade.UpgradeHistoryLength))
+		Expect(entries[0].StartTime).To(Equal("2022-01-02T00:00:00Z"))
+		Expect(entries[len(entries)-1].Outcome).To(Equal(upgrade.UpgradeOutcomeInProgress))
+	})
+
+	It("Should record the failure of the restarted driver pod", func() {
+		node := nodeWithUpgradeState(upgrade.UpgradeStatePodRestart)
+		setHistory(node, []upgrade.UpgradeHistoryEntry{{
+			StartTime: "2022-01-01T00:00:00Z", ToImage: "mofed:5.6", Outcome: upgrade.UpgradeOutcomeInProgress}})
+		pod := driverPod("2", "mofed:5.6")
+		pod.Status.Phase = corev1.PodFailed
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStatePodRestart] = []*upgrade.NodeUpgradeState{{
+			Node: node, DriverPod: pod, DriverDaemonSet: driverDaemonSet("mofed:5.6"),
+		}}
+
+		applyState(&clusterState)
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateFailed))
+		entries := history(node)
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Outcome).To(Equal(upgrade.UpgradeOutcomeFailed))
+		Expect(entries[0].FailureTime).NotTo(BeEmpty())
+		Expect(entries[0].EndTime).To(Equal(entries[0].FailureTime))
+	})
+
+	It("Should record the upgrade which is no longer required as canceled", func() {
+		node := nodeWithUpgradeState(upgrade.UpgradeStatePendingApproval)
+		setHistory(node, []upgrade.UpgradeHistoryEntry{{
+			StartTime: "2022-01-01T00:00:00Z", ToImage: "mofed:5.6", Outcome: upgrade.UpgradeOutcomeInProgress}})
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStatePendingApproval] = []*upgrade.NodeUpgradeState{{
+			Node: node, DriverPod: driverPod("2", "mofed:5.5"), DriverDaemonSet: driverDaemonSet("mofed:5.5"),
+		}}
+
+		applyState(&clusterState)
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateDone))
+		Expect(history(node)[0].Outcome).To(Equal(upgrade.UpgradeOutcomeCanceled))
+	})
+
+	It("Should only change the outcome of an upgrade in progress or failed", func() {
+		node := nodeWithUpgradeState(upgrade.UpgradeStateDone)
+		Expect(upgrade.SetUpgradeHistoryOutcome(log, node, upgrade.UpgradeOutcomeCanceled)).To(BeFalse())
+
+		setHistory(node, []upgrade.UpgradeHistoryEntry{{
+			StartTime: "2022-01-01T00:00:00Z", Outcome: upgrade.UpgradeOutcomeSucceeded}})
+		Expect(upgrade.SetUpgradeHistoryOutcome(log, node, upgrade.UpgradeOutcomeCanceled)).To(BeFalse())
+
+		setHistory(node, []upgrade.UpgradeHistoryEntry{{
+			StartTime: "2022-01-01T00:00:00Z", FailureTime: "2022-01-01T01:00:00Z", Outcome: upgrade.UpgradeOutcomeFailed}})
+		Expect(upgrade.SetUpgradeHistoryOutcome(log, node, upgrade.UpgradeOutcomeCanceled)).To(BeTrue())
+		entries := history(node)
+		Expect(entries[0].Outcome).To(Equal(upgrade.UpgradeOutcomeCanceled))
+		Expect(entries[0].FailureTime).To(Equal("2022-01-01T01:00:00Z"))
+	})
+
+	It("Should start a new history if the annotation is invalid", func() {
+		node := nodeWithUpgradeState(upgrade.UpgradeStateDone)
+		node.Annotations[upgrade.UpgradeHistoryAnnotation] = "not-json"
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStateDone] = []*upgrade.NodeUpgradeState{{
+			Node:            node,
+			DriverPod:       driverPod("1", "mofed:5.5"),
+			DriverDaemonSet: driverDaemonSet("mofed:5.6"),
+		}}
+
+		applyState(&clusterState)
+		Expect(history(node)).To(HaveLen(1))
+	})
+})
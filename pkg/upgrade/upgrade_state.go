@@ -19,10 +19,15 @@ package upgrade
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/go-logr/logr"
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -31,6 +36,13 @@ import (
 	"github.com/Mellanox/network-operator/pkg/utils"
 )
 
+const (
+	// defaultUncordonReadyBackoff is used if the upgrade policy doesn't specify the initial backoff
+	defaultUncordonReadyBackoff = 10 * time.Second
+	// maxUncordonReadyBackoff limits the time between the Ready state checks of the uncordoned node
+	maxUncordonReadyBackoff = time.Hour
+)
+
 // NodeUpgradeState contains a mapping between a node,
 // the driver POD running on them and the daemon set, controlling this pod
 type NodeUpgradeState struct {
@@ -45,6 +57,12 @@ type NodeUpgradeState struct {
 // This state is then used as an input for the ClusterUpgradeStateManager
 type ClusterUpgradeState struct {
 	NodeStates map[string][]*NodeUpgradeState
+	// ApprovedImages contains OFED driver images approved for the upgrade,
+	// it is only used if the upgrade policy requires approval
+	ApprovedImages []string
+	// MinDriverVersion limits the upgrade to the nodes with driver pods older than the version if set,
+	// e.g. when the upgrade of the outdated drivers is forced while automatic upgrade is disabled
+	MinDriverVersion string
 }
 
 // NewClusterUpgradeState creates an empty ClusterUpgradeState object
@@ -62,6 +80,10 @@ type ClusterUpgradeStateManager struct {
 	PodDeleteManager         PodDeleteManager
 	UncordonManager          UncordonManager
 	NodeUpgradeStateProvider NodeUpgradeStateProvider
+	// DriverValidator runs the driver validation command of the upgrade policy, the validation is skipped if not set
+	DriverValidator DriverValidator
+	// validatingNodes contains the nodes with the driver validation in progress
+	validatingNodes *StringSet
 }
 
 // NewClusterUpdateStateManager creates a new instance of ClusterUpgradeStateManager
@@ -81,6 +103,7 @@ func NewClusterUpdateStateManager(
 		Log:                      log,
 		K8sClient:                k8sClient,
 		K8sInterface:             k8sInterface,
+		validatingNodes:          NewStringSet(),
 	}
 
 	return manager
@@ -104,16 +127,34 @@ func (m *ClusterUpgradeStateManager) ApplyState(ctx context.Context,
 		return nil
 	}
 
+	err := m.ProcessQuarantinedNodes(ctx, currentState, upgradePolicy.QuarantineAfterFailures)
+	if err != nil {
+		m.Log.V(consts.LogLevelError).Error(err, "Failed to process quarantined nodes")
+		return err
+	}
+	err = m.removeStaleNodeUpgradeMarks(ctx, currentState)
+	if err != nil {
+		m.Log.V(consts.LogLevelError).Error(err, "Failed to remove upgrade labels and taints from nodes")
+		return err
+	}
+	// quarantined nodes are skipped by the upgrade flow and don't count as upgrades in progress or failures
+	currentState = withoutQuarantinedNodes(currentState)
+
 	m.Log.V(consts.LogLevelInfo).Info("Node states:",
 		"Unknown", len(currentState.NodeStates[UpgradeStateUnknown]),
 		UpgradeStateDone, len(currentState.NodeStates[UpgradeStateDone]),
 		UpgradeStateUpgradeRequired, len(currentState.NodeStates[UpgradeStateUpgradeRequired]),
+		UpgradeStatePendingApproval, len(currentState.NodeStates[UpgradeStatePendingApproval]),
 		UpgradeStateDrain, len(currentState.NodeStates[UpgradeStateDrain]),
 		UpgradeStateDrainFailed, len(currentState.NodeStates[UpgradeStateDrainFailed]),
-		UpgradeStatePodRestart, len(currentState.NodeStates[UpgradeStatePodRestart]))
+		UpgradeStatePodRestart, len(currentState.NodeStates[UpgradeStatePodRestart]),
+		UpgradeStatePostUpgradeSoak, len(currentState.NodeStates[UpgradeStatePostUpgradeSoak]),
+		UpgradeStateFailed, len(currentState.NodeStates[UpgradeStateFailed]))
 
+	// failed nodes don't take upgrade slots, they are limited by the max failures of the upgrade policy
 	upgradesInProgress := len(currentState.NodeStates[UpgradeStateDrain]) +
 		len(currentState.NodeStates[UpgradeStatePodRestart]) +
+		len(currentState.NodeStates[UpgradeStatePostUpgradeSoak]) +
 		len(currentState.NodeStates[UpgradeStateDrainFailed]) +
 		len(currentState.NodeStates[UpgradeStateUncordonRequired])
 
@@ -125,13 +166,33 @@ func (m *ClusterUpgradeStateManager) ApplyState(ctx context.Context,
 		upgradesAvailable = upgradePolicy.MaxParallelUpgrades - upgradesInProgress
 	}
 
+	unavailableNodes := m.UnavailableNodesCount(currentState)
+	if upgradePolicy.MaxUnavailableNodes > 0 && upgradesAvailable > upgradePolicy.MaxUnavailableNodes-unavailableNodes {
+		m.Log.V(consts.LogLevelInfo).Info("Max unavailable nodes limit is reached, new upgrades are queued",
+			"unavailableNodes", unavailableNodes, "maxUnavailableNodes", upgradePolicy.MaxUnavailableNodes)
+		upgradesAvailable = upgradePolicy.MaxUnavailableNodes - unavailableNodes
+	}
+
+	if m.IsUpgradeAborted(currentState, upgradePolicy) {
+		m.Log.V(consts.LogLevelWarning).Info("Upgrade is aborted, too many nodes failed the upgrade",
+			"maxFailures", upgradePolicy.MaxFailures)
+		upgradesAvailable = 0
+	}
+
+	if m.isUpgradeCooldownActive(currentState, upgradePolicy.CooldownSeconds) {
+		m.Log.V(consts.LogLevelInfo).Info("Upgrade cooldown is active, new upgrades are postponed",
+			"cooldownSeconds", upgradePolicy.CooldownSeconds)
+		upgradesAvailable = 0
+	}
+
 	m.Log.V(consts.LogLevelInfo).Info("Upgrades in progress",
 		"currently in progress", upgradesInProgress,
 		"max parallel upgrades", upgradePolicy.MaxParallelUpgrades,
+		"unavailable nodes", unavailableNodes,
 		"upgrade slots available", upgradesAvailable)
 
 	// First, check if unknown or ready nodes need to be upgraded
-	err := m.ProcessDoneOrUnknownNodes(ctx, currentState, UpgradeStateUnknown)
+	err = m.ProcessDoneOrUnknownNodes(ctx, currentState, UpgradeStateUnknown)
 	if err != nil {
 		m.Log.V(consts.LogLevelError).Error(err, "Failed to process nodes", "state", UpgradeStateUnknown)
 		return err
@@ -141,30 +202,54 @@ func (m *ClusterUpgradeStateManager) ApplyState(ctx context.Context,
 		m.Log.V(consts.LogLevelError).Error(err, "Failed to process nodes", "state", UpgradeStateDone)
 		return err
 	}
+	err = m.ProcessPendingApprovalNodes(ctx, currentState, upgradePolicy.RequireApproval)
+	if err != nil {
+		m.Log.V(consts.LogLevelError).Error(err, "Failed to process nodes", "state", UpgradeStatePendingApproval)
+		return err
+	}
+	err = m.ProcessManualUpgradeNodes(ctx, currentState, upgradePolicy.NodeGroups)
+	if err != nil {
+		m.Log.V(consts.LogLevelError).Error(err, "Failed to process nodes which require manual upgrade")
+		return err
+	}
 	// Start upgrade process for upgradesAvailable number of nodes
-	err = m.ProcessUpgradeRequiredNodes(ctx, currentState, upgradesAvailable)
+	err = m.ProcessUpgradeRequiredNodes(
+		ctx, currentState, upgradesAvailable, upgradePolicy.RequireApproval, upgradePolicy.NodeGroups)
 	if err != nil {
 		m.Log.V(consts.LogLevelError).Error(
 			err, "Failed to process nodes", "state", UpgradeStateUpgradeRequired)
 		return err
 	}
 	// Schedule nodes for drain
-	err = m.ProcessDrainNodes(ctx, currentState, upgradePolicy.DrainSpec)
+	err = m.ProcessDrainNodes(
+		ctx, currentState, upgradePolicy.DrainSpec, upgradePolicy.NodeMarks, upgradePolicy.DevicePluginGraceSeconds)
 	if err != nil {
 		m.Log.V(consts.LogLevelError).Error(err, "Failed to schedule nodes drain")
 		return err
 	}
-	err = m.ProcessPodRestartNodes(ctx, currentState)
+	err = m.ProcessPodRestartNodes(ctx, currentState, upgradePolicy.SoakSeconds, upgradePolicy.DriverValidation)
 	if err != nil {
 		m.Log.V(consts.LogLevelError).Error(err, "Failed to schedule pods restart")
 		return err
 	}
-	err = m.ProcessDrainFailedNodes(ctx, currentState)
+	err = m.ProcessPostUpgradeSoakNodes(ctx, currentState, upgradePolicy.SoakSeconds)
+	if err != nil {
+		m.Log.V(consts.LogLevelError).Error(err, "Failed to process nodes", "state", UpgradeStatePostUpgradeSoak)
+		return err
+	}
+	err = m.ProcessDrainFailedNodes(ctx, currentState, upgradePolicy.SoakSeconds, upgradePolicy.DriverValidation)
 	if err != nil {
 		m.Log.V(consts.LogLevelError).Error(err, "Failed to process nodes which failed to drain")
 		return err
 	}
-	err = m.ProcessUncordonRequiredNodes(ctx, currentState)
+	err = m.ProcessUpgradeFailedNodes(ctx, currentState, upgradePolicy.SoakSeconds,
+		upgradePolicy.DriverValidation, upgradePolicy.UncordonWaitForPods)
+	if err != nil {
+		m.Log.V(consts.LogLevelError).Error(err, "Failed to process nodes which failed to upgrade")
+		return err
+	}
+	err = m.ProcessUncordonRequiredNodes(ctx, currentState, upgradePolicy.UncordonReadyRetries,
+		upgradePolicy.UncordonReadyBackoffSeconds, upgradePolicy.UncordonWaitForPods)
 	if err != nil {
 		m.Log.V(consts.LogLevelError).Error(err, "Failed to uncordon nodes")
 		return err
@@ -186,7 +271,11 @@ func (m *ClusterUpgradeStateManager) ProcessDoneOrUnknownNodes(
 				err, "Failed to get pod template generation", "pod", nodeState.DriverPod)
 			return err
 		}
-		if podTemplateGeneration != nodeState.DriverDaemonSet.GetGeneration() {
+		if podTemplateGeneration != nodeState.DriverDaemonSet.GetGeneration() &&
+			isInUpgradeScope(currentClusterState, nodeState) {
+			if err := recordUpgradeStart(ctx, m.NodeUpgradeStateProvider, m.Log, nodeState); err != nil {
+				return err
+			}
 			err := m.NodeUpgradeStateProvider.ChangeNodeUpgradeState(ctx, nodeState.Node, UpgradeStateUpgradeRequired)
 			if err != nil {
 				m.Log.V(consts.LogLevelError).Error(
@@ -198,6 +287,29 @@ func (m *ClusterUpgradeStateManager) ProcessDoneOrUnknownNodes(
 			continue
 		}
 
+		if nodeState.Node.Annotations[ForceDriverReloadAnnotation] == "true" {
+			// Replace the request with its acceptance time to restart only the pods created before it
+			err := m.NodeUpgradeStateProvider.ChangeNodeUpgradeAnnotation(
+				ctx, nodeState.Node, ForceDriverReloadAnnotation, time.Now().UTC().Format(time.RFC3339))
+			if err != nil {
+				m.Log.V(consts.LogLevelError).Error(
+					err, "Failed to accept forced driver reload request", "node", nodeState.Node.Name)
+				return err
+			}
+			if err := recordUpgradeStart(ctx, m.NodeUpgradeStateProvider, m.Log, nodeState); err != nil {
+				return err
+			}
+			err = m.NodeUpgradeStateProvider.ChangeNodeUpgradeState(ctx, nodeState.Node, UpgradeStateUpgradeRequired)
+			if err != nil {
+				m.Log.V(consts.LogLevelError).Error(
+					err, "Failed to change node upgrade state", "state", UpgradeStateUpgradeRequired)
+				return err
+			}
+			m.Log.V(consts.LogLevelInfo).Info("Forced driver reload requested, changed node state to UpgradeRequired",
+				"node", nodeState.Node.Name)
+			continue
+		}
+
 		if nodeStateName == UpgradeStateUnknown {
 			err := m.NodeUpgradeStateProvider.ChangeNodeUpgradeState(ctx, nodeState.Node, UpgradeStateDone)
 			if err != nil {
@@ -211,16 +323,39 @@ func (m *ClusterUpgradeStateManager) ProcessDoneOrUnknownNodes(
 		}
 		m.Log.V(consts.LogLevelDebug).Info("Node in UpgradeDone state, upgrade not required",
 			"node", nodeState.Node.Name)
+		// annotations of completed upgrades are not cleared on some paths, e.g. when the upgrade was aborted.
+		// The obsolete annotations don't affect the upgrade, so the pruning is retried on the next reconcile
+		if err := m.NodeUpgradeStateProvider.PruneNodeUpgradeAnnotations(ctx, nodeState.Node); err != nil {
+			m.Log.V(consts.LogLevelWarning).Info("Failed to prune node upgrade annotations",
+				"node", nodeState.Node.Name, "error", err.Error())
+		}
 	}
 	return nil
 }
 
 // ProcessUpgradeRequiredNodes processes UpgradeStateUpgradeRequired nodes and moves them to UpgradeStateDrain until
-// the limit on max parallel upgrades is reached.
+// the limit on max parallel upgrades is reached. Nodes of the node groups which don't allow disruption are skipped.
+// If approval is required, nodes with not approved target driver image are moved to UpgradeStatePendingApproval.
 func (m *ClusterUpgradeStateManager) ProcessUpgradeRequiredNodes(
-	ctx context.Context, currentClusterState *ClusterUpgradeState, limit int) error {
+	ctx context.Context, currentClusterState *ClusterUpgradeState, limit int, requireApproval bool,
+	nodeGroups []v1alpha1.UpgradeNodeGroupSpec) error {
 	m.Log.V(consts.LogLevelInfo).Info("ProcessUpgradeRequiredNodes")
 	for _, nodeState := range currentClusterState.NodeStates[UpgradeStateUpgradeRequired] {
+		if _, manual := m.nonDisruptiveNodeGroup(nodeState.Node, nodeGroups); manual {
+			m.Log.V(consts.LogLevelDebug).Info("Node requires manual upgrade, skipping", "node", nodeState.Node.Name)
+			continue
+		}
+		if requireApproval && !m.isUpgradeApproved(currentClusterState, nodeState) {
+			err := m.NodeUpgradeStateProvider.ChangeNodeUpgradeState(ctx, nodeState.Node, UpgradeStatePendingApproval)
+			if err != nil {
+				m.Log.V(consts.LogLevelError).Error(
+					err, "Failed to change node upgrade state", "state", UpgradeStatePendingApproval)
+				return err
+			}
+			m.Log.V(consts.LogLevelInfo).Info("Node upgrade is waiting for approval",
+				"node", nodeState.Node.Name, "image", getDriverImage(nodeState.DriverDaemonSet))
+			continue
+		}
 		if limit <= 0 {
 			m.Log.V(consts.LogLevelInfo).Info("Limit for new upgrades is exceeded, skipping the iteration")
 			break
@@ -241,15 +376,212 @@ func (m *ClusterUpgradeStateManager) ProcessUpgradeRequiredNodes(
 	return nil
 }
 
+// ProcessManualUpgradeNodes flags UpgradeStateUpgradeRequired nodes of the node groups which don't allow disruption
+// with UpgradeManualRequiredAnnotation, ProcessUpgradeRequiredNodes skips these nodes. Once the driver pod
+// on such node was restarted manually and is in sync, the node is moved to UpgradeStateDone.
+// The annotation is removed from nodes which no longer belong to such node group
+func (m *ClusterUpgradeStateManager) ProcessManualUpgradeNodes(ctx context.Context,
+	currentClusterState *ClusterUpgradeState, nodeGroups []v1alpha1.UpgradeNodeGroupSpec) error {
+	m.Log.V(consts.LogLevelInfo).Info("ProcessManualUpgradeNodes")
+	for _, nodeState := range currentClusterState.NodeStates[UpgradeStateUpgradeRequired] {
+		group, manual := m.nonDisruptiveNodeGroup(nodeState.Node, nodeGroups)
+		current, flagged := nodeState.Node.Annotations[UpgradeManualRequiredAnnotation]
+		if !manual {
+			if flagged {
+				err := m.NodeUpgradeStateProvider.ChangeNodeUpgradeAnnotation(
+					ctx, nodeState.Node, UpgradeManualRequiredAnnotation, "null")
+				if err != nil {
+					m.Log.V(consts.LogLevelError).Error(
+						err, "Failed to remove manual upgrade annotation", "node", nodeState.Node.Name)
+					return err
+				}
+			}
+			continue
+		}
+
+		inSync, err := m.isDriverPodInSync(nodeState)
+		if err != nil {
+			return err
+		}
+		if inSync && !m.isForcedReloadPending(nodeState) {
+			for _, annotation := range []string{
+				UpgradeManualRequiredAnnotation, ForceDriverReloadAnnotation, UpgradeFailuresAnnotation} {
+				if _, ok := nodeState.Node.Annotations[annotation]; !ok {
+					continue
+				}
+				err = m.NodeUpgradeStateProvider.ChangeNodeUpgradeAnnotation(ctx, nodeState.Node, annotation, "null")
+				if err != nil {
+					m.Log.V(consts.LogLevelError).Error(
+						err, "Failed to remove node annotation", "node", nodeState.Node.Name, "annotation", annotation)
+					return err
+				}
+			}
+			err = recordUpgradeOutcome(ctx, m.NodeUpgradeStateProvider, m.Log, nodeState.Node,
+				UpgradeOutcomeSucceeded, getPodDriverImage(nodeState.DriverPod))
+			if err != nil {
+				return err
+			}
+			err = m.NodeUpgradeStateProvider.ChangeNodeUpgradeState(ctx, nodeState.Node, UpgradeStateDone)
+			if err != nil {
+				m.Log.V(consts.LogLevelError).Error(
+					err, "Failed to change node upgrade state", "state", UpgradeStateDone)
+				return err
+			}
+			m.Log.V(consts.LogLevelInfo).Info("Driver pod was restarted manually, changed node state to UpgradeDone",
+				"node", nodeState.Node.Name, "nodeGroup", group)
+			continue
+		}
+
+		if !flagged || current != group {
+			err = m.NodeUpgradeStateProvider.ChangeNodeUpgradeAnnotation(
+				ctx, nodeState.Node, UpgradeManualRequiredAnnotation, group)
+			if err != nil {
+				m.Log.V(consts.LogLevelError).Error(
+					err, "Failed to set manual upgrade annotation", "node", nodeState.Node.Name)
+				return err
+			}
+			m.Log.V(consts.LogLevelInfo).Info("Node group doesn't allow disruption, the node requires manual upgrade",
+				"node", nodeState.Node.Name, "nodeGroup", group)
+		}
+	}
+	return nil
+}
+
+// nonDisruptiveNodeGroup returns the name of the node group which selects the node first and true
+// if that group doesn't allow disruption. A group with an invalid node selector doesn't allow disruption
+// of any node, to be on the safe side
+func (m *ClusterUpgradeStateManager) nonDisruptiveNodeGroup(
+	node *v1.Node, nodeGroups []v1alpha1.UpgradeNodeGroupSpec) (string, bool) {
+	for i := range nodeGroups {
+		group := &nodeGroups[i]
+		selector, err := metav1.LabelSelectorAsSelector(&group.NodeSelector)
+		if err != nil {
+			m.Log.V(consts.LogLevelWarning).Info("Invalid node selector of the upgrade node group, "+
+				"disruption is not allowed", "nodeGroup", group.Name, "error", err.Error())
+			return group.Name, true
+		}
+		if !selector.Matches(labels.Set(node.Labels)) {
+			continue
+		}
+		return group.Name, group.DisruptionAllowed != nil && !*group.DisruptionAllowed
+	}
+	return "", false
+}
+
+// ManualUpgradeNodes returns the sorted list of nodes which require upgrade
+// but are skipped by the upgrade flow because their node group doesn't allow disruption
+func (m *ClusterUpgradeStateManager) ManualUpgradeNodes(currentClusterState *ClusterUpgradeState) []string {
+	var result []string
+	for _, nodeState := range currentClusterState.NodeStates[UpgradeStateUpgradeRequired] {
+		if _, ok := nodeState.Node.Annotations[UpgradeManualRequiredAnnotation]; ok {
+			result = append(result, nodeState.Node.Name)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// ProcessPendingApprovalNodes processes UpgradeStatePendingApproval nodes and moves them
+// to UpgradeStateUpgradeRequired once their target driver image is approved or approval is no longer required.
+// Nodes which don't require upgrade anymore are moved to UpgradeStateDone.
+func (m *ClusterUpgradeStateManager) ProcessPendingApprovalNodes(
+	ctx context.Context, currentClusterState *ClusterUpgradeState, requireApproval bool) error {
+	m.Log.V(consts.LogLevelInfo).Info("ProcessPendingApprovalNodes")
+	for _, nodeState := range currentClusterState.NodeStates[UpgradeStatePendingApproval] {
+		podTemplateGeneration, err := utils.GetPodTemplateGeneration(nodeState.DriverPod, m.Log)
+		if err != nil {
+			m.Log.V(consts.LogLevelError).Error(
+				err, "Failed to get pod template generation", "pod", nodeState.DriverPod)
+			return err
+		}
+		var nextState string
+		switch {
+		case podTemplateGeneration == nodeState.DriverDaemonSet.GetGeneration() && !m.isForcedReloadPending(nodeState):
+			nextState = UpgradeStateDone
+		case !requireApproval || m.isUpgradeApproved(currentClusterState, nodeState):
+			nextState = UpgradeStateUpgradeRequired
+		default:
+			continue
+		}
+		if nextState == UpgradeStateDone {
+			err = recordUpgradeOutcome(ctx, m.NodeUpgradeStateProvider, m.Log, nodeState.Node, UpgradeOutcomeCanceled, "")
+			if err != nil {
+				return err
+			}
+		}
+		err = m.NodeUpgradeStateProvider.ChangeNodeUpgradeState(ctx, nodeState.Node, nextState)
+		if err != nil {
+			m.Log.V(consts.LogLevelError).Error(
+				err, "Failed to change node upgrade state", "state", nextState)
+			return err
+		}
+		m.Log.V(consts.LogLevelInfo).Info("Node no longer waits for approval",
+			"node", nodeState.Node.Name, "state", nextState)
+	}
+	return nil
+}
+
+// ImagesPendingApproval returns the sorted list of target driver images which are not approved yet
+// for the nodes waiting for upgrade
+func (m *ClusterUpgradeStateManager) ImagesPendingApproval(
+	currentClusterState *ClusterUpgradeState, upgradePolicy *v1alpha1.OfedUpgradePolicySpec) []string {
+	if upgradePolicy == nil || !upgradePolicy.RequireApproval {
+		return nil
+	}
+	images := make(map[string]bool)
+	for _, stateName := range []string{UpgradeStateUpgradeRequired, UpgradeStatePendingApproval} {
+		for _, nodeState := range currentClusterState.NodeStates[stateName] {
+			if !m.isUpgradeApproved(currentClusterState, nodeState) {
+				images[getDriverImage(nodeState.DriverDaemonSet)] = true
+			}
+		}
+	}
+	result := make([]string, 0, len(images))
+	for image := range images {
+		result = append(result, image)
+	}
+	sort.Strings(result)
+	return result
+}
+
 // ProcessDrainNodes schedules UpgradeStateDrain nodes for drain.
 // If drain is disabled by upgrade policy, moves the nodes straight to UpgradeStatePodRestart state.
-func (m *ClusterUpgradeStateManager) ProcessDrainNodes(
-	ctx context.Context, currentClusterState *ClusterUpgradeState, drainSpec *v1alpha1.DrainSpec) error {
+// Labels and taints from nodeMarks are added to the nodes before the drain starts.
+// If devicePluginGraceSeconds is set, the nodes are cordoned and the device plugins are removed from them,
+// the drain starts once devicePluginGraceSeconds have passed, see waitForDevicePluginsPause.
+func (m *ClusterUpgradeStateManager) ProcessDrainNodes(ctx context.Context, currentClusterState *ClusterUpgradeState,
+	drainSpec *v1alpha1.DrainSpec, nodeMarks *v1alpha1.UpgradeNodeMarksSpec, devicePluginGraceSeconds int) error {
 	m.Log.V(consts.LogLevelInfo).Info("ProcessDrainNodes")
+	if nodeMarks != nil && (len(nodeMarks.Labels) != 0 || len(nodeMarks.Taints) != 0) {
+		for _, nodeState := range currentClusterState.NodeStates[UpgradeStateDrain] {
+			if _, ok := nodeState.Node.Annotations[UpgradeNodeMarksAnnotation]; ok {
+				continue
+			}
+			err := m.UncordonManager.AddNodeUpgradeMarks(ctx, nodeState.Node, nodeMarks)
+			if err != nil {
+				m.Log.V(consts.LogLevelError).Error(
+					err, "Failed to add upgrade labels and taints to the node", "node", nodeState.Node.Name)
+				return err
+			}
+		}
+	}
+	drainNodes := make([]*NodeUpgradeState, 0, len(currentClusterState.NodeStates[UpgradeStateDrain]))
+	for _, nodeState := range currentClusterState.NodeStates[UpgradeStateDrain] {
+		if devicePluginGraceSeconds > 0 {
+			paused, err := m.waitForDevicePluginsPause(ctx, nodeState.Node, devicePluginGraceSeconds)
+			if err != nil {
+				return err
+			}
+			if !paused {
+				continue
+			}
+		}
+		drainNodes = append(drainNodes, nodeState)
+	}
 	if drainSpec == nil || !drainSpec.Enable {
 		// If node drain is disabled, move nodes straight to PodRestart stage
 		m.Log.V(consts.LogLevelInfo).Info("Node drain is disabled by policy, skipping this step")
-		for _, nodeState := range currentClusterState.NodeStates[UpgradeStateDrain] {
+		for _, nodeState := range drainNodes {
 			err := m.NodeUpgradeStateProvider.ChangeNodeUpgradeState(ctx, nodeState.Node, UpgradeStatePodRestart)
 			if err != nil {
 				m.Log.V(consts.LogLevelError).Error(
@@ -273,19 +605,46 @@ func (m *ClusterUpgradeStateManager) ProcessDrainNodes(
 
 	drainConfig := DrainConfiguration{
 		Spec:  drainSpec,
-		Nodes: make([]*v1.Node, 0, len(currentClusterState.NodeStates[UpgradeStateDrain])),
+		Nodes: make([]*v1.Node, 0, len(drainNodes)),
 	}
-	for _, nodeState := range currentClusterState.NodeStates[UpgradeStateDrain] {
+	for _, nodeState := range drainNodes {
 		drainConfig.Nodes = append(drainConfig.Nodes, nodeState.Node)
 	}
 
 	return m.DrainManager.ScheduleNodesDrain(ctx, &drainConfig)
 }
 
+// waitForDevicePluginsPause cordons the node and removes the device plugins from it if they are not paused yet.
+// Returns true once graceSeconds have passed since the device plugins were paused.
+func (m *ClusterUpgradeStateManager) waitForDevicePluginsPause(
+	ctx context.Context, node *v1.Node, graceSeconds int) (bool, error) {
+	pausedAt, ok := devicePluginsPausedSince(node)
+	if ok {
+		if time.Since(pausedAt) < time.Duration(graceSeconds)*time.Second {
+			m.Log.V(consts.LogLevelDebug).Info("Waiting for the device plugins to deregister before the drain",
+				"node", node.Name, "pausedAt", pausedAt)
+			return false, nil
+		}
+		return true, nil
+	}
+	// cordon the node first so that no pods are scheduled to it during the grace period
+	err := m.UncordonManager.CordonOrUncordonNode(ctx, node, true)
+	if err != nil {
+		m.Log.V(consts.LogLevelError).Error(err, "Failed to cordon the node", "node", node.Name)
+		return false, err
+	}
+	err = m.UncordonManager.PauseDevicePlugins(ctx, node)
+	if err != nil {
+		m.Log.V(consts.LogLevelError).Error(err, "Failed to pause device plugins on the node", "node", node.Name)
+	}
+	return false, err
+}
+
 // ProcessPodRestartNodes processes UpgradeStatePodRestart nodes and schedules driver pod restart for them.
-// If the pod has already been restarted and is in Ready state - moves the node to UpgradeStateUncordonRequired state.
-func (m *ClusterUpgradeStateManager) ProcessPodRestartNodes(
-	ctx context.Context, currentClusterState *ClusterUpgradeState) error {
+// If the pod has already been restarted and is in Ready state - moves the node to UpgradeStatePostUpgradeSoak
+// or UpgradeStateUncordonRequired state, see moveToSoakOrUncordon.
+func (m *ClusterUpgradeStateManager) ProcessPodRestartNodes(ctx context.Context,
+	currentClusterState *ClusterUpgradeState, soakSeconds int, validation *v1alpha1.DriverValidationSpec) error {
 	m.Log.V(consts.LogLevelInfo).Info("ProcessPodRestartNodes")
 
 	pods := make([]*v1.Pod, 0, len(currentClusterState.NodeStates[UpgradeStatePodRestart]))
@@ -296,7 +655,7 @@ func (m *ClusterUpgradeStateManager) ProcessPodRestartNodes(
 				err, "Failed to get pod template generation", "pod", nodeState.DriverPod)
 			return err
 		}
-		if podTemplateGeneration != nodeState.DriverDaemonSet.GetGeneration() {
+		if podTemplateGeneration != nodeState.DriverDaemonSet.GetGeneration() || m.isForcedReloadPending(nodeState) {
 			// Pods should only be scheduled for restart if they are not terminating or restarting already
 			if nodeState.DriverPod.Status.Phase != "Terminating" {
 				pods = append(pods, nodeState.DriverPod)
@@ -309,25 +668,38 @@ func (m *ClusterUpgradeStateManager) ProcessPodRestartNodes(
 				return err
 			}
 			if driverPodInSync {
-				err := m.NodeUpgradeStateProvider.ChangeNodeUpgradeState(
-					ctx, nodeState.Node, UpgradeStateUncordonRequired)
+				if err := m.moveToSoakOrUncordon(ctx, nodeState, soakSeconds, validation); err != nil {
+					return err
+				}
+			} else if isDriverPodFailed(nodeState.DriverPod) {
+				m.Log.V(consts.LogLevelWarning).Info("Driver pod failed to start after restart",
+					"node", nodeState.Node.Name, "pod", nodeState.DriverPod.Name)
+				err := recordUpgradeFailure(ctx, m.NodeUpgradeStateProvider, m.Log, nodeState.Node)
+				if err != nil {
+					return err
+				}
+				err = m.NodeUpgradeStateProvider.ChangeNodeUpgradeState(ctx, nodeState.Node, UpgradeStateFailed)
 				if err != nil {
 					m.Log.V(consts.LogLevelError).Error(
-						err, "Failed to change node upgrade state", "state", UpgradeStateUncordonRequired)
+						err, "Failed to change node upgrade state", "state", UpgradeStateFailed)
 					return err
 				}
 			}
 		}
 	}
 
+	if len(pods) == 0 {
+		return nil
+	}
 	// Create pod restart manager to handle pod restarts
 	return m.PodDeleteManager.SchedulePodsRestart(ctx, pods)
 }
 
 // ProcessDrainFailedNodes processes UpgradeStateDrainFailed nodes and checks whether the driver pod on the node
-// has been successfully restarted. If the pod is in Ready state - moves the node to UpgradeStateUncordonRequired state.
-func (m *ClusterUpgradeStateManager) ProcessDrainFailedNodes(
-	ctx context.Context, currentClusterState *ClusterUpgradeState) error {
+// has been successfully restarted. If the pod is in Ready state - moves the node to UpgradeStatePostUpgradeSoak
+// or UpgradeStateUncordonRequired state, see moveToSoakOrUncordon.
+func (m *ClusterUpgradeStateManager) ProcessDrainFailedNodes(ctx context.Context,
+	currentClusterState *ClusterUpgradeState, soakSeconds int, validation *v1alpha1.DriverValidationSpec) error {
 	m.Log.V(consts.LogLevelInfo).Info("ProcessDrainFailedNodes")
 
 	for _, nodeState := range currentClusterState.NodeStates[UpgradeStateDrainFailed] {
@@ -338,10 +710,70 @@ func (m *ClusterUpgradeStateManager) ProcessDrainFailedNodes(
 			return err
 		}
 		if driverPodInSync {
-			err := m.NodeUpgradeStateProvider.ChangeNodeUpgradeState(ctx, nodeState.Node, UpgradeStateUncordonRequired)
+			if err := m.moveToSoakOrUncordon(ctx, nodeState, soakSeconds, validation); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ProcessUpgradeFailedNodes processes UpgradeStateFailed nodes and checks whether the driver pod on the node
+// has recovered. If the pod is up to date and in Ready state - moves the node to UpgradeStatePostUpgradeSoak
+// or UpgradeStateUncordonRequired state, see moveToSoakOrUncordon.
+// Nodes which failed the soak recover only after the driver pod is recreated.
+// Nodes which didn't become Ready after uncordon recover only after the node is Ready.
+func (m *ClusterUpgradeStateManager) ProcessUpgradeFailedNodes(ctx context.Context,
+	currentClusterState *ClusterUpgradeState, soakSeconds int, validation *v1alpha1.DriverValidationSpec,
+	waitForPods *v1alpha1.UncordonWaitForPodsSpec) error {
+	m.Log.V(consts.LogLevelInfo).Info("ProcessUpgradeFailedNodes")
+
+	for _, nodeState := range currentClusterState.NodeStates[UpgradeStateFailed] {
+		if isSoakFailurePending(nodeState) {
+			m.Log.V(consts.LogLevelDebug).Info("Driver pod failed the soak and was not recreated yet",
+				"node", nodeState.Node.Name, "pod", nodeState.DriverPod.Name)
+			continue
+		}
+		if _, ok := nodeState.Node.Annotations[UpgradeUncordonRetriesAnnotation]; ok {
+			if !isNodeReady(nodeState.Node) {
+				m.Log.V(consts.LogLevelDebug).Info("Node didn't become Ready after uncordon yet",
+					"node", nodeState.Node.Name)
+				continue
+			}
+			err := m.removeNodeUpgradeAnnotations(ctx, nodeState.Node,
+				UpgradeUncordonRetriesAnnotation, UpgradeUncordonCheckTimestampAnnotation)
+			if err != nil {
+				return err
+			}
+		}
+		if _, ok := nodeState.Node.Annotations[UpgradeUncordonPodsWaitStartAnnotation]; ok {
+			if waitForPods != nil && waitForPods.PodSelector != "" {
+				notReady, err := m.UncordonManager.NotReadyDaemonSetPods(ctx, nodeState.Node, waitForPods.PodSelector)
+				if err != nil {
+					m.Log.V(consts.LogLevelError).Error(
+						err, "Failed to check DaemonSet pods on the node", "node", nodeState.Node.Name)
+					return err
+				}
+				if len(notReady) > 0 {
+					m.Log.V(consts.LogLevelDebug).Info("DaemonSet pods are not Ready on the uncordoned node yet",
+						"node", nodeState.Node.Name, "daemonSets", notReady)
+					continue
+				}
+			}
+			err := m.removeNodeUpgradeAnnotations(ctx, nodeState.Node, UpgradeUncordonPodsWaitStartAnnotation)
 			if err != nil {
-				m.Log.V(consts.LogLevelError).Error(
-					err, "Failed to change node upgrade state", "state", UpgradeStateUncordonRequired)
+				return err
+			}
+		}
+		driverPodInSync, err := m.isDriverPodInSync(nodeState)
+		if err != nil {
+			m.Log.V(consts.LogLevelError).Error(
+				err, "Failed to check if driver pod on the node is in sync", "nodeState", nodeState)
+			return err
+		}
+		if driverPodInSync {
+			if err := m.moveToSoakOrUncordon(ctx, nodeState, soakSeconds, validation); err != nil {
 				return err
 			}
 		}
@@ -350,10 +782,198 @@ func (m *ClusterUpgradeStateManager) ProcessDrainFailedNodes(
 	return nil
 }
 
+// ProcessPostUpgradeSoakNodes processes UpgradeStatePostUpgradeSoak nodes and moves them to
+// UpgradeStateUncordonRequired state once the driver pod has stayed healthy for soakSeconds.
+// If the driver pod fails or any of its containers restarts during the soak, moves the node to UpgradeStateFailed.
+func (m *ClusterUpgradeStateManager) ProcessPostUpgradeSoakNodes(
+	ctx context.Context, currentClusterState *ClusterUpgradeState, soakSeconds int) error {
+	m.Log.V(consts.LogLevelInfo).Info("ProcessPostUpgradeSoakNodes")
+
+	for _, nodeState := range currentClusterState.NodeStates[UpgradeStatePostUpgradeSoak] {
+		soakStart, err := time.Parse(time.RFC3339, nodeState.Node.Annotations[UpgradeSoakStartTimestampAnnotation])
+		if err != nil {
+			// start time is unknown, restart the soak
+			err = m.NodeUpgradeStateProvider.ChangeNodeUpgradeAnnotation(
+				ctx, nodeState.Node, UpgradeSoakStartTimestampAnnotation, time.Now().UTC().Format(time.RFC3339))
+			if err != nil {
+				m.Log.V(consts.LogLevelError).Error(
+					err, "Failed to set soak start timestamp annotation", "node", nodeState.Node.Name)
+				return err
+			}
+			continue
+		}
+
+		podTemplateGeneration, err := utils.GetPodTemplateGeneration(nodeState.DriverPod, m.Log)
+		if err != nil {
+			m.Log.V(consts.LogLevelError).Error(
+				err, "Failed to get pod template generation", "pod", nodeState.DriverPod)
+			return err
+		}
+		var nextState string
+		switch {
+		case podTemplateGeneration != nodeState.DriverDaemonSet.GetGeneration():
+			// driver was updated during the soak, the node is still cordoned so the pod can be restarted right away
+			nextState = UpgradeStatePodRestart
+		case isDriverPodFailed(nodeState.DriverPod) || isDriverPodRestartedSince(nodeState.DriverPod, soakStart):
+			m.Log.V(consts.LogLevelWarning).Info("Driver pod failed during the soak",
+				"node", nodeState.Node.Name, "pod", nodeState.DriverPod.Name)
+			nextState = UpgradeStateFailed
+		case time.Since(soakStart) >= time.Duration(soakSeconds)*time.Second:
+			driverPodInSync, err := m.isDriverPodInSync(nodeState)
+			if err != nil {
+				m.Log.V(consts.LogLevelError).Error(
+					err, "Failed to check if driver pod on the node is in sync", "nodeState", nodeState)
+				return err
+			}
+			if !driverPodInSync {
+				continue
+			}
+			nextState = UpgradeStateUncordonRequired
+		default:
+			m.Log.V(consts.LogLevelDebug).Info("Node soak is in progress",
+				"node", nodeState.Node.Name, "soakStart", soakStart)
+			continue
+		}
+		if nextState == UpgradeStateFailed {
+			err = recordUpgradeFailure(ctx, m.NodeUpgradeStateProvider, m.Log, nodeState.Node)
+			if err != nil {
+				return err
+			}
+		}
+		err = m.NodeUpgradeStateProvider.ChangeNodeUpgradeState(ctx, nodeState.Node, nextState)
+		if err != nil {
+			m.Log.V(consts.LogLevelError).Error(
+				err, "Failed to change node upgrade state", "state", nextState)
+			return err
+		}
+	}
+	return nil
+}
+
+// moveToSoakOrUncordon moves the node with the up to date and ready driver pod to UpgradeStatePostUpgradeSoak state
+// if soak is enabled by the upgrade policy, otherwise to UpgradeStateUncordonRequired state.
+// If the upgrade policy specifies the driver validation, the node is moved only once the validation succeeds,
+// see scheduleDriverValidation. Device plugins removed from the node before the drain are returned to the node.
+func (m *ClusterUpgradeStateManager) moveToSoakOrUncordon(ctx context.Context, nodeState *NodeUpgradeState,
+	soakSeconds int, validation *v1alpha1.DriverValidationSpec) error {
+	if validation != nil && m.DriverValidator != nil {
+		m.scheduleDriverValidation(ctx, nodeState, soakSeconds, validation)
+		return nil
+	}
+	return m.moveValidatedToSoakOrUncordon(ctx, nodeState, soakSeconds)
+}
+
+// scheduleDriverValidation runs the driver validation of the node in the background, so the validation commands
+// of the nodes run concurrently and don't block the processing of the other nodes. The node is moved
+// by moveValidatedToSoakOrUncordon once the validation succeeds, see validateDriver.
+// The node is not scheduled again while its validation is in progress. The validation works on copies of the node
+// and its driver pod, the objects of the cluster state are read by the other steps while it runs.
+func (m *ClusterUpgradeStateManager) scheduleDriverValidation(ctx context.Context, nodeState *NodeUpgradeState,
+	soakSeconds int, validation *v1alpha1.DriverValidationSpec) {
+	nodeName := nodeState.Node.Name
+	if m.validatingNodes.Has(nodeName) {
+		m.Log.V(consts.LogLevelDebug).Info("Driver validation is in progress", "node", nodeName)
+		return
+	}
+	m.validatingNodes.Add(nodeName)
+	validatedState := &NodeUpgradeState{
+		Node:            nodeState.Node.DeepCopy(),
+		DriverPod:       nodeState.DriverPod.DeepCopy(),
+		DriverDaemonSet: nodeState.DriverDaemonSet.DeepCopy(),
+	}
+	go func() {
+		defer m.validatingNodes.Remove(nodeName)
+		valid, err := m.validateDriver(ctx, validatedState, validation)
+		if err != nil || !valid {
+			return
+		}
+		err = m.moveValidatedToSoakOrUncordon(ctx, validatedState, soakSeconds)
+		if err != nil {
+			m.Log.V(consts.LogLevelError).Error(
+				err, "Failed to move the node with the validated driver", "node", nodeName)
+		}
+	}()
+}
+
+// moveValidatedToSoakOrUncordon moves the node with the valid driver to UpgradeStatePostUpgradeSoak state
+// if soak is enabled by the upgrade policy, otherwise to UpgradeStateUncordonRequired state
+func (m *ClusterUpgradeStateManager) moveValidatedToSoakOrUncordon(
+	ctx context.Context, nodeState *NodeUpgradeState, soakSeconds int) error {
+	if _, ok := nodeState.Node.Annotations[UpgradeDevicePluginsPausedAnnotation]; ok {
+		err := m.UncordonManager.ResumeDevicePlugins(ctx, nodeState.Node)
+		if err != nil {
+			m.Log.V(consts.LogLevelError).Error(
+				err, "Failed to resume device plugins on the node", "node", nodeState.Node.Name)
+			return err
+		}
+	}
+	if soakSeconds <= 0 {
+		err := m.NodeUpgradeStateProvider.ChangeNodeUpgradeState(ctx, nodeState.Node, UpgradeStateUncordonRequired)
+		if err != nil {
+			m.Log.V(consts.LogLevelError).Error(
+				err, "Failed to change node upgrade state", "state", UpgradeStateUncordonRequired)
+		}
+		return err
+	}
+	err := m.NodeUpgradeStateProvider.ChangeNodeUpgradeAnnotation(
+		ctx, nodeState.Node, UpgradeSoakStartTimestampAnnotation, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		m.Log.V(consts.LogLevelError).Error(
+			err, "Failed to set soak start timestamp annotation", "node", nodeState.Node.Name)
+		return err
+	}
+	err = m.NodeUpgradeStateProvider.ChangeNodeUpgradeState(ctx, nodeState.Node, UpgradeStatePostUpgradeSoak)
+	if err != nil {
+		m.Log.V(consts.LogLevelError).Error(
+			err, "Failed to change node upgrade state", "state", UpgradeStatePostUpgradeSoak)
+	}
+	return err
+}
+
+// validateDriver runs the driver validation command in the driver pod of the node, true is returned if it succeeds.
+// The output of the failed command is stored in UpgradeDriverValidationFailedAnnotation and the node stays
+// in its state, a node in UpgradeStatePodRestart state is moved to UpgradeStateFailed, where the validation
+// is retried until it succeeds
+func (m *ClusterUpgradeStateManager) validateDriver(ctx context.Context, nodeState *NodeUpgradeState,
+	validation *v1alpha1.DriverValidationSpec) (bool, error) {
+	node := nodeState.Node
+	output, validationErr := m.DriverValidator.ValidateDriver(ctx, nodeState.DriverPod, validation)
+	if validationErr == nil {
+		return true, m.removeNodeUpgradeAnnotations(ctx, node, UpgradeDriverValidationFailedAnnotation)
+	}
+	m.Log.V(consts.LogLevelWarning).Info("Driver validation failed in the restarted driver pod",
+		"node", node.Name, "pod", nodeState.DriverPod.Name, "error", validationErr.Error())
+	failure := driverValidationFailure(output, validationErr)
+	if node.Annotations[UpgradeDriverValidationFailedAnnotation] != failure {
+		err := m.NodeUpgradeStateProvider.ChangeNodeUpgradeAnnotation(
+			ctx, node, UpgradeDriverValidationFailedAnnotation, failure)
+		if err != nil {
+			m.Log.V(consts.LogLevelError).Error(
+				err, "Failed to set driver validation failure annotation", "node", node.Name)
+			return false, err
+		}
+	}
+	if node.Annotations[UpgradeStateAnnotation] != UpgradeStatePodRestart {
+		return false, nil
+	}
+	if err := recordUpgradeFailure(ctx, m.NodeUpgradeStateProvider, m.Log, node); err != nil {
+		return false, err
+	}
+	err := m.NodeUpgradeStateProvider.ChangeNodeUpgradeState(ctx, node, UpgradeStateFailed)
+	if err != nil {
+		m.Log.V(consts.LogLevelError).Error(
+			err, "Failed to change node upgrade state", "state", UpgradeStateFailed)
+	}
+	return false, err
+}
+
 // ProcessUncordonRequiredNodes processes UpgradeStateUncordonRequired nodes,
-// uncordons them and moves them to UpgradeStateDone state
-func (m *ClusterUpgradeStateManager) ProcessUncordonRequiredNodes(
-	ctx context.Context, currentClusterState *ClusterUpgradeState) error {
+// uncordons them, removes the upgrade labels and taints and moves them to UpgradeStateDone state.
+// If readyRetries is set, the node is moved to UpgradeStateDone only once it is in Ready state,
+// see processNotReadyUncordonedNode.
+func (m *ClusterUpgradeStateManager) ProcessUncordonRequiredNodes(ctx context.Context,
+	currentClusterState *ClusterUpgradeState, readyRetries, backoffSeconds int,
+	waitForPods *v1alpha1.UncordonWaitForPodsSpec) error {
 	m.Log.V(consts.LogLevelInfo).Info("ProcessUncordonRequiredNodes")
 
 	for _, nodeState := range currentClusterState.NodeStates[UpgradeStateUncordonRequired] {
@@ -363,6 +983,48 @@ func (m *ClusterUpgradeStateManager) ProcessUncordonRequiredNodes(
 				err, "Node uncordone failed", "node", nodeState.Node)
 			return err
 		}
+		if _, ok := nodeState.Node.Annotations[UpgradeNodeMarksAnnotation]; ok {
+			err = m.UncordonManager.RemoveNodeUpgradeMarks(ctx, nodeState.Node)
+			if err != nil {
+				m.Log.V(consts.LogLevelError).Error(
+					err, "Failed to remove upgrade labels and taints from the node", "node", nodeState.Node.Name)
+				return err
+			}
+		}
+		if readyRetries > 0 && !isNodeReady(nodeState.Node) {
+			if err := m.processNotReadyUncordonedNode(ctx, nodeState, readyRetries, backoffSeconds); err != nil {
+				return err
+			}
+			continue
+		}
+		if waitForPods != nil && waitForPods.PodSelector != "" {
+			waiting, err := m.processUncordonedNodePods(ctx, nodeState, waitForPods)
+			if err != nil {
+				return err
+			}
+			if waiting {
+				continue
+			}
+		}
+		err = m.removeNodeUpgradeAnnotations(ctx, nodeState.Node,
+			ForceDriverReloadAnnotation, UpgradeSoakStartTimestampAnnotation,
+			UpgradeUncordonRetriesAnnotation, UpgradeUncordonCheckTimestampAnnotation,
+			UpgradeUncordonPodsWaitStartAnnotation, UpgradeFailuresAnnotation)
+		if err != nil {
+			return err
+		}
+		err = m.NodeUpgradeStateProvider.ChangeNodeUpgradeAnnotation(
+			ctx, nodeState.Node, UpgradeDoneTimestampAnnotation, time.Now().UTC().Format(time.RFC3339))
+		if err != nil {
+			m.Log.V(consts.LogLevelError).Error(
+				err, "Failed to set upgrade done timestamp annotation", "node", nodeState.Node.Name)
+			return err
+		}
+		err = recordUpgradeOutcome(ctx, m.NodeUpgradeStateProvider, m.Log, nodeState.Node,
+			UpgradeOutcomeSucceeded, getPodDriverImage(nodeState.DriverPod))
+		if err != nil {
+			return err
+		}
 		err = m.NodeUpgradeStateProvider.ChangeNodeUpgradeState(ctx, nodeState.Node, UpgradeStateDone)
 		if err != nil {
 			m.Log.V(consts.LogLevelError).Error(
@@ -373,6 +1035,374 @@ func (m *ClusterUpgradeStateManager) ProcessUncordonRequiredNodes(
 	return nil
 }
 
+// processNotReadyUncordonedNode checks the Ready state of the uncordoned node again with exponential backoff
+// starting from backoffSeconds. The node stays in UpgradeStateUncordonRequired state and occupies an upgrade slot
+// until it is Ready, when readyRetries are exhausted the node is moved to UpgradeStateFailed.
+func (m *ClusterUpgradeStateManager) processNotReadyUncordonedNode(
+	ctx context.Context, nodeState *NodeUpgradeState, readyRetries, backoffSeconds int) error {
+	node := nodeState.Node
+	retries, err := strconv.Atoi(node.Annotations[UpgradeUncordonRetriesAnnotation])
+	if err != nil {
+		retries = 0
+	}
+	lastCheck, err := time.Parse(time.RFC3339, node.Annotations[UpgradeUncordonCheckTimestampAnnotation])
+	if err == nil {
+		backoff := uncordonReadyBackoff(backoffSeconds, retries)
+		if time.Since(lastCheck) < backoff {
+			m.Log.V(consts.LogLevelDebug).Info("Waiting for the uncordoned node to become Ready",
+				"node", node.Name, "retries", retries, "backoff", backoff)
+			return nil
+		}
+		if retries >= readyRetries {
+			m.Log.V(consts.LogLevelWarning).Info("Node didn't become Ready after uncordon, manual interaction required",
+				"node", node.Name, "retries", retries)
+			err = recordUpgradeFailure(ctx, m.NodeUpgradeStateProvider, m.Log, node)
+			if err != nil {
+				return err
+			}
+			err = m.NodeUpgradeStateProvider.ChangeNodeUpgradeState(ctx, node, UpgradeStateFailed)
+			if err != nil {
+				m.Log.V(consts.LogLevelError).Error(
+					err, "Failed to change node upgrade state", "state", UpgradeStateFailed)
+			}
+			return err
+		}
+		retries++
+	}
+	m.Log.V(consts.LogLevelInfo).Info("Uncordoned node is not Ready", "node", node.Name, "retries", retries)
+	err = m.NodeUpgradeStateProvider.ChangeNodeUpgradeAnnotation(
+		ctx, node, UpgradeUncordonRetriesAnnotation, strconv.Itoa(retries))
+	if err != nil {
+		m.Log.V(consts.LogLevelError).Error(
+			err, "Failed to set uncordon retries annotation", "node", node.Name)
+		return err
+	}
+	err = m.NodeUpgradeStateProvider.ChangeNodeUpgradeAnnotation(
+		ctx, node, UpgradeUncordonCheckTimestampAnnotation, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		m.Log.V(consts.LogLevelError).Error(
+			err, "Failed to set uncordon check timestamp annotation", "node", node.Name)
+	}
+	return err
+}
+
+// processUncordonedNodePods waits for the DaemonSet pods selected by waitForPods to be Ready on the uncordoned node,
+// it returns true while the node waits. The node stays in UpgradeStateUncordonRequired state and occupies
+// an upgrade slot until the pods are Ready, after the timeout the node is moved to UpgradeStateFailed
+func (m *ClusterUpgradeStateManager) processUncordonedNodePods(
+	ctx context.Context, nodeState *NodeUpgradeState, waitForPods *v1alpha1.UncordonWaitForPodsSpec) (bool, error) {
+	node := nodeState.Node
+	notReady, err := m.UncordonManager.NotReadyDaemonSetPods(ctx, node, waitForPods.PodSelector)
+	if err != nil {
+		m.Log.V(consts.LogLevelError).Error(err, "Failed to check DaemonSet pods on the node", "node", node.Name)
+		return true, err
+	}
+	if len(notReady) == 0 {
+		return false, nil
+	}
+	waitStart, err := time.Parse(time.RFC3339, node.Annotations[UpgradeUncordonPodsWaitStartAnnotation])
+	if err != nil {
+		m.Log.V(consts.LogLevelInfo).Info("Waiting for DaemonSet pods on the uncordoned node",
+			"node", node.Name, "daemonSets", notReady)
+		err = m.NodeUpgradeStateProvider.ChangeNodeUpgradeAnnotation(
+			ctx, node, UpgradeUncordonPodsWaitStartAnnotation, time.Now().UTC().Format(time.RFC3339))
+		if err != nil {
+			m.Log.V(consts.LogLevelError).Error(
+				err, "Failed to set uncordon pods wait start annotation", "node", node.Name)
+		}
+		return true, err
+	}
+	if time.Since(waitStart) < time.Duration(waitForPods.TimeoutSeconds)*time.Second {
+		m.Log.V(consts.LogLevelDebug).Info("Waiting for DaemonSet pods on the uncordoned node",
+			"node", node.Name, "daemonSets", notReady)
+		return true, nil
+	}
+	m.Log.V(consts.LogLevelWarning).Info(
+		"DaemonSet pods are not Ready on the uncordoned node, manual interaction required",
+		"node", node.Name, "daemonSets", notReady)
+	err = recordUpgradeFailure(ctx, m.NodeUpgradeStateProvider, m.Log, node)
+	if err != nil {
+		return true, err
+	}
+	err = m.NodeUpgradeStateProvider.ChangeNodeUpgradeState(ctx, node, UpgradeStateFailed)
+	if err != nil {
+		m.Log.V(consts.LogLevelError).Error(err, "Failed to change node upgrade state", "state", UpgradeStateFailed)
+	}
+	return true, err
+}
+
+// removeNodeUpgradeAnnotations removes the given annotations from the node if they are set
+func (m *ClusterUpgradeStateManager) removeNodeUpgradeAnnotations(
+	ctx context.Context, node *v1.Node, annotations ...string) error {
+	for _, annotation := range annotations {
+		if _, ok := node.Annotations[annotation]; !ok {
+			continue
+		}
+		err := m.NodeUpgradeStateProvider.ChangeNodeUpgradeAnnotation(ctx, node, annotation, "null")
+		if err != nil {
+			m.Log.V(consts.LogLevelError).Error(
+				err, "Failed to remove node upgrade annotation", "node", node.Name, "annotation", annotation)
+			return err
+		}
+	}
+	return nil
+}
+
+// removeStaleNodeUpgradeMarks removes the labels and taints added for the upgrade from the nodes which are
+// not upgrading anymore, e.g. the nodes which failed the drain or the upgrade or went back to an earlier state.
+// The marks of the nodes which are drained, restarted, soaked or uncordoned are kept, they are removed at uncordon.
+func (m *ClusterUpgradeStateManager) removeStaleNodeUpgradeMarks(
+	ctx context.Context, currentClusterState *ClusterUpgradeState) error {
+	for stateName, nodeStates := range currentClusterState.NodeStates {
+		switch stateName {
+		case UpgradeStateDrain, UpgradeStatePodRestart, UpgradeStatePostUpgradeSoak, UpgradeStateUncordonRequired:
+			continue
+		}
+		for _, nodeState := range nodeStates {
+			if _, ok := nodeState.Node.Annotations[UpgradeNodeMarksAnnotation]; !ok {
+				continue
+			}
+			err := m.UncordonManager.RemoveNodeUpgradeMarks(ctx, nodeState.Node)
+			if err != nil {
+				m.Log.V(consts.LogLevelError).Error(
+					err, "Failed to remove upgrade labels and taints from the node", "node", nodeState.Node.Name)
+				return err
+			}
+			m.Log.V(consts.LogLevelInfo).Info("Removed upgrade labels and taints from the node which is not upgrading",
+				"node", nodeState.Node.Name, "state", stateName)
+		}
+	}
+	return nil
+}
+
+// isUpgradeCooldownActive returns true if any node in UpgradeStateDone state finished its upgrade
+// less than cooldownSeconds ago
+func (m *ClusterUpgradeStateManager) isUpgradeCooldownActive(
+	currentClusterState *ClusterUpgradeState, cooldownSeconds int) bool {
+	if cooldownSeconds <= 0 {
+		return false
+	}
+	cooldown := time.Duration(cooldownSeconds) * time.Second
+	for _, nodeState := range currentClusterState.NodeStates[UpgradeStateDone] {
+		value, ok := nodeState.Node.Annotations[UpgradeDoneTimestampAnnotation]
+		if !ok {
+			continue
+		}
+		doneTime, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			m.Log.V(consts.LogLevelWarning).Info("Failed to parse upgrade done timestamp, ignoring",
+				"node", nodeState.Node.Name, "value", value)
+			continue
+		}
+		if time.Since(doneTime) < cooldown {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUpgradeAborted returns true if the number of nodes which failed the upgrade
+// reached the max failures limit of the upgrade policy
+func (m *ClusterUpgradeStateManager) IsUpgradeAborted(
+	currentClusterState *ClusterUpgradeState, upgradePolicy *v1alpha1.OfedUpgradePolicySpec) bool {
+	if upgradePolicy == nil || upgradePolicy.MaxFailures <= 0 {
+		return false
+	}
+	currentClusterState = withoutQuarantinedNodes(currentClusterState)
+	failedNodes := len(currentClusterState.NodeStates[UpgradeStateDrainFailed]) +
+		len(currentClusterState.NodeStates[UpgradeStateFailed])
+	return failedNodes >= upgradePolicy.MaxFailures
+}
+
+// StalledNodes returns the sorted list of nodes which stay in upgrade-required, pending-approval or drain state
+// longer than the max pre-upgrade state time of the upgrade policy, nodes which require manual upgrade are not stalled
+func (m *ClusterUpgradeStateManager) StalledNodes(
+	currentClusterState *ClusterUpgradeState, upgradePolicy *v1alpha1.OfedUpgradePolicySpec) []string {
+	if upgradePolicy == nil || upgradePolicy.MaxPreUpgradeStateSeconds <= 0 {
+		return nil
+	}
+	maxDuration := time.Duration(upgradePolicy.MaxPreUpgradeStateSeconds) * time.Second
+	var result []string
+	for _, stateName := range PreUpgradeStates() {
+		for _, nodeState := range currentClusterState.NodeStates[stateName] {
+			if _, manual := nodeState.Node.Annotations[UpgradeManualRequiredAnnotation]; manual {
+				continue
+			}
+			duration, ok := GetNodeUpgradeStateDuration(nodeState.Node)
+			if ok && duration > maxDuration {
+				result = append(result, nodeState.Node.Name)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// CordonedNodes returns the sorted list of unschedulable nodes in the upgrade states in which the upgrade flow
+// keeps the node cordoned, from drain until the node is uncordoned
+func (m *ClusterUpgradeStateManager) CordonedNodes(currentClusterState *ClusterUpgradeState) []string {
+	var result []string
+	for _, stateName := range []string{UpgradeStateDrain, UpgradeStateDrainFailed, UpgradeStatePodRestart,
+		UpgradeStatePostUpgradeSoak, UpgradeStateFailed, UpgradeStateUncordonRequired} {
+		for _, nodeState := range currentClusterState.NodeStates[stateName] {
+			if nodeState.Node.Spec.Unschedulable {
+				result = append(result, nodeState.Node.Name)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// UnavailableNodesCount returns the number of nodes made unavailable by the upgrade flow: nodes scheduled for drain
+// and nodes which are kept cordoned until they are uncordoned
+func (m *ClusterUpgradeStateManager) UnavailableNodesCount(currentClusterState *ClusterUpgradeState) int {
+	count := len(currentClusterState.NodeStates[UpgradeStateDrain])
+	for _, stateName := range []string{UpgradeStateDrainFailed, UpgradeStatePodRestart,
+		UpgradeStatePostUpgradeSoak, UpgradeStateFailed, UpgradeStateUncordonRequired} {
+		for _, nodeState := range currentClusterState.NodeStates[stateName] {
+			if nodeState.Node.Spec.Unschedulable {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// isUpgradeApproved returns true if the target driver image of the node is in the list of approved images
+func (m *ClusterUpgradeStateManager) isUpgradeApproved(
+	currentClusterState *ClusterUpgradeState, nodeState *NodeUpgradeState) bool {
+	image := getDriverImage(nodeState.DriverDaemonSet)
+	if image == "" {
+		return false
+	}
+	for _, approved := range currentClusterState.ApprovedImages {
+		if approved == image {
+			return true
+		}
+	}
+	return false
+}
+
+// getDriverImage returns the image of the driver container in the driver daemon set
+func getDriverImage(ds *appsv1.DaemonSet) string {
+	if ds == nil {
+		return ""
+	}
+	for i := range ds.Spec.Template.Spec.Containers {
+		if ds.Spec.Template.Spec.Containers[i].Name == OfedDriverContainerName {
+			return ds.Spec.Template.Spec.Containers[i].Image
+		}
+	}
+	return ""
+}
+
+// isInUpgradeScope returns true if the driver pod of the node may be upgraded, the upgrade of all nodes is allowed
+// unless it is limited to the drivers older than the MinDriverVersion of the cluster state
+func isInUpgradeScope(currentClusterState *ClusterUpgradeState, nodeState *NodeUpgradeState) bool {
+	if currentClusterState.MinDriverVersion == "" {
+		return true
+	}
+	version, ok := utils.ParseDriverVersion(getPodDriverImage(nodeState.DriverPod))
+	if !ok {
+		return false
+	}
+	cmp, err := utils.CompareDriverVersions(version, currentClusterState.MinDriverVersion)
+	return err == nil && cmp < 0
+}
+
+// getPodDriverImage returns the image of the driver container in the driver pod
+func getPodDriverImage(pod *v1.Pod) string {
+	if pod == nil {
+		return ""
+	}
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == OfedDriverContainerName {
+			return pod.Spec.Containers[i].Image
+		}
+	}
+	return ""
+}
+
+// uncordonReadyBackoff returns the time to wait before the next Ready state check of the uncordoned node,
+// the initial backoff is doubled for each performed retry
+func uncordonReadyBackoff(backoffSeconds, retries int) time.Duration {
+	backoff := time.Duration(backoffSeconds) * time.Second
+	if backoff <= 0 {
+		backoff = defaultUncordonReadyBackoff
+	}
+	for i := 0; i < retries && backoff < maxUncordonReadyBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxUncordonReadyBackoff {
+		backoff = maxUncordonReadyBackoff
+	}
+	return backoff
+}
+
+// isNodeReady returns true if the node has Ready condition with True status
+func isNodeReady(node *v1.Node) bool {
+	for i := range node.Status.Conditions {
+		if node.Status.Conditions[i].Type == v1.NodeReady {
+			return node.Status.Conditions[i].Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// isDriverPodFailed returns true if the driver pod has failed or its container is crash looping
+func isDriverPodFailed(pod *v1.Pod) bool {
+	if pod.Status.Phase == v1.PodFailed {
+		return true
+	}
+	for i := range pod.Status.ContainerStatuses {
+		waiting := pod.Status.ContainerStatuses[i].State.Waiting
+		if waiting != nil && waiting.Reason == "CrashLoopBackOff" {
+			return true
+		}
+	}
+	return false
+}
+
+// isDriverPodRestartedSince returns true if a container of the driver pod has terminated after the given time
+func isDriverPodRestartedSince(pod *v1.Pod, since time.Time) bool {
+	for i := range pod.Status.ContainerStatuses {
+		terminated := pod.Status.ContainerStatuses[i].LastTerminationState.Terminated
+		if terminated != nil && terminated.FinishedAt.Time.After(since) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSoakFailurePending returns true if the node failed the post upgrade soak
+// and the driver pod on the node was created before the soak started
+func isSoakFailurePending(nodeState *NodeUpgradeState) bool {
+	value, ok := nodeState.Node.Annotations[UpgradeSoakStartTimestampAnnotation]
+	if !ok {
+		return false
+	}
+	soakStart, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return false
+	}
+	return nodeState.DriverPod.CreationTimestamp.Time.Before(soakStart)
+}
+
+// isForcedReloadPending returns true if a forced driver reload was accepted for the node
+// and the driver pod on the node was created before that
+func (m *ClusterUpgradeStateManager) isForcedReloadPending(nodeState *NodeUpgradeState) bool {
+	value, ok := nodeState.Node.Annotations[ForceDriverReloadAnnotation]
+	if !ok {
+		return false
+	}
+	requestTime, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return false
+	}
+	return nodeState.DriverPod.CreationTimestamp.Time.Before(requestTime)
+}
+
 func (m *ClusterUpgradeStateManager) isDriverPodInSync(nodeState *NodeUpgradeState) (bool, error) {
 	podTemplateGeneration, err := utils.GetPodTemplateGeneration(nodeState.DriverPod, m.Log)
 	if err != nil {
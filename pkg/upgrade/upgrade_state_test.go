@@ -20,6 +20,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -29,6 +31,7 @@ import (
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/Mellanox/network-operator/api/v1alpha1"
+	"github.com/Mellanox/network-operator/pkg/nodeinfo"
 	"github.com/Mellanox/network-operator/pkg/upgrade"
 	"github.com/Mellanox/network-operator/pkg/upgrade/mocks"
 	"github.com/Mellanox/network-operator/pkg/utils"
@@ -83,6 +86,92 @@ var _ = Describe("UpgradeStateManager tests", func() {
 		Expect(getNodeUpgradeState(DoneToDoneNode)).To(Equal(upgrade.UpgradeStateDone))
 		Expect(getNodeUpgradeState(DoneToUpgradeRequiredNode)).To(Equal(upgrade.UpgradeStateUpgradeRequired))
 	})
+	It("UpgradeStateManager should move only nodes with drivers older than the minimum version to UpgradeRequired "+
+		"if the upgrade is limited to them", func() {
+		ctx := context.TODO()
+
+		daemonSet := &appsv1.DaemonSet{ObjectMeta: v1.ObjectMeta{Generation: 2}}
+		newOutdatedPod := func(image string) *corev1.Pod {
+			return &corev1.Pod{
+				ObjectMeta: v1.ObjectMeta{Labels: map[string]string{utils.PodTemplateGenerationLabel: "1"}},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{
+					{Name: upgrade.OfedDriverContainerName, Image: image}}}}
+		}
+
+		belowMinNode := nodeWithUpgradeState(upgrade.UpgradeStateDone)
+		aboveMinNode := nodeWithUpgradeState(upgrade.UpgradeStateDone)
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.MinDriverVersion = "5.6-1.0.3.3"
+		clusterState.NodeStates[upgrade.UpgradeStateDone] = []*upgrade.NodeUpgradeState{
+			{Node: belowMinNode, DriverPod: newOutdatedPod("mellanox/mofed:5.5-1.0.3.2"), DriverDaemonSet: daemonSet},
+			{Node: aboveMinNode, DriverPod: newOutdatedPod("mellanox/mofed:5.6-1.0.3.3"), DriverDaemonSet: daemonSet},
+		}
+
+		stateManager := upgrade.NewClusterUpdateStateManager(
+			&drainManager, &podDeleteManager, &uncordonManager, &nodeUpgradeStateProvider, log, k8sClient, k8sInterface)
+		Expect(stateManager.ApplyState(ctx, &clusterState, &v1alpha1.OfedUpgradePolicySpec{AutoUpgrade: true})).To(Succeed())
+		Expect(getNodeUpgradeState(belowMinNode)).To(Equal(upgrade.UpgradeStateUpgradeRequired))
+		Expect(getNodeUpgradeState(aboveMinNode)).To(Equal(upgrade.UpgradeStateDone))
+	})
+	It("UpgradeStateManager should prune obsolete upgrade annotations of up-to-date Done nodes", func() {
+		ctx := context.TODO()
+
+		daemonSet := &appsv1.DaemonSet{ObjectMeta: v1.ObjectMeta{Generation: 2}}
+		upToDatePod := &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{Labels: map[string]string{utils.PodTemplateGenerationLabel: "2"}}}
+		node := nodeWithUpgradeState(upgrade.UpgradeStateDone)
+		node.Annotations[upgrade.UpgradeUncordonRetriesAnnotation] = "2"
+		node.Annotations[upgrade.UpgradeDrainBlockedAnnotation] = "Deployment default/app"
+		node.Annotations[upgrade.UpgradeDoneTimestampAnnotation] = time.Now().UTC().Format(time.RFC3339)
+
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStateDone] = []*upgrade.NodeUpgradeState{
+			{Node: node, DriverPod: upToDatePod, DriverDaemonSet: daemonSet},
+		}
+
+		stateManager := upgrade.NewClusterUpdateStateManager(
+			&drainManager, &podDeleteManager, &uncordonManager, &nodeUpgradeStateProvider, log, k8sClient, k8sInterface)
+		Expect(stateManager.ApplyState(ctx, &clusterState, &v1alpha1.OfedUpgradePolicySpec{AutoUpgrade: true})).To(Succeed())
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateDone))
+		Expect(node.Annotations).NotTo(HaveKey(upgrade.UpgradeUncordonRetriesAnnotation))
+		Expect(node.Annotations).NotTo(HaveKey(upgrade.UpgradeDrainBlockedAnnotation))
+		Expect(node.Annotations).To(HaveKey(upgrade.UpgradeDoneTimestampAnnotation))
+	})
+	It("UpgradeStateManager should continue if the upgrade annotations of a node can't be pruned", func() {
+		ctx := context.TODO()
+
+		daemonSet := &appsv1.DaemonSet{ObjectMeta: v1.ObjectMeta{Generation: 2}}
+		upToDatePod := &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{Labels: map[string]string{utils.PodTemplateGenerationLabel: "2"}}}
+		failingNode := nodeWithUpgradeState(upgrade.UpgradeStateDone)
+		failingNode.Name = "failing-node"
+		failingNode.Annotations[upgrade.UpgradeDrainBlockedAnnotation] = "Deployment default/app"
+		node := nodeWithUpgradeState(upgrade.UpgradeStateDone)
+		node.Name = "node"
+		node.Annotations[upgrade.UpgradeDrainBlockedAnnotation] = "Deployment default/app"
+
+		stateProvider := mocks.NodeUpgradeStateProvider{}
+		stateProvider.
+			On("PruneNodeUpgradeAnnotations", mock.Anything, failingNode).
+			Return(errors.New("node update failed"))
+		stateProvider.
+			On("PruneNodeUpgradeAnnotations", mock.Anything, node).
+			Return(func(ctx context.Context, node *corev1.Node) error {
+				delete(node.Annotations, upgrade.UpgradeDrainBlockedAnnotation)
+				return nil
+			})
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStateDone] = []*upgrade.NodeUpgradeState{
+			{Node: failingNode, DriverPod: upToDatePod, DriverDaemonSet: daemonSet},
+			{Node: node, DriverPod: upToDatePod, DriverDaemonSet: daemonSet},
+		}
+
+		stateManager := upgrade.NewClusterUpdateStateManager(
+			&drainManager, &podDeleteManager, &uncordonManager, &stateProvider, log, k8sClient, k8sInterface)
+		Expect(stateManager.ApplyState(ctx, &clusterState, &v1alpha1.OfedUpgradePolicySpec{AutoUpgrade: true})).To(Succeed())
+		Expect(failingNode.Annotations).To(HaveKey(upgrade.UpgradeDrainBlockedAnnotation))
+		Expect(node.Annotations).NotTo(HaveKey(upgrade.UpgradeDrainBlockedAnnotation))
+	})
 	It("UpgradeStateManager should schedule upgrade on all nodes if maxParallel upgrades is set to 0", func() {
 		ctx := context.TODO()
 
@@ -261,6 +350,175 @@ var _ = Describe("UpgradeStateManager tests", func() {
 		expectedDrainSpec.PodSelector = fmt.Sprintf("%s,%s", policy.DrainSpec.PodSelector, skipDrainPodSelector)
 		Expect(stateManager.ApplyState(ctx, &clusterState, &policy)).To(Succeed())
 	})
+	It("UpgradeStateManager should add node marks at drain start and remove them at uncordon", func() {
+		ctx := context.TODO()
+
+		node := nodeWithUpgradeState(upgrade.UpgradeStateDrain)
+		policy := &v1alpha1.OfedUpgradePolicySpec{
+			AutoUpgrade: true,
+			DrainSpec:   &v1alpha1.DrainSpec{Enable: true},
+			NodeMarks: &v1alpha1.UpgradeNodeMarksSpec{
+				Labels: map[string]string{"example.com/upgrading": "true"},
+			},
+		}
+
+		uncordonManagerMock := mocks.UncordonManager{}
+		uncordonManagerMock.
+			On("AddNodeUpgradeMarks", mock.Anything, mock.Anything, mock.Anything).
+			Return(func(ctx context.Context, node *corev1.Node, marks *v1alpha1.UpgradeNodeMarksSpec) error {
+				return upgrade.AddNodeUpgradeMarks(node, marks)
+			})
+		uncordonManagerMock.
+			On("RemoveNodeUpgradeMarks", mock.Anything, mock.Anything).
+			Return(func(ctx context.Context, node *corev1.Node) error {
+				upgrade.RemoveNodeUpgradeMarks(node)
+				return nil
+			})
+		uncordonManagerMock.
+			On("CordonOrUncordonNode", mock.Anything, mock.Anything, mock.Anything).
+			Return(nil)
+		stateManager := upgrade.NewClusterUpdateStateManager(
+			&drainManager, &podDeleteManager, &uncordonManagerMock, &nodeUpgradeStateProvider, log, k8sClient, k8sInterface)
+
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStateDrain] = []*upgrade.NodeUpgradeState{{Node: node}}
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(node.Labels).To(HaveKeyWithValue("example.com/upgrading", "true"))
+
+		// marks are added only once
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		uncordonManagerMock.AssertNumberOfCalls(GinkgoT(), "AddNodeUpgradeMarks", 1)
+
+		// the recorded marks are removed even if the policy doesn't define them anymore
+		policy.NodeMarks = nil
+		node.Annotations[upgrade.UpgradeStateAnnotation] = upgrade.UpgradeStateUncordonRequired
+		clusterState = upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStateUncordonRequired] = []*upgrade.NodeUpgradeState{{Node: node}}
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateDone))
+		Expect(node.Labels).NotTo(HaveKey("example.com/upgrading"))
+		Expect(node.Annotations).NotTo(HaveKey(upgrade.UpgradeNodeMarksAnnotation))
+	})
+	It("UpgradeStateManager should remove node marks from nodes which are not upgrading anymore", func() {
+		ctx := context.TODO()
+
+		daemonSet := &appsv1.DaemonSet{ObjectMeta: v1.ObjectMeta{Generation: 2}}
+		outdatedPod := &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{Labels: map[string]string{utils.PodTemplateGenerationLabel: "1"}}}
+		marks := &v1alpha1.UpgradeNodeMarksSpec{Labels: map[string]string{"example.com/upgrading": "true"}}
+		markedNode := func(state string) *corev1.Node {
+			node := nodeWithUpgradeState(state)
+			Expect(upgrade.AddNodeUpgradeMarks(node, marks)).To(Succeed())
+			return node
+		}
+		failedNode := markedNode(upgrade.UpgradeStateFailed)
+		drainFailedNode := markedNode(upgrade.UpgradeStateDrainFailed)
+		upgradeRequiredNode := markedNode(upgrade.UpgradeStateUpgradeRequired)
+		podRestartNode := markedNode(upgrade.UpgradeStatePodRestart)
+
+		uncordonManagerMock := mocks.UncordonManager{}
+		uncordonManagerMock.
+			On("RemoveNodeUpgradeMarks", mock.Anything, mock.Anything).
+			Return(func(ctx context.Context, node *corev1.Node) error {
+				upgrade.RemoveNodeUpgradeMarks(node)
+				return nil
+			})
+		stateManager := upgrade.NewClusterUpdateStateManager(
+			&drainManager, &podDeleteManager, &uncordonManagerMock, &nodeUpgradeStateProvider, log, k8sClient, k8sInterface)
+
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStateFailed] = []*upgrade.NodeUpgradeState{
+			{Node: failedNode, DriverPod: outdatedPod, DriverDaemonSet: daemonSet}}
+		clusterState.NodeStates[upgrade.UpgradeStateDrainFailed] = []*upgrade.NodeUpgradeState{
+			{Node: drainFailedNode, DriverPod: outdatedPod, DriverDaemonSet: daemonSet}}
+		// the node went back to an earlier state, e.g. its state annotation was reset
+		clusterState.NodeStates[upgrade.UpgradeStateUpgradeRequired] = []*upgrade.NodeUpgradeState{
+			{Node: upgradeRequiredNode}}
+		clusterState.NodeStates[upgrade.UpgradeStatePodRestart] = []*upgrade.NodeUpgradeState{
+			{Node: podRestartNode, DriverPod: outdatedPod, DriverDaemonSet: daemonSet}}
+		policy := &v1alpha1.OfedUpgradePolicySpec{AutoUpgrade: true, MaxParallelUpgrades: 1}
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+
+		for _, node := range []*corev1.Node{failedNode, drainFailedNode, upgradeRequiredNode} {
+			Expect(node.Labels).NotTo(HaveKey("example.com/upgrading"))
+			Expect(node.Annotations).NotTo(HaveKey(upgrade.UpgradeNodeMarksAnnotation))
+		}
+		// the marks of upgrading nodes are removed at uncordon
+		Expect(podRestartNode.Labels).To(HaveKeyWithValue("example.com/upgrading", "true"))
+		uncordonManagerMock.AssertNumberOfCalls(GinkgoT(), "RemoveNodeUpgradeMarks", 3)
+	})
+	It("UpgradeStateManager should pause device plugins before drain and resume them after driver restart", func() {
+		ctx := context.TODO()
+
+		node := nodeWithUpgradeState(upgrade.UpgradeStateDrain)
+		policy := &v1alpha1.OfedUpgradePolicySpec{
+			AutoUpgrade:              true,
+			DrainSpec:                &v1alpha1.DrainSpec{Enable: true},
+			DevicePluginGraceSeconds: 30,
+		}
+
+		var drainedNodes []*corev1.Node
+		drainManagerMock := mocks.DrainManager{}
+		drainManagerMock.
+			On("ScheduleNodesDrain", mock.Anything, mock.Anything).
+			Return(func(ctx context.Context, drainConfig *upgrade.DrainConfiguration) error {
+				drainedNodes = append(drainedNodes, drainConfig.Nodes...)
+				return nil
+			})
+		uncordonManagerMock := mocks.UncordonManager{}
+		uncordonManagerMock.
+			On("CordonOrUncordonNode", mock.Anything, mock.Anything, true).
+			Return(nil)
+		uncordonManagerMock.
+			On("PauseDevicePlugins", mock.Anything, mock.Anything).
+			Return(func(ctx context.Context, node *corev1.Node) error {
+				upgrade.PauseDevicePlugins(node, time.Now())
+				return nil
+			})
+		uncordonManagerMock.
+			On("ResumeDevicePlugins", mock.Anything, mock.Anything).
+			Return(func(ctx context.Context, node *corev1.Node) error {
+				upgrade.ResumeDevicePlugins(node)
+				return nil
+			})
+		stateManager := upgrade.NewClusterUpdateStateManager(
+			&drainManagerMock, &podDeleteManager, &uncordonManagerMock, &nodeUpgradeStateProvider,
+			log, k8sClient, k8sInterface)
+
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStateDrain] = []*upgrade.NodeUpgradeState{{Node: node}}
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(node.Labels).To(HaveKeyWithValue(nodeinfo.NodeLabelWaitOFED, "true"))
+		Expect(node.Annotations).To(HaveKey(upgrade.UpgradeDevicePluginsPausedAnnotation))
+		uncordonManagerMock.AssertCalled(GinkgoT(), "CordonOrUncordonNode", mock.Anything, node, true)
+
+		// drain is postponed until the grace period is over
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(drainedNodes).To(BeEmpty())
+		uncordonManagerMock.AssertNumberOfCalls(GinkgoT(), "PauseDevicePlugins", 1)
+
+		node.Annotations[upgrade.UpgradeDevicePluginsPausedAnnotation] =
+			time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(drainedNodes).To(Equal([]*corev1.Node{node}))
+
+		daemonSet := &appsv1.DaemonSet{ObjectMeta: v1.ObjectMeta{Generation: 3}}
+		pod := &corev1.Pod{
+			Status: corev1.PodStatus{
+				Phase:             "Running",
+				ContainerStatuses: []corev1.ContainerStatus{{Ready: true}},
+			},
+			ObjectMeta: v1.ObjectMeta{Labels: map[string]string{utils.PodTemplateGenerationLabel: "3"}}}
+		node.Annotations[upgrade.UpgradeStateAnnotation] = upgrade.UpgradeStatePodRestart
+		clusterState = upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStatePodRestart] = []*upgrade.NodeUpgradeState{
+			{Node: node, DriverPod: pod, DriverDaemonSet: daemonSet},
+		}
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateUncordonRequired))
+		Expect(node.Labels).To(HaveKeyWithValue(nodeinfo.NodeLabelWaitOFED, "false"))
+		Expect(node.Annotations).NotTo(HaveKey(upgrade.UpgradeDevicePluginsPausedAnnotation))
+	})
 	It("UpgradeStateManager should fail if drain manager returns an error", func() {
 		ctx := context.TODO()
 
@@ -337,6 +595,61 @@ var _ = Describe("UpgradeStateManager tests", func() {
 			&drainManager, &podDeleteManagerMock, &uncordonManager, &nodeUpgradeStateProvider, log, k8sClient, k8sInterface)
 		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
 	})
+	It("UpgradeStateManager should reload driver on the node with forced reload annotation", func() {
+		ctx := context.TODO()
+
+		daemonSet := &appsv1.DaemonSet{ObjectMeta: v1.ObjectMeta{Generation: 1}}
+		upToDatePod := &corev1.Pod{
+			Status: corev1.PodStatus{Phase: "Running"},
+			ObjectMeta: v1.ObjectMeta{
+				Labels:            map[string]string{utils.PodTemplateGenerationLabel: "1"},
+				CreationTimestamp: v1.NewTime(time.Now().Add(-time.Hour)),
+			}}
+		node := nodeWithUpgradeState(upgrade.UpgradeStateDone)
+		node.Annotations[upgrade.ForceDriverReloadAnnotation] = "true"
+
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStateDone] = []*upgrade.NodeUpgradeState{
+			{Node: node, DriverPod: upToDatePod, DriverDaemonSet: daemonSet},
+		}
+
+		policy := &v1alpha1.OfedUpgradePolicySpec{
+			AutoUpgrade: true,
+		}
+
+		stateManager := upgrade.NewClusterUpdateStateManager(
+			&drainManager, &podDeleteManager, &uncordonManager, &nodeUpgradeStateProvider, log, k8sClient, k8sInterface)
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateUpgradeRequired))
+		Expect(node.Annotations[upgrade.ForceDriverReloadAnnotation]).NotTo(Equal("true"))
+
+		node.Annotations[upgrade.UpgradeStateAnnotation] = upgrade.UpgradeStatePodRestart
+		clusterState = upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStatePodRestart] = []*upgrade.NodeUpgradeState{
+			{Node: node, DriverPod: upToDatePod, DriverDaemonSet: daemonSet},
+		}
+		podDeleteManagerMock := mocks.PodDeleteManager{}
+		podDeleteManagerMock.
+			On("SchedulePodsRestart", mock.Anything, mock.Anything).
+			Return(func(ctx context.Context, podsToDelete []*corev1.Pod) error {
+				Expect(podsToDelete).To(HaveLen(1))
+				Expect(podsToDelete[0]).To(Equal(upToDatePod))
+				return nil
+			})
+		stateManager = upgrade.NewClusterUpdateStateManager(
+			&drainManager, &podDeleteManagerMock, &uncordonManager, &nodeUpgradeStateProvider, log, k8sClient, k8sInterface)
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStatePodRestart))
+
+		node.Annotations[upgrade.UpgradeStateAnnotation] = upgrade.UpgradeStateUncordonRequired
+		clusterState = upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStateUncordonRequired] = []*upgrade.NodeUpgradeState{
+			{Node: node, DriverPod: upToDatePod, DriverDaemonSet: daemonSet},
+		}
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateDone))
+		Expect(node.Annotations).NotTo(HaveKey(upgrade.ForceDriverReloadAnnotation))
+	})
 	It("UpgradeStateManager should move pod to UncordonRequired state"+
 		"if it's in PodRestart or DrainFailed, up to date and ready", func() {
 		ctx := context.TODO()
@@ -377,6 +690,94 @@ var _ = Describe("UpgradeStateManager tests", func() {
 		Expect(getNodeUpgradeState(podRestartNode)).To(Equal(upgrade.UpgradeStateUncordonRequired))
 		Expect(getNodeUpgradeState(drainFailedNode)).To(Equal(upgrade.UpgradeStateUncordonRequired))
 	})
+	It("UpgradeStateManager should soak restarted driver pod before uncordon if soak is enabled", func() {
+		ctx := context.TODO()
+
+		daemonSet := &appsv1.DaemonSet{ObjectMeta: v1.ObjectMeta{Generation: 3}}
+		pod := &corev1.Pod{
+			Status: corev1.PodStatus{
+				Phase:             "Running",
+				ContainerStatuses: []corev1.ContainerStatus{{Ready: true}},
+			},
+			ObjectMeta: v1.ObjectMeta{
+				Labels:            map[string]string{utils.PodTemplateGenerationLabel: "3"},
+				CreationTimestamp: v1.NewTime(time.Now().Add(-time.Hour)),
+			}}
+		node := nodeWithUpgradeState(upgrade.UpgradeStatePodRestart)
+
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStatePodRestart] = []*upgrade.NodeUpgradeState{
+			{Node: node, DriverPod: pod, DriverDaemonSet: daemonSet},
+		}
+
+		policy := &v1alpha1.OfedUpgradePolicySpec{
+			AutoUpgrade: true,
+			SoakSeconds: 600,
+		}
+
+		stateManager := upgrade.NewClusterUpdateStateManager(
+			&drainManager, &podDeleteManager, &uncordonManager, &nodeUpgradeStateProvider, log, k8sClient, k8sInterface)
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStatePostUpgradeSoak))
+		Expect(node.Annotations).To(HaveKey(upgrade.UpgradeSoakStartTimestampAnnotation))
+
+		clusterState = upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStatePostUpgradeSoak] = []*upgrade.NodeUpgradeState{
+			{Node: node, DriverPod: pod, DriverDaemonSet: daemonSet},
+		}
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStatePostUpgradeSoak))
+
+		node.Annotations[upgrade.UpgradeSoakStartTimestampAnnotation] =
+			time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateUncordonRequired))
+	})
+	It("UpgradeStateManager should move node to UpgradeFailed state if driver pod restarts during the soak", func() {
+		ctx := context.TODO()
+
+		soakStart := time.Now().Add(-time.Minute)
+		daemonSet := &appsv1.DaemonSet{ObjectMeta: v1.ObjectMeta{Generation: 3}}
+		pod := &corev1.Pod{
+			Status: corev1.PodStatus{
+				Phase: "Running",
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Ready: true,
+					LastTerminationState: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{FinishedAt: v1.NewTime(time.Now())},
+					},
+				}},
+			},
+			ObjectMeta: v1.ObjectMeta{
+				Labels:            map[string]string{utils.PodTemplateGenerationLabel: "3"},
+				CreationTimestamp: v1.NewTime(time.Now().Add(-time.Hour)),
+			}}
+		node := nodeWithUpgradeState(upgrade.UpgradeStatePostUpgradeSoak)
+		node.Annotations[upgrade.UpgradeSoakStartTimestampAnnotation] = soakStart.UTC().Format(time.RFC3339)
+
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStatePostUpgradeSoak] = []*upgrade.NodeUpgradeState{
+			{Node: node, DriverPod: pod, DriverDaemonSet: daemonSet},
+		}
+
+		policy := &v1alpha1.OfedUpgradePolicySpec{
+			AutoUpgrade: true,
+			SoakSeconds: 600,
+		}
+
+		stateManager := upgrade.NewClusterUpdateStateManager(
+			&drainManager, &podDeleteManager, &uncordonManager, &nodeUpgradeStateProvider, log, k8sClient, k8sInterface)
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateFailed))
+
+		// the failed node doesn't recover until the driver pod is recreated
+		clusterState = upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStateFailed] = []*upgrade.NodeUpgradeState{
+			{Node: node, DriverPod: pod, DriverDaemonSet: daemonSet},
+		}
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateFailed))
+	})
 	It("UpgradeStateManager should uncordon UncordonRequired pod and finish upgrade", func() {
 		ctx := context.TODO()
 
@@ -405,6 +806,588 @@ var _ = Describe("UpgradeStateManager tests", func() {
 			&drainManager, &podDeleteManager, &uncordonManagerMock, &nodeUpgradeStateProvider, log, k8sClient, k8sInterface)
 		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
 		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateDone))
+		Expect(node.Annotations).To(HaveKey(upgrade.UpgradeDoneTimestampAnnotation))
+	})
+	It("UpgradeStateManager should retry Ready check of uncordoned node before moving it to UpgradeFailed", func() {
+		ctx := context.TODO()
+
+		daemonSet := &appsv1.DaemonSet{ObjectMeta: v1.ObjectMeta{Generation: 3}}
+		pod := &corev1.Pod{
+			Status: corev1.PodStatus{
+				Phase:             "Running",
+				ContainerStatuses: []corev1.ContainerStatus{{Ready: true}},
+			},
+			ObjectMeta: v1.ObjectMeta{Labels: map[string]string{utils.PodTemplateGenerationLabel: "3"}}}
+		node := nodeWithUpgradeState(upgrade.UpgradeStateUncordonRequired)
+		node.Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionUnknown}}
+
+		policy := &v1alpha1.OfedUpgradePolicySpec{
+			AutoUpgrade:                 true,
+			UncordonReadyRetries:        1,
+			UncordonReadyBackoffSeconds: 60,
+		}
+		applyState := func(state string) {
+			clusterState := upgrade.NewClusterUpgradeState()
+			clusterState.NodeStates[state] = []*upgrade.NodeUpgradeState{
+				{Node: node, DriverPod: pod, DriverDaemonSet: daemonSet},
+			}
+			stateManager := upgrade.NewClusterUpdateStateManager(
+				&drainManager, &podDeleteManager, &uncordonManager, &nodeUpgradeStateProvider, log, k8sClient, k8sInterface)
+			Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		}
+
+		applyState(upgrade.UpgradeStateUncordonRequired)
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateUncordonRequired))
+		Expect(node.Annotations).To(HaveKeyWithValue(upgrade.UpgradeUncordonRetriesAnnotation, "0"))
+
+		// the node is not checked again until the backoff expires
+		node.Annotations[upgrade.UpgradeUncordonCheckTimestampAnnotation] =
+			time.Now().Add(-30 * time.Second).UTC().Format(time.RFC3339)
+		applyState(upgrade.UpgradeStateUncordonRequired)
+		Expect(node.Annotations).To(HaveKeyWithValue(upgrade.UpgradeUncordonRetriesAnnotation, "0"))
+
+		node.Annotations[upgrade.UpgradeUncordonCheckTimestampAnnotation] =
+			time.Now().Add(-90 * time.Second).UTC().Format(time.RFC3339)
+		applyState(upgrade.UpgradeStateUncordonRequired)
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateUncordonRequired))
+		Expect(node.Annotations).To(HaveKeyWithValue(upgrade.UpgradeUncordonRetriesAnnotation, "1"))
+
+		// the backoff is doubled after the retry
+		node.Annotations[upgrade.UpgradeUncordonCheckTimestampAnnotation] =
+			time.Now().Add(-90 * time.Second).UTC().Format(time.RFC3339)
+		applyState(upgrade.UpgradeStateUncordonRequired)
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateUncordonRequired))
+
+		node.Annotations[upgrade.UpgradeUncordonCheckTimestampAnnotation] =
+			time.Now().Add(-150 * time.Second).UTC().Format(time.RFC3339)
+		applyState(upgrade.UpgradeStateUncordonRequired)
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateFailed))
+
+		// the failed node recovers only when it is Ready
+		applyState(upgrade.UpgradeStateFailed)
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateFailed))
+
+		node.Status.Conditions[0].Status = corev1.ConditionTrue
+		applyState(upgrade.UpgradeStateFailed)
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateUncordonRequired))
+		Expect(node.Annotations).NotTo(HaveKey(upgrade.UpgradeUncordonRetriesAnnotation))
+
+		applyState(upgrade.UpgradeStateUncordonRequired)
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateDone))
+	})
+	It("UpgradeStateManager should wait for DaemonSet pods on uncordoned node before moving it to UpgradeDone", func() {
+		ctx := context.TODO()
+
+		daemonSet := &appsv1.DaemonSet{ObjectMeta: v1.ObjectMeta{Generation: 3}}
+		pod := &corev1.Pod{
+			Status: corev1.PodStatus{
+				Phase:             "Running",
+				ContainerStatuses: []corev1.ContainerStatus{{Ready: true}},
+			},
+			ObjectMeta: v1.ObjectMeta{Labels: map[string]string{utils.PodTemplateGenerationLabel: "3"}}}
+		node := nodeWithUpgradeState(upgrade.UpgradeStateUncordonRequired)
+
+		notReady := []string{"default/critical"}
+		uncordonManagerMock := mocks.UncordonManager{}
+		uncordonManagerMock.On("CordonOrUncordonNode", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		uncordonManagerMock.On("NotReadyDaemonSetPods", mock.Anything, mock.Anything, "app=critical").
+			Return(func(context.Context, *corev1.Node, string) []string { return notReady }, nil)
+
+		policy := &v1alpha1.OfedUpgradePolicySpec{
+			AutoUpgrade:         true,
+			UncordonWaitForPods: &v1alpha1.UncordonWaitForPodsSpec{PodSelector: "app=critical", TimeoutSeconds: 60},
+		}
+		applyState := func(state string) {
+			clusterState := upgrade.NewClusterUpgradeState()
+			clusterState.NodeStates[state] = []*upgrade.NodeUpgradeState{
+				{Node: node, DriverPod: pod, DriverDaemonSet: daemonSet},
+			}
+			stateManager := upgrade.NewClusterUpdateStateManager(
+				&drainManager, &podDeleteManager, &uncordonManagerMock, &nodeUpgradeStateProvider, log, k8sClient,
+				k8sInterface)
+			Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		}
+
+		applyState(upgrade.UpgradeStateUncordonRequired)
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateUncordonRequired))
+		Expect(node.Annotations).To(HaveKey(upgrade.UpgradeUncordonPodsWaitStartAnnotation))
+
+		// the node fails the upgrade if the pods are not Ready in time
+		node.Annotations[upgrade.UpgradeUncordonPodsWaitStartAnnotation] =
+			time.Now().Add(-90 * time.Second).UTC().Format(time.RFC3339)
+		applyState(upgrade.UpgradeStateUncordonRequired)
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateFailed))
+
+		// the failed node recovers only when the pods are Ready
+		applyState(upgrade.UpgradeStateFailed)
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateFailed))
+
+		notReady = nil
+		applyState(upgrade.UpgradeStateFailed)
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateUncordonRequired))
+		Expect(node.Annotations).NotTo(HaveKey(upgrade.UpgradeUncordonPodsWaitStartAnnotation))
+
+		applyState(upgrade.UpgradeStateUncordonRequired)
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateDone))
+	})
+	It("UpgradeStateManager should not start new upgrades during the cooldown period", func() {
+		ctx := context.TODO()
+
+		doneNode := nodeWithUpgradeState(upgrade.UpgradeStateDone)
+		doneNode.Annotations[upgrade.UpgradeDoneTimestampAnnotation] = time.Now().UTC().Format(time.RFC3339)
+		upgradeRequiredNode := nodeWithUpgradeState(upgrade.UpgradeStateUpgradeRequired)
+
+		daemonSet := &appsv1.DaemonSet{ObjectMeta: v1.ObjectMeta{Generation: 1}}
+		upToDatePod := &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{Labels: map[string]string{utils.PodTemplateGenerationLabel: "1"}}}
+
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStateDone] = []*upgrade.NodeUpgradeState{
+			{Node: doneNode, DriverPod: upToDatePod, DriverDaemonSet: daemonSet},
+		}
+		clusterState.NodeStates[upgrade.UpgradeStateUpgradeRequired] = []*upgrade.NodeUpgradeState{
+			{Node: upgradeRequiredNode},
+		}
+
+		policy := &v1alpha1.OfedUpgradePolicySpec{
+			AutoUpgrade:     true,
+			CooldownSeconds: 3600,
+		}
+
+		stateManager := upgrade.NewClusterUpdateStateManager(
+			&drainManager, &podDeleteManager, &uncordonManager, &nodeUpgradeStateProvider, log, k8sClient, k8sInterface)
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(getNodeUpgradeState(upgradeRequiredNode)).To(Equal(upgrade.UpgradeStateUpgradeRequired))
+
+		doneNode.Annotations[upgrade.UpgradeDoneTimestampAnnotation] =
+			time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(getNodeUpgradeState(upgradeRequiredNode)).To(Equal(upgrade.UpgradeStateDrain))
+	})
+	It("UpgradeStateManager should move node to UpgradeFailed state if restarted pod is crash looping", func() {
+		ctx := context.TODO()
+
+		daemonSet := &appsv1.DaemonSet{ObjectMeta: v1.ObjectMeta{Generation: 2}}
+		crashLoopingPod := &corev1.Pod{
+			Status: corev1.PodStatus{
+				Phase: "Running",
+				ContainerStatuses: []corev1.ContainerStatus{{
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				}},
+			},
+			ObjectMeta: v1.ObjectMeta{Labels: map[string]string{utils.PodTemplateGenerationLabel: "2"}}}
+		node := nodeWithUpgradeState(upgrade.UpgradeStatePodRestart)
+
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStatePodRestart] = []*upgrade.NodeUpgradeState{
+			{Node: node, DriverPod: crashLoopingPod, DriverDaemonSet: daemonSet},
+		}
+
+		policy := &v1alpha1.OfedUpgradePolicySpec{
+			AutoUpgrade: true,
+		}
+
+		stateManager := upgrade.NewClusterUpdateStateManager(
+			&drainManager, &podDeleteManager, &uncordonManager, &nodeUpgradeStateProvider, log, k8sClient, k8sInterface)
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateFailed))
+	})
+	It("UpgradeStateManager should move node to UpgradeFailed state until the driver validation succeeds", func() {
+		ctx := context.TODO()
+
+		daemonSet := &appsv1.DaemonSet{ObjectMeta: v1.ObjectMeta{Generation: 2}}
+		pod := &corev1.Pod{
+			Status: corev1.PodStatus{
+				Phase:             "Running",
+				ContainerStatuses: []corev1.ContainerStatus{{Ready: true}},
+			},
+			ObjectMeta: v1.ObjectMeta{Labels: map[string]string{utils.PodTemplateGenerationLabel: "2"}}}
+		node := nodeWithUpgradeState(upgrade.UpgradeStatePodRestart)
+		policy := &v1alpha1.OfedUpgradePolicySpec{
+			AutoUpgrade:      true,
+			DriverValidation: &v1alpha1.DriverValidationSpec{Command: []string{"ibstat"}},
+		}
+
+		driverValidatorMock := mocks.DriverValidator{}
+		driverValidatorMock.
+			On("ValidateDriver", mock.Anything, pod, policy.DriverValidation).
+			Return("State: Down", errors.New("command terminated with exit code 1")).Twice()
+		driverValidatorMock.
+			On("ValidateDriver", mock.Anything, pod, policy.DriverValidation).
+			Return("State: Active", nil)
+		provider, lastNode := recordingNodeUpgradeStateProvider(node)
+		stateManager := upgrade.NewClusterUpdateStateManager(
+			&drainManager, &podDeleteManager, &uncordonManager, provider, log, k8sClient, k8sInterface)
+		stateManager.DriverValidator = &driverValidatorMock
+
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStatePodRestart] = []*upgrade.NodeUpgradeState{
+			{Node: node, DriverPod: pod, DriverDaemonSet: daemonSet},
+		}
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Eventually(func() string { return getNodeUpgradeState(lastNode()) }).Should(Equal(upgrade.UpgradeStateFailed))
+		Expect(lastNode().Annotations).To(
+			HaveKeyWithValue(upgrade.UpgradeDriverValidationFailedAnnotation, "State: Down"))
+		Expect(lastNode().Annotations).To(HaveKeyWithValue(upgrade.UpgradeFailuresAnnotation, "1"))
+		// the node of the cluster state is not changed by the validation in the background
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStatePodRestart))
+
+		// the validation is retried in the failed state without counting another failure,
+		// the node is validated again once the previous validation has finished
+		Eventually(func() int {
+			clusterState = upgrade.NewClusterUpgradeState()
+			clusterState.NodeStates[upgrade.UpgradeStateFailed] = []*upgrade.NodeUpgradeState{
+				{Node: lastNode(), DriverPod: pod, DriverDaemonSet: daemonSet},
+			}
+			Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+			return len(driverValidatorMock.Calls)
+		}).Should(Equal(2))
+		Expect(getNodeUpgradeState(lastNode())).To(Equal(upgrade.UpgradeStateFailed))
+		Expect(lastNode().Annotations).To(HaveKeyWithValue(upgrade.UpgradeFailuresAnnotation, "1"))
+
+		Eventually(func() string {
+			clusterState = upgrade.NewClusterUpgradeState()
+			clusterState.NodeStates[upgrade.UpgradeStateFailed] = []*upgrade.NodeUpgradeState{
+				{Node: lastNode(), DriverPod: pod, DriverDaemonSet: daemonSet},
+			}
+			Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+			return getNodeUpgradeState(lastNode())
+		}).Should(Equal(upgrade.UpgradeStateUncordonRequired))
+		Expect(lastNode().Annotations).NotTo(HaveKey(upgrade.UpgradeDriverValidationFailedAnnotation))
+	})
+	It("UpgradeStateManager should not block on the driver validations in progress", func() {
+		ctx := context.TODO()
+
+		daemonSet := &appsv1.DaemonSet{ObjectMeta: v1.ObjectMeta{Generation: 2}}
+		pod := &corev1.Pod{
+			Status: corev1.PodStatus{
+				Phase:             "Running",
+				ContainerStatuses: []corev1.ContainerStatus{{Ready: true}},
+			},
+			ObjectMeta: v1.ObjectMeta{Labels: map[string]string{utils.PodTemplateGenerationLabel: "2"}}}
+		node := nodeWithUpgradeState(upgrade.UpgradeStatePodRestart)
+		policy := &v1alpha1.OfedUpgradePolicySpec{
+			AutoUpgrade:      true,
+			DriverValidation: &v1alpha1.DriverValidationSpec{Command: []string{"ibstat"}},
+		}
+
+		finish := make(chan struct{})
+		driverValidatorMock := mocks.DriverValidator{}
+		driverValidatorMock.
+			On("ValidateDriver", mock.Anything, pod, policy.DriverValidation).
+			Run(func(mock.Arguments) { <-finish }).
+			Return("State: Active", nil)
+		provider, lastNode := recordingNodeUpgradeStateProvider(node)
+		stateManager := upgrade.NewClusterUpdateStateManager(
+			&drainManager, &podDeleteManager, &uncordonManager, provider, log, k8sClient, k8sInterface)
+		stateManager.DriverValidator = &driverValidatorMock
+
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStatePodRestart] = []*upgrade.NodeUpgradeState{
+			{Node: node, DriverPod: pod, DriverDaemonSet: daemonSet},
+		}
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(getNodeUpgradeState(lastNode())).To(Equal(upgrade.UpgradeStatePodRestart))
+
+		// the node is not validated again while its validation is in progress
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		close(finish)
+		Eventually(func() string {
+			return getNodeUpgradeState(lastNode())
+		}).Should(Equal(upgrade.UpgradeStateUncordonRequired))
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStatePodRestart))
+		driverValidatorMock.AssertNumberOfCalls(GinkgoT(), "ValidateDriver", 1)
+	})
+	It("UpgradeStateManager should not count failed nodes as upgrades in progress", func() {
+		ctx := context.TODO()
+
+		upgradeRequiredNode := nodeWithUpgradeState(upgrade.UpgradeStateUpgradeRequired)
+		daemonSet := &appsv1.DaemonSet{ObjectMeta: v1.ObjectMeta{Generation: 2}}
+		outdatedPod := &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{Labels: map[string]string{utils.PodTemplateGenerationLabel: "1"}}}
+
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStateUpgradeRequired] = []*upgrade.NodeUpgradeState{
+			{Node: upgradeRequiredNode},
+		}
+		clusterState.NodeStates[upgrade.UpgradeStateFailed] = []*upgrade.NodeUpgradeState{
+			{Node: nodeWithUpgradeState(upgrade.UpgradeStateFailed), DriverPod: outdatedPod, DriverDaemonSet: daemonSet},
+		}
+
+		policy := &v1alpha1.OfedUpgradePolicySpec{
+			AutoUpgrade:         true,
+			MaxParallelUpgrades: 1,
+		}
+
+		stateManager := upgrade.NewClusterUpdateStateManager(
+			&drainManager, &podDeleteManager, &uncordonManager, &nodeUpgradeStateProvider, log, k8sClient, k8sInterface)
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(getNodeUpgradeState(upgradeRequiredNode)).To(Equal(upgrade.UpgradeStateDrain))
+	})
+	It("UpgradeStateManager should not start new upgrades if max failures limit is reached", func() {
+		ctx := context.TODO()
+
+		upgradeRequiredNode := nodeWithUpgradeState(upgrade.UpgradeStateUpgradeRequired)
+		daemonSet := &appsv1.DaemonSet{ObjectMeta: v1.ObjectMeta{Generation: 2}}
+		outdatedPod := &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{Labels: map[string]string{utils.PodTemplateGenerationLabel: "1"}}}
+
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStateUpgradeRequired] = []*upgrade.NodeUpgradeState{
+			{Node: upgradeRequiredNode},
+		}
+		clusterState.NodeStates[upgrade.UpgradeStateFailed] = []*upgrade.NodeUpgradeState{
+			{Node: nodeWithUpgradeState(upgrade.UpgradeStateFailed), DriverPod: outdatedPod, DriverDaemonSet: daemonSet},
+		}
+		clusterState.NodeStates[upgrade.UpgradeStateDrainFailed] = []*upgrade.NodeUpgradeState{
+			{Node: nodeWithUpgradeState(upgrade.UpgradeStateDrainFailed), DriverPod: outdatedPod, DriverDaemonSet: daemonSet},
+		}
+
+		policy := &v1alpha1.OfedUpgradePolicySpec{
+			AutoUpgrade: true,
+			MaxFailures: 2,
+		}
+
+		stateManager := upgrade.NewClusterUpdateStateManager(
+			&drainManager, &podDeleteManager, &uncordonManager, &nodeUpgradeStateProvider, log, k8sClient, k8sInterface)
+		Expect(stateManager.IsUpgradeAborted(&clusterState, policy)).To(BeTrue())
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(getNodeUpgradeState(upgradeRequiredNode)).To(Equal(upgrade.UpgradeStateUpgradeRequired))
+
+		policy.MaxFailures = 3
+		Expect(stateManager.IsUpgradeAborted(&clusterState, policy)).To(BeFalse())
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(getNodeUpgradeState(upgradeRequiredNode)).To(Equal(upgrade.UpgradeStateDrain))
+	})
+	It("UpgradeStateManager should report nodes exceeding the max pre-upgrade state time", func() {
+		stalledNode := nodeWithUpgradeState(upgrade.UpgradeStateDrain)
+		stalledNode.Name = "stalled"
+		stalledNode.Annotations[upgrade.UpgradeStateTimestampAnnotation] =
+			time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+		recentNode := nodeWithUpgradeState(upgrade.UpgradeStateUpgradeRequired)
+		recentNode.Name = "recent"
+		recentNode.Annotations[upgrade.UpgradeStateTimestampAnnotation] = time.Now().UTC().Format(time.RFC3339)
+		failedNode := nodeWithUpgradeState(upgrade.UpgradeStateFailed)
+		failedNode.Name = "failed"
+		failedNode.Annotations[upgrade.UpgradeStateTimestampAnnotation] =
+			time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStateDrain] = []*upgrade.NodeUpgradeState{{Node: stalledNode}}
+		clusterState.NodeStates[upgrade.UpgradeStateUpgradeRequired] = []*upgrade.NodeUpgradeState{
+			{Node: recentNode}, {Node: nodeWithUpgradeState(upgrade.UpgradeStateUpgradeRequired)}}
+		clusterState.NodeStates[upgrade.UpgradeStateFailed] = []*upgrade.NodeUpgradeState{{Node: failedNode}}
+
+		policy := &v1alpha1.OfedUpgradePolicySpec{AutoUpgrade: true}
+		stateManager := upgrade.NewClusterUpdateStateManager(
+			&drainManager, &podDeleteManager, &uncordonManager, &nodeUpgradeStateProvider, log, k8sClient, k8sInterface)
+		Expect(stateManager.StalledNodes(&clusterState, policy)).To(BeEmpty())
+
+		policy.MaxPreUpgradeStateSeconds = 600
+		Expect(stateManager.StalledNodes(&clusterState, policy)).To(Equal([]string{"stalled"}))
+	})
+	It("UpgradeStateManager should report unschedulable nodes in upgrade states after cordon", func() {
+		cordonedNode := func(name, state string, unschedulable bool) *upgrade.NodeUpgradeState {
+			node := nodeWithUpgradeState(state)
+			node.Name = name
+			node.Spec.Unschedulable = unschedulable
+			return &upgrade.NodeUpgradeState{Node: node}
+		}
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStateDone] = []*upgrade.NodeUpgradeState{
+			cordonedNode("cordoned-by-admin", upgrade.UpgradeStateDone, true)}
+		clusterState.NodeStates[upgrade.UpgradeStateDrain] = []*upgrade.NodeUpgradeState{
+			cordonedNode("drain", upgrade.UpgradeStateDrain, true),
+			cordonedNode("drain-not-cordoned-yet", upgrade.UpgradeStateDrain, false)}
+		clusterState.NodeStates[upgrade.UpgradeStatePodRestart] = []*upgrade.NodeUpgradeState{
+			cordonedNode("pod-restart", upgrade.UpgradeStatePodRestart, true)}
+		clusterState.NodeStates[upgrade.UpgradeStateUncordonRequired] = []*upgrade.NodeUpgradeState{
+			cordonedNode("a-uncordon-required", upgrade.UpgradeStateUncordonRequired, true),
+			cordonedNode("uncordoned", upgrade.UpgradeStateUncordonRequired, false)}
+
+		stateManager := upgrade.NewClusterUpdateStateManager(
+			&drainManager, &podDeleteManager, &uncordonManager, &nodeUpgradeStateProvider, log, k8sClient, k8sInterface)
+		Expect(stateManager.CordonedNodes(&clusterState)).To(
+			Equal([]string{"a-uncordon-required", "drain", "pod-restart"}))
+	})
+	It("UpgradeStateManager should not cordon more nodes than max unavailable nodes", func() {
+		ctx := context.TODO()
+
+		daemonSet := &appsv1.DaemonSet{ObjectMeta: v1.ObjectMeta{Generation: 2}}
+		outdatedPod := &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{Labels: map[string]string{utils.PodTemplateGenerationLabel: "1"}}}
+		drainFailedNode := func(unschedulable bool) *upgrade.NodeUpgradeState {
+			node := nodeWithUpgradeState(upgrade.UpgradeStateDrainFailed)
+			node.Spec.Unschedulable = unschedulable
+			return &upgrade.NodeUpgradeState{Node: node, DriverPod: outdatedPod, DriverDaemonSet: daemonSet}
+		}
+		upgradeRequiredNodes := []*corev1.Node{
+			nodeWithUpgradeState(upgrade.UpgradeStateUpgradeRequired),
+			nodeWithUpgradeState(upgrade.UpgradeStateUpgradeRequired),
+			nodeWithUpgradeState(upgrade.UpgradeStateUpgradeRequired),
+		}
+
+		clusterState := upgrade.NewClusterUpgradeState()
+		for _, node := range upgradeRequiredNodes {
+			clusterState.NodeStates[upgrade.UpgradeStateUpgradeRequired] = append(
+				clusterState.NodeStates[upgrade.UpgradeStateUpgradeRequired], &upgrade.NodeUpgradeState{Node: node})
+		}
+		clusterState.NodeStates[upgrade.UpgradeStateDrainFailed] = []*upgrade.NodeUpgradeState{
+			drainFailedNode(true), drainFailedNode(false)}
+
+		policy := &v1alpha1.OfedUpgradePolicySpec{
+			AutoUpgrade:         true,
+			MaxParallelUpgrades: 0,
+			MaxUnavailableNodes: 2,
+		}
+
+		stateManager := upgrade.NewClusterUpdateStateManager(
+			&drainManager, &podDeleteManager, &uncordonManager, &nodeUpgradeStateProvider, log, k8sClient, k8sInterface)
+		Expect(stateManager.UnavailableNodesCount(&clusterState)).To(Equal(1))
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+
+		drainNodes := 0
+		for _, node := range upgradeRequiredNodes {
+			if getNodeUpgradeState(node) == upgrade.UpgradeStateDrain {
+				drainNodes++
+			}
+		}
+		Expect(drainNodes).To(Equal(1))
+	})
+	It("UpgradeStateManager should wait for approval of the target driver image if approval is required", func() {
+		ctx := context.TODO()
+
+		daemonSet := &appsv1.DaemonSet{ObjectMeta: v1.ObjectMeta{Generation: 2}}
+		daemonSet.Spec.Template.Spec.Containers = []corev1.Container{
+			{Name: upgrade.OfedDriverContainerName, Image: "mellanox/mofed-5.7:ubuntu20.04"}}
+		outdatedPod := &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{Labels: map[string]string{utils.PodTemplateGenerationLabel: "1"}}}
+		node := nodeWithUpgradeState(upgrade.UpgradeStateUpgradeRequired)
+
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStateUpgradeRequired] = []*upgrade.NodeUpgradeState{
+			{Node: node, DriverPod: outdatedPod, DriverDaemonSet: daemonSet},
+		}
+		clusterState.ApprovedImages = []string{"mellanox/mofed-5.6:ubuntu20.04"}
+
+		policy := &v1alpha1.OfedUpgradePolicySpec{
+			AutoUpgrade:     true,
+			RequireApproval: true,
+		}
+
+		stateManager := upgrade.NewClusterUpdateStateManager(
+			&drainManager, &podDeleteManager, &uncordonManager, &nodeUpgradeStateProvider, log, k8sClient, k8sInterface)
+		Expect(stateManager.ImagesPendingApproval(&clusterState, policy)).To(
+			Equal([]string{"mellanox/mofed-5.7:ubuntu20.04"}))
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStatePendingApproval))
+
+		clusterState = upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStatePendingApproval] = []*upgrade.NodeUpgradeState{
+			{Node: node, DriverPod: outdatedPod, DriverDaemonSet: daemonSet},
+		}
+		clusterState.ApprovedImages = []string{"mellanox/mofed-5.6:ubuntu20.04"}
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStatePendingApproval))
+
+		clusterState.ApprovedImages = []string{"mellanox/mofed-5.7:ubuntu20.04"}
+		Expect(stateManager.ImagesPendingApproval(&clusterState, policy)).To(BeEmpty())
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateUpgradeRequired))
+	})
+	It("UpgradeStateManager should move pending approval node to Done if upgrade is not required anymore", func() {
+		ctx := context.TODO()
+
+		daemonSet := &appsv1.DaemonSet{ObjectMeta: v1.ObjectMeta{Generation: 2}}
+		upToDatePod := &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{Labels: map[string]string{utils.PodTemplateGenerationLabel: "2"}}}
+		node := nodeWithUpgradeState(upgrade.UpgradeStatePendingApproval)
+
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStatePendingApproval] = []*upgrade.NodeUpgradeState{
+			{Node: node, DriverPod: upToDatePod, DriverDaemonSet: daemonSet},
+		}
+
+		policy := &v1alpha1.OfedUpgradePolicySpec{
+			AutoUpgrade:     true,
+			RequireApproval: true,
+		}
+
+		stateManager := upgrade.NewClusterUpdateStateManager(
+			&drainManager, &podDeleteManager, &uncordonManager, &nodeUpgradeStateProvider, log, k8sClient, k8sInterface)
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateDone))
+	})
+	It("UpgradeStateManager should skip nodes of node groups which don't allow disruption", func() {
+		ctx := context.TODO()
+
+		daemonSet := &appsv1.DaemonSet{ObjectMeta: v1.ObjectMeta{Generation: 2}}
+		outdatedPod := &corev1.Pod{
+			Status:     corev1.PodStatus{Phase: "Running", ContainerStatuses: []corev1.ContainerStatus{{Ready: true}}},
+			ObjectMeta: v1.ObjectMeta{Labels: map[string]string{utils.PodTemplateGenerationLabel: "1"}}}
+		manualNode := nodeWithUpgradeState(upgrade.UpgradeStateUpgradeRequired)
+		manualNode.Name = "manual-node"
+		manualNode.Labels = map[string]string{"storage": "true"}
+		automaticNode := nodeWithUpgradeState(upgrade.UpgradeStateUpgradeRequired)
+		automaticNode.Name = "automatic-node"
+
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStateUpgradeRequired] = []*upgrade.NodeUpgradeState{
+			{Node: manualNode, DriverPod: outdatedPod, DriverDaemonSet: daemonSet},
+			{Node: automaticNode, DriverPod: outdatedPod, DriverDaemonSet: daemonSet},
+		}
+
+		disruptionAllowed := false
+		policy := &v1alpha1.OfedUpgradePolicySpec{
+			AutoUpgrade:               true,
+			MaxParallelUpgrades:       1,
+			MaxPreUpgradeStateSeconds: 1,
+			NodeGroups: []v1alpha1.UpgradeNodeGroupSpec{{
+				Name:              "storage",
+				NodeSelector:      v1.LabelSelector{MatchLabels: map[string]string{"storage": "true"}},
+				DisruptionAllowed: &disruptionAllowed,
+			}},
+		}
+		manualNode.Annotations[upgrade.UpgradeStateTimestampAnnotation] =
+			time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+
+		stateManager := upgrade.NewClusterUpdateStateManager(
+			&drainManager, &podDeleteManager, &uncordonManager, &nodeUpgradeStateProvider, log, k8sClient, k8sInterface)
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(getNodeUpgradeState(manualNode)).To(Equal(upgrade.UpgradeStateUpgradeRequired))
+		Expect(manualNode.Annotations[upgrade.UpgradeManualRequiredAnnotation]).To(Equal("storage"))
+		Expect(getNodeUpgradeState(automaticNode)).To(Equal(upgrade.UpgradeStateDrain))
+		Expect(automaticNode.Annotations).NotTo(HaveKey(upgrade.UpgradeManualRequiredAnnotation))
+		Expect(stateManager.ManualUpgradeNodes(&clusterState)).To(Equal([]string{"manual-node"}))
+		Expect(stateManager.StalledNodes(&clusterState, policy)).To(BeEmpty())
+
+		// the driver pod was restarted manually
+		restartedPod := outdatedPod.DeepCopy()
+		restartedPod.Labels[utils.PodTemplateGenerationLabel] = "2"
+		clusterState = upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStateUpgradeRequired] = []*upgrade.NodeUpgradeState{
+			{Node: manualNode, DriverPod: restartedPod, DriverDaemonSet: daemonSet},
+		}
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(getNodeUpgradeState(manualNode)).To(Equal(upgrade.UpgradeStateDone))
+		Expect(manualNode.Annotations).NotTo(HaveKey(upgrade.UpgradeManualRequiredAnnotation))
+	})
+	It("UpgradeStateManager should remove manual upgrade annotation if the node group allows disruption", func() {
+		ctx := context.TODO()
+
+		node := nodeWithUpgradeState(upgrade.UpgradeStateUpgradeRequired)
+		node.Annotations[upgrade.UpgradeManualRequiredAnnotation] = "storage"
+
+		clusterState := upgrade.NewClusterUpgradeState()
+		clusterState.NodeStates[upgrade.UpgradeStateUpgradeRequired] = []*upgrade.NodeUpgradeState{{Node: node}}
+
+		policy := &v1alpha1.OfedUpgradePolicySpec{AutoUpgrade: true}
+
+		stateManager := upgrade.NewClusterUpdateStateManager(
+			&drainManager, &podDeleteManager, &uncordonManager, &nodeUpgradeStateProvider, log, k8sClient, k8sInterface)
+		Expect(stateManager.ApplyState(ctx, &clusterState, policy)).To(Succeed())
+		Expect(node.Annotations).NotTo(HaveKey(upgrade.UpgradeManualRequiredAnnotation))
+		Expect(getNodeUpgradeState(node)).To(Equal(upgrade.UpgradeStateDrain))
 	})
 	It("UpgradeStateManager should fail if uncordonManager fails", func() {
 		ctx := context.TODO()
@@ -439,3 +1422,45 @@ var _ = Describe("UpgradeStateManager tests", func() {
 func nodeWithUpgradeState(state string) *corev1.Node {
 	return &corev1.Node{ObjectMeta: v1.ObjectMeta{Annotations: map[string]string{upgrade.UpgradeStateAnnotation: state}}}
 }
+
+// recordingNodeUpgradeStateProvider changes the node like nodeUpgradeStateProvider and returns a func which returns
+// a copy of the last changed node, starting with node. The driver validation changes a copy of the node
+// of the cluster state, so its changes are only visible this way
+func recordingNodeUpgradeStateProvider(node *corev1.Node) (*mocks.NodeUpgradeStateProvider, func() *corev1.Node) {
+	var mu sync.Mutex
+	lastNode := node.DeepCopy()
+	record := func(changed *corev1.Node, change func() error) error {
+		mu.Lock()
+		defer mu.Unlock()
+		err := change()
+		lastNode = changed.DeepCopy()
+		return err
+	}
+	provider := &mocks.NodeUpgradeStateProvider{}
+	provider.
+		On("ChangeNodeUpgradeState", mock.Anything, mock.Anything, mock.Anything).
+		Return(func(ctx context.Context, node *corev1.Node, newNodeState string) error {
+			return record(node, func() error {
+				return nodeUpgradeStateProvider.ChangeNodeUpgradeState(ctx, node, newNodeState)
+			})
+		})
+	provider.
+		On("ChangeNodeUpgradeAnnotation", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(func(ctx context.Context, node *corev1.Node, key string, value string) error {
+			return record(node, func() error {
+				return nodeUpgradeStateProvider.ChangeNodeUpgradeAnnotation(ctx, node, key, value)
+			})
+		})
+	provider.
+		On("PruneNodeUpgradeAnnotations", mock.Anything, mock.Anything).
+		Return(func(ctx context.Context, node *corev1.Node) error {
+			return record(node, func() error {
+				return nodeUpgradeStateProvider.PruneNodeUpgradeAnnotations(ctx, node)
+			})
+		})
+	return provider, func() *corev1.Node {
+		mu.Lock()
+		defer mu.Unlock()
+		return lastNode.DeepCopy()
+	}
+}
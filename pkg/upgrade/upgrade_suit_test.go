@@ -18,6 +18,7 @@ package upgrade_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/go-logr/logr"
@@ -88,9 +89,31 @@ var _ = BeforeSuite(func() {
 	nodeUpgradeStateProvider.
 		On("ChangeNodeUpgradeState", mock.Anything, mock.Anything, mock.Anything).
 		Return(func(ctx context.Context, node *corev1.Node, newNodeState string) error {
+			currentState := node.Annotations[upgrade.UpgradeStateAnnotation]
+			if !upgrade.IsStateTransitionAllowed(currentState, newNodeState) {
+				return fmt.Errorf("transition from %q to %q is not allowed", currentState, newNodeState)
+			}
 			node.Annotations[upgrade.UpgradeStateAnnotation] = newNodeState
 			return nil
 		})
+	nodeUpgradeStateProvider.
+		On("ChangeNodeUpgradeAnnotation", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(func(ctx context.Context, node *corev1.Node, key string, value string) error {
+			if value == "null" {
+				delete(node.Annotations, key)
+			} else {
+				node.Annotations[key] = value
+			}
+			return nil
+		})
+	nodeUpgradeStateProvider.
+		On("PruneNodeUpgradeAnnotations", mock.Anything, mock.Anything).
+		Return(func(ctx context.Context, node *corev1.Node) error {
+			for _, key := range upgrade.ObsoleteUpgradeAnnotations(node) {
+				delete(node.Annotations, key)
+			}
+			return nil
+		})
 
 	drainManager = mocks.DrainManager{}
 	drainManager.
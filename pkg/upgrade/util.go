@@ -58,17 +58,50 @@ func (s *StringSet) Clear() {
 	s.m = make(map[string]bool)
 }
 
-// KeyedMutex is a struct that provides a per-key synchronized access
+// KeyedMutex is a struct that provides a per-key synchronized access. The mutex of a key is reference counted
+// and released once it is not locked or waited for, so the keys of deleted objects are not kept
 type KeyedMutex struct {
-	mutexes sync.Map // Zero value is empty and ready for use
+	mu      sync.Mutex
+	mutexes map[string]*keyedMutexEntry // Zero value is empty and ready for use
+}
+
+// keyedMutexEntry is the mutex of a key and the number of its holders and waiters
+type keyedMutexEntry struct {
+	sync.Mutex
+	refs int
 }
 
 type UnlockFunc = func()
 
 // Lock locks a mutex, associated with a given key and returns an unlock function
 func (m *KeyedMutex) Lock(key string) UnlockFunc {
-	value, _ := m.mutexes.LoadOrStore(key, &sync.Mutex{})
-	mtx := value.(*sync.Mutex)
-	mtx.Lock()
-	return func() { mtx.Unlock() }
+	m.mu.Lock()
+	if m.mutexes == nil {
+		m.mutexes = make(map[string]*keyedMutexEntry)
+	}
+	entry, ok := m.mutexes[key]
+	if !ok {
+		entry = &keyedMutexEntry{}
+		m.mutexes[key] = entry
+	}
+	entry.refs++
+	m.mu.Unlock()
+
+	entry.Lock()
+	return func() {
+		entry.Unlock()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		entry.refs--
+		if entry.refs == 0 {
+			delete(m.mutexes, key)
+		}
+	}
+}
+
+// Len returns the number of keys which are locked or waited for
+func (m *KeyedMutex) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.mutexes)
 }
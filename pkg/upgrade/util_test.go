@@ -0,0 +1,54 @@
+/*
+Copyright 2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/Mellanox/network-operator/pkg/upgrade"
+)
+
+var _ = Describe("KeyedMutex tests", func() {
+	It("KeyedMutex should keep the mutex of a key while it is waited for and release it once unlocked", func() {
+		mutex := upgrade.KeyedMutex{}
+		unlock := mutex.Lock("node1")
+		unlockOther := mutex.Lock("node2")
+		Expect(mutex.Len()).To(Equal(2))
+		unlockOther()
+		Expect(mutex.Len()).To(Equal(1))
+
+		locked := make(chan upgrade.UnlockFunc)
+		go func() {
+			locked <- mutex.Lock("node1")
+		}()
+		Consistently(locked).ShouldNot(Receive())
+		unlock()
+		var unlockWaiter upgrade.UnlockFunc
+		Eventually(locked).Should(Receive(&unlockWaiter))
+		// the waiter holds the same mutex, a new Lock of the key waits for it
+		Expect(mutex.Len()).To(Equal(1))
+		go func() {
+			locked <- mutex.Lock("node1")
+		}()
+		Consistently(locked).ShouldNot(Receive())
+		unlockWaiter()
+		Eventually(locked).Should(Receive(&unlockWaiter))
+		unlockWaiter()
+		Expect(mutex.Len()).To(BeZero())
+	})
+})
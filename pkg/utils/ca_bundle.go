@@ -0,0 +1,56 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// LoadCABundle returns the system certificate pool with the PEM encoded certificates of the CA bundle file appended,
+// e.g. the CA of an internal mirror. An error is returned if the file doesn't contain any certificate
+func LoadCABundle(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %v", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM encoded certificates found in CA bundle %s", file)
+	}
+	return pool, nil
+}
+
+// NewHTTPClient creates an HTTP client with the timeout which trusts the root CAs for HTTPS,
+// the system CAs are trusted if the root CAs are nil
+func NewHTTPClient(timeout time.Duration, rootCAs *x509.CertPool) *http.Client {
+	client := &http.Client{Timeout: timeout}
+	if rootCAs == nil {
+		return client
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs, MinVersion: tls.VersionTLS12}
+	client.Transport = transport
+	return client
+}
@@ -0,0 +1,77 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils_test
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/Mellanox/network-operator/pkg/utils"
+)
+
+var _ = Describe("CA bundle", func() {
+	var (
+		server *httptest.Server
+		dir    string
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		var err error
+		dir, err = os.MkdirTemp("", "ca-bundle")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("Should trust the CAs of the bundle", func() {
+		bundle := filepath.Join(dir, "ca.crt")
+		Expect(os.WriteFile(bundle,
+			pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)).To(Succeed())
+		rootCAs, err := utils.LoadCABundle(bundle)
+		Expect(err).NotTo(HaveOccurred())
+
+		resp, err := utils.NewHTTPClient(time.Second, rootCAs).Get(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		_, err = utils.NewHTTPClient(time.Second, nil).Get(server.URL)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should reject a bundle without certificates", func() {
+		bundle := filepath.Join(dir, "ca.crt")
+		Expect(os.WriteFile(bundle, []byte("not a certificate"), 0600)).To(Succeed())
+		_, err := utils.LoadCABundle(bundle)
+		Expect(err).To(HaveOccurred())
+		_, err = utils.LoadCABundle(filepath.Join(dir, "missing.crt"))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,115 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// imageBuildDateRegexp matches a build date in YYYYMMDD, YYYY-MM-DD or YYYY.MM.DD format in an image tag
+var imageBuildDateRegexp = regexp.MustCompile(
+	`(?:^|[^0-9])(20[0-9]{2})[-.]?(0[1-9]|1[0-2])[-.]?(0[1-9]|[12][0-9]|3[01])(?:[^0-9]|$)`)
+
+// driverVersionRegexp matches an OFED driver version, e.g. 5.7-0.1.2.0, in the image name or tag
+var driverVersionRegexp = regexp.MustCompile(`(?:^|[-:])([0-9]+\.[0-9]+-[0-9]+(?:\.[0-9]+)*)(?:[^0-9.]|$)`)
+
+// SplitImageTag splits the image reference to the image name and its tag or digest,
+// the tag is empty if the reference doesn't specify it
+func SplitImageTag(image string) (name, tag string) {
+	if i := strings.Index(image, "@"); i >= 0 {
+		return image[:i], image[i+1:]
+	}
+	// the tag follows the last colon after the last slash, a colon before it separates the registry port
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i], image[i+1:]
+	}
+	return image, ""
+}
+
+// ParseImageBuildDate returns the build date embedded in the image tag, e.g. 5.6-1.0.3.3-20220730,
+// false is returned if the tag doesn't contain a valid date
+func ParseImageBuildDate(tag string) (time.Time, bool) {
+	match := imageBuildDateRegexp.FindStringSubmatch(tag)
+	if match == nil {
+		return time.Time{}, false
+	}
+	date, err := time.Parse("20060102", match[1]+match[2]+match[3])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// ParseDriverVersion returns the OFED driver version embedded in the driver image reference, either in the tag,
+// e.g. mofed:5.7-0.1.2.0-ubuntu20.04-amd64, or in the image name of the old format,
+// e.g. mofed-5.4-1.0.3.0:ubuntu20.04-amd64. False is returned if the reference doesn't contain a driver version
+func ParseDriverVersion(image string) (string, bool) {
+	match := driverVersionRegexp.FindStringSubmatch(image[strings.LastIndex(image, "/")+1:])
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
+// CompareDriverVersions compares OFED driver versions, e.g. 5.7-0.1.2.0, component by component,
+// missing components are treated as zero. It returns -1, 0 or 1 if a is lower than, equal to or greater than b
+func CompareDriverVersions(a, b string) (int, error) {
+	aParts, err := driverVersionParts(a)
+	if err != nil {
+		return 0, err
+	}
+	bParts, err := driverVersionParts(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var x, y int
+		if i < len(aParts) {
+			x = aParts[i]
+		}
+		if i < len(bParts) {
+			y = bParts[i]
+		}
+		if x != y {
+			if x < y {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func driverVersionParts(version string) ([]int, error) {
+	fields := strings.FieldsFunc(version, func(r rune) bool { return r == '.' || r == '-' })
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("invalid driver version %q", version)
+	}
+	parts := make([]int, len(fields))
+	for i, field := range fields {
+		part, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid driver version %q", version)
+		}
+		parts[i] = part
+	}
+	return parts, nil
+}
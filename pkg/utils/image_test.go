@@ -0,0 +1,93 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/Mellanox/network-operator/pkg/utils"
+)
+
+var _ = Describe("Image utils", func() {
+	It("Should split image name and tag", func() {
+		for image, expected := range map[string][]string{
+			"nvcr.io/nvidia/mellanox/mofed:5.6-1.0.3.3": {"nvcr.io/nvidia/mellanox/mofed", "5.6-1.0.3.3"},
+			"localhost:5000/mofed:latest":               {"localhost:5000/mofed", "latest"},
+			"localhost:5000/mofed":                      {"localhost:5000/mofed", ""},
+			"mofed@sha256:abcd":                         {"mofed", "sha256:abcd"},
+		} {
+			name, tag := utils.SplitImageTag(image)
+			Expect([]string{name, tag}).To(Equal(expected), image)
+		}
+	})
+
+	It("Should parse build date from image tag", func() {
+		expected := time.Date(2022, time.July, 30, 0, 0, 0, 0, time.UTC)
+		for _, tag := range []string{
+			"5.6-1.0.3.3-20220730", "5.6-1.0.3.3-2022-07-30-ubuntu20.04-amd64", "2022.07.30",
+		} {
+			date, ok := utils.ParseImageBuildDate(tag)
+			Expect(ok).To(BeTrue(), tag)
+			Expect(date).To(Equal(expected), tag)
+		}
+	})
+
+	It("Should not parse build date from tag without date", func() {
+		for _, tag := range []string{"5.6-1.0.3.3", "5.6-1.0.3.3-ubuntu20.04-amd64", "20221340", "latest", ""} {
+			_, ok := utils.ParseImageBuildDate(tag)
+			Expect(ok).To(BeFalse(), tag)
+		}
+	})
+
+	It("Should parse driver version from image reference", func() {
+		for image, expected := range map[string]string{
+			"nvcr.io/nvidia/mellanox/mofed:5.7-0.1.2.0-ubuntu20.04-amd64": "5.7-0.1.2.0",
+			"nvcr.io/nvidia/mellanox/mofed-5.4-1.0.3.0:ubuntu20.04-amd64": "5.4-1.0.3.0",
+			"localhost:5000/mofed:5.6-1.0.3.3":                            "5.6-1.0.3.3",
+		} {
+			version, ok := utils.ParseDriverVersion(image)
+			Expect(ok).To(BeTrue(), image)
+			Expect(version).To(Equal(expected), image)
+		}
+		for _, image := range []string{"mofed:latest", "mofed:ubuntu20.04-amd64", "5.4-1.0.3.0/mofed:v1", "mofed"} {
+			_, ok := utils.ParseDriverVersion(image)
+			Expect(ok).To(BeFalse(), image)
+		}
+	})
+
+	It("Should compare driver versions", func() {
+		for _, c := range []struct {
+			a, b     string
+			expected int
+		}{
+			{"5.7-0.1.2.0", "5.7-0.1.2.0", 0},
+			{"5.4-1.0.3.0", "5.7-0.1.2.0", -1},
+			{"5.10-0.1.2.0", "5.9-3.1.5.0", 1},
+			{"5.7-0.1.2.0", "5.7", 1},
+			{"5.7", "5.7-0", 0},
+		} {
+			result, err := utils.CompareDriverVersions(c.a, c.b)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(c.expected), "%s vs %s", c.a, c.b)
+		}
+		_, err := utils.CompareDriverVersions("latest", "5.7-0.1.2.0")
+		Expect(err).To(HaveOccurred())
+	})
+})
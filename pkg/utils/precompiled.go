@@ -0,0 +1,106 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PrecompiledPackageChecker checks whether precompiled driver packages are available for a kernel version
+type PrecompiledPackageChecker interface {
+	// IsAvailable returns true if the repository contains precompiled packages
+	// of the driver version for the kernel version
+	IsAvailable(repository, driverVersion, kernelVersion string) (bool, error)
+}
+
+// precompiledLookupErrorTTL is the time failed lookups are cached for, so that an unreachable repository is not
+// requested on every reconcile. Failed lookups are cached for cacheTTL if it is shorter
+const precompiledLookupErrorTTL = 30 * time.Second
+
+// NewPrecompiledPackageChecker creates a PrecompiledPackageChecker which looks up packages over HTTP
+// at <repository>/<driverVersion>/<kernelVersion>/. Lookup results are cached for cacheTTL,
+// failed lookups for precompiledLookupErrorTTL.
+// HTTPS repositories are verified with the root CAs, the system CAs are used if they are nil
+func NewPrecompiledPackageChecker(timeout, cacheTTL time.Duration, rootCAs *x509.CertPool) PrecompiledPackageChecker {
+	return &httpPrecompiledPackageChecker{
+		client:   NewHTTPClient(timeout, rootCAs),
+		cacheTTL: cacheTTL,
+		cache:    make(map[string]precompiledLookupResult),
+	}
+}
+
+type precompiledLookupResult struct {
+	available bool
+	err       error
+	checkedAt time.Time
+}
+
+type httpPrecompiledPackageChecker struct {
+	client   *http.Client
+	cacheTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[string]precompiledLookupResult
+}
+
+// IsAvailable returns true if the repository contains precompiled packages
+// of the driver version for the kernel version
+func (c *httpPrecompiledPackageChecker) IsAvailable(repository, driverVersion, kernelVersion string) (bool, error) {
+	url := fmt.Sprintf("%s/%s/%s/", strings.TrimSuffix(repository, "/"), driverVersion, kernelVersion)
+
+	c.mu.Lock()
+	cached, ok := c.cache[url]
+	c.mu.Unlock()
+	ttl := c.cacheTTL
+	if cached.err != nil && precompiledLookupErrorTTL < ttl {
+		ttl = precompiledLookupErrorTTL
+	}
+	if ok && time.Since(cached.checkedAt) < ttl {
+		return cached.available, cached.err
+	}
+
+	available, err := c.lookUp(url)
+	c.mu.Lock()
+	c.cache[url] = precompiledLookupResult{available: available, err: err, checkedAt: time.Now()}
+	c.mu.Unlock()
+	return available, err
+}
+
+// lookUp requests the URL of the precompiled packages
+func (c *httpPrecompiledPackageChecker) lookUp(url string) (bool, error) {
+	resp, err := c.client.Head(url)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up precompiled packages at %s: %v", url, err)
+	}
+	resp.Body.Close()
+
+	var available bool
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		available = true
+	case resp.StatusCode == http.StatusNotFound:
+		available = false
+	default:
+		return false, fmt.Errorf("unexpected status code %d for precompiled packages at %s", resp.StatusCode, url)
+	}
+	return available, nil
+}
@@ -0,0 +1,90 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/Mellanox/network-operator/pkg/utils"
+)
+
+var _ = Describe("Precompiled package checker", func() {
+	var (
+		server   *httptest.Server
+		requests int
+	)
+
+	BeforeEach(func() {
+		requests = 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			switch r.URL.Path {
+			case "/5.7-1.0.2.0/5.4.0-100-generic/":
+				w.WriteHeader(http.StatusOK)
+			case "/5.7-1.0.2.0/broken/":
+				w.WriteHeader(http.StatusInternalServerError)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Should report available and missing packages", func() {
+		checker := utils.NewPrecompiledPackageChecker(time.Second, time.Minute, nil)
+		available, err := checker.IsAvailable(server.URL+"/", "5.7-1.0.2.0", "5.4.0-100-generic")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(available).To(BeTrue())
+
+		available, err = checker.IsAvailable(server.URL, "5.7-1.0.2.0", "5.15.0-1-generic")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(available).To(BeFalse())
+
+		_, err = checker.IsAvailable(server.URL, "5.7-1.0.2.0", "broken")
+		Expect(err).To(HaveOccurred())
+	})
+	It("Should cache lookup results", func() {
+		checker := utils.NewPrecompiledPackageChecker(time.Second, time.Minute, nil)
+		for i := 0; i < 3; i++ {
+			available, err := checker.IsAvailable(server.URL, "5.7-1.0.2.0", "5.4.0-100-generic")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(available).To(BeTrue())
+		}
+		Expect(requests).To(Equal(1))
+	})
+	It("Should cache failed lookups briefly", func() {
+		checker := utils.NewPrecompiledPackageChecker(time.Second, 100*time.Millisecond, nil)
+		for i := 0; i < 3; i++ {
+			_, err := checker.IsAvailable(server.URL, "5.7-1.0.2.0", "broken")
+			Expect(err).To(HaveOccurred())
+		}
+		Expect(requests).To(Equal(1))
+
+		time.Sleep(150 * time.Millisecond)
+		_, err := checker.IsAvailable(server.URL, "5.7-1.0.2.0", "broken")
+		Expect(err).To(HaveOccurred())
+		Expect(requests).To(Equal(2))
+	})
+})
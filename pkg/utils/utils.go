@@ -22,12 +22,15 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	netattdefv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 
 	"github.com/Mellanox/network-operator/pkg/consts"
@@ -83,6 +86,54 @@ func CreateK8sInterface() (kubernetes.Interface, error) {
 	return k8sInterface, nil
 }
 
+// CreateK8sInterfaceWithRetry creates a new ClientSet for interacting with K8s API and checks that the API server
+// is reachable, so that a brief API outage at startup doesn't stop the operator. Failed attempts are retried
+// up to retries times, the initial backoff is doubled after each attempt. The reachability check of each attempt
+// is limited by timeout, the returned ClientSet has no timeout.
+func CreateK8sInterfaceWithRetry(
+	log logr.Logger, retries int, backoff, timeout time.Duration) (kubernetes.Interface, error) {
+	var k8sInterface kubernetes.Interface
+	var lastErr error
+	attempt := 0
+	err := wait.ExponentialBackoff(wait.Backoff{Duration: backoff, Factor: 2, Steps: retries + 1}, func() (bool, error) {
+		attempt++
+		k8sInterface, lastErr = createReachableK8sInterface(timeout)
+		if lastErr != nil {
+			log.V(consts.LogLevelWarning).Info("Failed to create k8s interface", "attempt", attempt,
+				"attempts", retries+1, "error", lastErr.Error())
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(lastErr, "unable to create a k8sInterface after %d attempts", attempt)
+	}
+	log.V(consts.LogLevelDebug).Info("Created k8s interface", "attempt", attempt)
+	return k8sInterface, nil
+}
+
+// createReachableK8sInterface creates a new ClientSet and requests the API server version with the given timeout
+func createReachableK8sInterface(timeout time.Duration) (kubernetes.Interface, error) {
+	k8sConfig, err := config.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get k8s config: %v", err)
+	}
+	k8sInterface, err := kubernetes.NewForConfig(k8sConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create a k8sInterface: %v", err)
+	}
+	probeConfig := rest.CopyConfig(k8sConfig)
+	probeConfig.Timeout = timeout
+	probe, err := kubernetes.NewForConfig(probeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create a k8sInterface: %v", err)
+	}
+	if _, err := probe.Discovery().ServerVersion(); err != nil {
+		return nil, fmt.Errorf("k8s API server is not reachable: %v", err)
+	}
+	return k8sInterface, nil
+}
+
 func GetPodTemplateGeneration(pod *v1.Pod, log logr.Logger) (int64, error) {
 	generation, err := strconv.ParseInt(pod.Labels[PodTemplateGenerationLabel], 10, 0)
 	if err != nil {
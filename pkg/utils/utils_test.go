@@ -0,0 +1,94 @@
+/*
+2022 NVIDIA CORPORATION & AFFILIATES
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/Mellanox/network-operator/pkg/utils"
+)
+
+var _ = Describe("K8s interface creation", func() {
+	var (
+		server     *httptest.Server
+		failures   int32
+		requests   int32
+		kubeconfig string
+		saved      string
+	)
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&requests, 0)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&requests, 1) <= atomic.LoadInt32(&failures) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"major": "1", "minor": "20", "gitVersion": "v1.20.2"}`))
+		}))
+		dir, err := os.MkdirTemp("", "kubeconfig")
+		Expect(err).NotTo(HaveOccurred())
+		kubeconfig = filepath.Join(dir, "config")
+		Expect(os.WriteFile(kubeconfig, []byte(fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: %s
+contexts:
+- name: test
+  context:
+    cluster: test
+current-context: test
+`, server.URL)), 0600)).To(Succeed())
+		saved = os.Getenv("KUBECONFIG")
+		Expect(os.Setenv("KUBECONFIG", kubeconfig)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		server.Close()
+		Expect(os.Setenv("KUBECONFIG", saved)).To(Succeed())
+		Expect(os.RemoveAll(filepath.Dir(kubeconfig))).To(Succeed())
+	})
+
+	It("Should retry while the API server is not reachable", func() {
+		atomic.StoreInt32(&failures, 2)
+		k8sInterface, err := utils.CreateK8sInterfaceWithRetry(
+			ctrl.Log, 3, 10*time.Millisecond, time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sInterface).NotTo(BeNil())
+		Expect(atomic.LoadInt32(&requests)).To(BeEquivalentTo(3))
+	})
+	It("Should fail after the retries are exhausted", func() {
+		atomic.StoreInt32(&failures, 10)
+		_, err := utils.CreateK8sInterfaceWithRetry(ctrl.Log, 2, 10*time.Millisecond, time.Second)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("after 3 attempts"))
+		Expect(atomic.LoadInt32(&requests)).To(BeEquivalentTo(3))
+	})
+})
@@ -16,8 +16,60 @@ limitations under the License.
 
 package version
 
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Path of the version endpoint, served next to the metrics endpoint
+const Path = "/version"
+
+// The variables are set at build time with ldflags
 var (
 	Version = "x.x.x"
 	Date    = "1970-01-01T00:00:00"
 	Commit  = "N/A"
+	// Components is a comma separated list of name=version of the default images of the components
+	// deployed by the operator, e.g. ofedDriver=5.6-1.0.3.3,rdmaSharedDevicePlugin=v1.3.2
+	Components = ""
 )
+
+// Info describes the operator build and the default versions of the components it deploys
+type Info struct {
+	Version    string            `json:"version"`
+	Commit     string            `json:"commit"`
+	Date       string            `json:"date"`
+	Components map[string]string `json:"components"`
+}
+
+// Get returns the version information set at build time, malformed component entries are skipped
+func Get() Info {
+	components := make(map[string]string)
+	for _, entry := range strings.Split(Components, ",") {
+		name, componentVersion, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || name == "" || componentVersion == "" {
+			continue
+		}
+		components[name] = componentVersion
+	}
+	return Info{Version: Version, Commit: Commit, Date: Date, Components: components}
+}
+
+// Handler returns an HTTP handler which responds with the version information as JSON
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := json.Marshal(Get())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	})
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2020 NVIDIA
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package version
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Version", func() {
+	var components string
+	BeforeEach(func() {
+		components = Components
+	})
+	AfterEach(func() {
+		Components = components
+	})
+
+	It("Should parse the component versions set at build time", func() {
+		Components = "ofedDriver=5.6-1.0.3.3, rdmaSharedDevicePlugin=v1.3.2,invalid,=v1,nvPeerDriver="
+		Expect(Get().Components).To(Equal(map[string]string{
+			"ofedDriver":             "5.6-1.0.3.3",
+			"rdmaSharedDevicePlugin": "v1.3.2",
+		}))
+	})
+
+	It("Should serve the version information as JSON", func() {
+		Components = "ofedDriver=5.6-1.0.3.3"
+		recorder := httptest.NewRecorder()
+		Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, Path, nil))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+		info := Info{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &info)).To(Succeed())
+		Expect(info).To(Equal(Get()))
+
+		recorder = httptest.NewRecorder()
+		Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, Path, nil))
+		Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})